@@ -17,6 +17,8 @@ import (
 	"github.com/argoproj/argo-workflows/v3/util"
 	"github.com/argoproj/argo-workflows/v3/util/cmd"
 	"github.com/argoproj/argo-workflows/v3/util/logs"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/cache"
+	artifactscommon "github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
 	"github.com/argoproj/argo-workflows/v3/workflow/common"
 	"github.com/argoproj/argo-workflows/v3/workflow/executor"
 	"github.com/argoproj/argo-workflows/v3/workflow/executor/docker"
@@ -110,11 +112,41 @@ func initExecutor() *executor.WorkflowExecutor {
 	checkErr(err)
 
 	wfExecutor := executor.NewExecutor(clientset, podName, namespace, podAnnotationsPath, cre, *tmpl)
+	wfExecutor.ArtifactCache, err = newArtifactCache()
+	checkErr(err)
+	artifactscommon.DefaultBandwidthLimit, err = artifactscommon.ParseBandwidthLimit(os.Getenv(common.EnvVarArtifactBandwidthLimit))
+	if err != nil {
+		checkErr(fmt.Errorf("%s: %w", common.EnvVarArtifactBandwidthLimit, err))
+	}
+	artifactscommon.DefaultMaxSize, err = artifactscommon.ParseMaxSize(os.Getenv(common.EnvVarArtifactMaxSize))
+	if err != nil {
+		checkErr(fmt.Errorf("%s: %w", common.EnvVarArtifactMaxSize, err))
+	}
+	artifactscommon.DefaultTempDir = os.Getenv(common.EnvVarArtifactTempDir)
+	breakerConfig, err := artifactscommon.ParseCircuitBreakerConfig(os.Getenv(common.EnvVarArtifactCircuitBreakerFailureRate), os.Getenv(common.EnvVarArtifactCircuitBreakerCooldown))
+	checkErr(err)
+	if breakerConfig != nil {
+		artifactscommon.DefaultCircuitBreakers = artifactscommon.NewCircuitBreakers(*breakerConfig)
+	}
 	yamlBytes, _ := json.Marshal(&wfExecutor.Template)
 	log.Infof("Executor (version: %s, build_date: %s) initialized (pod: %s/%s) with template:\n%s", version.Version, version.BuildDate, namespace, podName, string(yamlBytes))
 	return &wfExecutor
 }
 
+// newArtifactCache returns the cache of the input artifacts of the executor, or nil if it has none
+func newArtifactCache() (*cache.Cache, error) {
+	dir := os.Getenv(common.EnvVarArtifactCacheDir)
+	if dir == "" {
+		return nil, nil
+	}
+	maxSize, err := cache.ParseMaxSize(os.Getenv(common.EnvVarArtifactCacheMaxSize))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", common.EnvVarArtifactCacheMaxSize, err)
+	}
+	log.WithFields(log.Fields{"dir": dir, "maxSize": maxSize}).Info("Caching input artifacts")
+	return cache.New(dir, maxSize)
+}
+
 // checkErr is a convenience function to panic upon error
 func checkErr(err error) {
 	if err != nil {
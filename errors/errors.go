@@ -119,6 +119,12 @@ func (e argoerr) Message() string {
 	return e.message
 }
 
+// Unwrap returns the wrapped error, so that the standard library's errors.Is and errors.As can
+// inspect the original error
+func (e argoerr) Unwrap() error {
+	return e.stracer
+}
+
 func (e argoerr) StackTrace() errors.StackTrace {
 	return e.stracer.StackTrace()
 }
@@ -151,10 +157,14 @@ func (e argoerr) Format(s fmt.State, verb rune) {
 	}
 }
 
-// IsCode is a helper to determine if the error is of a specific code
+// IsCode is a helper to determine if the error is of a specific code. Errors other than Argo errors which have a
+// code, e.g. those which wrap an Argo error and keep its code, are of their code too.
 func IsCode(code string, err error) bool {
 	if argoErr, ok := err.(argoerr); ok {
 		return argoErr.code == code
 	}
+	if coder, ok := err.(interface{ Code() string }); ok {
+		return coder.Code() == code
+	}
 	return false
 }
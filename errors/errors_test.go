@@ -1,6 +1,7 @@
 package errors_test
 
 import (
+	stderrors "errors"
 	"fmt"
 	"testing"
 
@@ -30,6 +31,13 @@ func TestWrap(t *testing.T) {
 	assert.Equal(t, err.Error(), orig.Error())
 }
 
+func TestUnwrap(t *testing.T) {
+	err := fmt.Errorf("original error message")
+	argoErr := errors.Wrap(err, "WRAPPED", "wrapped message")
+	assert.True(t, stderrors.Is(argoErr, err))
+	assert.False(t, stderrors.Is(errors.New("MYCODE", "my message"), err))
+}
+
 // TestInternalError verifies
 func TestInternalError(t *testing.T) {
 	err := errors.InternalError("test internal")
@@ -52,3 +60,15 @@ func TestStackTrace(t *testing.T) {
 	err := errors.New("MYCODE", "my message")
 	assert.Contains(t, fmt.Sprintf("%+v", err), "errors_test.go")
 }
+
+type codedError struct{}
+
+func (codedError) Error() string { return "coded" }
+func (codedError) Code() string  { return errors.CodeNotFound }
+
+func TestIsCode(t *testing.T) {
+	assert.True(t, errors.IsCode(errors.CodeNotFound, errors.New(errors.CodeNotFound, "my message")))
+	assert.False(t, errors.IsCode(errors.CodeTimeout, errors.New(errors.CodeNotFound, "my message")))
+	assert.True(t, errors.IsCode(errors.CodeNotFound, codedError{}))
+	assert.False(t, errors.IsCode(errors.CodeNotFound, fmt.Errorf("my message")))
+}
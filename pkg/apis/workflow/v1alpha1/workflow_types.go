@@ -747,6 +747,232 @@ type Artifact struct {
 
 	// If mode is set, apply the permission recursively into the artifact if it is a folder
 	RecurseMode bool `json:"recurseMode,omitempty" protobuf:"varint,10,opt,name=recurseMode"`
+
+	// Checksum is the expected checksum of an input artifact, which is verified once it is loaded
+	Checksum *ArtifactChecksum `json:"checksum,omitempty" protobuf:"bytes,11,opt,name=checksum"`
+
+	// Include are glob patterns of the files of an output directory to save, matched against their paths
+	// relative to the directory, e.g. "**/*.jar". All files are saved if there are none. Requires archive none.
+	Include []string `json:"include,omitempty" protobuf:"bytes,12,rep,name=include"`
+
+	// Exclude are glob patterns of the files of an output directory not to save, which take precedence over Include
+	Exclude []string `json:"exclude,omitempty" protobuf:"bytes,13,rep,name=exclude"`
+
+	// Compression compresses an output artifact file before it is saved, recording the encoding in the metadata
+	// of its object so that it is decompressed when loaded. One of: none, gzip, zstd. Supported by S3, GCS and OSS.
+	Compression ArtifactCompression `json:"compression,omitempty" protobuf:"bytes,14,opt,name=compression,casttype=ArtifactCompression"`
+
+	// Timeout is the maximum duration of loading or saving the artifact, including every object of a
+	// directory, e.g. "30m". Defaults to 1h.
+	Timeout string `json:"timeout,omitempty" protobuf:"bytes,15,opt,name=timeout"`
+
+	// Overwrite is whether saving an output artifact replaces what already exists at its destination. One of:
+	// allow, fail-if-exists, skip-if-exists, skip-if-unchanged. Defaults to allow. The existence is checked by a
+	// precondition of the storage, which is atomic, so it is supported by S3, GCS and OSS. skip-if-unchanged does not
+	// upload a file whose sha256, which is recorded in the metadata of its object, or otherwise whose md5, is that of
+	// its destination, nor a directory whose manifest, which is saved with it, is that of its destination. It is
+	// supported by S3, GCS and OSS.
+	Overwrite ArtifactOverwrite `json:"overwrite,omitempty" protobuf:"bytes,16,opt,name=overwrite,casttype=ArtifactOverwrite"`
+
+	// Mirrors saves an output artifact to more locations, e.g. buckets in other regions or clouds, as well as to its
+	// own. The artifact is saved only once it is saved to every location.
+	Mirrors *ArtifactMirrors `json:"mirrors,omitempty" protobuf:"bytes,17,opt,name=mirrors"`
+
+	// ExtractPath is the path of a file or directory in the tar, tar.gz or zip archive an input artifact is, which
+	// alone is extracted to the path of the artifact, with everything under it if it is a directory. The format of
+	// the archive is detected by the extension of its key, or by its content.
+	ExtractPath string `json:"extractPath,omitempty" protobuf:"bytes,18,opt,name=extractPath"`
+
+	// ContentType is the Content-Type of the objects an output artifact is saved as, e.g. "application/json". If
+	// it is not set, that of each file is detected by its extension, or otherwise by its content. Supported by S3,
+	// GCS and OSS, and by HTTP uploads, which only send it if it is set.
+	ContentType string `json:"contentType,omitempty" protobuf:"bytes,19,opt,name=contentType"`
+
+	// Manifest saves a manifest of the files of an output directory artifact, which is saved with archive none, next
+	// to it, with the key of the directory and the suffix ".manifest". It lists the path, size and sha256 of each
+	// file, and loading a directory which has one verifies its files against it. One of: json, text. Supported by
+	// S3, GCS and OSS.
+	Manifest ArtifactManifestFormat `json:"manifest,omitempty" protobuf:"bytes,20,opt,name=manifest,casttype=ArtifactManifestFormat"`
+
+	// AtomicSave saves an output directory artifact to a staging prefix next to its key, and only copies its objects
+	// to its key once every file is saved, so that a failed save leaves no objects under it. The staging objects are
+	// deleted whether or not the save succeeds. Supported by S3, GCS and OSS.
+	AtomicSave bool `json:"atomicSave,omitempty" protobuf:"varint,21,opt,name=atomicSave"`
+
+	// BandwidthLimit is the maximum rate of loading or saving the artifact in bytes per second, e.g. "10Mi", which
+	// the objects of a directory share. Defaults to that of the executor, if it has one. Supported by HTTP, S3, GCS,
+	// Artifactory, WebDAV and Hugging Face.
+	BandwidthLimit string `json:"bandwidthLimit,omitempty" protobuf:"bytes,22,opt,name=bandwidthLimit"`
+
+	// Symlinks is how the symlinks of an output directory artifact, which is saved with archive none, are saved. One
+	// of: follow, which saves what each links to in its place; skip, which does not save them; preserve, which saves
+	// each as an empty object with its target in the metadata of the object, from which loading the directory
+	// recreates it, if it links to a path in the directory. A followed symlink which links to nothing, or to a
+	// directory it is under, fails the save. Defaults to skip for S3 and OSS, and to follow for GCS. Supported by S3,
+	// GCS and OSS.
+	Symlinks ArtifactSymlinks `json:"symlinks,omitempty" protobuf:"bytes,23,opt,name=symlinks,casttype=ArtifactSymlinks"`
+
+	// SelectLatest makes the key of an input artifact a prefix, e.g. data/data-, and loads the latest of the objects
+	// whose keys start with it, e.g. data/data-20240101.csv. One of: last-modified, which is the object modified
+	// last, or lexical, which is that with the greatest key. Objects modified at the same time are ordered by key.
+	// Supported by S3, GCS and OSS.
+	SelectLatest ArtifactSelectLatest `json:"selectLatest,omitempty" protobuf:"bytes,24,opt,name=selectLatest,casttype=ArtifactSelectLatest"`
+
+	// ExpandEnv expands references to environment variables, e.g. ${WORKSPACE}/output, in the bucket and key of an
+	// S3, GCS or OSS artifact, or the URL of an HTTP one, and of its mirror and fallback locations, with the
+	// environment of the executor when its driver is created. A reference to a variable which is not defined is an
+	// error, rather than expanding to nothing. An output is recorded with the locations it was expanded to.
+	ExpandEnv bool `json:"expandEnv,omitempty" protobuf:"varint,25,opt,name=expandEnv"`
+
+	// MaxSize is the largest size of the artifact which is loaded or saved, e.g. "10Gi", which the files of a
+	// directory share. Loading or saving a larger artifact fails, before it is transferred if its size is known then,
+	// and otherwise once more than it is transferred. Defaults to the max size of the executor, if any. Loads are
+	// supported by S3, GCS, OSS and HTTP, and saves by every driver.
+	MaxSize string `json:"maxSize,omitempty" protobuf:"bytes,26,opt,name=maxSize"`
+
+	// Metadata is the user-defined metadata of every object of an output artifact, e.g. {"git-commit": "abc123"},
+	// which S3 and OSS send as x-amz-meta-* and x-oss-meta-* headers, and GCS as the custom metadata of the objects.
+	// Keys are lowercase letters, digits and hyphens, and cannot start with "argo-", which is reserved for the
+	// metadata the drivers record themselves. Supported by S3, GCS and OSS.
+	Metadata map[string]string `json:"metadata,omitempty" protobuf:"bytes,27,rep,name=metadata"`
+
+	// Encryption encrypts an output artifact file on the client before it is saved, and decrypts an input artifact
+	// once it is loaded, before it is written to its path, with the private key of a secret. Directories must be
+	// archived to be encrypted. Supported by every driver, though an encrypted artifact cannot be compressed, have a
+	// max size or metadata, or be saved atomically, idempotently or with an overwrite policy other than allow.
+	Encryption *ArtifactEncryption `json:"encryption,omitempty" protobuf:"bytes,28,opt,name=encryption"`
+
+	// Idempotency skips saving an output artifact which a previous attempt of its save already saved with the same
+	// content, e.g. one of a step which was retried once the artifact was saved, rather than saving it again, which
+	// would create another version of its objects. Each save records the sha256 of its idempotency key in the
+	// metadata of the object of a file, or of the manifest of a directory, which is saved once every file is.
+	// Supported by S3 and GCS.
+	Idempotency *ArtifactIdempotency `json:"idempotency,omitempty" protobuf:"bytes,29,opt,name=idempotency"`
+
+	// Fallbacks loads an input artifact from more locations, e.g. a canonical store behind a faster cache, if it
+	// fails to be loaded from its own. The locations are tried in order until the artifact is loaded from one.
+	Fallbacks *ArtifactFallbacks `json:"fallbacks,omitempty" protobuf:"bytes,30,opt,name=fallbacks"`
+}
+
+// ArtifactIdempotency identifies the attempts of the save of an output artifact, which skip it once one completes
+type ArtifactIdempotency struct {
+	// Key is the idempotency key of the save, e.g. "{{workflow.uid}}-my-step". If it is not set, it is derived from
+	// the namespace, the name of the node without its retry attempts, and the name of the artifact, so that every
+	// attempt of a retried step has the same key.
+	Key string `json:"key,omitempty" protobuf:"bytes,1,opt,name=key"`
+}
+
+// ArtifactEncryption is the client-side encryption of an artifact
+type ArtifactEncryption struct {
+	// Scheme is the scheme the artifact is encrypted with. One of: age, gpg.
+	Scheme ArtifactEncryptionScheme `json:"scheme" protobuf:"bytes,1,opt,name=scheme,casttype=ArtifactEncryptionScheme"`
+
+	// PrivateKeySecret is the secret of the private key the artifact is decrypted with, and whose public key it is
+	// encrypted to: the X25519 identity of age, e.g. AGE-SECRET-KEY-1..., or the armored secret key of GPG
+	PrivateKeySecret *apiv1.SecretKeySelector `json:"privateKeySecret" protobuf:"bytes,2,opt,name=privateKeySecret"`
+
+	// PassphraseSecret is the secret of the passphrase of the private key of GPG, if it is protected by one
+	PassphraseSecret *apiv1.SecretKeySelector `json:"passphraseSecret,omitempty" protobuf:"bytes,3,opt,name=passphraseSecret"`
+}
+
+// ArtifactEncryptionScheme is the scheme of the client-side encryption of an artifact
+type ArtifactEncryptionScheme string
+
+const (
+	ArtifactEncryptionSchemeAge ArtifactEncryptionScheme = "age"
+	ArtifactEncryptionSchemeGPG ArtifactEncryptionScheme = "gpg"
+)
+
+// ArtifactFallbacks are the locations an input artifact is loaded from if it fails to be loaded from its own
+type ArtifactFallbacks struct {
+	// Locations are the locations the artifact is loaded from, in order, each with its key, as keys of fallbacks are
+	// not generated from the archive location
+	Locations []ArtifactLocation `json:"locations" protobuf:"bytes,1,rep,name=locations"`
+
+	// StopOnAuthError stops loading the artifact once a location does not authorize it, rather than loading it from
+	// the next location. An artifact which is not found, or fails to be loaded otherwise, is loaded from the next.
+	StopOnAuthError bool `json:"stopOnAuthError,omitempty" protobuf:"varint,2,opt,name=stopOnAuthError"`
+}
+
+// ArtifactMirrors are the locations an output artifact is mirrored to
+type ArtifactMirrors struct {
+	// Locations are the locations the artifact is mirrored to, each with its key, as keys of mirrors are not
+	// generated from the archive location
+	Locations []ArtifactLocation `json:"locations" protobuf:"bytes,1,rep,name=locations"`
+
+	// Parallelism is the number of locations, including the own location of the artifact, it is saved to at once.
+	// Defaults to 1: the artifact is saved to its own location, then to each of the mirrors in order.
+	Parallelism *int32 `json:"parallelism,omitempty" protobuf:"varint,2,opt,name=parallelism"`
+
+	// FailFast stops saving the artifact once it fails to be saved to a location, rather than saving it to every
+	// location it can be
+	FailFast bool `json:"failFast,omitempty" protobuf:"varint,3,opt,name=failFast"`
+}
+
+// ArtifactOverwrite is whether saving an artifact replaces what already exists at its destination
+type ArtifactOverwrite string
+
+const (
+	ArtifactOverwriteAllow        ArtifactOverwrite = "allow"
+	ArtifactOverwriteFailIfExists ArtifactOverwrite = "fail-if-exists"
+	ArtifactOverwriteSkipIfExists ArtifactOverwrite = "skip-if-exists"
+	// ArtifactOverwriteSkipIfUnchanged replaces the destination of an artifact only if its content is different
+	ArtifactOverwriteSkipIfUnchanged ArtifactOverwrite = "skip-if-unchanged"
+)
+
+// ArtifactCompression is the codec an artifact is compressed with before it is saved
+type ArtifactCompression string
+
+const (
+	ArtifactCompressionNone ArtifactCompression = "none"
+	ArtifactCompressionGzip ArtifactCompression = "gzip"
+	ArtifactCompressionZstd ArtifactCompression = "zstd"
+)
+
+// ArtifactManifestFormat is the format of the manifest of the files of a directory artifact
+type ArtifactManifestFormat string
+
+const (
+	ArtifactManifestFormatJSON ArtifactManifestFormat = "json"
+	ArtifactManifestFormatText ArtifactManifestFormat = "text"
+)
+
+// ArtifactSymlinks is how the symlinks of a directory artifact are saved
+type ArtifactSymlinks string
+
+const (
+	ArtifactSymlinksFollow   ArtifactSymlinks = "follow"
+	ArtifactSymlinksSkip     ArtifactSymlinks = "skip"
+	ArtifactSymlinksPreserve ArtifactSymlinks = "preserve"
+)
+
+// ArtifactSelectLatest is how the latest of the objects under the key prefix of an input artifact is selected
+type ArtifactSelectLatest string
+
+const (
+	ArtifactSelectLatestLastModified ArtifactSelectLatest = "last-modified"
+	ArtifactSelectLatestLexical      ArtifactSelectLatest = "lexical"
+)
+
+// ChecksumAlgorithm is the hash algorithm of an artifact checksum
+type ChecksumAlgorithm string
+
+const (
+	ChecksumAlgorithmSHA256 ChecksumAlgorithm = "sha256"
+	ChecksumAlgorithmMD5    ChecksumAlgorithm = "md5"
+)
+
+// ArtifactChecksum is the expected checksum of an artifact
+type ArtifactChecksum struct {
+	// Algorithm is the hash algorithm. One of: sha256, md5
+	Algorithm ChecksumAlgorithm `json:"algorithm" protobuf:"bytes,1,opt,name=algorithm,casttype=ChecksumAlgorithm"`
+
+	// Value is the expected hex encoded digest of a file artifact
+	Value string `json:"value,omitempty" protobuf:"bytes,2,opt,name=value"`
+
+	// Files is the manifest of a directory artifact: the expected hex encoded digest of each file,
+	// keyed by its path relative to the directory
+	Files map[string]string `json:"files,omitempty" protobuf:"bytes,3,rep,name=files"`
 }
 
 // PodGC describes how to delete completed pods as they complete
@@ -831,6 +1057,48 @@ type ArtifactLocation struct {
 
 	// GCS contains GCS artifact location details
 	GCS *GCSArtifact `json:"gcs,omitempty" protobuf:"bytes,9,opt,name=gcs"`
+
+	// SFTP contains SFTP artifact location details
+	SFTP *SFTPArtifact `json:"sftp,omitempty" protobuf:"bytes,10,opt,name=sftp"`
+
+	// GDrive contains Google Drive artifact location details
+	GDrive *GDriveArtifact `json:"gdrive,omitempty" protobuf:"bytes,11,opt,name=gdrive"`
+
+	// WebDAV contains WebDAV artifact location details
+	WebDAV *WebDAVArtifact `json:"webdav,omitempty" protobuf:"bytes,12,opt,name=webdav"`
+
+	// FTP contains FTP or FTPS artifact location details
+	FTP *FTPArtifact `json:"ftp,omitempty" protobuf:"bytes,13,opt,name=ftp"`
+
+	// B2 contains Backblaze B2 artifact location details
+	B2 *B2Artifact `json:"b2,omitempty" protobuf:"bytes,14,opt,name=b2"`
+
+	// Swift contains OpenStack Swift artifact location details
+	Swift *SwiftArtifact `json:"swift,omitempty" protobuf:"bytes,15,opt,name=swift"`
+
+	// Rclone contains the location of an artifact on an rclone remote
+	Rclone *RcloneArtifact `json:"rclone,omitempty" protobuf:"bytes,16,opt,name=rclone"`
+
+	// Dropbox contains Dropbox artifact location details
+	Dropbox *DropboxArtifact `json:"dropbox,omitempty" protobuf:"bytes,17,opt,name=dropbox"`
+
+	// IPFS contains the location of an artifact in IPFS
+	IPFS *IPFSArtifact `json:"ipfs,omitempty" protobuf:"bytes,18,opt,name=ipfs"`
+
+	// ADLS contains the location of an artifact in Azure Data Lake Storage Gen2
+	ADLS *ADLSArtifact `json:"adls,omitempty" protobuf:"bytes,19,opt,name=adls"`
+
+	// HuggingFace contains the location of an artifact in a repository of the Hugging Face Hub
+	HuggingFace *HuggingFaceArtifact `json:"huggingFace,omitempty" protobuf:"bytes,20,opt,name=huggingFace"`
+
+	// DevNull discards an output artifact, e.g. one which is not needed, or to benchmark saving
+	DevNull *DevNullArtifact `json:"devNull,omitempty" protobuf:"bytes,21,opt,name=devNull"`
+
+	// Filesystem contains the location of an artifact on a file system mounted into the pod, e.g. an EFS or NFS volume
+	Filesystem *FilesystemArtifact `json:"filesystem,omitempty" protobuf:"bytes,22,opt,name=filesystem"`
+
+	// OCI contains the location of an artifact in a repository of an OCI registry
+	OCI *OCIArtifact `json:"oci,omitempty" protobuf:"bytes,23,opt,name=oci"`
 }
 
 func (a *ArtifactLocation) Get() ArtifactLocationType {
@@ -838,6 +1106,8 @@ func (a *ArtifactLocation) Get() ArtifactLocationType {
 		return nil
 	} else if a.Artifactory != nil {
 		return a.Artifactory
+	} else if a.GDrive != nil {
+		return a.GDrive
 	} else if a.Git != nil {
 		return a.Git
 	} else if a.GCS != nil {
@@ -852,6 +1122,32 @@ func (a *ArtifactLocation) Get() ArtifactLocationType {
 		return a.Raw
 	} else if a.S3 != nil {
 		return a.S3
+	} else if a.SFTP != nil {
+		return a.SFTP
+	} else if a.WebDAV != nil {
+		return a.WebDAV
+	} else if a.FTP != nil {
+		return a.FTP
+	} else if a.B2 != nil {
+		return a.B2
+	} else if a.Swift != nil {
+		return a.Swift
+	} else if a.Rclone != nil {
+		return a.Rclone
+	} else if a.Dropbox != nil {
+		return a.Dropbox
+	} else if a.IPFS != nil {
+		return a.IPFS
+	} else if a.ADLS != nil {
+		return a.ADLS
+	} else if a.HuggingFace != nil {
+		return a.HuggingFace
+	} else if a.DevNull != nil {
+		return a.DevNull
+	} else if a.Filesystem != nil {
+		return a.Filesystem
+	} else if a.OCI != nil {
+		return a.OCI
 	}
 	return nil
 }
@@ -864,6 +1160,8 @@ func (a *ArtifactLocation) SetType(x ArtifactLocationType) error {
 		a.Artifactory = &ArtifactoryArtifact{}
 	case *GCSArtifact:
 		a.GCS = &GCSArtifact{}
+	case *GDriveArtifact:
+		a.GDrive = &GDriveArtifact{}
 	case *HDFSArtifact:
 		a.HDFS = &HDFSArtifact{}
 	case *HTTPArtifact:
@@ -874,6 +1172,32 @@ func (a *ArtifactLocation) SetType(x ArtifactLocationType) error {
 		a.Raw = &RawArtifact{}
 	case *S3Artifact:
 		a.S3 = &S3Artifact{}
+	case *SFTPArtifact:
+		a.SFTP = &SFTPArtifact{}
+	case *WebDAVArtifact:
+		a.WebDAV = &WebDAVArtifact{}
+	case *FTPArtifact:
+		a.FTP = &FTPArtifact{}
+	case *B2Artifact:
+		a.B2 = &B2Artifact{}
+	case *SwiftArtifact:
+		a.Swift = &SwiftArtifact{}
+	case *RcloneArtifact:
+		a.Rclone = &RcloneArtifact{}
+	case *DropboxArtifact:
+		a.Dropbox = &DropboxArtifact{}
+	case *IPFSArtifact:
+		a.IPFS = &IPFSArtifact{}
+	case *ADLSArtifact:
+		a.ADLS = &ADLSArtifact{}
+	case *HuggingFaceArtifact:
+		a.HuggingFace = &HuggingFaceArtifact{}
+	case *DevNullArtifact:
+		a.DevNull = &DevNullArtifact{}
+	case *FilesystemArtifact:
+		a.Filesystem = &FilesystemArtifact{}
+	case *OCIArtifact:
+		a.OCI = &OCIArtifact{}
 	default:
 		return fmt.Errorf("set type not supported for type: %v", reflect.TypeOf(v))
 	}
@@ -920,7 +1244,15 @@ func (a *ArtifactLocation) Relocate(l *ArtifactLocation) error {
 	if err != nil {
 		return err
 	}
+	var tags map[string]string
+	if a.S3 != nil {
+		tags = a.S3.Tags
+	}
 	*a = *l.DeepCopy()
+	// the tags of an artifact are kept when it is relocated to the bucket of the repository
+	if a.S3 != nil && len(tags) > 0 {
+		a.S3.Tags = tags
+	}
 	return a.SetKey(key)
 }
 
@@ -1741,11 +2073,111 @@ type S3Bucket struct {
 	// RoleARN is the Amazon Resource Name (ARN) of the role to assume.
 	RoleARN string `json:"roleARN,omitempty" protobuf:"bytes,7,opt,name=roleARN"`
 
-	// UseSDKCreds tells the driver to figure out credentials based on sdk defaults.
+	// UseSDKCreds tells the driver to figure out credentials based on sdk defaults. It is a shortcut for the default
+	// chain of the SDK, which cannot be combined with CredentialProviders.
 	UseSDKCreds bool `json:"useSDKCreds,omitempty" protobuf:"varint,8,opt,name=useSDKCreds"`
 
 	// CreateBucketIfNotPresent tells the driver to attempt to create the S3 bucket for output artifacts, if it doesn't exist
 	CreateBucketIfNotPresent *CreateS3BucketOptions `json:"createBucketIfNotPresent,omitempty" protobuf:"bytes,9,opt,name=createBucketIfNotPresent"`
+
+	// ServerSideEncryption tells the driver to encrypt output artifacts at rest
+	ServerSideEncryption *S3ServerSideEncryption `json:"serverSideEncryption,omitempty" protobuf:"bytes,10,opt,name=serverSideEncryption"`
+
+	// PathStyle addresses buckets in the path of requests (https://endpoint/bucket/key) when true, and in
+	// the hostname (https://bucket.endpoint/key) when false. If not set, path-style is used for
+	// s3.amazonaws.com and otherwise the style is detected from the endpoint.
+	PathStyle *bool `json:"pathStyle,omitempty" protobuf:"varint,11,opt,name=pathStyle"`
+
+	// RequesterPays bills the downloads of objects from requester pays buckets to the account of the credentials,
+	// rather than to the bucket owner
+	RequesterPays bool `json:"requesterPays,omitempty" protobuf:"varint,12,opt,name=requesterPays"`
+
+	// UploadParallelism is the number of files of a directory output artifact uploaded at once. Defaults to 5.
+	UploadParallelism *int32 `json:"uploadParallelism,omitempty" protobuf:"varint,13,opt,name=uploadParallelism"`
+
+	// CASecret is the secret selector to a PEM encoded bundle of CA certificates, which are trusted,
+	// along with the system ones, to verify the TLS connection to the endpoint
+	CASecret *apiv1.SecretKeySelector `json:"caSecret,omitempty" protobuf:"bytes,14,opt,name=caSecret"`
+
+	// ProxyURL is the URL of the HTTP proxy requests are sent through (e.g. http://proxy.example.com:3128).
+	// If not set, the proxy is that of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	ProxyURL string `json:"proxyURL,omitempty" protobuf:"bytes,15,opt,name=proxyURL"`
+
+	// RoleExternalID is the external ID passed when assuming RoleARN, as required by the trust policy of many
+	// cross-account roles
+	RoleExternalID string `json:"roleExternalID,omitempty" protobuf:"bytes,16,opt,name=roleExternalID"`
+
+	// RoleChain are the ARNs of roles assumed in turn before RoleARN, each with the credentials of the one before.
+	// AWS limits the sessions of chained roles to an hour.
+	RoleChain []string `json:"roleChain,omitempty" protobuf:"bytes,17,rep,name=roleChain"`
+
+	// RoleSessionName is the name of the sessions of assumed roles, which appears in CloudTrail. Defaults to a timestamp.
+	RoleSessionName string `json:"roleSessionName,omitempty" protobuf:"bytes,18,opt,name=roleSessionName"`
+
+	// RoleSessionDuration is the duration of the sessions of assumed roles (e.g. 1h), between 15m and 12h. Defaults to 15m.
+	RoleSessionDuration string `json:"roleSessionDuration,omitempty" protobuf:"bytes,19,opt,name=roleSessionDuration"`
+
+	// Retry retries each list, get and put of objects which fails with a 5xx response or a network error, such as
+	// the list of a page of a directory, rather than only the whole load or save. If not set, they are not retried
+	// on their own.
+	Retry *S3Retry `json:"retry,omitempty" protobuf:"bytes,20,opt,name=retry"`
+
+	// UseAccelerateEndpoint sends requests to the S3 Transfer Acceleration endpoint (s3-accelerate.amazonaws.com),
+	// which must be enabled on the bucket. It requires an AWS endpoint, virtual-hosted-style addressing, and a
+	// bucket name without dots.
+	UseAccelerateEndpoint bool `json:"useAccelerateEndpoint,omitempty" protobuf:"varint,21,opt,name=useAccelerateEndpoint"`
+
+	// PartSize is the size (e.g. 64Mi) of the parts files larger than it are uploaded in, with a multipart upload,
+	// between 5Mi and 5Gi. Defaults to the smallest size of at least 16Mi which uploads the file in 10000 parts.
+	PartSize string `json:"partSize,omitempty" protobuf:"bytes,22,opt,name=partSize"`
+
+	// PartConcurrency is the number of parts of a multipart upload uploaded at once. Defaults to 4.
+	PartConcurrency *int32 `json:"partConcurrency,omitempty" protobuf:"varint,23,opt,name=partConcurrency"`
+
+	// CredentialProviders are the sources of credentials tried in turn, of which the first to yield credentials is
+	// used, for buckets whose credentials depend on where the workflow runs. RoleARN, if set, is assumed with them.
+	// If not set, the credentials are those of the secrets, or otherwise of RoleARN or the IAM role.
+	CredentialProviders []S3CredentialProvider `json:"credentialProviders,omitempty" protobuf:"bytes,24,rep,name=credentialProviders,casttype=S3CredentialProvider"`
+
+	// Anonymous loads the public objects of the bucket with unsigned requests, rather than with credentials. Input
+	// artifacts without credentials are loaded anonymously anyway, unless useSDKCreds is set. Artifacts are never
+	// saved anonymously.
+	Anonymous bool `json:"anonymous,omitempty" protobuf:"varint,25,opt,name=anonymous"`
+}
+
+// S3CredentialProvider is a source of the credentials of S3 artifacts
+type S3CredentialProvider string
+
+const (
+	// S3CredentialProviderStatic is the access key and secret key of AccessKeySecret and SecretKeySecret
+	S3CredentialProviderStatic S3CredentialProvider = "static"
+	// S3CredentialProviderWebIdentity is the role assumed with the web identity token of the
+	// AWS_WEB_IDENTITY_TOKEN_FILE and AWS_ROLE_ARN environment variables, which IRSA sets for service accounts
+	S3CredentialProviderWebIdentity S3CredentialProvider = "webIdentity"
+	// S3CredentialProviderInstanceProfile is the role of the instance profile of the EC2 instance
+	S3CredentialProviderInstanceProfile S3CredentialProvider = "instanceProfile"
+	// S3CredentialProviderEnv is the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables
+	S3CredentialProviderEnv S3CredentialProvider = "env"
+)
+
+// S3Retry configures the exponential backoff used to retry the requests of S3 artifacts. The S3 SDK briefly retries
+// each request itself, and every retry here includes those.
+type S3Retry struct {
+	// Limit is the maximum number of retries of each request. Defaults to 4.
+	Limit *int32 `json:"limit,omitempty" protobuf:"varint,1,opt,name=limit"`
+
+	// BaseDelay is the delay before the first retry, which doubles on each subsequent retry (e.g. "1s"). Defaults to "1s".
+	BaseDelay string `json:"baseDelay,omitempty" protobuf:"bytes,2,opt,name=baseDelay"`
+}
+
+// S3ServerSideEncryption configures the server-side encryption of objects saved to S3
+type S3ServerSideEncryption struct {
+	// SSEAlgorithm is the server-side encryption algorithm. One of "AES256" (SSE-S3) or "aws:kms" (SSE-KMS)
+	SSEAlgorithm string `json:"sseAlgorithm" protobuf:"bytes,1,opt,name=sseAlgorithm"`
+
+	// KMSKeyID is the ID of the KMS key to encrypt objects with. Only used with "aws:kms".
+	// If not set, the bucket's AWS managed key is used.
+	KMSKeyID string `json:"kmsKeyId,omitempty" protobuf:"bytes,2,opt,name=kmsKeyId"`
 }
 
 // CreateS3BucketOptions options used to determine automatic automatic bucket-creation process
@@ -1760,8 +2192,79 @@ type S3Artifact struct {
 
 	// Key is the key in the bucket where the artifact resides
 	Key string `json:"key" protobuf:"bytes,2,opt,name=key"`
+
+	// Tags are set on every object of an output artifact, e.g. for cost allocation or lifecycle policies
+	Tags map[string]string `json:"tags,omitempty" protobuf:"bytes,3,rep,name=tags"`
+
+	// VersionID is the version of the object of an input artifact to load, rather than its latest, which requires
+	// the bucket to be versioned. Only a file, not a "directory", can be loaded by version.
+	VersionID string `json:"versionID,omitempty" protobuf:"bytes,4,opt,name=versionID"`
+
+	// ACL is the access control list set on every object of an output artifact, rather than that of the bucket
+	ACL *S3ObjectACL `json:"acl,omitempty" protobuf:"bytes,5,opt,name=acl"`
+
+	// ObjectLock is the retention and legal hold set on every object of an output artifact, which requires the bucket
+	// to have object lock enabled
+	ObjectLock *S3ObjectLock `json:"objectLock,omitempty" protobuf:"bytes,6,opt,name=objectLock"`
 }
 
+// S3ObjectACL is the access control list of the objects of an S3 artifact, which is either a canned ACL or grants,
+// as S3 does not allow both
+type S3ObjectACL struct {
+	// Canned is the canned ACL of the objects. One of: private, public-read, public-read-write, authenticated-read,
+	// aws-exec-read, bucket-owner-read, bucket-owner-full-control
+	Canned S3CannedACL `json:"canned,omitempty" protobuf:"bytes,1,opt,name=canned,casttype=S3CannedACL"`
+
+	// GrantRead are the grantees allowed to read the objects and their metadata, each as S3 expects it in a grant
+	// header, e.g. `id="canonical-user-id"`, `emailAddress="user@example.com"` or
+	// `uri="http://acs.amazonaws.com/groups/global/AllUsers"`
+	GrantRead []string `json:"grantRead,omitempty" protobuf:"bytes,2,rep,name=grantRead"`
+
+	// GrantReadACP are the grantees allowed to read the ACL of the objects
+	GrantReadACP []string `json:"grantReadACP,omitempty" protobuf:"bytes,3,rep,name=grantReadACP"`
+
+	// GrantWriteACP are the grantees allowed to write the ACL of the objects
+	GrantWriteACP []string `json:"grantWriteACP,omitempty" protobuf:"bytes,4,rep,name=grantWriteACP"`
+
+	// GrantFullControl are the grantees allowed to read the objects, and to read and write their ACL
+	GrantFullControl []string `json:"grantFullControl,omitempty" protobuf:"bytes,5,rep,name=grantFullControl"`
+}
+
+// S3CannedACL is a canned ACL of S3 objects
+type S3CannedACL string
+
+const (
+	S3CannedACLPrivate                S3CannedACL = "private"
+	S3CannedACLPublicRead             S3CannedACL = "public-read"
+	S3CannedACLPublicReadWrite        S3CannedACL = "public-read-write"
+	S3CannedACLAuthenticatedRead      S3CannedACL = "authenticated-read"
+	S3CannedACLAWSExecRead            S3CannedACL = "aws-exec-read"
+	S3CannedACLBucketOwnerRead        S3CannedACL = "bucket-owner-read"
+	S3CannedACLBucketOwnerFullControl S3CannedACL = "bucket-owner-full-control"
+)
+
+// S3ObjectLock is the object lock of the objects of an S3 artifact, which S3 does not allow to be deleted or overwritten
+// until their retention ends, or while they are under legal hold
+type S3ObjectLock struct {
+	// Mode is the retention mode of the objects, which requires retainUntilDate. One of: GOVERNANCE, which users with
+	// the s3:BypassGovernanceRetention permission can bypass, or COMPLIANCE, which no one can
+	Mode S3RetentionMode `json:"mode,omitempty" protobuf:"bytes,1,opt,name=mode,casttype=S3RetentionMode"`
+
+	// RetainUntilDate is when the retention of the objects ends, which requires mode
+	RetainUntilDate *metav1.Time `json:"retainUntilDate,omitempty" protobuf:"bytes,2,opt,name=retainUntilDate"`
+
+	// LegalHold places the objects under legal hold, which lasts until it is removed, whether or not they are retained
+	LegalHold bool `json:"legalHold,omitempty" protobuf:"varint,3,opt,name=legalHold"`
+}
+
+// S3RetentionMode is the retention mode of S3 objects
+type S3RetentionMode string
+
+const (
+	S3RetentionModeGovernance S3RetentionMode = "GOVERNANCE"
+	S3RetentionModeCompliance S3RetentionMode = "COMPLIANCE"
+)
+
 func (s *S3Artifact) GetKey() (string, error) {
 	return s.Key, nil
 }
@@ -1780,14 +2283,19 @@ type GitArtifact struct {
 	// Repo is the git repository
 	Repo string `json:"repo" protobuf:"bytes,1,opt,name=repo"`
 
-	// Revision is the git commit, tag, branch to checkout
+	// Revision is the git commit, tag, branch to checkout. Without a refType, a full commit hash is checked out as a
+	// commit, and any other name as a tag, else as a branch, else as whatever git resolves it to (e.g. HEAD~1), so
+	// that a tag is checked out rather than a branch of the same name. A fully-qualified ref, e.g. refs/tags/v1.2.3,
+	// is checked out as that ref.
 	Revision string `json:"revision,omitempty" protobuf:"bytes,2,opt,name=revision"`
 
 	// Depth specifies clones/fetches should be shallow and include the given
 	// number of commits from the branch tip
 	Depth *uint64 `json:"depth,omitempty" protobuf:"bytes,3,opt,name=depth"`
 
-	// Fetch specifies a number of refs that should be fetched before checkout
+	// Fetch specifies a number of refs that should be fetched before checkout. Each is a refspec, e.g.
+	// +refs/pull/*/merge:refs/remotes/origin/pr/*, or a fully-qualified ref, e.g. refs/pull/123/merge, which is fetched
+	// to the ref of the same name. A revision which is the source of a refspec is checked out as its destination.
 	Fetch []string `json:"fetch,omitempty" protobuf:"bytes,4,rep,name=fetch"`
 
 	// UsernameSecret is the secret selector to the repository username
@@ -1801,8 +2309,42 @@ type GitArtifact struct {
 
 	// InsecureIgnoreHostKey disables SSH strict host key checking during git clone
 	InsecureIgnoreHostKey bool `json:"insecureIgnoreHostKey,omitempty" protobuf:"varint,8,opt,name=insecureIgnoreHostKey"`
+
+	// SingleBranch clones only the history of the default branch. A revision which is not in it is fetched before checkout.
+	SingleBranch bool `json:"singleBranch,omitempty" protobuf:"varint,9,opt,name=singleBranch"`
+
+	// RecurseSubmodules initializes and updates the submodules of the repository, recursively, once it is checked
+	// out. They are fetched with the credentials of the repository, and relative submodule URLs are resolved against
+	// the URL of the repository.
+	RecurseSubmodules bool `json:"recurseSubmodules,omitempty" protobuf:"varint,10,opt,name=recurseSubmodules"`
+
+	// RefType is whether the revision is a commit, branch or tag, one of: commit, branch, tag. Loading it fails if it
+	// does not exist as one.
+	RefType GitRefType `json:"refType,omitempty" protobuf:"bytes,11,opt,name=refType,casttype=GitRefType"`
+
+	// TextFilters checks out files with the line ending conversions and filters the git config and .gitattributes of
+	// the repository set, e.g. core.autocrlf, eol=crlf or those of Git LFS. By default, the files of the repository are
+	// checked out byte for byte as they were committed, so that binary files are never converted as if they were text.
+	// The files of submodules are checked out with their own .gitattributes.
+	TextFilters bool `json:"textFilters,omitempty" protobuf:"varint,12,opt,name=textFilters"`
+
+	// File is the path of a single file of the repository to load, as it is at the revision, rather than checking out
+	// the repository, so that the artifact is the file itself. Only the commit, its trees and the file are fetched, if
+	// the server supports partial clones, so loading a file of a large repository takes as long as the file does. The
+	// revision must then be a branch, tag or full commit hash, and the file is loaded byte for byte, so it cannot be
+	// combined with fetch, recurseSubmodules or textFilters.
+	File string `json:"file,omitempty" protobuf:"bytes,13,opt,name=file"`
 }
 
+// GitRefType is the type of ref a git revision is
+type GitRefType string
+
+const (
+	GitRefTypeCommit GitRefType = "commit"
+	GitRefTypeBranch GitRefType = "branch"
+	GitRefTypeTag    GitRefType = "tag"
+)
+
 func (g *GitArtifact) HasLocation() bool {
 	return g != nil && g.Repo != ""
 }
@@ -1829,6 +2371,14 @@ type ArtifactoryAuth struct {
 
 	// PasswordSecret is the secret selector to the repository password
 	PasswordSecret *apiv1.SecretKeySelector `json:"passwordSecret,omitempty" protobuf:"bytes,2,opt,name=passwordSecret"`
+
+	// APIKeySecret is the secret selector to an API key, which is sent in an X-JFrog-Art-Api header in place of the
+	// username and password
+	APIKeySecret *apiv1.SecretKeySelector `json:"apiKeySecret,omitempty" protobuf:"bytes,3,opt,name=apiKeySecret"`
+
+	// AccessTokenSecret is the secret selector to an access or identity token, which is sent as a bearer token in
+	// place of the API key, or the username and password
+	AccessTokenSecret *apiv1.SecretKeySelector `json:"accessTokenSecret,omitempty" protobuf:"bytes,4,opt,name=accessTokenSecret"`
 }
 
 // ArtifactoryArtifact is the location of an artifactory artifact
@@ -1836,6 +2386,14 @@ type ArtifactoryArtifact struct {
 	// URL of the artifact
 	URL             string `json:"url" protobuf:"bytes,1,opt,name=url"`
 	ArtifactoryAuth `json:",inline" protobuf:"bytes,2,opt,name=artifactoryAuth"`
+
+	// ProxyURL is the URL of the HTTP proxy requests are sent through (e.g. http://proxy.example.com:3128).
+	// If not set, the proxy is that of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	ProxyURL string `json:"proxyURL,omitempty" protobuf:"bytes,3,opt,name=proxyURL"`
+
+	// VerifyChecksum verifies a loaded artifact against the X-Checksum-Sha256, X-Checksum-Sha1 or X-Checksum-Md5
+	// header Artifactory returns, and sends the checksums of a saved artifact for Artifactory to verify
+	VerifyChecksum bool `json:"verifyChecksum,omitempty" protobuf:"varint,4,opt,name=verifyChecksum"`
 }
 
 //func (a *ArtifactoryArtifact) String() string {
@@ -1921,6 +2479,10 @@ type HDFSKrbConfig struct {
 	// It must be set if either ccache or keytab is used.
 	KrbConfigConfigMap *apiv1.ConfigMapKeySelector `json:"krbConfigConfigMap,omitempty" protobuf:"bytes,5,opt,name=krbConfigConfigMap"`
 
+	// KrbConfigSecret is the secret selector for Kerberos config as string
+	// It can be set instead of the configmap, e.g. if the config is kept with the keytab.
+	KrbConfigSecret *apiv1.SecretKeySelector `json:"krbConfigSecret,omitempty" protobuf:"bytes,7,opt,name=krbConfigSecret"`
+
 	// KrbServicePrincipalName is the principal name of Kerberos service
 	// It must be set if either ccache or keytab is used.
 	KrbServicePrincipalName string `json:"krbServicePrincipalName,omitempty" protobuf:"bytes,6,opt,name=krbServicePrincipalName"`
@@ -1960,6 +2522,73 @@ type HTTPArtifact struct {
 
 	// Headers are an optional list of headers to send with HTTP requests for artifacts
 	Headers []Header `json:"headers,omitempty" protobuf:"bytes,2,opt,name=headers"`
+
+	// Retry configures how requests failing with a network error or a 5xx response are retried
+	Retry *HTTPRetry `json:"retry,omitempty" protobuf:"bytes,3,opt,name=retry"`
+
+	// CASecret is the secret selector to a PEM encoded bundle of CA certificates, which are trusted,
+	// along with the system ones, to verify the TLS connection to the URL
+	CASecret *apiv1.SecretKeySelector `json:"caSecret,omitempty" protobuf:"bytes,4,opt,name=caSecret"`
+
+	// ProxyURL is the URL of the HTTP proxy requests are sent through (e.g. http://proxy.example.com:3128).
+	// If not set, the proxy is that of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	ProxyURL string `json:"proxyURL,omitempty" protobuf:"bytes,5,opt,name=proxyURL"`
+
+	// BearerTokenSecret is the secret selector to a token sent in an "Authorization: Bearer" header
+	BearerTokenSecret *apiv1.SecretKeySelector `json:"bearerTokenSecret,omitempty" protobuf:"bytes,6,opt,name=bearerTokenSecret"`
+
+	// UsernameSecret is the secret selector to the username of basic auth
+	UsernameSecret *apiv1.SecretKeySelector `json:"usernameSecret,omitempty" protobuf:"bytes,7,opt,name=usernameSecret"`
+
+	// PasswordSecret is the secret selector to the password of basic auth
+	PasswordSecret *apiv1.SecretKeySelector `json:"passwordSecret,omitempty" protobuf:"bytes,8,opt,name=passwordSecret"`
+
+	// Upload configures saving an output artifact to the URL, which is unsupported if it is not set
+	Upload *HTTPUpload `json:"upload,omitempty" protobuf:"bytes,9,opt,name=upload"`
+
+	// DisableDecompression writes the body of the response to a load as it is received. Otherwise, it is requested
+	// with an Accept-Encoding of gzip, deflate and br, unless the headers set one, and decoded if it has a Content-Encoding of any.
+	// If it is set, unencoded content is requested, unless the headers set an Accept-Encoding.
+	DisableDecompression bool `json:"disableDecompression,omitempty" protobuf:"varint,10,opt,name=disableDecompression"`
+
+	// Redirects configures which redirects requests follow. If not set, they follow up to 10, dropping the
+	// Authorization header on those to other hosts.
+	Redirects *HTTPRedirects `json:"redirects,omitempty" protobuf:"bytes,11,opt,name=redirects"`
+}
+
+// HTTPRedirects is the policy of the redirects HTTP artifact requests follow
+type HTTPRedirects struct {
+	// Limit is the maximum number of redirects a request follows, past which it fails. Defaults to 10. If it is 0,
+	// redirects are not followed, and a request which is redirected fails.
+	Limit *int32 `json:"limit,omitempty" protobuf:"varint,1,opt,name=limit"`
+
+	// SameHostOnly fails a request which is redirected to a host other than that of the URL, rather than following it
+	SameHostOnly bool `json:"sameHostOnly,omitempty" protobuf:"varint,2,opt,name=sameHostOnly"`
+
+	// ForwardAuthorization sends the Authorization header of a request, e.g. that of the credentials of the artifact,
+	// on redirects to other hosts. It is dropped on them otherwise, so that the credentials are only sent to the
+	// host of the URL.
+	ForwardAuthorization bool `json:"forwardAuthorization,omitempty" protobuf:"varint,3,opt,name=forwardAuthorization"`
+}
+
+// HTTPUpload configures saving an output artifact file to a HTTP URL, in a single request whose body is the file.
+// The Content-Type of the request is the content type of the artifact, and is only sent if it is set.
+type HTTPUpload struct {
+	// Method is the method of the request, PUT or POST. Defaults to PUT.
+	Method string `json:"method,omitempty" protobuf:"bytes,1,opt,name=method"`
+
+	// Presigned is whether the URL is presigned, e.g. that of an object in S3 or GCS, which is then the only
+	// credential of the request: no Authorization header is added, and the secrets of auth cannot be set
+	Presigned bool `json:"presigned,omitempty" protobuf:"varint,2,opt,name=presigned"`
+}
+
+// HTTPRetry configures the exponential backoff used to retry HTTP artifact requests, and loads which are interrupted
+type HTTPRetry struct {
+	// Limit is the maximum number of retries. Defaults to 4.
+	Limit *int32 `json:"limit,omitempty" protobuf:"varint,1,opt,name=limit"`
+
+	// BaseDelay is the delay before the first retry, which doubles on each subsequent retry (e.g. "1s"). Defaults to "1s".
+	BaseDelay string `json:"baseDelay,omitempty" protobuf:"bytes,2,opt,name=baseDelay"`
 }
 
 func (h *HTTPArtifact) GetKey() (string, error) {
@@ -1992,6 +2621,35 @@ type GCSBucket struct {
 
 	// ServiceAccountKeySecret is the secret selector to the bucket's service account key
 	ServiceAccountKeySecret *apiv1.SecretKeySelector `json:"serviceAccountKeySecret,omitempty" protobuf:"bytes,2,opt,name=serviceAccountKeySecret"`
+
+	// RequesterPays bills the requests to requester pays buckets to the billing project, rather than to the bucket owner
+	RequesterPays bool `json:"requesterPays,omitempty" protobuf:"varint,3,opt,name=requesterPays"`
+
+	// BillingProject is the ID of the project billed for requests to requester pays buckets.
+	// If not set, the project of the service account key is billed.
+	BillingProject string `json:"billingProject,omitempty" protobuf:"bytes,4,opt,name=billingProject"`
+
+	// ProxyURL is the URL of the HTTP proxy requests are sent through (e.g. http://proxy.example.com:3128).
+	// If not set, the proxy is that of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	ProxyURL string `json:"proxyURL,omitempty" protobuf:"bytes,5,opt,name=proxyURL"`
+
+	// UploadChunkSize is the size of the chunks output artifacts are uploaded in (e.g. "32Mi"), which is rounded up to
+	// a multiple of 256Ki. A failed chunk is retried, rather than the whole upload. Files smaller than a chunk are
+	// uploaded in a single request. Defaults to "16Mi".
+	UploadChunkSize string `json:"uploadChunkSize,omitempty" protobuf:"bytes,6,opt,name=uploadChunkSize"`
+
+	// EncryptionKeySecret is the secret selector to a base64 encoded AES-256 customer-supplied encryption key (CSEK),
+	// which output artifacts are encrypted with, and input artifacts must have been encrypted with
+	EncryptionKeySecret *apiv1.SecretKeySelector `json:"encryptionKeySecret,omitempty" protobuf:"bytes,7,opt,name=encryptionKeySecret"`
+
+	// Endpoint is the base URL requests are sent to, rather than https://storage.googleapis.com, e.g. that of a
+	// Private Service Connect endpoint (e.g. https://storage-myendpoint.p.googleapis.com). Signed URLs are of it too.
+	Endpoint string `json:"endpoint,omitempty" protobuf:"bytes,8,opt,name=endpoint"`
+
+	// Anonymous loads the public objects of the bucket with unauthenticated requests, rather than with credentials.
+	// Input artifacts without a service account key are loaded anonymously anyway if there are no default
+	// credentials, e.g. those of Workload Identity. Artifacts are never saved anonymously.
+	Anonymous bool `json:"anonymous,omitempty" protobuf:"varint,9,opt,name=anonymous"`
 }
 
 // GCSArtifact is the location of a GCS artifact
@@ -2028,6 +2686,21 @@ type OSSBucket struct {
 
 	// SecretKeySecret is the secret selector to the bucket's secret key
 	SecretKeySecret *apiv1.SecretKeySelector `json:"secretKeySecret,omitempty" protobuf:"bytes,4,opt,name=secretKeySecret"`
+
+	// SecurityTokenSecret is the secret selector to the STS security token of temporary access and secret keys
+	SecurityTokenSecret *apiv1.SecretKeySelector `json:"securityTokenSecret,omitempty" protobuf:"bytes,5,opt,name=securityTokenSecret"`
+
+	// UseSDKCreds tells the driver to use the temporary credentials of the RAM role of the ECS instance, which are
+	// refreshed before they expire. Access and secret keys take precedence.
+	UseSDKCreds bool `json:"useSDKCreds,omitempty" protobuf:"varint,6,opt,name=useSDKCreds"`
+
+	// RAMRole is the name of the RAM role of the ECS instance used with UseSDKCreds. If not set, it is looked up
+	// from the instance metadata.
+	RAMRole string `json:"ramRole,omitempty" protobuf:"bytes,7,opt,name=ramRole"`
+
+	// PartSize is the size (e.g. 64Mi) of the parts files larger than it are uploaded in, with a multipart upload,
+	// between 100Ki and 5Gi. Defaults to 16Mi. Files which would have more than 10000 parts are uploaded in larger ones.
+	PartSize string `json:"partSize,omitempty" protobuf:"bytes,8,opt,name=partSize"`
 }
 
 // OSSArtifact is the location of an Alibaba Cloud OSS artifact
@@ -2051,6 +2724,506 @@ func (o *OSSArtifact) HasLocation() bool {
 	return o != nil && o.Bucket != "" && o.Endpoint != "" && o.Key != ""
 }
 
+// SFTPArtifact is the location of an artifact on an SFTP server
+type SFTPArtifact struct {
+	// Host is the address of the SFTP server, optionally including the port (e.g. sftp.example.com:2222).
+	// Defaults to port 22.
+	Host string `json:"host" protobuf:"bytes,1,opt,name=host"`
+
+	// Path is the path of the file or directory on the server
+	Path string `json:"path" protobuf:"bytes,2,opt,name=path"`
+
+	// UsernameSecret is the secret selector to the username
+	UsernameSecret *apiv1.SecretKeySelector `json:"usernameSecret,omitempty" protobuf:"bytes,3,opt,name=usernameSecret"`
+
+	// PasswordSecret is the secret selector to the password
+	PasswordSecret *apiv1.SecretKeySelector `json:"passwordSecret,omitempty" protobuf:"bytes,4,opt,name=passwordSecret"`
+
+	// SSHPrivateKeySecret is the secret selector to the ssh private key
+	SSHPrivateKeySecret *apiv1.SecretKeySelector `json:"sshPrivateKeySecret,omitempty" protobuf:"bytes,5,opt,name=sshPrivateKeySecret"`
+
+	// InsecureIgnoreHostKey disables SSH strict host key checking
+	InsecureIgnoreHostKey bool `json:"insecureIgnoreHostKey,omitempty" protobuf:"varint,6,opt,name=insecureIgnoreHostKey"`
+}
+
+func (s *SFTPArtifact) GetKey() (string, error) {
+	return s.Path, nil
+}
+
+func (s *SFTPArtifact) SetKey(key string) error {
+	s.Path = key
+	return nil
+}
+
+func (s *SFTPArtifact) HasLocation() bool {
+	return s != nil && s.Host != ""
+}
+
+// GDriveArtifact is the location of a Google Drive file or folder
+type GDriveArtifact struct {
+	// FileID is the ID of the file or folder. If Path is also set, it is resolved relative to this folder.
+	FileID string `json:"fileId,omitempty" protobuf:"bytes,1,opt,name=fileId"`
+
+	// Path is the slash separated path of the file or folder, relative to FileID, or to the root of the
+	// service account's drive if FileID is not set (e.g. datasets/2021/train.csv)
+	Path string `json:"path,omitempty" protobuf:"bytes,2,opt,name=path"`
+
+	// ServiceAccountKeySecret is the secret selector to the service account key. If not set, the default
+	// credentials, e.g. of Workload Identity, are used.
+	ServiceAccountKeySecret *apiv1.SecretKeySelector `json:"serviceAccountKeySecret,omitempty" protobuf:"bytes,3,opt,name=serviceAccountKeySecret"`
+
+	// ExportMimeType is the MIME type Google Docs, Sheets, Slides and Drawings are exported as.
+	// Defaults to the matching Microsoft Office format, or PNG for Drawings.
+	ExportMimeType string `json:"exportMimeType,omitempty" protobuf:"bytes,4,opt,name=exportMimeType"`
+}
+
+func (g *GDriveArtifact) GetKey() (string, error) {
+	return g.Path, nil
+}
+
+func (g *GDriveArtifact) SetKey(key string) error {
+	g.Path = key
+	return nil
+}
+
+func (g *GDriveArtifact) HasLocation() bool {
+	return g != nil && (g.FileID != "" || g.Path != "")
+}
+
+// WebDAVArtifact is the location of a WebDAV resource or collection
+type WebDAVArtifact struct {
+	// URL of the resource, or of the collection of a directory artifact (e.g. https://nextcloud.example.com/remote.php/dav/files/me/data)
+	URL string `json:"url" protobuf:"bytes,1,opt,name=url"`
+
+	// UsernameSecret is the secret selector to the username
+	UsernameSecret *apiv1.SecretKeySelector `json:"usernameSecret,omitempty" protobuf:"bytes,2,opt,name=usernameSecret"`
+
+	// PasswordSecret is the secret selector to the password
+	PasswordSecret *apiv1.SecretKeySelector `json:"passwordSecret,omitempty" protobuf:"bytes,3,opt,name=passwordSecret"`
+
+	// ProxyURL is the URL of the HTTP proxy requests are sent through (e.g. http://proxy.example.com:3128).
+	// If not set, the proxy is that of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	ProxyURL string `json:"proxyURL,omitempty" protobuf:"bytes,4,opt,name=proxyURL"`
+}
+
+func (w *WebDAVArtifact) GetKey() (string, error) {
+	u, err := url.Parse(w.URL)
+	if err != nil {
+		return "", err
+	}
+	return u.Path, nil
+}
+
+func (w *WebDAVArtifact) SetKey(key string) error {
+	u, err := url.Parse(w.URL)
+	if err != nil {
+		return err
+	}
+	u.Path = key
+	w.URL = u.String()
+	return nil
+}
+
+func (w *WebDAVArtifact) HasLocation() bool {
+	return w != nil && w.URL != ""
+}
+
+// FTPArtifact is the location of a file or directory on an FTP server
+type FTPArtifact struct {
+	// URL of the file or directory, e.g. ftp://ftp.example.com/pub/data.csv, or ftps:// for explicit FTPS. The path is
+	// relative to the directory the user logs in to, unless it starts with %2F (e.g. ftp://ftp.example.com/%2Fpub).
+	URL string `json:"url" protobuf:"bytes,1,opt,name=url"`
+
+	// UsernameSecret is the secret selector to the username. Logs in anonymously if not set.
+	UsernameSecret *apiv1.SecretKeySelector `json:"usernameSecret,omitempty" protobuf:"bytes,2,opt,name=usernameSecret"`
+
+	// PasswordSecret is the secret selector to the password
+	PasswordSecret *apiv1.SecretKeySelector `json:"passwordSecret,omitempty" protobuf:"bytes,3,opt,name=passwordSecret"`
+
+	// ActiveMode has the server connect to the client for transfers, rather than the client to the server (passive mode)
+	ActiveMode bool `json:"activeMode,omitempty" protobuf:"varint,4,opt,name=activeMode"`
+
+	// CASecret is the secret selector to a PEM encoded bundle of CA certificates, which are trusted,
+	// along with the system ones, to verify the TLS connection of FTPS
+	CASecret *apiv1.SecretKeySelector `json:"caSecret,omitempty" protobuf:"bytes,5,opt,name=caSecret"`
+
+	// InsecureSkipVerify skips the verification of the TLS certificate of an FTPS server
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty" protobuf:"varint,6,opt,name=insecureSkipVerify"`
+}
+
+func (f *FTPArtifact) GetKey() (string, error) {
+	u, err := url.Parse(f.URL)
+	if err != nil {
+		return "", err
+	}
+	return u.Path, nil
+}
+
+func (f *FTPArtifact) SetKey(key string) error {
+	u, err := url.Parse(f.URL)
+	if err != nil {
+		return err
+	}
+	u.Path = key
+	f.URL = u.String()
+	return nil
+}
+
+func (f *FTPArtifact) HasLocation() bool {
+	return f != nil && f.URL != ""
+}
+
+// B2Artifact is the location of a file in a Backblaze B2 bucket, which is accessed with the native B2 API
+type B2Artifact struct {
+	// Bucket is the name of the bucket
+	Bucket string `json:"bucket" protobuf:"bytes,1,opt,name=bucket"`
+
+	// Key is the name of the file in the bucket
+	Key string `json:"key" protobuf:"bytes,2,opt,name=key"`
+
+	// ApplicationKeyIDSecret is the secret selector to the ID of the application key
+	ApplicationKeyIDSecret *apiv1.SecretKeySelector `json:"applicationKeyIDSecret" protobuf:"bytes,3,opt,name=applicationKeyIDSecret"`
+
+	// ApplicationKeySecret is the secret selector to the application key
+	ApplicationKeySecret *apiv1.SecretKeySelector `json:"applicationKeySecret" protobuf:"bytes,4,opt,name=applicationKeySecret"`
+}
+
+func (b *B2Artifact) GetKey() (string, error) {
+	return b.Key, nil
+}
+
+func (b *B2Artifact) SetKey(key string) error {
+	b.Key = key
+	return nil
+}
+
+func (b *B2Artifact) HasLocation() bool {
+	return b != nil && b.Bucket != "" && b.Key != ""
+}
+
+// SwiftArtifact is the location of an object in an OpenStack Swift container, which is accessed with a Keystone v3
+// token. Either the username, password and project secrets, or the application credential secrets, must be set.
+type SwiftArtifact struct {
+	// AuthURL is the URL of the Keystone v3 identity API, e.g. https://keystone.example.com:5000/v3
+	AuthURL string `json:"authURL" protobuf:"bytes,1,opt,name=authURL"`
+
+	// Region is the region of the object-store endpoint, if the service catalog has more than one
+	Region string `json:"region,omitempty" protobuf:"bytes,2,opt,name=region"`
+
+	// Container is the name of the container
+	Container string `json:"container" protobuf:"bytes,3,opt,name=container"`
+
+	// Key is the name of the object in the container
+	Key string `json:"key" protobuf:"bytes,4,opt,name=key"`
+
+	// UsernameSecret is the secret selector to the name of the user
+	UsernameSecret *apiv1.SecretKeySelector `json:"usernameSecret,omitempty" protobuf:"bytes,5,opt,name=usernameSecret"`
+
+	// PasswordSecret is the secret selector to the password of the user
+	PasswordSecret *apiv1.SecretKeySelector `json:"passwordSecret,omitempty" protobuf:"bytes,6,opt,name=passwordSecret"`
+
+	// ProjectSecret is the secret selector to the name of the project the token is scoped to
+	ProjectSecret *apiv1.SecretKeySelector `json:"projectSecret,omitempty" protobuf:"bytes,7,opt,name=projectSecret"`
+
+	// UserDomainName is the name of the domain of the user, "Default" if not set
+	UserDomainName string `json:"userDomainName,omitempty" protobuf:"bytes,8,opt,name=userDomainName"`
+
+	// ProjectDomainName is the name of the domain of the project, "Default" if not set
+	ProjectDomainName string `json:"projectDomainName,omitempty" protobuf:"bytes,9,opt,name=projectDomainName"`
+
+	// ApplicationCredentialIDSecret is the secret selector to the ID of an application credential
+	ApplicationCredentialIDSecret *apiv1.SecretKeySelector `json:"applicationCredentialIDSecret,omitempty" protobuf:"bytes,10,opt,name=applicationCredentialIDSecret"`
+
+	// ApplicationCredentialSecretSecret is the secret selector to the secret of the application credential
+	ApplicationCredentialSecretSecret *apiv1.SecretKeySelector `json:"applicationCredentialSecretSecret,omitempty" protobuf:"bytes,11,opt,name=applicationCredentialSecretSecret"`
+
+	// SegmentSize is the size (e.g. "1Gi") of the segments files larger than it are uploaded in, as static large
+	// objects. It is 1Gi if not set, and at most 5Gi, the largest object Swift accepts by default.
+	SegmentSize string `json:"segmentSize,omitempty" protobuf:"bytes,12,opt,name=segmentSize"`
+
+	// SegmentContainer is the name of the container segments are uploaded to, "<container>_segments" if not set
+	SegmentContainer string `json:"segmentContainer,omitempty" protobuf:"bytes,13,opt,name=segmentContainer"`
+}
+
+func (s *SwiftArtifact) GetKey() (string, error) {
+	return s.Key, nil
+}
+
+func (s *SwiftArtifact) SetKey(key string) error {
+	s.Key = key
+	return nil
+}
+
+func (s *SwiftArtifact) HasLocation() bool {
+	return s != nil && s.Container != "" && s.Key != ""
+}
+
+// RcloneArtifact is the location of a file or directory on a remote of rclone (https://rclone.org), such as any of
+// the backends rclone supports, which is configured by an rclone config file. Either the config secret, or the
+// config config map, must be set.
+type RcloneArtifact struct {
+	// Remote is the name of the remote in the config file, e.g. my-remote
+	Remote string `json:"remote" protobuf:"bytes,1,opt,name=remote"`
+
+	// Path is the path of the file or directory on the remote, e.g. my-bucket/my-key
+	Path string `json:"path" protobuf:"bytes,2,opt,name=path"`
+
+	// ConfigSecret is the secret selector to the rclone config file, for remotes configured with credentials
+	ConfigSecret *apiv1.SecretKeySelector `json:"configSecret,omitempty" protobuf:"bytes,3,opt,name=configSecret"`
+
+	// ConfigConfigMap is the config map selector to the rclone config file, for remotes configured without credentials
+	ConfigConfigMap *apiv1.ConfigMapKeySelector `json:"configConfigMap,omitempty" protobuf:"bytes,4,opt,name=configConfigMap"`
+}
+
+func (r *RcloneArtifact) GetKey() (string, error) {
+	return r.Path, nil
+}
+
+func (r *RcloneArtifact) SetKey(key string) error {
+	r.Path = key
+	return nil
+}
+
+func (r *RcloneArtifact) HasLocation() bool {
+	return r != nil && r.Remote != "" && r.Path != ""
+}
+
+// DropboxArtifact is the location of a file or folder in Dropbox, which is accessed with the short-lived access
+// tokens the OAuth 2 refresh token of a Dropbox app is exchanged for
+type DropboxArtifact struct {
+	// Path is the path of the file or folder, e.g. /my-folder/my-file, which is relative to the app folder of apps
+	// with app folder access
+	Path string `json:"path" protobuf:"bytes,1,opt,name=path"`
+
+	// AppKeySecret is the secret selector to the key of the Dropbox app
+	AppKeySecret *apiv1.SecretKeySelector `json:"appKeySecret,omitempty" protobuf:"bytes,2,opt,name=appKeySecret"`
+
+	// AppSecretSecret is the secret selector to the secret of the app, which is not needed for refresh tokens issued
+	// with PKCE
+	AppSecretSecret *apiv1.SecretKeySelector `json:"appSecretSecret,omitempty" protobuf:"bytes,3,opt,name=appSecretSecret"`
+
+	// RefreshTokenSecret is the secret selector to the OAuth 2 refresh token of the app
+	RefreshTokenSecret *apiv1.SecretKeySelector `json:"refreshTokenSecret,omitempty" protobuf:"bytes,4,opt,name=refreshTokenSecret"`
+
+	// ChunkSize is the size (e.g. "8Mi") of the chunks files larger than it are uploaded in, with an upload session.
+	// It is 8Mi if not set, and at most 150Mi, the largest upload Dropbox accepts.
+	ChunkSize string `json:"chunkSize,omitempty" protobuf:"bytes,5,opt,name=chunkSize"`
+}
+
+func (d *DropboxArtifact) GetKey() (string, error) {
+	return d.Path, nil
+}
+
+func (d *DropboxArtifact) SetKey(key string) error {
+	d.Path = key
+	return nil
+}
+
+func (d *DropboxArtifact) HasLocation() bool {
+	return d != nil && d.Path != ""
+}
+
+// IPFSArtifact is the location of a file or directory in IPFS, which is added and fetched with the RPC API of an
+// IPFS node, e.g. Kubo. Its key is its CID, which saving it returns, so it cannot be chosen.
+type IPFSArtifact struct {
+	// URL is the URL of the RPC API of the node, e.g. http://ipfs:5001
+	URL string `json:"url" protobuf:"bytes,1,opt,name=url"`
+
+	// CID is the content identifier of the file or directory, which is required for input artifacts. Saving an
+	// output artifact sets it to the CID it was added as.
+	CID string `json:"cid,omitempty" protobuf:"bytes,2,opt,name=cid"`
+
+	// UsernameSecret is the secret selector to the username of the basic auth of the API, if it requires it
+	UsernameSecret *apiv1.SecretKeySelector `json:"usernameSecret,omitempty" protobuf:"bytes,3,opt,name=usernameSecret"`
+
+	// PasswordSecret is the secret selector to the password of the basic auth of the API
+	PasswordSecret *apiv1.SecretKeySelector `json:"passwordSecret,omitempty" protobuf:"bytes,4,opt,name=passwordSecret"`
+
+	// BearerTokenSecret is the secret selector to a bearer token the API is authorized with, rather than basic auth
+	BearerTokenSecret *apiv1.SecretKeySelector `json:"bearerTokenSecret,omitempty" protobuf:"bytes,5,opt,name=bearerTokenSecret"`
+}
+
+func (i *IPFSArtifact) GetKey() (string, error) {
+	return i.CID, nil
+}
+
+func (i *IPFSArtifact) SetKey(string) error {
+	return keyUnsupportedErr
+}
+
+func (i *IPFSArtifact) HasLocation() bool {
+	return i != nil && i.URL != ""
+}
+
+// ADLSArtifact is the location of a file or directory in Azure Data Lake Storage Gen2, i.e. a storage account with a
+// hierarchical namespace. Its directories are real ones, which are saved and deleted atomically. Exactly one of the
+// account key, SAS token, or workload identity authorizes requests.
+type ADLSArtifact struct {
+	// Endpoint is the Data Lake Storage endpoint of the storage account, e.g. https://myaccount.dfs.core.windows.net,
+	// the first label of whose host is the name of the account
+	Endpoint string `json:"endpoint" protobuf:"bytes,1,opt,name=endpoint"`
+
+	// Filesystem is the file system, i.e. the container, the file or directory is in
+	Filesystem string `json:"filesystem" protobuf:"bytes,2,opt,name=filesystem"`
+
+	// Path is the path of the file or directory in the file system
+	Path string `json:"path" protobuf:"bytes,3,opt,name=path"`
+
+	// AccountKeySecret is the secret selector to the key of the storage account, which requests are signed with
+	AccountKeySecret *apiv1.SecretKeySelector `json:"accountKeySecret,omitempty" protobuf:"bytes,4,opt,name=accountKeySecret"`
+
+	// SASTokenSecret is the secret selector to a shared access signature, which is added to the query of requests
+	SASTokenSecret *apiv1.SecretKeySelector `json:"sasTokenSecret,omitempty" protobuf:"bytes,5,opt,name=sasTokenSecret"`
+
+	// UseWorkloadIdentity authorizes requests with a token of Azure AD Workload Identity, which must be enabled for
+	// the service account of the pod
+	UseWorkloadIdentity bool `json:"useWorkloadIdentity,omitempty" protobuf:"varint,6,opt,name=useWorkloadIdentity"`
+
+	// UserDelegationSASDuration is how long (e.g. 1h), between 1m and 7d, the user delegation shared access signature,
+	// which requests are authorized with instead of the token of the Workload Identity, is valid for. Requests are only
+	// authorized with one, signed by a user delegation key of the Workload Identity and permitting no more than what
+	// the load, save, check or deletion of the artifact needs, if set. Requires useWorkloadIdentity.
+	UserDelegationSASDuration string `json:"userDelegationSASDuration,omitempty" protobuf:"bytes,7,opt,name=userDelegationSASDuration"`
+}
+
+func (a *ADLSArtifact) GetKey() (string, error) {
+	return a.Path, nil
+}
+
+func (a *ADLSArtifact) SetKey(key string) error {
+	a.Path = key
+	return nil
+}
+
+func (a *ADLSArtifact) HasLocation() bool {
+	return a != nil && a.Endpoint != "" && a.Filesystem != "" && a.Path != ""
+}
+
+// HuggingFaceArtifact is the location of a file or directory in a model, dataset or space repository of the Hugging
+// Face Hub. Files the Hub stores with Git LFS, e.g. model weights, are transferred with its LFS storage.
+type HuggingFaceArtifact struct {
+	// RepoID is the ID of the repository, e.g. my-org/my-model
+	RepoID string `json:"repoID" protobuf:"bytes,1,opt,name=repoID"`
+
+	// RepoType is the type of the repository. One of: model, dataset, space. Defaults to model.
+	RepoType HuggingFaceRepoType `json:"repoType,omitempty" protobuf:"bytes,2,opt,name=repoType,casttype=HuggingFaceRepoType"`
+
+	// Revision is the branch, tag or commit an input artifact is loaded from, or the branch an output artifact is
+	// committed to. Defaults to main.
+	Revision string `json:"revision,omitempty" protobuf:"bytes,3,opt,name=revision"`
+
+	// Path is the path of the file or directory in the repository, its root if not set
+	Path string `json:"path,omitempty" protobuf:"bytes,4,opt,name=path"`
+
+	// TokenSecret is the secret selector to a user access token, which private and gated repositories, and saving
+	// artifacts, require
+	TokenSecret *apiv1.SecretKeySelector `json:"tokenSecret,omitempty" protobuf:"bytes,5,opt,name=tokenSecret"`
+
+	// Endpoint is the URL of the Hub, e.g. that of a mirror. Defaults to https://huggingface.co.
+	Endpoint string `json:"endpoint,omitempty" protobuf:"bytes,6,opt,name=endpoint"`
+}
+
+// HuggingFaceRepoType is the type of a repository of the Hugging Face Hub
+type HuggingFaceRepoType string
+
+const (
+	HuggingFaceRepoTypeModel   HuggingFaceRepoType = "model"
+	HuggingFaceRepoTypeDataset HuggingFaceRepoType = "dataset"
+	HuggingFaceRepoTypeSpace   HuggingFaceRepoType = "space"
+)
+
+func (h *HuggingFaceArtifact) GetKey() (string, error) {
+	return h.Path, nil
+}
+
+func (h *HuggingFaceArtifact) SetKey(key string) error {
+	h.Path = key
+	return nil
+}
+
+func (h *HuggingFaceArtifact) HasLocation() bool {
+	return h != nil && h.RepoID != ""
+}
+
+// DevNullArtifact is the location of output artifacts which are discarded once they are read, as if they were saved,
+// and so cannot be loaded
+type DevNullArtifact struct{}
+
+func (d *DevNullArtifact) GetKey() (string, error) {
+	return "", keyUnsupportedErr
+}
+
+func (d *DevNullArtifact) SetKey(string) error {
+	return keyUnsupportedErr
+}
+
+func (d *DevNullArtifact) HasLocation() bool {
+	return d != nil
+}
+
+// FilesystemArtifact is the location of a file or directory on a file system mounted into the pod, e.g. an EFS or NFS
+// volume, which is copied from and to its path. Its key is its path relative to the base path.
+type FilesystemArtifact struct {
+	// BasePath is the directory the file system is mounted at in the pod, e.g. /mnt/efs, which the artifact cannot be
+	// outside of, even by following a symlink
+	BasePath string `json:"basePath" protobuf:"bytes,1,opt,name=basePath"`
+
+	// Path is the slash separated path of the file or directory relative to the base path, e.g. my-dir/my-file
+	Path string `json:"path" protobuf:"bytes,2,opt,name=path"`
+}
+
+func (f *FilesystemArtifact) GetKey() (string, error) {
+	return f.Path, nil
+}
+
+func (f *FilesystemArtifact) SetKey(key string) error {
+	f.Path = key
+	return nil
+}
+
+func (f *FilesystemArtifact) HasLocation() bool {
+	return f != nil && f.BasePath != "" && f.Path != ""
+}
+
+// OCIArtifact is the location of an artifact in a repository of an OCI registry, which is pulled and pushed as ORAS
+// does: a file is a layer of the manifest of the artifact, and a directory a tar.gz layer, which is unpacked once it is
+// pulled. Its key is its reference, which is its digest, if it is pinned to one, or otherwise its tag.
+type OCIArtifact struct {
+	// Repository is the repository of the artifact, with the host of its registry, e.g. ghcr.io/my-org/my-model
+	Repository string `json:"repository" protobuf:"bytes,1,opt,name=repository"`
+
+	// Tag is the tag an input artifact is pulled from, unless it is pinned to a digest, and an output artifact is
+	// pushed to. Defaults to latest.
+	Tag string `json:"tag,omitempty" protobuf:"bytes,2,opt,name=tag"`
+
+	// Digest pins an input artifact to the digest of its manifest, e.g. sha256:..., so that it is immutable: the
+	// manifest of the digest is pulled, rather than that of the tag, and verified against it. Pushing an output
+	// artifact sets it to the digest of the manifest it pushed.
+	Digest string `json:"digest,omitempty" protobuf:"bytes,3,opt,name=digest"`
+
+	// CredentialsSecret is the secret selector to a docker config, e.g. the .dockerconfigjson of a
+	// kubernetes.io/dockerconfigjson secret, with the credentials of the registry. Anonymous if not set.
+	CredentialsSecret *apiv1.SecretKeySelector `json:"credentialsSecret,omitempty" protobuf:"bytes,4,opt,name=credentialsSecret"`
+
+	// Insecure connects to the registry over plain HTTP, e.g. that of a cluster, rather than HTTPS
+	Insecure bool `json:"insecure,omitempty" protobuf:"varint,5,opt,name=insecure"`
+}
+
+func (o *OCIArtifact) GetKey() (string, error) {
+	if o.Digest != "" {
+		return o.Digest, nil
+	}
+	return o.Tag, nil
+}
+
+func (o *OCIArtifact) SetKey(string) error {
+	return keyUnsupportedErr
+}
+
+func (o *OCIArtifact) HasLocation() bool {
+	return o != nil && o.Repository != ""
+}
+
 // ExecutorConfig holds configurations of an executor container.
 type ExecutorConfig struct {
 	// ServiceAccountName specifies the service account name of the executor container.
@@ -188,6 +188,12 @@ func TestArtifactLocation_Relocate(t *testing.T) {
 		assert.Equal(t, "my-bucket", l.S3.Bucket, "bucket copied from argument")
 		assert.Equal(t, "my-key", l.S3.Key, "key is unchanged")
 	})
+	t.Run("Tags", func(t *testing.T) {
+		l := &ArtifactLocation{S3: &S3Artifact{Key: "my-key", Tags: map[string]string{"team": "my-team"}}}
+		assert.NoError(t, l.Relocate(&ArtifactLocation{S3: &S3Artifact{S3Bucket: S3Bucket{Bucket: "my-bucket"}, Key: "other-key"}}))
+		assert.Equal(t, "my-bucket", l.S3.Bucket, "bucket copied from argument")
+		assert.Equal(t, map[string]string{"team": "my-team"}, l.S3.Tags, "tags are unchanged")
+	})
 }
 
 func TestArtifactLocation_Get(t *testing.T) {
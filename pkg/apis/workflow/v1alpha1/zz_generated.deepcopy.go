@@ -12,6 +12,32 @@ import (
 	intstr "k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ADLSArtifact) DeepCopyInto(out *ADLSArtifact) {
+	*out = *in
+	if in.AccountKeySecret != nil {
+		in, out := &in.AccountKeySecret, &out.AccountKeySecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SASTokenSecret != nil {
+		in, out := &in.SASTokenSecret, &out.SASTokenSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ADLSArtifact.
+func (in *ADLSArtifact) DeepCopy() *ADLSArtifact {
+	if in == nil {
+		return nil
+	}
+	out := new(ADLSArtifact)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Amount) DeepCopyInto(out *Amount) {
 	*out = *in
@@ -103,6 +129,48 @@ func (in *Artifact) DeepCopyInto(out *Artifact) {
 		*out = new(ArchiveStrategy)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Checksum != nil {
+		in, out := &in.Checksum, &out.Checksum
+		*out = new(ArtifactChecksum)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Include != nil {
+		in, out := &in.Include, &out.Include
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Mirrors != nil {
+		in, out := &in.Mirrors, &out.Mirrors
+		*out = new(ArtifactMirrors)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Metadata != nil {
+		in, out := &in.Metadata, &out.Metadata
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Encryption != nil {
+		in, out := &in.Encryption, &out.Encryption
+		*out = new(ArtifactEncryption)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Idempotency != nil {
+		in, out := &in.Idempotency, &out.Idempotency
+		*out = new(ArtifactIdempotency)
+		**out = **in
+	}
+	if in.Fallbacks != nil {
+		in, out := &in.Fallbacks, &out.Fallbacks
+		*out = new(ArtifactFallbacks)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -116,6 +184,94 @@ func (in *Artifact) DeepCopy() *Artifact {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ArtifactChecksum) DeepCopyInto(out *ArtifactChecksum) {
+	*out = *in
+	if in.Files != nil {
+		in, out := &in.Files, &out.Files
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArtifactChecksum.
+func (in *ArtifactChecksum) DeepCopy() *ArtifactChecksum {
+	if in == nil {
+		return nil
+	}
+	out := new(ArtifactChecksum)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ArtifactEncryption) DeepCopyInto(out *ArtifactEncryption) {
+	*out = *in
+	if in.PrivateKeySecret != nil {
+		in, out := &in.PrivateKeySecret, &out.PrivateKeySecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PassphraseSecret != nil {
+		in, out := &in.PassphraseSecret, &out.PassphraseSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArtifactEncryption.
+func (in *ArtifactEncryption) DeepCopy() *ArtifactEncryption {
+	if in == nil {
+		return nil
+	}
+	out := new(ArtifactEncryption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ArtifactFallbacks) DeepCopyInto(out *ArtifactFallbacks) {
+	*out = *in
+	if in.Locations != nil {
+		in, out := &in.Locations, &out.Locations
+		*out = make([]ArtifactLocation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArtifactFallbacks.
+func (in *ArtifactFallbacks) DeepCopy() *ArtifactFallbacks {
+	if in == nil {
+		return nil
+	}
+	out := new(ArtifactFallbacks)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ArtifactIdempotency) DeepCopyInto(out *ArtifactIdempotency) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArtifactIdempotency.
+func (in *ArtifactIdempotency) DeepCopy() *ArtifactIdempotency {
+	if in == nil {
+		return nil
+	}
+	out := new(ArtifactIdempotency)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ArtifactLocation) DeepCopyInto(out *ArtifactLocation) {
 	*out = *in
@@ -164,6 +320,76 @@ func (in *ArtifactLocation) DeepCopyInto(out *ArtifactLocation) {
 		*out = new(GCSArtifact)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.SFTP != nil {
+		in, out := &in.SFTP, &out.SFTP
+		*out = new(SFTPArtifact)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GDrive != nil {
+		in, out := &in.GDrive, &out.GDrive
+		*out = new(GDriveArtifact)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WebDAV != nil {
+		in, out := &in.WebDAV, &out.WebDAV
+		*out = new(WebDAVArtifact)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FTP != nil {
+		in, out := &in.FTP, &out.FTP
+		*out = new(FTPArtifact)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.B2 != nil {
+		in, out := &in.B2, &out.B2
+		*out = new(B2Artifact)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Swift != nil {
+		in, out := &in.Swift, &out.Swift
+		*out = new(SwiftArtifact)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Rclone != nil {
+		in, out := &in.Rclone, &out.Rclone
+		*out = new(RcloneArtifact)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Dropbox != nil {
+		in, out := &in.Dropbox, &out.Dropbox
+		*out = new(DropboxArtifact)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IPFS != nil {
+		in, out := &in.IPFS, &out.IPFS
+		*out = new(IPFSArtifact)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ADLS != nil {
+		in, out := &in.ADLS, &out.ADLS
+		*out = new(ADLSArtifact)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HuggingFace != nil {
+		in, out := &in.HuggingFace, &out.HuggingFace
+		*out = new(HuggingFaceArtifact)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DevNull != nil {
+		in, out := &in.DevNull, &out.DevNull
+		*out = new(DevNullArtifact)
+		**out = **in
+	}
+	if in.Filesystem != nil {
+		in, out := &in.Filesystem, &out.Filesystem
+		*out = new(FilesystemArtifact)
+		**out = **in
+	}
+	if in.OCI != nil {
+		in, out := &in.OCI, &out.OCI
+		*out = new(OCIArtifact)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -177,6 +403,34 @@ func (in *ArtifactLocation) DeepCopy() *ArtifactLocation {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ArtifactMirrors) DeepCopyInto(out *ArtifactMirrors) {
+	*out = *in
+	if in.Locations != nil {
+		in, out := &in.Locations, &out.Locations
+		*out = make([]ArtifactLocation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Parallelism != nil {
+		in, out := &in.Parallelism, &out.Parallelism
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArtifactMirrors.
+func (in *ArtifactMirrors) DeepCopy() *ArtifactMirrors {
+	if in == nil {
+		return nil
+	}
+	out := new(ArtifactMirrors)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ArtifactRepositoryRef) DeepCopyInto(out *ArtifactRepositoryRef) {
 	*out = *in
@@ -240,6 +494,16 @@ func (in *ArtifactoryAuth) DeepCopyInto(out *ArtifactoryAuth) {
 		*out = new(v1.SecretKeySelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.APIKeySecret != nil {
+		in, out := &in.APIKeySecret, &out.APIKeySecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AccessTokenSecret != nil {
+		in, out := &in.AccessTokenSecret, &out.AccessTokenSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -275,6 +539,32 @@ func (in Artifacts) DeepCopy() Artifacts {
 	return *out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *B2Artifact) DeepCopyInto(out *B2Artifact) {
+	*out = *in
+	if in.ApplicationKeyIDSecret != nil {
+		in, out := &in.ApplicationKeyIDSecret, &out.ApplicationKeyIDSecret
+		*out = new(v1.SecretKeySelector)
+		**out = **in
+	}
+	if in.ApplicationKeySecret != nil {
+		in, out := &in.ApplicationKeySecret, &out.ApplicationKeySecret
+		*out = new(v1.SecretKeySelector)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new B2Artifact.
+func (in *B2Artifact) DeepCopy() *B2Artifact {
+	if in == nil {
+		return nil
+	}
+	out := new(B2Artifact)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Backoff) DeepCopyInto(out *Backoff) {
 	*out = *in
@@ -683,6 +973,53 @@ func (in *DAGTemplate) DeepCopy() *DAGTemplate {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DevNullArtifact) DeepCopyInto(out *DevNullArtifact) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DevNullArtifact.
+func (in *DevNullArtifact) DeepCopy() *DevNullArtifact {
+	if in == nil {
+		return nil
+	}
+	out := new(DevNullArtifact)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DropboxArtifact) DeepCopyInto(out *DropboxArtifact) {
+	*out = *in
+	if in.AppKeySecret != nil {
+		in, out := &in.AppKeySecret, &out.AppKeySecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AppSecretSecret != nil {
+		in, out := &in.AppSecretSecret, &out.AppSecretSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RefreshTokenSecret != nil {
+		in, out := &in.RefreshTokenSecret, &out.RefreshTokenSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DropboxArtifact.
+func (in *DropboxArtifact) DeepCopy() *DropboxArtifact {
+	if in == nil {
+		return nil
+	}
+	out := new(DropboxArtifact)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Event) DeepCopyInto(out *Event) {
 	*out = *in
@@ -715,6 +1052,53 @@ func (in *ExecutorConfig) DeepCopy() *ExecutorConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FTPArtifact) DeepCopyInto(out *FTPArtifact) {
+	*out = *in
+	if in.UsernameSecret != nil {
+		in, out := &in.UsernameSecret, &out.UsernameSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PasswordSecret != nil {
+		in, out := &in.PasswordSecret, &out.PasswordSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CASecret != nil {
+		in, out := &in.CASecret, &out.CASecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FTPArtifact.
+func (in *FTPArtifact) DeepCopy() *FTPArtifact {
+	if in == nil {
+		return nil
+	}
+	out := new(FTPArtifact)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FilesystemArtifact) DeepCopyInto(out *FilesystemArtifact) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FilesystemArtifact.
+func (in *FilesystemArtifact) DeepCopy() *FilesystemArtifact {
+	if in == nil {
+		return nil
+	}
+	out := new(FilesystemArtifact)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GCSArtifact) DeepCopyInto(out *GCSArtifact) {
 	*out = *in
@@ -740,6 +1124,11 @@ func (in *GCSBucket) DeepCopyInto(out *GCSBucket) {
 		*out = new(v1.SecretKeySelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.EncryptionKeySecret != nil {
+		in, out := &in.EncryptionKeySecret, &out.EncryptionKeySecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -753,6 +1142,27 @@ func (in *GCSBucket) DeepCopy() *GCSBucket {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GDriveArtifact) DeepCopyInto(out *GDriveArtifact) {
+	*out = *in
+	if in.ServiceAccountKeySecret != nil {
+		in, out := &in.ServiceAccountKeySecret, &out.ServiceAccountKeySecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GDriveArtifact.
+func (in *GDriveArtifact) DeepCopy() *GDriveArtifact {
+	if in == nil {
+		return nil
+	}
+	out := new(GDriveArtifact)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Gauge) DeepCopyInto(out *Gauge) {
 	*out = *in
@@ -872,6 +1282,11 @@ func (in *HDFSKrbConfig) DeepCopyInto(out *HDFSKrbConfig) {
 		*out = new(v1.ConfigMapKeySelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.KrbConfigSecret != nil {
+		in, out := &in.KrbConfigSecret, &out.KrbConfigSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -893,15 +1308,108 @@ func (in *HTTPArtifact) DeepCopyInto(out *HTTPArtifact) {
 		*out = make([]Header, len(*in))
 		copy(*out, *in)
 	}
+	if in.Retry != nil {
+		in, out := &in.Retry, &out.Retry
+		*out = new(HTTPRetry)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CASecret != nil {
+		in, out := &in.CASecret, &out.CASecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BearerTokenSecret != nil {
+		in, out := &in.BearerTokenSecret, &out.BearerTokenSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UsernameSecret != nil {
+		in, out := &in.UsernameSecret, &out.UsernameSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PasswordSecret != nil {
+		in, out := &in.PasswordSecret, &out.PasswordSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Upload != nil {
+		in, out := &in.Upload, &out.Upload
+		*out = new(HTTPUpload)
+		**out = **in
+	}
+	if in.Redirects != nil {
+		in, out := &in.Redirects, &out.Redirects
+		*out = new(HTTPRedirects)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPArtifact.
+func (in *HTTPArtifact) DeepCopy() *HTTPArtifact {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPArtifact)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPRedirects) DeepCopyInto(out *HTTPRedirects) {
+	*out = *in
+	if in.Limit != nil {
+		in, out := &in.Limit, &out.Limit
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPRedirects.
+func (in *HTTPRedirects) DeepCopy() *HTTPRedirects {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPRedirects)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPRetry) DeepCopyInto(out *HTTPRetry) {
+	*out = *in
+	if in.Limit != nil {
+		in, out := &in.Limit, &out.Limit
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPRetry.
+func (in *HTTPRetry) DeepCopy() *HTTPRetry {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPRetry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPUpload) DeepCopyInto(out *HTTPUpload) {
+	*out = *in
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPArtifact.
-func (in *HTTPArtifact) DeepCopy() *HTTPArtifact {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPUpload.
+func (in *HTTPUpload) DeepCopy() *HTTPUpload {
 	if in == nil {
 		return nil
 	}
-	out := new(HTTPArtifact)
+	out := new(HTTPUpload)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -943,6 +1451,58 @@ func (in *Histogram) DeepCopy() *Histogram {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HuggingFaceArtifact) DeepCopyInto(out *HuggingFaceArtifact) {
+	*out = *in
+	if in.TokenSecret != nil {
+		in, out := &in.TokenSecret, &out.TokenSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HuggingFaceArtifact.
+func (in *HuggingFaceArtifact) DeepCopy() *HuggingFaceArtifact {
+	if in == nil {
+		return nil
+	}
+	out := new(HuggingFaceArtifact)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPFSArtifact) DeepCopyInto(out *IPFSArtifact) {
+	*out = *in
+	if in.UsernameSecret != nil {
+		in, out := &in.UsernameSecret, &out.UsernameSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PasswordSecret != nil {
+		in, out := &in.PasswordSecret, &out.PasswordSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BearerTokenSecret != nil {
+		in, out := &in.BearerTokenSecret, &out.BearerTokenSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPFSArtifact.
+func (in *IPFSArtifact) DeepCopy() *IPFSArtifact {
+	if in == nil {
+		return nil
+	}
+	out := new(IPFSArtifact)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Inputs) DeepCopyInto(out *Inputs) {
 	*out = *in
@@ -1286,6 +1846,27 @@ func (in *NoneStrategy) DeepCopy() *NoneStrategy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCIArtifact) DeepCopyInto(out *OCIArtifact) {
+	*out = *in
+	if in.CredentialsSecret != nil {
+		in, out := &in.CredentialsSecret, &out.CredentialsSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCIArtifact.
+func (in *OCIArtifact) DeepCopy() *OCIArtifact {
+	if in == nil {
+		return nil
+	}
+	out := new(OCIArtifact)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OSSArtifact) DeepCopyInto(out *OSSArtifact) {
 	*out = *in
@@ -1316,6 +1897,11 @@ func (in *OSSBucket) DeepCopyInto(out *OSSBucket) {
 		*out = new(v1.SecretKeySelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.SecurityTokenSecret != nil {
+		in, out := &in.SecurityTokenSecret, &out.SecurityTokenSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -1502,6 +2088,32 @@ func (in *RawArtifact) DeepCopy() *RawArtifact {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RcloneArtifact) DeepCopyInto(out *RcloneArtifact) {
+	*out = *in
+	if in.ConfigSecret != nil {
+		in, out := &in.ConfigSecret, &out.ConfigSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConfigConfigMap != nil {
+		in, out := &in.ConfigConfigMap, &out.ConfigConfigMap
+		*out = new(v1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RcloneArtifact.
+func (in *RcloneArtifact) DeepCopy() *RcloneArtifact {
+	if in == nil {
+		return nil
+	}
+	out := new(RcloneArtifact)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceTemplate) DeepCopyInto(out *ResourceTemplate) {
 	*out = *in
@@ -1617,6 +2229,23 @@ func (in *RetryStrategy) DeepCopy() *RetryStrategy {
 func (in *S3Artifact) DeepCopyInto(out *S3Artifact) {
 	*out = *in
 	in.S3Bucket.DeepCopyInto(&out.S3Bucket)
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ACL != nil {
+		in, out := &in.ACL, &out.ACL
+		*out = new(S3ObjectACL)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ObjectLock != nil {
+		in, out := &in.ObjectLock, &out.ObjectLock
+		*out = new(S3ObjectLock)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -1653,6 +2282,46 @@ func (in *S3Bucket) DeepCopyInto(out *S3Bucket) {
 		*out = new(CreateS3BucketOptions)
 		**out = **in
 	}
+	if in.ServerSideEncryption != nil {
+		in, out := &in.ServerSideEncryption, &out.ServerSideEncryption
+		*out = new(S3ServerSideEncryption)
+		**out = **in
+	}
+	if in.PathStyle != nil {
+		in, out := &in.PathStyle, &out.PathStyle
+		*out = new(bool)
+		**out = **in
+	}
+	if in.UploadParallelism != nil {
+		in, out := &in.UploadParallelism, &out.UploadParallelism
+		*out = new(int32)
+		**out = **in
+	}
+	if in.CASecret != nil {
+		in, out := &in.CASecret, &out.CASecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RoleChain != nil {
+		in, out := &in.RoleChain, &out.RoleChain
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Retry != nil {
+		in, out := &in.Retry, &out.Retry
+		*out = new(S3Retry)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PartConcurrency != nil {
+		in, out := &in.PartConcurrency, &out.PartConcurrency
+		*out = new(int32)
+		**out = **in
+	}
+	if in.CredentialProviders != nil {
+		in, out := &in.CredentialProviders, &out.CredentialProviders
+		*out = make([]S3CredentialProvider, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -1666,6 +2335,130 @@ func (in *S3Bucket) DeepCopy() *S3Bucket {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S3ObjectACL) DeepCopyInto(out *S3ObjectACL) {
+	*out = *in
+	if in.GrantRead != nil {
+		in, out := &in.GrantRead, &out.GrantRead
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.GrantReadACP != nil {
+		in, out := &in.GrantReadACP, &out.GrantReadACP
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.GrantWriteACP != nil {
+		in, out := &in.GrantWriteACP, &out.GrantWriteACP
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.GrantFullControl != nil {
+		in, out := &in.GrantFullControl, &out.GrantFullControl
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3ObjectACL.
+func (in *S3ObjectACL) DeepCopy() *S3ObjectACL {
+	if in == nil {
+		return nil
+	}
+	out := new(S3ObjectACL)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S3ObjectLock) DeepCopyInto(out *S3ObjectLock) {
+	*out = *in
+	if in.RetainUntilDate != nil {
+		in, out := &in.RetainUntilDate, &out.RetainUntilDate
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3ObjectLock.
+func (in *S3ObjectLock) DeepCopy() *S3ObjectLock {
+	if in == nil {
+		return nil
+	}
+	out := new(S3ObjectLock)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S3Retry) DeepCopyInto(out *S3Retry) {
+	*out = *in
+	if in.Limit != nil {
+		in, out := &in.Limit, &out.Limit
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3Retry.
+func (in *S3Retry) DeepCopy() *S3Retry {
+	if in == nil {
+		return nil
+	}
+	out := new(S3Retry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S3ServerSideEncryption) DeepCopyInto(out *S3ServerSideEncryption) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3ServerSideEncryption.
+func (in *S3ServerSideEncryption) DeepCopy() *S3ServerSideEncryption {
+	if in == nil {
+		return nil
+	}
+	out := new(S3ServerSideEncryption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SFTPArtifact) DeepCopyInto(out *SFTPArtifact) {
+	*out = *in
+	if in.UsernameSecret != nil {
+		in, out := &in.UsernameSecret, &out.UsernameSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PasswordSecret != nil {
+		in, out := &in.PasswordSecret, &out.PasswordSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SSHPrivateKeySecret != nil {
+		in, out := &in.SSHPrivateKeySecret, &out.SSHPrivateKeySecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SFTPArtifact.
+func (in *SFTPArtifact) DeepCopy() *SFTPArtifact {
+	if in == nil {
+		return nil
+	}
+	out := new(SFTPArtifact)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ScriptTemplate) DeepCopyInto(out *ScriptTemplate) {
 	*out = *in
@@ -1867,6 +2660,47 @@ func (in *SuspendTemplate) DeepCopy() *SuspendTemplate {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SwiftArtifact) DeepCopyInto(out *SwiftArtifact) {
+	*out = *in
+	if in.UsernameSecret != nil {
+		in, out := &in.UsernameSecret, &out.UsernameSecret
+		*out = new(v1.SecretKeySelector)
+		**out = **in
+	}
+	if in.PasswordSecret != nil {
+		in, out := &in.PasswordSecret, &out.PasswordSecret
+		*out = new(v1.SecretKeySelector)
+		**out = **in
+	}
+	if in.ProjectSecret != nil {
+		in, out := &in.ProjectSecret, &out.ProjectSecret
+		*out = new(v1.SecretKeySelector)
+		**out = **in
+	}
+	if in.ApplicationCredentialIDSecret != nil {
+		in, out := &in.ApplicationCredentialIDSecret, &out.ApplicationCredentialIDSecret
+		*out = new(v1.SecretKeySelector)
+		**out = **in
+	}
+	if in.ApplicationCredentialSecretSecret != nil {
+		in, out := &in.ApplicationCredentialSecretSecret, &out.ApplicationCredentialSecretSecret
+		*out = new(v1.SecretKeySelector)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SwiftArtifact.
+func (in *SwiftArtifact) DeepCopy() *SwiftArtifact {
+	if in == nil {
+		return nil
+	}
+	out := new(SwiftArtifact)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Synchronization) DeepCopyInto(out *Synchronization) {
 	*out = *in
@@ -2225,6 +3059,32 @@ func (in *VolumeClaimGC) DeepCopy() *VolumeClaimGC {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebDAVArtifact) DeepCopyInto(out *WebDAVArtifact) {
+	*out = *in
+	if in.UsernameSecret != nil {
+		in, out := &in.UsernameSecret, &out.UsernameSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PasswordSecret != nil {
+		in, out := &in.PasswordSecret, &out.PasswordSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebDAVArtifact.
+func (in *WebDAVArtifact) DeepCopy() *WebDAVArtifact {
+	if in == nil {
+		return nil
+	}
+	out := new(WebDAVArtifact)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Workflow) DeepCopyInto(out *Workflow) {
 	*out = *in
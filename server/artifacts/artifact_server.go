@@ -121,7 +121,7 @@ func (a *ArtifactServer) gateKeeping(r *http.Request) (context.Context, error) {
 
 func (a *ArtifactServer) serverInternalError(err error, w http.ResponseWriter) {
 	w.WriteHeader(500)
-	_, _ = w.Write([]byte(err.Error()))
+	_, _ = w.Write([]byte(artifact.UserMessage(err)))
 }
 
 func (a *ArtifactServer) returnArtifact(ctx context.Context, w http.ResponseWriter, r *http.Request, wf *wfv1.Workflow, nodeId, artifactName string) error {
@@ -157,7 +157,8 @@ func (a *ArtifactServer) returnArtifact(ctx context.Context, w http.ResponseWrit
 	tmpPath := tmp.Name()
 	defer func() { _ = os.Remove(tmpPath) }()
 
-	err = driver.Load(art, tmpPath)
+	// compressed artifacts are downloaded decompressed
+	err = artifact.LoadWithProgress(ctx, driver, art, tmpPath, nil)
 	if err != nil {
 		return err
 	}
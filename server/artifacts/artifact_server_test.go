@@ -41,11 +41,11 @@ type fakeArtifactDriver struct {
 	data []byte
 }
 
-func (a *fakeArtifactDriver) Load(_ *wfv1.Artifact, path string) error {
+func (a *fakeArtifactDriver) Load(_ context.Context, _ *wfv1.Artifact, path string) error {
 	return ioutil.WriteFile(path, a.data, 0666)
 }
 
-func (a *fakeArtifactDriver) Save(_ string, _ *wfv1.Artifact) error {
+func (a *fakeArtifactDriver) Save(_ context.Context, _ string, _ *wfv1.Artifact) error {
 	return fmt.Errorf("not implemented")
 }
 
@@ -0,0 +1,230 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	"github.com/argoproj/argo-workflows/v3/util"
+)
+
+// Format is the format of an archive
+type Format string
+
+const (
+	FormatTar   Format = "tar"
+	FormatTarGz Format = "tar.gz"
+	FormatZip   Format = "zip"
+)
+
+// DetectFormat returns the format of the archive at archivePath, by the extension of name, e.g. the key it was
+// loaded from, or otherwise by its content. It is an error if it is not a tar, tar.gz or zip archive.
+func DetectFormat(archivePath, name string) (Format, error) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return FormatTarGz, nil
+	case strings.HasSuffix(lower, ".tar"):
+		return FormatTar, nil
+	case strings.HasSuffix(lower, ".zip"):
+		return FormatZip, nil
+	}
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return "", errors.InternalWrapError(err)
+	}
+	defer util.Close(f)
+	// the magic numbers of gzip and zip are at the start of the file, and that of tar at offset 257
+	header := make([]byte, 262)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", errors.InternalWrapError(err)
+	}
+	header = header[:n]
+	switch {
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return FormatTarGz, nil
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return FormatZip, nil
+	case len(header) == 262 && string(header[257:262]) == "ustar":
+		return FormatTar, nil
+	}
+	return "", errors.Errorf(errors.CodeBadRequest, "%s is not a tar, tar.gz or zip archive", name)
+}
+
+// cleanMember returns the name of a member of an archive without any leading "./" or "/", or trailing "/"
+func cleanMember(name string) string {
+	name = path.Clean("/" + strings.ReplaceAll(name, `\`, "/"))
+	return strings.TrimPrefix(name, "/")
+}
+
+// extractor extracts the member of an archive, or the subtree under it, to a destination path
+type extractor struct {
+	member   string
+	destPath string
+	found    bool
+}
+
+// target returns the path a member of the archive is extracted to, and false if it is not the member or under it
+func (e *extractor) target(name string) (string, bool, error) {
+	name = cleanMember(name)
+	var rel string
+	switch {
+	case e.member == "":
+		rel = name
+	case name == e.member:
+		rel = ""
+	case strings.HasPrefix(name, e.member+"/"):
+		rel = strings.TrimPrefix(name, e.member+"/")
+	default:
+		return "", false, nil
+	}
+	target := filepath.Join(e.destPath, filepath.FromSlash(rel))
+	if target != e.destPath && !strings.HasPrefix(target, filepath.Clean(e.destPath)+string(os.PathSeparator)) {
+		return "", false, errors.InternalErrorf("%s: Illegal file path", name)
+	}
+	e.found = true
+	return target, true, nil
+}
+
+func writeFile(target string, mode os.FileMode, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return errors.InternalWrapError(err)
+	}
+	// archives written without permissions, e.g. on Windows, have none
+	perm := mode.Perm()
+	if perm == 0 {
+		perm = 0644
+	}
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	_, err = io.Copy(f, r)
+	closeErr := f.Close()
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	if closeErr != nil {
+		return errors.InternalWrapError(closeErr)
+	}
+	return nil
+}
+
+// ExtractPath extracts member, a file or a directory of the archive at archivePath, to destPath. A directory is
+// extracted with everything under it, keeping its structure, so that destPath is the directory. An empty member
+// extracts all of the archive. Only regular files and directories are extracted.
+func ExtractPath(archivePath string, format Format, member, destPath string) error {
+	e := &extractor{member: cleanMember(member), destPath: destPath}
+	log.Infof("Extracting %q from %s archive %s to %s", e.member, format, archivePath, destPath)
+	var err error
+	switch format {
+	case FormatTar, FormatTarGz:
+		err = e.extractTar(archivePath, format == FormatTarGz)
+	case FormatZip:
+		err = e.extractZip(archivePath)
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "unsupported archive format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+	if !e.found {
+		return errors.Errorf(errors.CodeNotFound, "%s not found in archive %s", member, archivePath)
+	}
+	return nil
+}
+
+func (e *extractor) extractTar(archivePath string, gzipped bool) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	defer util.Close(f)
+	var r io.Reader = bufio.NewReader(f)
+	if gzipped {
+		gzr, err := gzip.NewReader(r)
+		if err != nil {
+			return errors.InternalWrapError(err)
+		}
+		defer util.Close(gzr)
+		r = gzr
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.InternalWrapError(err)
+		}
+		target, ok, err := e.target(header.Name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return errors.InternalWrapError(err)
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			log.Debugf("Extracting file: %s", header.Name)
+			if err := writeFile(target, os.FileMode(header.Mode), tr); err != nil {
+				return err
+			}
+		default:
+			log.Warnf("Skipping %s of the archive, which is neither a regular file nor a directory", header.Name)
+		}
+	}
+}
+
+func (e *extractor) extractZip(archivePath string) error {
+	r, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	defer util.Close(r)
+	for _, f := range r.File {
+		target, ok, err := e.target(f.Name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		switch {
+		case f.FileInfo().IsDir():
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return errors.InternalWrapError(err)
+			}
+		case f.Mode().IsRegular():
+			log.Debugf("Extracting file: %s", f.Name)
+			rc, err := f.Open()
+			if err != nil {
+				return errors.InternalWrapError(err)
+			}
+			err = writeFile(target, f.Mode(), rc)
+			util.Close(rc)
+			if err != nil {
+				return err
+			}
+		default:
+			log.Warnf("Skipping %s of the archive, which is neither a regular file nor a directory", f.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+)
+
+// testMember is a member of an archive, which is a directory if its name ends with a slash
+type testMember struct {
+	name string
+	data string
+}
+
+var testMembers = []testMember{
+	{name: "bundle/"},
+	{name: "bundle/a.txt", data: "a"},
+	{name: "bundle/sub/"},
+	{name: "bundle/sub/b.txt", data: "b"},
+	{name: "bundle/sub/c/d.txt", data: "d"},
+	{name: "bundle/subway.txt", data: "not in sub"},
+	{name: "./other.txt", data: "other"},
+}
+
+func writeTar(t *testing.T, w io.Writer) {
+	tw := tar.NewWriter(w)
+	for _, m := range testMembers {
+		header := &tar.Header{Name: m.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(m.data))}
+		if strings.HasSuffix(m.name, "/") {
+			header = &tar.Header{Name: m.name, Mode: 0755, Typeflag: tar.TypeDir}
+		}
+		assert.NoError(t, tw.WriteHeader(header))
+		_, err := io.WriteString(tw, m.data)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+}
+
+func writeZip(t *testing.T, w io.Writer) {
+	zw := zip.NewWriter(w)
+	for _, m := range testMembers {
+		fw, err := zw.Create(m.name)
+		if assert.NoError(t, err) {
+			_, err = io.WriteString(fw, m.data)
+			assert.NoError(t, err)
+		}
+	}
+	assert.NoError(t, zw.Close())
+}
+
+// writeArchive writes the test members to an archive of the format, with the name
+func writeArchive(t *testing.T, dir, name string, format Format) string {
+	p := filepath.Join(dir, name)
+	f, err := os.Create(p)
+	if !assert.NoError(t, err) {
+		return p
+	}
+	defer func() { assert.NoError(t, f.Close()) }()
+	switch format {
+	case FormatTar:
+		writeTar(t, f)
+	case FormatTarGz:
+		gzw := gzip.NewWriter(f)
+		writeTar(t, gzw)
+		assert.NoError(t, gzw.Close())
+	case FormatZip:
+		writeZip(t, f)
+	}
+	return p
+}
+
+func readFile(t *testing.T, p string) string {
+	data, err := ioutil.ReadFile(p)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestExtractPath(t *testing.T) {
+	for _, format := range []Format{FormatTar, FormatTarGz, FormatZip} {
+		t.Run(string(format), func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "extract")
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer func() { _ = os.RemoveAll(dir) }()
+			archivePath := writeArchive(t, dir, "archive", format)
+
+			t.Run("File", func(t *testing.T) {
+				dest := filepath.Join(dir, "file", "a.txt")
+				if assert.NoError(t, ExtractPath(archivePath, format, "bundle/a.txt", dest)) {
+					assert.Equal(t, "a", readFile(t, dest))
+				}
+			})
+			t.Run("FileWithLeadingDot", func(t *testing.T) {
+				dest := filepath.Join(dir, "other")
+				if assert.NoError(t, ExtractPath(archivePath, format, "other.txt", dest)) {
+					assert.Equal(t, "other", readFile(t, dest))
+				}
+			})
+			t.Run("Subtree", func(t *testing.T) {
+				dest := filepath.Join(dir, "subtree")
+				if !assert.NoError(t, ExtractPath(archivePath, format, "./bundle/sub/", dest)) {
+					return
+				}
+				assert.Equal(t, "b", readFile(t, filepath.Join(dest, "b.txt")))
+				assert.Equal(t, "d", readFile(t, filepath.Join(dest, "c", "d.txt")))
+				// a member whose name only starts with that of the directory is not under it
+				_, err := os.Stat(filepath.Join(dir, "subtree", "way.txt"))
+				assert.True(t, os.IsNotExist(err))
+				files, err := ioutil.ReadDir(dest)
+				if assert.NoError(t, err) {
+					assert.Len(t, files, 2)
+				}
+			})
+			t.Run("NotFound", func(t *testing.T) {
+				err := ExtractPath(archivePath, format, "bundle/missing.txt", filepath.Join(dir, "missing"))
+				assert.True(t, errors.IsCode(errors.CodeNotFound, err), err)
+			})
+			t.Run("Escape", func(t *testing.T) {
+				// a member cannot be outside the archive, so this is the one at its root
+				dest := filepath.Join(dir, "escape")
+				if assert.NoError(t, ExtractPath(archivePath, format, "../other.txt", dest)) {
+					assert.Equal(t, "other", readFile(t, dest))
+				}
+			})
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "detect")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	for _, format := range []Format{FormatTar, FormatTarGz, FormatZip} {
+		t.Run(string(format), func(t *testing.T) {
+			archivePath := writeArchive(t, dir, string(format), format)
+			t.Run("Content", func(t *testing.T) {
+				detected, err := DetectFormat(archivePath, "my-key")
+				if assert.NoError(t, err) {
+					assert.Equal(t, format, detected)
+				}
+			})
+			t.Run("Extension", func(t *testing.T) {
+				detected, err := DetectFormat(archivePath, "path/to/my-bundle."+strings.ToUpper(string(format)))
+				if assert.NoError(t, err) {
+					assert.Equal(t, format, detected)
+				}
+			})
+		})
+	}
+	t.Run("TGZ", func(t *testing.T) {
+		detected, err := DetectFormat(filepath.Join(dir, "missing"), "my-bundle.tgz")
+		if assert.NoError(t, err) {
+			assert.Equal(t, FormatTarGz, detected)
+		}
+	})
+	t.Run("NotAnArchive", func(t *testing.T) {
+		p := filepath.Join(dir, "text")
+		assert.NoError(t, ioutil.WriteFile(p, []byte("hello"), 0600))
+		_, err := DetectFormat(p, "my-key")
+		assert.True(t, errors.IsCode(errors.CodeBadRequest, err), err)
+	})
+}
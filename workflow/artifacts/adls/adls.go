@@ -0,0 +1,332 @@
+package adls
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+// ArtifactDriver is the artifact driver for Azure Data Lake Storage Gen2, which loads, saves and deletes files and
+// directories with the Data Lake Storage API of a storage account with a hierarchical namespace. Directories are real
+// ones, so a directory is saved by uploading it under a temporary name and renaming it, and deleted, atomically.
+type ArtifactDriver struct {
+	// AccountKey is the base64 encoded key of the storage account, which requests are signed with, if set
+	AccountKey string
+	// SASToken is a shared access signature, which is added to the query of requests, if set
+	SASToken string
+	// UseWorkloadIdentity authorizes requests with an Azure AD token of the Workload Identity of the pod
+	UseWorkloadIdentity bool
+	// UserDelegationSASDuration is how long the user delegation SAS, which requests are authorized with instead of the
+	// token of the Workload Identity if it is set, is valid for
+	UserDelegationSASDuration time.Duration
+	common.Logging
+}
+
+// ValidateArtifact validates the ADLS artifact, beyond what the general artifact validation checks
+func ValidateArtifact(errPrefix string, art *wfv1.ADLSArtifact) error {
+	if u, err := url.Parse(art.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+		return errors.Errorf(errors.CodeBadRequest, "%s.endpoint must be the Data Lake Storage endpoint of the storage account, e.g. https://myaccount.dfs.core.windows.net", errPrefix)
+	}
+	if art.Filesystem == "" {
+		return errors.Errorf(errors.CodeBadRequest, "%s.filesystem is required", errPrefix)
+	}
+	if cleanPath(art.Path) == "" {
+		return errors.Errorf(errors.CodeBadRequest, "%s.path is required", errPrefix)
+	}
+	auths := 0
+	for _, set := range []bool{art.AccountKeySecret != nil, art.SASTokenSecret != nil, art.UseWorkloadIdentity} {
+		if set {
+			auths++
+		}
+	}
+	if auths != 1 {
+		return errors.Errorf(errors.CodeBadRequest, "exactly one of %s.accountKeySecret, %s.sasTokenSecret or %s.useWorkloadIdentity is required", errPrefix, errPrefix, errPrefix)
+	}
+	if art.UserDelegationSASDuration != "" {
+		if !art.UseWorkloadIdentity {
+			return errors.Errorf(errors.CodeBadRequest, "%s.userDelegationSASDuration requires %s.useWorkloadIdentity", errPrefix, errPrefix)
+		}
+		if _, err := ParseUserDelegationSASDuration(art.UserDelegationSASDuration); err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "%s.userDelegationSASDuration %q is invalid: %v", errPrefix, art.UserDelegationSASDuration, err)
+		}
+	}
+	return nil
+}
+
+const (
+	minUserDelegationSASDuration = time.Minute
+	// maxUserDelegationSASDuration is the longest a user delegation key, and so a SAS signed by it, is valid for
+	maxUserDelegationSASDuration = 7 * 24 * time.Hour
+)
+
+// ParseUserDelegationSASDuration parses how long user delegation SASs are valid for, which is zero, for requests to
+// be authorized with the token of the Workload Identity instead, if not set
+func ParseUserDelegationSASDuration(duration string) (time.Duration, error) {
+	if duration == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return 0, err
+	}
+	if d < minUserDelegationSASDuration || d > maxUserDelegationSASDuration {
+		return 0, fmt.Errorf("must be between %v and %v", minUserDelegationSASDuration, maxUserDelegationSASDuration)
+	}
+	return d, nil
+}
+
+// the permissions of the user delegation SASs of each operation, in the order the service requires them in
+const (
+	loadPermissions   = "rl"
+	savePermissions   = "rcwdm"
+	existsPermissions = "r"
+	deletePermissions = "d"
+)
+
+// cleanPath returns the path of an artifact in its file system, without leading or trailing slashes
+func cleanPath(p string) string {
+	return strings.Trim(path.Clean("/"+p), "/")
+}
+
+// accountName returns the name of the storage account of an endpoint, which is the first label of its host
+func accountName(endpoint *url.URL) string {
+	return strings.SplitN(endpoint.Hostname(), ".", 2)[0]
+}
+
+// newClient returns a client of the file system of the artifact. One authorized with a user delegation SAS only has
+// the permissions, which are those of an operation, e.g. savePermissions.
+func (d *ArtifactDriver) newClient(ctx context.Context, art *wfv1.ADLSArtifact, permissions string) (*client, error) {
+	endpoint, err := url.Parse(art.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	c := &client{ctx: ctx, httpClient: &http.Client{}, endpoint: endpoint, filesystem: art.Filesystem, account: accountName(endpoint), now: time.Now}
+	switch {
+	case d.UseWorkloadIdentity:
+		c.token, err = workloadIdentityToken(ctx, c.httpClient)
+		if err != nil {
+			return nil, err
+		}
+		if d.UserDelegationSASDuration > 0 {
+			c.sasToken, err = c.userDelegationSAS(permissions, d.UserDelegationSASDuration)
+			if err != nil {
+				return nil, err
+			}
+			c.token = ""
+		}
+	case d.SASToken != "":
+		c.sasToken, err = url.ParseQuery(strings.TrimPrefix(strings.TrimSpace(d.SASToken), "?"))
+		if err != nil {
+			return nil, errors.Errorf(errors.CodeBadRequest, "the ADLS SAS token is not a query string: %v", err)
+		}
+	case d.AccountKey != "":
+		c.accountKey, err = base64.StdEncoding.DecodeString(strings.TrimSpace(d.AccountKey))
+		if err != nil {
+			return nil, errors.Errorf(errors.CodeBadRequest, "the ADLS account key is not base64 encoded: %v", err)
+		}
+	}
+	return c, nil
+}
+
+// Load downloads the file, or every file under the directory, and every directory under it, even if it is empty
+func (d *ArtifactDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, localPath string) error {
+	p := cleanPath(inputArtifact.ADLS.Path)
+	d.Log().Infof("ADLS Load path: %s, filesystem: %s, adls path: %s", localPath, inputArtifact.ADLS.Filesystem, p)
+	c, err := d.newClient(ctx, inputArtifact.ADLS, loadPermissions)
+	if err != nil {
+		return err
+	}
+	info, err := c.getProperties(p)
+	if err != nil {
+		return err
+	}
+	if info.isDirectory {
+		return d.loadDirectory(c, p, localPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(localPath), 0700); err != nil {
+		return err
+	}
+	if err := c.read(p, localPath); err != nil {
+		_ = os.Remove(localPath)
+		return err
+	}
+	return nil
+}
+
+func (d *ArtifactDriver) loadDirectory(c *client, dir, localPath string) error {
+	entries, err := c.list(dir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(localPath, 0700); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name, dir+"/") {
+			continue
+		}
+		rel := strings.TrimPrefix(entry.Name, dir+"/")
+		if cleanPath(rel) != rel {
+			return fmt.Errorf("ADLS directory %s has an entry with an invalid name %q", dir, entry.Name)
+		}
+		p := filepath.Join(localPath, filepath.FromSlash(rel))
+		if entry.isDir() {
+			if err := os.MkdirAll(p, 0700); err != nil {
+				return err
+			}
+			continue
+		}
+		d.Log().Debugf("ADLS reading %s to %s", entry.Name, p)
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			return err
+		}
+		if err := c.read(entry.Name, p); err != nil {
+			_ = os.Remove(p)
+			return err
+		}
+	}
+	return nil
+}
+
+// Save uploads the file, or the directory and everything under it, under a temporary name next to its path, and then
+// renames it to its path, so that it is replaced atomically. A directory, or a file which a directory is replaced with,
+// replaces what is at the path by deleting it first, as a rename only replaces a file.
+func (d *ArtifactDriver) Save(ctx context.Context, localPath string, outputArtifact *wfv1.Artifact) error {
+	p := cleanPath(outputArtifact.ADLS.Path)
+	d.Log().Infof("ADLS Save path: %s, filesystem: %s, adls path: %s", localPath, outputArtifact.ADLS.Filesystem, p)
+	if p == "" {
+		return errors.Errorf(errors.CodeBadRequest, "ADLS artifact %s has no path", outputArtifact.Name)
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+	c, err := d.newClient(ctx, outputArtifact.ADLS, savePermissions)
+	if err != nil {
+		return err
+	}
+	tmp, err := tempPath(p)
+	if err != nil {
+		return err
+	}
+	if err := d.saveTemp(c, localPath, tmp, info); err != nil {
+		d.deleteTemp(c, tmp)
+		return err
+	}
+	existing, err := c.getProperties(p)
+	if err == nil && (existing.isDirectory || info.IsDir()) {
+		err = c.delete(p)
+	}
+	if err != nil && !stderrors.Is(err, common.ErrArtifactNotFound) {
+		d.deleteTemp(c, tmp)
+		return fmt.Errorf("ADLS replace of %s failed: %w", p, err)
+	}
+	if err := c.rename(tmp, p); err != nil {
+		d.deleteTemp(c, tmp)
+		return err
+	}
+	return nil
+}
+
+// saveTemp uploads the file, or creates the directory, and every directory and file under it, at the temporary path
+func (d *ArtifactDriver) saveTemp(c *client, localPath, tmp string, info os.FileInfo) error {
+	if !info.IsDir() {
+		return uploadFile(c, localPath, tmp, info.Size())
+	}
+	return filepath.Walk(localPath, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localPath, p)
+		if err != nil {
+			return err
+		}
+		remote := path.Join(tmp, filepath.ToSlash(rel))
+		if fi.IsDir() {
+			return c.createDirectory(remote)
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		d.Log().Debugf("ADLS uploading %s to %s", p, remote)
+		return uploadFile(c, p, remote, fi.Size())
+	})
+}
+
+func uploadFile(c *client, localPath, p string, size int64) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	return c.upload(p, f, size)
+}
+
+// deleteTemp deletes what was uploaded to the temporary path of a save which failed
+func (d *ArtifactDriver) deleteTemp(c *client, tmp string) {
+	if err := c.delete(tmp); err != nil && !stderrors.Is(err, common.ErrArtifactNotFound) {
+		d.Log().Warnf("ADLS failed to delete %s, which a failed save was uploaded to: %v", tmp, err)
+	}
+}
+
+// tempPath returns a unique path next to the path, which is hidden by a leading dot
+func tempPath(p string) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	dir, base := path.Split(p)
+	return dir + "." + base + ".argo-" + hex.EncodeToString(b), nil
+}
+
+// Exists returns whether there is a file or directory at the path of the artifact
+func (d *ArtifactDriver) Exists(artifact *wfv1.Artifact) (bool, error) {
+	c, err := d.newClient(context.Background(), artifact.ADLS, existsPermissions)
+	if err != nil {
+		return false, err
+	}
+	_, err = c.getProperties(cleanPath(artifact.ADLS.Path))
+	if stderrors.Is(err, common.ErrArtifactNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Delete deletes the file, or the directory and everything under it, which is not an error if there is none
+func (d *ArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	p := cleanPath(artifact.ADLS.Path)
+	d.Log().Infof("ADLS Delete filesystem: %s, adls path: %s", artifact.ADLS.Filesystem, p)
+	if p == "" {
+		return errors.Errorf(errors.CodeBadRequest, "ADLS cannot delete the root of a file system")
+	}
+	c, err := d.newClient(context.Background(), artifact.ADLS, deletePermissions)
+	if err != nil {
+		return err
+	}
+	if err := c.delete(p); err != nil && !stderrors.Is(err, common.ErrArtifactNotFound) {
+		return fmt.Errorf("ADLS delete of %s failed: %w", p, err)
+	}
+	return nil
+}
+
+// Capabilities returns the optional operations on ADLS artifacts the driver supports
+func (d *ArtifactDriver) Capabilities() common.DriverCapabilities {
+	return common.DriverCapabilities{
+		Delete: true,
+		Exists: true,
+	}
+}
@@ -0,0 +1,651 @@
+package adls
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const (
+	testFilesystem = "my-fs"
+	testSignature  = "my-signature"
+	testToken      = "my-token"
+	// the user delegation key the fake returns, and the resource the SASs signed by it are of
+	testDelegationKey = "my-delegation-key"
+	testSASResource   = "/blob/127/" + testFilesystem
+	testChunkSize     = 5
+	// the number of paths the fake lists per page
+	listPageSize = 2
+)
+
+// fakeADLS is the Data Lake Storage API of a single file system with a hierarchical namespace, whose files and
+// directories are keyed by their paths in it
+type fakeADLS struct {
+	*httptest.Server
+	mu sync.Mutex
+	// the committed contents of files, the directories, and the contents appended to files but not yet flushed
+	files   map[string][]byte
+	dirs    map[string]bool
+	pending map[string][]byte
+	// the number of appends and renames
+	appends, renames int
+	// whether flushes, and requests of user delegation keys, fail
+	failFlush, failDelegationKey bool
+	// the start and expiry of the user delegation keys requested
+	delegationKeys []string
+	// authorized returns whether a request is authorized
+	authorized func(r *http.Request) bool
+}
+
+func newFakeADLS() *fakeADLS {
+	f := &fakeADLS{
+		files:      map[string][]byte{},
+		dirs:       map[string]bool{},
+		pending:    map[string][]byte{},
+		authorized: func(r *http.Request) bool { return r.URL.Query().Get("sig") == testSignature },
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+func (f *fakeADLS) artifact(p string) *wfv1.Artifact {
+	return &wfv1.Artifact{Name: "my-artifact", ArtifactLocation: wfv1.ArtifactLocation{ADLS: &wfv1.ADLSArtifact{Endpoint: f.URL, Filesystem: testFilesystem, Path: p}}}
+}
+
+func (f *fakeADLS) put(p string, data []byte) {
+	f.files[p] = data
+	f.mkdirs(path.Dir(p))
+}
+
+// mkdirs creates the directory and its parents, as the service does
+func (f *fakeADLS) mkdirs(dir string) {
+	for ; dir != "." && dir != ""; dir = path.Dir(dir) {
+		f.dirs[dir] = true
+	}
+}
+
+// under returns the paths of the files and directories at or under the path
+func (f *fakeADLS) under(p string) []string {
+	var paths []string
+	for _, m := range []map[string]bool{f.dirs, keys(f.files)} {
+		for k := range m {
+			if k == p || strings.HasPrefix(k, p+"/") {
+				paths = append(paths, k)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func keys(files map[string][]byte) map[string]bool {
+	m := make(map[string]bool, len(files))
+	for k := range files {
+		m[k] = true
+	}
+	return m
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, code string) {
+	w.Header().Set("x-ms-error-code", code)
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"code": code, "message": "The request failed."}})
+	}
+}
+
+func (f *fakeADLS) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path == "/my-tenant/oauth2/v2.0/token" {
+		if r.FormValue("client_id") != "my-client" || r.FormValue("client_assertion") != "my-federated-token" || r.FormValue("scope") != storageScope {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client", "error_description": "AADSTS700211"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"access_token": testToken, "token_type": "Bearer", "expires_in": 3599})
+		return
+	}
+	if r.URL.Path == "/" && r.URL.Query().Get("restype") == "service" && r.URL.Query().Get("comp") == "userdelegationkey" {
+		f.userDelegationKey(w, r)
+		return
+	}
+	if !f.authorized(r) || r.Header.Get("x-ms-version") != apiVersion {
+		writeError(w, r, http.StatusForbidden, "AuthorizationFailure")
+		return
+	}
+	if r.URL.Path != "/"+testFilesystem && !strings.HasPrefix(r.URL.Path, "/"+testFilesystem+"/") {
+		writeError(w, r, http.StatusNotFound, "FilesystemNotFound")
+		return
+	}
+	p := strings.Trim(strings.TrimPrefix(r.URL.Path, "/"+testFilesystem), "/")
+	q := r.URL.Query()
+	switch r.Method {
+	case http.MethodHead:
+		if data, ok := f.files[p]; ok {
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Header().Set(resourceTypeHeader, "file")
+		} else if f.dirs[p] {
+			w.Header().Set(resourceTypeHeader, "directory")
+		} else {
+			writeError(w, r, http.StatusNotFound, "PathNotFound")
+		}
+	case http.MethodGet:
+		if p == "" && q.Get("resource") == "filesystem" {
+			f.list(w, r)
+		} else if data, ok := f.files[p]; ok {
+			_, _ = w.Write(data)
+		} else {
+			writeError(w, r, http.StatusNotFound, "PathNotFound")
+		}
+	case http.MethodPut:
+		if source := r.Header.Get(renameSourceHeader); source != "" {
+			f.rename(w, r, source, p)
+			return
+		}
+		switch q.Get("resource") {
+		case "file":
+			if f.dirs[p] {
+				writeError(w, r, http.StatusConflict, "PathConflict")
+				return
+			}
+			f.put(p, []byte{})
+			f.pending[p] = []byte{}
+		case "directory":
+			f.mkdirs(p)
+		}
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodPatch:
+		pending, ok := f.pending[p]
+		position, _ := strconv.Atoi(q.Get("position"))
+		if !ok || position != len(pending) {
+			writeError(w, r, http.StatusBadRequest, "InvalidFlushPosition")
+			return
+		}
+		switch q.Get("action") {
+		case "append":
+			data, _ := ioutil.ReadAll(r.Body)
+			f.pending[p] = append(pending, data...)
+			f.appends++
+			w.WriteHeader(http.StatusAccepted)
+		case "flush":
+			if f.failFlush {
+				writeError(w, r, http.StatusInternalServerError, "InternalError")
+				return
+			}
+			f.files[p] = pending
+			delete(f.pending, p)
+		}
+	case http.MethodDelete:
+		paths := f.under(p)
+		if len(paths) == 0 {
+			writeError(w, r, http.StatusNotFound, "PathNotFound")
+			return
+		}
+		if len(paths) > 1 && q.Get("recursive") != "true" {
+			writeError(w, r, http.StatusConflict, "DirectoryNotEmpty")
+			return
+		}
+		for _, k := range paths {
+			delete(f.files, k)
+			delete(f.dirs, k)
+		}
+	}
+}
+
+func (f *fakeADLS) list(w http.ResponseWriter, r *http.Request) {
+	dir := r.URL.Query().Get("directory")
+	if !f.dirs[dir] {
+		writeError(w, r, http.StatusNotFound, "PathNotFound")
+		return
+	}
+	var paths []map[string]interface{}
+	for _, k := range f.under(dir) {
+		if k == dir {
+			continue
+		}
+		if data, ok := f.files[k]; ok {
+			paths = append(paths, map[string]interface{}{"name": k, "contentLength": strconv.Itoa(len(data))})
+		} else {
+			paths = append(paths, map[string]interface{}{"name": k, "isDirectory": "true", "contentLength": "0"})
+		}
+	}
+	start, _ := strconv.Atoi(r.URL.Query().Get("continuation"))
+	end := start + listPageSize
+	if end < len(paths) {
+		w.Header().Set(continuationHeader, strconv.Itoa(end))
+	} else {
+		end = len(paths)
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"paths": paths[start:end]})
+}
+
+func (f *fakeADLS) rename(w http.ResponseWriter, r *http.Request, source, dst string) {
+	u, err := url.Parse(source)
+	// the source of a rename is authorized by the SAS, if any, which the request is
+	if err != nil || u.Query().Get("sig") != r.URL.Query().Get("sig") {
+		writeError(w, r, http.StatusForbidden, "AuthorizationFailure")
+		return
+	}
+	src := strings.TrimPrefix(u.Path, "/"+testFilesystem+"/")
+	paths := f.under(src)
+	if len(paths) == 0 {
+		writeError(w, r, http.StatusNotFound, "SourcePathNotFound")
+		return
+	}
+	if f.dirs[dst] {
+		writeError(w, r, http.StatusConflict, "PathAlreadyExists")
+		return
+	}
+	for _, k := range paths {
+		moved := dst + strings.TrimPrefix(k, src)
+		if data, ok := f.files[k]; ok {
+			delete(f.files, k)
+			f.files[moved] = data
+		} else {
+			delete(f.dirs, k)
+			f.dirs[moved] = true
+		}
+	}
+	f.renames++
+	w.WriteHeader(http.StatusCreated)
+}
+
+// userDelegationKey returns a user delegation key for the Workload Identity, which is valid from the start to the
+// expiry of the request, as the Blob Storage API does
+func (f *fakeADLS) userDelegationKey(w http.ResponseWriter, r *http.Request) {
+	var keyInfo struct {
+		Start  string `xml:"Start"`
+		Expiry string `xml:"Expiry"`
+	}
+	if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer "+testToken || f.failDelegationKey {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?><Error><Code>AuthorizationPermissionMismatch</Code><Message>This request is not authorized.</Message></Error>`))
+		return
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&keyInfo); err != nil {
+		writeError(w, r, http.StatusBadRequest, "InvalidXmlDocument")
+		return
+	}
+	f.delegationKeys = append(f.delegationKeys, keyInfo.Start+"/"+keyInfo.Expiry)
+	_ = xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"UserDelegationKey"`
+		userDelegationKey
+	}{userDelegationKey: userDelegationKey{
+		SignedOid:     "my-object-id",
+		SignedTid:     "my-tenant",
+		SignedStart:   keyInfo.Start,
+		SignedExpiry:  keyInfo.Expiry,
+		SignedService: "b",
+		SignedVersion: apiVersion,
+		Value:         base64.StdEncoding.EncodeToString([]byte(testDelegationKey)),
+	}})
+}
+
+func (f *fakeADLS) contents(dir string) map[string]string {
+	files := map[string]string{}
+	for k, data := range f.files {
+		if strings.HasPrefix(k, dir+"/") {
+			files[strings.TrimPrefix(k, dir+"/")] = string(data)
+		}
+	}
+	return files
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0700))
+		assert.NoError(t, ioutil.WriteFile(p, []byte(data), 0600))
+	}
+}
+
+func readFiles(t *testing.T, dir string) map[string]string {
+	files := map[string]string{}
+	assert.NoError(t, filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(p)
+		files[filepath.ToSlash(rel)] = string(data)
+		return err
+	}))
+	return files
+}
+
+func setChunkSize(t *testing.T) {
+	chunkSize := uploadChunkSize
+	uploadChunkSize = testChunkSize
+	t.Cleanup(func() { uploadChunkSize = chunkSize })
+}
+
+func TestArtifactDriver_Directory(t *testing.T) {
+	setChunkSize(t)
+	f := newFakeADLS()
+	defer f.Close()
+	f.put("data/out/stale.txt", []byte("stale"))
+	tmp, err := ioutil.TempDir("", "adls")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	files := map[string]string{
+		"part-00000":        "a",
+		"logs/history.log":  "more than a chunk",
+		"logs/sub/deep.txt": "d",
+		"empty.txt":         "",
+	}
+	src := filepath.Join(tmp, "src")
+	writeFiles(t, src, files)
+	assert.NoError(t, os.MkdirAll(filepath.Join(src, "no-files"), 0700))
+	driver := &ArtifactDriver{SASToken: "?sv=2021-06-08&sig=" + testSignature}
+
+	t.Run("Save", func(t *testing.T) {
+		if !assert.NoError(t, driver.Save(context.Background(), src, f.artifact("/data/out/"))) {
+			return
+		}
+		// the directory replaces the one which was there, and is renamed from where it was uploaded to
+		assert.Equal(t, files, f.contents("data/out"))
+		assert.True(t, f.dirs["data/out/no-files"])
+		assert.Equal(t, 1, f.renames)
+		for _, k := range f.under("data") {
+			assert.NotContains(t, k, ".argo-")
+		}
+		// "more than a chunk" is appended in 4 chunks, and each other file which is not empty in one
+		assert.Equal(t, 6, f.appends)
+	})
+	t.Run("Load", func(t *testing.T) {
+		dst := filepath.Join(tmp, "dst", "out")
+		if !assert.NoError(t, driver.Load(context.Background(), f.artifact("data/out"), dst)) {
+			return
+		}
+		assert.Equal(t, files, readFiles(t, dst))
+		info, err := os.Stat(filepath.Join(dst, "no-files"))
+		if assert.NoError(t, err) {
+			assert.True(t, info.IsDir())
+		}
+	})
+	t.Run("Exists", func(t *testing.T) {
+		exists, err := driver.Exists(f.artifact("data/out"))
+		assert.NoError(t, err)
+		assert.True(t, exists)
+		exists, err = driver.Exists(f.artifact("data/missing"))
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+	t.Run("Delete", func(t *testing.T) {
+		assert.NoError(t, driver.Delete(f.artifact("data/out")))
+		assert.Empty(t, f.under("data/out"))
+		assert.NoError(t, driver.Delete(f.artifact("data/out")))
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		err := driver.Load(context.Background(), f.artifact("data/out"), filepath.Join(tmp, "missing"))
+		assert.True(t, errors.Is(err, common.ErrArtifactNotFound), err)
+	})
+}
+
+func TestArtifactDriver_File(t *testing.T) {
+	setChunkSize(t)
+	f := newFakeADLS()
+	defer f.Close()
+	f.put("data/file.txt", []byte("old"))
+	f.put("data/was-a-dir/file.txt", []byte("in a dir"))
+	tmp, err := ioutil.TempDir("", "adls")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	src := filepath.Join(tmp, "file.txt")
+	assert.NoError(t, ioutil.WriteFile(src, []byte("new content"), 0600))
+	driver := &ArtifactDriver{SASToken: "sig=" + testSignature}
+
+	t.Run("ReplaceFile", func(t *testing.T) {
+		if assert.NoError(t, driver.Save(context.Background(), src, f.artifact("data/file.txt"))) {
+			assert.Equal(t, "new content", string(f.files["data/file.txt"]))
+		}
+		dst := filepath.Join(tmp, "loaded", "file.txt")
+		if assert.NoError(t, driver.Load(context.Background(), f.artifact("data/file.txt"), dst)) {
+			data, err := ioutil.ReadFile(dst)
+			assert.NoError(t, err)
+			assert.Equal(t, "new content", string(data))
+		}
+	})
+	t.Run("ReplaceDirectory", func(t *testing.T) {
+		if assert.NoError(t, driver.Save(context.Background(), src, f.artifact("data/was-a-dir"))) {
+			assert.Equal(t, "new content", string(f.files["data/was-a-dir"]))
+			assert.False(t, f.dirs["data/was-a-dir"])
+			assert.Empty(t, f.under("data/was-a-dir/file.txt"))
+		}
+	})
+	t.Run("Failed", func(t *testing.T) {
+		f.failFlush = true
+		defer func() { f.failFlush = false }()
+		assert.Error(t, driver.Save(context.Background(), src, f.artifact("data/unchanged.txt")))
+		// nothing is left of the upload
+		assert.Equal(t, []string{"data", "data/file.txt", "data/was-a-dir"}, f.under("data"))
+	})
+}
+
+func TestArtifactDriver_Auth(t *testing.T) {
+	f := newFakeADLS()
+	defer f.Close()
+	f.put("data/file.txt", []byte("my-data"))
+	tmp, err := ioutil.TempDir("", "adls")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	t.Run("AccountKey", func(t *testing.T) {
+		f.authorized = func(r *http.Request) bool {
+			return strings.HasPrefix(r.Header.Get("Authorization"), "SharedKey 127:") && r.Header.Get("x-ms-date") != ""
+		}
+		driver := &ArtifactDriver{AccountKey: base64.StdEncoding.EncodeToString([]byte("my-account-key"))}
+		assert.NoError(t, driver.Load(context.Background(), f.artifact("data/file.txt"), filepath.Join(tmp, "account-key")))
+		driver = &ArtifactDriver{AccountKey: "not base64"}
+		assert.Error(t, driver.Load(context.Background(), f.artifact("data/file.txt"), filepath.Join(tmp, "invalid")))
+	})
+	t.Run("WorkloadIdentity", func(t *testing.T) {
+		f.authorized = func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer "+testToken }
+		driver := &ArtifactDriver{UseWorkloadIdentity: true}
+		assert.Error(t, driver.Load(context.Background(), f.artifact("data/file.txt"), filepath.Join(tmp, "unconfigured")))
+		setWorkloadIdentity(t, f, tmp)
+		if assert.NoError(t, driver.Load(context.Background(), f.artifact("data/file.txt"), filepath.Join(tmp, "workload-identity"))) {
+			data, err := ioutil.ReadFile(filepath.Join(tmp, "workload-identity"))
+			assert.NoError(t, err)
+			assert.Equal(t, "my-data", string(data))
+		}
+	})
+	t.Run("Unauthorized", func(t *testing.T) {
+		f.authorized = func(*http.Request) bool { return false }
+		driver := &ArtifactDriver{SASToken: "sig=wrong"}
+		err := driver.Load(context.Background(), f.artifact("data/file.txt"), filepath.Join(tmp, "unauthorized"))
+		var e *apiError
+		if assert.True(t, errors.As(err, &e), err) {
+			assert.Equal(t, "AuthorizationFailure", e.Code)
+		}
+	})
+}
+
+// setWorkloadIdentity sets the environment variables Azure AD Workload Identity sets in pods, for the identity the
+// fake exchanges federated tokens of, until the test ends
+func setWorkloadIdentity(t *testing.T, f *fakeADLS, dir string) {
+	tokenFile := filepath.Join(dir, "token")
+	assert.NoError(t, ioutil.WriteFile(tokenFile, []byte("my-federated-token\n"), 0600))
+	for k, v := range map[string]string{"AZURE_CLIENT_ID": "my-client", "AZURE_TENANT_ID": "my-tenant", "AZURE_FEDERATED_TOKEN_FILE": tokenFile, "AZURE_AUTHORITY_HOST": f.URL + "/"} {
+		assert.NoError(t, os.Setenv(k, v))
+		k := k
+		t.Cleanup(func() { _ = os.Unsetenv(k) })
+	}
+}
+
+func TestArtifactDriver_UserDelegationSAS(t *testing.T) {
+	f := newFakeADLS()
+	defer f.Close()
+	f.put("data/file.txt", []byte("my-data"))
+	tmp, err := ioutil.TempDir("", "adls")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	setWorkloadIdentity(t, f, tmp)
+	// requests are only authorized by a SAS signed by the user delegation key, and not by the token it was requested with
+	var permissions []string
+	var sas url.Values
+	f.authorized = func(r *http.Request) bool {
+		sas = r.URL.Query()
+		permissions = append(permissions, sas.Get("sp"))
+		return r.Header.Get("Authorization") == "" && sas.Get("sig") == signUserDelegationSAS(sas, testSASResource, []byte(testDelegationKey))
+	}
+	driver := &ArtifactDriver{UseWorkloadIdentity: true, UserDelegationSASDuration: time.Hour}
+	operations := []struct {
+		name        string
+		run         func() error
+		permissions string
+	}{
+		{"Load", func() error {
+			return driver.Load(context.Background(), f.artifact("data/file.txt"), filepath.Join(tmp, "file.txt"))
+		}, "rl"},
+		{"Save", func() error {
+			return driver.Save(context.Background(), filepath.Join(tmp, "file.txt"), f.artifact("data/copy.txt"))
+		}, "rcwdm"},
+		{"Exists", func() error {
+			exists, err := driver.Exists(f.artifact("data/copy.txt"))
+			if err == nil && !exists {
+				err = errors.New("data/copy.txt does not exist")
+			}
+			return err
+		}, "r"},
+		{"Delete", func() error { return driver.Delete(f.artifact("data/copy.txt")) }, "d"},
+	}
+	for _, op := range operations {
+		t.Run(op.name, func(t *testing.T) {
+			permissions, f.delegationKeys = nil, nil
+			before := time.Now().UTC().Truncate(time.Second)
+			if !assert.NoError(t, op.run()) {
+				return
+			}
+			after := time.Now().UTC()
+			// each operation requests a key, and signs a SAS permitting only what it does with it
+			assert.Len(t, f.delegationKeys, 1)
+			if assert.NotEmpty(t, permissions) {
+				assert.Equal(t, op.permissions, permissions[0])
+				for _, p := range permissions {
+					assert.Equal(t, op.permissions, p)
+				}
+			}
+			start, err := time.Parse(sasTimeFormat, sas.Get("st"))
+			assert.NoError(t, err)
+			expiry, err := time.Parse(sasTimeFormat, sas.Get("se"))
+			assert.NoError(t, err)
+			assert.False(t, expiry.Before(before.Add(time.Hour)) || expiry.After(after.Add(time.Hour)), expiry)
+			assert.Equal(t, sasClockSkew+time.Hour, expiry.Sub(start))
+			assert.Equal(t, []string{sas.Get("st") + "/" + sas.Get("se")}, f.delegationKeys)
+			assert.Equal(t, "c", sas.Get("sr"))
+			assert.Equal(t, "https,http", sas.Get("spr"))
+			assert.Equal(t, "my-object-id", sas.Get("skoid"))
+		})
+	}
+	assert.Equal(t, "my-data", f.contents("data")["file.txt"])
+	assert.NotContains(t, f.contents("data"), "copy.txt")
+
+	t.Run("DelegationKeyFailed", func(t *testing.T) {
+		f.failDelegationKey = true
+		defer func() { f.failDelegationKey = false }()
+		err := driver.Load(context.Background(), f.artifact("data/file.txt"), filepath.Join(tmp, "failed"))
+		var e *apiError
+		if assert.True(t, errors.As(err, &e), err) {
+			assert.Equal(t, "AuthorizationPermissionMismatch", e.Code)
+		}
+	})
+}
+
+func TestSignUserDelegationSAS(t *testing.T) {
+	sas := url.Values{
+		"sv":    {"2021-06-08"},
+		"sr":    {"c"},
+		"sp":    {"rl"},
+		"st":    {"2006-01-02T15:00:00Z"},
+		"se":    {"2006-01-02T16:00:00Z"},
+		"spr":   {"https"},
+		"skoid": {"my-object-id"},
+		"sktid": {"my-tenant"},
+		"skt":   {"2006-01-02T15:00:00Z"},
+		"ske":   {"2006-01-02T16:00:00Z"},
+		"sks":   {"b"},
+		"skv":   {"2021-06-08"},
+	}
+	assert.Equal(t, "1YCQUWij9Y2412+0lQZ/SQDgp5yhlOMozjIvKfIqLKE=", signUserDelegationSAS(sas, "/blob/myaccount/my-fs", []byte(testDelegationKey)))
+}
+
+func TestSignSharedKey(t *testing.T) {
+	for _, tt := range []struct {
+		method    string
+		query     string
+		body      string
+		signature string
+	}{
+		{http.MethodPut, "resource=file", "", "rj2woKKBabLE5YUqMeICMg4eoCeiTAlW3+UbngWtDO8="},
+		{http.MethodPatch, "position=0&action=append", "hello", "XT4aFt29iVQ55hQogphvPcrc27IATnLh2xcdOKTJ3gA="},
+	} {
+		t.Run(tt.method, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, "https://myaccount.dfs.core.windows.net/my-fs/dir/my%20file.txt?"+tt.query, strings.NewReader(tt.body))
+			if !assert.NoError(t, err) {
+				return
+			}
+			req.Header.Set("x-ms-version", apiVersion)
+			req.Header.Set("x-ms-date", "Mon, 02 Jan 2006 15:04:05 GMT")
+			assert.Equal(t, tt.signature, signSharedKey(req, "myaccount", []byte("my-account-key")))
+		})
+	}
+}
+
+func TestValidateArtifact(t *testing.T) {
+	valid := wfv1.ADLSArtifact{Endpoint: "https://myaccount.dfs.core.windows.net", Filesystem: testFilesystem, Path: "data/out", UseWorkloadIdentity: true}
+	assert.NoError(t, ValidateArtifact("my-art", &valid))
+	assert.NoError(t, ValidateArtifact("my-art", &wfv1.ADLSArtifact{Endpoint: valid.Endpoint, Filesystem: testFilesystem, Path: "data/out", UseWorkloadIdentity: true, UserDelegationSASDuration: "168h"}))
+	for name, mutate := range map[string]func(a *wfv1.ADLSArtifact){
+		"Endpoint":   func(a *wfv1.ADLSArtifact) { a.Endpoint = "myaccount" },
+		"Filesystem": func(a *wfv1.ADLSArtifact) { a.Filesystem = "" },
+		"Path":       func(a *wfv1.ADLSArtifact) { a.Path = "/" },
+		"NoAuth":     func(a *wfv1.ADLSArtifact) { a.UseWorkloadIdentity = false },
+		"TwoAuths":   func(a *wfv1.ADLSArtifact) { a.SASTokenSecret = &apiv1.SecretKeySelector{} },
+		"UserDelegationSASWithoutWorkloadIdentity": func(a *wfv1.ADLSArtifact) {
+			a.UseWorkloadIdentity, a.AccountKeySecret, a.UserDelegationSASDuration = false, &apiv1.SecretKeySelector{}, "1h"
+		},
+		"UserDelegationSASDuration":      func(a *wfv1.ADLSArtifact) { a.UserDelegationSASDuration = "1 hour" },
+		"ShortUserDelegationSASDuration": func(a *wfv1.ADLSArtifact) { a.UserDelegationSASDuration = "30s" },
+		"LongUserDelegationSASDuration":  func(a *wfv1.ADLSArtifact) { a.UserDelegationSASDuration = "169h" },
+	} {
+		t.Run(name, func(t *testing.T) {
+			art := valid.DeepCopy()
+			mutate(art)
+			assert.Error(t, ValidateArtifact("my-art", art))
+		})
+	}
+}
@@ -0,0 +1,486 @@
+package adls
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const (
+	// apiVersion is the version of the Data Lake Storage API requests are made with
+	apiVersion = "2021-06-08"
+	// storageScope is the scope of the Azure AD tokens of storage accounts
+	storageScope         = "https://storage.azure.com/.default"
+	defaultAuthorityHost = "https://login.microsoftonline.com/"
+	// the headers the type of a path is returned in, and the source of a rename is sent in
+	resourceTypeHeader = "x-ms-resource-type"
+	renameSourceHeader = "x-ms-rename-source"
+	continuationHeader = "x-ms-continuation"
+	// sasTimeFormat is the format of the times of shared access signatures and user delegation keys
+	sasTimeFormat = "2006-01-02T15:04:05Z"
+	// sasClockSkew is how long before it is requested a user delegation SAS starts, for the skew of clocks
+	sasClockSkew = 5 * time.Minute
+)
+
+// uploadChunkSize is the size of the chunks files are appended in
+var uploadChunkSize int64 = 8 * 1024 * 1024
+
+// apiError is a Data Lake Storage response with an error status, whose code is e.g. "PathNotFound"
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *apiError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s (%d)", e.Code, e.Status)
+	}
+	return fmt.Sprintf("%s: %s (%d)", e.Code, e.Message, e.Status)
+}
+
+// client is a client of the paths of a file system of the Data Lake Storage API, authorized with the key of the
+// storage account, a shared access signature, or an Azure AD token
+type client struct {
+	ctx        context.Context
+	httpClient *http.Client
+	endpoint   *url.URL
+	filesystem string
+	account    string
+	accountKey []byte
+	sasToken   url.Values
+	token      string
+	now        func() time.Time
+}
+
+// pathInfo is what the properties of a path say of it
+type pathInfo struct {
+	isDirectory bool
+	size        int64
+}
+
+// pathEntry is a path listed under a directory, whose name is its path in the file system
+type pathEntry struct {
+	Name          string          `json:"name"`
+	IsDirectory   json.RawMessage `json:"isDirectory"`
+	ContentLength json.Number     `json:"contentLength"`
+}
+
+// isDir returns whether the entry is a directory, which it is listed as with either a boolean or a string
+func (e pathEntry) isDir() bool {
+	return strings.Trim(string(e.IsDirectory), `"`) == "true"
+}
+
+// workloadIdentityToken exchanges the federated token of the pod, which Azure AD Workload Identity projects into it,
+// along with the environment variables of its identity, for an Azure AD token of storage accounts
+func workloadIdentityToken(ctx context.Context, httpClient *http.Client) (string, error) {
+	clientID, tenantID, tokenFile := os.Getenv("AZURE_CLIENT_ID"), os.Getenv("AZURE_TENANT_ID"), os.Getenv("AZURE_FEDERATED_TOKEN_FILE")
+	if clientID == "" || tenantID == "" || tokenFile == "" {
+		return "", fmt.Errorf("ADLS workload identity requires AZURE_CLIENT_ID, AZURE_TENANT_ID and AZURE_FEDERATED_TOKEN_FILE, which Azure AD Workload Identity sets in the pods of service accounts it is enabled for")
+	}
+	authorityHost := os.Getenv("AZURE_AUTHORITY_HOST")
+	if authorityHost == "" {
+		authorityHost = defaultAuthorityHost
+	}
+	assertion, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"client_id":             {clientID},
+		"scope":                 {storageScope},
+		"grant_type":            {"client_credentials"},
+		"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+		"client_assertion":      {strings.TrimSpace(string(assertion))},
+	}
+	tokenURL := strings.TrimSuffix(authorityHost, "/") + "/" + url.PathEscape(tenantID) + "/oauth2/v2.0/token"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ADLS workload identity token exchange failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var body struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	_ = json.Unmarshal(data, &body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || body.AccessToken == "" {
+		return "", fmt.Errorf("ADLS workload identity token exchange failed: %s %s (%d)", body.Error, body.ErrorDescription, resp.StatusCode)
+	}
+	return body.AccessToken, nil
+}
+
+// userDelegationKey is a key the service signs user delegation SASs of an Azure AD identity with, which is valid for
+// as long as they are
+type userDelegationKey struct {
+	SignedOid     string `xml:"SignedOid"`
+	SignedTid     string `xml:"SignedTid"`
+	SignedStart   string `xml:"SignedStart"`
+	SignedExpiry  string `xml:"SignedExpiry"`
+	SignedService string `xml:"SignedService"`
+	SignedVersion string `xml:"SignedVersion"`
+	Value         string `xml:"Value"`
+}
+
+// blobEndpoint returns the Blob Storage endpoint of the storage account of a Data Lake Storage endpoint, which user
+// delegation keys are requested of. An endpoint which is not that of Azure, e.g. of an emulator, serves both.
+func blobEndpoint(endpoint *url.URL) *url.URL {
+	u := *endpoint
+	u.Host = strings.Replace(u.Host, ".dfs.", ".blob.", 1)
+	u.Path = strings.TrimSuffix(path.Join("/", u.Path), "/") + "/"
+	u.RawPath = ""
+	return &u
+}
+
+// userDelegationSAS requests a user delegation key with the Azure AD token of the client, and returns a shared access
+// signature of the file system signed by it, which has the permissions, e.g. "rl", and is valid for the duration
+func (c *client) userDelegationSAS(permissions string, duration time.Duration) (url.Values, error) {
+	now := c.now().UTC().Truncate(time.Second)
+	start, expiry := now.Add(-sasClockSkew).Format(sasTimeFormat), now.Add(duration).Format(sasTimeFormat)
+	key, err := c.userDelegationKey(start, expiry)
+	if err != nil {
+		return nil, fmt.Errorf("ADLS request of a user delegation key failed: %w", err)
+	}
+	keyValue, err := base64.StdEncoding.DecodeString(key.Value)
+	if err != nil {
+		return nil, fmt.Errorf("ADLS user delegation key is not base64 encoded: %w", err)
+	}
+	protocol := "https"
+	if c.endpoint.Scheme != "https" {
+		protocol = "https,http"
+	}
+	sas := url.Values{
+		"sv":    {apiVersion},
+		"sr":    {"c"},
+		"sp":    {permissions},
+		"st":    {start},
+		"se":    {expiry},
+		"spr":   {protocol},
+		"skoid": {key.SignedOid},
+		"sktid": {key.SignedTid},
+		"skt":   {key.SignedStart},
+		"ske":   {key.SignedExpiry},
+		"sks":   {key.SignedService},
+		"skv":   {key.SignedVersion},
+	}
+	sas.Set("sig", signUserDelegationSAS(sas, "/blob/"+c.account+"/"+c.filesystem, keyValue))
+	return sas, nil
+}
+
+// userDelegationKey requests a user delegation key, which is valid from the start until the expiry, of the Blob
+// Storage API
+func (c *client) userDelegationKey(start, expiry string) (*userDelegationKey, error) {
+	u := blobEndpoint(c.endpoint)
+	u.RawQuery = url.Values{"restype": {"service"}, "comp": {"userdelegationkey"}}.Encode()
+	body := fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?><KeyInfo><Start>%s</Start><Expiry>%s</Expiry></KeyInfo>`, start, expiry)
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, u.String(), strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/xml")
+	req.Header.Set("x-ms-version", apiVersion)
+	req.Header.Set("x-ms-date", c.now().UTC().Format(http.TimeFormat))
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, decodeError(resp)
+	}
+	key := &userDelegationKey{}
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// signUserDelegationSAS returns the signature of a user delegation SAS of the canonicalized resource, e.g.
+// "/blob/myaccount/my-fs", which is the HMAC-SHA256, with the value of the user delegation key, of its fields
+func signUserDelegationSAS(sas url.Values, resource string, key []byte) string {
+	fields := []string{sas.Get("sp"), sas.Get("st"), sas.Get("se"), resource}
+	for _, name := range []string{"skoid", "sktid", "skt", "ske", "sks", "skv", "saoid", "suoid", "scid", "sip", "spr", "sv", "sr", "snapshot", "ses", "rscc", "rscd", "rsce", "rscl", "rsct"} {
+		fields = append(fields, sas.Get(name))
+	}
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(strings.Join(fields, "\n")))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// pathURL returns the URL of a path of the file system, or of the file system itself if it is empty
+func (c *client) pathURL(p string, query url.Values) *url.URL {
+	u := *c.endpoint
+	u.Path = path.Join("/", c.endpoint.Path, c.filesystem, p)
+	u.RawPath = ""
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	for k, v := range c.sasToken {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+	return &u
+}
+
+// do sends a request of the path, which is authorized as the client is, and returns its response, or the error of
+// it. A 404 response is a not found error.
+func (c *client) do(method, p string, query url.Values, header http.Header, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(c.ctx, method, c.pathURL(p, query).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.ContentLength = size
+	if body == nil {
+		req.ContentLength = 0
+	}
+	req.Header.Set("x-ms-version", apiVersion)
+	req.Header.Set("x-ms-date", c.now().UTC().Format(http.TimeFormat))
+	switch {
+	case c.token != "":
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	case c.accountKey != nil:
+		req.Header.Set("Authorization", "SharedKey "+c.account+":"+signSharedKey(req, c.account, c.accountKey))
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer func() { _ = resp.Body.Close() }()
+		return nil, decodeError(resp)
+	}
+	return resp, nil
+}
+
+// call sends a request as do does, and discards the body of its response, returning its headers
+func (c *client) call(method, p string, query url.Values, header http.Header) (http.Header, error) {
+	resp, err := c.do(method, p, query, header, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return resp.Header, nil
+}
+
+// decodeError returns the error of a response, which has a JSON body, or an XML one if it is of the Blob Storage API,
+// unless it is that of a HEAD request, whose code is only in a header
+func decodeError(resp *http.Response) error {
+	e := &apiError{Status: resp.StatusCode, Code: resp.Header.Get("x-ms-error-code")}
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var blobBody struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Error.Code != "" {
+		e.Code, e.Message = body.Error.Code, body.Error.Message
+	} else if xml.Unmarshal(data, &blobBody) == nil && blobBody.Code != "" {
+		e.Code, e.Message = blobBody.Code, blobBody.Message
+	}
+	if e.Code == "" {
+		e.Code = http.StatusText(resp.StatusCode)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return common.NewNotFoundError(e)
+	}
+	return e
+}
+
+// signSharedKey returns the Shared Key signature of a request, which is the HMAC-SHA256, with the account key, of
+// its method, standard headers, x-ms- headers, and resource
+func signSharedKey(req *http.Request, account string, key []byte) string {
+	contentLength := ""
+	if req.ContentLength > 0 {
+		contentLength = strconv.FormatInt(req.ContentLength, 10)
+	}
+	lines := []string{req.Method}
+	for _, h := range []string{"Content-Encoding", "Content-Language"} {
+		lines = append(lines, req.Header.Get(h))
+	}
+	lines = append(lines, contentLength)
+	for _, h := range []string{"Content-MD5", "Content-Type", "Date", "If-Modified-Since", "If-Match", "If-None-Match", "If-Unmodified-Since", "Range"} {
+		lines = append(lines, req.Header.Get(h))
+	}
+	var msHeaders []string
+	for k, v := range req.Header {
+		if k = strings.ToLower(k); strings.HasPrefix(k, "x-ms-") {
+			msHeaders = append(msHeaders, k+":"+strings.TrimSpace(strings.Join(v, ",")))
+		}
+	}
+	sort.Strings(msHeaders)
+	resource := "/" + account + req.URL.EscapedPath()
+	query := req.URL.Query()
+	names := make([]string, 0, len(query))
+	values := map[string][]string{}
+	for k, v := range query {
+		name := strings.ToLower(k)
+		if _, ok := values[name]; !ok {
+			names = append(names, name)
+		}
+		values[name] = append(values[name], v...)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v := values[name]
+		sort.Strings(v)
+		resource += "\n" + name + ":" + strings.Join(v, ",")
+	}
+	stringToSign := strings.Join(lines, "\n") + "\n" + strings.Join(msHeaders, "\n") + "\n" + resource
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// getProperties returns whether the path is a file or a directory, and its size if it is a file
+func (c *client) getProperties(p string) (pathInfo, error) {
+	header, err := c.call(http.MethodHead, p, nil, nil)
+	if err != nil {
+		return pathInfo{}, err
+	}
+	size, _ := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	return pathInfo{isDirectory: header.Get(resourceTypeHeader) == "directory", size: size}, nil
+}
+
+// list lists every file and directory under the directory, recursively
+func (c *client) list(dir string) ([]pathEntry, error) {
+	var entries []pathEntry
+	query := url.Values{"resource": {"filesystem"}, "directory": {dir}, "recursive": {"true"}}
+	for {
+		resp, err := c.do(http.MethodGet, "", query, nil, nil, 0)
+		if err != nil {
+			return nil, fmt.Errorf("ADLS list of %s failed: %w", dir, err)
+		}
+		var body struct {
+			Paths []pathEntry `json:"paths"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("ADLS list of %s failed: %w", dir, err)
+		}
+		entries = append(entries, body.Paths...)
+		continuation := resp.Header.Get(continuationHeader)
+		if continuation == "" {
+			return entries, nil
+		}
+		query.Set("continuation", continuation)
+	}
+}
+
+// read downloads the file to the local path
+func (c *client) read(p, localPath string) error {
+	resp, err := c.do(http.MethodGet, p, nil, nil, nil, 0)
+	if err != nil {
+		return fmt.Errorf("ADLS read of %s failed: %w", p, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	out, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = out.Close() }()
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		return fmt.Errorf("ADLS read of %s failed: %w", p, err)
+	}
+	return out.Close()
+}
+
+// createDirectory creates the directory, and any parent directory of it which does not exist
+func (c *client) createDirectory(p string) error {
+	if _, err := c.call(http.MethodPut, p, url.Values{"resource": {"directory"}}, nil); err != nil {
+		return fmt.Errorf("ADLS create of directory %s failed: %w", p, err)
+	}
+	return nil
+}
+
+// upload creates the file, replacing any file at the path, and appends the content of the local file to it in
+// chunks, which is only committed, and so visible, once it is flushed
+func (c *client) upload(p string, f *os.File, size int64) error {
+	if _, err := c.call(http.MethodPut, p, url.Values{"resource": {"file"}}, nil); err != nil {
+		return fmt.Errorf("ADLS create of file %s failed: %w", p, err)
+	}
+	for offset := int64(0); offset < size; offset += uploadChunkSize {
+		n := uploadChunkSize
+		if size-offset < n {
+			n = size - offset
+		}
+		query := url.Values{"action": {"append"}, "position": {strconv.FormatInt(offset, 10)}}
+		resp, err := c.do(http.MethodPatch, p, query, nil, io.NewSectionReader(f, offset, n), n)
+		if err != nil {
+			return fmt.Errorf("ADLS append to %s at %d failed: %w", p, offset, err)
+		}
+		_ = resp.Body.Close()
+	}
+	query := url.Values{"action": {"flush"}, "position": {strconv.FormatInt(size, 10)}}
+	if _, err := c.call(http.MethodPatch, p, query, nil); err != nil {
+		return fmt.Errorf("ADLS flush of %s failed: %w", p, err)
+	}
+	return nil
+}
+
+// rename renames the file or directory at the source path to the destination path, which is atomic, replacing any
+// file at it
+func (c *client) rename(src, dst string) error {
+	source := (&url.URL{Path: path.Join("/", c.filesystem, src)}).EscapedPath()
+	if len(c.sasToken) > 0 {
+		source += "?" + c.sasToken.Encode()
+	}
+	header := http.Header{}
+	header.Set(renameSourceHeader, source)
+	if _, err := c.call(http.MethodPut, dst, url.Values{"mode": {"legacy"}}, header); err != nil {
+		return fmt.Errorf("ADLS rename of %s to %s failed: %w", src, dst, err)
+	}
+	return nil
+}
+
+// delete deletes the file, or the directory and everything under it, which is atomic in an account with a
+// hierarchical namespace. A deletion the service continues over several requests, as it may do otherwise, is followed
+// to its end.
+func (c *client) delete(p string) error {
+	query := url.Values{"recursive": {"true"}}
+	for {
+		header, err := c.call(http.MethodDelete, p, query, nil)
+		if err != nil {
+			return err
+		}
+		continuation := header.Get(continuationHeader)
+		if continuation == "" {
+			return nil
+		}
+		query.Set("continuation", continuation)
+	}
+}
@@ -1,21 +1,117 @@
 package artifactory
 
 import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 
 	"github.com/argoproj/argo-workflows/v3/errors"
 	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
 )
 
 type ArtifactoryArtifactDriver struct {
 	Username string
 	Password string
+	// APIKey is sent in an X-JFrog-Art-Api header, if set, in place of Username and Password
+	APIKey string
+	// AccessToken is sent in an "Authorization: Bearer" header, if set, in place of APIKey, or Username and Password
+	AccessToken string
+	// VerifyChecksum verifies loaded files against the checksums Artifactory returns, and sends those of saved files
+	VerifyChecksum bool
+	// ProxyURL is the proxy requests are sent through, if set, rather than that of the environment
+	ProxyURL *url.URL
+	// URL is that of the artifact the driver was created for, which HealthCheck checks. Loads and saves use the URL of
+	// the artifact they are given.
+	URL string
+	common.Transporting
+}
+
+func (a *ArtifactoryArtifactDriver) client() *http.Client {
+	if a.ProxyURL == nil {
+		return &http.Client{Transport: a.RoundTripper(nil)}
+	}
+	return &http.Client{Transport: a.RoundTripper(common.NewTransport(nil, a.ProxyURL))}
+}
+
+// authenticate authenticates the request with the access token, the API key, or the username and password, in that
+// order of preference
+func (a *ArtifactoryArtifactDriver) authenticate(req *http.Request) {
+	switch {
+	case a.AccessToken != "":
+		req.Header.Set("Authorization", "Bearer "+a.AccessToken)
+	case a.APIKey != "":
+		req.Header.Set("X-JFrog-Art-Api", a.APIKey)
+	default:
+		req.SetBasicAuth(a.Username, a.Password)
+	}
+}
+
+// checksumHeaders are the headers of the checksums Artifactory returns for a file, and is sent for a deployed one, in
+// order of preference, and the hashes they are of
+var checksumHeaders = []struct {
+	name    string
+	newHash func() hash.Hash
+}{
+	{"X-Checksum-Sha256", sha256.New},
+	{"X-Checksum-Sha1", sha1.New},
+	{"X-Checksum-Md5", md5.New},
+}
+
+// verifyChecksum verifies the data read from r, which it writes to w, against the preferred checksum of the header
+func verifyChecksum(w io.Writer, r io.Reader, header http.Header) error {
+	for _, checksum := range checksumHeaders {
+		expected := header.Get(checksum.name)
+		if expected == "" {
+			continue
+		}
+		h := checksum.newHash()
+		if _, err := io.Copy(io.MultiWriter(w, h), r); err != nil {
+			return err
+		}
+		if actual := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(actual, expected) {
+			return errors.InternalErrorf("%s of the file loaded from artifactory does not match: expected %s, got %s", checksum.name, expected, actual)
+		}
+		return nil
+	}
+	return errors.InternalErrorf("artifactory did not return a checksum of the file to verify it against")
+}
+
+// setChecksumHeaders sets the headers of the checksums of the file f, which Artifactory verifies it against when it is
+// deployed, and seeks f back to its start
+func setChecksumHeaders(req *http.Request, f *os.File) error {
+	hashes := make([]hash.Hash, len(checksumHeaders))
+	writers := make([]io.Writer, len(checksumHeaders))
+	for i, checksum := range checksumHeaders {
+		hashes[i] = checksum.newHash()
+		writers[i] = hashes[i]
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), f); err != nil {
+		return err
+	}
+	for i, checksum := range checksumHeaders {
+		name := checksum.name
+		if name == "X-Checksum-Md5" {
+			// which Artifactory expects without the suffix when a file is deployed
+			name = "X-Checksum"
+		}
+		req.Header.Set(name, hex.EncodeToString(hashes[i].Sum(nil)))
+	}
+	_, err := f.Seek(0, io.SeekStart)
+	return err
 }
 
 // Download artifact from an artifactory URL
-func (a *ArtifactoryArtifactDriver) Load(artifact *wfv1.Artifact, path string) error {
+func (a *ArtifactoryArtifactDriver) Load(ctx context.Context, artifact *wfv1.Artifact, path string) error {
 	lf, err := os.Create(path)
 	if err != nil {
 		return err
@@ -24,12 +120,12 @@ func (a *ArtifactoryArtifactDriver) Load(artifact *wfv1.Artifact, path string) e
 		_ = lf.Close()
 	}()
 
-	req, err := http.NewRequest(http.MethodGet, artifact.Artifactory.URL, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, artifact.Artifactory.URL, nil)
 	if err != nil {
 		return err
 	}
-	req.SetBasicAuth(a.Username, a.Password)
-	res, err := (&http.Client{}).Do(req)
+	a.authenticate(req)
+	res, err := a.client().Do(req)
 	if err != nil {
 		return err
 	}
@@ -37,29 +133,90 @@ func (a *ArtifactoryArtifactDriver) Load(artifact *wfv1.Artifact, path string) e
 		_ = res.Body.Close()
 	}()
 	if res.StatusCode == 404 {
-		return errors.New(errors.CodeNotFound, res.Status)
+		return common.NewNotFoundError(fmt.Errorf("loading file from artifactory failed with reason:%s", res.Status))
 	}
 	if res.StatusCode < 200 || res.StatusCode >= 300 {
 		return errors.InternalErrorf("loading file from artifactory failed with reason:%s", res.Status)
 	}
 
-	_, err = io.Copy(lf, res.Body)
+	if !a.VerifyChecksum {
+		_, err = io.Copy(lf, res.Body)
+		return err
+	}
+	if err := verifyChecksum(lf, res.Body, res.Header); err != nil {
+		// so that what does not match is not used in place of the artifact
+		_ = lf.Close()
+		_ = os.Remove(path)
+		return err
+	}
+	return nil
+}
 
-	return err
+// Exists sends a HEAD request to an artifactory URL
+func (a *ArtifactoryArtifactDriver) Exists(artifact *wfv1.Artifact) (bool, error) {
+	req, err := http.NewRequest(http.MethodHead, artifact.Artifactory.URL, nil)
+	if err != nil {
+		return false, err
+	}
+	a.authenticate(req)
+	res, err := a.client().Do(req)
+	if err != nil {
+		return false, err
+	}
+	_ = res.Body.Close()
+	if res.StatusCode == 404 {
+		return false, nil
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return false, errors.InternalErrorf("checking file exists in artifactory failed with reason:%s", res.Status)
+	}
+	return true, nil
+}
+
+// HealthCheck sends a HEAD request to the URL of the driver, so that Artifactory authenticates it without returning
+// the file. A file which does not exist yet, e.g. that of an output artifact, is healthy.
+func (a *ArtifactoryArtifactDriver) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, a.URL, nil)
+	if err != nil {
+		return err
+	}
+	a.authenticate(req)
+	res, err := a.client().Do(req)
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusUnauthorized:
+		return errors.Errorf(errors.CodeUnauthorized, "artifactory did not accept the credentials: %s", res.Status)
+	case res.StatusCode == http.StatusForbidden:
+		return errors.Errorf(errors.CodeForbidden, "artifactory denied access to %s: %s", a.URL, res.Status)
+	case res.StatusCode == http.StatusNotFound, res.StatusCode >= 200 && res.StatusCode < 300:
+		return nil
+	}
+	return errors.InternalErrorf("checking the health of artifactory failed with reason:%s", res.Status)
 }
 
 // UpLoad artifact to an artifactory URL
-func (a *ArtifactoryArtifactDriver) Save(path string, artifact *wfv1.Artifact) error {
+func (a *ArtifactoryArtifactDriver) Save(ctx context.Context, path string, artifact *wfv1.Artifact) error {
 	f, err := os.Open(path)
 	if err != nil {
 		return err
 	}
-	req, err := http.NewRequest(http.MethodPut, artifact.Artifactory.URL, f)
+	defer func() {
+		_ = f.Close()
+	}()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, artifact.Artifactory.URL, f)
 	if err != nil {
 		return err
 	}
-	req.SetBasicAuth(a.Username, a.Password)
-	res, err := (&http.Client{}).Do(req)
+	if a.VerifyChecksum {
+		if err := setChecksumHeaders(req, f); err != nil {
+			return err
+		}
+	}
+	a.authenticate(req)
+	res, err := a.client().Do(req)
 	if err != nil {
 		return err
 	}
@@ -71,3 +228,35 @@ func (a *ArtifactoryArtifactDriver) Save(path string, artifact *wfv1.Artifact) e
 	}
 	return nil
 }
+
+// Delete artifact from an artifactory URL
+func (a *ArtifactoryArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	req, err := http.NewRequest(http.MethodDelete, artifact.Artifactory.URL, nil)
+	if err != nil {
+		return err
+	}
+	a.authenticate(req)
+	res, err := a.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	if res.StatusCode == 404 {
+		return nil
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.InternalErrorf("deleting file from artifactory failed with reason:%s", res.Status)
+	}
+	return nil
+}
+
+// Capabilities returns the optional operations on Artifactory artifacts the driver supports
+func (a *ArtifactoryArtifactDriver) Capabilities() common.DriverCapabilities {
+	return common.DriverCapabilities{
+		Delete:      true,
+		Exists:      true,
+		HealthCheck: true,
+	}
+}
@@ -1,7 +1,18 @@
 package artifactory
 
 import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -13,7 +24,7 @@ import (
 func TestArtifactoryArtifactDriver_Load(t *testing.T) {
 	driver := &ArtifactoryArtifactDriver{}
 	t.Run("NotFound", func(t *testing.T) {
-		err := driver.Load(&wfv1.Artifact{
+		err := driver.Load(context.Background(), &wfv1.Artifact{
 			ArtifactLocation: wfv1.ArtifactLocation{
 				Artifactory: &wfv1.ArtifactoryArtifact{URL: "https://github.com/argoproj/argo-workflows/not-found"},
 			},
@@ -26,7 +37,7 @@ func TestArtifactoryArtifactDriver_Load(t *testing.T) {
 		}
 	})
 	t.Run("Found", func(t *testing.T) {
-		err := driver.Load(&wfv1.Artifact{
+		err := driver.Load(context.Background(), &wfv1.Artifact{
 			ArtifactLocation: wfv1.ArtifactLocation{
 				Artifactory: &wfv1.ArtifactoryArtifact{URL: "https://github.com/argoproj/argo-workflows"},
 			},
@@ -37,3 +48,194 @@ func TestArtifactoryArtifactDriver_Load(t *testing.T) {
 		}
 	})
 }
+
+func TestArtifactoryArtifactDriver_Exists(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodHead, r.Method)
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "my-username" || password != "my-password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/present" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	driver := &ArtifactoryArtifactDriver{Username: "my-username", Password: "my-password"}
+	newArtifact := func(path string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{Artifactory: &wfv1.ArtifactoryArtifact{URL: server.URL + path}}}
+	}
+	t.Run("Present", func(t *testing.T) {
+		ok, err := driver.Exists(newArtifact("/present"))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+	t.Run("Absent", func(t *testing.T) {
+		ok, err := driver.Exists(newArtifact("/not-found"))
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+	t.Run("Unauthorized", func(t *testing.T) {
+		_, err := (&ArtifactoryArtifactDriver{}).Exists(newArtifact("/present"))
+		assert.Error(t, err)
+	})
+}
+
+func TestArtifactoryArtifactDriver_HealthCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodHead, r.Method)
+		switch r.Header.Get("Authorization") {
+		case "Bearer my-token":
+		case "Bearer read-only":
+			w.WriteHeader(http.StatusForbidden)
+			return
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/present" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	t.Run("Healthy", func(t *testing.T) {
+		assert.NoError(t, (&ArtifactoryArtifactDriver{AccessToken: "my-token", URL: server.URL + "/present"}).HealthCheck(ctx))
+		// the file of an output artifact is not saved yet
+		assert.NoError(t, (&ArtifactoryArtifactDriver{AccessToken: "my-token", URL: server.URL + "/not-found"}).HealthCheck(ctx))
+	})
+	t.Run("Unauthorized", func(t *testing.T) {
+		err := (&ArtifactoryArtifactDriver{AccessToken: "other-token", URL: server.URL + "/present"}).HealthCheck(ctx)
+		assert.True(t, errors.IsCode(errors.CodeUnauthorized, err))
+		err = (&ArtifactoryArtifactDriver{AccessToken: "read-only", URL: server.URL + "/present"}).HealthCheck(ctx)
+		assert.True(t, errors.IsCode(errors.CodeForbidden, err))
+	})
+}
+
+func TestArtifactoryArtifactDriver_Cancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Fail(t, "unexpected request")
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	driver := &ArtifactoryArtifactDriver{}
+	art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{Artifactory: &wfv1.ArtifactoryArtifact{URL: server.URL}}}
+	assert.Error(t, driver.Load(ctx, art, "/tmp/cancelled"))
+	assert.Error(t, driver.Save(ctx, "/dev/null", art))
+}
+
+func TestArtifactoryArtifactDriver_ProxyURL(t *testing.T) {
+	var hosts []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.URL.Host)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	assert.NoError(t, err)
+	driver := &ArtifactoryArtifactDriver{ProxyURL: proxyURL}
+	ok, err := driver.Exists(&wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{Artifactory: &wfv1.ArtifactoryArtifact{URL: "http://artifactory.example.com/my-file"}}})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"artifactory.example.com"}, hosts)
+}
+
+func TestArtifactoryArtifactDriver_Auth(t *testing.T) {
+	var authorization, apiKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization, apiKey = r.Header.Get("Authorization"), r.Header.Get("X-JFrog-Art-Api")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{Artifactory: &wfv1.ArtifactoryArtifact{URL: server.URL + "/my-file"}}}
+	for name, tt := range map[string]struct {
+		driver                ArtifactoryArtifactDriver
+		authorization, apiKey string
+	}{
+		"Basic":       {ArtifactoryArtifactDriver{Username: "my-username", Password: "my-password"}, "Basic bXktdXNlcm5hbWU6bXktcGFzc3dvcmQ=", ""},
+		"APIKey":      {ArtifactoryArtifactDriver{Username: "my-username", Password: "my-password", APIKey: "my-api-key"}, "", "my-api-key"},
+		"AccessToken": {ArtifactoryArtifactDriver{Username: "my-username", Password: "my-password", APIKey: "my-api-key", AccessToken: "my-token"}, "Bearer my-token", ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := tt.driver.Exists(art)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.authorization, authorization)
+			assert.Equal(t, tt.apiKey, apiKey)
+			assert.NoError(t, tt.driver.Save(context.Background(), "/dev/null", art))
+			assert.Equal(t, tt.authorization, authorization)
+			assert.Equal(t, tt.apiKey, apiKey)
+		})
+	}
+}
+
+func TestArtifactoryArtifactDriver_VerifyChecksum(t *testing.T) {
+	data := "my-data"
+	sha256Sum := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+	sha1Sum := fmt.Sprintf("%x", sha1.Sum([]byte(data)))
+	var deployed http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			deployed = r.Header
+			return
+		}
+		switch r.URL.Path {
+		case "/sha256":
+			w.Header().Set("X-Checksum-Sha256", sha256Sum)
+			w.Header().Set("X-Checksum-Sha1", "wrong")
+		case "/sha1":
+			w.Header().Set("X-Checksum-Sha1", strings.ToUpper(sha1Sum))
+		case "/mismatch":
+			w.Header().Set("X-Checksum-Sha256", sha1Sum)
+		}
+		_, _ = fmt.Fprint(w, data)
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "artifactory")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	driver := &ArtifactoryArtifactDriver{VerifyChecksum: true}
+	newArtifact := func(path string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{Artifactory: &wfv1.ArtifactoryArtifact{URL: server.URL + path}}}
+	}
+
+	t.Run("Verified", func(t *testing.T) {
+		for _, p := range []string{"/sha256", "/sha1"} {
+			path := filepath.Join(dir, "verified")
+			if assert.NoError(t, driver.Load(context.Background(), newArtifact(p), path), p) {
+				loaded, err := ioutil.ReadFile(path)
+				assert.NoError(t, err)
+				assert.Equal(t, data, string(loaded))
+			}
+		}
+	})
+	t.Run("Mismatch", func(t *testing.T) {
+		path := filepath.Join(dir, "mismatch")
+		err := driver.Load(context.Background(), newArtifact("/mismatch"), path)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "X-Checksum-Sha256 of the file loaded from artifactory does not match")
+		}
+		// what does not match was removed
+		_, err = os.Stat(path)
+		assert.True(t, os.IsNotExist(err))
+	})
+	t.Run("Missing", func(t *testing.T) {
+		assert.Error(t, driver.Load(context.Background(), newArtifact("/missing"), filepath.Join(dir, "missing")))
+		// which is not verified unless asked to be
+		assert.NoError(t, (&ArtifactoryArtifactDriver{}).Load(context.Background(), newArtifact("/missing"), filepath.Join(dir, "missing")))
+	})
+	t.Run("Deployed", func(t *testing.T) {
+		path := filepath.Join(dir, "deployed")
+		assert.NoError(t, ioutil.WriteFile(path, []byte(data), 0600))
+		if assert.NoError(t, driver.Save(context.Background(), path, newArtifact("/deployed"))) {
+			assert.Equal(t, sha256Sum, deployed.Get("X-Checksum-Sha256"))
+			assert.Equal(t, sha1Sum, deployed.Get("X-Checksum-Sha1"))
+			assert.Equal(t, fmt.Sprintf("%x", md5.Sum([]byte(data))), deployed.Get("X-Checksum"))
+		}
+	})
+}
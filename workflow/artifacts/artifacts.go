@@ -2,155 +2,893 @@ package executor
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
 
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
 	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
-	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/artifactory"
-	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/gcs"
-	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/git"
-	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/hdfs"
-	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/http"
-	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/oss"
-	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/raw"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/cache"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/encryption"
 	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/resource"
-	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/s3"
 )
 
 // ArtifactDriver is the interface for loading and saving of artifacts
 type ArtifactDriver interface {
-	// Load accepts an artifact source URL and places it at specified path
-	Load(inputArtifact *wfv1.Artifact, path string) error
+	// Load accepts an artifact source URL and places it at specified path. Cancelling the context aborts the download.
+	Load(ctx context.Context, inputArtifact *wfv1.Artifact, path string) error
+
+	// Save uploads the path to artifact destination. Cancelling the context aborts the upload.
+	Save(ctx context.Context, path string, outputArtifact *wfv1.Artifact) error
+
+	// Delete removes the artifact from its storage. Drivers that cannot delete return common.ErrDeletionNotSupported
+	Delete(artifact *wfv1.Artifact) error
+}
+
+// ArtifactStreamer is implemented by drivers that can open an artifact for reading without first
+// writing it to disk
+type ArtifactStreamer interface {
+	// OpenStream opens the artifact for reading. The caller must close the returned reader.
+	OpenStream(artifact *wfv1.Artifact) (io.ReadCloser, error)
+}
+
+// RangeLoader is implemented by drivers that can load a range of the bytes of an artifact, e.g. the footer of a
+// Parquet file, without downloading all of it
+type RangeLoader interface {
+	// LoadRange loads length bytes of the artifact, which must be a file, from the offset start to path, or every
+	// byte from start if length is negative. Fewer bytes are loaded if the artifact ends before the range does, but
+	// it is an error if it ends before start.
+	LoadRange(artifact *wfv1.Artifact, start, length int64, path string) error
+}
+
+// ChecksumVerifier is implemented by drivers that can verify the checksum of a loaded artifact using
+// metadata from its storage, rather than hashing the loaded bytes
+type ChecksumVerifier interface {
+	// VerifyChecksum verifies that the artifact loaded at path matches artifact.Checksum
+	VerifyChecksum(artifact *wfv1.Artifact, path string) error
+}
+
+// ArtifactExistenceChecker is implemented by drivers that can check whether an artifact exists without
+// downloading it
+type ArtifactExistenceChecker interface {
+	// Exists returns false, and no error, if the artifact does not exist. Any other failure to find out,
+	// such as an authentication or network error, is returned as an error.
+	Exists(artifact *wfv1.Artifact) (bool, error)
+}
+
+// BatchExistenceChecker is implemented by drivers that can check whether many artifacts exist in fewer
+// requests than checking each one
+type BatchExistenceChecker interface {
+	// ExistsBatch returns whether each of the artifacts, which are in the storage of the driver, exists, as
+	// Exists does
+	ExistsBatch(artifacts []*wfv1.Artifact) ([]bool, error)
+}
 
-	// Save uploads the path to artifact destination
-	Save(path string, outputArtifact *wfv1.Artifact) error
+// HealthChecker is implemented by drivers that can check that the storage of the artifact they were created for is
+// reachable with their credentials, e.g. before many steps save to it
+type HealthChecker interface {
+	// HealthCheck returns an error if the storage cannot be reached, or the credentials are not accepted. No artifact
+	// is loaded or saved.
+	HealthCheck(ctx context.Context) error
+}
+
+// ArtifactProgressReporter is implemented by drivers that can report the progress of transfers
+type ArtifactProgressReporter interface {
+	// LoadWithProgress is Load, calling progress as the artifact is downloaded
+	LoadWithProgress(ctx context.Context, inputArtifact *wfv1.Artifact, path string, progress common.ProgressFunc) error
+
+	// SaveWithProgress is Save, calling progress as the artifact is uploaded
+	SaveWithProgress(ctx context.Context, path string, outputArtifact *wfv1.Artifact, progress common.ProgressFunc) error
+}
+
+// ContentEncodingRecorder is implemented by drivers that can record the compression of a saved artifact in the
+// metadata of its object, under common.ContentEncodingMetadataKey, so that it can be decompressed when loaded
+type ContentEncodingRecorder interface {
+	// SaveWithContentEncoding is SaveWithProgress, recording encoding in the metadata of the saved object
+	SaveWithContentEncoding(ctx context.Context, path string, outputArtifact *wfv1.Artifact, encoding string, progress common.ProgressFunc) error
+
+	// ContentEncoding returns the encoding recorded in the metadata of the object of the artifact, or "" if none is
+	ContentEncoding(artifact *wfv1.Artifact) (string, error)
+}
+
+// OverwriteProtector is implemented by drivers that honour the overwrite policy of an output artifact. Unless it is
+// allow, they save the artifact only if its destination does not exist, as checked by a precondition of each write,
+// e.g. If-None-Match, and otherwise return an error which matches common.ErrArtifactExists.
+type OverwriteProtector interface {
+	// ProtectsOverwrite returns whether the saves of the driver honour the overwrite policies of artifacts
+	ProtectsOverwrite() bool
+}
+
+// UnchangedSkipper is implemented by drivers that honour the skip-if-unchanged overwrite policy of an output artifact.
+// They do not save an artifact whose destination has the same content, and return a SaveResult which is Unchanged.
+type UnchangedSkipper interface {
+	// SkipsUnchanged returns whether the saves of the driver honour the skip-if-unchanged overwrite policy
+	SkipsUnchanged() bool
+}
+
+// IdempotentSaver is implemented by drivers that honour the idempotency of an output artifact. They do not save an
+// artifact which a previous attempt of its save, with the same idempotency key, already saved with the same content,
+// and return a SaveResult which is Unchanged.
+type IdempotentSaver interface {
+	// SavesIdempotently returns whether the saves of the driver honour the idempotency of artifacts
+	SavesIdempotently() bool
+}
+
+// SizeLimiter is implemented by drivers that honour the max size of an input artifact. They fail to load an artifact
+// whose storage reports a size larger than it before transferring it, and once more than it is transferred of one whose
+// storage does not, with an error which matches common.ErrArtifactTooLarge.
+type SizeLimiter interface {
+	// LimitsSize returns whether the loads of the driver honour the max sizes of artifacts
+	LimitsSize() bool
+}
+
+// MetadataRecorder is implemented by drivers that save the metadata of an output artifact as the user metadata of each
+// of its objects, and which return that of the object of an artifact
+type MetadataRecorder interface {
+	// Metadata returns the user metadata of the object of the artifact, without that the drivers record themselves,
+	// e.g. common.ContentSHA256MetadataKey
+	Metadata(artifact *wfv1.Artifact) (map[string]string, error)
+}
+
+// AtomicSaver is implemented by drivers that can save an output directory artifact atomically, which puts the objects
+// of its files under a staging key and only copies them to its key once every file is put
+type AtomicSaver interface {
+	// SavesAtomically returns whether the saves of the driver honour the atomicSave of an output artifact
+	SavesAtomically() bool
+}
+
+// SaveDryRunner is implemented by drivers that can check the destination of an artifact, e.g. that its bucket
+// exists, without writing to it
+type SaveDryRunner interface {
+	// DryRunSave returns what saving path to the artifact would write, or an error if the destination is not valid
+	DryRunSave(ctx context.Context, path string, outputArtifact *wfv1.Artifact) (*common.DryRunResult, error)
+}
+
+// SaveResultReporter is implemented by drivers that can return what saving an artifact wrote, e.g. the ETag and
+// version of each object, as their storage reported it
+type SaveResultReporter interface {
+	// SaveWithResult is SaveWithProgress, or SaveWithContentEncoding if encoding is not empty, returning what was
+	// written
+	SaveWithResult(ctx context.Context, path string, outputArtifact *wfv1.Artifact, encoding string, progress common.ProgressFunc) (*common.SaveResult, error)
+}
+
+// ArtifactCopier is implemented by drivers that can copy an artifact to another in their storage server-side, e.g.
+// with CopyObject, without its content passing through the driver
+type ArtifactCopier interface {
+	// CopiesFrom returns whether Copy can copy src, which is of the location type of the artifacts of the driver, to
+	// dst, e.g. because both are in storage the credentials of the driver are for
+	CopiesFrom(src, dst *wfv1.Artifact) bool
+
+	// Copy copies the file or directory of src to dst, replacing anything at its key
+	Copy(ctx context.Context, src, dst *wfv1.Artifact) error
+}
+
+// ArtifactPresigner is implemented by drivers that can presign URLs of objects, which allow anyone with them to send
+// requests of a method for the object, without credentials, until they expire
+type ArtifactPresigner interface {
+	// Presign returns a presigned URL for requests of the method, e.g. GET, of the object at the key of the
+	// artifact, valid for expiry, as validated by common.ValidatePresign. The URL is signed without any request.
+	Presign(artifact *wfv1.Artifact, method string, expiry time.Duration) (string, error)
+}
+
+// PostPolicyPresigner is implemented by drivers that can presign POST policies, which allow anyone with them to upload
+// objects under a key prefix with an HTML form, e.g. from a browser, without credentials, until they expire
+type PostPolicyPresigner interface {
+	// PresignPostPolicy returns a presigned POST policy which allows uploads of objects of the sizes of the policy
+	// whose keys start with the key of the artifact, as validated by common.ValidatePostPolicy. It is signed without
+	// any request.
+	PresignPostPolicy(artifact *wfv1.Artifact, policy common.PostPolicy) (*common.PresignedPost, error)
+}
+
+// CapabilityReporter is implemented by drivers that report which of the optional operations they support, which are
+// those of the interfaces they implement that they honour, and whether they delete artifacts
+type CapabilityReporter interface {
+	// Capabilities returns the optional operations the driver supports
+	Capabilities() common.DriverCapabilities
+}
+
+// LoggerSetter is implemented by drivers which log to the logger NewDriver sets, e.g. by embedding common.Logging
+type LoggerSetter interface {
+	SetLogger(logger log.FieldLogger)
+}
+
+// RoundTripperSetter is implemented by drivers whose clients send their requests with the RoundTripper of the
+// RoundTripperFactory NewDriver sets, e.g. by embedding common.Transporting
+type RoundTripperSetter interface {
+	// SetRoundTripper sets the function which returns the RoundTripper of a client, given its transport
+	SetRoundTripper(wrap func(base http.RoundTripper) http.RoundTripper)
 }
 
 var ErrUnsupportedDriver = fmt.Errorf("unsupported artifact driver")
 
-type NewDriverFunc func(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error)
+// LoadWithProgress loads the artifact, reporting progress if the driver is an ArtifactProgressReporter. A file
+// which was compressed when it was saved, according to a ContentEncodingRecorder, is decompressed. Loading is
+// cancelled once the timeout of the artifact expires. An explicit max size of the artifact requires the driver to be a
+// SizeLimiter. It is traced in a span, which is a child of the span of ctx.
+func LoadWithProgress(ctx context.Context, driver ArtifactDriver, inputArtifact *wfv1.Artifact, path string, progress common.ProgressFunc) error {
+	timeout, err := common.Timeout(inputArtifact)
+	if err != nil {
+		return err
+	}
+	ctx, span := startSpan(ctx, "Load", inputArtifact)
+	loadCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	err = load(loadCtx, driver, inputArtifact, path, progress)
+	err = timeoutError(ctx, loadCtx, err, "loading", inputArtifact, timeout)
+	recordOutcome(driver, inputArtifact, "Load", path, start, span, err, false)
+	return err
+}
 
-// NewDriver initializes an instance of an artifact driver
-func NewDriver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
-	if art.S3 != nil {
-		var accessKey string
-		var secretKey string
-
-		if art.S3.AccessKeySecret.Name != "" {
-			accessKeyBytes, err := ri.GetSecret(ctx, art.S3.AccessKeySecret.Name, art.S3.AccessKeySecret.Key)
-			if err != nil {
-				return nil, err
-			}
-			accessKey = accessKeyBytes
-			secretKeyBytes, err := ri.GetSecret(ctx, art.S3.SecretKeySecret.Name, art.S3.SecretKeySecret.Key)
-			if err != nil {
-				return nil, err
-			}
-			secretKey = secretKeyBytes
+// LoadCached loads the artifact with LoadWithProgress and verifies it with VerifyChecksum, removing it if its
+// checksum does not match. If the cache is not nil, an artifact it has under the Key of the artifact is copied
+// from it rather than loaded, and one which is loaded and verified is added to it.
+func LoadCached(ctx context.Context, c *cache.Cache, driver ArtifactDriver, inputArtifact *wfv1.Artifact, path string, progress common.ProgressFunc) error {
+	load := func(path string) error {
+		if err := LoadWithProgress(ctx, driver, inputArtifact, path, progress); err != nil {
+			return err
 		}
+		if err := VerifyChecksum(driver, inputArtifact, path); err != nil {
+			_ = os.RemoveAll(path)
+			return err
+		}
+		return nil
+	}
+	if c == nil {
+		return load(path)
+	}
+	key, err := cache.Key(inputArtifact)
+	if err != nil {
+		return err
+	}
+	return c.Load(key, path, load)
+}
 
-		driver := s3.S3ArtifactDriver{
-			Endpoint:    art.S3.Endpoint,
-			AccessKey:   accessKey,
-			SecretKey:   secretKey,
-			Secure:      art.S3.Insecure == nil || !*art.S3.Insecure,
-			Region:      art.S3.Region,
-			RoleARN:     art.S3.RoleARN,
-			UseSDKCreds: art.S3.UseSDKCreds,
+func load(ctx context.Context, driver ArtifactDriver, inputArtifact *wfv1.Artifact, path string, progress common.ProgressFunc) error {
+	if inputArtifact.MaxSize != "" {
+		if limiter, ok := driver.(SizeLimiter); !ok || !limiter.LimitsSize() {
+			return errors.Errorf(errors.CodeBadRequest, "max size of loading %s artifacts is not supported", driverName(inputArtifact))
 		}
-		return &driver, nil
 	}
-	if art.HTTP != nil {
-		return &http.HTTPArtifactDriver{}, nil
+	var err error
+	if reporter, ok := driver.(ArtifactProgressReporter); ok {
+		err = reporter.LoadWithProgress(ctx, inputArtifact, path, progress)
+	} else {
+		err = driver.Load(ctx, inputArtifact, path)
+	}
+	if err != nil {
+		return driverError(inputArtifact, OperationLoad, err)
+	}
+	recorder, ok := driver.(ContentEncodingRecorder)
+	if !ok {
+		return nil
+	}
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		// directories are never compressed
+		return err
+	}
+	encoding, err := recorder.ContentEncoding(inputArtifact)
+	if err != nil || encoding == "" {
+		return driverError(inputArtifact, OperationLoad, err)
+	}
+	log.Infof("Decompressing %s content of artifact %s", encoding, inputArtifact.Name)
+	return common.DecompressFile(encoding, path)
+}
+
+// SaveWithProgress saves the artifact, reporting progress if the driver is an ArtifactProgressReporter. A file
+// is first compressed if the artifact has a compression, which requires the driver to be a ContentEncodingRecorder.
+// Saving is cancelled once the timeout of the artifact expires. If its overwrite policy is fail-if-exists or
+// skip-if-exists, which requires the driver to be an OverwriteProtector, the artifact is not saved if its destination
+// exists, which fails with an error matching common.ErrArtifactExists if the policy is fail-if-exists. If it is
+// skip-if-unchanged, which requires the driver to be an UnchangedSkipper, it is not saved if its destination has the
+// same content. Saving a directory atomically requires the driver to be an AtomicSaver, and saving the metadata of an
+// artifact a MetadataRecorder. An artifact larger than its max size is not saved, which fails with an error matching
+// common.ErrArtifactTooLarge.
+func SaveWithProgress(ctx context.Context, driver ArtifactDriver, path string, outputArtifact *wfv1.Artifact, progress common.ProgressFunc) error {
+	_, err := SaveWithResult(ctx, driver, path, outputArtifact, progress)
+	return err
+}
+
+// SaveWithResult saves the artifact as SaveWithProgress does, returning what was written if the driver is a
+// SaveResultReporter. The result is nil if it is not, or if the artifact was not saved because its destination exists,
+// and Unchanged if the artifact was not saved because its destination has the same content. It is traced in a span, as
+// LoadWithProgress is.
+func SaveWithResult(ctx context.Context, driver ArtifactDriver, path string, outputArtifact *wfv1.Artifact, progress common.ProgressFunc) (*common.SaveResult, error) {
+	timeout, err := common.Timeout(outputArtifact)
+	if err != nil {
+		return nil, err
+	}
+	ctx, span := startSpan(ctx, "Save", outputArtifact)
+	saveCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	result, err := save(saveCtx, driver, path, outputArtifact, progress)
+	skipped := false
+	if outputArtifact.Overwrite == wfv1.ArtifactOverwriteSkipIfExists && stderrors.Is(err, common.ErrArtifactExists) {
+		driverLogger(driver, outputArtifact).Infof("Artifact %s exists, so it is not saved: %v", outputArtifact.Name, err)
+		err = nil
+		skipped = true
+	}
+	if err == nil && result != nil && result.Unchanged {
+		driverLogger(driver, outputArtifact).Infof("Artifact %s is unchanged, so it is not saved", outputArtifact.Name)
+		skipped = true
+	}
+	err = timeoutError(ctx, saveCtx, err, "saving", outputArtifact, timeout)
+	recordOutcome(driver, outputArtifact, "Save", path, start, span, err, skipped)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// recordOutcome logs the outcome of loading or saving an artifact at debug level, with the size of the local file or
+// directory once it succeeded, records it in the metrics of RegisterMetrics, and ends its span. A save which was skipped
+// transferred nothing.
+func recordOutcome(driver ArtifactDriver, art *wfv1.Artifact, operation, path string, start time.Time, span trace.Span, err error, skipped bool) {
+	duration := time.Since(start)
+	logger := driverLogger(driver, art).WithFields(log.Fields{"artifact": art.Name, "path": path, "duration": duration})
+	if err != nil {
+		logger.WithError(err).Debugf("%s failed", operation)
+		observeOperation(art, operation, metricStatusFailed, duration, -1)
+		endSpan(span, err, -1)
+		return
+	}
+	if skipped {
+		logger.Debugf("%s skipped", operation)
+		observeOperation(art, operation, metricStatusSkipped, duration, -1)
+		span.SetAttributes(attribute.Bool("artifact.skipped", true))
+		endSpan(span, nil, -1)
+		return
+	}
+	size, err := common.PathSize(path)
+	if err == nil {
+		logger = logger.WithField("bytes", size)
+	} else {
+		size = -1
+	}
+	logger.Debugf("%s succeeded", operation)
+	observeOperation(art, operation, metricStatusSucceeded, duration, size)
+	endSpan(span, nil, size)
+}
+
+// timeoutError returns err as an ERR_TIMEOUT error if transferCtx expired, rather than ctx being cancelled
+func timeoutError(ctx, transferCtx context.Context, err error, verb string, art *wfv1.Artifact, timeout time.Duration) error {
+	if err == nil || ctx.Err() != nil || transferCtx.Err() != context.DeadlineExceeded {
+		return err
+	}
+	return errors.Wrap(err, errors.CodeTimeout, fmt.Sprintf("%s artifact %s timed out after %v: %v", verb, art.Name, timeout, err))
+}
+
+func save(ctx context.Context, driver ArtifactDriver, path string, outputArtifact *wfv1.Artifact, progress common.ProgressFunc) (*common.SaveResult, error) {
+	if common.IsOverwriteProtected(outputArtifact.Overwrite) {
+		if protector, ok := driver.(OverwriteProtector); !ok || !protector.ProtectsOverwrite() {
+			return nil, errors.Errorf(errors.CodeBadRequest, "overwrite %s of %s artifacts is not supported", outputArtifact.Overwrite, driverName(outputArtifact))
+		}
 	}
-	if art.Git != nil {
-		gitDriver := git.GitArtifactDriver{
-			InsecureIgnoreHostKey: art.Git.InsecureIgnoreHostKey,
+	if common.SkipsUnchanged(outputArtifact.Overwrite) {
+		if skipper, ok := driver.(UnchangedSkipper); !ok || !skipper.SkipsUnchanged() {
+			return nil, errors.Errorf(errors.CodeBadRequest, "overwrite %s of %s artifacts is not supported", outputArtifact.Overwrite, driverName(outputArtifact))
 		}
-		if art.Git.UsernameSecret != nil {
-			usernameBytes, err := ri.GetSecret(ctx, art.Git.UsernameSecret.Name, art.Git.UsernameSecret.Key)
-			if err != nil {
-				return nil, err
-			}
-			gitDriver.Username = usernameBytes
+	}
+	if outputArtifact.Idempotency != nil {
+		if saver, ok := driver.(IdempotentSaver); !ok || !saver.SavesIdempotently() {
+			return nil, errors.Errorf(errors.CodeBadRequest, "idempotent save of %s artifacts is not supported", driverName(outputArtifact))
 		}
-		if art.Git.PasswordSecret != nil {
-			passwordBytes, err := ri.GetSecret(ctx, art.Git.PasswordSecret.Name, art.Git.PasswordSecret.Key)
-			if err != nil {
-				return nil, err
-			}
-			gitDriver.Password = passwordBytes
+	}
+	if len(outputArtifact.Metadata) > 0 {
+		if _, ok := driver.(MetadataRecorder); !ok {
+			return nil, errors.Errorf(errors.CodeBadRequest, "metadata of %s artifacts is not supported", driverName(outputArtifact))
 		}
-		if art.Git.SSHPrivateKeySecret != nil {
-			sshPrivateKeyBytes, err := ri.GetSecret(ctx, art.Git.SSHPrivateKeySecret.Name, art.Git.SSHPrivateKeySecret.Key)
-			if err != nil {
-				return nil, err
-			}
-			gitDriver.SSHPrivateKey = sshPrivateKeyBytes
+	}
+	if outputArtifact.AtomicSave {
+		if saver, ok := driver.(AtomicSaver); !ok || !saver.SavesAtomically() {
+			return nil, errors.Errorf(errors.CodeBadRequest, "atomic save of %s artifacts is not supported", driverName(outputArtifact))
 		}
-
-		return &gitDriver, nil
 	}
-	if art.Artifactory != nil {
-		usernameBytes, err := ri.GetSecret(ctx, art.Artifactory.UsernameSecret.Name, art.Artifactory.UsernameSecret.Key)
+	if err := checkSaveSize(path, outputArtifact); err != nil {
+		return nil, err
+	}
+	if common.IsCompressed(outputArtifact.Compression) {
+		recorder, ok := driver.(ContentEncodingRecorder)
+		if !ok {
+			return nil, errors.Errorf(errors.CodeBadRequest, "compression of %s artifacts is not supported", driverName(outputArtifact))
+		}
+		info, err := os.Stat(path)
 		if err != nil {
 			return nil, err
 		}
-		passwordBytes, err := ri.GetSecret(ctx, art.Artifactory.PasswordSecret.Name, art.Artifactory.PasswordSecret.Key)
+		if info.Mode().IsRegular() {
+			return saveCompressed(ctx, recorder, path, outputArtifact, progress)
+		}
+		log.Warnf("Artifact %s is a directory, which is saved uncompressed; archive it as a tar to compress it", outputArtifact.Name)
+	}
+	if reporter, ok := driver.(SaveResultReporter); ok {
+		result, err := reporter.SaveWithResult(ctx, path, outputArtifact, "", progress)
+		return result, driverError(outputArtifact, OperationSave, err)
+	}
+	if reporter, ok := driver.(ArtifactProgressReporter); ok {
+		return nil, driverError(outputArtifact, OperationSave, reporter.SaveWithProgress(ctx, path, outputArtifact, progress))
+	}
+	return nil, driverError(outputArtifact, OperationSave, driver.Save(ctx, path, outputArtifact))
+}
+
+// checkSaveSize returns an error which matches common.ErrArtifactTooLarge if the file or directory at path is larger
+// than the max size of the output artifact, before any of it is saved
+func checkSaveSize(path string, outputArtifact *wfv1.Artifact) error {
+	maxSize, err := common.MaxSize(outputArtifact)
+	if err != nil || maxSize <= 0 {
+		return err
+	}
+	size, err := common.PathSize(path)
+	if err != nil {
+		return err
+	}
+	return driverError(outputArtifact, OperationSave, common.CheckSize(size, maxSize))
+}
+
+// saveCompressed saves the file at path compressed with the compression of the artifact
+func saveCompressed(ctx context.Context, recorder ContentEncodingRecorder, path string, outputArtifact *wfv1.Artifact, progress common.ProgressFunc) (*common.SaveResult, error) {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".compress")
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := tmp.Name()
+	_ = tmp.Close()
+	defer func() { _ = os.Remove(tmpPath) }()
+	if err := common.CompressFile(outputArtifact.Compression, path, tmpPath); err != nil {
+		return nil, err
+	}
+	// the content type is that of the file, which the object is once decompressed, rather than of the temporary file
+	if outputArtifact.ContentType == "" {
+		contentType, err := common.DetectContentType(path)
 		if err != nil {
 			return nil, err
 		}
-		driver := artifactory.ArtifactoryArtifactDriver{
-			Username: usernameBytes,
-			Password: passwordBytes,
+		art := *outputArtifact
+		art.ContentType = contentType
+		outputArtifact = &art
+	}
+	encoding := string(outputArtifact.Compression)
+	if reporter, ok := recorder.(SaveResultReporter); ok {
+		result, err := reporter.SaveWithResult(ctx, tmpPath, outputArtifact, encoding, progress)
+		if result != nil {
+			// the object is of the file, rather than of the temporary file it was compressed to
+			for i := range result.Objects {
+				result.Objects[i].Path = path
+			}
 		}
-		return &driver, nil
+		return result, driverError(outputArtifact, OperationSave, err)
+	}
+	return nil, driverError(outputArtifact, OperationSave, recorder.SaveWithContentEncoding(ctx, tmpPath, outputArtifact, encoding, progress))
+}
+
+// Delete deletes the artifact from its storage
+func Delete(driver ArtifactDriver, artifact *wfv1.Artifact) error {
+	return driverError(artifact, OperationDelete, driver.Delete(artifact))
+}
+
+// ErrExistsNotSupported is returned by Exists if the driver is not an ArtifactExistenceChecker
+var ErrExistsNotSupported = errors.New(errors.CodeNotImplemented, "checking existence not supported for this artifact storage")
+
+// Exists returns whether the artifact exists, if the driver is an ArtifactExistenceChecker
+func Exists(driver ArtifactDriver, artifact *wfv1.Artifact) (bool, error) {
+	if checker, ok := driver.(ArtifactExistenceChecker); ok {
+		exists, err := checker.Exists(artifact)
+		return exists, driverError(artifact, OperationExists, err)
+	}
+	return false, ErrExistsNotSupported
+}
+
+// ErrMetadataNotSupported is returned by Metadata if the driver is not a MetadataRecorder
+var ErrMetadataNotSupported = errors.New(errors.CodeNotImplemented, "getting the metadata of objects not supported for this artifact storage")
+
+// Metadata returns the user metadata of the object of the artifact, e.g. that it was saved with, if the driver is a
+// MetadataRecorder
+func Metadata(driver ArtifactDriver, artifact *wfv1.Artifact) (map[string]string, error) {
+	if recorder, ok := driver.(MetadataRecorder); ok {
+		metadata, err := recorder.Metadata(artifact)
+		return metadata, driverError(artifact, OperationMetadata, err)
+	}
+	return nil, ErrMetadataNotSupported
+}
+
+// ErrPresignNotSupported is returned by Presign if the driver is not an ArtifactPresigner
+var ErrPresignNotSupported = errors.New(errors.CodeNotImplemented, "presigning URLs not supported for this artifact storage")
 
+// Presign returns a presigned URL for requests of the method of an object of the artifact, valid for expiry, if the
+// driver is an ArtifactPresigner. The object is that of a file artifact if objectPath is empty, and is otherwise
+// that at objectPath under its key, as the objects of a directory artifact are.
+func Presign(driver ArtifactDriver, artifact *wfv1.Artifact, objectPath, method string, expiry time.Duration) (string, error) {
+	presigner, ok := driver.(ArtifactPresigner)
+	if !ok {
+		return "", ErrPresignNotSupported
 	}
-	if art.HDFS != nil {
-		return hdfs.CreateDriver(ctx, ri, art.HDFS)
+	if objectPath != "" {
+		key, err := artifact.GetKey()
+		if err != nil {
+			return "", err
+		}
+		artifact = artifact.DeepCopy()
+		if err := artifact.SetKey(path.Join(key, objectPath)); err != nil {
+			return "", err
+		}
 	}
-	if art.Raw != nil {
-		return &raw.RawArtifactDriver{}, nil
+	u, err := presigner.Presign(artifact, method, expiry)
+	return u, driverError(artifact, OperationPresign, err)
+}
+
+// ErrPostPolicyNotSupported is returned by PresignPostPolicy if the driver is not a PostPolicyPresigner
+var ErrPostPolicyNotSupported = errors.New(errors.CodeNotImplemented, "presigning POST policies not supported for this artifact storage")
+
+// PresignPostPolicy returns a presigned POST policy which allows uploads of objects under the key of the artifact, if
+// the driver is a PostPolicyPresigner
+func PresignPostPolicy(driver ArtifactDriver, artifact *wfv1.Artifact, policy common.PostPolicy) (*common.PresignedPost, error) {
+	presigner, ok := driver.(PostPolicyPresigner)
+	if !ok {
+		return nil, ErrPostPolicyNotSupported
 	}
+	post, err := presigner.PresignPostPolicy(artifact, policy)
+	return post, driverError(artifact, OperationPresignPost, err)
+}
 
-	if art.OSS != nil {
-		var accessKey string
-		var secretKey string
+// Capabilities returns the optional operations the driver supports, as it reports them if it is a CapabilityReporter,
+// otherwise those of the interfaces it implements, in which case it is assumed to delete artifacts
+func Capabilities(driver ArtifactDriver) common.DriverCapabilities {
+	if reporter, ok := driver.(CapabilityReporter); ok {
+		return reporter.Capabilities()
+	}
+	capabilities := implementedCapabilities(driver)
+	capabilities.Delete = true
+	return capabilities
+}
 
-		if art.OSS.AccessKeySecret.Name != "" {
-			accessKeyBytes, err := ri.GetSecret(ctx, art.OSS.AccessKeySecret.Name, art.OSS.AccessKeySecret.Key)
-			if err != nil {
-				return nil, err
-			}
-			accessKey = string(accessKeyBytes)
-			secretKeyBytes, err := ri.GetSecret(ctx, art.OSS.SecretKeySecret.Name, art.OSS.SecretKeySecret.Key)
-			if err != nil {
-				return nil, err
-			}
-			secretKey = string(secretKeyBytes)
+// implementedCapabilities returns the optional operations of the interfaces the driver implements, and honours if
+// they report whether it does, without whether it deletes artifacts, which no interface tells
+func implementedCapabilities(driver ArtifactDriver) common.DriverCapabilities {
+	var c common.DriverCapabilities
+	_, c.Stream = driver.(ArtifactStreamer)
+	_, c.LoadRange = driver.(RangeLoader)
+	_, c.Exists = driver.(ArtifactExistenceChecker)
+	_, c.ExistsBatch = driver.(BatchExistenceChecker)
+	_, c.VerifyChecksum = driver.(ChecksumVerifier)
+	_, c.HealthCheck = driver.(HealthChecker)
+	_, c.Progress = driver.(ArtifactProgressReporter)
+	_, c.ContentEncoding = driver.(ContentEncodingRecorder)
+	if p, ok := driver.(OverwriteProtector); ok {
+		c.OverwriteProtection = p.ProtectsOverwrite()
+	}
+	if s, ok := driver.(UnchangedSkipper); ok {
+		c.SkipUnchanged = s.SkipsUnchanged()
+	}
+	if s, ok := driver.(AtomicSaver); ok {
+		c.AtomicSave = s.SavesAtomically()
+	}
+	if s, ok := driver.(IdempotentSaver); ok {
+		c.IdempotentSave = s.SavesIdempotently()
+	}
+	if l, ok := driver.(SizeLimiter); ok {
+		c.MaxSize = l.LimitsSize()
+	}
+	_, c.Metadata = driver.(MetadataRecorder)
+	_, c.DryRunSave = driver.(SaveDryRunner)
+	_, c.SaveResult = driver.(SaveResultReporter)
+	_, c.Copy = driver.(ArtifactCopier)
+	_, c.Presign = driver.(ArtifactPresigner)
+	_, c.PresignPostPolicy = driver.(PostPolicyPresigner)
+	return c
+}
+
+// ExistsBatch returns whether each of the artifacts exists, in one batch if the driver is a BatchExistenceChecker,
+// otherwise one by one if it is an ArtifactExistenceChecker
+func ExistsBatch(driver ArtifactDriver, artifacts []*wfv1.Artifact) ([]bool, error) {
+	if checker, ok := driver.(BatchExistenceChecker); ok {
+		result, err := checker.ExistsBatch(artifacts)
+		if err != nil && len(artifacts) > 0 {
+			// the artifacts are in the same storage, but which of them the driver failed on is not known
+			return nil, &DriverError{Driver: driverName(artifacts[0]), Operation: OperationExists, Err: err}
 		}
+		return result, err
+	}
+	result := make([]bool, len(artifacts))
+	for i, artifact := range artifacts {
+		ok, err := Exists(driver, artifact)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = ok
+	}
+	return result, nil
+}
 
-		driver := oss.OSSArtifactDriver{
-			Endpoint:  art.OSS.Endpoint,
-			AccessKey: accessKey,
-			SecretKey: secretKey,
+// ErrHealthCheckNotSupported is returned by HealthCheck if the driver is not a HealthChecker
+var ErrHealthCheckNotSupported = errors.New(errors.CodeNotImplemented, "health check not supported for this artifact storage")
+
+// HealthCheck checks that the storage of the artifact, which the driver was created for, is reachable with the
+// credentials of the driver, if the driver is a HealthChecker
+func HealthCheck(ctx context.Context, driver ArtifactDriver, artifact *wfv1.Artifact) error {
+	if checker, ok := driver.(HealthChecker); ok {
+		return driverError(artifact, OperationHealthCheck, checker.HealthCheck(ctx))
+	}
+	return ErrHealthCheckNotSupported
+}
+
+// ErrLoadRangeNotSupported is returned by LoadRange if the driver is not a RangeLoader
+var ErrLoadRangeNotSupported = errors.New(errors.CodeNotImplemented, "loading a byte range not supported for this artifact storage")
+
+// LoadRange loads a range of the bytes of the artifact to path, as RangeLoader.LoadRange does, if the driver is a
+// RangeLoader. A range of the bytes of an artifact which was compressed when it was saved, according to a
+// ContentEncodingRecorder, cannot be decompressed, so it is an error to load one.
+func LoadRange(driver ArtifactDriver, artifact *wfv1.Artifact, start, length int64, path string) error {
+	if start < 0 {
+		return errors.Errorf(errors.CodeBadRequest, "the range of artifact %s cannot start at the negative offset %d", artifact.Name, start)
+	}
+	if length == 0 {
+		return errors.Errorf(errors.CodeBadRequest, "the range of artifact %s cannot be empty", artifact.Name)
+	}
+	loader, ok := driver.(RangeLoader)
+	if !ok {
+		return ErrLoadRangeNotSupported
+	}
+	if recorder, ok := driver.(ContentEncodingRecorder); ok {
+		encoding, err := recorder.ContentEncoding(artifact)
+		if err != nil {
+			return driverError(artifact, OperationLoadRange, err)
+		}
+		if encoding != "" {
+			return errors.Errorf(errors.CodeBadRequest, "a range of artifact %s cannot be loaded, as it is %s compressed", artifact.Name, encoding)
 		}
-		return &driver, nil
 	}
+	return driverError(artifact, OperationLoadRange, loader.LoadRange(artifact, start, length, path))
+}
 
-	if art.GCS != nil {
-		driver := gcs.ArtifactDriver{}
-		if art.GCS.ServiceAccountKeySecret.Name != "" {
-			serviceAccountKeyBytes, err := ri.GetSecret(ctx, art.GCS.ServiceAccountKeySecret.Name, art.GCS.ServiceAccountKeySecret.Key)
-			if err != nil {
-				return nil, err
-			}
-			serviceAccountKey := string(serviceAccountKeyBytes)
-			driver.ServiceAccountKey = serviceAccountKey
+// ErrDryRunNotSupported is returned by DryRunSave if the driver is not a SaveDryRunner
+var ErrDryRunNotSupported = errors.New(errors.CodeNotImplemented, "dry run of saving not supported for this artifact storage")
+
+// DryRunSave checks that the artifact could be saved, and returns what saving path would write, if the driver is a
+// SaveDryRunner. Nothing is written.
+func DryRunSave(ctx context.Context, driver ArtifactDriver, path string, outputArtifact *wfv1.Artifact) (*common.DryRunResult, error) {
+	if dryRunner, ok := driver.(SaveDryRunner); ok {
+		result, err := dryRunner.DryRunSave(ctx, path, outputArtifact)
+		return result, driverError(outputArtifact, OperationDryRunSave, err)
+	}
+	return nil, ErrDryRunNotSupported
+}
+
+// VerifyChecksum verifies that the artifact loaded at path matches its expected checksum, if it has one.
+// If the driver is not a ChecksumVerifier, the checksum is computed over the loaded bytes.
+func VerifyChecksum(driver ArtifactDriver, artifact *wfv1.Artifact, path string) error {
+	if artifact.Checksum == nil {
+		return nil
+	}
+	if verifier, ok := driver.(ChecksumVerifier); ok {
+		return driverError(artifact, OperationVerifyChecksum, verifier.VerifyChecksum(artifact, path))
+	}
+	return common.VerifyChecksum(artifact.Checksum, path)
+}
+
+// OpenStream opens the artifact for reading. If the driver is not an ArtifactStreamer, the artifact is
+// loaded into a temporary file, which is removed when the returned reader is closed.
+func OpenStream(ctx context.Context, driver ArtifactDriver, artifact *wfv1.Artifact) (io.ReadCloser, error) {
+	if streamer, ok := driver.(ArtifactStreamer); ok {
+		stream, err := streamer.OpenStream(artifact)
+		if err != nil {
+			return nil, driverError(artifact, OperationOpen, err)
+		}
+		return decompressStream(driver, artifact, stream)
+	}
+	tmp, err := common.CreateTemp("artifact")
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := tmp.Name()
+	_ = tmp.Close()
+	err = LoadWithProgress(ctx, driver, artifact, tmpPath, nil)
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return nil, err
+	}
+	f, err := os.Open(tmpPath)
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return nil, err
+	}
+	return &tempFileReadCloser{File: f}, nil
+}
+
+// decompressStream returns a reader of the decompressed content of the stream, if the driver is a
+// ContentEncodingRecorder which recorded that the artifact is compressed
+func decompressStream(driver ArtifactDriver, artifact *wfv1.Artifact, stream io.ReadCloser) (io.ReadCloser, error) {
+	recorder, ok := driver.(ContentEncodingRecorder)
+	if !ok {
+		return stream, nil
+	}
+	encoding, err := recorder.ContentEncoding(artifact)
+	if err != nil {
+		_ = stream.Close()
+		return nil, driverError(artifact, OperationOpen, err)
+	}
+	if encoding == "" {
+		return stream, nil
+	}
+	r, err := common.NewDecompressReader(encoding, stream)
+	if err != nil {
+		_ = stream.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// tempFileReadCloser removes the file once it is closed
+type tempFileReadCloser struct {
+	*os.File
+}
+
+func (t *tempFileReadCloser) Close() error {
+	err := t.File.Close()
+	_ = os.Remove(t.Name())
+	return err
+}
+
+type NewDriverFunc func(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error)
+
+var (
+	driversLock sync.RWMutex
+	drivers     = map[string]NewDriverFunc{}
+	// roundTripperFactory is guarded by driversLock
+	roundTripperFactory RoundTripperFactory
+)
+
+// RoundTripperFactory returns the RoundTripper the clients of the named driver send their requests with, given base,
+// the transport they would otherwise send them with, e.g. one which records or instruments the requests it sends with
+// base, or one with the client certificate of mTLS
+type RoundTripperFactory func(driver string, base http.RoundTripper) http.RoundTripper
+
+// SetRoundTripperFactory sets the factory of the RoundTripper of the RoundTripperSetters NewDriver creates, or if it is
+// nil, unsets it, so that they send their requests with their own transport, as they do by default
+func SetRoundTripperFactory(factory RoundTripperFactory) {
+	driversLock.Lock()
+	defer driversLock.Unlock()
+	roundTripperFactory = factory
+}
+
+// RegisterDriver registers the constructor of the driver for artifacts of the named location type
+// (e.g. "s3"), replacing any driver previously registered under that name. It is safe to call from
+// the init functions of multiple packages.
+func RegisterDriver(name string, fn NewDriverFunc) {
+	driversLock.Lock()
+	defer driversLock.Unlock()
+	drivers[name] = fn
+}
+
+// driverName returns the name of the location type of the artifact, or "" if it has none
+func driverName(art *wfv1.Artifact) string {
+	switch {
+	case art.S3 != nil:
+		return DriverS3
+	case art.HTTP != nil:
+		return DriverHTTP
+	case art.Git != nil:
+		return DriverGit
+	case art.Artifactory != nil:
+		return DriverArtifactory
+	case art.HDFS != nil:
+		return DriverHDFS
+	case art.Raw != nil:
+		return DriverRaw
+	case art.OSS != nil:
+		return DriverOSS
+	case art.GCS != nil:
+		return DriverGCS
+	case art.SFTP != nil:
+		return DriverSFTP
+	case art.GDrive != nil:
+		return DriverGDrive
+	case art.WebDAV != nil:
+		return DriverWebDAV
+	case art.FTP != nil:
+		return DriverFTP
+	case art.B2 != nil:
+		return DriverB2
+	case art.Swift != nil:
+		return DriverSwift
+	case art.Rclone != nil:
+		return DriverRclone
+	case art.Dropbox != nil:
+		return DriverDropbox
+	case art.IPFS != nil:
+		return DriverIPFS
+	case art.ADLS != nil:
+		return DriverADLS
+	case art.HuggingFace != nil:
+		return DriverHuggingFace
+	case art.DevNull != nil:
+		return DriverDevNull
+	case art.Filesystem != nil:
+		return DriverFilesystem
+	case art.OCI != nil:
+		return DriverOCI
+	}
+	return ""
+}
+
+// NewDriver initializes an instance of an artifact driver. A LoggerSetter logs to the logger of ri, if it is a
+// resource.LoggerProvider, or the standard logger, with the name of the driver as a field. A RoundTripperSetter sends
+// its requests with the RoundTripper of the factory of SetRoundTripperFactory, if one is set, through the circuit
+// breakers of common.DefaultCircuitBreakers, if they are set, and transfers at most the bandwidth limit of the
+// artifact, which other drivers do not support, unless it is the default. The driver of an encrypted artifact encrypts
+// and decrypts it, and supports none of the optional interfaces.
+func NewDriver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	name := driverName(art)
+	driversLock.RLock()
+	fn, ok := drivers[name]
+	factory := roundTripperFactory
+	driversLock.RUnlock()
+	if !ok {
+		return nil, ErrUnsupportedDriver
+	}
+	limit, err := common.BandwidthLimit(art)
+	if err != nil {
+		return nil, err
+	}
+	if art.ExpandEnv {
+		if err := common.ExpandEnv(art, os.LookupEnv); err != nil {
+			return nil, err
 		}
-		// key is not set, assume it is using Workload Idendity
-		return &driver, nil
 	}
+	driver, err := fn(ctx, art, ri)
+	if err != nil {
+		return nil, err
+	}
+	if setter, ok := driver.(LoggerSetter); ok {
+		setter.SetLogger(newLogger(ri, name))
+	}
+	setter, ok := driver.(RoundTripperSetter)
+	if !ok && art.BandwidthLimit != "" {
+		return nil, errors.Errorf(errors.CodeBadRequest, "bandwidth limit of %s artifacts is not supported", name)
+	}
+	breakers := common.DefaultCircuitBreakers
+	if ok && (factory != nil || limit > 0 || breakers != nil) {
+		// the throttle is shared by every request of the driver, e.g. those of the files of a directory
+		throttle := common.NewThrottle(limit)
+		setter.SetRoundTripper(func(base http.RoundTripper) http.RoundTripper {
+			rt := common.NewThrottledTransport(common.NewCircuitBreakerTransport(base, breakers), throttle)
+			if factory != nil {
+				rt = factory(name, rt)
+			}
+			return rt
+		})
+	}
+	if art.Encryption != nil {
+		return encryption.NewArtifactDriver(driver, ri), nil
+	}
+	return driver, nil
+}
+
+// newLogger returns the logger of a driver created with ri
+func newLogger(ri resource.Interface, name string) log.FieldLogger {
+	var logger log.FieldLogger = log.StandardLogger()
+	if provider, ok := ri.(resource.LoggerProvider); ok {
+		logger = provider.Logger()
+	}
+	return logger.WithField("driver", name)
+}
 
-	return nil, ErrUnsupportedDriver
+// driverLogger returns the logger of the driver, if it has one, or the standard logger
+func driverLogger(driver ArtifactDriver, art *wfv1.Artifact) log.FieldLogger {
+	if logging, ok := driver.(interface{ Log() log.FieldLogger }); ok {
+		return logging.Log()
+	}
+	return log.WithField("driver", driverName(art))
 }
@@ -0,0 +1,1543 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	stderrors "errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/adls"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/b2"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/cache"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/devnull"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/dropbox"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/encryption"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/filesystem"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/ftp"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/gcs"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/gdrive"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/git"
+	artifactshttp "github.com/argoproj/argo-workflows/v3/workflow/artifacts/http"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/huggingface"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/ipfs"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/memory"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/oci"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/oss"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/rclone"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/resource"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/resource/resourcetest"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/s3"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/swift"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/webdav"
+)
+
+type fakeResources map[string]string
+
+func (r fakeResources) GetSecret(_ context.Context, name, key string) (string, error) {
+	return r[name+"/"+key], nil
+}
+
+func (r fakeResources) GetConfigMapKey(_ context.Context, name, key string) (string, error) {
+	return r[name+"/"+key], nil
+}
+
+type loadOnlyDriver struct {
+	ArtifactDriver
+	data []byte
+}
+
+func (d *loadOnlyDriver) Load(_ context.Context, _ *wfv1.Artifact, path string) error {
+	return ioutil.WriteFile(path, d.data, 0600)
+}
+
+func TestOpenStream(t *testing.T) {
+	t.Run("Fallback", func(t *testing.T) {
+		stream, err := OpenStream(context.Background(), &loadOnlyDriver{data: []byte("my-data")}, &wfv1.Artifact{})
+		if assert.NoError(t, err) {
+			data, err := ioutil.ReadAll(stream)
+			assert.NoError(t, err)
+			assert.Equal(t, "my-data", string(data))
+			tmpPath := stream.(*tempFileReadCloser).Name()
+			assert.NoError(t, stream.Close())
+			_, err = os.Stat(tmpPath)
+			assert.True(t, os.IsNotExist(err))
+		}
+	})
+	t.Run("TempDir", func(t *testing.T) {
+		tmp, err := ioutil.TempDir("", "temp-dir")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer func() { _ = os.RemoveAll(tmp) }()
+		common.DefaultTempDir = filepath.Join(tmp, "staging")
+		defer func() { common.DefaultTempDir = "" }()
+		stream, err := OpenStream(context.Background(), &loadOnlyDriver{data: []byte("my-data")}, &wfv1.Artifact{})
+		if assert.NoError(t, err) {
+			// the artifact is staged in the temp dir, which is created, until the stream is closed
+			tmpPath := stream.(*tempFileReadCloser).Name()
+			assert.Equal(t, common.DefaultTempDir, filepath.Dir(tmpPath))
+			assert.NoError(t, stream.Close())
+			files, err := ioutil.ReadDir(common.DefaultTempDir)
+			assert.NoError(t, err)
+			assert.Empty(t, files)
+		}
+	})
+}
+
+func TestNewDriver(t *testing.T) {
+	ctx := context.Background()
+	t.Run("S3ServerSideEncryption", func(t *testing.T) {
+		driver, err := NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				S3: &wfv1.S3Artifact{
+					S3Bucket: wfv1.S3Bucket{
+						Endpoint:        "my-endpoint",
+						Bucket:          "my-bucket",
+						AccessKeySecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "accesskey"},
+						SecretKeySecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "secretkey"},
+						ServerSideEncryption: &wfv1.S3ServerSideEncryption{
+							SSEAlgorithm: s3.SSEAlgorithmKMS,
+							KMSKeyID:     "my-key",
+						},
+					},
+					Key: "my-key",
+				},
+			},
+		}, fakeResources{"my-secret/accesskey": "my-access-key", "my-secret/secretkey": "my-secret-key"})
+		if assert.NoError(t, err) {
+			s3Driver := driver.(*s3.S3ArtifactDriver)
+			assert.Equal(t, "my-access-key", s3Driver.AccessKey)
+			assert.Equal(t, s3.SSEAlgorithmKMS, s3Driver.SSEAlgorithm)
+			assert.Equal(t, "my-key", s3Driver.KMSKeyID)
+			assert.Equal(t, "my-bucket", s3Driver.Bucket)
+		}
+	})
+	t.Run("S3PathStyle", func(t *testing.T) {
+		pathStyle := true
+		driver, err := NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				S3: &wfv1.S3Artifact{
+					S3Bucket: wfv1.S3Bucket{
+						Endpoint:        "my-endpoint",
+						Bucket:          "my-bucket",
+						AccessKeySecret: &apiv1.SecretKeySelector{},
+						PathStyle:       &pathStyle,
+					},
+					Key: "my-key",
+				},
+			},
+		}, fakeResources{})
+		if assert.NoError(t, err) {
+			s3Driver := driver.(*s3.S3ArtifactDriver)
+			if assert.NotNil(t, s3Driver.PathStyle) {
+				assert.True(t, *s3Driver.PathStyle)
+			}
+		}
+	})
+	t.Run("S3Multipart", func(t *testing.T) {
+		partConcurrency := int32(8)
+		driver, err := NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				S3: &wfv1.S3Artifact{
+					S3Bucket: wfv1.S3Bucket{
+						Endpoint:        "my-endpoint",
+						Bucket:          "my-bucket",
+						AccessKeySecret: &apiv1.SecretKeySelector{},
+						PartSize:        "64Mi",
+						PartConcurrency: &partConcurrency,
+					},
+					Key: "my-key",
+				},
+			},
+		}, fakeResources{})
+		if assert.NoError(t, err) {
+			s3Driver := driver.(*s3.S3ArtifactDriver)
+			assert.Equal(t, int64(64*1024*1024), s3Driver.PartSize)
+			assert.Equal(t, 8, s3Driver.PartConcurrency)
+		}
+	})
+	t.Run("S3RequesterPays", func(t *testing.T) {
+		driver, err := NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				S3: &wfv1.S3Artifact{
+					S3Bucket: wfv1.S3Bucket{
+						Endpoint:        "my-endpoint",
+						Bucket:          "my-bucket",
+						AccessKeySecret: &apiv1.SecretKeySelector{},
+						RequesterPays:   true,
+					},
+					Key: "my-key",
+				},
+			},
+		}, fakeResources{})
+		if assert.NoError(t, err) {
+			assert.True(t, driver.(*s3.S3ArtifactDriver).RequesterPays)
+		}
+	})
+	t.Run("S3Retry", func(t *testing.T) {
+		limit := int32(2)
+		driver, err := NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				S3: &wfv1.S3Artifact{
+					S3Bucket: wfv1.S3Bucket{
+						Endpoint:        "my-endpoint",
+						Bucket:          "my-bucket",
+						AccessKeySecret: &apiv1.SecretKeySelector{},
+						Retry:           &wfv1.S3Retry{Limit: &limit, BaseDelay: "5s"},
+					},
+					Key: "my-key",
+				},
+			},
+		}, fakeResources{})
+		if assert.NoError(t, err) {
+			retry := driver.(*s3.S3ArtifactDriver).Retry
+			assert.Equal(t, 2, retry.Steps)
+			assert.Equal(t, 5*time.Second, retry.Duration)
+		}
+	})
+	t.Run("GCSRequesterPays", func(t *testing.T) {
+		driver, err := NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				GCS: &wfv1.GCSArtifact{
+					GCSBucket: wfv1.GCSBucket{
+						Bucket:                  "my-bucket",
+						ServiceAccountKeySecret: &apiv1.SecretKeySelector{},
+						RequesterPays:           true,
+						BillingProject:          "my-project",
+					},
+					Key: "my-key",
+				},
+			},
+		}, fakeResources{})
+		if assert.NoError(t, err) {
+			gcsDriver := driver.(*gcs.ArtifactDriver)
+			assert.True(t, gcsDriver.RequesterPays)
+			assert.Equal(t, "my-project", gcsDriver.BillingProject)
+			assert.Equal(t, "my-bucket", gcsDriver.Bucket)
+		}
+	})
+	t.Run("GCSEndpoint", func(t *testing.T) {
+		newArtifact := func(endpoint string) *wfv1.Artifact {
+			return &wfv1.Artifact{
+				ArtifactLocation: wfv1.ArtifactLocation{
+					GCS: &wfv1.GCSArtifact{GCSBucket: wfv1.GCSBucket{Bucket: "my-bucket", ServiceAccountKeySecret: &apiv1.SecretKeySelector{}, Endpoint: endpoint}, Key: "my-key"},
+				},
+			}
+		}
+		driver, err := NewDriver(ctx, newArtifact("https://storage-myendpoint.p.googleapis.com/"), fakeResources{})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "https://storage-myendpoint.p.googleapis.com", driver.(*gcs.ArtifactDriver).Endpoint.String())
+		}
+		driver, err = NewDriver(ctx, newArtifact(""), fakeResources{})
+		if assert.NoError(t, err) {
+			assert.Nil(t, driver.(*gcs.ArtifactDriver).Endpoint)
+		}
+		_, err = NewDriver(ctx, newArtifact("storage-myendpoint.p.googleapis.com"), fakeResources{})
+		assert.Error(t, err)
+	})
+	t.Run("GCSEncryptionKey", func(t *testing.T) {
+		encryptionKey := bytes.Repeat([]byte{7}, 32)
+		newArtifact := func() *wfv1.Artifact {
+			return &wfv1.Artifact{
+				ArtifactLocation: wfv1.ArtifactLocation{
+					GCS: &wfv1.GCSArtifact{
+						GCSBucket: wfv1.GCSBucket{
+							Bucket:                  "my-bucket",
+							ServiceAccountKeySecret: &apiv1.SecretKeySelector{},
+							EncryptionKeySecret:     &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "csek"},
+						},
+						Key: "my-key",
+					},
+				},
+			}
+		}
+		driver, err := NewDriver(ctx, newArtifact(), fakeResources{"my-secret/csek": base64.StdEncoding.EncodeToString(encryptionKey) + "\n"})
+		if assert.NoError(t, err) {
+			assert.Equal(t, encryptionKey, driver.(*gcs.ArtifactDriver).EncryptionKey)
+		}
+		_, err = NewDriver(ctx, newArtifact(), fakeResources{"my-secret/csek": base64.StdEncoding.EncodeToString(encryptionKey[:16])})
+		assert.Error(t, err)
+	})
+	t.Run("OSS", func(t *testing.T) {
+		secret := func(key string) *apiv1.SecretKeySelector {
+			return &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: key}
+		}
+		driver, err := NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				OSS: &wfv1.OSSArtifact{
+					OSSBucket: wfv1.OSSBucket{
+						Bucket:              "my-bucket",
+						AccessKeySecret:     secret("accessKey"),
+						SecretKeySecret:     secret("secretKey"),
+						SecurityTokenSecret: secret("securityToken"),
+					},
+					Key: "my-key",
+				},
+			},
+		}, fakeResources{"my-secret/accessKey": "my-access-key", "my-secret/secretKey": "my-secret-key", "my-secret/securityToken": "my-token"})
+		if assert.NoError(t, err) {
+			ossDriver := driver.(*oss.OSSArtifactDriver)
+			assert.Equal(t, "my-access-key", ossDriver.AccessKey)
+			assert.Equal(t, "my-secret-key", ossDriver.SecretKey)
+			assert.Equal(t, "my-token", ossDriver.SecurityToken)
+		}
+		driver, err = NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				OSS: &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{Bucket: "my-bucket", UseSDKCreds: true, RAMRole: "my-role"}, Key: "my-key"},
+			},
+		}, fakeResources{})
+		if assert.NoError(t, err) {
+			ossDriver := driver.(*oss.OSSArtifactDriver)
+			assert.Empty(t, ossDriver.AccessKey)
+			assert.True(t, ossDriver.UseSDKCreds)
+			assert.Equal(t, "my-role", ossDriver.RAMRole)
+		}
+	})
+	t.Run("HTTPCASecret", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("my-data"))
+		}))
+		defer server.Close()
+		caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		art := &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				HTTP: &wfv1.HTTPArtifact{
+					URL:      server.URL,
+					CASecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "ca.crt"},
+				},
+			},
+		}
+		driver, err := NewDriver(ctx, art, fakeResources{"my-secret/ca.crt": string(caBundle)})
+		if assert.NoError(t, err) {
+			stream, err := OpenStream(ctx, driver, art)
+			if assert.NoError(t, err) {
+				data, err := ioutil.ReadAll(stream)
+				assert.NoError(t, err)
+				assert.Equal(t, "my-data", string(data))
+				assert.NoError(t, stream.Close())
+			}
+		}
+	})
+	t.Run("ProxyURL", func(t *testing.T) {
+		driver, err := NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				HTTP: &wfv1.HTTPArtifact{URL: "http://example.com/my-file", ProxyURL: "http://proxy.example.com:3128"},
+			},
+		}, fakeResources{})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "proxy.example.com:3128", driver.(*artifactshttp.HTTPArtifactDriver).ProxyURL.Host)
+		}
+		_, err = NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				GCS: &wfv1.GCSArtifact{GCSBucket: wfv1.GCSBucket{Bucket: "my-bucket", ServiceAccountKeySecret: &apiv1.SecretKeySelector{}, ProxyURL: "proxy.example.com:3128"}, Key: "my-key"},
+			},
+		}, fakeResources{})
+		assert.Error(t, err)
+	})
+	t.Run("HTTPAuth", func(t *testing.T) {
+		secret := func(key string) *apiv1.SecretKeySelector {
+			return &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: key}
+		}
+		resources := fakeResources{"my-secret/token": "my-token", "my-secret/username": "my-username", "my-secret/password": "my-password"}
+		driver, err := NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				HTTP: &wfv1.HTTPArtifact{URL: "http://example.com/my-file", BearerTokenSecret: secret("token")},
+			},
+		}, resources)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "my-token", driver.(*artifactshttp.HTTPArtifactDriver).BearerToken)
+		}
+		driver, err = NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				HTTP: &wfv1.HTTPArtifact{URL: "http://example.com/my-file", UsernameSecret: secret("username"), PasswordSecret: secret("password")},
+			},
+		}, resources)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "my-username", driver.(*artifactshttp.HTTPArtifactDriver).Username)
+			assert.Equal(t, "my-password", driver.(*artifactshttp.HTTPArtifactDriver).Password)
+		}
+	})
+	t.Run("GDrive", func(t *testing.T) {
+		driver, err := NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				GDrive: &wfv1.GDriveArtifact{
+					FileID:                  "my-file",
+					ServiceAccountKeySecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "serviceAccountKey"},
+				},
+			},
+		}, fakeResources{"my-secret/serviceAccountKey": "my-key"})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "my-key", driver.(*gdrive.ArtifactDriver).ServiceAccountKey)
+		}
+	})
+	t.Run("WebDAV", func(t *testing.T) {
+		driver, err := NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				WebDAV: &wfv1.WebDAVArtifact{
+					URL:            "https://example.com/dav/my-file",
+					UsernameSecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "username"},
+					PasswordSecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "password"},
+				},
+			},
+		}, fakeResources{"my-secret/username": "my-username", "my-secret/password": "my-password"})
+		if assert.NoError(t, err) {
+			webdavDriver := driver.(*webdav.ArtifactDriver)
+			assert.Equal(t, "my-username", webdavDriver.Username)
+			assert.Equal(t, "my-password", webdavDriver.Password)
+		}
+	})
+	t.Run("FTP", func(t *testing.T) {
+		driver, err := NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				FTP: &wfv1.FTPArtifact{
+					URL:                "ftps://example.com/my-file",
+					UsernameSecret:     &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "username"},
+					PasswordSecret:     &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "password"},
+					ActiveMode:         true,
+					InsecureSkipVerify: true,
+				},
+			},
+		}, fakeResources{"my-secret/username": "my-username", "my-secret/password": "my-password"})
+		if assert.NoError(t, err) {
+			ftpDriver := driver.(*ftp.ArtifactDriver)
+			assert.Equal(t, "my-username", ftpDriver.Username)
+			assert.Equal(t, "my-password", ftpDriver.Password)
+			assert.True(t, ftpDriver.ActiveMode)
+			assert.True(t, ftpDriver.InsecureSkipVerify)
+		}
+	})
+	t.Run("B2", func(t *testing.T) {
+		driver, err := NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				B2: &wfv1.B2Artifact{
+					Bucket:                 "my-bucket",
+					Key:                    "my-key",
+					ApplicationKeyIDSecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "keyID"},
+					ApplicationKeySecret:   &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "key"},
+				},
+			},
+		}, fakeResources{"my-secret/keyID": "my-key-id", "my-secret/key": "my-application-key"})
+		if assert.NoError(t, err) {
+			b2Driver := driver.(*b2.ArtifactDriver)
+			assert.Equal(t, "my-key-id", b2Driver.ApplicationKeyID)
+			assert.Equal(t, "my-application-key", b2Driver.ApplicationKey)
+		}
+	})
+	t.Run("Swift", func(t *testing.T) {
+		driver, err := NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				Swift: &wfv1.SwiftArtifact{
+					AuthURL:        "https://keystone.example.com:5000/v3",
+					Container:      "my-container",
+					Key:            "my-key",
+					UsernameSecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "username"},
+					PasswordSecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "password"},
+					ProjectSecret:  &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "project"},
+					SegmentSize:    "100Mi",
+				},
+			},
+		}, fakeResources{"my-secret/username": "my-user", "my-secret/password": "my-password", "my-secret/project": "my-project"})
+		if assert.NoError(t, err) {
+			swiftDriver := driver.(*swift.ArtifactDriver)
+			assert.Equal(t, "https://keystone.example.com:5000/v3", swiftDriver.AuthURL)
+			assert.Equal(t, "my-user", swiftDriver.Username)
+			assert.Equal(t, "my-password", swiftDriver.Password)
+			assert.Equal(t, "my-project", swiftDriver.ProjectName)
+			assert.Equal(t, int64(100*1024*1024), swiftDriver.SegmentSize)
+		}
+	})
+	t.Run("Rclone", func(t *testing.T) {
+		for name, art := range map[string]*wfv1.RcloneArtifact{
+			"ConfigSecret":    {Remote: "my-remote", Path: "my-path", ConfigSecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "rclone.conf"}},
+			"ConfigConfigMap": {Remote: "my-remote", Path: "my-path", ConfigConfigMap: &apiv1.ConfigMapKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-config-map"}, Key: "rclone.conf"}},
+		} {
+			t.Run(name, func(t *testing.T) {
+				driver, err := NewDriver(ctx, &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{Rclone: art}},
+					fakeResources{"my-secret/rclone.conf": "[my-remote]\ntype = s3\n", "my-config-map/rclone.conf": "[my-remote]\ntype = s3\n"})
+				if assert.NoError(t, err) {
+					assert.Equal(t, "[my-remote]\ntype = s3\n", driver.(*rclone.ArtifactDriver).Config)
+				}
+			})
+		}
+	})
+	t.Run("Dropbox", func(t *testing.T) {
+		driver, err := NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				Dropbox: &wfv1.DropboxArtifact{
+					Path:               "/my-folder/my-file",
+					AppKeySecret:       &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "appKey"},
+					AppSecretSecret:    &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "appSecret"},
+					RefreshTokenSecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "refreshToken"},
+					ChunkSize:          "16Mi",
+				},
+			},
+		}, fakeResources{"my-secret/appKey": "my-app-key", "my-secret/appSecret": "my-app-secret", "my-secret/refreshToken": "my-refresh-token"})
+		if assert.NoError(t, err) {
+			dropboxDriver := driver.(*dropbox.ArtifactDriver)
+			assert.Equal(t, "my-app-key", dropboxDriver.AppKey)
+			assert.Equal(t, "my-app-secret", dropboxDriver.AppSecret)
+			assert.Equal(t, "my-refresh-token", dropboxDriver.RefreshToken)
+			assert.Equal(t, int64(16*1024*1024), dropboxDriver.ChunkSize)
+		}
+	})
+	t.Run("IPFS", func(t *testing.T) {
+		driver, err := NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				IPFS: &wfv1.IPFSArtifact{
+					URL:            "http://ipfs:5001",
+					CID:            "bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi",
+					UsernameSecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "username"},
+					PasswordSecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "password"},
+				},
+			},
+		}, fakeResources{"my-secret/username": "my-username", "my-secret/password": "my-password"})
+		if assert.NoError(t, err) {
+			ipfsDriver := driver.(*ipfs.ArtifactDriver)
+			assert.Equal(t, "my-username", ipfsDriver.Username)
+			assert.Equal(t, "my-password", ipfsDriver.Password)
+			assert.Empty(t, ipfsDriver.BearerToken)
+		}
+	})
+	t.Run("ADLS", func(t *testing.T) {
+		driver, err := NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				ADLS: &wfv1.ADLSArtifact{
+					Endpoint:       "https://myaccount.dfs.core.windows.net",
+					Filesystem:     "my-fs",
+					Path:           "my-dir",
+					SASTokenSecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "sasToken"},
+				},
+			},
+		}, fakeResources{"my-secret/sasToken": "sv=2021-06-08&sig=my-signature"})
+		if assert.NoError(t, err) {
+			adlsDriver := driver.(*adls.ArtifactDriver)
+			assert.Equal(t, "sv=2021-06-08&sig=my-signature", adlsDriver.SASToken)
+			assert.Empty(t, adlsDriver.AccountKey)
+			assert.False(t, adlsDriver.UseWorkloadIdentity)
+		}
+	})
+	t.Run("HuggingFace", func(t *testing.T) {
+		driver, err := NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				HuggingFace: &wfv1.HuggingFaceArtifact{
+					RepoID:      "my-org/my-model",
+					Revision:    "v1.0",
+					TokenSecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "token"},
+				},
+			},
+			BandwidthLimit: "10Mi",
+		}, fakeResources{"my-secret/token": "hf_my-token"})
+		if assert.NoError(t, err) {
+			huggingFaceDriver := driver.(*huggingface.ArtifactDriver)
+			assert.Equal(t, "hf_my-token", huggingFaceDriver.Token)
+			// its transfers are throttled
+			assert.True(t, huggingFaceDriver.HasRoundTripper())
+		}
+	})
+	t.Run("DevNull", func(t *testing.T) {
+		driver, err := NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{DevNull: &wfv1.DevNullArtifact{}},
+		}, fakeResources{})
+		if assert.NoError(t, err) {
+			assert.IsType(t, &devnull.ArtifactDriver{}, driver)
+		}
+	})
+	t.Run("Filesystem", func(t *testing.T) {
+		driver, err := NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{Filesystem: &wfv1.FilesystemArtifact{BasePath: "/mnt/efs", Path: "my-file"}},
+		}, fakeResources{})
+		if assert.NoError(t, err) {
+			assert.IsType(t, &filesystem.ArtifactDriver{}, driver)
+		}
+	})
+	t.Run("OCI", func(t *testing.T) {
+		driver, err := NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				OCI: &wfv1.OCIArtifact{
+					Repository:        "ghcr.io/my-org/my-model",
+					CredentialsSecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: ".dockerconfigjson"},
+				},
+			},
+		}, fakeResources{"my-secret/.dockerconfigjson": `{"auths":{"ghcr.io":{"username":"my-user","password":"my-password"}}}`})
+		if assert.NoError(t, err) {
+			ociDriver := driver.(*oci.ArtifactDriver)
+			assert.Equal(t, `{"auths":{"ghcr.io":{"username":"my-user","password":"my-password"}}}`, ociDriver.DockerConfig)
+		}
+	})
+	t.Run("Encryption", func(t *testing.T) {
+		driver, err := NewDriver(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{Filesystem: &wfv1.FilesystemArtifact{BasePath: "/mnt/efs", Path: "my-file"}},
+			Encryption:       &wfv1.ArtifactEncryption{Scheme: wfv1.ArtifactEncryptionSchemeAge, PrivateKeySecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "private-key"}},
+		}, fakeResources{})
+		if assert.NoError(t, err) {
+			assert.IsType(t, &encryption.ArtifactDriver{}, driver)
+		}
+	})
+}
+
+type fakeDriver struct {
+	ArtifactDriver
+}
+
+func TestRegisterDriver(t *testing.T) {
+	builtin := drivers[DriverRaw]
+	defer RegisterDriver(DriverRaw, builtin)
+	RegisterDriver(DriverRaw, func(context.Context, *wfv1.Artifact, resource.Interface) (ArtifactDriver, error) {
+		return &fakeDriver{}, nil
+	})
+	driver, err := NewDriver(context.Background(), &wfv1.Artifact{
+		ArtifactLocation: wfv1.ArtifactLocation{Raw: &wfv1.RawArtifact{Data: "my-data"}},
+	}, fakeResources{})
+	if assert.NoError(t, err) {
+		assert.IsType(t, &fakeDriver{}, driver)
+	}
+}
+
+type loggingResources struct {
+	fakeResources
+	logger log.FieldLogger
+}
+
+func (r loggingResources) Logger() log.FieldLogger {
+	return r.logger
+}
+
+func TestNewDriver_Logger(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(log.DebugLevel)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("my-data"))
+	}))
+	defer server.Close()
+	art := &wfv1.Artifact{
+		Name: "my-artifact",
+		ArtifactLocation: wfv1.ArtifactLocation{
+			HTTP: &wfv1.HTTPArtifact{
+				URL:               strings.Replace(server.URL, "http://", "http://my-username:my-url-password@", 1) + "/my-file?X-Amz-Signature=my-signature&version=1",
+				BearerTokenSecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "token"},
+			},
+		},
+	}
+	driver, err := NewDriver(context.Background(), art, loggingResources{fakeResources{"my-secret/token": "my-token"}, logger})
+	if !assert.NoError(t, err) {
+		return
+	}
+	dir, err := ioutil.TempDir("", "logger")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	assert.NoError(t, LoadWithProgress(context.Background(), driver, art, filepath.Join(dir, "my-file"), nil))
+
+	var outcome *log.Entry
+	for _, entry := range hook.AllEntries() {
+		assert.Equal(t, DriverHTTP, entry.Data["driver"])
+		output, err := entry.String()
+		assert.NoError(t, err)
+		for _, secret := range []string{"my-url-password", "my-signature", "my-token"} {
+			assert.NotContains(t, output, secret)
+		}
+		if entry.Message == "Load succeeded" {
+			outcome = entry
+		}
+	}
+	if assert.NotNil(t, outcome) {
+		assert.Equal(t, log.DebugLevel, outcome.Level)
+		assert.Equal(t, int64(len("my-data")), outcome.Data["bytes"])
+		assert.Equal(t, "my-artifact", outcome.Data["artifact"])
+	}
+}
+
+// roundTripperFunc is a RoundTripper which sends requests with the function
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestSetRoundTripperFactory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"my-etag"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		_, _ = w.Write([]byte("my-data"))
+	}))
+	defer server.Close()
+	// the factory records the driver, method and path of the requests it sends
+	var lock sync.Mutex
+	var requests []string
+	SetRoundTripperFactory(func(driver string, base http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			lock.Lock()
+			requests = append(requests, driver+" "+r.Method+" "+r.URL.Path)
+			lock.Unlock()
+			return base.RoundTrip(r)
+		})
+	})
+	defer SetRoundTripperFactory(nil)
+	ctx := context.Background()
+	secret := func(key string) *apiv1.SecretKeySelector {
+		return &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: key}
+	}
+	ri := fakeResources{"my-secret/username": "my-username", "my-secret/password": "my-password"}
+	load := func(t *testing.T, art *wfv1.Artifact) {
+		driver, err := NewDriver(ctx, art, ri)
+		if !assert.NoError(t, err) {
+			return
+		}
+		dir, err := ioutil.TempDir("", "transport")
+		assert.NoError(t, err)
+		defer func() { _ = os.RemoveAll(dir) }()
+		assert.NoError(t, driver.Load(ctx, art, filepath.Join(dir, "my-file")))
+	}
+	t.Run("HTTP", func(t *testing.T) {
+		requests = nil
+		load(t, &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL + "/my-file"}}})
+		assert.Equal(t, []string{"http GET /my-file"}, requests)
+	})
+	t.Run("Artifactory", func(t *testing.T) {
+		requests = nil
+		load(t, &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{Artifactory: &wfv1.ArtifactoryArtifact{URL: server.URL + "/my-file", ArtifactoryAuth: wfv1.ArtifactoryAuth{UsernameSecret: secret("username"), PasswordSecret: secret("password")}}}})
+		assert.Equal(t, []string{"artifactory GET /my-file"}, requests)
+	})
+	t.Run("S3", func(t *testing.T) {
+		requests = nil
+		insecure := true
+		art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{
+			S3Bucket: wfv1.S3Bucket{Endpoint: strings.TrimPrefix(server.URL, "http://"), Region: "us-east-1", Bucket: "my-bucket", Insecure: &insecure, AccessKeySecret: secret("username"), SecretKeySecret: secret("password")},
+			Key:      "my-key",
+		}}}
+		driver, err := NewDriver(ctx, art, ri)
+		if !assert.NoError(t, err) {
+			return
+		}
+		_, err = driver.(ArtifactExistenceChecker).Exists(art)
+		assert.NoError(t, err)
+		assert.Contains(t, requests, "s3 HEAD /my-bucket/my-key")
+	})
+	t.Run("Unset", func(t *testing.T) {
+		SetRoundTripperFactory(nil)
+		requests = nil
+		load(t, &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL + "/my-file"}}})
+		assert.Empty(t, requests)
+	})
+}
+
+func TestNewDriver_BandwidthLimit(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 200)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "bandwidth")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	t.Run("Limited", func(t *testing.T) {
+		art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL + "/my-file"}}, BandwidthLimit: "4Ki"}
+		driver, err := NewDriver(ctx, art, fakeResources{})
+		if !assert.NoError(t, err) {
+			return
+		}
+		start := time.Now()
+		path := filepath.Join(dir, "limited")
+		if assert.NoError(t, driver.Load(ctx, art, path)) {
+			// 2000 bytes at 4096 bytes per second
+			assert.GreaterOrEqual(t, int64(time.Since(start)), int64(450*time.Millisecond))
+			loaded, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, data, loaded)
+		}
+	})
+	t.Run("Default", func(t *testing.T) {
+		common.DefaultBandwidthLimit = 4096
+		defer func() { common.DefaultBandwidthLimit = 0 }()
+		art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL + "/my-file"}}}
+		driver, err := NewDriver(ctx, art, fakeResources{})
+		if !assert.NoError(t, err) {
+			return
+		}
+		start := time.Now()
+		if assert.NoError(t, driver.Load(ctx, art, filepath.Join(dir, "default"))) {
+			assert.GreaterOrEqual(t, int64(time.Since(start)), int64(450*time.Millisecond))
+		}
+		// drivers which cannot be throttled are not, rather than failing
+		_, err = NewDriver(ctx, &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{Raw: &wfv1.RawArtifact{Data: "my-data"}}}, fakeResources{})
+		assert.NoError(t, err)
+	})
+	t.Run("Unsupported", func(t *testing.T) {
+		_, err := NewDriver(ctx, &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{Raw: &wfv1.RawArtifact{Data: "my-data"}}, BandwidthLimit: "4Ki"}, fakeResources{})
+		assert.EqualError(t, err, "bandwidth limit of raw artifacts is not supported")
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := NewDriver(ctx, &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL}}, BandwidthLimit: "fast"}, fakeResources{})
+		assert.Error(t, err)
+	})
+}
+
+func TestNewDriver_CircuitBreakers(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+	common.DefaultCircuitBreakers = common.NewCircuitBreakers(common.CircuitBreakerConfig{FailureRate: 1, MinRequests: 2, Cooldown: time.Hour})
+	defer func() { common.DefaultCircuitBreakers = nil }()
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "breakers")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL + "/my-file", Retry: &wfv1.HTTPRetry{BaseDelay: "1ms"}}}}
+	// the breaker opens on the first retry of the first load, which is then not retried, and is shared by the drivers
+	for i := 0; i < 3; i++ {
+		driver, err := NewDriver(ctx, art, fakeResources{})
+		if assert.NoError(t, err) {
+			err = driver.Load(ctx, art, filepath.Join(dir, "my-file"))
+			assert.True(t, stderrors.Is(err, common.ErrCircuitOpen), err)
+		}
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	assert.Equal(t, map[string]common.CircuitState{server.URL: common.CircuitOpen}, common.DefaultCircuitBreakers.States())
+}
+
+func TestNewDriver_ExpandEnv(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	assert.NoError(t, os.Setenv("ARGO_TEST_WORKSPACE", "workspaces/1"))
+	defer func() { _ = os.Unsetenv("ARGO_TEST_WORKSPACE") }()
+	t.Run("Expanded", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "expand-env")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer func() { _ = os.RemoveAll(dir) }()
+		art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL + "/${ARGO_TEST_WORKSPACE}/output"}}, ExpandEnv: true}
+		driver, err := NewDriver(ctx, art, fakeResources{})
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, server.URL+"/workspaces/1/output", art.HTTP.URL)
+		path := filepath.Join(dir, "output")
+		if assert.NoError(t, driver.Load(ctx, art, path)) {
+			loaded, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, "/workspaces/1/output", string(loaded))
+		}
+	})
+	t.Run("NotExpanded", func(t *testing.T) {
+		art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL + "/${ARGO_TEST_WORKSPACE}/output"}}}
+		_, err := NewDriver(ctx, art, fakeResources{})
+		assert.NoError(t, err)
+		assert.Equal(t, server.URL+"/${ARGO_TEST_WORKSPACE}/output", art.HTTP.URL)
+	})
+	t.Run("Undefined", func(t *testing.T) {
+		art := &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket", AccessKeySecret: &apiv1.SecretKeySelector{}}, Key: "${ARGO_TEST_UNDEFINED}/output"}}, ExpandEnv: true}
+		_, err := NewDriver(ctx, art, fakeResources{})
+		assert.EqualError(t, err, `key "${ARGO_TEST_UNDEFINED}/output" of artifact my-art refers to undefined environment variables: ARGO_TEST_UNDEFINED`)
+	})
+}
+
+func TestNewDriver_Secrets(t *testing.T) {
+	ctx := context.Background()
+	selector := func(key string) *apiv1.SecretKeySelector {
+		return &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: key}
+	}
+	secrets := map[string]string{
+		"my-secret/accesskey":     "my-access-key",
+		"my-secret/secretkey":     "my-secret-key",
+		"my-secret/username":      "my-username",
+		"my-secret/password":      "my-password",
+		"my-secret/sshPrivateKey": "my-ssh-private-key",
+	}
+	newS3Artifact := func(accessKeySecret, secretKeySecret *apiv1.SecretKeySelector) *wfv1.Artifact {
+		return &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				S3: &wfv1.S3Artifact{
+					S3Bucket: wfv1.S3Bucket{Endpoint: "my-endpoint", Bucket: "my-bucket", AccessKeySecret: accessKeySecret, SecretKeySecret: secretKeySecret},
+					Key:      "my-key",
+				},
+			},
+		}
+	}
+	t.Run("S3", func(t *testing.T) {
+		ri := resourcetest.NewFakeInterface(secrets)
+		driver, err := NewDriver(ctx, newS3Artifact(selector("accesskey"), selector("secretkey")), ri)
+		if assert.NoError(t, err) {
+			s3Driver := driver.(*s3.S3ArtifactDriver)
+			assert.Equal(t, "my-access-key", s3Driver.AccessKey)
+			assert.Equal(t, "my-secret-key", s3Driver.SecretKey)
+		}
+		assert.Equal(t, []string{"my-secret/accesskey", "my-secret/secretkey"}, ri.SecretLookups())
+	})
+	t.Run("S3WithoutSecrets", func(t *testing.T) {
+		ri := resourcetest.NewFakeInterface(secrets)
+		driver, err := NewDriver(ctx, newS3Artifact(nil, nil), ri)
+		if assert.NoError(t, err) {
+			assert.Empty(t, driver.(*s3.S3ArtifactDriver).AccessKey)
+		}
+		assert.Empty(t, ri.Lookups())
+	})
+	t.Run("S3MissingSecretKey", func(t *testing.T) {
+		ri := resourcetest.NewFakeInterface(secrets)
+		_, err := NewDriver(ctx, newS3Artifact(selector("accesskey"), selector("missing")), ri)
+		assert.True(t, errors.IsCode(errors.CodeNotFound, err), err)
+	})
+	t.Run("S3Err", func(t *testing.T) {
+		ri := &resourcetest.FakeInterface{Err: stderrors.New("forbidden")}
+		_, err := NewDriver(ctx, newS3Artifact(selector("accesskey"), selector("secretkey")), ri)
+		assert.EqualError(t, err, "forbidden")
+		// the secret key is not looked up once the access key cannot be
+		assert.Equal(t, []string{"my-secret/accesskey"}, ri.SecretLookups())
+	})
+	newGitArtifact := func(usernameSecret, passwordSecret, sshPrivateKeySecret *apiv1.SecretKeySelector) *wfv1.Artifact {
+		return &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				Git: &wfv1.GitArtifact{
+					Repo:                "https://github.com/argoproj/argo-workflows.git",
+					UsernameSecret:      usernameSecret,
+					PasswordSecret:      passwordSecret,
+					SSHPrivateKeySecret: sshPrivateKeySecret,
+				},
+			},
+		}
+	}
+	t.Run("GitBasicAuth", func(t *testing.T) {
+		ri := resourcetest.NewFakeInterface(secrets)
+		driver, err := NewDriver(ctx, newGitArtifact(selector("username"), selector("password"), nil), ri)
+		if assert.NoError(t, err) {
+			gitDriver := driver.(*git.GitArtifactDriver)
+			assert.Equal(t, "my-username", gitDriver.Username)
+			assert.Equal(t, "my-password", gitDriver.Password)
+			assert.Empty(t, gitDriver.SSHPrivateKey)
+		}
+		assert.Equal(t, []string{"my-secret/username", "my-secret/password"}, ri.SecretLookups())
+	})
+	t.Run("GitSSH", func(t *testing.T) {
+		ri := resourcetest.NewFakeInterface(secrets)
+		driver, err := NewDriver(ctx, newGitArtifact(nil, nil, selector("sshPrivateKey")), ri)
+		if assert.NoError(t, err) {
+			gitDriver := driver.(*git.GitArtifactDriver)
+			assert.Equal(t, "my-ssh-private-key", gitDriver.SSHPrivateKey)
+			assert.Empty(t, gitDriver.Username)
+		}
+		assert.Equal(t, []string{"my-secret/sshPrivateKey"}, ri.SecretLookups())
+	})
+	t.Run("GitMissingPassword", func(t *testing.T) {
+		ri := resourcetest.NewFakeInterface(secrets)
+		_, err := NewDriver(ctx, newGitArtifact(selector("username"), selector("missing"), nil), ri)
+		assert.True(t, errors.IsCode(errors.CodeNotFound, err), err)
+	})
+}
+
+func TestNewDriver_Unsupported(t *testing.T) {
+	_, err := NewDriver(context.Background(), &wfv1.Artifact{}, fakeResources{})
+	assert.Equal(t, ErrUnsupportedDriver, err)
+}
+
+type verifyingDriver struct {
+	ArtifactDriver
+	verified bool
+}
+
+func (d *verifyingDriver) VerifyChecksum(*wfv1.Artifact, string) error {
+	d.verified = true
+	return nil
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "artifact")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	_, err = tmp.WriteString("my-data")
+	assert.NoError(t, err)
+	assert.NoError(t, tmp.Close())
+	// sha256 of "my-data"
+	checksum := &wfv1.ArtifactChecksum{Algorithm: wfv1.ChecksumAlgorithmSHA256, Value: "c0b8114a809d94b548e3f098b4b76b1589e8ea6297dc795b1377df2c99055385"}
+	t.Run("NoChecksum", func(t *testing.T) {
+		assert.NoError(t, VerifyChecksum(&loadOnlyDriver{}, &wfv1.Artifact{}, tmp.Name()))
+	})
+	t.Run("Verifier", func(t *testing.T) {
+		driver := &verifyingDriver{}
+		assert.NoError(t, VerifyChecksum(driver, &wfv1.Artifact{Checksum: checksum}, tmp.Name()))
+		assert.True(t, driver.verified)
+	})
+	t.Run("Fallback", func(t *testing.T) {
+		assert.NoError(t, VerifyChecksum(&loadOnlyDriver{}, &wfv1.Artifact{Checksum: checksum}, tmp.Name()))
+	})
+	t.Run("FallbackMismatch", func(t *testing.T) {
+		mismatch := &wfv1.ArtifactChecksum{Algorithm: wfv1.ChecksumAlgorithmMD5, Value: "5d41402abc4b2a76b9719d911017c592"}
+		assert.Error(t, VerifyChecksum(&loadOnlyDriver{}, &wfv1.Artifact{Checksum: mismatch}, tmp.Name()))
+	})
+}
+
+type existsDriver struct {
+	ArtifactDriver
+}
+
+func (d *existsDriver) Exists(*wfv1.Artifact) (bool, error) {
+	return true, nil
+}
+
+func TestExists(t *testing.T) {
+	ok, err := Exists(&existsDriver{}, &wfv1.Artifact{})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	_, err = Exists(&loadOnlyDriver{}, &wfv1.Artifact{})
+	assert.Equal(t, ErrExistsNotSupported, err)
+}
+
+func TestExistsBatch(t *testing.T) {
+	result, err := ExistsBatch(&existsDriver{}, []*wfv1.Artifact{{}, {}})
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, true}, result)
+	_, err = ExistsBatch(&loadOnlyDriver{}, []*wfv1.Artifact{{}})
+	assert.Equal(t, ErrExistsNotSupported, err)
+}
+
+// healthCheckDriver is healthy unless it has an error
+type healthCheckDriver struct {
+	ArtifactDriver
+	err error
+}
+
+func (d *healthCheckDriver) HealthCheck(context.Context) error {
+	return d.err
+}
+
+func TestHealthCheck(t *testing.T) {
+	ctx := context.Background()
+	art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "my-key"}}}
+	assert.NoError(t, HealthCheck(ctx, &healthCheckDriver{}, art))
+	err := HealthCheck(ctx, &healthCheckDriver{err: errors.New(errors.CodeUnauthorized, "Access Denied")}, art)
+	assert.EqualError(t, err, "s3 check the health of the storage of my-key: Access Denied")
+	assert.True(t, errors.IsCode(errors.CodeUnauthorized, err))
+	assert.Equal(t, ErrHealthCheckNotSupported, HealthCheck(ctx, &loadOnlyDriver{}, art))
+}
+
+// presignDriver presigns URLs which are the method and key they are of
+type presignDriver struct {
+	ArtifactDriver
+}
+
+func (d *presignDriver) Presign(artifact *wfv1.Artifact, method string, _ time.Duration) (string, error) {
+	return method + " " + artifact.S3.Key, nil
+}
+
+func TestPresign(t *testing.T) {
+	art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "my-dir"}}}
+	u, err := Presign(&presignDriver{}, art, "", http.MethodGet, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, "GET my-dir", u)
+	// an object of a directory is that under its key
+	u, err = Presign(&presignDriver{}, art, "sub/my-file", http.MethodPut, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, "PUT my-dir/sub/my-file", u)
+	assert.Equal(t, "my-dir", art.S3.Key)
+	_, err = Presign(&loadOnlyDriver{}, art, "", http.MethodGet, time.Hour)
+	assert.Equal(t, ErrPresignNotSupported, err)
+}
+
+func (d *presignDriver) PresignPostPolicy(artifact *wfv1.Artifact, policy common.PostPolicy) (*common.PresignedPost, error) {
+	if err := common.ValidatePostPolicy(artifact.S3.Key, policy); err != nil {
+		return nil, err
+	}
+	return &common.PresignedPost{URL: "POST " + artifact.S3.Key}, nil
+}
+
+func TestPresignPostPolicy(t *testing.T) {
+	art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "uploads/"}}}
+	post, err := PresignPostPolicy(&presignDriver{}, art, common.PostPolicy{MaxSize: 1024, Expiry: time.Hour})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "POST uploads/", post.URL)
+	}
+	_, err = PresignPostPolicy(&presignDriver{}, art, common.PostPolicy{MaxSize: 1024})
+	var driverErr *DriverError
+	if assert.True(t, stderrors.As(err, &driverErr), err) {
+		assert.Equal(t, OperationPresignPost, driverErr.Operation)
+	}
+	_, err = PresignPostPolicy(&loadOnlyDriver{}, art, common.PostPolicy{MaxSize: 1024, Expiry: time.Hour})
+	assert.Equal(t, ErrPostPolicyNotSupported, err)
+}
+
+type dryRunDriver struct {
+	ArtifactDriver
+}
+
+func (d *dryRunDriver) DryRunSave(_ context.Context, path string, _ *wfv1.Artifact) (*common.DryRunResult, error) {
+	return &common.DryRunResult{Location: "my-location", Objects: []common.DryRunObject{{Key: "my-key", Path: path}}}, nil
+}
+
+func TestDryRunSave(t *testing.T) {
+	result, err := DryRunSave(context.Background(), &dryRunDriver{}, "my-path", &wfv1.Artifact{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "my-location", result.Location)
+		assert.Equal(t, []common.DryRunObject{{Key: "my-key", Path: "my-path"}}, result.Objects)
+	}
+	_, err = DryRunSave(context.Background(), &loadOnlyDriver{}, "my-path", &wfv1.Artifact{})
+	assert.Equal(t, ErrDryRunNotSupported, err)
+}
+
+// resultDriver is a memory driver which reports the objects it saves with the ETag "my-etag"
+type resultDriver struct {
+	*memory.ArtifactDriver
+	paths []string
+}
+
+func (d *resultDriver) SaveWithResult(ctx context.Context, path string, outputArtifact *wfv1.Artifact, encoding string, progress common.ProgressFunc) (*common.SaveResult, error) {
+	if err := d.SaveWithContentEncoding(ctx, path, outputArtifact, encoding, progress); err != nil {
+		return nil, err
+	}
+	d.paths = append(d.paths, path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return common.NewSaveResult("memory://my-key", []common.SavedObject{{Key: "my-key", Path: path, Size: info.Size(), ETag: "my-etag"}}), nil
+}
+
+func TestSaveWithResult(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "artifact")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	ctx := context.Background()
+	data := []byte(strings.Repeat("my-text-heavy-output\n", 1000))
+	src := filepath.Join(tmp, "src")
+	assert.NoError(t, ioutil.WriteFile(src, data, 0600))
+	newArtifact := func(compression wfv1.ArtifactCompression) *wfv1.Artifact {
+		return &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "my-key"}}, Compression: compression}
+	}
+	t.Run("Reported", func(t *testing.T) {
+		driver := &resultDriver{ArtifactDriver: memory.NewArtifactDriver()}
+		result, err := SaveWithResult(ctx, driver, src, newArtifact(""), nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, &common.SaveResult{
+				Location: "memory://my-key",
+				Objects:  []common.SavedObject{{Key: "my-key", Path: src, Size: int64(len(data)), ETag: "my-etag"}},
+				Size:     int64(len(data)),
+			}, result)
+		}
+	})
+	t.Run("Compressed", func(t *testing.T) {
+		driver := &resultDriver{ArtifactDriver: memory.NewArtifactDriver()}
+		result, err := SaveWithResult(ctx, driver, src, newArtifact(wfv1.ArtifactCompressionGzip), nil)
+		if assert.NoError(t, err) && assert.Len(t, result.Objects, 1) {
+			// the object is of the file, and the size is that of the object, which was compressed
+			assert.Equal(t, src, result.Objects[0].Path)
+			assert.NotEqual(t, src, driver.paths[0])
+			assert.Less(t, result.Size, int64(len(data)))
+			encoding, err := driver.ContentEncoding(newArtifact(""))
+			assert.NoError(t, err)
+			assert.Equal(t, string(wfv1.ArtifactCompressionGzip), encoding)
+		}
+	})
+	t.Run("NotReported", func(t *testing.T) {
+		driver := memory.NewArtifactDriver()
+		result, err := SaveWithResult(ctx, driver, src, newArtifact(""), nil)
+		assert.NoError(t, err)
+		assert.Nil(t, result)
+		_, ok := driver.Content("memory://my-key")
+		assert.True(t, ok)
+	})
+}
+
+func TestLoadWithProgress(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "artifact")
+	if !assert.NoError(t, err) {
+		return
+	}
+	_ = tmp.Close()
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	// drivers which cannot report progress still load the artifact
+	err = LoadWithProgress(context.Background(), &loadOnlyDriver{data: []byte("my-data")}, &wfv1.Artifact{}, tmp.Name(), func(int64, int64) {
+		assert.Fail(t, "unexpected progress")
+	})
+	if assert.NoError(t, err) {
+		data, err := ioutil.ReadFile(tmp.Name())
+		assert.NoError(t, err)
+		assert.Equal(t, "my-data", string(data))
+	}
+}
+
+func TestCompression(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "artifact")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	ctx := context.Background()
+	data := []byte(strings.Repeat("my-text-heavy-output\n", 1000))
+	src := filepath.Join(tmp, "src")
+	assert.NoError(t, ioutil.WriteFile(src, data, 0600))
+	newArtifact := func(compression wfv1.ArtifactCompression) *wfv1.Artifact {
+		return &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "my-key"}}, Compression: compression}
+	}
+	for _, compression := range []wfv1.ArtifactCompression{wfv1.ArtifactCompressionGzip, wfv1.ArtifactCompressionZstd} {
+		t.Run(string(compression), func(t *testing.T) {
+			driver := memory.NewArtifactDriver()
+			art := newArtifact(compression)
+			assert.NoError(t, SaveWithProgress(ctx, driver, src, art, nil))
+			stored, _ := driver.Content("memory://my-key")
+			assert.Less(t, len(stored), len(data))
+			encoding, err := driver.ContentEncoding(art)
+			assert.NoError(t, err)
+			assert.Equal(t, string(compression), encoding)
+			// the input artifact does not need to have the compression
+			dest := filepath.Join(tmp, "dest-"+string(compression))
+			if assert.NoError(t, LoadWithProgress(ctx, driver, newArtifact(""), dest, nil)) {
+				loaded, err := ioutil.ReadFile(dest)
+				assert.NoError(t, err)
+				assert.Equal(t, data, loaded)
+			}
+			stream, err := OpenStream(ctx, driver, newArtifact(""))
+			if assert.NoError(t, err) {
+				streamed, err := ioutil.ReadAll(stream)
+				assert.NoError(t, err)
+				assert.Equal(t, data, streamed)
+				assert.NoError(t, stream.Close())
+			}
+		})
+	}
+	t.Run("None", func(t *testing.T) {
+		driver := memory.NewArtifactDriver()
+		assert.NoError(t, SaveWithProgress(ctx, driver, src, newArtifact(wfv1.ArtifactCompressionNone), nil))
+		stored, _ := driver.Content("memory://my-key")
+		assert.Equal(t, data, stored)
+		encoding, err := driver.ContentEncoding(newArtifact(""))
+		assert.NoError(t, err)
+		assert.Empty(t, encoding)
+	})
+	t.Run("Directory", func(t *testing.T) {
+		dir := filepath.Join(tmp, "src-dir")
+		assert.NoError(t, os.MkdirAll(dir, 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), data, 0600))
+		driver := memory.NewArtifactDriver()
+		assert.NoError(t, SaveWithProgress(ctx, driver, dir, newArtifact(wfv1.ArtifactCompressionGzip), nil))
+		stored, _ := driver.Content("memory://my-key/a")
+		assert.Equal(t, data, stored)
+	})
+	t.Run("Unsupported", func(t *testing.T) {
+		err := SaveWithProgress(ctx, &loadOnlyDriver{}, src, newArtifact(wfv1.ArtifactCompressionGzip), nil)
+		assert.EqualError(t, err, "compression of s3 artifacts is not supported")
+	})
+}
+
+// existingDriver is an OverwriteProtector whose destinations all exist
+type existingDriver struct {
+	ArtifactDriver
+	saved bool
+}
+
+func (d *existingDriver) ProtectsOverwrite() bool {
+	return true
+}
+
+func (d *existingDriver) Save(_ context.Context, _ string, outputArtifact *wfv1.Artifact) error {
+	if common.IsOverwriteProtected(outputArtifact.Overwrite) {
+		return common.NewExistsError("memory://my-key")
+	}
+	d.saved = true
+	return nil
+}
+
+func TestOverwrite(t *testing.T) {
+	ctx := context.Background()
+	newArtifact := func(overwrite wfv1.ArtifactOverwrite) *wfv1.Artifact {
+		return &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "my-key"}}, Overwrite: overwrite}
+	}
+	t.Run("Allow", func(t *testing.T) {
+		driver := &existingDriver{}
+		assert.NoError(t, SaveWithProgress(ctx, driver, "my-path", newArtifact(wfv1.ArtifactOverwriteAllow), nil))
+		assert.True(t, driver.saved)
+	})
+	t.Run("FailIfExists", func(t *testing.T) {
+		err := SaveWithProgress(ctx, &existingDriver{}, "my-path", newArtifact(wfv1.ArtifactOverwriteFailIfExists), nil)
+		assert.True(t, stderrors.Is(err, common.ErrArtifactExists))
+		assert.True(t, errors.IsCode(errors.CodeBadRequest, err))
+	})
+	t.Run("SkipIfExists", func(t *testing.T) {
+		driver := &existingDriver{}
+		assert.NoError(t, SaveWithProgress(ctx, driver, "my-path", newArtifact(wfv1.ArtifactOverwriteSkipIfExists), nil))
+		assert.False(t, driver.saved)
+	})
+	t.Run("Unsupported", func(t *testing.T) {
+		// the driver could not save the artifact without replacing what exists
+		err := SaveWithProgress(ctx, memory.NewArtifactDriver(), "my-path", newArtifact(wfv1.ArtifactOverwriteFailIfExists), nil)
+		assert.EqualError(t, err, "overwrite fail-if-exists of s3 artifacts is not supported")
+		err = SaveWithProgress(ctx, &existingDriver{}, "my-path", newArtifact(wfv1.ArtifactOverwriteSkipIfUnchanged), nil)
+		assert.EqualError(t, err, "overwrite skip-if-unchanged of s3 artifacts is not supported")
+	})
+	t.Run("SkipIfUnchanged", func(t *testing.T) {
+		result, err := SaveWithResult(ctx, &unchangedDriver{}, "my-path", newArtifact(wfv1.ArtifactOverwriteSkipIfUnchanged), nil)
+		if assert.NoError(t, err) {
+			assert.True(t, result.Unchanged)
+			assert.Empty(t, result.Objects)
+		}
+	})
+}
+
+// unchangedDriver is an UnchangedSkipper whose destinations all have the content of what is saved
+type unchangedDriver struct {
+	ArtifactDriver
+}
+
+func (d *unchangedDriver) SkipsUnchanged() bool {
+	return true
+}
+
+func (d *unchangedDriver) SaveWithResult(_ context.Context, _ string, _ *wfv1.Artifact, _ string, _ common.ProgressFunc) (*common.SaveResult, error) {
+	return common.NewUnchangedResult("memory://my-key"), nil
+}
+
+// atomicDriver is an AtomicSaver
+type atomicDriver struct {
+	ArtifactDriver
+	saved bool
+}
+
+func (d *atomicDriver) SavesAtomically() bool {
+	return true
+}
+
+func (d *atomicDriver) Save(context.Context, string, *wfv1.Artifact) error {
+	d.saved = true
+	return nil
+}
+
+func TestAtomicSave(t *testing.T) {
+	ctx := context.Background()
+	art := &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "my-key"}}, AtomicSave: true}
+	driver := &atomicDriver{}
+	assert.NoError(t, SaveWithProgress(ctx, driver, "my-path", art, nil))
+	assert.True(t, driver.saved)
+	// the driver could not save a directory without its objects being read before every file is saved
+	err := SaveWithProgress(ctx, memory.NewArtifactDriver(), "my-path", art, nil)
+	assert.EqualError(t, err, "atomic save of s3 artifacts is not supported")
+}
+
+func TestMaxSize(t *testing.T) {
+	ctx := context.Background()
+	tmp, err := ioutil.TempDir("", "artifact")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	path := filepath.Join(tmp, "my-file")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("0123456789"), 0600))
+	newArtifact := func(maxSize string) *wfv1.Artifact {
+		return &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "my-key"}}, MaxSize: maxSize}
+	}
+
+	t.Run("Save", func(t *testing.T) {
+		driver := memory.NewArtifactDriver()
+		err := SaveWithProgress(ctx, driver, path, newArtifact("9"), nil)
+		assert.True(t, stderrors.Is(err, common.ErrArtifactTooLarge))
+		// the artifact is not saved
+		assert.Error(t, driver.Load(ctx, newArtifact(""), filepath.Join(tmp, "not-saved")))
+		assert.NoError(t, SaveWithProgress(ctx, driver, path, newArtifact("10"), nil))
+	})
+	t.Run("Default", func(t *testing.T) {
+		common.DefaultMaxSize = 9
+		defer func() { common.DefaultMaxSize = 0 }()
+		err := SaveWithProgress(ctx, memory.NewArtifactDriver(), path, newArtifact(""), nil)
+		assert.True(t, stderrors.Is(err, common.ErrArtifactTooLarge))
+		// drivers which cannot limit the size of loads are not limited by the default, rather than failing
+		assert.NoError(t, LoadWithProgress(ctx, &loadOnlyDriver{data: []byte("0123456789")}, newArtifact(""), filepath.Join(tmp, "default"), nil))
+	})
+	t.Run("Unsupported", func(t *testing.T) {
+		err := LoadWithProgress(ctx, &loadOnlyDriver{data: []byte("0123456789")}, newArtifact("10"), filepath.Join(tmp, "unsupported"), nil)
+		assert.EqualError(t, err, "max size of loading s3 artifacts is not supported")
+	})
+}
+
+func TestMetadata(t *testing.T) {
+	ctx := context.Background()
+	art := &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "my-key"}}, Metadata: map[string]string{"git-commit": "abc123"}}
+	err := SaveWithProgress(ctx, &loadOnlyDriver{}, "my-path", art, nil)
+	assert.EqualError(t, err, "metadata of s3 artifacts is not supported")
+	_, err = Metadata(&loadOnlyDriver{}, art)
+	assert.Equal(t, ErrMetadataNotSupported, err)
+}
+
+// countingDriver counts the artifacts it loads
+type countingDriver struct {
+	loadOnlyDriver
+	loads int32
+}
+
+func (d *countingDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, path string) error {
+	atomic.AddInt32(&d.loads, 1)
+	return d.loadOnlyDriver.Load(ctx, inputArtifact, path)
+}
+
+func TestLoadCached(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "artifact")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	ctx := context.Background()
+	art := &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "my-key"}}}
+	t.Run("Cached", func(t *testing.T) {
+		c, err := cache.New(filepath.Join(tmp, "cache"), cache.DefaultMaxSize)
+		if !assert.NoError(t, err) {
+			return
+		}
+		driver := &countingDriver{loadOnlyDriver: loadOnlyDriver{data: []byte("my-data")}}
+		assert.NoError(t, LoadCached(ctx, c, driver, art, filepath.Join(tmp, "first"), nil))
+		// the second load does not call the driver
+		second := filepath.Join(tmp, "second")
+		if assert.NoError(t, LoadCached(ctx, c, driver, art, second, nil)) {
+			data, err := ioutil.ReadFile(second)
+			assert.NoError(t, err)
+			assert.Equal(t, "my-data", string(data))
+		}
+		assert.Equal(t, int32(1), driver.loads)
+	})
+	t.Run("ChecksumMismatch", func(t *testing.T) {
+		c, err := cache.New(filepath.Join(tmp, "mismatch-cache"), cache.DefaultMaxSize)
+		if !assert.NoError(t, err) {
+			return
+		}
+		mismatch := art.DeepCopy()
+		mismatch.Checksum = &wfv1.ArtifactChecksum{Algorithm: wfv1.ChecksumAlgorithmMD5, Value: "5d41402abc4b2a76b9719d911017c592"}
+		driver := &countingDriver{loadOnlyDriver: loadOnlyDriver{data: []byte("my-data")}}
+		path := filepath.Join(tmp, "mismatch")
+		assert.Error(t, LoadCached(ctx, c, driver, mismatch, path, nil))
+		// an artifact which fails verification is neither left at its path nor cached
+		_, err = os.Stat(path)
+		assert.True(t, os.IsNotExist(err))
+		assert.Error(t, LoadCached(ctx, c, driver, mismatch, path, nil))
+		assert.Equal(t, int32(2), driver.loads)
+	})
+	t.Run("NoCache", func(t *testing.T) {
+		driver := &countingDriver{loadOnlyDriver: loadOnlyDriver{data: []byte("my-data")}}
+		assert.NoError(t, LoadCached(ctx, nil, driver, art, filepath.Join(tmp, "uncached"), nil))
+		assert.NoError(t, LoadCached(ctx, nil, driver, art, filepath.Join(tmp, "uncached"), nil))
+		assert.Equal(t, int32(2), driver.loads)
+	})
+}
+
+func TestLoadRange(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "artifact")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	art := &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "my-key"}}}
+	path := filepath.Join(tmp, "range")
+	driver := memory.NewArtifactDriver()
+	driver.Seed("memory://my-key", []byte("0123456789"))
+	t.Run("Range", func(t *testing.T) {
+		if assert.NoError(t, LoadRange(driver, art, 2, 3, path)) {
+			data, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, "234", string(data))
+		}
+	})
+	t.Run("ToEnd", func(t *testing.T) {
+		if assert.NoError(t, LoadRange(driver, art, 7, -1, path)) {
+			data, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, "789", string(data))
+		}
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		assert.EqualError(t, LoadRange(driver, art, -1, 3, path), "the range of artifact my-art cannot start at the negative offset -1")
+		assert.EqualError(t, LoadRange(driver, art, 0, 0, path), "the range of artifact my-art cannot be empty")
+	})
+	t.Run("Compressed", func(t *testing.T) {
+		driver := memory.NewArtifactDriver()
+		src := filepath.Join(tmp, "src")
+		assert.NoError(t, ioutil.WriteFile(src, []byte("0123456789"), 0600))
+		assert.NoError(t, driver.SaveWithContentEncoding(context.Background(), src, art, "gzip", nil))
+		assert.EqualError(t, LoadRange(driver, art, 0, 3, path), "a range of artifact my-art cannot be loaded, as it is gzip compressed")
+	})
+	t.Run("NotSupported", func(t *testing.T) {
+		assert.Equal(t, ErrLoadRangeNotSupported, LoadRange(&loadOnlyDriver{}, art, 0, 3, path))
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "artifact")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	ctx := context.Background()
+	t.Run("HTTP", func(t *testing.T) {
+		// the server hangs until the client gives up
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(10 * time.Second):
+			}
+		}))
+		defer server.Close()
+		noRetry := int32(0)
+		art := &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL, Retry: &wfv1.HTTPRetry{Limit: &noRetry}}}, Timeout: "100ms"}
+		driver, err := NewDriver(ctx, art, fakeResources{})
+		if !assert.NoError(t, err) {
+			return
+		}
+		start := time.Now()
+		err = LoadWithProgress(ctx, driver, art, filepath.Join(tmp, "http"), nil)
+		assert.True(t, errors.IsCode(errors.CodeTimeout, err), "%v", err)
+		assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+		// the client times out, as streams are not given a context
+		_, err = driver.(*artifactshttp.HTTPArtifactDriver).OpenStream(art)
+		assert.Error(t, err)
+	})
+	t.Run("S3Directory", func(t *testing.T) {
+		// each object is uploaded promptly, but the directory is not
+		var puts int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(50 * time.Millisecond):
+			}
+			atomic.AddInt32(&puts, 1)
+			w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+		}))
+		defer server.Close()
+		dir := filepath.Join(tmp, "s3-dir")
+		assert.NoError(t, os.MkdirAll(dir, 0700))
+		for i := 0; i < 20; i++ {
+			assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("file-%d", i)), []byte("my-data"), 0600))
+		}
+		driver := &s3.S3ArtifactDriver{Endpoint: strings.TrimPrefix(server.URL, "http://"), Region: "us-east-1", AccessKey: "my-access-key", SecretKey: "my-secret-key", UploadParallelism: 1}
+		art := &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: "my-dir"}}, Timeout: "300ms"}
+		err := SaveWithProgress(ctx, driver, dir, art, nil)
+		assert.True(t, errors.IsCode(errors.CodeTimeout, err), "%v", err)
+		assert.Less(t, atomic.LoadInt32(&puts), int32(20))
+	})
+}
@@ -0,0 +1,126 @@
+package b2
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+// ArtifactDriver is the artifact driver for Backblaze B2, which uses its native API
+type ArtifactDriver struct {
+	ApplicationKeyID string
+	ApplicationKey   string
+	// PartSize is the size of the parts large files are uploaded in, if set, rather than the part size B2
+	// recommends. Files larger than a part are uploaded as large files.
+	PartSize int64
+	// AuthorizeURL is the URL of b2_authorize_account, if set, rather than that of Backblaze
+	AuthorizeURL string
+	common.Logging
+}
+
+func (d *ArtifactDriver) authorize(ctx context.Context) (*client, error) {
+	authorizeURL := d.AuthorizeURL
+	if authorizeURL == "" {
+		authorizeURL = defaultAuthorizeURL
+	}
+	return authorize(ctx, authorizeURL, d.ApplicationKeyID, d.ApplicationKey)
+}
+
+// Load downloads a file from a B2 bucket
+func (d *ArtifactDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, localPath string) error {
+	d.Log().Infof("B2 Load path: %s, bucket: %s, key: %s", localPath, inputArtifact.B2.Bucket, inputArtifact.B2.Key)
+	c, err := d.authorize(ctx)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(localPath), 0700); err != nil {
+		return err
+	}
+	if err := c.download(inputArtifact.B2.Bucket, inputArtifact.B2.Key, localPath); err != nil {
+		_ = os.Remove(localPath)
+		return err
+	}
+	return nil
+}
+
+// Save uploads a file to a B2 bucket. Files larger than the part size are uploaded as large files, in parts.
+// Directories cannot be uploaded, other than as archives.
+func (d *ArtifactDriver) Save(ctx context.Context, localPath string, outputArtifact *wfv1.Artifact) error {
+	d.Log().Infof("B2 Save path: %s, bucket: %s, key: %s", localPath, outputArtifact.B2.Bucket, outputArtifact.B2.Key)
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return errors.Errorf(errors.CodeBadRequest, "B2 cannot save the directory %s, which must be archived", localPath)
+	}
+	c, err := d.authorize(ctx)
+	if err != nil {
+		return err
+	}
+	bucketID, err := c.bucketID(outputArtifact.B2.Bucket)
+	if err != nil {
+		return err
+	}
+	partSize := d.PartSize
+	if partSize <= 0 {
+		partSize = c.account.RecommendedPartSize
+	}
+	if partSize > 0 && info.Size() > partSize {
+		d.Log().Debugf("B2 uploading %s as a large file in parts of %d bytes", outputArtifact.B2.Key, partSize)
+		return c.uploadLargeFile(bucketID, outputArtifact.B2.Key, f, info.Size(), partSize)
+	}
+	return c.uploadFile(bucketID, outputArtifact.B2.Key, f, info.Size())
+}
+
+// Exists returns whether the file is in the bucket, and not hidden
+func (d *ArtifactDriver) Exists(artifact *wfv1.Artifact) (bool, error) {
+	c, err := d.authorize(context.Background())
+	if err != nil {
+		return false, err
+	}
+	bucketID, err := c.bucketID(artifact.B2.Bucket)
+	if stderrors.Is(err, common.ErrArtifactNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return c.exists(bucketID, artifact.B2.Key)
+}
+
+// Delete deletes every version of the file from the bucket
+func (d *ArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	d.Log().Infof("B2 Delete bucket: %s, key: %s", artifact.B2.Bucket, artifact.B2.Key)
+	c, err := d.authorize(context.Background())
+	if err != nil {
+		return err
+	}
+	bucketID, err := c.bucketID(artifact.B2.Bucket)
+	if err != nil {
+		return err
+	}
+	if err := c.deleteVersions(bucketID, artifact.B2.Key); err != nil {
+		return fmt.Errorf("B2 delete of %s failed: %w", artifact.B2.Key, err)
+	}
+	return nil
+}
+
+// Capabilities returns the optional operations on B2 artifacts the driver supports
+func (d *ArtifactDriver) Capabilities() common.DriverCapabilities {
+	return common.DriverCapabilities{
+		Delete: true,
+		Exists: true,
+	}
+}
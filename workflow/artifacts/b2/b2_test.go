@@ -0,0 +1,360 @@
+package b2
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const (
+	testKeyID     = "my-key-id"
+	testKey       = "my-key"
+	testToken     = "my-token"
+	testBucket    = "my-bucket"
+	testBucketID  = "my-bucket-id"
+	testPartSize  = 5
+	uploadToken   = "my-upload-token"
+	largeFileID   = "my-large-file"
+	largeFileInfo = "X-Bz-Info-" + largeFileSHA1Info
+)
+
+// fakeB2 is a B2 API with a single bucket, which keeps a single version of each file
+type fakeB2 struct {
+	*httptest.Server
+	mu    sync.Mutex
+	files map[string][]byte
+	// the SHA1 checksums of large files, which B2 does not compute
+	largeFileSHA1s map[string]string
+	large          struct {
+		name  string
+		sha1  string
+		parts map[int][]byte
+	}
+	// the number of single-shot uploads, and of uploaded parts
+	uploads, parts int
+	// the number of uploads which fail as if the upload URL were busy
+	busy int
+	// whether a large file was cancelled
+	cancelled bool
+}
+
+func newFakeB2() *fakeB2 {
+	f := &fakeB2{files: map[string][]byte{}, largeFileSHA1s: map[string]string{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+func sha1Hex(data []byte) string {
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(apiError{Status: status, Code: code, Message: code})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (f *fakeB2) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path == "/b2api/v2/b2_authorize_account" {
+		if keyID, key, ok := r.BasicAuth(); !ok || keyID != testKeyID || key != testKey {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"accountId":           "my-account",
+			"authorizationToken":  testToken,
+			"apiUrl":              f.URL,
+			"downloadUrl":         f.URL,
+			"recommendedPartSize": 100 * 1000 * 1000,
+		})
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request")
+		return
+	}
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/upload/"):
+		f.upload(w, r, data)
+	case strings.HasPrefix(r.URL.Path, "/upload_part/"):
+		f.uploadPart(w, r, data)
+	case strings.HasPrefix(r.URL.Path, "/file/"+testBucket+"/"):
+		f.download(w, r)
+	case strings.HasPrefix(r.URL.Path, "/b2api/v2/"):
+		if r.Header.Get("Authorization") != testToken {
+			writeError(w, http.StatusUnauthorized, "bad_auth_token")
+			return
+		}
+		var req map[string]interface{}
+		if err := json.Unmarshal(data, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request")
+			return
+		}
+		f.call(w, strings.TrimPrefix(r.URL.Path, "/b2api/v2/"), req)
+	default:
+		writeError(w, http.StatusNotFound, "not_found")
+	}
+}
+
+func (f *fakeB2) call(w http.ResponseWriter, operation string, req map[string]interface{}) {
+	switch operation {
+	case "b2_list_buckets":
+		var buckets []interface{}
+		if req["bucketName"] == testBucket {
+			buckets = append(buckets, map[string]string{"bucketId": testBucketID, "bucketName": testBucket})
+		}
+		writeJSON(w, map[string]interface{}{"buckets": buckets})
+	case "b2_get_upload_url":
+		writeJSON(w, uploadURL{UploadURL: f.URL + "/upload/" + testBucketID, AuthorizationToken: uploadToken})
+	case "b2_start_large_file":
+		f.large.name = req["fileName"].(string)
+		f.large.sha1 = req["fileInfo"].(map[string]interface{})[largeFileSHA1Info].(string)
+		f.large.parts = map[int][]byte{}
+		writeJSON(w, map[string]string{"fileId": largeFileID})
+	case "b2_get_upload_part_url":
+		writeJSON(w, uploadURL{UploadURL: f.URL + "/upload_part/" + largeFileID, AuthorizationToken: uploadToken})
+	case "b2_finish_large_file":
+		var data []byte
+		for i, checksum := range req["partSha1Array"].([]interface{}) {
+			part, ok := f.large.parts[i+1]
+			if !ok || sha1Hex(part) != checksum {
+				writeError(w, http.StatusBadRequest, "bad_request")
+				return
+			}
+			data = append(data, part...)
+		}
+		if len(f.large.parts) != len(req["partSha1Array"].([]interface{})) {
+			writeError(w, http.StatusBadRequest, "bad_request")
+			return
+		}
+		f.files[f.large.name] = data
+		f.largeFileSHA1s[f.large.name] = f.large.sha1
+		writeJSON(w, map[string]string{"fileId": largeFileID})
+	case "b2_cancel_large_file":
+		f.cancelled = true
+		writeJSON(w, map[string]string{"fileId": largeFileID})
+	case "b2_list_file_names", "b2_list_file_versions":
+		var names []string
+		for name := range f.files {
+			if name >= req["startFileName"].(string) && strings.HasPrefix(name, stringValue(req["prefix"])) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		files := []fileVersion{}
+		for _, name := range names {
+			files = append(files, fileVersion{FileID: "id-" + name, FileName: name, Action: "upload"})
+		}
+		if n := int(req["maxFileCount"].(float64)); len(files) > n {
+			files = files[:n]
+		}
+		writeJSON(w, map[string]interface{}{"files": files})
+	case "b2_delete_file_version":
+		name := req["fileName"].(string)
+		if _, ok := f.files[name]; !ok || req["fileId"] != "id-"+name {
+			writeError(w, http.StatusBadRequest, "file_not_present")
+			return
+		}
+		delete(f.files, name)
+		writeJSON(w, map[string]string{"fileName": name})
+	default:
+		writeError(w, http.StatusBadRequest, "bad_request")
+	}
+}
+
+func stringValue(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// checkUpload returns whether the upload is authorized and has the checksum of its data, or responds with an error
+func (f *fakeB2) checkUpload(w http.ResponseWriter, r *http.Request, data []byte) bool {
+	if r.Header.Get("Authorization") != uploadToken {
+		writeError(w, http.StatusUnauthorized, "bad_auth_token")
+		return false
+	}
+	if f.busy > 0 {
+		f.busy--
+		writeError(w, http.StatusServiceUnavailable, "service_unavailable")
+		return false
+	}
+	if r.Header.Get("X-Bz-Content-Sha1") != sha1Hex(data) || r.ContentLength != int64(len(data)) {
+		writeError(w, http.StatusBadRequest, "bad_request")
+		return false
+	}
+	return true
+}
+
+func (f *fakeB2) upload(w http.ResponseWriter, r *http.Request, data []byte) {
+	if !f.checkUpload(w, r, data) {
+		return
+	}
+	name, err := url.PathUnescape(r.Header.Get("X-Bz-File-Name"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request")
+		return
+	}
+	f.uploads++
+	f.files[name] = data
+	writeJSON(w, map[string]string{"fileName": name})
+}
+
+func (f *fakeB2) uploadPart(w http.ResponseWriter, r *http.Request, data []byte) {
+	if !f.checkUpload(w, r, data) {
+		return
+	}
+	part, err := strconv.Atoi(r.Header.Get("X-Bz-Part-Number"))
+	if err != nil || part < 1 {
+		writeError(w, http.StatusBadRequest, "bad_request")
+		return
+	}
+	f.parts++
+	f.large.parts[part] = data
+	writeJSON(w, map[string]int{"partNumber": part})
+}
+
+func (f *fakeB2) download(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != testToken {
+		writeError(w, http.StatusUnauthorized, "bad_auth_token")
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/file/"+testBucket+"/")
+	data, ok := f.files[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found")
+		return
+	}
+	if checksum, ok := f.largeFileSHA1s[name]; ok {
+		w.Header().Set("X-Bz-Content-Sha1", "none")
+		w.Header().Set(largeFileInfo, checksum)
+	} else {
+		w.Header().Set("X-Bz-Content-Sha1", sha1Hex(data))
+	}
+	_, _ = w.Write(data)
+}
+
+func newArtifact(key string) *wfv1.Artifact {
+	return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{B2: &wfv1.B2Artifact{Bucket: testBucket, Key: key}}}
+}
+
+func TestArtifactDriver(t *testing.T) {
+	retryDelay = 0
+	server := newFakeB2()
+	defer server.Close()
+	driver := &ArtifactDriver{
+		ApplicationKeyID: testKeyID,
+		ApplicationKey:   testKey,
+		PartSize:         testPartSize,
+		AuthorizeURL:     server.URL + "/b2api/v2/b2_authorize_account",
+	}
+	tmp, err := ioutil.TempDir("", "b2")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	writeFile := func(name, data string) string {
+		p := filepath.Join(tmp, name)
+		assert.NoError(t, ioutil.WriteFile(p, []byte(data), 0600))
+		return p
+	}
+	readFile := func(p string) string {
+		data, err := ioutil.ReadFile(p)
+		assert.NoError(t, err)
+		return string(data)
+	}
+
+	t.Run("SingleShot", func(t *testing.T) {
+		art := newArtifact("my-dir/my file+1.txt")
+		assert.NoError(t, driver.Save(context.Background(), writeFile("small", "small"), art))
+		assert.Equal(t, 1, server.uploads)
+		assert.Equal(t, 0, server.parts)
+		assert.Equal(t, "small", string(server.files["my-dir/my file+1.txt"]))
+		dst := filepath.Join(tmp, "small-dst", "small")
+		if assert.NoError(t, driver.Load(context.Background(), art, dst)) {
+			assert.Equal(t, "small", readFile(dst))
+		}
+	})
+	t.Run("LargeFile", func(t *testing.T) {
+		art := newArtifact("my-large-file")
+		assert.NoError(t, driver.Save(context.Background(), writeFile("large", "0123456789abc"), art))
+		assert.Equal(t, 1, server.uploads)
+		assert.Equal(t, 3, server.parts)
+		assert.False(t, server.cancelled)
+		assert.Equal(t, "0123456789abc", string(server.files["my-large-file"]))
+		dst := filepath.Join(tmp, "large-dst")
+		if assert.NoError(t, driver.Load(context.Background(), art, dst)) {
+			assert.Equal(t, "0123456789abc", readFile(dst))
+		}
+	})
+	t.Run("Retry", func(t *testing.T) {
+		server.busy = 2
+		assert.NoError(t, driver.Save(context.Background(), writeFile("retry", "retry"), newArtifact("my-retry")))
+		assert.Equal(t, "retry", string(server.files["my-retry"]))
+	})
+	t.Run("CancelLargeFile", func(t *testing.T) {
+		server.busy = maxUploadAttempts
+		err := driver.Save(context.Background(), writeFile("cancel", "0123456789"), newArtifact("my-cancelled-file"))
+		assert.Error(t, err)
+		assert.True(t, server.cancelled)
+		assert.NotContains(t, server.files, "my-cancelled-file")
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		err := driver.Load(context.Background(), newArtifact("missing"), filepath.Join(tmp, "missing"))
+		assert.True(t, errors.Is(err, common.ErrArtifactNotFound))
+		assert.NoFileExists(t, filepath.Join(tmp, "missing"))
+	})
+	t.Run("Exists", func(t *testing.T) {
+		exists, err := driver.Exists(newArtifact("my-large-file"))
+		assert.NoError(t, err)
+		assert.True(t, exists)
+		exists, err = driver.Exists(newArtifact("my-large"))
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+	t.Run("Delete", func(t *testing.T) {
+		assert.NoError(t, driver.Delete(newArtifact("my-retry")))
+		assert.NotContains(t, server.files, "my-retry")
+		assert.Contains(t, server.files, "my-large-file")
+	})
+	t.Run("BadCredentials", func(t *testing.T) {
+		driver := &ArtifactDriver{ApplicationKeyID: testKeyID, ApplicationKey: "wrong", AuthorizeURL: driver.AuthorizeURL}
+		err := driver.Load(context.Background(), newArtifact("my-retry"), filepath.Join(tmp, "unauthorized"))
+		assert.Error(t, err)
+	})
+	t.Run("Directory", func(t *testing.T) {
+		err := driver.Save(context.Background(), tmp, newArtifact("my-dir"))
+		assert.Error(t, err)
+	})
+}
+
+func TestEncodeFileName(t *testing.T) {
+	assert.Equal(t, "my-dir/my%20file%2B1.txt", encodeFileName("my-dir/my file+1.txt"))
+	assert.Equal(t, "%E2%82%AC~", encodeFileName("€~"))
+}
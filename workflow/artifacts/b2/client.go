@@ -0,0 +1,369 @@
+package b2
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const (
+	defaultAuthorizeURL = "https://api.backblazeb2.com/b2api/v2/b2_authorize_account"
+	// B2 detects the content type of files uploaded with it from their names
+	autoContentType = "b2/x-auto"
+	// the number of parts a large file can be uploaded in
+	maxParts = 10000
+	// the number of times an upload is attempted, each with a new upload URL, as B2 recommends
+	maxUploadAttempts = 5
+	// the key of the file info of a large file which has its SHA1 checksum, which B2 does not compute for them
+	largeFileSHA1Info = "large_file_sha1"
+)
+
+// retryDelay is the delay before the second attempt of an upload, which grows linearly with further attempts
+var retryDelay = time.Second
+
+// apiError is the body of the response to a B2 API request which fails
+type apiError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s (%d): %s", e.Code, e.Status, e.Message)
+}
+
+// isRetryable returns whether an upload which failed with the error should be attempted again with a new upload
+// URL: the upload URL is busy or expired, the request timed out, or it failed without a response
+func isRetryable(err error) bool {
+	var e *apiError
+	if !errors.As(err, &e) {
+		return true
+	}
+	return e.Status == http.StatusUnauthorized || e.Status == http.StatusRequestTimeout || e.Status == http.StatusTooManyRequests || e.Status >= 500
+}
+
+// client is a client of the B2 native API, authorized with an application key
+type client struct {
+	ctx        context.Context
+	httpClient *http.Client
+	account    authorizeAccountResponse
+}
+
+type authorizeAccountResponse struct {
+	AccountID           string `json:"accountId"`
+	AuthorizationToken  string `json:"authorizationToken"`
+	APIURL              string `json:"apiUrl"`
+	DownloadURL         string `json:"downloadUrl"`
+	RecommendedPartSize int64  `json:"recommendedPartSize"`
+	Allowed             struct {
+		BucketID   string `json:"bucketId"`
+		BucketName string `json:"bucketName"`
+	} `json:"allowed"`
+}
+
+// authorize logs in to B2 with the ID of an application key and the key
+func authorize(ctx context.Context, authorizeURL, keyID, key string) (*client, error) {
+	c := &client{ctx: ctx, httpClient: &http.Client{}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, authorizeURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(keyID, key)
+	if err := c.do(req, &c.account); err != nil {
+		return nil, fmt.Errorf("B2 b2_authorize_account failed: %w", err)
+	}
+	return c, nil
+}
+
+// do sends the request, and decodes the JSON body of the response into res, or returns the error B2 responded with
+func (c *client) do(req *http.Request, res interface{}) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return decodeError(resp)
+	}
+	if res == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
+
+func decodeError(resp *http.Response) error {
+	e := &apiError{Status: resp.StatusCode}
+	if err := json.NewDecoder(resp.Body).Decode(e); err != nil || e.Code == "" {
+		e.Code = http.StatusText(resp.StatusCode)
+	}
+	if e.Status == http.StatusNotFound {
+		return common.NewNotFoundError(e)
+	}
+	return e
+}
+
+// call calls an operation of the B2 API, which is a POST of a JSON request, with a JSON response
+func (c *client) call(operation string, request, res interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, c.account.APIURL+"/b2api/v2/"+operation, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", c.account.AuthorizationToken)
+	req.Header.Set("Content-Type", "application/json")
+	if err := c.do(req, res); err != nil {
+		return fmt.Errorf("B2 %s failed: %w", operation, err)
+	}
+	return nil
+}
+
+// bucketID returns the ID of the bucket, which application keys restricted to a bucket are allowed to look up
+// without listing buckets
+func (c *client) bucketID(bucket string) (string, error) {
+	if c.account.Allowed.BucketName == bucket && c.account.Allowed.BucketID != "" {
+		return c.account.Allowed.BucketID, nil
+	}
+	var res struct {
+		Buckets []struct {
+			BucketID   string `json:"bucketId"`
+			BucketName string `json:"bucketName"`
+		} `json:"buckets"`
+	}
+	if err := c.call("b2_list_buckets", map[string]string{"accountId": c.account.AccountID, "bucketName": bucket}, &res); err != nil {
+		return "", err
+	}
+	for _, b := range res.Buckets {
+		if b.BucketName == bucket {
+			return b.BucketID, nil
+		}
+	}
+	return "", common.NewNotFoundError(fmt.Errorf("B2 bucket %s not found", bucket))
+}
+
+// uploadURL is a URL files or parts are uploaded to, with the token that authorizes the uploads
+type uploadURL struct {
+	UploadURL          string `json:"uploadUrl"`
+	AuthorizationToken string `json:"authorizationToken"`
+}
+
+// upload attempts to upload with a new upload URL until it succeeds, it fails with an error which is not
+// retryable, or maxUploadAttempts attempts fail
+func (c *client) upload(operation string, request interface{}, send func(u uploadURL) error) error {
+	var err error
+	for attempt := 1; attempt <= maxUploadAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-c.ctx.Done():
+				return c.ctx.Err()
+			case <-time.After(time.Duration(attempt-1) * retryDelay):
+			}
+		}
+		var u uploadURL
+		if err = c.call(operation, request, &u); err != nil {
+			return err
+		}
+		if err = send(u); err == nil || !isRetryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// post uploads the section of the file to the upload URL
+func (c *client) post(u uploadURL, f *os.File, offset, size int64, checksum string, header map[string]string) error {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, u.UploadURL, io.NewSectionReader(f, offset, size))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Authorization", u.AuthorizationToken)
+	req.Header.Set("X-Bz-Content-Sha1", checksum)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	return c.do(req, nil)
+}
+
+// uploadFile uploads a file in a single request
+func (c *client) uploadFile(bucketID, name string, f *os.File, size int64) error {
+	checksum, err := sha1Section(f, 0, size)
+	if err != nil {
+		return err
+	}
+	err = c.upload("b2_get_upload_url", map[string]string{"bucketId": bucketID}, func(u uploadURL) error {
+		return c.post(u, f, 0, size, checksum, map[string]string{
+			"X-Bz-File-Name": encodeFileName(name),
+			"Content-Type":   autoContentType,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("B2 upload of %s failed: %w", name, err)
+	}
+	return nil
+}
+
+// uploadLargeFile uploads a file in parts of the part size, or of larger parts if it would take more than maxParts.
+// The large file is cancelled if any part fails, so that its parts are not kept.
+func (c *client) uploadLargeFile(bucketID, name string, f *os.File, size, partSize int64) (err error) {
+	if size > partSize*maxParts {
+		partSize = (size + maxParts - 1) / maxParts
+	}
+	checksum, err := sha1Section(f, 0, size)
+	if err != nil {
+		return err
+	}
+	var started struct {
+		FileID string `json:"fileId"`
+	}
+	err = c.call("b2_start_large_file", map[string]interface{}{
+		"bucketId":    bucketID,
+		"fileName":    name,
+		"contentType": autoContentType,
+		"fileInfo":    map[string]string{largeFileSHA1Info: checksum},
+	}, &started)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = c.call("b2_cancel_large_file", map[string]string{"fileId": started.FileID}, nil)
+		}
+	}()
+	var partChecksums []string
+	for offset, part := int64(0), 1; offset < size; offset, part = offset+partSize, part+1 {
+		n := partSize
+		if offset+n > size {
+			n = size - offset
+		}
+		partChecksum, err := sha1Section(f, offset, n)
+		if err != nil {
+			return err
+		}
+		err = c.upload("b2_get_upload_part_url", map[string]string{"fileId": started.FileID}, func(u uploadURL) error {
+			return c.post(u, f, offset, n, partChecksum, map[string]string{"X-Bz-Part-Number": strconv.Itoa(part)})
+		})
+		if err != nil {
+			return fmt.Errorf("B2 upload of part %d of %s failed: %w", part, name, err)
+		}
+		partChecksums = append(partChecksums, partChecksum)
+	}
+	return c.call("b2_finish_large_file", map[string]interface{}{"fileId": started.FileID, "partSha1Array": partChecksums}, nil)
+}
+
+// download downloads a file by its name, and verifies its SHA1 checksum, if B2 has one
+func (c *client) download(bucket, name, localPath string) error {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, c.account.DownloadURL+"/file/"+bucket+"/"+encodeFileName(name), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", c.account.AuthorizationToken)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("B2 download of %s failed: %w", name, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("B2 download of %s failed: %w", name, decodeError(resp))
+	}
+	checksum := strings.TrimPrefix(resp.Header.Get("X-Bz-Content-Sha1"), "unverified:")
+	if checksum == "" || checksum == "none" {
+		checksum = resp.Header.Get("X-Bz-Info-" + largeFileSHA1Info)
+	}
+	out, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = out.Close() }()
+	h := sha1.New()
+	if _, err := io.Copy(io.MultiWriter(out, h), resp.Body); err != nil {
+		return fmt.Errorf("B2 download of %s failed: %w", name, err)
+	}
+	if checksum != "" && checksum != "none" && !strings.EqualFold(checksum, hex.EncodeToString(h.Sum(nil))) {
+		return fmt.Errorf("B2 download of %s failed: SHA1 checksum mismatch", name)
+	}
+	return out.Close()
+}
+
+// fileVersion is a version of a file, or a large file which is being uploaded
+type fileVersion struct {
+	FileID   string `json:"fileId"`
+	FileName string `json:"fileName"`
+	Action   string `json:"action"`
+}
+
+// exists returns whether the file is uploaded, and not hidden
+func (c *client) exists(bucketID, name string) (bool, error) {
+	var res struct {
+		Files []fileVersion `json:"files"`
+	}
+	err := c.call("b2_list_file_names", map[string]interface{}{"bucketId": bucketID, "startFileName": name, "maxFileCount": 1}, &res)
+	if err != nil {
+		return false, err
+	}
+	return len(res.Files) > 0 && res.Files[0].FileName == name && res.Files[0].Action == "upload", nil
+}
+
+// deleteVersions deletes every version of the file, which B2 keeps until they are each deleted
+func (c *client) deleteVersions(bucketID, name string) error {
+	for {
+		var res struct {
+			Files []fileVersion `json:"files"`
+		}
+		err := c.call("b2_list_file_versions", map[string]interface{}{"bucketId": bucketID, "startFileName": name, "prefix": name, "maxFileCount": 100}, &res)
+		if err != nil {
+			return err
+		}
+		deleted := 0
+		for _, v := range res.Files {
+			if v.FileName != name {
+				continue
+			}
+			if err := c.call("b2_delete_file_version", map[string]string{"fileName": v.FileName, "fileId": v.FileID}, nil); err != nil {
+				return err
+			}
+			deleted++
+		}
+		if deleted == 0 {
+			return nil
+		}
+	}
+}
+
+// sha1Section returns the hex SHA1 checksum of the section of the file
+func sha1Section(f *os.File, offset, size int64) (string, error) {
+	h := sha1.New()
+	if _, err := io.Copy(h, io.NewSectionReader(f, offset, size)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// encodeFileName percent-encodes a file name for the X-Bz-File-Name header and download URLs, in which slashes
+// separate "directories" and are not encoded, and "+" would be decoded as a space
+func encodeFileName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		ch := name[i]
+		if ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || strings.IndexByte("-._~/", ch) >= 0 {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,160 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/resource"
+)
+
+// BatchLoad is an input artifact of a batch, and the path it is loaded to
+type BatchLoad struct {
+	Artifact *wfv1.Artifact
+	Path     string
+}
+
+// BatchLoadError is the error of a batch of which some artifacts failed to be loaded. errors.Is and errors.As find
+// the error of the first artifact of the batch which failed to be loaded.
+type BatchLoadError struct {
+	// Loads is the number of artifacts of the batch
+	Loads int
+	// Failures are the artifacts which were not loaded, in the order of the batch
+	Failures []BatchLoadFailure
+}
+
+// BatchLoadFailure is an artifact of a batch which was not loaded
+type BatchLoadFailure struct {
+	// Artifact is the name of the artifact
+	Artifact string
+	// Err is the error of loading the artifact, or that of its context if it was not loaded before it was done
+	Err error
+}
+
+// Error returns e.g. "1 of 3 artifacts failed to be loaded: my-art: s3 load my-key: denied"
+func (e *BatchLoadError) Error() string {
+	failures := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		failures[i] = fmt.Sprintf("%s: %v", f.Artifact, f.Err)
+	}
+	return fmt.Sprintf("%d of %d artifacts failed to be loaded: %s", len(e.Failures), e.Loads, strings.Join(failures, "; "))
+}
+
+func (e *BatchLoadError) Unwrap() error {
+	if len(e.Failures) == 0 {
+		return nil
+	}
+	return e.Failures[0].Err
+}
+
+// sharedDriver is a driver which is created once for every artifact of a batch which shares it
+type sharedDriver struct {
+	once   sync.Once
+	driver ArtifactDriver
+	err    error
+}
+
+// batchDrivers creates the drivers of the artifacts of a batch, which share a driver NewDriver creates once if
+// their locations only differ in their keys
+type batchDrivers struct {
+	ri      resource.Interface
+	lock    sync.Mutex
+	drivers map[string]*sharedDriver
+}
+
+// sharingKey returns what the artifacts which share a driver with the artifact have in common, which is its location
+// without its key, or "" if it does not share its driver. One which expands environment variables, or has a bandwidth
+// limit, does not, as it is expanded by, and the throttle of its limit is shared by every request of, its driver. Nor
+// does an encrypted one, as its driver decrypts what the driver of its location loads, and that of an unencrypted one
+// does not.
+func sharingKey(art *wfv1.Artifact) string {
+	if art.ExpandEnv || art.BandwidthLimit != "" || art.Encryption != nil {
+		return ""
+	}
+	location := art.ArtifactLocation.DeepCopy()
+	if err := location.SetKey(""); err != nil {
+		return ""
+	}
+	data, err := json.Marshal(location)
+	if err != nil {
+		return ""
+	}
+	return driverName(art) + ":" + string(data)
+}
+
+// get returns the driver of the artifact, which it shares with the other artifacts of the batch with its sharing key
+func (b *batchDrivers) get(ctx context.Context, art *wfv1.Artifact) (ArtifactDriver, error) {
+	key := sharingKey(art)
+	if key == "" {
+		return NewDriver(ctx, art, b.ri)
+	}
+	b.lock.Lock()
+	d, ok := b.drivers[key]
+	if !ok {
+		d = &sharedDriver{}
+		b.drivers[key] = d
+	}
+	b.lock.Unlock()
+	d.once.Do(func() { d.driver, d.err = NewDriver(ctx, art, b.ri) })
+	return d.driver, d.err
+}
+
+// LoadBatch loads the input artifacts of the batch concurrently, at most parallelism, or 1 if it is less, at once.
+// Each is loaded as LoadWithProgress does, with a driver NewDriver creates with ri, which is shared by the artifacts
+// whose locations only differ in their keys, so that e.g. those of an S3 bucket share a client. Every artifact is
+// loaded, or attempted to be, even if another fails to be, unless ctx is done first, e.g. once its deadline passes,
+// which cancels the loads which are not done and fails those which did not start. The error is a *BatchLoadError with
+// the error of each artifact which was not loaded.
+func LoadBatch(ctx context.Context, ri resource.Interface, loads []BatchLoad, parallelism int) error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	drivers := &batchDrivers{ri: ri, drivers: map[string]*sharedDriver{}}
+	errs := make([]error, len(loads))
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, l := range loads {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func(i int, l BatchLoad) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = loadBatched(ctx, drivers, l)
+		}(i, l)
+	}
+	wg.Wait()
+	batchErr := &BatchLoadError{Loads: len(loads)}
+	for i, err := range errs {
+		if err != nil {
+			batchErr.Failures = append(batchErr.Failures, BatchLoadFailure{Artifact: loads[i].Artifact.Name, Err: err})
+		}
+	}
+	if len(batchErr.Failures) > 0 {
+		return batchErr
+	}
+	return nil
+}
+
+// loadBatched loads an artifact of a batch, unless the context of the batch is done before it starts
+func loadBatched(ctx context.Context, drivers *batchDrivers, l BatchLoad) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	driver, err := drivers.get(ctx, l.Artifact)
+	if err != nil {
+		return err
+	}
+	if err := LoadWithProgress(ctx, driver, l.Artifact, l.Path, nil); err != nil {
+		driverLogger(driver, l.Artifact).Warnf("Failed to load artifact %s of batch: %v", l.Artifact.Name, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/memory"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/resource"
+)
+
+// batchDriver is a memory driver which records how many of its loads run at once, and whose loads wait until as many
+// as the barrier run at once, or until their context is done if they block
+type batchDriver struct {
+	*memory.ArtifactDriver
+	lock                sync.Mutex
+	running, maxRunning int
+	barrier             int
+	block               bool
+}
+
+func (d *batchDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, path string) error {
+	d.lock.Lock()
+	d.running++
+	if d.running > d.maxRunning {
+		d.maxRunning = d.running
+	}
+	d.lock.Unlock()
+	defer func() {
+		d.lock.Lock()
+		d.running--
+		d.lock.Unlock()
+	}()
+	if d.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		d.lock.Lock()
+		reached := d.maxRunning >= d.barrier
+		d.lock.Unlock()
+		if reached {
+			break
+		}
+	}
+	return d.ArtifactDriver.Load(ctx, inputArtifact, path)
+}
+
+// registerBatchDriver registers the driver as that of GCS artifacts, until the returned function restores the builtin
+// driver, and counts the drivers NewDriver creates
+func registerBatchDriver(driver ArtifactDriver, created *int) (restore func()) {
+	gcsDriver := drivers[DriverGCS]
+	var lock sync.Mutex
+	RegisterDriver(DriverGCS, func(context.Context, *wfv1.Artifact, resource.Interface) (ArtifactDriver, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		*created++
+		return driver, nil
+	})
+	return func() { RegisterDriver(DriverGCS, gcsDriver) }
+}
+
+func batchArtifact(bucket, key string) *wfv1.Artifact {
+	return &wfv1.Artifact{Name: key, ArtifactLocation: wfv1.ArtifactLocation{GCS: &wfv1.GCSArtifact{GCSBucket: wfv1.GCSBucket{Bucket: bucket}, Key: key}}}
+}
+
+func TestLoadBatch(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "batch")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	newBatch := func(name string, keys ...string) []BatchLoad {
+		var loads []BatchLoad
+		for _, key := range keys {
+			loads = append(loads, BatchLoad{Artifact: batchArtifact("my-bucket", key), Path: filepath.Join(tmp, name+"-"+key)})
+		}
+		return loads
+	}
+	newDriver := func(keys ...string) *batchDriver {
+		driver := &batchDriver{ArtifactDriver: memory.NewArtifactDriver()}
+		for _, key := range keys {
+			driver.Seed("memory://"+key, []byte("data of "+key))
+		}
+		return driver
+	}
+
+	t.Run("Concurrent", func(t *testing.T) {
+		keys := []string{"a", "b", "c", "d", "e", "f"}
+		driver := newDriver(keys...)
+		driver.barrier = 3
+		created := 0
+		defer registerBatchDriver(driver, &created)()
+		assert.NoError(t, LoadBatch(context.Background(), fakeResources{}, newBatch("concurrent", keys...), 3))
+		// the loads wait for each other, so as many as the parallelism run at once, but no more
+		assert.Equal(t, 3, driver.maxRunning)
+		for _, key := range keys {
+			data, err := ioutil.ReadFile(filepath.Join(tmp, "concurrent-"+key))
+			assert.NoError(t, err)
+			assert.Equal(t, "data of "+key, string(data))
+		}
+		// the artifacts of the bucket share a driver
+		assert.Equal(t, 1, created)
+	})
+	t.Run("Serial", func(t *testing.T) {
+		driver := newDriver("a", "b", "c")
+		created := 0
+		defer registerBatchDriver(driver, &created)()
+		assert.NoError(t, LoadBatch(context.Background(), fakeResources{}, newBatch("serial", "a", "b", "c"), 0))
+		assert.Equal(t, 1, driver.maxRunning)
+	})
+	t.Run("Failed", func(t *testing.T) {
+		driver := newDriver("a", "c")
+		created := 0
+		defer registerBatchDriver(driver, &created)()
+		err := LoadBatch(context.Background(), fakeResources{}, newBatch("failed", "a", "b", "c", "d"), 2)
+		var batchErr *BatchLoadError
+		if assert.True(t, stderrors.As(err, &batchErr), err) && assert.Len(t, batchErr.Failures, 2) {
+			// every artifact is loaded, even once one fails
+			assert.Equal(t, "b", batchErr.Failures[0].Artifact)
+			assert.Equal(t, "d", batchErr.Failures[1].Artifact)
+			assert.Equal(t, 4, batchErr.Loads)
+		}
+		var driverErr *DriverError
+		if assert.True(t, stderrors.As(err, &driverErr)) {
+			assert.Equal(t, "b", driverErr.Key)
+		}
+		for key, loaded := range map[string]bool{"a": true, "b": false, "c": true, "d": false} {
+			_, err := os.Stat(filepath.Join(tmp, "failed-"+key))
+			assert.Equal(t, loaded, err == nil, key)
+		}
+	})
+	t.Run("Deadline", func(t *testing.T) {
+		driver := newDriver("a", "b", "c")
+		driver.block = true
+		created := 0
+		defer registerBatchDriver(driver, &created)()
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		err := LoadBatch(ctx, fakeResources{}, newBatch("deadline", "a", "b", "c"), 2)
+		assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+		var batchErr *BatchLoadError
+		if assert.True(t, stderrors.As(err, &batchErr), err) && assert.Len(t, batchErr.Failures, 3) {
+			// the loads which run are cancelled, and the one which did not start is not loaded
+			for _, f := range batchErr.Failures {
+				assert.True(t, stderrors.Is(f.Err, context.DeadlineExceeded), f.Err)
+			}
+		}
+		assert.True(t, stderrors.Is(err, context.DeadlineExceeded))
+		assert.Equal(t, 2, driver.maxRunning)
+		assert.Zero(t, driver.running)
+	})
+	t.Run("Drivers", func(t *testing.T) {
+		driver := newDriver("a", "b", "c", "d")
+		created := 0
+		defer registerBatchDriver(driver, &created)()
+		limited := batchArtifact("my-bucket", "d")
+		limited.BandwidthLimit = "10Mi"
+		loads := []BatchLoad{
+			{Artifact: batchArtifact("my-bucket", "a"), Path: filepath.Join(tmp, "drivers-a")},
+			{Artifact: batchArtifact("my-bucket", "b"), Path: filepath.Join(tmp, "drivers-b")},
+			{Artifact: batchArtifact("other-bucket", "c"), Path: filepath.Join(tmp, "drivers-c")},
+			{Artifact: limited, Path: filepath.Join(tmp, "drivers-d")},
+		}
+		err := LoadBatch(context.Background(), fakeResources{}, loads, 4)
+		// the artifact with a bandwidth limit fails to be loaded, as the driver does not support limits, which the others do not
+		var batchErr *BatchLoadError
+		if assert.True(t, stderrors.As(err, &batchErr), err) && assert.Len(t, batchErr.Failures, 1) {
+			assert.Equal(t, "d", batchErr.Failures[0].Artifact)
+		}
+		// the artifacts of my-bucket share a driver, the one of other-bucket does not, nor does the one with a bandwidth limit
+		assert.Equal(t, 3, created)
+	})
+	t.Run("Encrypted", func(t *testing.T) {
+		identity, err := age.GenerateX25519Identity()
+		if !assert.NoError(t, err) {
+			return
+		}
+		var encrypted bytes.Buffer
+		w, err := age.Encrypt(&encrypted, identity.Recipient())
+		if !assert.NoError(t, err) {
+			return
+		}
+		_, err = w.Write([]byte("data of b"))
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+		driver := newDriver("a")
+		driver.Seed("memory://b", encrypted.Bytes())
+		created := 0
+		defer registerBatchDriver(driver, &created)()
+		art := batchArtifact("my-bucket", "b")
+		art.Encryption = &wfv1.ArtifactEncryption{Scheme: wfv1.ArtifactEncryptionSchemeAge, PrivateKeySecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "private-key"}}
+		loads := []BatchLoad{
+			{Artifact: batchArtifact("my-bucket", "a"), Path: filepath.Join(tmp, "encrypted-a")},
+			{Artifact: art, Path: filepath.Join(tmp, "encrypted-b")},
+		}
+		assert.NoError(t, LoadBatch(context.Background(), fakeResources{"my-secret/private-key": identity.String()}, loads, 1))
+		// the encrypted artifact does not share the driver of the unencrypted one, which would not decrypt it
+		assert.Equal(t, 2, created)
+		for key, expected := range map[string]string{"a": "data of a", "b": "data of b"} {
+			data, err := ioutil.ReadFile(filepath.Join(tmp, "encrypted-"+key))
+			assert.NoError(t, err)
+			assert.Equal(t, expected, string(data))
+		}
+	})
+}
+
+func TestBatchLoadError(t *testing.T) {
+	err := &BatchLoadError{Loads: 3, Failures: []BatchLoadFailure{{Artifact: "my-art", Err: fmt.Errorf("denied")}}}
+	assert.EqualError(t, err, "1 of 3 artifacts failed to be loaded: my-art: denied")
+	assert.EqualError(t, stderrors.Unwrap(err), "denied")
+}
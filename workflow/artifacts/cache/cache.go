@@ -0,0 +1,259 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+// DefaultMaxSize is the max size of a cache, unless one is set
+const DefaultMaxSize = 1024 * 1024 * 1024
+
+const (
+	// dataName is the name of the file, or directory, of an entry which is the loaded artifact
+	dataName = "data"
+	// tmpPrefix prefixes the names of entries being added or evicted, which are not yet, or no longer, cached
+	tmpPrefix = ".tmp-"
+)
+
+// Cache is a local, content-addressed cache of loaded artifacts. Each artifact is cached under its Key, so that
+// loading it again copies it from the cache rather than calling the driver. The cache is shared by every process
+// which uses its directory, e.g. the init containers of a pod which mount the same volume: an entry is added by
+// renaming it into place, so that no process sees it partly written. Once the entries add up to more than the max
+// size, the least recently used are evicted.
+type Cache struct {
+	dir     string
+	maxSize int64
+	// lock guards keyLocks, and is held while entries are evicted
+	lock sync.Mutex
+	// keyLocks serialize the loads of each key by this process, so that it is loaded only once
+	keyLocks map[string]*sync.Mutex
+	// now is the time entries are used at, time.Now if not set
+	now func() time.Time
+}
+
+// New returns a cache of at most maxSize bytes in the directory, which is created if it does not exist
+func New(dir string, maxSize int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &Cache{dir: dir, maxSize: maxSize, keyLocks: map[string]*sync.Mutex{}, now: time.Now}, nil
+}
+
+// ParseMaxSize returns the max size of a cache in bytes, or DefaultMaxSize if it is not set
+func ParseMaxSize(size string) (int64, error) {
+	if size == "" {
+		return DefaultMaxSize, nil
+	}
+	q, err := resource.ParseQuantity(size)
+	if err != nil {
+		return 0, errors.Errorf(errors.CodeBadRequest, "max size must be a quantity of bytes, e.g. 1Gi: %v", err)
+	}
+	if q.Value() < 1 {
+		return 0, errors.Errorf(errors.CodeBadRequest, "max size must be at least 1 byte")
+	}
+	return q.Value(), nil
+}
+
+// Key returns the key of an artifact: a digest of its resolved location, e.g. relocated to the archive location
+// of its template, and its checksum, if it has one
+func Key(art *wfv1.Artifact) (string, error) {
+	data, err := json.Marshal(struct {
+		Location wfv1.ArtifactLocation  `json:"location"`
+		Checksum *wfv1.ArtifactChecksum `json:"checksum,omitempty"`
+	}{art.ArtifactLocation, art.Checksum})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Load copies the artifact cached under the key to path, or if it is not cached, loads it to path with load and
+// then caches it. Concurrent loads of a key by this process wait for the first, so that it is loaded only once,
+// while those of other processes may each load it. A failure to cache an artifact is logged, rather than failing
+// the load.
+func (c *Cache) Load(key, path string, load func(path string) error) error {
+	unlock := c.lockKey(key)
+	defer unlock()
+	entry := filepath.Join(c.dir, key)
+	if c.get(entry, path) {
+		log.WithField("key", key).Infof("Copied cached artifact to %s", path)
+		return nil
+	}
+	if err := load(path); err != nil {
+		return err
+	}
+	if err := c.add(entry, path); err != nil {
+		log.WithField("key", key).Warnf("Failed to cache artifact loaded to %s: %v", path, err)
+	}
+	return nil
+}
+
+func (c *Cache) lockKey(key string) (unlock func()) {
+	c.lock.Lock()
+	keyLock, ok := c.keyLocks[key]
+	if !ok {
+		keyLock = &sync.Mutex{}
+		c.keyLocks[key] = keyLock
+	}
+	c.lock.Unlock()
+	keyLock.Lock()
+	return keyLock.Unlock
+}
+
+// get copies the data of the entry to path, and returns whether it did. An entry evicted while it is copied is a
+// miss.
+func (c *Cache) get(entry, path string) bool {
+	data := filepath.Join(entry, dataName)
+	if _, err := os.Lstat(data); err != nil {
+		return false
+	}
+	now := c.now()
+	if err := os.Chtimes(entry, now, now); err != nil {
+		return false
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return false
+	}
+	if err := copyPath(data, path); err != nil {
+		log.Warnf("Failed to copy cached artifact %s: %v", entry, err)
+		_ = os.RemoveAll(path)
+		return false
+	}
+	return true
+}
+
+// add copies the file or directory at path to the entry, unless it is larger than the cache, and evicts the least
+// recently used entries which no longer fit
+func (c *Cache) add(entry, path string) error {
+	size, err := common.PathSize(path)
+	if err != nil {
+		return err
+	}
+	if size > c.maxSize {
+		log.Infof("Artifact loaded to %s is larger than the cache, so it is not cached", path)
+		return nil
+	}
+	tmp, err := ioutil.TempDir(c.dir, tmpPrefix)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	if err := copyPath(path, filepath.Join(tmp, dataName)); err != nil {
+		return err
+	}
+	now := c.now()
+	if err := os.Chtimes(tmp, now, now); err != nil {
+		return err
+	}
+	// another process may have added the entry since, in which case the rename fails, and its entry is kept
+	if err := os.Rename(tmp, entry); err != nil {
+		if _, statErr := os.Stat(entry); statErr != nil {
+			return err
+		}
+	}
+	return c.evict()
+}
+
+type entryInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+// evict removes the least recently used entries until the rest fit in the cache
+func (c *Cache) evict() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	infos, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	var entries []entryInfo
+	var total int64
+	for _, info := range infos {
+		if !info.IsDir() || strings.HasPrefix(info.Name(), tmpPrefix) {
+			continue
+		}
+		size, err := common.PathSize(filepath.Join(c.dir, info.Name()))
+		if err != nil {
+			// evicted by another process
+			continue
+		}
+		entries = append(entries, entryInfo{name: info.Name(), size: size, modTime: info.ModTime()})
+		total += size
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].modTime.Before(entries[j].modTime) })
+	for _, e := range entries {
+		if total <= c.maxSize {
+			break
+		}
+		// the entry is renamed first, so that it is no longer found while it is removed
+		tmp := filepath.Join(c.dir, tmpPrefix+e.name)
+		if err := os.Rename(filepath.Join(c.dir, e.name), tmp); err == nil {
+			log.WithField("key", e.name).Debugf("Evicting cached artifact of %d bytes", e.size)
+			_ = os.RemoveAll(tmp)
+		}
+		total -= e.size
+	}
+	return nil
+}
+
+// copyPath copies the file, or directory tree, at src to dst, with their modes
+func copyPath(src, dst string) error {
+	return filepath.Walk(src, func(srcPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, srcPath)
+		if err != nil {
+			return err
+		}
+		dstPath := filepath.Join(dst, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(dstPath, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(srcPath)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(target, dstPath)
+		default:
+			return copyFile(srcPath, dstPath, info.Mode().Perm())
+		}
+	})
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
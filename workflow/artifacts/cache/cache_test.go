@@ -0,0 +1,214 @@
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+// newTestCache returns a cache whose clock advances a second each time it is read, so that the order entries are
+// used in is told apart
+func newTestCache(t *testing.T, maxSize int64) (*Cache, string) {
+	tmp, err := ioutil.TempDir("", "cache")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	c, err := New(filepath.Join(tmp, "cache"), maxSize)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	clock := time.Unix(1600000000, 0)
+	c.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	return c, tmp
+}
+
+// writeData returns a load which writes the data to its path, counting its calls
+func writeData(data string, calls *int32) func(string) error {
+	return func(path string) error {
+		atomic.AddInt32(calls, 1)
+		return ioutil.WriteFile(path, []byte(data), 0600)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestCache_Load(t *testing.T) {
+	c, tmp := newTestCache(t, DefaultMaxSize)
+	defer func() { _ = os.RemoveAll(tmp) }()
+	var calls int32
+	load := writeData("my-data", &calls)
+	first := filepath.Join(tmp, "first")
+	if assert.NoError(t, c.Load("my-key", first, load)) {
+		assert.Equal(t, "my-data", readFile(t, first))
+	}
+	// the second load is a copy of the cached artifact
+	second := filepath.Join(tmp, "nested", "second")
+	if assert.NoError(t, c.Load("my-key", second, load)) {
+		assert.Equal(t, "my-data", readFile(t, second))
+	}
+	assert.Equal(t, int32(1), calls)
+	// the copy is independent of the cache
+	assert.NoError(t, ioutil.WriteFile(second, []byte("changed"), 0600))
+	third := filepath.Join(tmp, "third")
+	if assert.NoError(t, c.Load("my-key", third, load)) {
+		assert.Equal(t, "my-data", readFile(t, third))
+	}
+	assert.NoError(t, c.Load("other-key", filepath.Join(tmp, "other"), load))
+	assert.Equal(t, int32(2), calls)
+
+	t.Run("Error", func(t *testing.T) {
+		err := c.Load("failing-key", filepath.Join(tmp, "failing"), func(string) error { return fmt.Errorf("not found") })
+		assert.EqualError(t, err, "not found")
+		// a failed load is not cached
+		var calls int32
+		assert.NoError(t, c.Load("failing-key", filepath.Join(tmp, "failing"), writeData("my-data", &calls)))
+		assert.Equal(t, int32(1), calls)
+	})
+	t.Run("SharedDirectory", func(t *testing.T) {
+		// another process which uses the directory finds the artifacts this one cached
+		other, err := New(c.dir, DefaultMaxSize)
+		if assert.NoError(t, err) {
+			var calls int32
+			assert.NoError(t, other.Load("my-key", filepath.Join(tmp, "other-process"), writeData("other-data", &calls)))
+			assert.Equal(t, int32(0), calls)
+			assert.Equal(t, "my-data", readFile(t, filepath.Join(tmp, "other-process")))
+		}
+	})
+}
+
+func TestCache_LoadDirectory(t *testing.T) {
+	c, tmp := newTestCache(t, DefaultMaxSize)
+	defer func() { _ = os.RemoveAll(tmp) }()
+	var calls int32
+	load := func(path string) error {
+		calls++
+		if err := os.MkdirAll(filepath.Join(path, "sub"), 0700); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(path, "a"), []byte("a"), 0600); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(path, "sub", "b"), []byte("b"), 0755); err != nil {
+			return err
+		}
+		return os.Symlink("a", filepath.Join(path, "link"))
+	}
+	assert.NoError(t, c.Load("my-key", filepath.Join(tmp, "first"), load))
+	dir := filepath.Join(tmp, "second")
+	if assert.NoError(t, c.Load("my-key", dir, load)) {
+		assert.Equal(t, "a", readFile(t, filepath.Join(dir, "a")))
+		assert.Equal(t, "b", readFile(t, filepath.Join(dir, "sub", "b")))
+		info, err := os.Stat(filepath.Join(dir, "sub", "b"))
+		if assert.NoError(t, err) {
+			assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+		}
+		target, err := os.Readlink(filepath.Join(dir, "link"))
+		assert.NoError(t, err)
+		assert.Equal(t, "a", target)
+	}
+	assert.Equal(t, int32(1), calls)
+}
+
+func TestCache_LoadConcurrently(t *testing.T) {
+	c, tmp := newTestCache(t, DefaultMaxSize)
+	defer func() { _ = os.RemoveAll(tmp) }()
+	var calls int32
+	load := func(path string) error {
+		atomic.AddInt32(&calls, 1)
+		// the other loads wait for this one, rather than loading the artifact too
+		time.Sleep(10 * time.Millisecond)
+		return ioutil.WriteFile(path, []byte("my-data"), 0600)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			path := filepath.Join(tmp, fmt.Sprintf("load-%d", i))
+			if assert.NoError(t, c.Load("my-key", path, load)) {
+				assert.Equal(t, "my-data", readFile(t, path))
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), calls)
+}
+
+func TestCache_Evict(t *testing.T) {
+	// there is room for two artifacts of 3 bytes
+	c, tmp := newTestCache(t, 7)
+	defer func() { _ = os.RemoveAll(tmp) }()
+	var calls int32
+	load := writeData("abc", &calls)
+	assert.NoError(t, c.Load("a", filepath.Join(tmp, "a-1"), load))
+	assert.NoError(t, c.Load("b", filepath.Join(tmp, "b-1"), load))
+	// a is used after b, so b is the least recently used
+	assert.NoError(t, c.Load("a", filepath.Join(tmp, "a-2"), load))
+	assert.NoError(t, c.Load("c", filepath.Join(tmp, "c-1"), load))
+	assert.Equal(t, int32(3), calls)
+	assert.NoError(t, c.Load("a", filepath.Join(tmp, "a-3"), load))
+	assert.NoError(t, c.Load("c", filepath.Join(tmp, "c-2"), load))
+	assert.Equal(t, int32(3), calls)
+	assert.NoError(t, c.Load("b", filepath.Join(tmp, "b-2"), load))
+	assert.Equal(t, int32(4), calls)
+
+	t.Run("TooLarge", func(t *testing.T) {
+		var calls int32
+		load := writeData("larger than the cache", &calls)
+		assert.NoError(t, c.Load("large", filepath.Join(tmp, "large-1"), load))
+		assert.NoError(t, c.Load("large", filepath.Join(tmp, "large-2"), load))
+		assert.Equal(t, int32(2), calls)
+		assert.Equal(t, "larger than the cache", readFile(t, filepath.Join(tmp, "large-2")))
+	})
+}
+
+func TestKey(t *testing.T) {
+	newArtifact := func(key string, checksum *wfv1.ArtifactChecksum) *wfv1.Artifact {
+		return &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: key}}, Checksum: checksum}
+	}
+	key, err := Key(newArtifact("my-key", nil))
+	if !assert.NoError(t, err) {
+		return
+	}
+	// the name is not part of the key, so that artifacts of the same location share it
+	other := newArtifact("my-key", nil)
+	other.Name = "other-art"
+	assert.Equal(t, key, mustKey(t, other))
+	assert.NotEqual(t, key, mustKey(t, newArtifact("other-key", nil)))
+	assert.NotEqual(t, key, mustKey(t, newArtifact("my-key", &wfv1.ArtifactChecksum{Algorithm: wfv1.ChecksumAlgorithmSHA256, Value: "0123"})))
+}
+
+func mustKey(t *testing.T, art *wfv1.Artifact) string {
+	key, err := Key(art)
+	assert.NoError(t, err)
+	return key
+}
+
+func TestParseMaxSize(t *testing.T) {
+	size, err := ParseMaxSize("")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(DefaultMaxSize), size)
+	size, err = ParseMaxSize("10Gi")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10*1024*1024*1024), size)
+	_, err = ParseMaxSize("lots")
+	assert.Error(t, err)
+	_, err = ParseMaxSize("0")
+	assert.Error(t, err)
+}
@@ -0,0 +1,83 @@
+package executor
+
+import (
+	stderrors "errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/adls"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/artifactory"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/b2"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/devnull"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/dropbox"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/filesystem"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/ftp"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/gcs"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/gdrive"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/git"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/hdfs"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/http"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/huggingface"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/ipfs"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/memory"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/oci"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/oss"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/raw"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/rclone"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/s3"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/sftp"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/swift"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/webdav"
+)
+
+func TestCapabilities(t *testing.T) {
+	t.Run("Builtin", func(t *testing.T) {
+		for name, driver := range map[string]ArtifactDriver{
+			DriverADLS:        &adls.ArtifactDriver{},
+			DriverArtifactory: &artifactory.ArtifactoryArtifactDriver{},
+			DriverB2:          &b2.ArtifactDriver{},
+			DriverDevNull:     &devnull.ArtifactDriver{},
+			DriverDropbox:     &dropbox.ArtifactDriver{},
+			DriverFilesystem:  &filesystem.ArtifactDriver{},
+			DriverFTP:         &ftp.ArtifactDriver{},
+			DriverGCS:         &gcs.ArtifactDriver{},
+			DriverGDrive:      &gdrive.ArtifactDriver{},
+			DriverGit:         &git.GitArtifactDriver{},
+			DriverHDFS:        &hdfs.ArtifactDriver{},
+			DriverHTTP:        &http.HTTPArtifactDriver{},
+			DriverHuggingFace: &huggingface.ArtifactDriver{},
+			DriverIPFS:        &ipfs.ArtifactDriver{},
+			"memory":          memory.NewArtifactDriver(),
+			DriverOCI:         &oci.ArtifactDriver{},
+			DriverOSS:         &oss.OSSArtifactDriver{},
+			DriverRaw:         &raw.RawArtifactDriver{},
+			DriverRclone:      &rclone.ArtifactDriver{},
+			DriverS3:          &s3.S3ArtifactDriver{},
+			DriverSFTP:        &sftp.ArtifactDriver{},
+			DriverSwift:       &swift.ArtifactDriver{},
+			DriverWebDAV:      &webdav.ArtifactDriver{},
+		} {
+			_, ok := driver.(CapabilityReporter)
+			if !assert.True(t, ok, name) {
+				continue
+			}
+			capabilities := Capabilities(driver)
+			// every capability but deleting is that of an interface the driver implements, and every interface it
+			// implements is a capability
+			implemented := implementedCapabilities(driver)
+			implemented.Delete = capabilities.Delete
+			assert.Equal(t, implemented, capabilities, name)
+			if !capabilities.Delete {
+				assert.True(t, stderrors.Is(driver.Delete(&wfv1.Artifact{}), common.ErrDeletionNotSupported), name)
+			}
+		}
+	})
+	t.Run("NotReported", func(t *testing.T) {
+		// a driver which does not report its capabilities has those of the interfaces it implements
+		driver := struct{ ArtifactDriver }{memory.NewArtifactDriver()}
+		assert.Equal(t, common.DriverCapabilities{Delete: true}, Capabilities(driver))
+	})
+}
@@ -0,0 +1,264 @@
+package common
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+)
+
+// DefaultCircuitBreakers are the circuit breakers shared by the requests of every driver which sends its requests with
+// the RoundTripper NewDriver sets, e.g. those of the environment of the executor, or nil if requests are not broken
+var DefaultCircuitBreakers *CircuitBreakers
+
+const (
+	defaultCircuitBreakerWindow      = 20
+	defaultCircuitBreakerMinRequests = 10
+	defaultCircuitBreakerCooldown    = 30 * time.Second
+)
+
+// ErrCircuitOpen is the error of a request which was not sent because the circuit breaker of its endpoint is open
+var ErrCircuitOpen = stderrors.New("circuit breaker is open")
+
+// CircuitState is the state of a circuit breaker
+type CircuitState string
+
+const (
+	// CircuitClosed breakers send every request
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen breakers send no requests until their cooldown has elapsed
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen breakers send a single request, which closes them if it succeeds, or opens them again if not
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitBreakerConfig configures when circuit breakers open, and for how long
+type CircuitBreakerConfig struct {
+	// FailureRate is the fraction of the requests of the window which must fail for a breaker to open, in (0, 1]
+	FailureRate float64
+	// Window is the number of the most recent requests the failure rate is of, which defaults to 20
+	Window int
+	// MinRequests is the number of requests of the window there must be before a breaker opens, which defaults to 10
+	MinRequests int
+	// Cooldown is how long a breaker is open before it is half-open, which defaults to 30s
+	Cooldown time.Duration
+}
+
+// ParseCircuitBreakerConfig parses the failure rate, e.g. "0.5", and cooldown, e.g. "1m", of circuit breakers, or
+// returns nil if the failure rate is not set, as breakers are then disabled
+func ParseCircuitBreakerConfig(failureRate, cooldown string) (*CircuitBreakerConfig, error) {
+	if failureRate == "" {
+		return nil, nil
+	}
+	config := &CircuitBreakerConfig{}
+	rate, err := strconv.ParseFloat(failureRate, 64)
+	if err != nil || rate <= 0 || rate > 1 {
+		return nil, errors.Errorf(errors.CodeBadRequest, "failure rate %q must be a number greater than 0 and at most 1", failureRate)
+	}
+	config.FailureRate = rate
+	if cooldown != "" {
+		config.Cooldown, err = time.ParseDuration(cooldown)
+		if err != nil || config.Cooldown <= 0 {
+			return nil, errors.Errorf(errors.CodeBadRequest, "cooldown %q must be a positive duration, e.g. 30s", cooldown)
+		}
+	}
+	return config, nil
+}
+
+// CircuitBreakers are the circuit breakers of endpoints, e.g. https://s3.amazonaws.com, so that the failures of one
+// backend do not stop the requests to others
+type CircuitBreakers struct {
+	config   CircuitBreakerConfig
+	now      func() time.Time
+	lock     sync.Mutex
+	breakers map[string]*CircuitBreaker
+}
+
+// NewCircuitBreakers returns the circuit breakers of config, with the defaults of the fields it does not set
+func NewCircuitBreakers(config CircuitBreakerConfig) *CircuitBreakers {
+	if config.Window <= 0 {
+		config.Window = defaultCircuitBreakerWindow
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = defaultCircuitBreakerMinRequests
+	}
+	if config.MinRequests > config.Window {
+		config.MinRequests = config.Window
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = defaultCircuitBreakerCooldown
+	}
+	return &CircuitBreakers{config: config, now: time.Now, breakers: map[string]*CircuitBreaker{}}
+}
+
+// Breaker returns the circuit breaker of the endpoint, which is created closed if it has none
+func (c *CircuitBreakers) Breaker(endpoint string) *CircuitBreaker {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	b, ok := c.breakers[endpoint]
+	if !ok {
+		b = &CircuitBreaker{endpoint: endpoint, config: c.config, now: c.now, outcomes: make([]bool, 0, c.config.Window)}
+		c.breakers[endpoint] = b
+	}
+	return b
+}
+
+// States returns the state of the breaker of each endpoint which has sent a request
+func (c *CircuitBreakers) States() map[string]CircuitState {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	states := make(map[string]CircuitState, len(c.breakers))
+	for endpoint, b := range c.breakers {
+		states[endpoint] = b.State()
+	}
+	return states
+}
+
+// CircuitBreaker opens when the failure rate of the recent requests to its endpoint reaches that of its config, and
+// then rejects requests for the cooldown, so that the retries of many operations do not overload a recovering backend
+type CircuitBreaker struct {
+	endpoint string
+	config   CircuitBreakerConfig
+	now      func() time.Time
+	lock     sync.Mutex
+	state    CircuitState
+	// outcomes are whether each of the requests of the window failed, in a ring from next
+	outcomes []bool
+	next     int
+	failures int
+	openedAt time.Time
+	// probing is whether the single request of a half-open breaker has been allowed, and has not finished
+	probing bool
+}
+
+// State returns the state of the breaker, which is half-open once the cooldown of an open breaker has elapsed
+func (b *CircuitBreaker) State() CircuitState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.currentState()
+}
+
+func (b *CircuitBreaker) currentState() CircuitState {
+	switch {
+	case b.state == "":
+		return CircuitClosed
+	case b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.config.Cooldown:
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// Allow returns an error which wraps ErrCircuitOpen if a request may not be sent, or otherwise nil, when the outcome
+// of the request must be recorded with Record
+func (b *CircuitBreaker) Allow() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	switch b.currentState() {
+	case CircuitOpen:
+		return fmt.Errorf("%w for %s, retry in %v", ErrCircuitOpen, b.endpoint, (b.config.Cooldown - b.now().Sub(b.openedAt)).Round(time.Second))
+	case CircuitHalfOpen:
+		if b.probing {
+			return fmt.Errorf("%w for %s, which is waiting for the outcome of a request", ErrCircuitOpen, b.endpoint)
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+	}
+	return nil
+}
+
+// Record records whether an allowed request failed
+func (b *CircuitBreaker) Record(failed bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.state == CircuitHalfOpen {
+		b.probing = false
+		if failed {
+			b.open()
+		} else {
+			b.reset()
+		}
+		return
+	}
+	if b.state == CircuitOpen {
+		// a request allowed before the breaker opened
+		return
+	}
+	if len(b.outcomes) < b.config.Window {
+		b.outcomes = append(b.outcomes, failed)
+	} else {
+		if b.outcomes[b.next] {
+			b.failures--
+		}
+		b.outcomes[b.next] = failed
+		b.next = (b.next + 1) % b.config.Window
+	}
+	if failed {
+		b.failures++
+	}
+	if len(b.outcomes) >= b.config.MinRequests && float64(b.failures) >= b.config.FailureRate*float64(len(b.outcomes)) {
+		b.open()
+	}
+}
+
+// abandon records that an allowed request has no outcome, so that a half-open breaker allows another
+func (b *CircuitBreaker) abandon() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.probing = false
+}
+
+func (b *CircuitBreaker) open() {
+	b.state = CircuitOpen
+	b.openedAt = b.now()
+}
+
+func (b *CircuitBreaker) reset() {
+	b.state = CircuitClosed
+	b.outcomes = b.outcomes[:0]
+	b.next = 0
+	b.failures = 0
+}
+
+// NewCircuitBreakerTransport returns a RoundTripper which sends requests with base unless the breaker of their endpoint
+// is open, when it returns an error which wraps ErrCircuitOpen. Responses of 429 and 5xx statuses, and errors other
+// than the cancellation of the request, are failures. It is base if breakers is nil.
+func NewCircuitBreakerTransport(base http.RoundTripper, breakers *CircuitBreakers) http.RoundTripper {
+	if breakers == nil {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return circuitBreakerTransport{base: base, breakers: breakers}
+}
+
+type circuitBreakerTransport struct {
+	base     http.RoundTripper
+	breakers *CircuitBreakers
+}
+
+func (t circuitBreakerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	breaker := t.breakers.Breaker(r.URL.Scheme + "://" + r.URL.Host)
+	if err := breaker.Allow(); err != nil {
+		if r.Body != nil {
+			_ = r.Body.Close()
+		}
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(r)
+	switch {
+	case err != nil && stderrors.Is(r.Context().Err(), context.Canceled):
+		// the outcome of a cancelled request says nothing of the endpoint
+		breaker.abandon()
+	case err != nil:
+		breaker.Record(true)
+	default:
+		breaker.Record(resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500)
+	}
+	return resp, err
+}
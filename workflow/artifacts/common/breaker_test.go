@@ -0,0 +1,157 @@
+package common
+
+import (
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCircuitBreakerConfig(t *testing.T) {
+	config, err := ParseCircuitBreakerConfig("", "1m")
+	assert.NoError(t, err)
+	assert.Nil(t, config)
+	config, err = ParseCircuitBreakerConfig("0.5", "1m")
+	if assert.NoError(t, err) {
+		assert.Equal(t, &CircuitBreakerConfig{FailureRate: 0.5, Cooldown: time.Minute}, config)
+	}
+	for _, invalid := range [][2]string{{"half", ""}, {"0", ""}, {"1.5", ""}, {"0.5", "soon"}, {"0.5", "-1s"}} {
+		_, err := ParseCircuitBreakerConfig(invalid[0], invalid[1])
+		assert.Error(t, err, invalid)
+	}
+}
+
+// newTestCircuitBreakers returns breakers whose clock is advanced by the returned function
+func newTestCircuitBreakers(config CircuitBreakerConfig) (*CircuitBreakers, func(time.Duration)) {
+	now := time.Now()
+	breakers := NewCircuitBreakers(config)
+	breakers.now = func() time.Time { return now }
+	return breakers, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	config := CircuitBreakerConfig{FailureRate: 0.5, Window: 10, MinRequests: 4, Cooldown: time.Minute}
+	t.Run("SustainedFailures", func(t *testing.T) {
+		breakers, advance := newTestCircuitBreakers(config)
+		b := breakers.Breaker("https://my-endpoint")
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, b.Allow())
+			b.Record(true)
+		}
+		// too few requests to open
+		assert.Equal(t, CircuitClosed, b.State())
+		assert.NoError(t, b.Allow())
+		b.Record(true)
+		assert.Equal(t, CircuitOpen, b.State())
+		err := b.Allow()
+		assert.True(t, stderrors.Is(err, ErrCircuitOpen))
+		assert.Contains(t, err.Error(), "https://my-endpoint")
+		advance(59 * time.Second)
+		assert.Equal(t, CircuitOpen, b.State())
+		advance(time.Second)
+		assert.Equal(t, CircuitHalfOpen, b.State())
+		// a single request is allowed while half-open
+		assert.NoError(t, b.Allow())
+		assert.True(t, stderrors.Is(b.Allow(), ErrCircuitOpen))
+		b.Record(true)
+		assert.Equal(t, CircuitOpen, b.State())
+		advance(time.Minute)
+		assert.NoError(t, b.Allow())
+		b.Record(false)
+		assert.Equal(t, CircuitClosed, b.State())
+		// the failures before it opened are forgotten
+		assert.NoError(t, b.Allow())
+		b.Record(true)
+		assert.Equal(t, CircuitClosed, b.State())
+	})
+	t.Run("FailureRate", func(t *testing.T) {
+		breakers, _ := newTestCircuitBreakers(config)
+		b := breakers.Breaker("https://my-endpoint")
+		for i := 0; i < 20; i++ {
+			assert.NoError(t, b.Allow())
+			// every fourth request fails, which is less than the failure rate
+			b.Record(i%4 == 0)
+		}
+		assert.Equal(t, CircuitClosed, b.State())
+		for i := 0; i < 4; i++ {
+			b.Record(true)
+		}
+		assert.Equal(t, CircuitOpen, b.State())
+	})
+	t.Run("Abandoned", func(t *testing.T) {
+		breakers, advance := newTestCircuitBreakers(config)
+		b := breakers.Breaker("https://my-endpoint")
+		for i := 0; i < 4; i++ {
+			b.Record(true)
+		}
+		advance(time.Minute)
+		assert.NoError(t, b.Allow())
+		b.abandon()
+		assert.NoError(t, b.Allow())
+	})
+}
+
+func TestCircuitBreakers(t *testing.T) {
+	breakers, _ := newTestCircuitBreakers(CircuitBreakerConfig{FailureRate: 1, MinRequests: 2})
+	assert.Empty(t, breakers.States())
+	bad := breakers.Breaker("https://bad")
+	bad.Record(true)
+	bad.Record(true)
+	assert.NoError(t, breakers.Breaker("https://good").Allow())
+	assert.Same(t, bad, breakers.Breaker("https://bad"))
+	assert.Equal(t, map[string]CircuitState{"https://bad": CircuitOpen, "https://good": CircuitClosed}, breakers.States())
+}
+
+func TestNewCircuitBreakerTransport(t *testing.T) {
+	var failing int32 = 1
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer healthy.Close()
+	assert.Same(t, http.DefaultTransport, NewCircuitBreakerTransport(http.DefaultTransport, nil))
+	breakers, advance := newTestCircuitBreakers(CircuitBreakerConfig{FailureRate: 0.5, Window: 10, MinRequests: 4, Cooldown: time.Minute})
+	client := &http.Client{Transport: NewCircuitBreakerTransport(nil, breakers)}
+	get := func(url string) (int, error) {
+		resp, err := client.Get(url)
+		if err != nil {
+			return 0, err
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+	// sustained failures of the server open its breaker, which rejects requests without sending them
+	for i := 0; i < 4; i++ {
+		status, err := get(server.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+	}
+	for i := 0; i < 10; i++ {
+		_, err := get(server.URL)
+		assert.True(t, stderrors.Is(err, ErrCircuitOpen))
+	}
+	assert.Equal(t, int32(4), atomic.LoadInt32(&requests))
+	// the breakers of other endpoints are not opened
+	status, err := get(healthy.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, CircuitOpen, breakers.States()[server.URL])
+	assert.Equal(t, CircuitClosed, breakers.States()[healthy.URL])
+	// once the cooldown elapses, the request of the half-open breaker closes it if it succeeds
+	advance(time.Minute)
+	assert.Equal(t, CircuitHalfOpen, breakers.States()[server.URL])
+	atomic.StoreInt32(&failing, 0)
+	status, err = get(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, CircuitClosed, breakers.States()[server.URL])
+}
@@ -0,0 +1,47 @@
+package common
+
+// DriverCapabilities are the optional operations an artifact driver supports, so that callers can tell which they can
+// use, e.g. to fall back to loading all of an artifact if it cannot load a range of it, without asserting the
+// interfaces of the driver
+type DriverCapabilities struct {
+	// Delete is whether the driver deletes artifacts, rather than returning ErrDeletionNotSupported
+	Delete bool `json:"delete,omitempty"`
+	// Stream is whether the driver opens artifacts for reading without writing them to disk (ArtifactStreamer)
+	Stream bool `json:"stream,omitempty"`
+	// LoadRange is whether the driver loads ranges of the bytes of artifacts (RangeLoader)
+	LoadRange bool `json:"loadRange,omitempty"`
+	// Exists is whether the driver checks whether artifacts exist without loading them (ArtifactExistenceChecker)
+	Exists bool `json:"exists,omitempty"`
+	// ExistsBatch is whether the driver checks whether many artifacts exist at once (BatchExistenceChecker)
+	ExistsBatch bool `json:"existsBatch,omitempty"`
+	// VerifyChecksum is whether the driver verifies checksums with the metadata of its storage (ChecksumVerifier)
+	VerifyChecksum bool `json:"verifyChecksum,omitempty"`
+	// HealthCheck is whether the driver checks that its storage is reachable (HealthChecker)
+	HealthCheck bool `json:"healthCheck,omitempty"`
+	// Progress is whether the driver reports the progress of its transfers (ArtifactProgressReporter)
+	Progress bool `json:"progress,omitempty"`
+	// ContentEncoding is whether the driver records the compression of saved artifacts (ContentEncodingRecorder)
+	ContentEncoding bool `json:"contentEncoding,omitempty"`
+	// OverwriteProtection is whether the driver honours overwrite policies (OverwriteProtector)
+	OverwriteProtection bool `json:"overwriteProtection,omitempty"`
+	// SkipUnchanged is whether the driver honours the skip-if-unchanged overwrite policy (UnchangedSkipper)
+	SkipUnchanged bool `json:"skipUnchanged,omitempty"`
+	// AtomicSave is whether the driver saves directories atomically (AtomicSaver)
+	AtomicSave bool `json:"atomicSave,omitempty"`
+	// IdempotentSave is whether the driver skips saves which a previous attempt completed (IdempotentSaver)
+	IdempotentSave bool `json:"idempotentSave,omitempty"`
+	// MaxSize is whether the driver fails loads of artifacts larger than their max size (SizeLimiter)
+	MaxSize bool `json:"maxSize,omitempty"`
+	// Metadata is whether the driver saves and returns the user metadata of objects (MetadataRecorder)
+	Metadata bool `json:"metadata,omitempty"`
+	// DryRunSave is whether the driver checks the destinations of artifacts without writing to them (SaveDryRunner)
+	DryRunSave bool `json:"dryRunSave,omitempty"`
+	// SaveResult is whether the driver returns what saving an artifact wrote (SaveResultReporter)
+	SaveResult bool `json:"saveResult,omitempty"`
+	// Copy is whether the driver copies artifacts server-side (ArtifactCopier)
+	Copy bool `json:"copy,omitempty"`
+	// Presign is whether the driver presigns URLs of objects (ArtifactPresigner)
+	Presign bool `json:"presign,omitempty"`
+	// PresignPostPolicy is whether the driver presigns POST policies (PostPolicyPresigner)
+	PresignPostPolicy bool `json:"presignPostPolicy,omitempty"`
+}
@@ -0,0 +1,100 @@
+package common
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+// ValidateChecksum validates the expected checksum of an artifact
+func ValidateChecksum(errPrefix string, checksum *wfv1.ArtifactChecksum) error {
+	if _, err := newHash(checksum.Algorithm); err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s.algorithm: %v", errPrefix, err)
+	}
+	if checksum.Value == "" && len(checksum.Files) == 0 {
+		return errors.Errorf(errors.CodeBadRequest, "either %s.value or %s.files is required", errPrefix, errPrefix)
+	}
+	if checksum.Value != "" && len(checksum.Files) > 0 {
+		return errors.Errorf(errors.CodeBadRequest, "%s.value and %s.files cannot both be specified", errPrefix, errPrefix)
+	}
+	return nil
+}
+
+func newHash(algorithm wfv1.ChecksumAlgorithm) (hash.Hash, error) {
+	switch algorithm {
+	case wfv1.ChecksumAlgorithmSHA256:
+		return sha256.New(), nil
+	case wfv1.ChecksumAlgorithmMD5:
+		return md5.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+	}
+}
+
+// FileChecksum returns the hex encoded digest of the file at path
+func FileChecksum(algorithm wfv1.ChecksumAlgorithm, path string) (string, error) {
+	h, err := newHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// VerifyChecksum verifies that the artifact loaded at path matches the expected checksum. A file is
+// compared with checksum.Value, and the files of a directory with the checksum.Files manifest.
+func VerifyChecksum(checksum *wfv1.ArtifactChecksum, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		if checksum.Value == "" {
+			return errors.Errorf(errors.CodeBadRequest, "artifact %s is a file, but the checksum has no value", path)
+		}
+		return verifyFile(checksum.Algorithm, path, checksum.Value)
+	}
+	if len(checksum.Files) == 0 {
+		return errors.Errorf(errors.CodeBadRequest, "artifact %s is a directory, but the checksum has no manifest of files", path)
+	}
+	// verify in a stable order so that the same file is reported on every attempt
+	names := make([]string, 0, len(checksum.Files))
+	for name := range checksum.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := verifyFile(checksum.Algorithm, filepath.Join(path, filepath.FromSlash(name)), checksum.Files[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func verifyFile(algorithm wfv1.ChecksumAlgorithm, path, expected string) error {
+	actual, err := FileChecksum(algorithm, path)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(actual, expected) {
+		return errors.InternalErrorf("%s checksum of %s does not match: expected %s, got %s", algorithm, path, expected, actual)
+	}
+	return nil
+}
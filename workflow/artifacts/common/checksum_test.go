@@ -0,0 +1,81 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+const (
+	helloSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	helloMD5    = "5d41402abc4b2a76b9719d911017c592"
+	worldSHA256 = "486ea46224d1bb4fb680f34f7c9ad96a8f24ec88be73ea8e5a6c65260e9cb8a7"
+)
+
+func TestVerifyChecksum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checksum")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "hello"), []byte("hello"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "world"), []byte("world"), 0600))
+	file := filepath.Join(dir, "hello")
+
+	t.Run("SHA256", func(t *testing.T) {
+		assert.NoError(t, VerifyChecksum(&wfv1.ArtifactChecksum{Algorithm: wfv1.ChecksumAlgorithmSHA256, Value: helloSHA256}, file))
+	})
+	t.Run("MD5", func(t *testing.T) {
+		assert.NoError(t, VerifyChecksum(&wfv1.ArtifactChecksum{Algorithm: wfv1.ChecksumAlgorithmMD5, Value: helloMD5}, file))
+	})
+	t.Run("UpperCase", func(t *testing.T) {
+		assert.NoError(t, VerifyChecksum(&wfv1.ArtifactChecksum{Algorithm: wfv1.ChecksumAlgorithmMD5, Value: "5D41402ABC4B2A76B9719D911017C592"}, file))
+	})
+	t.Run("Mismatch", func(t *testing.T) {
+		err := VerifyChecksum(&wfv1.ArtifactChecksum{Algorithm: wfv1.ChecksumAlgorithmSHA256, Value: worldSHA256}, file)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "does not match")
+		}
+	})
+	t.Run("UnsupportedAlgorithm", func(t *testing.T) {
+		assert.Error(t, VerifyChecksum(&wfv1.ArtifactChecksum{Algorithm: "crc32", Value: "3610a686"}, file))
+	})
+	t.Run("Manifest", func(t *testing.T) {
+		assert.NoError(t, VerifyChecksum(&wfv1.ArtifactChecksum{
+			Algorithm: wfv1.ChecksumAlgorithmSHA256,
+			Files:     map[string]string{"hello": helloSHA256, "sub/world": worldSHA256},
+		}, dir))
+	})
+	t.Run("ManifestMismatch", func(t *testing.T) {
+		err := VerifyChecksum(&wfv1.ArtifactChecksum{
+			Algorithm: wfv1.ChecksumAlgorithmSHA256,
+			Files:     map[string]string{"hello": helloSHA256, "sub/world": helloSHA256},
+		}, dir)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "world")
+		}
+	})
+	t.Run("ManifestMissingFile", func(t *testing.T) {
+		assert.Error(t, VerifyChecksum(&wfv1.ArtifactChecksum{
+			Algorithm: wfv1.ChecksumAlgorithmSHA256,
+			Files:     map[string]string{"missing": helloSHA256},
+		}, dir))
+	})
+	t.Run("DirectoryWithoutManifest", func(t *testing.T) {
+		assert.Error(t, VerifyChecksum(&wfv1.ArtifactChecksum{Algorithm: wfv1.ChecksumAlgorithmSHA256, Value: helloSHA256}, dir))
+	})
+}
+
+func TestValidateChecksum(t *testing.T) {
+	assert.NoError(t, ValidateChecksum("checksum", &wfv1.ArtifactChecksum{Algorithm: wfv1.ChecksumAlgorithmMD5, Value: helloMD5}))
+	assert.NoError(t, ValidateChecksum("checksum", &wfv1.ArtifactChecksum{Algorithm: wfv1.ChecksumAlgorithmSHA256, Files: map[string]string{"hello": helloSHA256}}))
+	assert.Error(t, ValidateChecksum("checksum", &wfv1.ArtifactChecksum{Algorithm: "sha1", Value: helloMD5}))
+	assert.Error(t, ValidateChecksum("checksum", &wfv1.ArtifactChecksum{Algorithm: wfv1.ChecksumAlgorithmMD5}))
+	assert.Error(t, ValidateChecksum("checksum", &wfv1.ArtifactChecksum{Algorithm: wfv1.ChecksumAlgorithmSHA256, Value: helloSHA256, Files: map[string]string{"hello": helloSHA256}}))
+}
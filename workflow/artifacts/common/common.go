@@ -0,0 +1,54 @@
+package common
+
+import (
+	"context"
+	stderrors "errors"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+)
+
+// ErrDeletionNotSupported is returned by drivers whose storage does not support deleting artifacts
+var ErrDeletionNotSupported = errors.New(errors.CodeNotImplemented, "delete not supported for this artifact storage")
+
+// ErrArtifactNotFound is matched, using errors.Is, by the errors drivers return when an artifact does not exist
+var ErrArtifactNotFound = stderrors.New("artifact not found")
+
+// NewNotFoundError returns an ERR_NOT_FOUND error which matches ErrArtifactNotFound and wraps the original error
+func NewNotFoundError(err error) error {
+	return errors.Wrap(notFoundError{err}, errors.CodeNotFound, err.Error())
+}
+
+type notFoundError struct {
+	err error
+}
+
+func (e notFoundError) Error() string {
+	return e.err.Error()
+}
+
+func (e notFoundError) Unwrap() error {
+	return e.err
+}
+
+func (e notFoundError) Is(target error) bool {
+	return target == ErrArtifactNotFound
+}
+
+// CloseOnCancel calls closeFn if ctx is cancelled before the returned stop function is called. It aborts the
+// transfers of clients which do not take a context, by closing their connections.
+func CloseOnCancel(ctx context.Context, closeFn func()) (stop func()) {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			closeFn()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+	}
+}
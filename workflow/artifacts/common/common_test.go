@@ -0,0 +1,46 @@
+package common
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+)
+
+func TestNewNotFoundError(t *testing.T) {
+	orig := fmt.Errorf("NoSuchKey")
+	err := NewNotFoundError(orig)
+	assert.Equal(t, "NoSuchKey", err.Error())
+	assert.True(t, errors.IsCode(errors.CodeNotFound, err))
+	assert.True(t, stderrors.Is(err, ErrArtifactNotFound))
+	assert.True(t, stderrors.Is(err, orig))
+	assert.False(t, stderrors.Is(orig, ErrArtifactNotFound))
+}
+
+func TestCloseOnCancel(t *testing.T) {
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		closed := make(chan struct{})
+		stop := CloseOnCancel(ctx, func() { close(closed) })
+		defer stop()
+		cancel()
+		select {
+		case <-closed:
+		case <-time.After(time.Second):
+			assert.Fail(t, "not closed")
+		}
+	})
+	t.Run("Stopped", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		closed := false
+		stop := CloseOnCancel(ctx, func() { closed = true })
+		stop()
+		cancel()
+		assert.False(t, closed)
+	})
+}
@@ -0,0 +1,144 @@
+package common
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+// ContentEncodingMetadataKey is the key of the user metadata of an object which records the compression of
+// the artifact it stores. It is not recorded as the Content-Encoding of the object, which some storage and
+// HTTP clients decompress themselves.
+const ContentEncodingMetadataKey = "argo-content-encoding"
+
+// ValidateCompression validates the compression of an output artifact
+func ValidateCompression(errPrefix string, compression wfv1.ArtifactCompression) error {
+	switch compression {
+	case "", wfv1.ArtifactCompressionNone, wfv1.ArtifactCompressionGzip, wfv1.ArtifactCompressionZstd:
+		return nil
+	}
+	return errors.Errorf(errors.CodeBadRequest, "%s.compression %q must be one of: none, gzip, zstd", errPrefix, compression)
+}
+
+// IsCompressed returns whether the artifact is compressed before it is saved
+func IsCompressed(compression wfv1.ArtifactCompression) bool {
+	return compression != "" && compression != wfv1.ArtifactCompressionNone
+}
+
+// CompressFile writes the file at src compressed with the codec to dst
+func CompressFile(compression wfv1.ArtifactCompression, src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = out.Close() }()
+	var w io.WriteCloser
+	switch compression {
+	case wfv1.ArtifactCompressionGzip:
+		w = gzip.NewWriter(out)
+	case wfv1.ArtifactCompressionZstd:
+		w, err = zstd.NewWriter(out)
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "unsupported compression %q", compression)
+	}
+	if _, err := io.Copy(w, in); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// NewDecompressReader returns a reader of the content of r, which is compressed with the encoding. Closing the
+// returned reader closes r.
+func NewDecompressReader(encoding string, r io.ReadCloser) (io.ReadCloser, error) {
+	switch wfv1.ArtifactCompression(encoding) {
+	case wfv1.ArtifactCompressionGzip:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return &decompressReader{Reader: zr, close: zr.Close, src: r}, nil
+	case wfv1.ArtifactCompressionZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return &decompressReader{Reader: zr, close: func() error { zr.Close(); return nil }, src: r}, nil
+	}
+	return nil, errors.Errorf(errors.CodeBadRequest, "unsupported content encoding %q", encoding)
+}
+
+type decompressReader struct {
+	io.Reader
+	close func() error
+	src   io.Closer
+}
+
+func (r *decompressReader) Close() error {
+	err := r.close()
+	if srcErr := r.src.Close(); err == nil {
+		err = srcErr
+	}
+	return err
+}
+
+// DecompressFile replaces the file at path, which is compressed with the encoding, with its decompressed content
+func DecompressFile(encoding string, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmpPath, err := decompressFile(encoding, path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, info.Mode()); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// decompressFile writes the decompressed content of the file at path to a temporary file next to it
+func decompressFile(encoding string, path string) (string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	r, err := NewDecompressReader(encoding, in)
+	if err != nil {
+		_ = in.Close()
+		return "", err
+	}
+	defer func() { _ = r.Close() }()
+	out, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".decompress")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(out, r)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+func TestCompressFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compression")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	data := []byte(strings.Repeat("hello world\n", 1000))
+	src := filepath.Join(dir, "src")
+	assert.NoError(t, ioutil.WriteFile(src, data, 0640))
+	for _, compression := range []wfv1.ArtifactCompression{wfv1.ArtifactCompressionGzip, wfv1.ArtifactCompressionZstd} {
+		t.Run(string(compression), func(t *testing.T) {
+			dst := filepath.Join(dir, string(compression))
+			if !assert.NoError(t, CompressFile(compression, src, dst)) {
+				return
+			}
+			compressed, err := ioutil.ReadFile(dst)
+			assert.NoError(t, err)
+			assert.Less(t, len(compressed), len(data))
+			assert.NoError(t, os.Chmod(dst, 0640))
+			if assert.NoError(t, DecompressFile(string(compression), dst)) {
+				decompressed, err := ioutil.ReadFile(dst)
+				assert.NoError(t, err)
+				assert.Equal(t, data, decompressed)
+				info, err := os.Stat(dst)
+				assert.NoError(t, err)
+				assert.Equal(t, os.FileMode(0640), info.Mode())
+			}
+		})
+	}
+	t.Run("Corrupt", func(t *testing.T) {
+		assert.Error(t, DecompressFile("gzip", src))
+		// the file is left as it was
+		content, err := ioutil.ReadFile(src)
+		assert.NoError(t, err)
+		assert.Equal(t, data, content)
+	})
+	t.Run("Unsupported", func(t *testing.T) {
+		assert.Error(t, CompressFile("brotli", src, filepath.Join(dir, "brotli")))
+		assert.Error(t, DecompressFile("brotli", src))
+	})
+}
+
+func TestValidateCompression(t *testing.T) {
+	for _, compression := range []wfv1.ArtifactCompression{"", wfv1.ArtifactCompressionNone, wfv1.ArtifactCompressionGzip, wfv1.ArtifactCompressionZstd} {
+		assert.NoError(t, ValidateCompression("my-art", compression))
+	}
+	assert.EqualError(t, ValidateCompression("my-art", "brotli"), `my-art.compression "brotli" must be one of: none, gzip, zstd`)
+}
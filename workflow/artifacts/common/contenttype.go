@@ -0,0 +1,51 @@
+package common
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+// ValidateContentType validates the content type of an artifact, which must be a media type
+func ValidateContentType(errPrefix string, art *wfv1.Artifact) error {
+	if art.ContentType == "" {
+		return nil
+	}
+	if _, _, err := mime.ParseMediaType(art.ContentType); err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s.contentType %q is not a valid media type: %v", errPrefix, art.ContentType, err)
+	}
+	return nil
+}
+
+// ContentType returns the Content-Type to save the file at path with, which is contentType, that of the artifact, if
+// it is set, and is otherwise detected
+func ContentType(contentType, path string) (string, error) {
+	if contentType != "" {
+		return contentType, nil
+	}
+	return DetectContentType(path)
+}
+
+// DetectContentType returns the Content-Type of the file at path by its extension, or otherwise by sniffing its
+// first 512 bytes, which is application/octet-stream if its content is not recognised
+func DetectContentType(path string) (string, error) {
+	if contentType := mime.TypeByExtension(filepath.Ext(path)); contentType != "" {
+		return contentType, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	data := make([]byte, 512)
+	n, err := io.ReadFull(f, data)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return http.DetectContentType(data[:n]), nil
+}
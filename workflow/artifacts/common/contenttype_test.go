@@ -0,0 +1,60 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+func TestDetectContentType(t *testing.T) {
+	dir, err := ioutil.TempDir("", "content-type")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	for _, tt := range []struct {
+		name        string
+		data        string
+		contentType string
+	}{
+		// by extension, even if the content is of another type
+		{"data.json", "plain text", "application/json"},
+		{"image.png", "plain text", "image/png"},
+		// by content
+		{"image", "\x89PNG\r\n\x1a\n", "image/png"},
+		{"page", "<!DOCTYPE html><html></html>", "text/html; charset=utf-8"},
+		{"log", "plain text", "text/plain; charset=utf-8"},
+		{"binary", "\x00\x01\x02\x03", "application/octet-stream"},
+		{"empty", "", "text/plain; charset=utf-8"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p := filepath.Join(dir, tt.name)
+			assert.NoError(t, ioutil.WriteFile(p, []byte(tt.data), 0600))
+			contentType, err := DetectContentType(p)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.contentType, contentType)
+			}
+		})
+	}
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := DetectContentType(filepath.Join(dir, "missing"))
+		assert.Error(t, err)
+	})
+	t.Run("Override", func(t *testing.T) {
+		contentType, err := ContentType("application/x-ndjson", filepath.Join(dir, "missing"))
+		if assert.NoError(t, err) {
+			assert.Equal(t, "application/x-ndjson", contentType)
+		}
+	})
+}
+
+func TestValidateContentType(t *testing.T) {
+	assert.NoError(t, ValidateContentType("my-art", &wfv1.Artifact{}))
+	assert.NoError(t, ValidateContentType("my-art", &wfv1.Artifact{ContentType: "text/csv; charset=utf-8"}))
+	assert.Error(t, ValidateContentType("my-art", &wfv1.Artifact{ContentType: "not a media type"}))
+}
@@ -0,0 +1,75 @@
+package common
+
+import (
+	"os"
+	"path"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+)
+
+// DryRunResult describes what saving an artifact would write, once its destination has been checked
+type DryRunResult struct {
+	// Location is the URL of the destination, e.g. s3://my-bucket/my-key
+	Location string `json:"location"`
+	// Objects are the objects which would be written
+	Objects []DryRunObject `json:"objects"`
+	// Size is the total size of the objects
+	Size int64 `json:"size"`
+	// CreateBucket is whether the bucket does not exist, and would be created
+	CreateBucket bool `json:"createBucket,omitempty"`
+	// WritePermissionChecked is whether permission to write the objects was checked. Storage which cannot check
+	// it without writing an object does not.
+	WritePermissionChecked bool `json:"writePermissionChecked"`
+}
+
+// DryRunObject is an object which saving an artifact would write
+type DryRunObject struct {
+	// Key is the key of the object
+	Key string `json:"key"`
+	// Path is the path of the local file written to the object
+	Path string `json:"path"`
+	// Size is the size of the local file, before any compression
+	Size int64 `json:"size"`
+}
+
+// maxKeyLength is the maximum length in bytes of the key of an object in S3, GCS and OSS
+const maxKeyLength = 1024
+
+// NewDryRunResult returns the objects which saving the file or directory at path to key at location would write:
+// the file itself, or each file of the directory which matches filter, under key
+func NewDryRunResult(location, key, localPath string, filter FileFilter) (*DryRunResult, error) {
+	if key == "" {
+		return nil, errors.Errorf(errors.CodeBadRequest, "the key of %s must not be empty", location)
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return nil, err
+	}
+	result := &DryRunResult{Location: location}
+	add := func(key, localPath string, size int64) error {
+		if len(key) > maxKeyLength {
+			return errors.Errorf(errors.CodeBadRequest, "key %s of %s is longer than %d bytes", key, localPath, maxKeyLength)
+		}
+		result.Objects = append(result.Objects, DryRunObject{Key: key, Path: localPath, Size: size})
+		result.Size += size
+		return nil
+	}
+	if !info.IsDir() {
+		if err := add(key, localPath, info.Size()); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	err = filter.Walk(localPath, func(f WalkedFile) error {
+		size := f.Info.Size()
+		if f.LinkTarget != "" {
+			// a preserved symlink is saved as an empty object
+			size = 0
+		}
+		return add(path.Join(key, f.RelPath), f.Path, size)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
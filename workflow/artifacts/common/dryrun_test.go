@@ -0,0 +1,54 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDryRunResult(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dry-run")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "lib"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "app.jar"), []byte("app"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "lib", "dep.jar"), []byte("dep"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("readme"), 0600))
+
+	t.Run("File", func(t *testing.T) {
+		result, err := NewDryRunResult("s3://my-bucket/my-key", "my-key", filepath.Join(dir, "README.md"), FileFilter{})
+		if assert.NoError(t, err) {
+			assert.Equal(t, &DryRunResult{
+				Location: "s3://my-bucket/my-key",
+				Objects:  []DryRunObject{{Key: "my-key", Path: filepath.Join(dir, "README.md"), Size: 6}},
+				Size:     6,
+			}, result)
+		}
+	})
+	t.Run("Directory", func(t *testing.T) {
+		result, err := NewDryRunResult("s3://my-bucket/my-key", "my-key", dir, FileFilter{Include: []string{"**/*.jar"}})
+		if assert.NoError(t, err) {
+			assert.Equal(t, []DryRunObject{
+				{Key: "my-key/app.jar", Path: filepath.Join(dir, "app.jar"), Size: 3},
+				{Key: "my-key/lib/dep.jar", Path: filepath.Join(dir, "lib", "dep.jar"), Size: 3},
+			}, result.Objects)
+			assert.Equal(t, int64(6), result.Size)
+		}
+	})
+	t.Run("EmptyKey", func(t *testing.T) {
+		_, err := NewDryRunResult("s3://my-bucket/", "", dir, FileFilter{})
+		assert.EqualError(t, err, "the key of s3://my-bucket/ must not be empty")
+	})
+	t.Run("LongKey", func(t *testing.T) {
+		_, err := NewDryRunResult("s3://my-bucket/my-key", strings.Repeat("k", 1024), dir, FileFilter{})
+		assert.Error(t, err)
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := NewDryRunResult("s3://my-bucket/my-key", "my-key", filepath.Join(dir, "missing"), FileFilter{})
+		assert.True(t, os.IsNotExist(err))
+	})
+}
@@ -0,0 +1,116 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+// ValidateExpandEnv validates that an artifact which expands environment variables has a location, and mirror and
+// fallback locations, which support it. One without a location is not validated, as it is only known once it is
+// relocated to the archive location.
+func ValidateExpandEnv(errPrefix string, art *wfv1.Artifact) error {
+	if !art.ExpandEnv {
+		return nil
+	}
+	if art.HasLocation() && !expandsEnv(&art.ArtifactLocation) {
+		return errors.Errorf(errors.CodeBadRequest, "%s.expandEnv is only supported by s3, gcs, oss and http", errPrefix)
+	}
+	for _, l := range otherLocations(art) {
+		if !expandsEnv(l.location) {
+			return errors.Errorf(errors.CodeBadRequest, "%s.expandEnv is only supported by s3, gcs, oss and http, which %s.%s is not", errPrefix, errPrefix, l.name)
+		}
+	}
+	return nil
+}
+
+// expandsEnv returns whether environment variables can be expanded in the location
+func expandsEnv(l *wfv1.ArtifactLocation) bool {
+	return l.S3 != nil || l.GCS != nil || l.OSS != nil || l.HTTP != nil
+}
+
+// namedLocation is a location of an artifact other than its own, with its name in errors
+type namedLocation struct {
+	name     string
+	location *wfv1.ArtifactLocation
+}
+
+// otherLocations returns the mirror and fallback locations of the artifact
+func otherLocations(art *wfv1.Artifact) []namedLocation {
+	var locations []namedLocation
+	if art.Mirrors != nil {
+		for i := range art.Mirrors.Locations {
+			locations = append(locations, namedLocation{fmt.Sprintf("mirrors.locations[%d]", i), &art.Mirrors.Locations[i]})
+		}
+	}
+	if art.Fallbacks != nil {
+		for i := range art.Fallbacks.Locations {
+			locations = append(locations, namedLocation{fmt.Sprintf("fallbacks.locations[%d]", i), &art.Fallbacks.Locations[i]})
+		}
+	}
+	return locations
+}
+
+// envField is a field of an artifact which refers to environment variables
+type envField struct {
+	name  string
+	value *string
+}
+
+// ExpandEnv expands the environment variables referred to by the bucket and key, or the URL, of the artifact with
+// lookup, e.g. os.LookupEnv, in place, and those of its mirror and fallback locations, as the copies of the artifact
+// with those locations are created from it once it is expanded. It is an error if any of them is not defined. The
+// artifact no longer expands them once they are expanded, so that expanding it again does not expand what they
+// expanded to.
+func ExpandEnv(art *wfv1.Artifact, lookup func(string) (string, bool)) error {
+	if err := expandLocation(art.Name, "", &art.ArtifactLocation, lookup); err != nil {
+		return err
+	}
+	for _, l := range otherLocations(art) {
+		if err := expandLocation(art.Name, l.name, l.location, lookup); err != nil {
+			return err
+		}
+	}
+	art.ExpandEnv = false
+	return nil
+}
+
+// expandLocation expands the environment variables of the location of the named artifact, which is its own if name
+// is empty, or else the named location of it
+func expandLocation(artName, name string, l *wfv1.ArtifactLocation, lookup func(string) (string, bool)) error {
+	of := ""
+	if name != "" {
+		of = " of " + name
+	}
+	var fields []envField
+	switch {
+	case l.S3 != nil:
+		fields = []envField{{"bucket", &l.S3.Bucket}, {"key", &l.S3.Key}}
+	case l.GCS != nil:
+		fields = []envField{{"bucket", &l.GCS.Bucket}, {"key", &l.GCS.Key}}
+	case l.OSS != nil:
+		fields = []envField{{"bucket", &l.OSS.Bucket}, {"key", &l.OSS.Key}}
+	case l.HTTP != nil:
+		fields = []envField{{"url", &l.HTTP.URL}}
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "artifact %s cannot expand environment variables%s, as only s3, gcs, oss and http artifacts can", artName, of)
+	}
+	for _, f := range fields {
+		var undefined []string
+		expanded := os.Expand(*f.value, func(name string) string {
+			value, ok := lookup(name)
+			if !ok {
+				undefined = append(undefined, name)
+			}
+			return value
+		})
+		if len(undefined) > 0 {
+			return errors.Errorf(errors.CodeBadRequest, "%s %q%s of artifact %s refers to undefined environment variables: %s", f.name, *f.value, of, artName, strings.Join(undefined, ", "))
+		}
+		*f.value = expanded
+	}
+	return nil
+}
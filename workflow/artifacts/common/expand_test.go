@@ -0,0 +1,84 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+func TestValidateExpandEnv(t *testing.T) {
+	assert.NoError(t, ValidateExpandEnv("my-art", &wfv1.Artifact{}))
+	assert.NoError(t, ValidateExpandEnv("my-art", &wfv1.Artifact{ExpandEnv: true}))
+	assert.NoError(t, ValidateExpandEnv("my-art", &wfv1.Artifact{ExpandEnv: true, ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: "https://example.com/$FILE"}}}))
+	assert.EqualError(t, ValidateExpandEnv("my-art", &wfv1.Artifact{ExpandEnv: true, ArtifactLocation: wfv1.ArtifactLocation{Raw: &wfv1.RawArtifact{Data: "$DATA"}}}), "my-art.expandEnv is only supported by s3, gcs, oss and http")
+	mirrors := &wfv1.ArtifactMirrors{Locations: []wfv1.ArtifactLocation{{GCS: &wfv1.GCSArtifact{Key: "$KEY"}}, {Git: &wfv1.GitArtifact{Repo: "$REPO"}}}}
+	assert.EqualError(t, ValidateExpandEnv("my-art", &wfv1.Artifact{ExpandEnv: true, Mirrors: mirrors}), "my-art.expandEnv is only supported by s3, gcs, oss and http, which my-art.mirrors.locations[1] is not")
+	fallbacks := &wfv1.ArtifactFallbacks{Locations: []wfv1.ArtifactLocation{{HTTP: &wfv1.HTTPArtifact{URL: "https://example.com/$FILE"}}}}
+	assert.NoError(t, ValidateExpandEnv("my-art", &wfv1.Artifact{ExpandEnv: true, Fallbacks: fallbacks}))
+}
+
+func TestExpandEnv(t *testing.T) {
+	env := map[string]string{"BUCKET": "my-bucket", "WORKSPACE": "workspaces/1", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	t.Run("S3", func(t *testing.T) {
+		art := &wfv1.Artifact{Name: "my-art", ExpandEnv: true, ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "$BUCKET"}, Key: "${WORKSPACE}/output${EMPTY}"}}}
+		assert.NoError(t, ExpandEnv(art, lookup))
+		assert.Equal(t, "my-bucket", art.S3.Bucket)
+		assert.Equal(t, "workspaces/1/output", art.S3.Key)
+		assert.False(t, art.ExpandEnv)
+	})
+	t.Run("GCS", func(t *testing.T) {
+		art := &wfv1.Artifact{Name: "my-art", ExpandEnv: true, ArtifactLocation: wfv1.ArtifactLocation{GCS: &wfv1.GCSArtifact{GCSBucket: wfv1.GCSBucket{Bucket: "$BUCKET"}, Key: "${WORKSPACE}/output"}}}
+		assert.NoError(t, ExpandEnv(art, lookup))
+		assert.Equal(t, "my-bucket", art.GCS.Bucket)
+		assert.Equal(t, "workspaces/1/output", art.GCS.Key)
+	})
+	t.Run("OSS", func(t *testing.T) {
+		art := &wfv1.Artifact{Name: "my-art", ExpandEnv: true, ArtifactLocation: wfv1.ArtifactLocation{OSS: &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{Bucket: "$BUCKET"}, Key: "${WORKSPACE}/output"}}}
+		assert.NoError(t, ExpandEnv(art, lookup))
+		assert.Equal(t, "my-bucket", art.OSS.Bucket)
+		assert.Equal(t, "workspaces/1/output", art.OSS.Key)
+	})
+	t.Run("HTTP", func(t *testing.T) {
+		art := &wfv1.Artifact{Name: "my-art", ExpandEnv: true, ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: "https://example.com/${WORKSPACE}/output"}}}
+		assert.NoError(t, ExpandEnv(art, lookup))
+		assert.Equal(t, "https://example.com/workspaces/1/output", art.HTTP.URL)
+	})
+	t.Run("MirrorsAndFallbacks", func(t *testing.T) {
+		art := &wfv1.Artifact{
+			Name:             "my-art",
+			ExpandEnv:        true,
+			ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "$BUCKET"}, Key: "${WORKSPACE}/output"}},
+			Mirrors:          &wfv1.ArtifactMirrors{Locations: []wfv1.ArtifactLocation{{GCS: &wfv1.GCSArtifact{GCSBucket: wfv1.GCSBucket{Bucket: "$BUCKET"}, Key: "${WORKSPACE}/mirror"}}}},
+			Fallbacks:        &wfv1.ArtifactFallbacks{Locations: []wfv1.ArtifactLocation{{HTTP: &wfv1.HTTPArtifact{URL: "https://example.com/${WORKSPACE}/fallback"}}}},
+		}
+		assert.NoError(t, ExpandEnv(art, lookup))
+		assert.Equal(t, "workspaces/1/output", art.S3.Key)
+		assert.Equal(t, "my-bucket", art.Mirrors.Locations[0].GCS.Bucket)
+		assert.Equal(t, "workspaces/1/mirror", art.Mirrors.Locations[0].GCS.Key)
+		assert.Equal(t, "https://example.com/workspaces/1/fallback", art.Fallbacks.Locations[0].HTTP.URL)
+		art = &wfv1.Artifact{
+			Name:             "my-art",
+			ExpandEnv:        true,
+			ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "output"}},
+			Mirrors:          &wfv1.ArtifactMirrors{Locations: []wfv1.ArtifactLocation{{GCS: &wfv1.GCSArtifact{Key: "${MISSING}/mirror"}}}},
+		}
+		assert.EqualError(t, ExpandEnv(art, lookup), `key "${MISSING}/mirror" of mirrors.locations[0] of artifact my-art refers to undefined environment variables: MISSING`)
+		assert.True(t, art.ExpandEnv)
+	})
+	t.Run("Undefined", func(t *testing.T) {
+		art := &wfv1.Artifact{Name: "my-art", ExpandEnv: true, ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "$BUCKET"}, Key: "${MISSING}/output/$OTHER"}}}
+		assert.EqualError(t, ExpandEnv(art, lookup), `key "${MISSING}/output/$OTHER" of artifact my-art refers to undefined environment variables: MISSING, OTHER`)
+		assert.Equal(t, "${MISSING}/output/$OTHER", art.S3.Key)
+		assert.True(t, art.ExpandEnv)
+	})
+	t.Run("Unsupported", func(t *testing.T) {
+		art := &wfv1.Artifact{Name: "my-art", ExpandEnv: true, ArtifactLocation: wfv1.ArtifactLocation{Raw: &wfv1.RawArtifact{Data: "$DATA"}}}
+		assert.EqualError(t, ExpandEnv(art, lookup), "artifact my-art cannot expand environment variables, as only s3, gcs, oss and http artifacts can")
+	})
+}
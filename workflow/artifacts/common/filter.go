@@ -0,0 +1,112 @@
+package common
+
+import (
+	"path"
+	"strings"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+// FileFilter selects the files of a directory which are saved, by the include and exclude patterns of an
+// output artifact. Patterns are path.Match patterns matched against slash separated paths relative to the
+// directory, in which "**" matches any number of directories, e.g. "**/*.jar" matches "a.jar" and "lib/b.jar".
+type FileFilter struct {
+	Include []string
+	Exclude []string
+	// Symlinks is the policy of the symlinks of the directory, with which they are walked
+	Symlinks wfv1.ArtifactSymlinks
+}
+
+// NewFileFilter returns the filter of the include and exclude patterns, and the symlink policy, of the artifact
+func NewFileFilter(art *wfv1.Artifact) FileFilter {
+	return FileFilter{Include: art.Include, Exclude: art.Exclude, Symlinks: art.Symlinks}
+}
+
+// ValidateFileFilter validates the include and exclude patterns of an output artifact
+func ValidateFileFilter(errPrefix string, art *wfv1.Artifact) error {
+	if len(art.Include) == 0 && len(art.Exclude) == 0 {
+		return nil
+	}
+	if art.Archive == nil || art.Archive.None == nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s.include and %s.exclude require archive none", errPrefix, errPrefix)
+	}
+	for i, pattern := range art.Include {
+		if err := validatePattern(pattern); err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "%s.include[%d] %q is not a valid pattern", errPrefix, i, pattern)
+		}
+	}
+	for i, pattern := range art.Exclude {
+		if err := validatePattern(pattern); err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "%s.exclude[%d] %q is not a valid pattern", errPrefix, i, pattern)
+		}
+	}
+	return nil
+}
+
+// Matches returns whether the file at the slash separated path relative to the directory is saved, i.e. it
+// matches no exclude pattern, and any include pattern if there are some
+func (f FileFilter) Matches(relPath string) bool {
+	for _, pattern := range f.Exclude {
+		if matchPattern(pattern, relPath) {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, pattern := range f.Include {
+		if matchPattern(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// validatePattern returns an error if a segment of the pattern is malformed. Segments are matched against
+// themselves as well as the empty string, as path.Match may not check the parts of a pattern it does not reach.
+func validatePattern(pattern string) error {
+	if pattern == "" {
+		return path.ErrBadPattern
+	}
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return err
+		}
+		if _, err := path.Match(segment, segment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchPattern returns whether the segments of the pattern match those of the path
+func matchPattern(pattern, relPath string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(relPath, "/"))
+}
+
+func matchSegments(patterns, names []string) bool {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			if len(patterns) == 1 {
+				// a trailing "**" matches every file under the directories matched so far
+				return len(names) > 0
+			}
+			// "**" matches no directories, or any number of them
+			for i := 0; i <= len(names); i++ {
+				if matchSegments(patterns[1:], names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 {
+			return false
+		}
+		if ok, err := path.Match(patterns[0], names[0]); err != nil || !ok {
+			return false
+		}
+		patterns, names = patterns[1:], names[1:]
+	}
+	return len(names) == 0
+}
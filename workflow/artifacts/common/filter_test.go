@@ -0,0 +1,48 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+func TestFileFilter_Matches(t *testing.T) {
+	files := []string{"app.jar", "lib/dep.jar", "lib/test/dep-test.jar", "test/app-test.jar", "README.md", "build/out/log.txt"}
+	for _, tt := range []struct {
+		name    string
+		filter  FileFilter
+		matches []string
+	}{
+		{"None", FileFilter{}, files},
+		{"Include", FileFilter{Include: []string{"*.jar"}}, []string{"app.jar"}},
+		{"IncludeRecursive", FileFilter{Include: []string{"**/*.jar"}}, []string{"app.jar", "lib/dep.jar", "lib/test/dep-test.jar", "test/app-test.jar"}},
+		{"Exclude", FileFilter{Exclude: []string{"**/test/**"}}, []string{"app.jar", "lib/dep.jar", "README.md", "build/out/log.txt"}},
+		{"ExcludeWins", FileFilter{Include: []string{"**/*.jar"}, Exclude: []string{"**/*-test.jar"}}, []string{"app.jar", "lib/dep.jar"}},
+		{"Directory", FileFilter{Include: []string{"build/**"}}, []string{"build/out/log.txt"}},
+		{"Multiple", FileFilter{Include: []string{"*.md", "lib/*.jar"}}, []string{"lib/dep.jar", "README.md"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var matches []string
+			for _, f := range files {
+				if tt.filter.Matches(f) {
+					matches = append(matches, f)
+				}
+			}
+			assert.Equal(t, tt.matches, matches)
+		})
+	}
+}
+
+func TestValidateFileFilter(t *testing.T) {
+	none := &wfv1.ArchiveStrategy{None: &wfv1.NoneStrategy{}}
+	assert.NoError(t, ValidateFileFilter("my-art", &wfv1.Artifact{}))
+	assert.NoError(t, ValidateFileFilter("my-art", &wfv1.Artifact{Archive: none, Include: []string{"**/*.jar"}, Exclude: []string{"test/*"}}))
+	err := ValidateFileFilter("my-art", &wfv1.Artifact{Include: []string{"*.jar"}})
+	assert.EqualError(t, err, "my-art.include and my-art.exclude require archive none")
+	err = ValidateFileFilter("my-art", &wfv1.Artifact{Archive: none, Exclude: []string{"*.jar", "lib/[a-"}})
+	assert.EqualError(t, err, `my-art.exclude[1] "lib/[a-" is not a valid pattern`)
+	err = ValidateFileFilter("my-art", &wfv1.Artifact{Archive: none, Include: []string{""}})
+	assert.EqualError(t, err, `my-art.include[0] "" is not a valid pattern`)
+}
@@ -0,0 +1,45 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+// IdempotencyKeyMetadataKey is the key of the user metadata of an object which records the hex encoded sha256 of the
+// idempotency key of the save of an output artifact, which is the marker that the save completed: that of the object
+// of a file, or of the manifest of a directory, which is saved once every file is
+const IdempotencyKeyMetadataKey = "argo-idempotency-key"
+
+// retryAttemptRegex matches the retry attempts of the name of a node, e.g. the (1) of my-wf[0].my-step(1), but not the
+// items of loops, e.g. (0:my-item)
+var retryAttemptRegex = regexp.MustCompile(`\(\d+\)`)
+
+// DeriveIdempotencyKey returns the idempotency key of the save of an output artifact of a node, which is the same for
+// every attempt of a retried node, and those of any retried node it is in
+func DeriveIdempotencyKey(namespace, nodeName, artifactName string) string {
+	return fmt.Sprintf("%s/%s/%s", namespace, retryAttemptRegex.ReplaceAllString(nodeName, ""), artifactName)
+}
+
+// IdempotencyKey returns the hex encoded sha256 of the idempotency key of an output artifact, as it is recorded under
+// IdempotencyKeyMetadataKey, or "" if its saves are not idempotent, which they are not if it has no key
+func IdempotencyKey(art *wfv1.Artifact) string {
+	if art.Idempotency == nil || art.Idempotency.Key == "" {
+		return ""
+	}
+	digest := sha256.Sum256([]byte(art.Idempotency.Key))
+	return hex.EncodeToString(digest[:])
+}
+
+// WithIdempotencyKey returns a copy of the user metadata of an object, with the hex encoded sha256 of an idempotency
+// key recorded under IdempotencyKeyMetadataKey
+func WithIdempotencyKey(metadata map[string]string, key string) map[string]string {
+	result := map[string]string{IdempotencyKeyMetadataKey: key}
+	for k, v := range metadata {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,37 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+func TestDeriveIdempotencyKey(t *testing.T) {
+	key := DeriveIdempotencyKey("my-ns", "my-wf[0].my-step", "my-art")
+	assert.Equal(t, "my-ns/my-wf[0].my-step/my-art", key)
+	// every attempt of a retried node, and of any retried node it is in, has the same key
+	assert.Equal(t, key, DeriveIdempotencyKey("my-ns", "my-wf[0].my-step(0)", "my-art"))
+	assert.Equal(t, key, DeriveIdempotencyKey("my-ns", "my-wf(1)[0].my-step(2)", "my-art"))
+	// but the items of loops are different nodes
+	assert.NotEqual(t, key, DeriveIdempotencyKey("my-ns", "my-wf[0].my-step(0:my-item)", "my-art"))
+	assert.NotEqual(t, key, DeriveIdempotencyKey("my-ns", "my-wf[0].my-step", "other-art"))
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	assert.Empty(t, IdempotencyKey(&wfv1.Artifact{}))
+	assert.Empty(t, IdempotencyKey(&wfv1.Artifact{Idempotency: &wfv1.ArtifactIdempotency{}}))
+	art := &wfv1.Artifact{Idempotency: &wfv1.ArtifactIdempotency{Key: "hello"}}
+	assert.Equal(t, helloSHA256, IdempotencyKey(art))
+	metadata := map[string]string{ContentSHA256MetadataKey: helloSHA256}
+	assert.Equal(t, map[string]string{ContentSHA256MetadataKey: helloSHA256, IdempotencyKeyMetadataKey: helloSHA256}, WithIdempotencyKey(metadata, IdempotencyKey(art)))
+	// the metadata passed is not modified
+	assert.Len(t, metadata, 1)
+}
+
+func TestManifestFormat_Idempotency(t *testing.T) {
+	assert.Empty(t, ManifestFormat(&wfv1.Artifact{}))
+	assert.Equal(t, wfv1.ArtifactManifestFormatText, ManifestFormat(&wfv1.Artifact{Idempotency: &wfv1.ArtifactIdempotency{Key: "my-key"}}))
+	assert.Equal(t, wfv1.ArtifactManifestFormatJSON, ManifestFormat(&wfv1.Artifact{Manifest: wfv1.ArtifactManifestFormatJSON, Idempotency: &wfv1.ArtifactIdempotency{Key: "my-key"}}))
+}
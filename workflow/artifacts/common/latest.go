@@ -0,0 +1,52 @@
+package common
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+// ListedObject is an object listed under the key prefix of an input artifact, e.g. one which selects the latest object
+type ListedObject struct {
+	Key          string
+	LastModified time.Time
+	// Size is the size of the object in bytes
+	Size int64
+}
+
+// ValidateSelectLatest validates how an input artifact selects the latest object under its key prefix
+func ValidateSelectLatest(errPrefix string, art *wfv1.Artifact) error {
+	switch art.SelectLatest {
+	case "":
+		return nil
+	case wfv1.ArtifactSelectLatestLastModified, wfv1.ArtifactSelectLatestLexical:
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "%s.selectLatest %q must be one of: last-modified, lexical", errPrefix, art.SelectLatest)
+	}
+	if art.S3 == nil && art.GCS == nil && art.OSS == nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s.selectLatest is only supported by s3, gcs and oss", errPrefix)
+	}
+	return nil
+}
+
+// SelectLatest returns the key of the latest of the objects listed under the key prefix by the policy. It is a not
+// found error if there are none, so that an optional artifact is skipped.
+func SelectLatest(policy wfv1.ArtifactSelectLatest, prefix string, objects []ListedObject) (string, error) {
+	if len(objects) == 0 {
+		return "", NewNotFoundError(fmt.Errorf("no object has the key prefix %s to select the latest of", prefix))
+	}
+	latest := objects[0]
+	for _, object := range objects[1:] {
+		switch {
+		case policy == wfv1.ArtifactSelectLatestLastModified && object.LastModified.After(latest.LastModified):
+			latest = object
+		case policy == wfv1.ArtifactSelectLatestLastModified && object.LastModified.Before(latest.LastModified):
+			// an object modified earlier is not the latest, whatever its key
+		case object.Key > latest.Key:
+			latest = object
+		}
+	}
+	return latest.Key, nil
+}
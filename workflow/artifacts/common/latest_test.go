@@ -0,0 +1,48 @@
+package common
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+func TestValidateSelectLatest(t *testing.T) {
+	s3 := wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{}}
+	assert.NoError(t, ValidateSelectLatest("my-art", &wfv1.Artifact{}))
+	for _, policy := range []wfv1.ArtifactSelectLatest{wfv1.ArtifactSelectLatestLastModified, wfv1.ArtifactSelectLatestLexical} {
+		assert.NoError(t, ValidateSelectLatest("my-art", &wfv1.Artifact{ArtifactLocation: s3, SelectLatest: policy}), policy)
+	}
+	assert.EqualError(t, ValidateSelectLatest("my-art", &wfv1.Artifact{ArtifactLocation: s3, SelectLatest: "newest"}), `my-art.selectLatest "newest" must be one of: last-modified, lexical`)
+	assert.EqualError(t, ValidateSelectLatest("my-art", &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{}}, SelectLatest: wfv1.ArtifactSelectLatestLexical}), "my-art.selectLatest is only supported by s3, gcs and oss")
+}
+
+func TestSelectLatest(t *testing.T) {
+	at := func(day int) time.Time { return time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC) }
+	// the object with the greatest key is not that modified last, which two objects were
+	objects := []ListedObject{
+		{Key: "data/data-20240102.csv", LastModified: at(5)},
+		{Key: "data/data-20240103.csv", LastModified: at(3)},
+		{Key: "data/data-20240101.csv", LastModified: at(5)},
+		{Key: "data/data-20231231.csv", LastModified: at(4)},
+	}
+
+	t.Run("LastModified", func(t *testing.T) {
+		key, err := SelectLatest(wfv1.ArtifactSelectLatestLastModified, "data/data-", objects)
+		assert.NoError(t, err)
+		assert.Equal(t, "data/data-20240102.csv", key)
+	})
+	t.Run("Lexical", func(t *testing.T) {
+		key, err := SelectLatest(wfv1.ArtifactSelectLatestLexical, "data/data-", objects)
+		assert.NoError(t, err)
+		assert.Equal(t, "data/data-20240103.csv", key)
+	})
+	t.Run("None", func(t *testing.T) {
+		_, err := SelectLatest(wfv1.ArtifactSelectLatestLexical, "data/data-", nil)
+		assert.EqualError(t, err, "no object has the key prefix data/data- to select the latest of")
+		assert.True(t, errors.Is(err, ErrArtifactNotFound))
+	})
+}
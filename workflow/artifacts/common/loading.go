@@ -0,0 +1,56 @@
+package common
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+)
+
+// LoadAtomically calls load to load an artifact to a path in a temporary directory next to path, which is hidden by a
+// leading dot, and only once it succeeds renames what was loaded to path, in place of what was there, so that a load
+// which fails part of the way leaves path absent, or as it was, rather than partially written. The temporary
+// directory is removed whether or not it succeeds, along with any partial files the client writes next to the path
+// it loads to.
+func LoadAtomically(path string, load func(path string) error) error {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	dir, base := filepath.Split(filepath.Clean(path))
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+	tmpDir := filepath.Join(dir, "."+base+".argo-loading-"+hex.EncodeToString(b))
+	if err := os.Mkdir(tmpDir, 0700); err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	tmp := filepath.Join(tmpDir, base)
+	if err := load(tmp); err != nil {
+		return err
+	}
+	return replace(tmp, path, filepath.Join(tmpDir, base+".replaced"))
+}
+
+// replace renames the file or directory at tmp to path. A directory cannot be renamed over a file, or over a
+// directory which is not empty, so what is at path is then renamed aside, and back if tmp still cannot be renamed.
+func replace(tmp, path, aside string) error {
+	err := os.Rename(tmp, path)
+	if err == nil {
+		return nil
+	}
+	if _, statErr := os.Lstat(path); statErr != nil {
+		return err
+	}
+	if err := os.Rename(path, aside); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Rename(aside, path)
+		return err
+	}
+	return nil
+}
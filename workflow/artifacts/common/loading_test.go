@@ -0,0 +1,107 @@
+package common
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadAtomically(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loading")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	errInterrupted := errors.New("interrupted")
+	// loadFile writes a file, or part of one next to it, as clients which resume loads do, and fails if interrupted
+	loadFile := func(interrupted bool) func(string) error {
+		return func(path string) error {
+			if interrupted {
+				_ = ioutil.WriteFile(path+".part", []byte("ne"), 0600)
+				return errInterrupted
+			}
+			return ioutil.WriteFile(path, []byte("new"), 0600)
+		}
+	}
+	// loadDirectory writes one file of a directory, and fails before the second if interrupted
+	loadDirectory := func(interrupted bool) func(string) error {
+		return func(path string) error {
+			if err := os.MkdirAll(path, 0700); err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(filepath.Join(path, "a"), []byte("new"), 0600); err != nil {
+				return err
+			}
+			if interrupted {
+				return errInterrupted
+			}
+			return ioutil.WriteFile(filepath.Join(path, "b"), []byte("new"), 0600)
+		}
+	}
+	// entries returns the names in the directory, which the temporary paths must not be left in
+	entries := func(t *testing.T, dir string) []string {
+		infos, err := ioutil.ReadDir(dir)
+		assert.NoError(t, err)
+		var names []string
+		for _, info := range infos {
+			names = append(names, info.Name())
+		}
+		return names
+	}
+
+	t.Run("File", func(t *testing.T) {
+		dir := filepath.Join(dir, "file")
+		path := filepath.Join(dir, "my-file")
+		// the directory of the path is created
+		assert.NoError(t, LoadAtomically(path, loadFile(false)))
+		data, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "new", string(data))
+		assert.Equal(t, []string{"my-file"}, entries(t, dir))
+	})
+	t.Run("FileInterrupted", func(t *testing.T) {
+		dir := filepath.Join(dir, "file-interrupted")
+		assert.NoError(t, os.Mkdir(dir, 0700))
+		absent := filepath.Join(dir, "absent")
+		assert.Equal(t, errInterrupted, LoadAtomically(absent, loadFile(true)))
+		prior := filepath.Join(dir, "prior")
+		assert.NoError(t, ioutil.WriteFile(prior, []byte("old"), 0600))
+		assert.Equal(t, errInterrupted, LoadAtomically(prior, loadFile(true)))
+		data, err := ioutil.ReadFile(prior)
+		assert.NoError(t, err)
+		assert.Equal(t, "old", string(data))
+		assert.Equal(t, []string{"prior"}, entries(t, dir))
+	})
+	t.Run("Directory", func(t *testing.T) {
+		dir := filepath.Join(dir, "directory")
+		path := filepath.Join(dir, "my-dir")
+		assert.NoError(t, os.MkdirAll(path, 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(path, "old"), []byte("old"), 0600))
+		// the directory which was there is swapped for that loaded
+		assert.NoError(t, LoadAtomically(path, loadDirectory(false)))
+		assert.Equal(t, []string{"a", "b"}, entries(t, path))
+		assert.Equal(t, []string{"my-dir"}, entries(t, dir))
+	})
+	t.Run("DirectoryInterrupted", func(t *testing.T) {
+		dir := filepath.Join(dir, "directory-interrupted")
+		path := filepath.Join(dir, "my-dir")
+		assert.NoError(t, os.MkdirAll(path, 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(path, "old"), []byte("old"), 0600))
+		assert.Equal(t, errInterrupted, LoadAtomically(path, loadDirectory(true)))
+		assert.Equal(t, []string{"old"}, entries(t, path))
+		assert.Equal(t, []string{"my-dir"}, entries(t, dir))
+	})
+	t.Run("DirectoryOverFile", func(t *testing.T) {
+		dir := filepath.Join(dir, "directory-over-file")
+		path := filepath.Join(dir, "my-dir")
+		assert.NoError(t, os.MkdirAll(dir, 0700))
+		assert.NoError(t, ioutil.WriteFile(path, []byte("old"), 0600))
+		assert.NoError(t, LoadAtomically(path, loadDirectory(false)))
+		assert.Equal(t, []string{"a", "b"}, entries(t, path))
+		assert.Equal(t, []string{"my-dir"}, entries(t, dir))
+	})
+}
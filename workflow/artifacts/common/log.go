@@ -0,0 +1,65 @@
+package common
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Logging is embedded in drivers to log to the logger NewDriver sets, which has the name of the driver as a field.
+// Drivers used without one log to the standard logger.
+type Logging struct {
+	logger log.FieldLogger
+}
+
+// SetLogger sets the logger of the driver
+func (l *Logging) SetLogger(logger log.FieldLogger) {
+	l.logger = logger
+}
+
+// Log returns the logger of the driver
+func (l *Logging) Log() log.FieldLogger {
+	if l == nil || l.logger == nil {
+		return log.StandardLogger()
+	}
+	return l.logger
+}
+
+// sensitiveQueryParam matches the names of query parameters which could be credentials, e.g. the X-Amz-Signature
+// and X-Amz-Credential of a presigned S3 URL, or the sig of an Azure SAS
+var sensitiveQueryParam = regexp.MustCompile(`(?i)sig|token|key|secret|password|credential|auth`)
+
+// RedactURL returns the URL without its password, or the values of query parameters which could be credentials,
+// so that it can be logged
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// the URL cannot be redacted, and may contain anything
+		return "<unparseable URL>"
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for name := range query {
+			if sensitiveQueryParam.MatchString(name) {
+				query[name] = []string{"REDACTED"}
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.Redacted()
+}
+
+// PathSize returns the size of the file, or the total size of the regular files of the directory, at path
+func PathSize(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return err
+	})
+	return size, err
+}
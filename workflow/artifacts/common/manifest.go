@@ -0,0 +1,157 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+// ManifestSuffix is appended to the key of a directory artifact to get the key of its manifest, which is next to the
+// objects of the files of the directory rather than one of them, so that it is not loaded with them
+const ManifestSuffix = ".manifest"
+
+// Manifest lists the files of a directory artifact, in the order of their paths
+type Manifest struct {
+	Files []ManifestFile `json:"files"`
+}
+
+// ManifestFile is a file of a directory artifact
+type ManifestFile struct {
+	// Path is the slash separated path of the file relative to the directory
+	Path string `json:"path"`
+	// Size is the size of the file
+	Size int64 `json:"size"`
+	// SHA256 is the hex encoded sha256 digest of the file
+	SHA256 string `json:"sha256"`
+}
+
+// ValidateManifest validates the manifest format of an output artifact
+func ValidateManifest(errPrefix string, format wfv1.ArtifactManifestFormat) error {
+	switch format {
+	case "", wfv1.ArtifactManifestFormatJSON, wfv1.ArtifactManifestFormatText:
+		return nil
+	}
+	return errors.Errorf(errors.CodeBadRequest, "%s.manifest %q must be one of: json, text", errPrefix, format)
+}
+
+// ManifestKey returns the key of the manifest of the directory artifact with the key
+func ManifestKey(key string) string {
+	return strings.TrimSuffix(key, "/") + ManifestSuffix
+}
+
+// ManifestContentType returns the Content-Type of a manifest in the format
+func ManifestContentType(format wfv1.ArtifactManifestFormat) string {
+	if format == wfv1.ArtifactManifestFormatJSON {
+		return "application/json"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// NewManifest returns the manifest of the objects saved from the files of the directory dir, but for preserved
+// symlinks, which have no content of their own
+func NewManifest(dir string, objects []SavedObject) (*Manifest, error) {
+	m := &Manifest{Files: make([]ManifestFile, 0, len(objects))}
+	for _, object := range objects {
+		if object.LinkTarget != "" {
+			continue
+		}
+		rel, err := filepath.Rel(dir, object.Path)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(object.Path)
+		if err != nil {
+			return nil, err
+		}
+		digest, err := FileChecksum(wfv1.ChecksumAlgorithmSHA256, object.Path)
+		if err != nil {
+			return nil, err
+		}
+		m.Files = append(m.Files, ManifestFile{Path: filepath.ToSlash(rel), Size: info.Size(), SHA256: digest})
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	return m, nil
+}
+
+// Marshal returns the manifest in the format. The text format has a line for each file of its digest, size and path,
+// separated by spaces, like that of sha256sum but for the size.
+func (m *Manifest) Marshal(format wfv1.ArtifactManifestFormat) ([]byte, error) {
+	switch format {
+	case wfv1.ArtifactManifestFormatJSON:
+		return json.MarshalIndent(m, "", "  ")
+	case wfv1.ArtifactManifestFormatText:
+		var buf bytes.Buffer
+		for _, f := range m.Files {
+			if strings.ContainsAny(f.Path, "\r\n") {
+				return nil, fmt.Errorf("the path %q cannot be listed in a text manifest", f.Path)
+			}
+			_, _ = fmt.Fprintf(&buf, "%s %d %s\n", f.SHA256, f.Size, f.Path)
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported manifest format %q", format)
+	}
+}
+
+// ParseManifest parses a manifest in either format, which is JSON if it is an object
+func ParseManifest(data []byte) (*Manifest, error) {
+	m := &Manifest{}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, m); err != nil {
+			return nil, fmt.Errorf("invalid JSON manifest: %w", err)
+		}
+		return m, nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid text manifest: line %d is not a digest, size and path", n)
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid text manifest: line %d has an invalid size %q", n, fields[1])
+		}
+		m.Files = append(m.Files, ManifestFile{Path: fields[2], Size: size, SHA256: fields[0]})
+	}
+	return m, scanner.Err()
+}
+
+// Verify verifies that each file of the manifest is in the directory dir, with its size and digest. Files of the
+// directory which are not in the manifest, e.g. those of objects put under its key by other means, are not errors.
+func (m *Manifest) Verify(dir string) error {
+	for _, f := range m.Files {
+		rel := filepath.FromSlash(f.Path)
+		if filepath.IsAbs(rel) || rel != filepath.Clean(rel) || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || rel == ".." {
+			return errors.InternalErrorf("the manifest of %s has an invalid path %q", dir, f.Path)
+		}
+		path := filepath.Join(dir, rel)
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			return errors.InternalErrorf("%s is in the manifest, but was not loaded", path)
+		}
+		if err != nil {
+			return err
+		}
+		if info.Size() != f.Size {
+			return errors.InternalErrorf("size of %s does not match the manifest: expected %d, got %d", path, f.Size, info.Size())
+		}
+		if err := verifyFile(wfv1.ChecksumAlgorithmSHA256, path, f.SHA256); err != nil {
+			return err
+		}
+	}
+	return nil
+}
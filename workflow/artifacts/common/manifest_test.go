@@ -0,0 +1,88 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+func TestManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "hello"), []byte("hello"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "my world"), []byte("world"), 0600))
+	objects := []SavedObject{
+		{Key: "my-key/sub/my world", Path: filepath.Join(dir, "sub", "my world")},
+		{Key: "my-key/hello", Path: filepath.Join(dir, "hello")},
+	}
+	m, err := NewManifest(dir, objects)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, &Manifest{Files: []ManifestFile{
+		{Path: "hello", Size: 5, SHA256: helloSHA256},
+		{Path: "sub/my world", Size: 5, SHA256: worldSHA256},
+	}}, m)
+
+	t.Run("JSON", func(t *testing.T) {
+		data, err := m.Marshal(wfv1.ArtifactManifestFormatJSON)
+		if assert.NoError(t, err) {
+			assert.Contains(t, string(data), `"path": "sub/my world"`)
+			parsed, err := ParseManifest(data)
+			assert.NoError(t, err)
+			assert.Equal(t, m, parsed)
+		}
+	})
+	t.Run("Text", func(t *testing.T) {
+		data, err := m.Marshal(wfv1.ArtifactManifestFormatText)
+		if assert.NoError(t, err) {
+			assert.Equal(t, helloSHA256+" 5 hello\n"+worldSHA256+" 5 sub/my world\n", string(data))
+			parsed, err := ParseManifest(data)
+			assert.NoError(t, err)
+			assert.Equal(t, m, parsed)
+		}
+		_, err = (&Manifest{Files: []ManifestFile{{Path: "a\nb"}}}).Marshal(wfv1.ArtifactManifestFormatText)
+		assert.Error(t, err)
+		_, err = ParseManifest([]byte(helloSHA256 + " five hello\n"))
+		assert.Error(t, err)
+	})
+	t.Run("Verify", func(t *testing.T) {
+		assert.NoError(t, m.Verify(dir))
+		// a file which is not in the manifest is not an error
+		assert.NoError(t, (&Manifest{Files: m.Files[:1]}).Verify(dir))
+	})
+	t.Run("Missing", func(t *testing.T) {
+		missing := &Manifest{Files: append(m.Files, ManifestFile{Path: "sub/missing", Size: 5, SHA256: helloSHA256})}
+		assert.Error(t, missing.Verify(dir))
+	})
+	t.Run("Corrupt", func(t *testing.T) {
+		corrupt := &Manifest{Files: []ManifestFile{{Path: "hello", Size: 5, SHA256: worldSHA256}}}
+		assert.Error(t, corrupt.Verify(dir))
+		truncated := &Manifest{Files: []ManifestFile{{Path: "hello", Size: 4, SHA256: helloSHA256}}}
+		assert.Error(t, truncated.Verify(dir))
+	})
+	t.Run("InvalidPath", func(t *testing.T) {
+		assert.Error(t, (&Manifest{Files: []ManifestFile{{Path: "../hello", Size: 5, SHA256: helloSHA256}}}).Verify(filepath.Join(dir, "sub")))
+	})
+}
+
+func TestValidateManifest(t *testing.T) {
+	assert.NoError(t, ValidateManifest("my-art", ""))
+	assert.NoError(t, ValidateManifest("my-art", wfv1.ArtifactManifestFormatJSON))
+	assert.NoError(t, ValidateManifest("my-art", wfv1.ArtifactManifestFormatText))
+	assert.Error(t, ValidateManifest("my-art", "yaml"))
+}
+
+func TestManifestKey(t *testing.T) {
+	assert.Equal(t, "my-dir.manifest", ManifestKey("my-dir"))
+	assert.Equal(t, "my-dir.manifest", ManifestKey("my-dir/"))
+}
@@ -0,0 +1,122 @@
+package common
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+// ReservedMetadataPrefix is the prefix of the keys of the user metadata the drivers record themselves, e.g.
+// ContentSHA256MetadataKey, which the metadata of an artifact cannot have
+const ReservedMetadataPrefix = "argo-"
+
+// metadataKeyRegex matches the keys of the metadata of an artifact, which S3 and OSS lowercase, and OSS only allows
+// letters, digits and hyphens in
+var metadataKeyRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// metadataLimits are the limits of the user metadata of the objects of a storage
+type metadataLimits struct {
+	// name is that of the storage, e.g. "s3"
+	name string
+	// maxSize is the most bytes the keys and values of the metadata of an object can have in total
+	maxSize int
+	// asciiValues is whether the values must be printable ASCII, as those of the metadata sent as headers must be
+	asciiValues bool
+}
+
+var (
+	s3MetadataLimits  = metadataLimits{name: "s3", maxSize: 2 * 1024, asciiValues: true}
+	gcsMetadataLimits = metadataLimits{name: "gcs", maxSize: 8 * 1024}
+	ossMetadataLimits = metadataLimits{name: "oss", maxSize: 8 * 1024, asciiValues: true}
+)
+
+// ValidateMetadata validates the metadata of an artifact against the limits of the user metadata of its storage.
+// Those of S3, the strictest, apply to an artifact without a location, which is saved to the artifact repository.
+// The drivers record their own metadata next to it, e.g. the sha256 of each file, so it should leave room for them.
+func ValidateMetadata(errPrefix string, art *wfv1.Artifact) error {
+	if len(art.Metadata) == 0 {
+		return nil
+	}
+	var limits metadataLimits
+	switch {
+	case art.S3 != nil:
+		limits = s3MetadataLimits
+	case art.GCS != nil:
+		limits = gcsMetadataLimits
+	case art.OSS != nil:
+		limits = ossMetadataLimits
+	case art.ArtifactLocation.Get() != nil:
+		return errors.Errorf(errors.CodeBadRequest, "%s.metadata is only supported by s3, gcs and oss", errPrefix)
+	default:
+		limits = s3MetadataLimits
+	}
+	keys := make([]string, 0, len(art.Metadata))
+	for key := range art.Metadata {
+		keys = append(keys, key)
+	}
+	// the first invalid key is reported, whatever the order of the map
+	sort.Strings(keys)
+	size := 0
+	for _, key := range keys {
+		value := art.Metadata[key]
+		if !metadataKeyRegex.MatchString(key) {
+			return errors.Errorf(errors.CodeBadRequest, "%s.metadata key %q must be lowercase letters, digits and hyphens, starting and ending with a letter or digit", errPrefix, key)
+		}
+		if strings.HasPrefix(key, ReservedMetadataPrefix) {
+			return errors.Errorf(errors.CodeBadRequest, "%s.metadata key %q cannot start with %q, which is reserved", errPrefix, key, ReservedMetadataPrefix)
+		}
+		if limits.asciiValues && !isPrintableASCII(value) {
+			return errors.Errorf(errors.CodeBadRequest, "%s.metadata value of %q must be printable ASCII for %s", errPrefix, key, limits.name)
+		}
+		if !utf8.ValidString(value) {
+			return errors.Errorf(errors.CodeBadRequest, "%s.metadata value of %q must be UTF-8", errPrefix, key)
+		}
+		size += len(key) + len(value)
+	}
+	if size > limits.maxSize {
+		return errors.Errorf(errors.CodeBadRequest, "%s.metadata is %d bytes, but the metadata of %s objects can be at most %d bytes", errPrefix, size, limits.name, limits.maxSize)
+	}
+	return nil
+}
+
+// isPrintableASCII returns whether every byte of s is printable ASCII, including spaces
+func isPrintableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < ' ' || s[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// WithMetadata returns a copy of the user metadata of an object, with the metadata of its artifact
+func WithMetadata(metadata map[string]string, art *wfv1.Artifact) map[string]string {
+	if len(art.Metadata) == 0 {
+		return metadata
+	}
+	result := make(map[string]string, len(metadata)+len(art.Metadata))
+	for k, v := range art.Metadata {
+		result[k] = v
+	}
+	for k, v := range metadata {
+		result[k] = v
+	}
+	return result
+}
+
+// UserMetadata returns the user metadata of an object, as its storage returns it, without the keys the drivers
+// record themselves, and with its keys lowercased, as the metadata of an artifact has them
+func UserMetadata(metadata map[string]string) map[string]string {
+	result := map[string]string{}
+	for k, v := range metadata {
+		k = strings.ToLower(k)
+		if !strings.HasPrefix(k, ReservedMetadataPrefix) {
+			result[k] = v
+		}
+	}
+	return result
+}
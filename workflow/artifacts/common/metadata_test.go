@@ -0,0 +1,56 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+func TestValidateMetadata(t *testing.T) {
+	s3 := wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "my-key"}}
+	gcs := wfv1.ArtifactLocation{GCS: &wfv1.GCSArtifact{Key: "my-key"}}
+	assert.NoError(t, ValidateMetadata("my-art", &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{Git: &wfv1.GitArtifact{Repo: "my-repo"}}}))
+	assert.NoError(t, ValidateMetadata("my-art", &wfv1.Artifact{ArtifactLocation: s3, Metadata: map[string]string{"git-commit": "abc123", "build-id": "42"}}))
+	// an artifact without a location is saved to the artifact repository
+	assert.NoError(t, ValidateMetadata("my-art", &wfv1.Artifact{Metadata: map[string]string{"git-commit": "abc123"}}))
+	err := ValidateMetadata("my-art", &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{Git: &wfv1.GitArtifact{Repo: "my-repo"}}, Metadata: map[string]string{"git-commit": "abc123"}})
+	assert.EqualError(t, err, "my-art.metadata is only supported by s3, gcs and oss")
+	for _, key := range []string{"Git-Commit", "git_commit", "-git", "git-", ""} {
+		err := ValidateMetadata("my-art", &wfv1.Artifact{ArtifactLocation: s3, Metadata: map[string]string{key: "abc123"}})
+		assert.Error(t, err, key)
+	}
+	err = ValidateMetadata("my-art", &wfv1.Artifact{ArtifactLocation: s3, Metadata: map[string]string{"argo-sha256": "abc123"}})
+	assert.EqualError(t, err, `my-art.metadata key "argo-sha256" cannot start with "argo-", which is reserved`)
+	t.Run("Values", func(t *testing.T) {
+		// the values of S3 metadata are sent as headers, but those of GCS are sent in the body
+		err := ValidateMetadata("my-art", &wfv1.Artifact{ArtifactLocation: s3, Metadata: map[string]string{"author": "José"}})
+		assert.EqualError(t, err, `my-art.metadata value of "author" must be printable ASCII for s3`)
+		assert.NoError(t, ValidateMetadata("my-art", &wfv1.Artifact{ArtifactLocation: gcs, Metadata: map[string]string{"author": "José"}}))
+		err = ValidateMetadata("my-art", &wfv1.Artifact{ArtifactLocation: gcs, Metadata: map[string]string{"author": "\xff"}})
+		assert.EqualError(t, err, `my-art.metadata value of "author" must be UTF-8`)
+	})
+	t.Run("Size", func(t *testing.T) {
+		metadata := map[string]string{"notes": strings.Repeat("a", 4*1024)}
+		err := ValidateMetadata("my-art", &wfv1.Artifact{ArtifactLocation: s3, Metadata: metadata})
+		assert.EqualError(t, err, "my-art.metadata is 4101 bytes, but the metadata of s3 objects can be at most 2048 bytes")
+		assert.NoError(t, ValidateMetadata("my-art", &wfv1.Artifact{ArtifactLocation: gcs, Metadata: metadata}))
+	})
+}
+
+func TestWithMetadata(t *testing.T) {
+	metadata := map[string]string{"argo-sha256": "abc"}
+	assert.Equal(t, metadata, WithMetadata(metadata, &wfv1.Artifact{}))
+	merged := WithMetadata(metadata, &wfv1.Artifact{Metadata: map[string]string{"git-commit": "abc123"}})
+	assert.Equal(t, map[string]string{"argo-sha256": "abc", "git-commit": "abc123"}, merged)
+	// the metadata of the object is not changed
+	assert.Equal(t, map[string]string{"argo-sha256": "abc"}, metadata)
+	assert.Equal(t, map[string]string{"git-commit": "abc123"}, WithMetadata(nil, &wfv1.Artifact{Metadata: map[string]string{"git-commit": "abc123"}}))
+}
+
+func TestUserMetadata(t *testing.T) {
+	assert.Equal(t, map[string]string{}, UserMetadata(nil))
+	assert.Equal(t, map[string]string{"git-commit": "abc123"}, UserMetadata(map[string]string{"Git-Commit": "abc123", "Argo-Sha256": "abc"}))
+}
@@ -0,0 +1,45 @@
+package common
+
+import (
+	stderrors "errors"
+	"fmt"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+// ErrArtifactExists is matched, using errors.Is, by the errors drivers return when an output artifact is not saved
+// because its destination exists, and its overwrite policy does not allow replacing it
+var ErrArtifactExists = stderrors.New("artifact exists")
+
+// NewExistsError returns an ERR_BAD_REQUEST error which matches ErrArtifactExists, for an artifact whose destination,
+// e.g. "s3://my-bucket/my-key", exists
+func NewExistsError(location string) error {
+	return errors.Wrap(existsError{location}, errors.CodeBadRequest, existsError{location}.Error())
+}
+
+type existsError struct {
+	location string
+}
+
+func (e existsError) Error() string {
+	return fmt.Sprintf("%s already exists, and the overwrite policy of the artifact does not allow replacing it", e.location)
+}
+
+func (e existsError) Is(target error) bool {
+	return target == ErrArtifactExists
+}
+
+// ValidateOverwrite validates the overwrite policy of an output artifact
+func ValidateOverwrite(errPrefix string, overwrite wfv1.ArtifactOverwrite) error {
+	switch overwrite {
+	case "", wfv1.ArtifactOverwriteAllow, wfv1.ArtifactOverwriteFailIfExists, wfv1.ArtifactOverwriteSkipIfExists, wfv1.ArtifactOverwriteSkipIfUnchanged:
+		return nil
+	}
+	return errors.Errorf(errors.CodeBadRequest, "%s.overwrite %q must be one of: allow, fail-if-exists, skip-if-exists, skip-if-unchanged", errPrefix, overwrite)
+}
+
+// IsOverwriteProtected returns whether the artifact is saved only if its destination does not exist
+func IsOverwriteProtected(overwrite wfv1.ArtifactOverwrite) bool {
+	return overwrite == wfv1.ArtifactOverwriteFailIfExists || overwrite == wfv1.ArtifactOverwriteSkipIfExists
+}
@@ -0,0 +1,34 @@
+package common
+
+import (
+	stderrors "errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+func TestNewExistsError(t *testing.T) {
+	err := NewExistsError("s3://my-bucket/my-key")
+	assert.True(t, errors.IsCode(errors.CodeBadRequest, err))
+	assert.True(t, stderrors.Is(err, ErrArtifactExists))
+	assert.False(t, stderrors.Is(err, ErrArtifactNotFound))
+	assert.EqualError(t, err, "s3://my-bucket/my-key already exists, and the overwrite policy of the artifact does not allow replacing it")
+}
+
+func TestValidateOverwrite(t *testing.T) {
+	for _, overwrite := range []wfv1.ArtifactOverwrite{"", wfv1.ArtifactOverwriteAllow, wfv1.ArtifactOverwriteFailIfExists, wfv1.ArtifactOverwriteSkipIfExists, wfv1.ArtifactOverwriteSkipIfUnchanged} {
+		assert.NoError(t, ValidateOverwrite("outputs.artifacts.foo", overwrite))
+	}
+	assert.EqualError(t, ValidateOverwrite("outputs.artifacts.foo", "never"), `outputs.artifacts.foo.overwrite "never" must be one of: allow, fail-if-exists, skip-if-exists, skip-if-unchanged`)
+}
+
+func TestIsOverwriteProtected(t *testing.T) {
+	assert.False(t, IsOverwriteProtected(""))
+	assert.False(t, IsOverwriteProtected(wfv1.ArtifactOverwriteAllow))
+	assert.True(t, IsOverwriteProtected(wfv1.ArtifactOverwriteFailIfExists))
+	assert.True(t, IsOverwriteProtected(wfv1.ArtifactOverwriteSkipIfExists))
+	assert.False(t, IsOverwriteProtected(wfv1.ArtifactOverwriteSkipIfUnchanged))
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+)
+
+// MaxPresignExpiry is the longest a presigned URL can be valid for, which is the limit of S3 and GCS
+const MaxPresignExpiry = 7 * 24 * time.Hour
+
+// ValidatePresign validates presigning a URL for requests of the method of the object at key, valid for expiry. The
+// key of a "directory" has no object of its own, so it cannot be presigned.
+func ValidatePresign(key, method string, expiry time.Duration) error {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "presigned URLs cannot be of %q requests; they can be of GET, HEAD, PUT or DELETE ones", method)
+	}
+	if expiry < time.Second || expiry > MaxPresignExpiry {
+		return errors.Errorf(errors.CodeBadRequest, "the expiry %v of a presigned URL must be between 1s and %v", expiry, MaxPresignExpiry)
+	}
+	if key == "" || strings.HasSuffix(key, "/") {
+		return errors.Errorf(errors.CodeBadRequest, "%q is not the key of an object, as a presigned URL must be", key)
+	}
+	return nil
+}
+
+// MaxPostPolicySize is the largest object a presigned POST policy can allow uploads of, which is the limit of S3
+const MaxPostPolicySize = 5 * 1024 * 1024 * 1024
+
+// PostPolicy is what a presigned POST policy allows uploads of
+type PostPolicy struct {
+	// MinSize and MaxSize are the smallest and largest objects which can be uploaded, in bytes
+	MinSize, MaxSize int64
+	// Expiry is how long the policy is valid for
+	Expiry time.Duration
+}
+
+// PresignedPost is a presigned POST policy, which browsers upload objects with by a multipart/form-data POST request
+// of its URL with its fields, followed by a "file" field of the content of the object
+type PresignedPost struct {
+	URL    string
+	Fields map[string]string
+}
+
+// ValidatePostPolicy validates presigning a POST policy which allows uploads of objects whose keys start with
+// keyPrefix. A policy must be scoped to a prefix, so it cannot allow uploads of any key of a bucket.
+func ValidatePostPolicy(keyPrefix string, policy PostPolicy) error {
+	if policy.MinSize < 0 || policy.MaxSize < policy.MinSize || policy.MaxSize < 1 || policy.MaxSize > MaxPostPolicySize {
+		return errors.Errorf(errors.CodeBadRequest, "the size range %d-%d of a POST policy must be within 0-%d, and allow a non-empty object", policy.MinSize, policy.MaxSize, int64(MaxPostPolicySize))
+	}
+	if policy.Expiry < time.Second || policy.Expiry > MaxPresignExpiry {
+		return errors.Errorf(errors.CodeBadRequest, "the expiry %v of a POST policy must be between 1s and %v", policy.Expiry, MaxPresignExpiry)
+	}
+	if keyPrefix == "" {
+		return errors.New(errors.CodeBadRequest, "a POST policy must be of a key prefix")
+	}
+	return nil
+}
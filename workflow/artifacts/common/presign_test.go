@@ -0,0 +1,31 @@
+package common
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePresign(t *testing.T) {
+	assert.NoError(t, ValidatePresign("my-key", http.MethodGet, time.Hour))
+	assert.NoError(t, ValidatePresign("my-dir/my-key", http.MethodPut, MaxPresignExpiry))
+	assert.Error(t, ValidatePresign("my-key", http.MethodPost, time.Hour))
+	assert.Error(t, ValidatePresign("my-key", http.MethodGet, 0))
+	assert.Error(t, ValidatePresign("my-key", http.MethodGet, MaxPresignExpiry+time.Second))
+	assert.Error(t, ValidatePresign("my-dir/", http.MethodGet, time.Hour))
+	assert.Error(t, ValidatePresign("", http.MethodGet, time.Hour))
+}
+
+func TestValidatePostPolicy(t *testing.T) {
+	assert.NoError(t, ValidatePostPolicy("uploads/", PostPolicy{MaxSize: 1024, Expiry: time.Hour}))
+	assert.NoError(t, ValidatePostPolicy("uploads/", PostPolicy{MinSize: 1, MaxSize: MaxPostPolicySize, Expiry: MaxPresignExpiry}))
+	assert.Error(t, ValidatePostPolicy("uploads/", PostPolicy{MinSize: -1, MaxSize: 1024, Expiry: time.Hour}))
+	assert.Error(t, ValidatePostPolicy("uploads/", PostPolicy{MinSize: 2048, MaxSize: 1024, Expiry: time.Hour}))
+	assert.Error(t, ValidatePostPolicy("uploads/", PostPolicy{Expiry: time.Hour}))
+	assert.Error(t, ValidatePostPolicy("uploads/", PostPolicy{MaxSize: MaxPostPolicySize + 1, Expiry: time.Hour}))
+	assert.Error(t, ValidatePostPolicy("uploads/", PostPolicy{MaxSize: 1024}))
+	assert.Error(t, ValidatePostPolicy("uploads/", PostPolicy{MaxSize: 1024, Expiry: MaxPresignExpiry + time.Second}))
+	assert.Error(t, ValidatePostPolicy("", PostPolicy{MaxSize: 1024, Expiry: time.Hour}))
+}
@@ -0,0 +1,76 @@
+package common
+
+import (
+	"io"
+	"time"
+)
+
+// ProgressFunc is called with the number of bytes of an artifact transferred so far, and the total number
+// of bytes to transfer, or -1 if that is not known
+type ProgressFunc func(bytesTransferred, totalBytes int64)
+
+// progressInterval is the longest time between reports
+var progressInterval = 5 * time.Second
+
+// ProgressCounter counts the bytes of a transfer, and reports them each time another tenth of the total is
+// transferred or progressInterval has passed, rather than on every write. A nil counter counts nothing,
+// so drivers can use one whether or not progress was requested.
+type ProgressCounter struct {
+	progress    ProgressFunc
+	total       int64
+	transferred int64
+	reported    int64
+	lastReport  time.Time
+}
+
+// NewProgressCounter returns a counter which reports to progress, or nil if progress is nil
+func NewProgressCounter(totalBytes int64, progress ProgressFunc) *ProgressCounter {
+	if progress == nil {
+		return nil
+	}
+	return &ProgressCounter{progress: progress, total: totalBytes, lastReport: time.Now()}
+}
+
+// Add counts n more bytes as transferred
+func (c *ProgressCounter) Add(n int64) {
+	if c == nil || n <= 0 {
+		return
+	}
+	c.transferred += n
+	if (c.total > 0 && c.transferred-c.reported >= c.total/10) || time.Since(c.lastReport) >= progressInterval {
+		c.report()
+	}
+}
+
+// Done reports the bytes transferred, unless they have been reported already
+func (c *ProgressCounter) Done() {
+	if c == nil || c.transferred == c.reported {
+		return
+	}
+	c.report()
+}
+
+func (c *ProgressCounter) report() {
+	c.reported = c.transferred
+	c.lastReport = time.Now()
+	c.progress(c.transferred, c.total)
+}
+
+// Reader returns a reader which counts the bytes read from r
+func (c *ProgressCounter) Reader(r io.Reader) io.Reader {
+	if c == nil {
+		return r
+	}
+	return &progressReader{Reader: r, counter: c}
+}
+
+type progressReader struct {
+	io.Reader
+	counter *ProgressCounter
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.counter.Add(int64(n))
+	return n, err
+}
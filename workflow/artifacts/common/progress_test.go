@@ -0,0 +1,62 @@
+package common
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressCounter(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		counter := NewProgressCounter(10, nil)
+		assert.Nil(t, counter)
+		counter.Add(10)
+		counter.Done()
+		r := bytes.NewReader(nil)
+		assert.Equal(t, r, counter.Reader(r))
+	})
+	t.Run("Reader", func(t *testing.T) {
+		data := bytes.Repeat([]byte("x"), 1000)
+		var transferred []int64
+		counter := NewProgressCounter(int64(len(data)), func(bytesTransferred, totalBytes int64) {
+			assert.Equal(t, int64(len(data)), totalBytes)
+			transferred = append(transferred, bytesTransferred)
+		})
+		// read one byte at a time, so that the reports must be throttled
+		n, err := io.Copy(ioutil.Discard, counter.Reader(&oneByteReader{bytes.NewReader(data)}))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(data)), n)
+		counter.Done()
+		assert.Len(t, transferred, 10)
+		for i := 1; i < len(transferred); i++ {
+			assert.Greater(t, transferred[i], transferred[i-1])
+		}
+		assert.Equal(t, int64(len(data)), transferred[len(transferred)-1])
+	})
+	t.Run("UnknownTotal", func(t *testing.T) {
+		var transferred []int64
+		counter := NewProgressCounter(-1, func(bytesTransferred, totalBytes int64) {
+			assert.Equal(t, int64(-1), totalBytes)
+			transferred = append(transferred, bytesTransferred)
+		})
+		counter.Add(10)
+		counter.Add(10)
+		counter.Done()
+		counter.Done()
+		assert.Equal(t, []int64{20}, transferred)
+	})
+}
+
+type oneByteReader struct {
+	io.Reader
+}
+
+func (r *oneByteReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return r.Reader.Read(p[:1])
+}
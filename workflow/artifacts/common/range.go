@@ -0,0 +1,17 @@
+package common
+
+import "fmt"
+
+// ByteRangeSpec returns the range of length bytes from the offset start, e.g. "0-99", or of every byte from start,
+// e.g. "100-", if length is negative
+func ByteRangeSpec(start, length int64) string {
+	if length < 0 {
+		return fmt.Sprintf("%d-", start)
+	}
+	return fmt.Sprintf("%d-%d", start, start+length-1)
+}
+
+// ByteRange returns the Range header of an HTTP request for the range of bytes, e.g. "bytes=0-99"
+func ByteRange(start, length int64) string {
+	return "bytes=" + ByteRangeSpec(start, length)
+}
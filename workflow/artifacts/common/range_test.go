@@ -0,0 +1,14 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestByteRange(t *testing.T) {
+	assert.Equal(t, "bytes=0-99", ByteRange(0, 100))
+	assert.Equal(t, "bytes=10-10", ByteRange(10, 1))
+	assert.Equal(t, "bytes=100-", ByteRange(100, -1))
+	assert.Equal(t, "0-", ByteRangeSpec(0, -1))
+}
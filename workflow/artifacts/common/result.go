@@ -0,0 +1,42 @@
+package common
+
+import "sort"
+
+// SaveResult describes what saving an artifact wrote, as its storage reported it
+type SaveResult struct {
+	// Location is the URL of the artifact, e.g. s3://my-bucket/my-key
+	Location string `json:"location"`
+	// Objects are the objects written, in the order of their keys: the file itself, or each file of the directory
+	Objects []SavedObject `json:"objects"`
+	// Size is the total size of the objects
+	Size int64 `json:"size"`
+	// Unchanged is whether the artifact was not saved because its destination has the same content, in which case
+	// there are no objects
+	Unchanged bool `json:"unchanged,omitempty"`
+}
+
+// SavedObject is an object which saving an artifact wrote
+type SavedObject struct {
+	// Key is the key of the object
+	Key string `json:"key"`
+	// Path is the path of the local file written to the object
+	Path string `json:"path"`
+	// Size is the size of the object, after any compression
+	Size int64 `json:"size"`
+	// ETag is the entity tag of the object, without quotes, e.g. the MD5 of the object in S3 if it was put in one part
+	ETag string `json:"etag,omitempty"`
+	// VersionID is the version of the object, if its bucket is versioned, e.g. the generation of the object in GCS
+	VersionID string `json:"versionId,omitempty"`
+	// LinkTarget is the target of the symlink the object preserves, if the file is one
+	LinkTarget string `json:"linkTarget,omitempty"`
+}
+
+// NewSaveResult returns the result of saving the objects to location, which may have been saved in any order
+func NewSaveResult(location string, objects []SavedObject) *SaveResult {
+	result := &SaveResult{Location: location, Objects: append([]SavedObject{}, objects...)}
+	sort.Slice(result.Objects, func(i, j int) bool { return result.Objects[i].Key < result.Objects[j].Key })
+	for _, object := range result.Objects {
+		result.Size += object.Size
+	}
+	return result
+}
@@ -0,0 +1,26 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSaveResult(t *testing.T) {
+	objects := []SavedObject{
+		{Key: "my-key/b", Path: "/tmp/my-dir/b", Size: 2, ETag: "b-etag"},
+		{Key: "my-key/a", Path: "/tmp/my-dir/a", Size: 1, ETag: "a-etag", VersionID: "1"},
+	}
+	result := NewSaveResult("s3://my-bucket/my-key", objects)
+	assert.Equal(t, &SaveResult{
+		Location: "s3://my-bucket/my-key",
+		Objects: []SavedObject{
+			{Key: "my-key/a", Path: "/tmp/my-dir/a", Size: 1, ETag: "a-etag", VersionID: "1"},
+			{Key: "my-key/b", Path: "/tmp/my-dir/b", Size: 2, ETag: "b-etag"},
+		},
+		Size: 3,
+	}, result)
+	// the objects passed are not reordered
+	assert.Equal(t, "my-key/b", objects[0].Key)
+	assert.Equal(t, []SavedObject{}, NewSaveResult("s3://my-bucket/my-key", nil).Objects)
+}
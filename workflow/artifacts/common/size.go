@@ -0,0 +1,116 @@
+package common
+
+import (
+	stderrors "errors"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+// DefaultMaxSize is the max size in bytes of loading or saving an artifact which does not set one, e.g. that of the
+// environment of the executor, or 0 if their sizes are not limited
+var DefaultMaxSize int64
+
+// ErrArtifactTooLarge is matched, using errors.Is, by the errors of loading or saving an artifact which is larger than
+// its max size
+var ErrArtifactTooLarge = stderrors.New("artifact is too large")
+
+// NewTooLargeError returns an ERR_BAD_REQUEST error which matches ErrArtifactTooLarge, for an artifact of size bytes,
+// or of more than size bytes if it is still being transferred, which is larger than maxSize
+func NewTooLargeError(size, maxSize int64, transferring bool) error {
+	err := tooLargeError{size: size, maxSize: maxSize, transferring: transferring}
+	return errors.Wrap(err, errors.CodeBadRequest, err.Error())
+}
+
+type tooLargeError struct {
+	size, maxSize int64
+	transferring  bool
+}
+
+func (e tooLargeError) Error() string {
+	if e.transferring {
+		return fmt.Sprintf("the artifact is larger than its max size of %d bytes, as more than %d bytes of it were transferred", e.maxSize, e.size)
+	}
+	return fmt.Sprintf("the artifact is %d bytes, which is larger than its max size of %d bytes", e.size, e.maxSize)
+}
+
+func (e tooLargeError) Is(target error) bool {
+	return target == ErrArtifactTooLarge
+}
+
+// ValidateMaxSize validates the max size of an artifact
+func ValidateMaxSize(errPrefix string, art *wfv1.Artifact) error {
+	if _, err := ParseMaxSize(art.MaxSize); err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s.maxSize %s", errPrefix, err.Error())
+	}
+	return nil
+}
+
+// ParseMaxSize returns a max size in bytes, or 0 if it is not set
+func ParseMaxSize(maxSize string) (int64, error) {
+	if maxSize == "" {
+		return 0, nil
+	}
+	q, err := resource.ParseQuantity(maxSize)
+	if err != nil {
+		return 0, errors.Errorf(errors.CodeBadRequest, "must be a quantity of bytes, e.g. 10Gi: %v", err)
+	}
+	if q.Value() < 1 {
+		return 0, errors.Errorf(errors.CodeBadRequest, "must be at least 1 byte")
+	}
+	return q.Value(), nil
+}
+
+// MaxSize returns the max size in bytes of loading or saving the artifact, which is DefaultMaxSize if it does not set
+// one, or 0 if its size is not limited
+func MaxSize(art *wfv1.Artifact) (int64, error) {
+	if art.MaxSize == "" {
+		return DefaultMaxSize, nil
+	}
+	return ParseMaxSize(art.MaxSize)
+}
+
+// CheckSize returns an error which matches ErrArtifactTooLarge if size, e.g. that the storage of an artifact reports
+// before it is transferred, is larger than maxSize, which is not a limit if it is not positive
+func CheckSize(size, maxSize int64) error {
+	if maxSize > 0 && size > maxSize {
+		return NewTooLargeError(size, maxSize, false)
+	}
+	return nil
+}
+
+// NewSizeLimitedReader returns a reader of r which fails with an error which matches ErrArtifactTooLarge once more
+// than maxSize bytes are read, including the offset bytes transferred before it, e.g. by an interrupted download it
+// resumes, so that a source which does not report its size cannot fill the disk. It is r if maxSize is not positive.
+func NewSizeLimitedReader(r io.Reader, maxSize, offset int64) io.Reader {
+	if maxSize <= 0 {
+		return r
+	}
+	return &sizeLimitedReader{r: r, maxSize: maxSize, read: offset}
+}
+
+type sizeLimitedReader struct {
+	r       io.Reader
+	maxSize int64
+	read    int64
+}
+
+func (r *sizeLimitedReader) Read(p []byte) (int, error) {
+	if r.read > r.maxSize {
+		return 0, NewTooLargeError(r.maxSize, r.maxSize, true)
+	}
+	// one byte past the limit is read, so that a source of exactly maxSize bytes is not an error
+	if remaining := r.maxSize - r.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := r.r.Read(p)
+	r.read += int64(n)
+	if r.read > r.maxSize {
+		return n - 1, NewTooLargeError(r.maxSize, r.maxSize, true)
+	}
+	return n, err
+}
@@ -0,0 +1,66 @@
+package common
+
+import (
+	"bytes"
+	stderrors "errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+func TestMaxSize(t *testing.T) {
+	maxSize, err := MaxSize(&wfv1.Artifact{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), maxSize)
+	maxSize, err = MaxSize(&wfv1.Artifact{MaxSize: "10Gi"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10*1024*1024*1024), maxSize)
+	DefaultMaxSize = 1024
+	defer func() { DefaultMaxSize = 0 }()
+	maxSize, err = MaxSize(&wfv1.Artifact{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1024), maxSize)
+	for _, invalid := range []string{"big", "0"} {
+		assert.Error(t, ValidateMaxSize("my-art", &wfv1.Artifact{MaxSize: invalid}), invalid)
+	}
+	assert.NoError(t, ValidateMaxSize("my-art", &wfv1.Artifact{MaxSize: "1Ki"}))
+}
+
+func TestCheckSize(t *testing.T) {
+	assert.NoError(t, CheckSize(10, 0))
+	assert.NoError(t, CheckSize(10, 10))
+	err := CheckSize(11, 10)
+	assert.True(t, stderrors.Is(err, ErrArtifactTooLarge))
+	assert.True(t, errors.IsCode(errors.CodeBadRequest, err))
+	assert.Contains(t, err.Error(), "11 bytes")
+}
+
+func TestNewSizeLimitedReader(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	t.Run("Unlimited", func(t *testing.T) {
+		read, err := ioutil.ReadAll(NewSizeLimitedReader(bytes.NewReader(data), 0, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, data, read)
+	})
+	t.Run("AtLimit", func(t *testing.T) {
+		read, err := ioutil.ReadAll(NewSizeLimitedReader(bytes.NewReader(data), 1000, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, data, read)
+	})
+	t.Run("OverLimit", func(t *testing.T) {
+		read, err := ioutil.ReadAll(NewSizeLimitedReader(bytes.NewReader(data), 999, 0))
+		assert.True(t, stderrors.Is(err, ErrArtifactTooLarge))
+		// no more than the limit is read
+		assert.Equal(t, data[:999], read)
+	})
+	t.Run("Offset", func(t *testing.T) {
+		// the bytes transferred before a resumed download count towards the limit
+		read, err := ioutil.ReadAll(NewSizeLimitedReader(bytes.NewReader(data), 1000, 500))
+		assert.True(t, stderrors.Is(err, ErrArtifactTooLarge))
+		assert.Len(t, read, 500)
+	})
+}
@@ -0,0 +1,24 @@
+package common
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"path"
+	"strings"
+)
+
+// StagingKey returns a unique key prefix next to the key of a directory artifact, which is hidden by a leading dot,
+// that the objects of its files are put under when it is saved atomically, before they are copied to the key
+func StagingKey(key string) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	dir, base := path.Split(strings.TrimSuffix(key, "/"))
+	return dir + "." + base + ".argo-staging-" + hex.EncodeToString(b), nil
+}
+
+// StagedKey returns the key under the key of a directory artifact that an object put under its staging key is copied to
+func StagedKey(key, stagingKey, objectKey string) string {
+	return path.Join(key, strings.TrimPrefix(objectKey, stagingKey+"/"))
+}
@@ -0,0 +1,30 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStagingKey(t *testing.T) {
+	staging, err := StagingKey("my-prefix/my-dir/")
+	if assert.NoError(t, err) {
+		assert.True(t, strings.HasPrefix(staging, "my-prefix/.my-dir.argo-staging-"), staging)
+		// files of the directory are not under the staging key, nor its objects under the directory
+		assert.False(t, strings.HasPrefix(staging, "my-prefix/my-dir/"))
+	}
+	other, err := StagingKey("my-prefix/my-dir")
+	if assert.NoError(t, err) {
+		assert.NotEqual(t, staging, other)
+	}
+	staging, err = StagingKey("my-dir")
+	if assert.NoError(t, err) {
+		assert.True(t, strings.HasPrefix(staging, ".my-dir.argo-staging-"), staging)
+	}
+}
+
+func TestStagedKey(t *testing.T) {
+	assert.Equal(t, "my-dir/a", StagedKey("my-dir", ".my-dir.argo-staging-1", ".my-dir.argo-staging-1/a"))
+	assert.Equal(t, "my-prefix/my-dir/sub/b", StagedKey("my-prefix/my-dir/", "my-prefix/.my-dir.argo-staging-1", "my-prefix/.my-dir.argo-staging-1/sub/b"))
+}
@@ -0,0 +1,215 @@
+package common
+
+import (
+	stderrors "errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+// SymlinkTargetMetadataKey is the key of the user metadata of the empty object a preserved symlink is saved as, which
+// records the slash separated target of the symlink
+const SymlinkTargetMetadataKey = "argo-symlink-target"
+
+// ErrSymlinkNotFollowed is matched, using errors.Is, by the errors walking a directory returns when a symlink under it
+// cannot be followed, which walking it again does not change
+var ErrSymlinkNotFollowed = stderrors.New("symlink cannot be followed")
+
+type symlinkError struct {
+	msg string
+}
+
+func (e symlinkError) Error() string {
+	return e.msg
+}
+
+func (e symlinkError) Is(target error) bool {
+	return target == ErrSymlinkNotFollowed
+}
+
+// ValidateSymlinks validates the symlink policy of an output artifact
+func ValidateSymlinks(errPrefix string, art *wfv1.Artifact) error {
+	switch art.Symlinks {
+	case "":
+		return nil
+	case wfv1.ArtifactSymlinksFollow, wfv1.ArtifactSymlinksSkip, wfv1.ArtifactSymlinksPreserve:
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "%s.symlinks %q must be one of: follow, skip, preserve", errPrefix, art.Symlinks)
+	}
+	if art.Archive == nil || art.Archive.None == nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s.symlinks requires archive none", errPrefix)
+	}
+	return nil
+}
+
+// WalkedFile is a file of a directory which matches a filter
+type WalkedFile struct {
+	// Path is the path of the file, which is under a followed symlink if the file is under the directory it links to
+	Path string
+	// RelPath is the slash separated path of the file relative to the directory
+	RelPath string
+	// Info is the info of the file, or of the file a followed symlink links to
+	Info os.FileInfo
+	// LinkTarget is the slash separated target of the file if it is a preserved symlink, which is saved in its place
+	LinkTarget string
+}
+
+// Walk calls fn for each regular file under the directory dir which matches the filter, in lexical order, and for
+// each symlink under it by the symlink policy of the filter: follow calls fn for the file, or for each file under the
+// directory, it links to, as if it were under dir; preserve calls fn for the symlink itself; and skip, the default,
+// does not call fn for it. A followed symlink which links to nothing is an error, as is one which links to a
+// directory it is under, which would be followed forever.
+func (f FileFilter) Walk(dir string, fn func(WalkedFile) error) error {
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	return f.walk(dir, "", map[string]bool{realDir: true}, fn)
+}
+
+// walk walks the directory dir, at the path relDir relative to the directory walked, which is under the directories
+// with the real paths of ancestors
+func (f FileFilter) walk(dir, relDir string, ancestors map[string]bool, fn func(WalkedFile) error) error {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		p := filepath.Join(dir, info.Name())
+		relPath := path.Join(relDir, info.Name())
+		if info.Mode()&os.ModeSymlink != 0 {
+			switch f.Symlinks {
+			case wfv1.ArtifactSymlinksPreserve:
+				if !f.Matches(relPath) {
+					continue
+				}
+				target, err := os.Readlink(p)
+				if err != nil {
+					return err
+				}
+				if err := fn(WalkedFile{Path: p, RelPath: relPath, Info: info, LinkTarget: filepath.ToSlash(target)}); err != nil {
+					return err
+				}
+				continue
+			case wfv1.ArtifactSymlinksFollow:
+				if info, err = os.Stat(p); os.IsNotExist(err) {
+					return symlinkError{fmt.Sprintf("symlink %s cannot be followed, as what it links to does not exist", p)}
+				} else if err != nil {
+					return err
+				}
+			default:
+				continue
+			}
+		}
+		switch {
+		case info.IsDir():
+			realPath, err := filepath.EvalSymlinks(p)
+			if err != nil {
+				return err
+			}
+			if ancestors[realPath] {
+				return symlinkError{fmt.Sprintf("symlink %s cannot be followed, as it links to %s, which it is under", p, realPath)}
+			}
+			ancestors[realPath] = true
+			err = f.walk(p, relPath, ancestors, fn)
+			delete(ancestors, realPath)
+			if err != nil {
+				return err
+			}
+		case info.Mode().IsRegular() && f.Matches(relPath):
+			if err := fn(WalkedFile{Path: p, RelPath: relPath, Info: info}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CreateSymlink creates the symlink to the slash separated target, which the object loaded to path under the directory
+// dir preserves, in place of anything loaded there, returning whether it was created. A symlink which links to an
+// absolute path, or outside of the directory, is not created, so that a loaded artifact cannot link to other files. Nor
+// is one whose target has .. after another element, e.g. sub/link/.., which symlinks loaded under the directory could
+// take outside of it. Creating a symlink under another loaded symlink is an error, as CheckLoadPath returns.
+func CreateSymlink(dir, path, target string) (bool, error) {
+	target = filepath.FromSlash(target)
+	if filepath.IsAbs(target) || !isDescending(target) {
+		return false, nil
+	}
+	if err := CheckLoadPath(dir, path); err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return false, err
+	}
+	// the target is relative to where the symlink is on disk, which the directory itself may be linked to
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false, err
+	}
+	realParent, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return false, err
+	}
+	rel, err := filepath.Rel(realDir, filepath.Join(realParent, target))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false, nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	return true, os.Symlink(target, path)
+}
+
+// isDescending returns whether the only .. elements of the relative path target are those it starts with
+func isDescending(target string) bool {
+	descending := false
+	for _, name := range strings.Split(target, string(filepath.Separator)) {
+		switch name {
+		case "", ".":
+		case "..":
+			if descending {
+				return false
+			}
+		default:
+			descending = true
+		}
+	}
+	return true
+}
+
+// CheckLoadPath returns an error which matches ErrSymlinkNotFollowed if the path an object is loaded to under the
+// directory dir is, or is under, a symlink, e.g. one which a preserved symlink of the directory was loaded as, so that
+// no object is written outside of the directory through one
+func CheckLoadPath(dir, path string) error {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		return nil
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is outside of the directory %s", path, dir)
+	}
+	p := dir
+	for _, name := range strings.Split(rel, string(filepath.Separator)) {
+		p = filepath.Join(p, name)
+		info, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return symlinkError{fmt.Sprintf("%s cannot be loaded through the symlink %s, which was loaded before it", path, p)}
+		}
+	}
+	return nil
+}
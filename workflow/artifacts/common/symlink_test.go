@@ -0,0 +1,179 @@
+package common
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+func TestValidateSymlinks(t *testing.T) {
+	none := &wfv1.ArchiveStrategy{None: &wfv1.NoneStrategy{}}
+	assert.NoError(t, ValidateSymlinks("my-art", &wfv1.Artifact{}))
+	for _, policy := range []wfv1.ArtifactSymlinks{wfv1.ArtifactSymlinksFollow, wfv1.ArtifactSymlinksSkip, wfv1.ArtifactSymlinksPreserve} {
+		assert.NoError(t, ValidateSymlinks("my-art", &wfv1.Artifact{Symlinks: policy, Archive: none}), policy)
+	}
+	assert.EqualError(t, ValidateSymlinks("my-art", &wfv1.Artifact{Symlinks: "copy", Archive: none}), `my-art.symlinks "copy" must be one of: follow, skip, preserve`)
+	assert.EqualError(t, ValidateSymlinks("my-art", &wfv1.Artifact{Symlinks: wfv1.ArtifactSymlinksFollow}), "my-art.symlinks requires archive none")
+}
+
+// newSymlinkDirectory returns a directory of files, with symlinks to a file and a directory in it, and one which links
+// to nothing
+//
+//	a
+//	lib/b
+//	link-a -> a
+//	link-lib -> lib
+//	dangling -> missing
+func newSymlinkDirectory(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "symlinks")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0600))
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "lib"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "lib", "b"), []byte("bb"), 0600))
+	assert.NoError(t, os.Symlink("a", filepath.Join(dir, "link-a")))
+	assert.NoError(t, os.Symlink("lib", filepath.Join(dir, "link-lib")))
+	assert.NoError(t, os.Symlink("missing", filepath.Join(dir, "dangling")))
+	return dir
+}
+
+func TestFileFilter_Walk(t *testing.T) {
+	dir := newSymlinkDirectory(t)
+	defer func() { _ = os.RemoveAll(dir) }()
+	// walk returns the sizes of the files walked, or the targets of the symlinks preserved, by their paths
+	walk := func(filter FileFilter, dir string) (map[string]interface{}, error) {
+		files := map[string]interface{}{}
+		err := filter.Walk(dir, func(f WalkedFile) error {
+			if f.LinkTarget != "" {
+				files[f.RelPath] = f.LinkTarget
+			} else {
+				files[f.RelPath] = f.Info.Size()
+			}
+			return nil
+		})
+		return files, err
+	}
+
+	t.Run("Skip", func(t *testing.T) {
+		for _, policy := range []wfv1.ArtifactSymlinks{"", wfv1.ArtifactSymlinksSkip} {
+			files, err := walk(FileFilter{Symlinks: policy}, dir)
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]interface{}{"a": int64(1), "lib/b": int64(2)}, files, policy)
+		}
+	})
+	t.Run("Preserve", func(t *testing.T) {
+		files, err := walk(FileFilter{Symlinks: wfv1.ArtifactSymlinksPreserve}, dir)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"a": int64(1), "lib/b": int64(2), "link-a": "a", "link-lib": "lib", "dangling": "missing"}, files)
+	})
+	t.Run("PreserveFiltered", func(t *testing.T) {
+		files, err := walk(FileFilter{Symlinks: wfv1.ArtifactSymlinksPreserve, Exclude: []string{"link-*"}}, dir)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"a": int64(1), "lib/b": int64(2), "dangling": "missing"}, files)
+	})
+	t.Run("Follow", func(t *testing.T) {
+		_, err := walk(FileFilter{Symlinks: wfv1.ArtifactSymlinksFollow}, dir)
+		assert.EqualError(t, err, "symlink "+filepath.Join(dir, "dangling")+" cannot be followed, as what it links to does not exist")
+		assert.True(t, errors.Is(err, ErrSymlinkNotFollowed))
+		assert.NoError(t, os.Remove(filepath.Join(dir, "dangling")))
+		defer func() { assert.NoError(t, os.Symlink("missing", filepath.Join(dir, "dangling"))) }()
+		files, err := walk(FileFilter{Symlinks: wfv1.ArtifactSymlinksFollow}, dir)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"a": int64(1), "lib/b": int64(2), "link-a": int64(1), "link-lib/b": int64(2)}, files)
+	})
+	t.Run("FollowLoop", func(t *testing.T) {
+		loop, err := ioutil.TempDir("", "loop")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer func() { _ = os.RemoveAll(loop) }()
+		assert.NoError(t, os.MkdirAll(filepath.Join(loop, "a", "b"), 0700))
+		assert.NoError(t, os.Symlink("../..", filepath.Join(loop, "a", "b", "up")))
+		_, err = walk(FileFilter{Symlinks: wfv1.ArtifactSymlinksFollow}, loop)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "which it is under")
+			assert.True(t, errors.Is(err, ErrSymlinkNotFollowed))
+		}
+		// a loop is only a loop if it is followed
+		files, err := walk(FileFilter{Symlinks: wfv1.ArtifactSymlinksPreserve}, loop)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"a/b/up": "../.."}, files)
+	})
+}
+
+func TestCreateSymlink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "create-symlink")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	t.Run("Internal", func(t *testing.T) {
+		path := filepath.Join(dir, "sub", "link")
+		// the empty file the object was loaded to is replaced
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		assert.NoError(t, ioutil.WriteFile(path, nil, 0600))
+		created, err := CreateSymlink(dir, path, "../a")
+		assert.NoError(t, err)
+		assert.True(t, created)
+		target, err := os.Readlink(path)
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.FromSlash("../a"), target)
+	})
+	t.Run("Dangling", func(t *testing.T) {
+		created, err := CreateSymlink(dir, filepath.Join(dir, "dangling"), "missing")
+		assert.NoError(t, err)
+		assert.True(t, created)
+	})
+	t.Run("Outside", func(t *testing.T) {
+		for _, target := range []string{"/etc/passwd", "../outside", "sub/../../outside"} {
+			path := filepath.Join(dir, "outside")
+			created, err := CreateSymlink(dir, path, target)
+			assert.NoError(t, err)
+			assert.False(t, created, target)
+			_, err = os.Lstat(path)
+			assert.True(t, os.IsNotExist(err), target)
+		}
+	})
+	t.Run("Chain", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "create-symlink-chain")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer func() { _ = os.RemoveAll(dir) }()
+		// a symlink to the directory itself is within it
+		created, err := CreateSymlink(dir, filepath.Join(dir, "sub", "link"), "..")
+		assert.NoError(t, err)
+		assert.True(t, created)
+		// sub/link/.. is dir/sub lexically, but the parent of the directory on disk
+		created, err = CreateSymlink(dir, filepath.Join(dir, "escape"), "sub/link/..")
+		assert.NoError(t, err)
+		assert.False(t, created)
+		_, err = os.Lstat(filepath.Join(dir, "escape"))
+		assert.True(t, os.IsNotExist(err))
+		// nothing is loaded through a loaded symlink, which could have been created before this check
+		assert.NoError(t, os.Symlink(filepath.FromSlash("sub/link/.."), filepath.Join(dir, "escape")))
+		err = CheckLoadPath(dir, filepath.Join(dir, "escape", "evil"))
+		assert.True(t, errors.Is(err, ErrSymlinkNotFollowed))
+		_, err = CreateSymlink(dir, filepath.Join(dir, "escape", "evil"), "a")
+		assert.True(t, errors.Is(err, ErrSymlinkNotFollowed))
+		_, err = os.Lstat(filepath.Join(filepath.Dir(dir), "evil"))
+		assert.True(t, os.IsNotExist(err))
+		assert.NoError(t, CheckLoadPath(dir, filepath.Join(dir, "sub", "file")))
+	})
+	t.Run("LinkedDirectory", func(t *testing.T) {
+		// the directory is compared with where the symlink is on disk, so a directory under a symlink is not outside
+		linked := filepath.Join(filepath.Dir(dir), filepath.Base(dir)+"-linked")
+		assert.NoError(t, os.Symlink(dir, linked))
+		defer func() { _ = os.Remove(linked) }()
+		created, err := CreateSymlink(linked, filepath.Join(linked, "linked", "link"), "../a")
+		assert.NoError(t, err)
+		assert.True(t, created)
+	})
+}
@@ -0,0 +1,41 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// DefaultTempDir is the directory drivers stage the intermediate files they buffer to disk in, e.g. that of the
+// environment of the executor, or "" for the default directory for temporary files, which is often a small tmpfs.
+// Artifacts which are loaded atomically are staged next to the path they are loaded to, not in it, so that they can
+// be renamed into place.
+var DefaultTempDir string
+
+// TempDir returns the directory drivers stage intermediate files in, which is created if it does not exist
+func TempDir() (string, error) {
+	if DefaultTempDir == "" {
+		return os.TempDir(), nil
+	}
+	if err := os.MkdirAll(DefaultTempDir, 0700); err != nil {
+		return "", err
+	}
+	return DefaultTempDir, nil
+}
+
+// CreateTemp creates a temporary file in TempDir, as ioutil.TempFile does, which the caller removes
+func CreateTemp(pattern string) (*os.File, error) {
+	dir, err := TempDir()
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.TempFile(dir, pattern)
+}
+
+// MkdirTemp creates a temporary directory in TempDir, as ioutil.TempDir does, which the caller removes
+func MkdirTemp(pattern string) (string, error) {
+	dir, err := TempDir()
+	if err != nil {
+		return "", err
+	}
+	return ioutil.TempDir(dir, pattern)
+}
@@ -0,0 +1,34 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTempDir(t *testing.T) {
+	dir, err := TempDir()
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.TempDir(), dir)
+	}
+	tmp, err := ioutil.TempDir("", "temp-dir")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	DefaultTempDir = filepath.Join(tmp, "staging")
+	defer func() { DefaultTempDir = "" }()
+	// the temp dir is created once it is needed
+	f, err := CreateTemp("my-file.")
+	if assert.NoError(t, err) {
+		_ = f.Close()
+		assert.Equal(t, DefaultTempDir, filepath.Dir(f.Name()))
+	}
+	d, err := MkdirTemp("my-dir.")
+	if assert.NoError(t, err) {
+		assert.Equal(t, DefaultTempDir, filepath.Dir(d))
+	}
+}
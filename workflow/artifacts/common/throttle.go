@@ -0,0 +1,200 @@
+package common
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+// DefaultBandwidthLimit is the bandwidth limit in bytes per second of loading or saving an artifact which does not
+// set one, e.g. that of the environment of the executor, or 0 if they are not limited
+var DefaultBandwidthLimit int64
+
+// maxThrottleChunk is the most bytes a throttled reader or writer transfers at once
+const maxThrottleChunk = 32 * 1024
+
+// ValidateBandwidthLimit validates the bandwidth limit of an artifact
+func ValidateBandwidthLimit(errPrefix string, art *wfv1.Artifact) error {
+	if _, err := ParseBandwidthLimit(art.BandwidthLimit); err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s.bandwidthLimit %s", errPrefix, err.Error())
+	}
+	return nil
+}
+
+// ParseBandwidthLimit returns a bandwidth limit in bytes per second, or 0 if it is not set
+func ParseBandwidthLimit(limit string) (int64, error) {
+	if limit == "" {
+		return 0, nil
+	}
+	q, err := resource.ParseQuantity(limit)
+	if err != nil {
+		return 0, errors.Errorf(errors.CodeBadRequest, "must be a quantity of bytes per second, e.g. 10Mi: %v", err)
+	}
+	if q.Value() < 1 {
+		return 0, errors.Errorf(errors.CodeBadRequest, "must be at least 1 byte per second")
+	}
+	return q.Value(), nil
+}
+
+// BandwidthLimit returns the bandwidth limit of loading or saving the artifact in bytes per second, which is
+// DefaultBandwidthLimit if it does not set one, or 0 if it is not limited
+func BandwidthLimit(art *wfv1.Artifact) (int64, error) {
+	if art.BandwidthLimit == "" {
+		return DefaultBandwidthLimit, nil
+	}
+	return ParseBandwidthLimit(art.BandwidthLimit)
+}
+
+// Throttle limits the rate of the bytes its readers and writers transfer, which it shares between them, e.g. the
+// concurrent uploads of the files of a directory. The bytes are spread evenly over time, in chunks of at most a tenth
+// of a second of them, rather than allowed in bursts, and the time a throttle is idle is not saved up.
+type Throttle struct {
+	bytesPerSecond int64
+	chunk          int
+	lock           sync.Mutex
+	// next is when the bytes allowed so far have been transferred at the limit
+	next time.Time
+}
+
+// NewThrottle returns a throttle of bytesPerSecond, or nil, which does not throttle, if it is not positive
+func NewThrottle(bytesPerSecond int64) *Throttle {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	chunk := bytesPerSecond / 10
+	if chunk < 1 {
+		chunk = 1
+	}
+	if chunk > maxThrottleChunk {
+		chunk = maxThrottleChunk
+	}
+	return &Throttle{bytesPerSecond: bytesPerSecond, chunk: int(chunk)}
+}
+
+// Wait waits until n more bytes may be transferred, or ctx is done, when it returns its error
+func (t *Throttle) Wait(ctx context.Context, n int) error {
+	if t == nil || n <= 0 {
+		return nil
+	}
+	t.lock.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	t.next = t.next.Add(time.Duration(n) * time.Second / time.Duration(t.bytesPerSecond))
+	delay := t.next.Sub(now)
+	t.lock.Unlock()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Reader returns a reader of r which reads at most the limit of the throttle, until ctx is done
+func (t *Throttle) Reader(ctx context.Context, r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+	return &throttledReader{ctx: ctx, r: r, throttle: t}
+}
+
+// Writer returns a writer to w which writes at most the limit of the throttle, until ctx is done
+func (t *Throttle) Writer(ctx context.Context, w io.Writer) io.Writer {
+	if t == nil {
+		return w
+	}
+	return &throttledWriter{ctx: ctx, w: w, throttle: t}
+}
+
+type throttledReader struct {
+	ctx      context.Context
+	r        io.Reader
+	throttle *Throttle
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if len(p) > r.throttle.chunk {
+		p = p[:r.throttle.chunk]
+	}
+	n, err := r.r.Read(p)
+	if waitErr := r.throttle.Wait(r.ctx, n); waitErr != nil {
+		return n, waitErr
+	}
+	return n, err
+}
+
+type throttledWriter struct {
+	ctx      context.Context
+	w        io.Writer
+	throttle *Throttle
+}
+
+func (w *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > w.throttle.chunk {
+			chunk = chunk[:w.throttle.chunk]
+		}
+		if err := w.throttle.Wait(w.ctx, len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := w.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// throttledBody is the body of a request or response read through a throttle, which closes the body
+type throttledBody struct {
+	io.Reader
+	io.Closer
+}
+
+// NewThrottledTransport returns a RoundTripper which sends requests with base, reading the bodies of the requests and
+// their responses through the throttle, until the contexts of the requests are done. It is base if throttle is nil.
+func NewThrottledTransport(base http.RoundTripper, throttle *Throttle) http.RoundTripper {
+	if throttle == nil {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return throttledTransport{base: base, throttle: throttle}
+}
+
+type throttledTransport struct {
+	base     http.RoundTripper
+	throttle *Throttle
+}
+
+func (t throttledTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Body != nil && r.Body != http.NoBody {
+		r = r.Clone(r.Context())
+		r.Body = throttledBody{Reader: t.throttle.Reader(r.Context(), r.Body), Closer: r.Body}
+	}
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = throttledBody{Reader: t.throttle.Reader(r.Context(), resp.Body), Closer: resp.Body}
+	return resp, nil
+}
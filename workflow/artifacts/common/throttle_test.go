@@ -0,0 +1,152 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+func TestBandwidthLimit(t *testing.T) {
+	limit, err := BandwidthLimit(&wfv1.Artifact{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), limit)
+	limit, err = BandwidthLimit(&wfv1.Artifact{BandwidthLimit: "10Mi"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10*1024*1024), limit)
+	DefaultBandwidthLimit = 1024
+	defer func() { DefaultBandwidthLimit = 0 }()
+	limit, err = BandwidthLimit(&wfv1.Artifact{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1024), limit)
+	for _, invalid := range []string{"fast", "0"} {
+		assert.Error(t, ValidateBandwidthLimit("my-art", &wfv1.Artifact{BandwidthLimit: invalid}), invalid)
+	}
+	assert.NoError(t, ValidateBandwidthLimit("my-art", &wfv1.Artifact{BandwidthLimit: "1Ki"}))
+}
+
+func TestThrottle(t *testing.T) {
+	ctx := context.Background()
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	t.Run("Reader", func(t *testing.T) {
+		start := time.Now()
+		read, err := ioutil.ReadAll(NewThrottle(2000).Reader(ctx, bytes.NewReader(data)))
+		elapsed := time.Since(start)
+		assert.NoError(t, err)
+		assert.Equal(t, data, read)
+		// 1000 bytes at 2000 bytes per second
+		assert.GreaterOrEqual(t, int64(elapsed), int64(450*time.Millisecond))
+		assert.Less(t, int64(elapsed), int64(2*time.Second))
+	})
+	t.Run("Writer", func(t *testing.T) {
+		var written bytes.Buffer
+		start := time.Now()
+		n, err := NewThrottle(2000).Writer(ctx, &written).Write(data)
+		assert.NoError(t, err)
+		assert.Equal(t, len(data), n)
+		assert.GreaterOrEqual(t, int64(time.Since(start)), int64(450*time.Millisecond))
+		assert.Equal(t, data, written.Bytes())
+	})
+	t.Run("Shared", func(t *testing.T) {
+		// the readers of a throttle share its limit
+		throttle := NewThrottle(4000)
+		start := time.Now()
+		var wg sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _ = io.Copy(ioutil.Discard, throttle.Reader(ctx, bytes.NewReader(data)))
+			}()
+		}
+		wg.Wait()
+		assert.GreaterOrEqual(t, int64(time.Since(start)), int64(450*time.Millisecond))
+	})
+	t.Run("Smooth", func(t *testing.T) {
+		// no read is of more than a tenth of a second of bytes, so a reader is not throttled in bursts
+		r := NewThrottle(2000).Reader(ctx, bytes.NewReader(data))
+		p := make([]byte, len(data))
+		n, err := r.Read(p)
+		assert.NoError(t, err)
+		assert.Equal(t, 200, n)
+	})
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		time.AfterFunc(50*time.Millisecond, cancel)
+		start := time.Now()
+		_, err := io.Copy(ioutil.Discard, NewThrottle(10).Reader(ctx, bytes.NewReader(data)))
+		assert.Equal(t, context.Canceled, err)
+		assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	})
+	t.Run("Unlimited", func(t *testing.T) {
+		var throttle *Throttle
+		r := bytes.NewReader(data)
+		assert.Equal(t, r, throttle.Reader(ctx, r))
+		assert.NoError(t, throttle.Wait(ctx, len(data)))
+		assert.Nil(t, NewThrottle(0))
+	})
+}
+
+func TestNewThrottledTransport(t *testing.T) {
+	data := strings.Repeat("0123456789", 100)
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received = string(body)
+		_, _ = w.Write([]byte(data))
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: NewThrottledTransport(nil, NewThrottle(4000))}
+	t.Run("Response", func(t *testing.T) {
+		start := time.Now()
+		resp, err := client.Get(server.URL)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, data, string(body))
+		assert.GreaterOrEqual(t, int64(time.Since(start)), int64(200*time.Millisecond))
+	})
+	t.Run("Request", func(t *testing.T) {
+		start := time.Now()
+		resp, err := client.Post(server.URL, "text/plain", strings.NewReader(data+data))
+		if !assert.NoError(t, err) {
+			return
+		}
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+		assert.Equal(t, data+data, received)
+		// the request and the response are 3000 bytes at 4000 bytes per second
+		assert.GreaterOrEqual(t, int64(time.Since(start)), int64(700*time.Millisecond))
+	})
+	t.Run("Cancelled", func(t *testing.T) {
+		client := &http.Client{Transport: NewThrottledTransport(nil, NewThrottle(10))}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		start := time.Now()
+		resp, err := client.Do(req)
+		if assert.NoError(t, err) {
+			_, err = io.Copy(ioutil.Discard, resp.Body)
+			_ = resp.Body.Close()
+			assert.Error(t, err)
+		}
+		assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	})
+	assert.Equal(t, http.DefaultTransport, NewThrottledTransport(http.DefaultTransport, nil))
+}
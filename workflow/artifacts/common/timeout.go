@@ -0,0 +1,35 @@
+package common
+
+import (
+	"time"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+// DefaultTimeout is the timeout of loading or saving an artifact which does not set one, so that a hung
+// artifact server cannot block a step indefinitely
+var DefaultTimeout = time.Hour
+
+// ValidateTimeout validates the timeout of an artifact
+func ValidateTimeout(errPrefix string, art *wfv1.Artifact) error {
+	if _, err := Timeout(art); err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s.timeout: %v", errPrefix, err)
+	}
+	return nil
+}
+
+// Timeout returns the timeout of loading or saving the artifact, which covers every object of a directory
+func Timeout(art *wfv1.Artifact) (time.Duration, error) {
+	if art.Timeout == "" {
+		return DefaultTimeout, nil
+	}
+	timeout, err := time.ParseDuration(art.Timeout)
+	if err != nil {
+		return 0, err
+	}
+	if timeout <= 0 {
+		return 0, errors.Errorf(errors.CodeBadRequest, "%q must be positive", art.Timeout)
+	}
+	return timeout, nil
+}
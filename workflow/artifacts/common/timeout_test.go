@@ -0,0 +1,23 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+func TestTimeout(t *testing.T) {
+	timeout, err := Timeout(&wfv1.Artifact{})
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultTimeout, timeout)
+	timeout, err = Timeout(&wfv1.Artifact{Timeout: "30m"})
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Minute, timeout)
+	for _, invalid := range []string{"soon", "0s", "-1m"} {
+		assert.Error(t, ValidateTimeout("my-art", &wfv1.Artifact{Timeout: invalid}), invalid)
+	}
+	assert.NoError(t, ValidateTimeout("my-art", &wfv1.Artifact{Timeout: "1h"}))
+}
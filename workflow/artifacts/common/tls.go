@@ -0,0 +1,19 @@
+package common
+
+import (
+	"crypto/x509"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+)
+
+// NewCertPool returns a pool of the system CA certificates and those of the PEM encoded bundle
+func NewCertPool(caBundle string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM([]byte(caBundle)) {
+		return nil, errors.Errorf(errors.CodeBadRequest, "no PEM encoded CA certificates found")
+	}
+	return pool, nil
+}
@@ -0,0 +1,26 @@
+package common
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCertPool(t *testing.T) {
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+	t.Run("Bundle", func(t *testing.T) {
+		pool, err := NewCertPool(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})))
+		if assert.NoError(t, err) {
+			_, err := server.Certificate().Verify(x509.VerifyOptions{Roots: pool})
+			assert.NoError(t, err)
+		}
+	})
+	t.Run("NoCertificates", func(t *testing.T) {
+		_, err := NewCertPool("not a certificate")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,64 @@
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+)
+
+// NewTransport returns a copy of http.DefaultTransport which verifies TLS connections with rootCAs, or the system
+// CA certificates if rootCAs is nil, and sends requests through the proxy at proxyURL, or if proxyURL is nil, the
+// proxy of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+func NewTransport(rootCAs *x509.CertPool, proxyURL *url.URL) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs}
+	transport.Proxy = http.ProxyFromEnvironment
+	if proxyURL != nil {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return transport
+}
+
+// Transporting is embedded in drivers whose clients send their requests with the RoundTripper NewDriver sets, which
+// wraps the transport they would otherwise send them with. Drivers used without one send them with their transport.
+type Transporting struct {
+	wrap func(base http.RoundTripper) http.RoundTripper
+}
+
+// SetRoundTripper sets the function which returns the RoundTripper of a client of the driver, given its transport
+func (t *Transporting) SetRoundTripper(wrap func(base http.RoundTripper) http.RoundTripper) {
+	t.wrap = wrap
+}
+
+// HasRoundTripper returns whether a RoundTripper was set
+func (t *Transporting) HasRoundTripper() bool {
+	return t != nil && t.wrap != nil
+}
+
+// RoundTripper returns the RoundTripper of a client of the driver whose transport is base, or http.DefaultTransport
+// if it is nil. It is base unless a RoundTripper was set.
+func (t *Transporting) RoundTripper(base http.RoundTripper) http.RoundTripper {
+	if !t.HasRoundTripper() {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return t.wrap(base)
+}
+
+// ParseProxyURL parses the proxy URL of an artifact location, which is nil if it is not set
+func ParseProxyURL(proxyURL string) (*url.URL, error) {
+	if proxyURL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(proxyURL)
+	// the URL is not in the message, because it may contain credentials
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, errors.Errorf(errors.CodeBadRequest, "proxy URL must be an absolute URL, e.g. http://proxy.example.com:3128")
+	}
+	return u, nil
+}
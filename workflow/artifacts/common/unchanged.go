@@ -0,0 +1,114 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+// ContentSHA256MetadataKey is the key of the user metadata of an object which records the hex encoded sha256 of the
+// file it was saved from, which saving an artifact with the skip-if-unchanged overwrite policy compares
+const ContentSHA256MetadataKey = "argo-content-sha256"
+
+// SkipsUnchanged returns whether the artifact is not saved if its destination has the same content
+func SkipsUnchanged(overwrite wfv1.ArtifactOverwrite) bool {
+	return overwrite == wfv1.ArtifactOverwriteSkipIfUnchanged
+}
+
+// ManifestFormat returns the format of the manifest saved with an output directory artifact, or "" if none is. An
+// artifact which skips saving unchanged directories, or whose saves are idempotent, needs one to compare, which is text
+// unless it has a format.
+func ManifestFormat(art *wfv1.Artifact) wfv1.ArtifactManifestFormat {
+	if art.Manifest == "" && (SkipsUnchanged(art.Overwrite) || IdempotencyKey(art) != "") {
+		return wfv1.ArtifactManifestFormatText
+	}
+	return art.Manifest
+}
+
+// NewUnchangedResult returns the result of an artifact which was not saved to location, as it was unchanged
+func NewUnchangedResult(location string) *SaveResult {
+	result := NewSaveResult(location, nil)
+	result.Unchanged = true
+	return result
+}
+
+// WithContentSHA256 returns a copy of the user metadata of the object of the file at path, with the sha256 of the file
+// recorded under ContentSHA256MetadataKey
+func WithContentSHA256(metadata map[string]string, path string) (map[string]string, error) {
+	digest, err := FileChecksum(wfv1.ChecksumAlgorithmSHA256, path)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]string{ContentSHA256MetadataKey: digest}
+	for k, v := range metadata {
+		result[k] = v
+	}
+	return result, nil
+}
+
+// FileUnchanged returns whether the file at path has the content of an object, which is the case if its sha256 is the
+// digest recorded in the metadata of the object, under ContentSHA256MetadataKey, or if none is, if its md5 is contentMD5,
+// e.g. the ETag of the object if that is its md5. Neither being known, e.g. they are empty, the file is changed.
+func FileUnchanged(path, recordedSHA256, contentMD5 string) (bool, error) {
+	if recordedSHA256 != "" {
+		digest, err := FileChecksum(wfv1.ChecksumAlgorithmSHA256, path)
+		return err == nil && strings.EqualFold(digest, recordedSHA256), err
+	}
+	if contentMD5 != "" {
+		digest, err := FileChecksum(wfv1.ChecksumAlgorithmMD5, path)
+		return err == nil && strings.EqualFold(digest, contentMD5), err
+	}
+	return false, nil
+}
+
+// DirectoryUnchanged returns whether the files of the directory dir which match the filter are those of the manifest
+// saved with its destination, as compared by the hashes of the manifests. A destination without a manifest, which is
+// nil, is changed.
+func DirectoryUnchanged(dir string, filter FileFilter, saved *Manifest) (bool, error) {
+	if saved == nil {
+		return false, nil
+	}
+	local, err := NewDirectoryManifest(dir, filter)
+	if err != nil {
+		return false, err
+	}
+	localHash, err := local.Hash()
+	if err != nil {
+		return false, err
+	}
+	savedHash, err := saved.Hash()
+	return err == nil && localHash == savedHash, err
+}
+
+// NewDirectoryManifest returns the manifest of the files of the directory dir which match the filter, which are those
+// saving the directory saves
+func NewDirectoryManifest(dir string, filter FileFilter) (*Manifest, error) {
+	var objects []SavedObject
+	err := filter.Walk(dir, func(f WalkedFile) error {
+		objects = append(objects, SavedObject{Path: f.Path, LinkTarget: f.LinkTarget})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewManifest(dir, objects)
+}
+
+// Hash returns the hex encoded sha256 of the manifest in the text format, in the order of its paths, which is the same
+// for manifests of the same files in either format
+func (m *Manifest) Hash() (string, error) {
+	files := &Manifest{Files: make([]ManifestFile, len(m.Files))}
+	for i, f := range m.Files {
+		files.Files[i] = ManifestFile{Path: f.Path, Size: f.Size, SHA256: strings.ToLower(f.SHA256)}
+	}
+	sort.Slice(files.Files, func(i, j int) bool { return files.Files[i].Path < files.Files[j].Path })
+	data, err := files.Marshal(wfv1.ArtifactManifestFormatText)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(data)
+	return hex.EncodeToString(digest[:]), nil
+}
@@ -0,0 +1,105 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+func TestFileUnchanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unchanged")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "hello")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("hello"), 0600))
+	helloMD5 := "5d41402abc4b2a76b9719d911017c592"
+	for name, test := range map[string]struct {
+		recordedSHA256, contentMD5 string
+		unchanged                  bool
+	}{
+		"SHA256":        {recordedSHA256: helloSHA256, unchanged: true},
+		"SHA256Upper":   {recordedSHA256: "2CF24DBA5FB0A30E26E83B2AC5B9E29E1B161E5C1FA7425E73043362938B9824", unchanged: true},
+		"SHA256Changed": {recordedSHA256: worldSHA256, contentMD5: helloMD5},
+		"MD5":           {contentMD5: helloMD5, unchanged: true},
+		"MD5Changed":    {contentMD5: "7d793037a0760186574b0282f2f435e7"},
+		"Unknown":       {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			unchanged, err := FileUnchanged(path, test.recordedSHA256, test.contentMD5)
+			assert.NoError(t, err)
+			assert.Equal(t, test.unchanged, unchanged)
+		})
+	}
+	_, err = FileUnchanged(filepath.Join(dir, "missing"), helloSHA256, "")
+	assert.Error(t, err)
+}
+
+func TestWithContentSHA256(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unchanged")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "hello")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("hello"), 0600))
+	metadata := map[string]string{ContentEncodingMetadataKey: "gzip"}
+	withDigest, err := WithContentSHA256(metadata, path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]string{ContentEncodingMetadataKey: "gzip", ContentSHA256MetadataKey: helloSHA256}, withDigest)
+		// the metadata passed is not modified
+		assert.Len(t, metadata, 1)
+	}
+	withDigest, err = WithContentSHA256(nil, path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]string{ContentSHA256MetadataKey: helloSHA256}, withDigest)
+	}
+}
+
+func TestDirectoryUnchanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unchanged")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "hello"), []byte("hello"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "world.log"), []byte("world"), 0600))
+	saved := &Manifest{Files: []ManifestFile{{Path: "sub/world.log", Size: 5, SHA256: worldSHA256}, {Path: "hello", Size: 5, SHA256: helloSHA256}}}
+
+	unchanged, err := DirectoryUnchanged(dir, FileFilter{}, saved)
+	assert.NoError(t, err)
+	assert.True(t, unchanged)
+	// only the files which are saved are compared
+	unchanged, err = DirectoryUnchanged(dir, FileFilter{Exclude: []string{"**/*.log"}}, &Manifest{Files: saved.Files[1:]})
+	assert.NoError(t, err)
+	assert.True(t, unchanged)
+	unchanged, err = DirectoryUnchanged(dir, FileFilter{}, &Manifest{Files: saved.Files[1:]})
+	assert.NoError(t, err)
+	assert.False(t, unchanged)
+	unchanged, err = DirectoryUnchanged(dir, FileFilter{}, nil)
+	assert.NoError(t, err)
+	assert.False(t, unchanged)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "hello"), []byte("HELLO"), 0600))
+	unchanged, err = DirectoryUnchanged(dir, FileFilter{}, saved)
+	assert.NoError(t, err)
+	assert.False(t, unchanged)
+}
+
+func TestManifestFormat(t *testing.T) {
+	assert.Equal(t, wfv1.ArtifactManifestFormat(""), ManifestFormat(&wfv1.Artifact{}))
+	assert.Equal(t, wfv1.ArtifactManifestFormatJSON, ManifestFormat(&wfv1.Artifact{Manifest: wfv1.ArtifactManifestFormatJSON}))
+	assert.Equal(t, wfv1.ArtifactManifestFormatText, ManifestFormat(&wfv1.Artifact{Overwrite: wfv1.ArtifactOverwriteSkipIfUnchanged}))
+	assert.Equal(t, wfv1.ArtifactManifestFormatJSON, ManifestFormat(&wfv1.Artifact{Overwrite: wfv1.ArtifactOverwriteSkipIfUnchanged, Manifest: wfv1.ArtifactManifestFormatJSON}))
+}
+
+func TestNewUnchangedResult(t *testing.T) {
+	assert.Equal(t, &SaveResult{Location: "s3://my-bucket/my-key", Objects: []SavedObject{}, Unchanged: true}, NewUnchangedResult("s3://my-bucket/my-key"))
+}
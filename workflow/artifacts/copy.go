@@ -0,0 +1,46 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+// Copy copies the artifact src, which srcDriver loads, to dst, which dstDriver saves, e.g. to promote an artifact from
+// a staging bucket to a release one. It is copied server-side if both are of the same location type, dstDriver is an
+// ArtifactCopier which copies from src, and neither has an option that only loading or saving honours. Otherwise src
+// is loaded to a temporary directory, verified against its checksum if it has one, and saved from there to dst, as
+// LoadWithProgress and SaveWithProgress do.
+func Copy(ctx context.Context, srcDriver ArtifactDriver, src *wfv1.Artifact, dstDriver ArtifactDriver, dst *wfv1.Artifact) error {
+	if copier, ok := dstDriver.(ArtifactCopier); ok && driverName(src) == driverName(dst) && copiesServerSide(src, dst) && copier.CopiesFrom(src, dst) {
+		driverLogger(dstDriver, dst).Infof("Copying artifact %s to artifact %s server-side", src.Name, dst.Name)
+		return driverError(dst, OperationCopy, copier.Copy(ctx, src, dst))
+	}
+	tmp, err := common.MkdirTemp("copy")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	path := filepath.Join(tmp, "artifact")
+	if err := LoadWithProgress(ctx, srcDriver, src, path, nil); err != nil {
+		return err
+	}
+	if err := VerifyChecksum(srcDriver, src, path); err != nil {
+		return err
+	}
+	return SaveWithProgress(ctx, dstDriver, path, dst, nil)
+}
+
+// copiesServerSide returns whether a server-side copy of src to dst would be what loading and saving it would be. It
+// would not if src loads the latest object under its key, or has a checksum, which would not be verified, nor if dst
+// filters, compresses, or only conditionally saves what is loaded, or records a manifest, content type or metadata.
+func copiesServerSide(src, dst *wfv1.Artifact) bool {
+	return src.SelectLatest == "" && src.Checksum == nil &&
+		len(dst.Include) == 0 && len(dst.Exclude) == 0 && dst.Symlinks == "" &&
+		!common.IsCompressed(dst.Compression) && !common.IsOverwriteProtected(dst.Overwrite) && !common.SkipsUnchanged(dst.Overwrite) &&
+		!dst.AtomicSave && dst.Idempotency == nil && common.ManifestFormat(dst) == "" && dst.ContentType == "" &&
+		len(dst.Metadata) == 0
+}
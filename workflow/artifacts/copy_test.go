@@ -0,0 +1,152 @@
+package executor
+
+import (
+	"context"
+	stderrors "errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/memory"
+)
+
+// copyingDriver is a memory driver which copies files server-side, if it copies from the source, and counts the
+// loads, saves and copies of artifacts
+type copyingDriver struct {
+	*memory.ArtifactDriver
+	copiesFrom           bool
+	loads, saves, copies int
+	loadedTo             string
+}
+
+func newCopyingDriver() *copyingDriver {
+	return &copyingDriver{ArtifactDriver: memory.NewArtifactDriver(), copiesFrom: true}
+}
+
+func (d *copyingDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, path string) error {
+	d.loads++
+	d.loadedTo = path
+	return d.ArtifactDriver.Load(ctx, inputArtifact, path)
+}
+
+func (d *copyingDriver) Save(ctx context.Context, path string, outputArtifact *wfv1.Artifact) error {
+	d.saves++
+	return d.ArtifactDriver.Save(ctx, path, outputArtifact)
+}
+
+func (d *copyingDriver) CopiesFrom(_, _ *wfv1.Artifact) bool {
+	return d.copiesFrom
+}
+
+func (d *copyingDriver) Copy(_ context.Context, src, dst *wfv1.Artifact) error {
+	d.copies++
+	srcURL, err := memory.URL(src)
+	if err != nil {
+		return err
+	}
+	dstURL, err := memory.URL(dst)
+	if err != nil {
+		return err
+	}
+	data, ok := d.Content(srcURL)
+	if !ok {
+		return common.NewNotFoundError(stderrors.New("no artifact stored at " + srcURL))
+	}
+	d.Seed(dstURL, data)
+	return nil
+}
+
+func TestCopy(t *testing.T) {
+	s3Artifact := func(key string) *wfv1.Artifact {
+		return &wfv1.Artifact{Name: key, ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: key}}}
+	}
+	gcsArtifact := func(key string) *wfv1.Artifact {
+		return &wfv1.Artifact{Name: key, ArtifactLocation: wfv1.ArtifactLocation{GCS: &wfv1.GCSArtifact{Key: key}}}
+	}
+	content := func(driver *copyingDriver, url string) string {
+		data, _ := driver.Content(url)
+		return string(data)
+	}
+
+	t.Run("SameDriver", func(t *testing.T) {
+		driver := newCopyingDriver()
+		driver.Seed("memory://staging/my-file", []byte("my-data"))
+		assert.NoError(t, Copy(context.Background(), driver, s3Artifact("staging/my-file"), driver, s3Artifact("release/my-file")))
+		assert.Equal(t, 1, driver.copies)
+		// the content did not pass through the local disk
+		assert.Zero(t, driver.loads)
+		assert.Zero(t, driver.saves)
+		assert.Equal(t, "my-data", content(driver, "memory://release/my-file"))
+	})
+	t.Run("DifferentDrivers", func(t *testing.T) {
+		src, dst := newCopyingDriver(), newCopyingDriver()
+		src.Seed("memory://staging/my-dir/a", []byte("a"))
+		src.Seed("memory://staging/my-dir/sub/b", []byte("bb"))
+		assert.NoError(t, Copy(context.Background(), src, s3Artifact("staging/my-dir"), dst, gcsArtifact("release/my-dir")))
+		assert.Zero(t, src.copies+dst.copies)
+		assert.Equal(t, 1, src.loads)
+		assert.Equal(t, 1, dst.saves)
+		assert.Equal(t, []string{"memory://release/my-dir/a", "memory://release/my-dir/sub/b"}, dst.URLs())
+		assert.Equal(t, "bb", content(dst, "memory://release/my-dir/sub/b"))
+	})
+	t.Run("TempDir", func(t *testing.T) {
+		tmp, err := ioutil.TempDir("", "temp-dir")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer func() { _ = os.RemoveAll(tmp) }()
+		common.DefaultTempDir = tmp
+		defer func() { common.DefaultTempDir = "" }()
+		src, dst := newCopyingDriver(), newCopyingDriver()
+		src.Seed("memory://staging/my-file", []byte("my-data"))
+		assert.NoError(t, Copy(context.Background(), src, s3Artifact("staging/my-file"), dst, gcsArtifact("release/my-file")))
+		// the source is staged in the temp dir, and removed once it is saved
+		assert.True(t, strings.HasPrefix(src.loadedTo, tmp+string(os.PathSeparator)), src.loadedTo)
+		files, err := ioutil.ReadDir(tmp)
+		assert.NoError(t, err)
+		assert.Empty(t, files)
+	})
+	t.Run("NotCopiedFrom", func(t *testing.T) {
+		driver := newCopyingDriver()
+		driver.copiesFrom = false
+		driver.Seed("memory://staging/my-file", []byte("my-data"))
+		assert.NoError(t, Copy(context.Background(), driver, s3Artifact("staging/my-file"), driver, s3Artifact("release/my-file")))
+		assert.Zero(t, driver.copies)
+		assert.Equal(t, 1, driver.loads)
+		assert.Equal(t, 1, driver.saves)
+		assert.Equal(t, "my-data", content(driver, "memory://release/my-file"))
+	})
+	t.Run("Compressed", func(t *testing.T) {
+		driver := newCopyingDriver()
+		driver.Seed("memory://staging/my-file", []byte("my-data"))
+		dst := s3Artifact("release/my-file")
+		dst.Compression = wfv1.ArtifactCompressionGzip
+		assert.NoError(t, Copy(context.Background(), driver, s3Artifact("staging/my-file"), driver, dst))
+		// the destination is compressed as it is saved, which a copy would not do
+		assert.Zero(t, driver.copies)
+		encoding, err := driver.ContentEncoding(dst)
+		assert.NoError(t, err)
+		assert.Equal(t, "gzip", encoding)
+	})
+	t.Run("Checksum", func(t *testing.T) {
+		driver := newCopyingDriver()
+		driver.Seed("memory://staging/my-file", []byte("my-data"))
+		src := s3Artifact("staging/my-file")
+		src.Checksum = &wfv1.ArtifactChecksum{Algorithm: wfv1.ChecksumAlgorithmMD5, Value: "5d41402abc4b2a76b9719d911017c592"}
+		// the checksum is verified once the source is loaded, so the destination is not saved if it does not match
+		assert.Error(t, Copy(context.Background(), driver, src, driver, s3Artifact("release/my-file")))
+		assert.Zero(t, driver.copies)
+		assert.Zero(t, driver.saves)
+	})
+	t.Run("CopyFailed", func(t *testing.T) {
+		driver := newCopyingDriver()
+		err := Copy(context.Background(), driver, s3Artifact("staging/missing"), driver, s3Artifact("release/missing"))
+		assert.EqualError(t, err, "s3 copy to release/missing: no artifact stored at memory://staging/missing")
+		assert.True(t, stderrors.Is(err, common.ErrArtifactNotFound))
+	})
+}
@@ -0,0 +1,103 @@
+package devnull
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+// location is the location of the result of a save
+const location = "devnull://"
+
+// ArtifactDriver is the artifact driver of DevNull artifacts, which reads the file, or every file under the directory,
+// of a saved artifact, and discards what it reads, so that saving is only bound by reading the files, e.g. to
+// benchmark saving
+type ArtifactDriver struct {
+	common.Logging
+}
+
+// Load is unsupported for DevNull artifacts, which have nothing saved
+func (d *ArtifactDriver) Load(context.Context, *wfv1.Artifact, string) error {
+	return errors.Errorf(errors.CodeBadRequest, "DevNull input artifacts unsupported")
+}
+
+// Save discards the file, or every file under the directory
+func (d *ArtifactDriver) Save(ctx context.Context, path string, outputArtifact *wfv1.Artifact) error {
+	_, err := d.SaveWithResult(ctx, path, outputArtifact, "", nil)
+	return err
+}
+
+// SaveWithProgress discards the artifact as Save does, reporting progress against the size of what is discarded
+func (d *ArtifactDriver) SaveWithProgress(ctx context.Context, path string, outputArtifact *wfv1.Artifact, progress common.ProgressFunc) error {
+	_, err := d.SaveWithResult(ctx, path, outputArtifact, "", progress)
+	return err
+}
+
+// SaveWithResult discards the artifact as Save does, returning the number of bytes discarded as its size, and the
+// files as its objects, keyed by their paths under the directory
+func (d *ArtifactDriver) SaveWithResult(ctx context.Context, path string, outputArtifact *wfv1.Artifact, _ string, progress common.ProgressFunc) (*common.SaveResult, error) {
+	d.Log().Infof("DevNull Save path: %s", path)
+	size, err := common.PathSize(path)
+	if err != nil {
+		return nil, err
+	}
+	counter := common.NewProgressCounter(size, progress)
+	var objects []common.SavedObject
+	err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		key, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+		if key == "." {
+			key = filepath.Base(p)
+		}
+		n, err := discard(p, counter)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, common.SavedObject{Key: filepath.ToSlash(key), Path: p, Size: n})
+		return nil
+	})
+	counter.Done()
+	if err != nil {
+		return nil, err
+	}
+	result := common.NewSaveResult(location, objects)
+	d.Log().Infof("DevNull discarded %d bytes of %d file(s)", result.Size, len(result.Objects))
+	return result, nil
+}
+
+// discard reads the file, discarding what it reads, and returns the number of bytes it read
+func discard(path string, counter *common.ProgressCounter) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = f.Close() }()
+	return io.Copy(ioutil.Discard, counter.Reader(f))
+}
+
+// Delete deletes nothing, as nothing is saved
+func (d *ArtifactDriver) Delete(*wfv1.Artifact) error {
+	return nil
+}
+
+// Capabilities returns the optional operations on DevNull artifacts the driver supports
+func (d *ArtifactDriver) Capabilities() common.DriverCapabilities {
+	return common.DriverCapabilities{
+		Delete:     true,
+		SaveResult: true,
+	}
+}
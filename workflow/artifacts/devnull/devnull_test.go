@@ -0,0 +1,76 @@
+package devnull
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+func TestArtifactDriver_Save(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "devnull")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	dir := filepath.Join(tmp, "dir")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("my-data"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("my-other-data"), 0600))
+	art := &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{DevNull: &wfv1.DevNullArtifact{}}}
+	driver := &ArtifactDriver{}
+	t.Run("File", func(t *testing.T) {
+		var transferred int64
+		result, err := driver.SaveWithResult(context.Background(), filepath.Join(dir, "a.txt"), art, "", func(bytesTransferred, totalBytes int64) {
+			assert.Equal(t, int64(7), totalBytes)
+			transferred = bytesTransferred
+		})
+		if assert.NoError(t, err) {
+			assert.Equal(t, &common.SaveResult{
+				Location: "devnull://",
+				Objects:  []common.SavedObject{{Key: "a.txt", Path: filepath.Join(dir, "a.txt"), Size: 7}},
+				Size:     7,
+			}, result)
+			assert.Equal(t, int64(7), transferred)
+		}
+	})
+	t.Run("Directory", func(t *testing.T) {
+		result, err := driver.SaveWithResult(context.Background(), dir, art, "", nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []common.SavedObject{
+				{Key: "a.txt", Path: filepath.Join(dir, "a.txt"), Size: 7},
+				{Key: "sub/b.txt", Path: filepath.Join(dir, "sub", "b.txt"), Size: 13},
+			}, result.Objects)
+			assert.Equal(t, int64(20), result.Size)
+		}
+		// the files are only read
+		data, err := ioutil.ReadFile(filepath.Join(dir, "a.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, "my-data", string(data))
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		assert.Error(t, driver.Save(context.Background(), filepath.Join(tmp, "not-found"), art))
+	})
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.Equal(t, context.Canceled, driver.Save(ctx, dir, art))
+	})
+}
+
+func TestArtifactDriver_Load(t *testing.T) {
+	driver := &ArtifactDriver{}
+	path := filepath.Join(os.TempDir(), "devnull-load")
+	err := driver.Load(context.Background(), &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{DevNull: &wfv1.DevNullArtifact{}}}, path)
+	assert.EqualError(t, err, "DevNull input artifacts unsupported")
+	assert.True(t, errors.IsCode(errors.CodeBadRequest, err))
+	assert.NoFileExists(t, path)
+	assert.NoError(t, driver.Delete(&wfv1.Artifact{}))
+}
@@ -0,0 +1,650 @@
+package executor
+
+import (
+	"context"
+	"crypto/x509"
+
+	apiv1 "k8s.io/api/core/v1"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/adls"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/artifactory"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/b2"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/devnull"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/dropbox"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/filesystem"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/ftp"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/gcs"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/gdrive"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/git"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/hdfs"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/http"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/huggingface"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/ipfs"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/oci"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/oss"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/raw"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/rclone"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/resource"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/s3"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/sftp"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/swift"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/webdav"
+)
+
+// Names of the built-in drivers, which match the artifact location they handle
+const (
+	DriverS3          = "s3"
+	DriverHTTP        = "http"
+	DriverGit         = "git"
+	DriverArtifactory = "artifactory"
+	DriverHDFS        = "hdfs"
+	DriverRaw         = "raw"
+	DriverOSS         = "oss"
+	DriverGCS         = "gcs"
+	DriverSFTP        = "sftp"
+	DriverGDrive      = "gdrive"
+	DriverWebDAV      = "webdav"
+	DriverFTP         = "ftp"
+	DriverB2          = "b2"
+	DriverSwift       = "swift"
+	DriverRclone      = "rclone"
+	DriverDropbox     = "dropbox"
+	DriverIPFS        = "ipfs"
+	DriverADLS        = "adls"
+	DriverHuggingFace = "huggingface"
+	DriverDevNull     = "devnull"
+	DriverFilesystem  = "filesystem"
+	DriverOCI         = "oci"
+)
+
+func init() {
+	RegisterDriver(DriverS3, newS3Driver)
+	RegisterDriver(DriverHTTP, newHTTPDriver)
+	RegisterDriver(DriverGit, newGitDriver)
+	RegisterDriver(DriverArtifactory, newArtifactoryDriver)
+	RegisterDriver(DriverHDFS, newHDFSDriver)
+	RegisterDriver(DriverRaw, newRawDriver)
+	RegisterDriver(DriverOSS, newOSSDriver)
+	RegisterDriver(DriverGCS, newGCSDriver)
+	RegisterDriver(DriverSFTP, newSFTPDriver)
+	RegisterDriver(DriverGDrive, newGDriveDriver)
+	RegisterDriver(DriverWebDAV, newWebDAVDriver)
+	RegisterDriver(DriverFTP, newFTPDriver)
+	RegisterDriver(DriverB2, newB2Driver)
+	RegisterDriver(DriverSwift, newSwiftDriver)
+	RegisterDriver(DriverRclone, newRcloneDriver)
+	RegisterDriver(DriverDropbox, newDropboxDriver)
+	RegisterDriver(DriverIPFS, newIPFSDriver)
+	RegisterDriver(DriverADLS, newADLSDriver)
+	RegisterDriver(DriverHuggingFace, newHuggingFaceDriver)
+	RegisterDriver(DriverDevNull, newDevNullDriver)
+	RegisterDriver(DriverFilesystem, newFilesystemDriver)
+	RegisterDriver(DriverOCI, newOCIDriver)
+}
+
+func newS3Driver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	var accessKey string
+	var secretKey string
+
+	if art.S3.AccessKeySecret != nil && art.S3.AccessKeySecret.Name != "" {
+		accessKeyBytes, err := ri.GetSecret(ctx, art.S3.AccessKeySecret.Name, art.S3.AccessKeySecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		accessKey = accessKeyBytes
+		secretKeyBytes, err := ri.GetSecret(ctx, art.S3.SecretKeySecret.Name, art.S3.SecretKeySecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		secretKey = secretKeyBytes
+	}
+
+	driver := s3.S3ArtifactDriver{
+		Endpoint:              art.S3.Endpoint,
+		AccessKey:             accessKey,
+		SecretKey:             secretKey,
+		Secure:                art.S3.Insecure == nil || !*art.S3.Insecure,
+		Region:                art.S3.Region,
+		RoleARN:               art.S3.RoleARN,
+		UseSDKCreds:           art.S3.UseSDKCreds,
+		PathStyle:             art.S3.PathStyle,
+		RequesterPays:         art.S3.RequesterPays,
+		UseAccelerateEndpoint: art.S3.UseAccelerateEndpoint,
+		CredentialProviders:   art.S3.CredentialProviders,
+		Anonymous:             art.S3.Anonymous,
+		Bucket:                art.S3.Bucket,
+	}
+	if art.S3.RoleARN != "" {
+		driver.RoleExternalID = art.S3.RoleExternalID
+		driver.RoleChain = art.S3.RoleChain
+		driver.RoleSessionName = art.S3.RoleSessionName
+		duration, err := s3.ParseRoleSessionDuration(art.S3.RoleSessionDuration)
+		if err != nil {
+			return nil, err
+		}
+		driver.RoleSessionDuration = duration
+	}
+	rootCAs, err := newCertPool(ctx, ri, art.S3.CASecret)
+	if err != nil {
+		return nil, err
+	}
+	driver.RootCAs = rootCAs
+	driver.ProxyURL, err = common.ParseProxyURL(art.S3.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+	if art.S3.UploadParallelism != nil {
+		driver.UploadParallelism = int(*art.S3.UploadParallelism)
+	}
+	driver.PartSize, err = s3.ParsePartSize(art.S3.PartSize)
+	if err != nil {
+		return nil, err
+	}
+	if art.S3.PartConcurrency != nil {
+		driver.PartConcurrency = int(*art.S3.PartConcurrency)
+	}
+	driver.Retry, err = s3.NewRetryBackoff(art.S3.Retry)
+	if err != nil {
+		return nil, err
+	}
+	if sse := art.S3.ServerSideEncryption; sse != nil {
+		driver.SSEAlgorithm = sse.SSEAlgorithm
+		driver.KMSKeyID = sse.KMSKeyID
+	}
+	return &driver, nil
+}
+
+func newHTTPDriver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	rootCAs, err := newCertPool(ctx, ri, art.HTTP.CASecret)
+	if err != nil {
+		return nil, err
+	}
+	proxyURL, err := common.ParseProxyURL(art.HTTP.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := common.Timeout(art)
+	if err != nil {
+		return nil, err
+	}
+	driver := &http.HTTPArtifactDriver{Context: ctx, RootCAs: rootCAs, ProxyURL: proxyURL, Timeout: timeout}
+	if art.HTTP.BearerTokenSecret != nil && art.HTTP.BearerTokenSecret.Name != "" {
+		driver.BearerToken, err = ri.GetSecret(ctx, art.HTTP.BearerTokenSecret.Name, art.HTTP.BearerTokenSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if art.HTTP.UsernameSecret != nil && art.HTTP.UsernameSecret.Name != "" {
+		driver.Username, err = ri.GetSecret(ctx, art.HTTP.UsernameSecret.Name, art.HTTP.UsernameSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if art.HTTP.PasswordSecret != nil && art.HTTP.PasswordSecret.Name != "" {
+		driver.Password, err = ri.GetSecret(ctx, art.HTTP.PasswordSecret.Name, art.HTTP.PasswordSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return driver, nil
+}
+
+// newCertPool returns the pool of the system CA certificates and those of the secret, or nil if there is no secret
+func newCertPool(ctx context.Context, ri resource.Interface, caSecret *apiv1.SecretKeySelector) (*x509.CertPool, error) {
+	if caSecret == nil || caSecret.Name == "" {
+		return nil, nil
+	}
+	caBundle, err := ri.GetSecret(ctx, caSecret.Name, caSecret.Key)
+	if err != nil {
+		return nil, err
+	}
+	return common.NewCertPool(caBundle)
+}
+
+func newGitDriver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	gitDriver := git.GitArtifactDriver{
+		InsecureIgnoreHostKey: art.Git.InsecureIgnoreHostKey,
+	}
+	if art.Git.UsernameSecret != nil {
+		usernameBytes, err := ri.GetSecret(ctx, art.Git.UsernameSecret.Name, art.Git.UsernameSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		gitDriver.Username = usernameBytes
+	}
+	if art.Git.PasswordSecret != nil {
+		passwordBytes, err := ri.GetSecret(ctx, art.Git.PasswordSecret.Name, art.Git.PasswordSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		gitDriver.Password = passwordBytes
+	}
+	if art.Git.SSHPrivateKeySecret != nil {
+		sshPrivateKeyBytes, err := ri.GetSecret(ctx, art.Git.SSHPrivateKeySecret.Name, art.Git.SSHPrivateKeySecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		gitDriver.SSHPrivateKey = sshPrivateKeyBytes
+	}
+
+	return &gitDriver, nil
+}
+
+func newArtifactoryDriver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	proxyURL, err := common.ParseProxyURL(art.Artifactory.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+	driver := artifactory.ArtifactoryArtifactDriver{
+		ProxyURL:       proxyURL,
+		VerifyChecksum: art.Artifactory.VerifyChecksum,
+		URL:            art.Artifactory.URL,
+	}
+	if art.Artifactory.UsernameSecret != nil && art.Artifactory.UsernameSecret.Name != "" {
+		driver.Username, err = ri.GetSecret(ctx, art.Artifactory.UsernameSecret.Name, art.Artifactory.UsernameSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if art.Artifactory.PasswordSecret != nil && art.Artifactory.PasswordSecret.Name != "" {
+		driver.Password, err = ri.GetSecret(ctx, art.Artifactory.PasswordSecret.Name, art.Artifactory.PasswordSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if art.Artifactory.APIKeySecret != nil && art.Artifactory.APIKeySecret.Name != "" {
+		driver.APIKey, err = ri.GetSecret(ctx, art.Artifactory.APIKeySecret.Name, art.Artifactory.APIKeySecret.Key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if art.Artifactory.AccessTokenSecret != nil && art.Artifactory.AccessTokenSecret.Name != "" {
+		driver.AccessToken, err = ri.GetSecret(ctx, art.Artifactory.AccessTokenSecret.Name, art.Artifactory.AccessTokenSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &driver, nil
+}
+
+func newHDFSDriver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	return hdfs.CreateDriver(ctx, ri, art.HDFS)
+}
+
+func newRawDriver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	return &raw.RawArtifactDriver{}, nil
+}
+
+func newOSSDriver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	var accessKey string
+	var secretKey string
+
+	var securityToken string
+
+	if art.OSS.AccessKeySecret != nil && art.OSS.AccessKeySecret.Name != "" {
+		accessKeyBytes, err := ri.GetSecret(ctx, art.OSS.AccessKeySecret.Name, art.OSS.AccessKeySecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		accessKey = string(accessKeyBytes)
+		secretKeyBytes, err := ri.GetSecret(ctx, art.OSS.SecretKeySecret.Name, art.OSS.SecretKeySecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		secretKey = string(secretKeyBytes)
+		if art.OSS.SecurityTokenSecret != nil && art.OSS.SecurityTokenSecret.Name != "" {
+			securityToken, err = ri.GetSecret(ctx, art.OSS.SecurityTokenSecret.Name, art.OSS.SecurityTokenSecret.Key)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	partSize, err := oss.ParsePartSize(art.OSS.PartSize)
+	if err != nil {
+		return nil, err
+	}
+	driver := oss.OSSArtifactDriver{
+		Endpoint:      art.OSS.Endpoint,
+		AccessKey:     accessKey,
+		SecretKey:     secretKey,
+		SecurityToken: securityToken,
+		UseSDKCreds:   art.OSS.UseSDKCreds,
+		RAMRole:       art.OSS.RAMRole,
+		PartSize:      partSize,
+		Bucket:        art.OSS.Bucket,
+	}
+	return &driver, nil
+}
+
+func newGCSDriver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	proxyURL, err := common.ParseProxyURL(art.GCS.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+	uploadChunkSize, err := gcs.ParseUploadChunkSize(art.GCS.UploadChunkSize)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err := gcs.ParseEndpoint(art.GCS.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	driver := gcs.ArtifactDriver{
+		RequesterPays:   art.GCS.RequesterPays,
+		BillingProject:  art.GCS.BillingProject,
+		ProxyURL:        proxyURL,
+		UploadChunkSize: uploadChunkSize,
+		Endpoint:        endpoint,
+		Anonymous:       art.GCS.Anonymous,
+		Bucket:          art.GCS.Bucket,
+	}
+	if art.GCS.ServiceAccountKeySecret.Name != "" {
+		serviceAccountKeyBytes, err := ri.GetSecret(ctx, art.GCS.ServiceAccountKeySecret.Name, art.GCS.ServiceAccountKeySecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		serviceAccountKey := string(serviceAccountKeyBytes)
+		driver.ServiceAccountKey = serviceAccountKey
+	}
+	// key is not set, assume it is using Workload Idendity
+	if art.GCS.EncryptionKeySecret != nil && art.GCS.EncryptionKeySecret.Name != "" {
+		encryptionKey, err := ri.GetSecret(ctx, art.GCS.EncryptionKeySecret.Name, art.GCS.EncryptionKeySecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.EncryptionKey, err = gcs.ParseEncryptionKey(encryptionKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &driver, nil
+}
+
+func newSFTPDriver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	driver := sftp.ArtifactDriver{
+		InsecureIgnoreHostKey: art.SFTP.InsecureIgnoreHostKey,
+	}
+	if art.SFTP.UsernameSecret != nil {
+		usernameBytes, err := ri.GetSecret(ctx, art.SFTP.UsernameSecret.Name, art.SFTP.UsernameSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.Username = usernameBytes
+	}
+	if art.SFTP.PasswordSecret != nil {
+		passwordBytes, err := ri.GetSecret(ctx, art.SFTP.PasswordSecret.Name, art.SFTP.PasswordSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.Password = passwordBytes
+	}
+	if art.SFTP.SSHPrivateKeySecret != nil {
+		sshPrivateKeyBytes, err := ri.GetSecret(ctx, art.SFTP.SSHPrivateKeySecret.Name, art.SFTP.SSHPrivateKeySecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.SSHPrivateKey = sshPrivateKeyBytes
+	}
+	return &driver, nil
+}
+
+func newGDriveDriver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	driver := gdrive.ArtifactDriver{}
+	if art.GDrive.ServiceAccountKeySecret != nil && art.GDrive.ServiceAccountKeySecret.Name != "" {
+		serviceAccountKey, err := ri.GetSecret(ctx, art.GDrive.ServiceAccountKeySecret.Name, art.GDrive.ServiceAccountKeySecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.ServiceAccountKey = serviceAccountKey
+	}
+	// key is not set, assume it is using Workload Identity
+	return &driver, nil
+}
+
+func newWebDAVDriver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	proxyURL, err := common.ParseProxyURL(art.WebDAV.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+	driver := webdav.ArtifactDriver{ProxyURL: proxyURL}
+	if art.WebDAV.UsernameSecret != nil {
+		usernameBytes, err := ri.GetSecret(ctx, art.WebDAV.UsernameSecret.Name, art.WebDAV.UsernameSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.Username = usernameBytes
+	}
+	if art.WebDAV.PasswordSecret != nil {
+		passwordBytes, err := ri.GetSecret(ctx, art.WebDAV.PasswordSecret.Name, art.WebDAV.PasswordSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.Password = passwordBytes
+	}
+	return &driver, nil
+}
+
+func newFTPDriver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	rootCAs, err := newCertPool(ctx, ri, art.FTP.CASecret)
+	if err != nil {
+		return nil, err
+	}
+	driver := ftp.ArtifactDriver{
+		ActiveMode:         art.FTP.ActiveMode,
+		RootCAs:            rootCAs,
+		InsecureSkipVerify: art.FTP.InsecureSkipVerify,
+	}
+	if art.FTP.UsernameSecret != nil {
+		usernameBytes, err := ri.GetSecret(ctx, art.FTP.UsernameSecret.Name, art.FTP.UsernameSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.Username = usernameBytes
+	}
+	if art.FTP.PasswordSecret != nil {
+		passwordBytes, err := ri.GetSecret(ctx, art.FTP.PasswordSecret.Name, art.FTP.PasswordSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.Password = passwordBytes
+	}
+	return &driver, nil
+}
+
+func newB2Driver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	driver := b2.ArtifactDriver{}
+	if art.B2.ApplicationKeyIDSecret != nil {
+		keyIDBytes, err := ri.GetSecret(ctx, art.B2.ApplicationKeyIDSecret.Name, art.B2.ApplicationKeyIDSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.ApplicationKeyID = keyIDBytes
+	}
+	if art.B2.ApplicationKeySecret != nil {
+		keyBytes, err := ri.GetSecret(ctx, art.B2.ApplicationKeySecret.Name, art.B2.ApplicationKeySecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.ApplicationKey = keyBytes
+	}
+	return &driver, nil
+}
+
+func newSwiftDriver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	segmentSize, err := swift.ParseSegmentSize(art.Swift.SegmentSize)
+	if err != nil {
+		return nil, err
+	}
+	driver := swift.ArtifactDriver{
+		AuthURL:           art.Swift.AuthURL,
+		Region:            art.Swift.Region,
+		UserDomainName:    art.Swift.UserDomainName,
+		ProjectDomainName: art.Swift.ProjectDomainName,
+		SegmentSize:       segmentSize,
+		SegmentContainer:  art.Swift.SegmentContainer,
+	}
+	if art.Swift.UsernameSecret != nil {
+		usernameBytes, err := ri.GetSecret(ctx, art.Swift.UsernameSecret.Name, art.Swift.UsernameSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.Username = usernameBytes
+	}
+	if art.Swift.PasswordSecret != nil {
+		passwordBytes, err := ri.GetSecret(ctx, art.Swift.PasswordSecret.Name, art.Swift.PasswordSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.Password = passwordBytes
+	}
+	if art.Swift.ProjectSecret != nil {
+		projectBytes, err := ri.GetSecret(ctx, art.Swift.ProjectSecret.Name, art.Swift.ProjectSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.ProjectName = projectBytes
+	}
+	if art.Swift.ApplicationCredentialIDSecret != nil {
+		applicationCredentialIDBytes, err := ri.GetSecret(ctx, art.Swift.ApplicationCredentialIDSecret.Name, art.Swift.ApplicationCredentialIDSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.ApplicationCredentialID = applicationCredentialIDBytes
+	}
+	if art.Swift.ApplicationCredentialSecretSecret != nil {
+		applicationCredentialSecretBytes, err := ri.GetSecret(ctx, art.Swift.ApplicationCredentialSecretSecret.Name, art.Swift.ApplicationCredentialSecretSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.ApplicationCredentialSecret = applicationCredentialSecretBytes
+	}
+	return &driver, nil
+}
+
+func newRcloneDriver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	driver := rclone.ArtifactDriver{}
+	var err error
+	if art.Rclone.ConfigSecret != nil {
+		driver.Config, err = ri.GetSecret(ctx, art.Rclone.ConfigSecret.Name, art.Rclone.ConfigSecret.Key)
+	} else if art.Rclone.ConfigConfigMap != nil {
+		driver.Config, err = ri.GetConfigMapKey(ctx, art.Rclone.ConfigConfigMap.Name, art.Rclone.ConfigConfigMap.Key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &driver, nil
+}
+
+func newDropboxDriver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	chunkSize, err := dropbox.ParseChunkSize(art.Dropbox.ChunkSize)
+	if err != nil {
+		return nil, err
+	}
+	driver := dropbox.ArtifactDriver{ChunkSize: chunkSize}
+	if art.Dropbox.AppKeySecret != nil {
+		appKeyBytes, err := ri.GetSecret(ctx, art.Dropbox.AppKeySecret.Name, art.Dropbox.AppKeySecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.AppKey = appKeyBytes
+	}
+	if art.Dropbox.AppSecretSecret != nil {
+		appSecretBytes, err := ri.GetSecret(ctx, art.Dropbox.AppSecretSecret.Name, art.Dropbox.AppSecretSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.AppSecret = appSecretBytes
+	}
+	if art.Dropbox.RefreshTokenSecret != nil {
+		refreshTokenBytes, err := ri.GetSecret(ctx, art.Dropbox.RefreshTokenSecret.Name, art.Dropbox.RefreshTokenSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.RefreshToken = refreshTokenBytes
+	}
+	return &driver, nil
+}
+
+func newIPFSDriver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	driver := ipfs.ArtifactDriver{}
+	if art.IPFS.UsernameSecret != nil {
+		usernameBytes, err := ri.GetSecret(ctx, art.IPFS.UsernameSecret.Name, art.IPFS.UsernameSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.Username = usernameBytes
+	}
+	if art.IPFS.PasswordSecret != nil {
+		passwordBytes, err := ri.GetSecret(ctx, art.IPFS.PasswordSecret.Name, art.IPFS.PasswordSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.Password = passwordBytes
+	}
+	if art.IPFS.BearerTokenSecret != nil {
+		bearerTokenBytes, err := ri.GetSecret(ctx, art.IPFS.BearerTokenSecret.Name, art.IPFS.BearerTokenSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.BearerToken = bearerTokenBytes
+	}
+	return &driver, nil
+}
+
+func newADLSDriver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	userDelegationSASDuration, err := adls.ParseUserDelegationSASDuration(art.ADLS.UserDelegationSASDuration)
+	if err != nil {
+		return nil, err
+	}
+	driver := adls.ArtifactDriver{UseWorkloadIdentity: art.ADLS.UseWorkloadIdentity, UserDelegationSASDuration: userDelegationSASDuration}
+	if art.ADLS.AccountKeySecret != nil {
+		accountKeyBytes, err := ri.GetSecret(ctx, art.ADLS.AccountKeySecret.Name, art.ADLS.AccountKeySecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.AccountKey = accountKeyBytes
+	}
+	if art.ADLS.SASTokenSecret != nil {
+		sasTokenBytes, err := ri.GetSecret(ctx, art.ADLS.SASTokenSecret.Name, art.ADLS.SASTokenSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.SASToken = sasTokenBytes
+	}
+	return &driver, nil
+}
+
+func newHuggingFaceDriver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	driver := huggingface.ArtifactDriver{}
+	if art.HuggingFace.TokenSecret != nil {
+		tokenBytes, err := ri.GetSecret(ctx, art.HuggingFace.TokenSecret.Name, art.HuggingFace.TokenSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.Token = tokenBytes
+	}
+	return &driver, nil
+}
+
+func newDevNullDriver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	return &devnull.ArtifactDriver{}, nil
+}
+
+func newFilesystemDriver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	return &filesystem.ArtifactDriver{}, nil
+}
+
+func newOCIDriver(ctx context.Context, art *wfv1.Artifact, ri resource.Interface) (ArtifactDriver, error) {
+	driver := oci.ArtifactDriver{}
+	if art.OCI.CredentialsSecret != nil {
+		dockerConfig, err := ri.GetSecret(ctx, art.OCI.CredentialsSecret.Name, art.OCI.CredentialsSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver.DockerConfig = dockerConfig
+	}
+	return &driver, nil
+}
@@ -0,0 +1,351 @@
+package dropbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const (
+	defaultAPIURL     = "https://api.dropboxapi.com"
+	defaultContentURL = "https://content.dropboxapi.com"
+	// the header the arguments of content endpoints are sent in, and the one their results are returned in
+	apiArgHeader    = "Dropbox-API-Arg"
+	apiResultHeader = "Dropbox-API-Result"
+	// the size of the blocks of files whose checksums their content hash is the checksum of
+	contentHashBlockSize = 4 * 1024 * 1024
+)
+
+// apiError is a Dropbox response with an error status. Endpoints fail with a 409 status and a summary of the error,
+// e.g. "path/not_found/...", for errors which are specific to them.
+type apiError struct {
+	Status  int
+	Summary string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Summary, e.Status)
+}
+
+// client is a client of the Dropbox API v2, authorized with a short-lived access token
+type client struct {
+	ctx         context.Context
+	httpClient  *http.Client
+	apiURL      string
+	contentURL  string
+	accessToken string
+}
+
+// authorize exchanges the refresh token of the app for an access token
+func authorize(ctx context.Context, apiURL, contentURL, appKey, appSecret, refreshToken string) (*client, error) {
+	c := &client{ctx: ctx, httpClient: &http.Client{}, apiURL: apiURL, contentURL: contentURL}
+	form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}, "client_id": {appKey}}
+	if appSecret != "" {
+		form.Set("client_secret", appSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL+"/oauth2/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var res struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := c.do(req, &res); err != nil {
+		return nil, fmt.Errorf("Dropbox refresh of the access token failed: %w", err)
+	}
+	c.accessToken = res.AccessToken
+	return c, nil
+}
+
+// do sends the request, and decodes the JSON body of the response into res, or returns the error Dropbox responded
+// with
+func (c *client) do(req *http.Request, res interface{}) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return decodeError(resp)
+	}
+	if res == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
+
+// decodeError returns the error of a response, which has a JSON body for the errors of endpoints and of OAuth, and a
+// plain text one otherwise
+func decodeError(resp *http.Response) error {
+	e := &apiError{Status: resp.StatusCode}
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var body struct {
+		Summary     string `json:"error_summary"`
+		Description string `json:"error_description"`
+	}
+	switch {
+	case json.Unmarshal(data, &body) == nil && body.Summary != "":
+		e.Summary = body.Summary
+	case body.Description != "":
+		e.Summary = body.Description
+	case len(bytes.TrimSpace(data)) > 0:
+		e.Summary = string(bytes.TrimSpace(data))
+	default:
+		e.Summary = http.StatusText(resp.StatusCode)
+	}
+	if e.Status == http.StatusConflict && strings.Contains(e.Summary, "not_found") {
+		return common.NewNotFoundError(e)
+	}
+	return e
+}
+
+// call calls an RPC endpoint, which is a POST of a JSON request, with a JSON response
+func (c *client) call(endpoint string, request, res interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, c.apiURL+"/2/"+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	req.Header.Set("Content-Type", "application/json")
+	if err := c.do(req, res); err != nil {
+		return fmt.Errorf("Dropbox %s failed: %w", endpoint, err)
+	}
+	return nil
+}
+
+// contentRequest returns a request of a content endpoint, whose arguments are sent in a header, and whose body, if
+// any, is the content uploaded
+func (c *client) contentRequest(endpoint string, arg interface{}, body io.Reader) (*http.Request, error) {
+	header, err := headerArg(arg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, c.contentURL+"/2/"+endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	req.Header.Set(apiArgHeader, header)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	return req, nil
+}
+
+// headerArg encodes the arguments of a content endpoint as JSON, with the characters which are not ASCII escaped, as
+// HTTP headers must be
+func headerArg(arg interface{}) (string, error) {
+	data, err := json.Marshal(arg)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, r := range string(data) {
+		switch {
+		case r < 0x80:
+			b.WriteRune(r)
+		case r > 0xffff:
+			r1, r2 := utf16.EncodeRune(r)
+			fmt.Fprintf(&b, `\u%04x\u%04x`, r1, r2)
+		default:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		}
+	}
+	return b.String(), nil
+}
+
+// metadata is the metadata of a file or folder
+type metadata struct {
+	Tag         string `json:".tag"`
+	Name        string `json:"name"`
+	PathLower   string `json:"path_lower"`
+	PathDisplay string `json:"path_display"`
+	Size        int64  `json:"size"`
+	ContentHash string `json:"content_hash"`
+}
+
+const (
+	tagFile   = "file"
+	tagFolder = "folder"
+)
+
+// getMetadata returns the metadata of the file or folder at the path, which cannot be the root folder
+func (c *client) getMetadata(path string) (metadata, error) {
+	var m metadata
+	err := c.call("files/get_metadata", map[string]string{"path": path}, &m)
+	return m, err
+}
+
+// listFolder lists every file and folder under the folder, recursively
+func (c *client) listFolder(path string) ([]metadata, error) {
+	var entries []metadata
+	var res struct {
+		Entries []metadata `json:"entries"`
+		Cursor  string     `json:"cursor"`
+		HasMore bool       `json:"has_more"`
+	}
+	if err := c.call("files/list_folder", map[string]interface{}{"path": path, "recursive": true}, &res); err != nil {
+		return nil, err
+	}
+	entries = append(entries, res.Entries...)
+	for res.HasMore {
+		cursor := res.Cursor
+		res.Entries = nil
+		if err := c.call("files/list_folder/continue", map[string]string{"cursor": cursor}, &res); err != nil {
+			return nil, err
+		}
+		entries = append(entries, res.Entries...)
+	}
+	return entries, nil
+}
+
+// download downloads a file, and verifies its content hash, if Dropbox returned it
+func (c *client) download(path, localPath string) error {
+	req, err := c.contentRequest("files/download", map[string]string{"path": path}, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Dropbox download of %s failed: %w", path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Dropbox download of %s failed: %w", path, decodeError(resp))
+	}
+	var m metadata
+	if result := resp.Header.Get(apiResultHeader); result != "" {
+		if err := json.Unmarshal([]byte(result), &m); err != nil {
+			return fmt.Errorf("Dropbox download of %s failed: invalid %s header: %w", path, apiResultHeader, err)
+		}
+	}
+	out, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = out.Close() }()
+	h := newContentHash()
+	if _, err := io.Copy(io.MultiWriter(out, h), resp.Body); err != nil {
+		return fmt.Errorf("Dropbox download of %s failed: %w", path, err)
+	}
+	if m.ContentHash != "" && !strings.EqualFold(m.ContentHash, h.sum()) {
+		return fmt.Errorf("Dropbox download of %s failed: content hash mismatch", path)
+	}
+	return out.Close()
+}
+
+// commitInfo is how an uploaded file is committed: it replaces any file at the path, without notifying the user
+type commitInfo struct {
+	Path string `json:"path"`
+	Mode string `json:"mode"`
+	Mute bool   `json:"mute"`
+}
+
+// uploadCursor is the position in an upload session the next chunk is uploaded at
+type uploadCursor struct {
+	SessionID string `json:"session_id"`
+	Offset    int64  `json:"offset"`
+}
+
+// send uploads the section of the file to a content endpoint
+func (c *client) send(endpoint string, arg interface{}, f *os.File, offset, size int64, res interface{}) error {
+	req, err := c.contentRequest(endpoint, arg, io.NewSectionReader(f, offset, size))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	return c.do(req, res)
+}
+
+// upload uploads a file in a single request if it is no larger than the chunk size, and otherwise in chunks of it,
+// with an upload session
+func (c *client) upload(path string, f *os.File, size, chunkSize int64) error {
+	commit := commitInfo{Path: path, Mode: "overwrite", Mute: true}
+	if size <= chunkSize {
+		if err := c.send("files/upload", commit, f, 0, size, nil); err != nil {
+			return fmt.Errorf("Dropbox upload of %s failed: %w", path, err)
+		}
+		return nil
+	}
+	var session struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := c.send("files/upload_session/start", map[string]bool{"close": false}, f, 0, chunkSize, &session); err != nil {
+		return fmt.Errorf("Dropbox upload of %s failed to start a session: %w", path, err)
+	}
+	offset := chunkSize
+	for ; size-offset > chunkSize; offset += chunkSize {
+		arg := map[string]interface{}{"cursor": uploadCursor{SessionID: session.SessionID, Offset: offset}, "close": false}
+		if err := c.send("files/upload_session/append_v2", arg, f, offset, chunkSize, nil); err != nil {
+			return fmt.Errorf("Dropbox upload of %s failed at offset %d: %w", path, offset, err)
+		}
+	}
+	arg := map[string]interface{}{"cursor": uploadCursor{SessionID: session.SessionID, Offset: offset}, "commit": commit}
+	if err := c.send("files/upload_session/finish", arg, f, offset, size-offset, nil); err != nil {
+		return fmt.Errorf("Dropbox upload of %s failed to finish its session: %w", path, err)
+	}
+	return nil
+}
+
+// delete deletes a file, or a folder and everything under it
+func (c *client) delete(path string) error {
+	return c.call("files/delete_v2", map[string]string{"path": path}, nil)
+}
+
+// contentHash computes the content hash of a file, which is the SHA256 checksum of the concatenation of the SHA256
+// checksums of its blocks of contentHashBlockSize bytes
+type contentHash struct {
+	overall hash.Hash
+	block   hash.Hash
+	n       int
+}
+
+func newContentHash() *contentHash {
+	return &contentHash{overall: sha256.New(), block: sha256.New()}
+}
+
+func (h *contentHash) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		n := contentHashBlockSize - h.n
+		if n > len(p) {
+			n = len(p)
+		}
+		_, _ = h.block.Write(p[:n])
+		h.n += n
+		p = p[n:]
+		if h.n == contentHashBlockSize {
+			_, _ = h.overall.Write(h.block.Sum(nil))
+			h.block.Reset()
+			h.n = 0
+		}
+	}
+	return written, nil
+}
+
+// sum returns the hex content hash of what has been written, after which nothing more can be
+func (h *contentHash) sum() string {
+	if h.n > 0 {
+		_, _ = h.overall.Write(h.block.Sum(nil))
+		h.n = 0
+	}
+	return hex.EncodeToString(h.overall.Sum(nil))
+}
@@ -0,0 +1,253 @@
+package dropbox
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const (
+	// defaultChunkSize is the size of the chunks large files are uploaded in, unless the artifact sets one
+	defaultChunkSize = 8 * 1024 * 1024
+	// maxChunkSize is the largest upload Dropbox accepts
+	maxChunkSize = 150 * 1024 * 1024
+)
+
+// ArtifactDriver is the artifact driver for Dropbox, which exchanges the refresh token of a Dropbox app for an access
+// token
+type ArtifactDriver struct {
+	AppKey       string
+	AppSecret    string
+	RefreshToken string
+	// ChunkSize is the size of the chunks files larger than it are uploaded in, defaultChunkSize if not set
+	ChunkSize int64
+	// APIURL and ContentURL are the URLs of the Dropbox API and content hosts, if set, rather than those of Dropbox
+	APIURL     string
+	ContentURL string
+	common.Logging
+}
+
+// ValidateArtifact validates the Dropbox artifact, beyond what the general artifact validation checks
+func ValidateArtifact(errPrefix string, art *wfv1.DropboxArtifact) error {
+	if _, err := ParseChunkSize(art.ChunkSize); err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s.chunkSize %s", errPrefix, err.Error())
+	}
+	return nil
+}
+
+// ParseChunkSize returns the chunk size of a Dropbox artifact in bytes, or 0 if it is not set
+func ParseChunkSize(size string) (int64, error) {
+	if size == "" {
+		return 0, nil
+	}
+	q, err := resource.ParseQuantity(size)
+	if err != nil {
+		return 0, errors.Errorf(errors.CodeBadRequest, "must be a quantity of bytes, e.g. 8Mi: %v", err)
+	}
+	if q.Value() < 1 || q.Value() > maxChunkSize {
+		return 0, errors.Errorf(errors.CodeBadRequest, "must be between 1 byte and 150Mi")
+	}
+	return q.Value(), nil
+}
+
+// dropboxPath returns the path of an artifact as Dropbox expects it: with a leading but no trailing slash, or empty
+// for the root folder
+func dropboxPath(path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return ""
+	}
+	return "/" + path
+}
+
+// relativePath returns the path of an entry under a folder, whose path is in lower case, relative to it. Only the
+// last component of the displayed path of an entry is sure to have the case it has in Dropbox, so each component is
+// the name of the entry it is the path of, if it was listed.
+func relativePath(folder string, entry metadata, names map[string]string) (string, bool) {
+	if !strings.HasPrefix(entry.PathLower, folder+"/") {
+		return "", false
+	}
+	components := strings.Split(strings.TrimPrefix(entry.PathLower, folder+"/"), "/")
+	path := folder
+	for i, component := range components {
+		path += "/" + component
+		if name, ok := names[path]; ok {
+			components[i] = name
+		}
+	}
+	return strings.Join(components, "/"), true
+}
+
+func (d *ArtifactDriver) authorize(ctx context.Context) (*client, error) {
+	apiURL := d.APIURL
+	if apiURL == "" {
+		apiURL = defaultAPIURL
+	}
+	contentURL := d.ContentURL
+	if contentURL == "" {
+		contentURL = defaultContentURL
+	}
+	return authorize(ctx, apiURL, contentURL, d.AppKey, d.AppSecret, d.RefreshToken)
+}
+
+// Load downloads a file, or every file under a folder, from Dropbox
+func (d *ArtifactDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, localPath string) error {
+	path := dropboxPath(inputArtifact.Dropbox.Path)
+	d.Log().Infof("Dropbox Load path: %s, dropbox path: %s", localPath, path)
+	c, err := d.authorize(ctx)
+	if err != nil {
+		return err
+	}
+	// the root folder has no metadata
+	m := metadata{Tag: tagFolder}
+	if path != "" {
+		m, err = c.getMetadata(path)
+		if err != nil {
+			return err
+		}
+	}
+	if m.Tag == tagFolder {
+		return d.loadFolder(c, m.PathLower, localPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(localPath), 0700); err != nil {
+		return err
+	}
+	if err := c.download(m.PathLower, localPath); err != nil {
+		_ = os.Remove(localPath)
+		return err
+	}
+	return nil
+}
+
+// loadFolder downloads every file under a folder, and creates every folder under it, even if it is empty
+func (d *ArtifactDriver) loadFolder(c *client, folder, localPath string) error {
+	entries, err := c.listFolder(folder)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(localPath, 0700); err != nil {
+		return err
+	}
+	names := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		names[entry.PathLower] = entry.Name
+	}
+	for _, entry := range entries {
+		rel, ok := relativePath(folder, entry, names)
+		if !ok {
+			continue
+		}
+		path := filepath.Join(localPath, filepath.FromSlash(rel))
+		switch entry.Tag {
+		case tagFolder:
+			if err := os.MkdirAll(path, 0700); err != nil {
+				return err
+			}
+		case tagFile:
+			d.Log().Debugf("Dropbox downloading %s to %s", entry.PathDisplay, path)
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				return err
+			}
+			if err := c.download(entry.PathLower, path); err != nil {
+				_ = os.Remove(path)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Save uploads a file, or every file under a directory, to Dropbox, replacing any file at its path. Files larger
+// than the chunk size are uploaded in chunks, with an upload session.
+func (d *ArtifactDriver) Save(ctx context.Context, localPath string, outputArtifact *wfv1.Artifact) error {
+	path := dropboxPath(outputArtifact.Dropbox.Path)
+	d.Log().Infof("Dropbox Save path: %s, dropbox path: %s", localPath, path)
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+	c, err := d.authorize(ctx)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return d.upload(c, localPath, path, info.Size())
+	}
+	return filepath.Walk(localPath, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(localPath, p)
+		if err != nil {
+			return err
+		}
+		return d.upload(c, p, path+"/"+filepath.ToSlash(rel), fi.Size())
+	})
+}
+
+func (d *ArtifactDriver) upload(c *client, localPath, path string, size int64) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	chunkSize := d.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	if size > chunkSize {
+		d.Log().Debugf("Dropbox uploading %s in chunks of %d bytes", path, chunkSize)
+	}
+	return c.upload(path, f, size, chunkSize)
+}
+
+// Exists returns whether there is a file or folder at the path of the artifact
+func (d *ArtifactDriver) Exists(artifact *wfv1.Artifact) (bool, error) {
+	path := dropboxPath(artifact.Dropbox.Path)
+	if path == "" {
+		return true, nil
+	}
+	c, err := d.authorize(context.Background())
+	if err != nil {
+		return false, err
+	}
+	_, err = c.getMetadata(path)
+	if stderrors.Is(err, common.ErrArtifactNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Delete deletes the file, or the folder and everything under it, from Dropbox
+func (d *ArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	path := dropboxPath(artifact.Dropbox.Path)
+	d.Log().Infof("Dropbox Delete path: %s", path)
+	if path == "" {
+		return errors.Errorf(errors.CodeBadRequest, "Dropbox cannot delete the root folder")
+	}
+	c, err := d.authorize(context.Background())
+	if err != nil {
+		return err
+	}
+	if err := c.delete(path); err != nil {
+		return fmt.Errorf("Dropbox delete of %s failed: %w", path, err)
+	}
+	return nil
+}
+
+// Capabilities returns the optional operations on Dropbox artifacts the driver supports
+func (d *ArtifactDriver) Capabilities() common.DriverCapabilities {
+	return common.DriverCapabilities{
+		Delete: true,
+		Exists: true,
+	}
+}
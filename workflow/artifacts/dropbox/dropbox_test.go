@@ -0,0 +1,429 @@
+package dropbox
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const (
+	testAppKey       = "my-app-key"
+	testAppSecret    = "my-app-secret"
+	testRefreshToken = "my-refresh-token"
+	testToken        = "my-access-token"
+	testChunkSize    = 5
+	// the number of entries the fake lists per page
+	listPageSize = 2
+)
+
+// fakeDropbox is a Dropbox API with the files and folders of a single user, keyed by their lower case paths
+type fakeDropbox struct {
+	*httptest.Server
+	mu sync.Mutex
+	// the contents of files, and the displayed paths of files and folders
+	files   map[string][]byte
+	folders map[string]bool
+	display map[string]string
+	// the contents uploaded to each upload session so far
+	sessions map[string][]byte
+	// the number of single-shot uploads, and of chunks uploaded to sessions
+	uploads, chunks int
+	// whether downloads are returned with a wrong content hash
+	corrupt bool
+}
+
+func newFakeDropbox() *fakeDropbox {
+	f := &fakeDropbox{files: map[string][]byte{}, folders: map[string]bool{}, display: map[string]string{}, sessions: map[string][]byte{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+// testContentHash computes a content hash as Dropbox documents it
+func testContentHash(data []byte) string {
+	var blocks []byte
+	for len(data) > 0 {
+		n := contentHashBlockSize
+		if n > len(data) {
+			n = len(data)
+		}
+		sum := sha256.Sum256(data[:n])
+		blocks = append(blocks, sum[:]...)
+		data = data[n:]
+	}
+	sum := sha256.Sum256(blocks)
+	return hex.EncodeToString(sum[:])
+}
+
+func writeError(w http.ResponseWriter, status int, summary string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error_summary": summary})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (f *fakeDropbox) put(p string, data []byte) {
+	lower := strings.ToLower(p)
+	f.files[lower] = data
+	f.display[lower] = p
+	for dir := path.Dir(p); dir != "/"; dir = path.Dir(dir) {
+		f.folders[strings.ToLower(dir)] = true
+		f.display[strings.ToLower(dir)] = dir
+	}
+}
+
+func (f *fakeDropbox) metadata(lower string) (metadata, bool) {
+	if data, ok := f.files[lower]; ok {
+		return metadata{Tag: tagFile, Name: path.Base(f.display[lower]), PathLower: lower, PathDisplay: f.display[lower], Size: int64(len(data)), ContentHash: testContentHash(data)}, true
+	}
+	if f.folders[lower] {
+		return metadata{Tag: tagFolder, Name: path.Base(f.display[lower]), PathLower: lower, PathDisplay: f.display[lower]}, true
+	}
+	return metadata{}, false
+}
+
+func (f *fakeDropbox) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path == "/oauth2/token" {
+		if r.FormValue("grant_type") != "refresh_token" || r.FormValue("refresh_token") != testRefreshToken || r.FormValue("client_id") != testAppKey || r.FormValue("client_secret") != testAppSecret {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]string{"error": "invalid_grant", "error_description": "refresh token is malformed"})
+			return
+		}
+		writeJSON(w, map[string]interface{}{"access_token": testToken, "token_type": "bearer", "expires_in": 14400})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+testToken {
+		writeError(w, http.StatusUnauthorized, "invalid_access_token/")
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	// content endpoints take their arguments in a header, which must be ASCII, and RPC endpoints in their body
+	arg := data
+	if header := r.Header.Get(apiArgHeader); header != "" {
+		for _, ch := range []byte(header) {
+			if ch >= 0x80 {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = fmt.Fprint(w, "Error in call to API function: invalid header")
+				return
+			}
+		}
+		arg = []byte(header)
+	}
+	var req struct {
+		Path      string      `json:"path"`
+		Cursor    interface{} `json:"cursor"`
+		Recursive bool        `json:"recursive"`
+		Mode      string      `json:"mode"`
+		Commit    commitInfo  `json:"commit"`
+		Close     bool        `json:"close"`
+	}
+	if err := json.Unmarshal(arg, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	lower := strings.ToLower(req.Path)
+	switch strings.TrimPrefix(r.URL.Path, "/2/") {
+	case "files/get_metadata":
+		m, ok := f.metadata(lower)
+		if !ok {
+			writeError(w, http.StatusConflict, "path/not_found/")
+			return
+		}
+		writeJSON(w, m)
+	case "files/list_folder":
+		f.list(w, lower, 0)
+	case "files/list_folder/continue":
+		cursor := strings.SplitN(req.Cursor.(string), ":", 2)
+		offset, _ := strconv.Atoi(cursor[0])
+		f.list(w, cursor[1], offset)
+	case "files/download":
+		m, ok := f.metadata(lower)
+		if !ok || m.Tag != tagFile {
+			writeError(w, http.StatusConflict, "path/not_found/")
+			return
+		}
+		if f.corrupt {
+			m.ContentHash = testContentHash([]byte("corrupt"))
+		}
+		result, _ := json.Marshal(m)
+		w.Header().Set(apiResultHeader, string(result))
+		_, _ = w.Write(f.files[lower])
+	case "files/upload":
+		if req.Mode != "overwrite" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.uploads++
+		f.put(req.Path, data)
+		writeJSON(w, map[string]string{"path_display": req.Path})
+	case "files/upload_session/start":
+		id := fmt.Sprintf("session-%d", len(f.sessions))
+		f.sessions[id] = data
+		f.chunks++
+		writeJSON(w, map[string]string{"session_id": id})
+	case "files/upload_session/append_v2", "files/upload_session/finish":
+		var cursor struct {
+			Cursor uploadCursor `json:"cursor"`
+		}
+		_ = json.Unmarshal(arg, &cursor)
+		session, ok := f.sessions[cursor.Cursor.SessionID]
+		if !ok {
+			writeError(w, http.StatusConflict, "lookup_failed/not_found/")
+			return
+		}
+		if cursor.Cursor.Offset != int64(len(session)) {
+			writeError(w, http.StatusConflict, "lookup_failed/incorrect_offset/")
+			return
+		}
+		f.sessions[cursor.Cursor.SessionID] = append(session, data...)
+		f.chunks++
+		if strings.HasSuffix(r.URL.Path, "/finish") {
+			f.put(req.Commit.Path, f.sessions[cursor.Cursor.SessionID])
+			delete(f.sessions, cursor.Cursor.SessionID)
+			writeJSON(w, map[string]string{"path_display": req.Commit.Path})
+			return
+		}
+		writeJSON(w, nil)
+	case "files/delete_v2":
+		if _, ok := f.metadata(lower); !ok {
+			writeError(w, http.StatusConflict, "path_lookup/not_found/")
+			return
+		}
+		for k := range f.files {
+			if k == lower || strings.HasPrefix(k, lower+"/") {
+				delete(f.files, k)
+			}
+		}
+		for k := range f.folders {
+			if k == lower || strings.HasPrefix(k, lower+"/") {
+				delete(f.folders, k)
+			}
+		}
+		writeJSON(w, map[string]interface{}{"metadata": map[string]string{"path_display": req.Path}})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// list lists a page of the entries under the folder, from the offset, with a cursor to the next page. The displayed
+// paths of the entries are in upper case, other than their last components, as Dropbox may return them.
+func (f *fakeDropbox) list(w http.ResponseWriter, folder string, offset int) {
+	if _, ok := f.metadata(folder); folder != "" && !ok {
+		writeError(w, http.StatusConflict, "path/not_found/")
+		return
+	}
+	var entries []metadata
+	for k := range f.display {
+		if m, ok := f.metadata(k); ok && strings.HasPrefix(k, folder+"/") {
+			m.PathDisplay = strings.ToUpper(path.Dir(m.PathDisplay)) + "/" + path.Base(m.PathDisplay)
+			entries = append(entries, m)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].PathLower < entries[j].PathLower })
+	end := offset + listPageSize
+	if end > len(entries) {
+		end = len(entries)
+	}
+	writeJSON(w, map[string]interface{}{
+		"entries":  entries[offset:end],
+		"cursor":   fmt.Sprintf("%d:%s", end, folder),
+		"has_more": end < len(entries),
+	})
+}
+
+func newArtifact(path string) *wfv1.Artifact {
+	return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{Dropbox: &wfv1.DropboxArtifact{Path: path}}}
+}
+
+func TestArtifactDriver(t *testing.T) {
+	f := newFakeDropbox()
+	defer f.Close()
+	driver := &ArtifactDriver{AppKey: testAppKey, AppSecret: testAppSecret, RefreshToken: testRefreshToken, ChunkSize: testChunkSize, APIURL: f.URL, ContentURL: f.URL}
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "dropbox")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	t.Run("File", func(t *testing.T) {
+		localPath := filepath.Join(dir, "small")
+		assert.NoError(t, ioutil.WriteFile(localPath, []byte("hello"), 0600))
+		// a path without a leading slash is relative to the root folder
+		if assert.NoError(t, driver.Save(ctx, localPath, newArtifact("my-folder/Small"))) {
+			assert.Equal(t, "hello", string(f.files["/my-folder/small"]))
+			assert.Equal(t, 1, f.uploads)
+			assert.Equal(t, 0, f.chunks)
+		}
+		loadPath := filepath.Join(dir, "loaded", "small")
+		if assert.NoError(t, driver.Load(ctx, newArtifact("/my-folder/small"), loadPath)) {
+			data, err := ioutil.ReadFile(loadPath)
+			assert.NoError(t, err)
+			assert.Equal(t, "hello", string(data))
+		}
+	})
+
+	t.Run("LargeFile", func(t *testing.T) {
+		localPath := filepath.Join(dir, "large")
+		assert.NoError(t, ioutil.WriteFile(localPath, []byte("hello, dropbox"), 0600))
+		f.uploads, f.chunks = 0, 0
+		if assert.NoError(t, driver.Save(ctx, localPath, newArtifact("/my-folder/large"))) {
+			assert.Equal(t, "hello, dropbox", string(f.files["/my-folder/large"]))
+			assert.Equal(t, 0, f.uploads)
+			// 14 bytes are uploaded in chunks of 5
+			assert.Equal(t, 3, f.chunks)
+			assert.Empty(t, f.sessions)
+		}
+		loadPath := filepath.Join(dir, "loaded", "large")
+		if assert.NoError(t, driver.Load(ctx, newArtifact("/my-folder/large"), loadPath)) {
+			data, err := ioutil.ReadFile(loadPath)
+			assert.NoError(t, err)
+			assert.Equal(t, "hello, dropbox", string(data))
+		}
+	})
+
+	t.Run("Folder", func(t *testing.T) {
+		localPath := filepath.Join(dir, "folder")
+		files := map[string]string{"a": "a", "b/c": "bc", "b/d/e": "bde", "héllo": "hello"}
+		for name, data := range files {
+			p := filepath.Join(localPath, filepath.FromSlash(name))
+			assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0700))
+			assert.NoError(t, ioutil.WriteFile(p, []byte(data), 0600))
+		}
+		if !assert.NoError(t, driver.Save(ctx, localPath, newArtifact("/My-Folder/folder/"))) {
+			return
+		}
+		for name, data := range files {
+			assert.Equal(t, data, string(f.files["/my-folder/folder/"+name]), name)
+		}
+		f.folders["/my-folder/folder/empty"] = true
+		f.display["/my-folder/folder/empty"] = "/My-Folder/folder/Empty"
+		loadPath := filepath.Join(dir, "loaded", "folder")
+		if !assert.NoError(t, driver.Load(ctx, newArtifact("/my-folder/folder"), loadPath)) {
+			return
+		}
+		for name, data := range files {
+			got, err := ioutil.ReadFile(filepath.Join(loadPath, filepath.FromSlash(name)))
+			assert.NoError(t, err)
+			assert.Equal(t, data, string(got), name)
+		}
+		info, err := os.Stat(filepath.Join(loadPath, "Empty"))
+		if assert.NoError(t, err) {
+			assert.True(t, info.IsDir())
+		}
+	})
+
+	t.Run("Exists", func(t *testing.T) {
+		exists, err := driver.Exists(newArtifact("/my-folder/small"))
+		assert.NoError(t, err)
+		assert.True(t, exists)
+		exists, err = driver.Exists(newArtifact("/my-folder"))
+		assert.NoError(t, err)
+		assert.True(t, exists)
+		exists, err = driver.Exists(newArtifact("/not-found"))
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		err := driver.Load(ctx, newArtifact("/not-found"), filepath.Join(dir, "not-found"))
+		assert.True(t, errors.Is(err, common.ErrArtifactNotFound))
+		_, err = os.Stat(filepath.Join(dir, "not-found"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("ContentHashMismatch", func(t *testing.T) {
+		f.corrupt = true
+		defer func() { f.corrupt = false }()
+		loadPath := filepath.Join(dir, "corrupt")
+		err := driver.Load(ctx, newArtifact("/my-folder/small"), loadPath)
+		assert.EqualError(t, err, "Dropbox download of /my-folder/small failed: content hash mismatch")
+		_, err = os.Stat(loadPath)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.NoError(t, driver.Delete(newArtifact("/my-folder/folder")))
+		assert.NotContains(t, f.files, "/my-folder/folder/a")
+		assert.Contains(t, f.files, "/my-folder/small")
+		exists, err := driver.Exists(newArtifact("/my-folder/folder"))
+		assert.NoError(t, err)
+		assert.False(t, exists)
+		err = driver.Delete(newArtifact("/my-folder/folder"))
+		assert.True(t, errors.Is(err, common.ErrArtifactNotFound))
+	})
+
+	t.Run("InvalidRefreshToken", func(t *testing.T) {
+		driver := &ArtifactDriver{AppKey: testAppKey, AppSecret: testAppSecret, RefreshToken: "wrong", APIURL: f.URL, ContentURL: f.URL}
+		err := driver.Load(ctx, newArtifact("/my-folder/small"), filepath.Join(dir, "unauthorized"))
+		assert.EqualError(t, err, "Dropbox refresh of the access token failed: refresh token is malformed (400)")
+	})
+}
+
+func TestContentHash(t *testing.T) {
+	// the content hash of an empty file is the checksum of no block checksums
+	h := newContentHash()
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", h.sum())
+	all := make([]byte, 2*contentHashBlockSize+10)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	data := all
+	h = newContentHash()
+	// the blocks are hashed whatever the sizes of the writes
+	for _, n := range []int{1, contentHashBlockSize, 100} {
+		_, _ = h.Write(data[:n])
+		data = data[n:]
+	}
+	_, _ = h.Write(data)
+	assert.Equal(t, testContentHash(all), h.sum())
+}
+
+func TestHeaderArg(t *testing.T) {
+	header, err := headerArg(map[string]string{"path": "/héllo/😀"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"path":"/h\u00e9llo/\ud83d\ude00"}`, header)
+	var arg map[string]string
+	assert.NoError(t, json.Unmarshal([]byte(header), &arg))
+	assert.Equal(t, "/héllo/😀", arg["path"])
+}
+
+func TestParseChunkSize(t *testing.T) {
+	for size, expected := range map[string]int64{"": 0, "1": 1, "8Mi": 8 * 1024 * 1024, "150Mi": maxChunkSize} {
+		n, err := ParseChunkSize(size)
+		assert.NoError(t, err, size)
+		assert.Equal(t, expected, n, size)
+	}
+	for _, size := range []string{"0", "151Mi", "big"} {
+		_, err := ParseChunkSize(size)
+		assert.Error(t, err, size)
+	}
+}
@@ -0,0 +1,288 @@
+// Package encryption encrypts artifacts on the client before they are saved, and decrypts them once they are loaded,
+// with age or GPG, by wrapping the driver of their location.
+package encryption
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/resource"
+)
+
+// Driver is the interface of the drivers an ArtifactDriver wraps, which is that of the ArtifactDriver of the
+// artifacts package
+type Driver interface {
+	Load(ctx context.Context, inputArtifact *wfv1.Artifact, path string) error
+	Save(ctx context.Context, path string, outputArtifact *wfv1.Artifact) error
+	Delete(artifact *wfv1.Artifact) error
+}
+
+// ValidateEncryption validates the encryption of an artifact
+func ValidateEncryption(errPrefix string, art *wfv1.Artifact) error {
+	encryption := art.Encryption
+	if encryption == nil {
+		return nil
+	}
+	switch encryption.Scheme {
+	case wfv1.ArtifactEncryptionSchemeAge:
+		if encryption.PassphraseSecret != nil {
+			return errors.Errorf(errors.CodeBadRequest, "%s.encryption.passphraseSecret is only valid for the gpg scheme", errPrefix)
+		}
+	case wfv1.ArtifactEncryptionSchemeGPG:
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "%s.encryption.scheme %q must be one of: age, gpg", errPrefix, encryption.Scheme)
+	}
+	if encryption.PrivateKeySecret == nil || encryption.PrivateKeySecret.Name == "" || encryption.PrivateKeySecret.Key == "" {
+		return errors.Errorf(errors.CodeBadRequest, "%s.encryption.privateKeySecret is required", errPrefix)
+	}
+	if common.IsCompressed(art.Compression) {
+		// ciphertext does not compress, and the driver only sees ciphertext
+		return errors.Errorf(errors.CodeBadRequest, "%s.compression cannot be used with encryption", errPrefix)
+	}
+	// the driver which encrypts artifacts implements none of the optional interfaces these require of drivers
+	if art.Overwrite != "" && art.Overwrite != wfv1.ArtifactOverwriteAllow {
+		return errors.Errorf(errors.CodeBadRequest, "%s.overwrite %s cannot be used with encryption", errPrefix, art.Overwrite)
+	}
+	if art.Idempotency != nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s.idempotency cannot be used with encryption", errPrefix)
+	}
+	if art.MaxSize != "" {
+		return errors.Errorf(errors.CodeBadRequest, "%s.maxSize cannot be used with encryption", errPrefix)
+	}
+	if len(art.Metadata) > 0 {
+		return errors.Errorf(errors.CodeBadRequest, "%s.metadata cannot be used with encryption", errPrefix)
+	}
+	if art.AtomicSave {
+		return errors.Errorf(errors.CodeBadRequest, "%s.atomicSave cannot be used with encryption", errPrefix)
+	}
+	return nil
+}
+
+// ArtifactDriver decrypts the artifacts the driver it wraps loads, before they are written to their paths, and
+// encrypts the files it saves, with the encryption of the artifacts and the keys of their secrets. It implements
+// none of the optional interfaces of drivers, e.g. streaming, which would read the ciphertext.
+type ArtifactDriver struct {
+	driver Driver
+	ri     resource.Interface
+}
+
+// NewArtifactDriver returns a driver which encrypts and decrypts the artifacts of driver, reading their keys from ri
+func NewArtifactDriver(driver Driver, ri resource.Interface) *ArtifactDriver {
+	return &ArtifactDriver{driver: driver, ri: ri}
+}
+
+// Load loads the encrypted artifact next to path, and writes it to path once it is decrypted, so that path is only
+// written once the artifact is loaded and decrypted
+func (d *ArtifactDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, path string) error {
+	if inputArtifact.Encryption == nil {
+		return d.driver.Load(ctx, inputArtifact, path)
+	}
+	c, err := d.newCipher(ctx, inputArtifact.Encryption)
+	if err != nil {
+		return err
+	}
+	return common.LoadAtomically(path, func(path string) error {
+		encrypted := path + ".encrypted"
+		if err := d.driver.Load(ctx, inputArtifact, encrypted); err != nil {
+			return err
+		}
+		info, err := os.Stat(encrypted)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return errors.Errorf(errors.CodeBadRequest, "encrypted artifact %s is a directory, which cannot be decrypted", inputArtifact.Name)
+		}
+		return transformFile(encrypted, path, c.decrypt)
+	})
+}
+
+// Save encrypts the file at path to a temporary file, which the driver it wraps saves. A directory cannot be
+// encrypted, and must be archived to be.
+func (d *ArtifactDriver) Save(ctx context.Context, path string, outputArtifact *wfv1.Artifact) error {
+	if outputArtifact.Encryption == nil {
+		return d.driver.Save(ctx, path, outputArtifact)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return errors.Errorf(errors.CodeBadRequest, "artifact %s is a directory, which must be archived to be encrypted", outputArtifact.Name)
+	}
+	c, err := d.newCipher(ctx, outputArtifact.Encryption)
+	if err != nil {
+		return err
+	}
+	tmp, err := common.CreateTemp("encrypted-")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	_ = tmp.Close()
+	defer func() { _ = os.Remove(tmpPath) }()
+	if err := transformFile(path, tmpPath, c.encrypt); err != nil {
+		return err
+	}
+	return d.driver.Save(ctx, tmpPath, outputArtifact)
+}
+
+// Delete deletes the artifact with the driver it wraps
+func (d *ArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	return d.driver.Delete(artifact)
+}
+
+// transformFile writes what transform writes to dst, given the content of src, to the file at dst
+func transformFile(src, dst string, transform func(dst io.Writer, src io.Reader) error) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = out.Close() }()
+	if err := transform(out, in); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// cipher encrypts and decrypts the content of artifacts
+type cipher struct {
+	encrypt func(dst io.Writer, src io.Reader) error
+	decrypt func(dst io.Writer, src io.Reader) error
+}
+
+// newCipher returns the cipher of the encryption, with the keys of its secrets
+func (d *ArtifactDriver) newCipher(ctx context.Context, encryption *wfv1.ArtifactEncryption) (*cipher, error) {
+	if encryption.PrivateKeySecret == nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "encryption has no private key secret")
+	}
+	privateKey, err := d.ri.GetSecret(ctx, encryption.PrivateKeySecret.Name, encryption.PrivateKeySecret.Key)
+	if err != nil {
+		return nil, err
+	}
+	switch encryption.Scheme {
+	case wfv1.ArtifactEncryptionSchemeAge:
+		return newAgeCipher(privateKey)
+	case wfv1.ArtifactEncryptionSchemeGPG:
+		var passphrase string
+		if encryption.PassphraseSecret != nil {
+			passphrase, err = d.ri.GetSecret(ctx, encryption.PassphraseSecret.Name, encryption.PassphraseSecret.Key)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return newGPGCipher(privateKey, passphrase)
+	}
+	return nil, errors.Errorf(errors.CodeBadRequest, "unsupported encryption scheme %q", encryption.Scheme)
+}
+
+// newAgeCipher returns the cipher of the age identities of the private key, which encrypts to their recipients
+func newAgeCipher(privateKey string) (*cipher, error) {
+	// the error would quote the key
+	identities, err := age.ParseIdentities(strings.NewReader(privateKey))
+	if err != nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "the private key is not an age identity")
+	}
+	var recipients []age.Recipient
+	for _, identity := range identities {
+		if x25519, ok := identity.(*age.X25519Identity); ok {
+			recipients = append(recipients, x25519.Recipient())
+		}
+	}
+	return &cipher{
+		encrypt: func(dst io.Writer, src io.Reader) error {
+			w, err := age.Encrypt(dst, recipients...)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(w, src); err != nil {
+				return err
+			}
+			return w.Close()
+		},
+		decrypt: func(dst io.Writer, src io.Reader) error {
+			r, err := age.Decrypt(src, identities...)
+			if err != nil {
+				return errors.Errorf(errors.CodeBadRequest, "failed to decrypt with age: %v", err)
+			}
+			_, err = io.Copy(dst, r)
+			return err
+		},
+	}, nil
+}
+
+// newGPGCipher returns the cipher of the entities of the armored or binary private key, whose private keys are
+// decrypted with the passphrase if they are protected by one
+func newGPGCipher(privateKey, passphrase string) (*cipher, error) {
+	keyring, err := readKeyRing(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, entity := range keyring {
+		keys := []*packet.PrivateKey{entity.PrivateKey}
+		for _, subkey := range entity.Subkeys {
+			keys = append(keys, subkey.PrivateKey)
+		}
+		for _, key := range keys {
+			if key == nil || !key.Encrypted {
+				continue
+			}
+			if passphrase == "" {
+				return nil, errors.Errorf(errors.CodeBadRequest, "the GPG private key is protected by a passphrase, which has no secret")
+			}
+			if err := key.Decrypt([]byte(passphrase)); err != nil {
+				return nil, errors.Errorf(errors.CodeBadRequest, "failed to decrypt the GPG private key with the passphrase: %v", err)
+			}
+		}
+	}
+	return &cipher{
+		encrypt: func(dst io.Writer, src io.Reader) error {
+			w, err := openpgp.Encrypt(dst, keyring, nil, &openpgp.FileHints{IsBinary: true}, nil)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(w, src); err != nil {
+				return err
+			}
+			return w.Close()
+		},
+		decrypt: func(dst io.Writer, src io.Reader) error {
+			md, err := openpgp.ReadMessage(src, keyring, nil, nil)
+			if err != nil {
+				return errors.Errorf(errors.CodeBadRequest, "failed to decrypt with GPG: %v", err)
+			}
+			// the artifact is only encrypted, not signed, so it has no signature to verify
+			_, err = io.Copy(dst, md.UnverifiedBody)
+			return err
+		},
+	}, nil
+}
+
+// readKeyRing reads the armored or binary keyring of the private key
+func readKeyRing(privateKey string) (openpgp.EntityList, error) {
+	var keyring openpgp.EntityList
+	var err error
+	if strings.HasPrefix(strings.TrimSpace(privateKey), "-----BEGIN") {
+		keyring, err = openpgp.ReadArmoredKeyRing(strings.NewReader(privateKey))
+	} else {
+		keyring, err = openpgp.ReadKeyRing(strings.NewReader(privateKey))
+	}
+	if err != nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "the private key is not a GPG key: %v", err)
+	}
+	return keyring, nil
+}
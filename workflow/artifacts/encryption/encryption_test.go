@@ -0,0 +1,222 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	apiv1 "k8s.io/api/core/v1"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/memory"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/resource/resourcetest"
+)
+
+func secret(key string) *apiv1.SecretKeySelector {
+	return &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: key}
+}
+
+func newArtifact(scheme wfv1.ArtifactEncryptionScheme) *wfv1.Artifact {
+	return &wfv1.Artifact{
+		Name:             "my-art",
+		ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "my-key"}},
+		Encryption:       &wfv1.ArtifactEncryption{Scheme: scheme, PrivateKeySecret: secret("private-key")},
+	}
+}
+
+// newGPGKey returns an armored GPG private key
+func newGPGKey(t *testing.T) string {
+	entity, err := openpgp.NewEntity("my-name", "", "my-name@example.com", nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PrivateKeyType, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, entity.SerializePrivate(w, nil))
+	assert.NoError(t, w.Close())
+	return buf.String()
+}
+
+// passphraseGPGKey returns the armored GPG private key of testdata, which is protected by the passphrase
+// "my-passphrase", as keys cannot be encrypted with a passphrase by openpgp
+func passphraseGPGKey(t *testing.T) string {
+	key, err := ioutil.ReadFile(filepath.Join("testdata", "passphrase-key.asc"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return string(key)
+}
+
+func TestArtifactDriver_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	identity, err := age.GenerateX25519Identity()
+	if !assert.NoError(t, err) {
+		return
+	}
+	tests := []struct {
+		name    string
+		scheme  wfv1.ArtifactEncryptionScheme
+		secrets map[string]string
+	}{
+		{"Age", wfv1.ArtifactEncryptionSchemeAge, map[string]string{"my-secret/private-key": identity.String()}},
+		{"GPG", wfv1.ArtifactEncryptionSchemeGPG, map[string]string{"my-secret/private-key": newGPGKey(t)}},
+		{"GPGPassphrase", wfv1.ArtifactEncryptionSchemeGPG, map[string]string{"my-secret/private-key": passphraseGPGKey(t), "my-secret/passphrase": "my-passphrase"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmp, err := ioutil.TempDir("", "encryption")
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer func() { _ = os.RemoveAll(tmp) }()
+			src := filepath.Join(tmp, "src")
+			data := []byte("my-sensitive-data")
+			assert.NoError(t, ioutil.WriteFile(src, data, 0600))
+			art := newArtifact(tt.scheme)
+			if _, ok := tt.secrets["my-secret/passphrase"]; ok {
+				art.Encryption.PassphraseSecret = secret("passphrase")
+			}
+			inner := memory.NewArtifactDriver()
+			driver := NewArtifactDriver(inner, resourcetest.NewFakeInterface(tt.secrets))
+			if !assert.NoError(t, driver.Save(ctx, src, art)) {
+				return
+			}
+			// only the ciphertext is saved
+			saved, ok := inner.Content("memory://my-key")
+			if assert.True(t, ok) {
+				assert.NotContains(t, string(saved), string(data))
+			}
+			dst := filepath.Join(tmp, "dst")
+			if assert.NoError(t, driver.Load(ctx, art, dst)) {
+				loaded, err := ioutil.ReadFile(dst)
+				assert.NoError(t, err)
+				assert.Equal(t, data, loaded)
+			}
+			// the ciphertext is not left next to the path
+			files, err := ioutil.ReadDir(tmp)
+			assert.NoError(t, err)
+			assert.Len(t, files, 2)
+		})
+	}
+}
+
+func TestArtifactDriver_Load(t *testing.T) {
+	ctx := context.Background()
+	identity, err := age.GenerateX25519Identity()
+	if !assert.NoError(t, err) {
+		return
+	}
+	other, err := age.GenerateX25519Identity()
+	if !assert.NoError(t, err) {
+		return
+	}
+	tmp, err := ioutil.TempDir("", "encryption")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	var encrypted bytes.Buffer
+	w, err := age.Encrypt(&encrypted, other.Recipient())
+	assert.NoError(t, err)
+	_, _ = w.Write([]byte("my-data"))
+	assert.NoError(t, w.Close())
+	inner := memory.NewArtifactDriver()
+	inner.Seed("memory://my-key", encrypted.Bytes())
+	driver := NewArtifactDriver(inner, resourcetest.NewFakeInterface(map[string]string{"my-secret/private-key": identity.String()}))
+	t.Run("WrongKey", func(t *testing.T) {
+		dst := filepath.Join(tmp, "wrong-key")
+		err := driver.Load(ctx, newArtifact(wfv1.ArtifactEncryptionSchemeAge), dst)
+		assert.Error(t, err)
+		_, err = os.Stat(dst)
+		assert.True(t, os.IsNotExist(err))
+	})
+	t.Run("Unencrypted", func(t *testing.T) {
+		art := newArtifact(wfv1.ArtifactEncryptionSchemeAge)
+		art.Encryption = nil
+		dst := filepath.Join(tmp, "unencrypted")
+		if assert.NoError(t, driver.Load(ctx, art, dst)) {
+			loaded, err := ioutil.ReadFile(dst)
+			assert.NoError(t, err)
+			assert.Equal(t, encrypted.Bytes(), loaded)
+		}
+	})
+	t.Run("InvalidKey", func(t *testing.T) {
+		driver := NewArtifactDriver(inner, resourcetest.NewFakeInterface(map[string]string{"my-secret/private-key": "my-key"}))
+		err := driver.Load(ctx, newArtifact(wfv1.ArtifactEncryptionSchemeAge), filepath.Join(tmp, "invalid-key"))
+		if assert.Error(t, err) {
+			assert.NotContains(t, err.Error(), "my-key")
+		}
+		err = driver.Load(ctx, newArtifact(wfv1.ArtifactEncryptionSchemeGPG), filepath.Join(tmp, "invalid-key"))
+		assert.Error(t, err)
+	})
+}
+
+func TestArtifactDriver_Save(t *testing.T) {
+	ctx := context.Background()
+	tmp, err := ioutil.TempDir("", "encryption")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	driver := NewArtifactDriver(memory.NewArtifactDriver(), resourcetest.NewFakeInterface(map[string]string{"my-secret/private-key": passphraseGPGKey(t)}))
+	t.Run("Directory", func(t *testing.T) {
+		err := driver.Save(ctx, tmp, newArtifact(wfv1.ArtifactEncryptionSchemeGPG))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "must be archived")
+		}
+	})
+	t.Run("NoPassphrase", func(t *testing.T) {
+		src := filepath.Join(tmp, "src")
+		assert.NoError(t, ioutil.WriteFile(src, []byte("my-data"), 0600))
+		err := driver.Save(ctx, src, newArtifact(wfv1.ArtifactEncryptionSchemeGPG))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "passphrase")
+		}
+	})
+}
+
+func TestValidateEncryption(t *testing.T) {
+	assert.NoError(t, ValidateEncryption("my-art", &wfv1.Artifact{}))
+	assert.NoError(t, ValidateEncryption("my-art", newArtifact(wfv1.ArtifactEncryptionSchemeAge)))
+	assert.NoError(t, ValidateEncryption("my-art", newArtifact(wfv1.ArtifactEncryptionSchemeGPG)))
+	art := newArtifact("rot13")
+	assert.EqualError(t, ValidateEncryption("my-art", art), `my-art.encryption.scheme "rot13" must be one of: age, gpg`)
+	art = newArtifact(wfv1.ArtifactEncryptionSchemeAge)
+	art.Encryption.PrivateKeySecret = nil
+	assert.EqualError(t, ValidateEncryption("my-art", art), "my-art.encryption.privateKeySecret is required")
+	art = newArtifact(wfv1.ArtifactEncryptionSchemeAge)
+	art.Encryption.PassphraseSecret = secret("passphrase")
+	assert.Error(t, ValidateEncryption("my-art", art))
+	art = newArtifact(wfv1.ArtifactEncryptionSchemeGPG)
+	art.Compression = wfv1.ArtifactCompressionGzip
+	err := ValidateEncryption("my-art", art)
+	if assert.Error(t, err) {
+		assert.True(t, strings.HasSuffix(err.Error(), "cannot be used with encryption"))
+	}
+	art = newArtifact(wfv1.ArtifactEncryptionSchemeAge)
+	art.Overwrite = wfv1.ArtifactOverwriteAllow
+	assert.NoError(t, ValidateEncryption("my-art", art))
+	art.Overwrite = wfv1.ArtifactOverwriteFailIfExists
+	assert.EqualError(t, ValidateEncryption("my-art", art), "my-art.overwrite fail-if-exists cannot be used with encryption")
+	art = newArtifact(wfv1.ArtifactEncryptionSchemeAge)
+	art.Idempotency = &wfv1.ArtifactIdempotency{}
+	assert.EqualError(t, ValidateEncryption("my-art", art), "my-art.idempotency cannot be used with encryption")
+	art = newArtifact(wfv1.ArtifactEncryptionSchemeAge)
+	art.MaxSize = "1Gi"
+	assert.EqualError(t, ValidateEncryption("my-art", art), "my-art.maxSize cannot be used with encryption")
+	art = newArtifact(wfv1.ArtifactEncryptionSchemeAge)
+	art.Metadata = map[string]string{"git-commit": "abc123"}
+	assert.EqualError(t, ValidateEncryption("my-art", art), "my-art.metadata cannot be used with encryption")
+	art = newArtifact(wfv1.ArtifactEncryptionSchemeAge)
+	art.AtomicSave = true
+	assert.EqualError(t, ValidateEncryption("my-art", art), "my-art.atomicSave cannot be used with encryption")
+}
@@ -0,0 +1,90 @@
+package executor
+
+import (
+	stderrors "errors"
+	"fmt"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+// Operations of drivers on artifacts, which a DriverError names. Each reads as a verb of an artifact key.
+const (
+	OperationLoad           = "load"
+	OperationSave           = "save"
+	OperationDelete         = "delete"
+	OperationOpen           = "open"
+	OperationLoadRange      = "load a range of"
+	OperationExists         = "check the existence of"
+	OperationVerifyChecksum = "verify the checksum of"
+	OperationDryRunSave     = "dry run saving"
+	OperationPresign        = "presign a URL of"
+	OperationPresignPost    = "presign a POST policy of"
+	OperationHealthCheck    = "check the health of the storage of"
+	OperationCopy           = "copy to"
+	OperationMetadata       = "get the metadata of"
+)
+
+// DriverError is an error a driver returned from an operation on an artifact, by way of the functions of this
+// package, e.g. LoadWithProgress. It names the driver, the operation, and the key of the artifact, and wraps the
+// error of the driver, which errors.Is and errors.As find, and whose code errors.IsCode matches.
+type DriverError struct {
+	// Driver is the name of the driver, e.g. "s3", or "" if the artifact has no location
+	Driver string
+	// Operation is one of the Operation constants
+	Operation string
+	// Key is the key of the artifact, or "" if it has none
+	Key string
+	Err error
+}
+
+// Error returns e.g. "s3 load my-key: Access Denied"
+func (e *DriverError) Error() string {
+	s := e.Operation
+	if e.Driver != "" {
+		s = e.Driver + " " + s
+	}
+	if e.Key != "" {
+		s += " " + e.Key
+	}
+	return fmt.Sprintf("%s: %v", s, e.Err)
+}
+
+func (e *DriverError) Unwrap() error {
+	return e.Err
+}
+
+// Code returns the code of the error of the driver, if it is an Argo error, or otherwise ""
+func (e *DriverError) Code() string {
+	if coder, ok := e.Err.(interface{ Code() string }); ok {
+		return coder.Code()
+	}
+	return ""
+}
+
+// UserMessage formats the error for users, e.g. in the response of the artifact server. If it is, or wraps, a
+// DriverError, the message says what the driver failed to do, e.g. "failed to load artifact my-key in s3: Access
+// Denied".
+func UserMessage(err error) string {
+	var driverErr *DriverError
+	if !stderrors.As(err, &driverErr) {
+		return err.Error()
+	}
+	s := "failed to " + driverErr.Operation + " artifact"
+	if driverErr.Key != "" {
+		s += " " + driverErr.Key
+	}
+	if driverErr.Driver != "" {
+		s += " in " + driverErr.Driver
+	}
+	return fmt.Sprintf("%s: %v", s, driverErr.Err)
+}
+
+// driverError returns the error the driver returned from the operation on the artifact as a DriverError, or nil if
+// there is none
+func driverError(art *wfv1.Artifact, operation string, err error) error {
+	if err == nil {
+		return nil
+	}
+	key, _ := art.GetKey()
+	return &DriverError{Driver: driverName(art), Operation: operation, Key: key, Err: err}
+}
@@ -0,0 +1,108 @@
+package executor
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/memory"
+)
+
+// quotaError is an error of a driver which is not an Argo error
+type quotaError struct {
+	bucket string
+}
+
+func (e *quotaError) Error() string {
+	return fmt.Sprintf("the quota of %s is exceeded", e.bucket)
+}
+
+type failingDriver struct {
+	ArtifactDriver
+	err error
+}
+
+func (d *failingDriver) Save(context.Context, string, *wfv1.Artifact) error {
+	return d.err
+}
+
+func (d *failingDriver) Delete(*wfv1.Artifact) error {
+	return d.err
+}
+
+func TestDriverError(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "artifact")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	art := &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "my-key"}}}
+	t.Run("NotFound", func(t *testing.T) {
+		err := LoadWithProgress(context.Background(), memory.NewArtifactDriver(), art, filepath.Join(tmp, "missing"), nil)
+		var driverErr *DriverError
+		if assert.True(t, stderrors.As(err, &driverErr)) {
+			assert.Equal(t, DriverS3, driverErr.Driver)
+			assert.Equal(t, OperationLoad, driverErr.Operation)
+			assert.Equal(t, "my-key", driverErr.Key)
+		}
+		// the original error is still found, and still has its code
+		assert.True(t, stderrors.Is(err, common.ErrArtifactNotFound))
+		assert.True(t, errors.IsCode(errors.CodeNotFound, err))
+		assert.EqualError(t, err, "s3 load my-key: no artifact stored at memory://my-key")
+		assert.Equal(t, "failed to load artifact my-key in s3: no artifact stored at memory://my-key", UserMessage(err))
+	})
+	t.Run("Save", func(t *testing.T) {
+		original := &quotaError{bucket: "my-bucket"}
+		err := SaveWithProgress(context.Background(), &failingDriver{err: original}, tmp, art, nil)
+		var quotaErr *quotaError
+		if assert.True(t, stderrors.As(err, &quotaErr)) {
+			assert.Equal(t, original, quotaErr)
+		}
+		assert.True(t, stderrors.Is(err, original))
+		assert.False(t, errors.IsCode(errors.CodeNotFound, err))
+		assert.Equal(t, "failed to save artifact my-key in s3: the quota of my-bucket is exceeded", UserMessage(err))
+	})
+	t.Run("Delete", func(t *testing.T) {
+		err := Delete(&failingDriver{err: common.ErrDeletionNotSupported}, art)
+		assert.True(t, stderrors.Is(err, common.ErrDeletionNotSupported))
+		assert.True(t, errors.IsCode(errors.CodeNotImplemented, err))
+		assert.NoError(t, Delete(memory.NewArtifactDriver(), art))
+	})
+	t.Run("Timeout", func(t *testing.T) {
+		// the timeout wraps the error of the driver, which is still found
+		timeoutArt := art.DeepCopy()
+		timeoutArt.Timeout = "10ms"
+		err := SaveWithProgress(context.Background(), &blockingDriver{}, tmp, timeoutArt, nil)
+		var driverErr *DriverError
+		if assert.True(t, stderrors.As(err, &driverErr)) {
+			assert.Equal(t, OperationSave, driverErr.Operation)
+		}
+		assert.True(t, errors.IsCode(errors.CodeTimeout, err))
+		assert.True(t, stderrors.Is(err, context.DeadlineExceeded))
+	})
+	t.Run("NotDriverError", func(t *testing.T) {
+		// errors of this package are not those of the driver
+		_, err := Exists(&loadOnlyDriver{}, art)
+		assert.Equal(t, ErrExistsNotSupported, err)
+		assert.Equal(t, ErrExistsNotSupported.Error(), UserMessage(err))
+	})
+}
+
+// blockingDriver saves until it is cancelled
+type blockingDriver struct {
+	ArtifactDriver
+}
+
+func (d *blockingDriver) Save(ctx context.Context, _ string, _ *wfv1.Artifact) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
@@ -0,0 +1,135 @@
+package executor
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/cache"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/resource"
+)
+
+// FallbackSource is the location an input artifact with fallbacks was loaded from
+type FallbackSource struct {
+	// Location is "location" for the own location of the artifact, or e.g. "fallbacks.locations[0]" for a fallback
+	Location string
+	// Artifact is the artifact with the location it was loaded from, and without fallbacks
+	Artifact *wfv1.Artifact
+}
+
+// FallbackError is the error of loading an artifact with fallbacks which failed to be loaded from every location it
+// was loaded from. errors.Is and errors.As find the error of the last location.
+type FallbackError struct {
+	// Artifact is the name of the artifact
+	Artifact string
+	// Failures are the locations the artifact failed to be loaded from, in the order they were tried
+	Failures []FallbackFailure
+}
+
+// FallbackFailure is a location an artifact failed to be loaded from
+type FallbackFailure struct {
+	// Location is "location" for the own location of the artifact, or e.g. "fallbacks.locations[0]" for a fallback
+	Location string
+	Err      error
+}
+
+// Error returns e.g. "artifact my-art was not loaded from any of the 2 locations tried: location: s3 load my-key: ...;
+// fallbacks.locations[0]: ..."
+func (e *FallbackError) Error() string {
+	failures := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		failures[i] = fmt.Sprintf("%s: %v", f.Location, f.Err)
+	}
+	return fmt.Sprintf("artifact %s was not loaded from any of the %d locations tried: %s", e.Artifact, len(e.Failures), strings.Join(failures, "; "))
+}
+
+func (e *FallbackError) Unwrap() error {
+	return e.Failures[len(e.Failures)-1].Err
+}
+
+// Code returns errors.CodeNotFound if the artifact was not found in any location, so that an optional artifact is
+// only skipped then, and otherwise the code of the error of the last location
+func (e *FallbackError) Code() string {
+	for _, f := range e.Failures {
+		if !isNotFound(f.Err) {
+			if coder, ok := e.Unwrap().(interface{ Code() string }); ok {
+				return coder.Code()
+			}
+			return ""
+		}
+	}
+	return errors.CodeNotFound
+}
+
+// isNotFound returns whether the error is that the artifact does not exist
+func isNotFound(err error) bool {
+	return errors.IsCode(errors.CodeNotFound, err) || stderrors.Is(err, common.ErrArtifactNotFound)
+}
+
+// isAuthError returns whether the error is that the credentials of the artifact were not accepted, or do not allow
+// loading it
+func isAuthError(err error) bool {
+	return errors.IsCode(errors.CodeUnauthorized, err) || errors.IsCode(errors.CodeForbidden, err)
+}
+
+// fallbackSources returns the own location of the artifact, and then its fallback locations, each as a copy of the
+// artifact with that location and without fallbacks
+func fallbackSources(inputArtifact *wfv1.Artifact) []FallbackSource {
+	art := inputArtifact.DeepCopy()
+	art.Fallbacks = nil
+	sources := []FallbackSource{{Location: "location", Artifact: art}}
+	for i, location := range inputArtifact.Fallbacks.Locations {
+		art := inputArtifact.DeepCopy()
+		art.Fallbacks = nil
+		art.ArtifactLocation = *location.DeepCopy()
+		sources = append(sources, FallbackSource{Location: fmt.Sprintf("fallbacks.locations[%d]", i), Artifact: art})
+	}
+	return sources
+}
+
+// LoadWithFallbacks loads the input artifact with LoadCached from its own location with the driver, and otherwise
+// from each of its fallback locations in order, with a driver NewDriver creates with ri, until it is loaded from one,
+// returning the location it was loaded from. An artifact without fallbacks is only loaded with the driver. Once the
+// artifact fails to be loaded from a location, e.g. because it is not found there, it is loaded from the next, unless
+// the error is an auth error and the fallbacks stop on those. If it is not loaded from any location, the error is a
+// *FallbackError with the error of each location it was tried.
+func LoadWithFallbacks(ctx context.Context, c *cache.Cache, driver ArtifactDriver, ri resource.Interface, inputArtifact *wfv1.Artifact, path string, progress common.ProgressFunc) (*FallbackSource, error) {
+	if inputArtifact.Fallbacks == nil || len(inputArtifact.Fallbacks.Locations) == 0 {
+		if err := LoadCached(ctx, c, driver, inputArtifact, path, progress); err != nil {
+			return nil, err
+		}
+		return &FallbackSource{Location: "location", Artifact: inputArtifact}, nil
+	}
+	fallbackErr := &FallbackError{Artifact: inputArtifact.Name}
+	for i, source := range fallbackSources(inputArtifact) {
+		err := loadSource(ctx, c, driver, ri, source, i, path, progress)
+		if err == nil {
+			return &source, nil
+		}
+		driverLogger(driver, inputArtifact).Warnf("Failed to load artifact %s from its %s: %v", inputArtifact.Name, source.Location, err)
+		fallbackErr.Failures = append(fallbackErr.Failures, FallbackFailure{Location: source.Location, Err: err})
+		// anything a failed load wrote is removed, so that it is not mixed with what the next location loads
+		_ = os.RemoveAll(path)
+		if ctx.Err() != nil || (inputArtifact.Fallbacks.StopOnAuthError && isAuthError(err)) {
+			break
+		}
+	}
+	return nil, fallbackErr
+}
+
+// loadSource loads the artifact from the i-th of its sources, the first of which is its own location
+func loadSource(ctx context.Context, c *cache.Cache, driver ArtifactDriver, ri resource.Interface, source FallbackSource, i int, path string, progress common.ProgressFunc) error {
+	if i == 0 {
+		return LoadCached(ctx, c, driver, source.Artifact, path, progress)
+	}
+	fallbackDriver, err := NewDriver(ctx, source.Artifact, ri)
+	if err != nil {
+		return err
+	}
+	return LoadCached(ctx, c, fallbackDriver, source.Artifact, path, progress)
+}
@@ -0,0 +1,159 @@
+package executor
+
+import (
+	"context"
+	stderrors "errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/memory"
+)
+
+// loadingDriver records the keys of the artifacts it loads, in the order it loads them, and fails to load those
+// with an error
+type loadingDriver struct {
+	*memory.ArtifactDriver
+	loaded []string
+	errs   map[string]error
+}
+
+func (d *loadingDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, path string) error {
+	key, err := inputArtifact.GetKey()
+	if err != nil {
+		return err
+	}
+	d.loaded = append(d.loaded, key)
+	if err := d.errs[key]; err != nil {
+		return err
+	}
+	return d.ArtifactDriver.Load(ctx, inputArtifact, path)
+}
+
+func newFallbackArtifact(fallbacks *wfv1.ArtifactFallbacks) *wfv1.Artifact {
+	fallbacks.Locations = []wfv1.ArtifactLocation{
+		{GCS: &wfv1.GCSArtifact{GCSBucket: wfv1.GCSBucket{Bucket: "my-bucket"}, Key: "gcs-key"}},
+		{OSS: &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{Bucket: "my-bucket"}, Key: "oss-key"}},
+	}
+	return &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "my-key"}}, Fallbacks: fallbacks}
+}
+
+func TestLoadWithFallbacks(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "artifact")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	path := filepath.Join(tmp, "my-file")
+	ctx := context.Background()
+	// the same driver loads every location, as the driver of the artifact and that of its fallbacks
+	newDriver := func(errs map[string]error, seeded ...string) *loadingDriver {
+		driver := &loadingDriver{ArtifactDriver: memory.NewArtifactDriver(), errs: errs}
+		for _, url := range seeded {
+			driver.Seed(url, []byte(url))
+		}
+		return driver
+	}
+	assertLoaded := func(t *testing.T, url string) {
+		data, err := ioutil.ReadFile(path)
+		if assert.NoError(t, err) {
+			assert.Equal(t, url, string(data))
+		}
+	}
+
+	t.Run("Location", func(t *testing.T) {
+		driver := newDriver(nil, "memory://my-key", "memory://gcs-key")
+		defer registerMirrorDriver(driver)()
+		source, err := LoadWithFallbacks(ctx, nil, driver, fakeResources{}, newFallbackArtifact(&wfv1.ArtifactFallbacks{}), path, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "location", source.Location)
+			assert.Equal(t, "my-key", source.Artifact.S3.Key)
+			assert.Nil(t, source.Artifact.Fallbacks)
+		}
+		assert.Equal(t, []string{"my-key"}, driver.loaded)
+		assertLoaded(t, "memory://my-key")
+	})
+	t.Run("InOrder", func(t *testing.T) {
+		// an artifact which is not found is loaded from the next location
+		driver := newDriver(nil, "memory://oss-key")
+		defer registerMirrorDriver(driver)()
+		source, err := LoadWithFallbacks(ctx, nil, driver, fakeResources{}, newFallbackArtifact(&wfv1.ArtifactFallbacks{}), path, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "fallbacks.locations[1]", source.Location)
+			if assert.NotNil(t, source.Artifact.OSS) {
+				assert.Equal(t, "oss-key", source.Artifact.OSS.Key)
+			}
+			assert.Equal(t, "my-art", source.Artifact.Name)
+		}
+		assert.Equal(t, []string{"my-key", "gcs-key", "oss-key"}, driver.loaded)
+		assertLoaded(t, "memory://oss-key")
+	})
+	t.Run("Failed", func(t *testing.T) {
+		// as is one which fails to be loaded otherwise
+		driver := newDriver(map[string]error{"my-key": &quotaError{bucket: "my-bucket"}}, "memory://my-key", "memory://gcs-key")
+		defer registerMirrorDriver(driver)()
+		source, err := LoadWithFallbacks(ctx, nil, driver, fakeResources{}, newFallbackArtifact(&wfv1.ArtifactFallbacks{}), path, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "fallbacks.locations[0]", source.Location)
+		}
+		assert.Equal(t, []string{"my-key", "gcs-key"}, driver.loaded)
+		assertLoaded(t, "memory://gcs-key")
+	})
+	t.Run("AuthError", func(t *testing.T) {
+		denied := errors.Errorf(errors.CodeForbidden, "access denied")
+		driver := newDriver(map[string]error{"my-key": denied}, "memory://gcs-key")
+		defer registerMirrorDriver(driver)()
+		source, err := LoadWithFallbacks(ctx, nil, driver, fakeResources{}, newFallbackArtifact(&wfv1.ArtifactFallbacks{}), path, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "fallbacks.locations[0]", source.Location)
+		}
+	})
+	t.Run("StopOnAuthError", func(t *testing.T) {
+		denied := errors.Errorf(errors.CodeForbidden, "access denied")
+		driver := newDriver(map[string]error{"gcs-key": denied}, "memory://oss-key")
+		defer registerMirrorDriver(driver)()
+		_, err := LoadWithFallbacks(ctx, nil, driver, fakeResources{}, newFallbackArtifact(&wfv1.ArtifactFallbacks{StopOnAuthError: true}), path, nil)
+		var fallbackErr *FallbackError
+		if assert.True(t, stderrors.As(err, &fallbackErr)) && assert.Len(t, fallbackErr.Failures, 2) {
+			assert.Equal(t, "location", fallbackErr.Failures[0].Location)
+			assert.Equal(t, "fallbacks.locations[0]", fallbackErr.Failures[1].Location)
+		}
+		assert.True(t, errors.IsCode(errors.CodeForbidden, err))
+		// the locations after the one which does not authorize the artifact are not tried
+		assert.Equal(t, []string{"my-key", "gcs-key"}, driver.loaded)
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		driver := newDriver(nil)
+		defer registerMirrorDriver(driver)()
+		_, err := LoadWithFallbacks(ctx, nil, driver, fakeResources{}, newFallbackArtifact(&wfv1.ArtifactFallbacks{}), path, nil)
+		var fallbackErr *FallbackError
+		if assert.True(t, stderrors.As(err, &fallbackErr)) {
+			assert.Len(t, fallbackErr.Failures, 3)
+		}
+		assert.True(t, stderrors.Is(err, common.ErrArtifactNotFound))
+		// an optional artifact is skipped only if it is not found in any location
+		assert.True(t, errors.IsCode(errors.CodeNotFound, err))
+		assert.Equal(t, []string{"my-key", "gcs-key", "oss-key"}, driver.loaded)
+
+		driver = newDriver(map[string]error{"my-key": &quotaError{bucket: "my-bucket"}})
+		defer registerMirrorDriver(driver)()
+		_, err = LoadWithFallbacks(ctx, nil, driver, fakeResources{}, newFallbackArtifact(&wfv1.ArtifactFallbacks{}), path, nil)
+		assert.Error(t, err)
+		assert.False(t, errors.IsCode(errors.CodeNotFound, err))
+	})
+	t.Run("NoFallbacks", func(t *testing.T) {
+		driver := newDriver(nil, "memory://my-key")
+		art := &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "my-key"}}}
+		source, err := LoadWithFallbacks(ctx, nil, driver, fakeResources{}, art, path, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "location", source.Location)
+		}
+		assertLoaded(t, "memory://my-key")
+	})
+}
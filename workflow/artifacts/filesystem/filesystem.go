@@ -0,0 +1,211 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+// ArtifactDriver is the artifact driver of file systems mounted into the pod, e.g. EFS or NFS volumes, which copies
+// the files and directories of artifacts from and to their paths under their base paths
+type ArtifactDriver struct {
+	common.Logging
+}
+
+// ValidateArtifact validates the filesystem artifact, beyond what the general artifact validation checks
+func ValidateArtifact(errPrefix string, art *wfv1.FilesystemArtifact) error {
+	if !filepath.IsAbs(art.BasePath) {
+		return errors.Errorf(errors.CodeBadRequest, "%s.basePath must be an absolute path, e.g. /mnt/efs", errPrefix)
+	}
+	if _, err := relPath(art.Path); err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s.path %q must be a relative path under %s.basePath", errPrefix, art.Path, errPrefix)
+	}
+	return nil
+}
+
+// relPath returns the cleaned path of an artifact relative to its base path, which is an error if it is absolute, or
+// is the base path or outside of it
+func relPath(p string) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(p))
+	if p == "" || filepath.IsAbs(rel) || rel == "." || !within(".", rel) {
+		return "", errors.Errorf(errors.CodeBadRequest, "%q is not a relative path under the base path", p)
+	}
+	return rel, nil
+}
+
+// within returns whether the path p is dir, or is under it
+func within(dir, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolve returns the real path of the base path of the artifact, and the path of the artifact under it, in which the
+// symlinks of the directories it is under are evaluated, but not one it may itself be. As only the directories which
+// exist are evaluated, the path of an artifact which does not exist yet is where it would be created. It is an error
+// if the path is outside of the base path, e.g. because a symlink links outside of it.
+func resolve(art *wfv1.FilesystemArtifact) (base, path string, err error) {
+	rel, err := relPath(art.Path)
+	if err != nil {
+		return "", "", err
+	}
+	base, err = filepath.EvalSymlinks(art.BasePath)
+	if err != nil {
+		return "", "", err
+	}
+	dir, err := evalExisting(filepath.Dir(filepath.Join(base, rel)))
+	if err != nil {
+		return "", "", err
+	}
+	if !within(base, dir) {
+		return "", "", outsideError(art)
+	}
+	return base, filepath.Join(dir, filepath.Base(rel)), nil
+}
+
+// evalExisting returns the path with the symlinks of the part of it which exists evaluated
+func evalExisting(p string) (string, error) {
+	real, err := filepath.EvalSymlinks(p)
+	if !os.IsNotExist(err) {
+		return real, err
+	}
+	parent := filepath.Dir(p)
+	if parent == p {
+		return p, nil
+	}
+	realParent, err := evalExisting(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(realParent, filepath.Base(p)), nil
+}
+
+func outsideError(art *wfv1.FilesystemArtifact) error {
+	return errors.Errorf(errors.CodeForbidden, "%s is outside of the base path %s", art.Path, art.BasePath)
+}
+
+// Load copies the file or directory of the artifact to path, in a temporary file which only replaces path once it
+// is copied. Symlinks are followed, but only to files and directories under the base path.
+func (d *ArtifactDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, path string) error {
+	art := inputArtifact.Filesystem
+	d.Log().Infof("Filesystem Load path: %s, base path: %s, artifact path: %s", path, art.BasePath, art.Path)
+	base, src, err := resolve(art)
+	if err != nil {
+		return err
+	}
+	src, err = filepath.EvalSymlinks(src)
+	if os.IsNotExist(err) {
+		return common.NewNotFoundError(err)
+	} else if err != nil {
+		return err
+	}
+	if !within(base, src) {
+		return outsideError(art)
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	return common.LoadAtomically(path, func(path string) error {
+		if !info.IsDir() {
+			return copyFile(ctx, src, path, info.Mode())
+		}
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return err
+		}
+		return common.FileFilter{Symlinks: wfv1.ArtifactSymlinksFollow}.Walk(src, func(f common.WalkedFile) error {
+			real, err := filepath.EvalSymlinks(f.Path)
+			if err != nil {
+				return err
+			}
+			if !within(base, real) {
+				return errors.Errorf(errors.CodeForbidden, "%s links to %s, which is outside of the base path %s", f.Path, real, art.BasePath)
+			}
+			return copyFile(ctx, real, filepath.Join(path, filepath.FromSlash(f.RelPath)), f.Info.Mode())
+		})
+	})
+}
+
+// Save copies the file, or the files of the directory which match its filter, to the path of the artifact, in place
+// of anything there, by way of a temporary file next to it, which only replaces it once it is copied. Symlinks of
+// the directory are followed, skipped or preserved by the symlink policy of the artifact.
+func (d *ArtifactDriver) Save(ctx context.Context, path string, outputArtifact *wfv1.Artifact) error {
+	art := outputArtifact.Filesystem
+	d.Log().Infof("Filesystem Save path: %s, base path: %s, artifact path: %s", path, art.BasePath, art.Path)
+	_, dst, err := resolve(art)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	// the directories of the destination are created readable by the other pods sharing the file system
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return common.LoadAtomically(dst, func(tmp string) error {
+		if !info.IsDir() {
+			return copyFile(ctx, path, tmp, info.Mode())
+		}
+		if err := os.MkdirAll(tmp, 0755); err != nil {
+			return err
+		}
+		return common.NewFileFilter(outputArtifact).Walk(path, func(f common.WalkedFile) error {
+			target := filepath.Join(tmp, filepath.FromSlash(f.RelPath))
+			if f.LinkTarget != "" {
+				_, err := common.CreateSymlink(tmp, target, f.LinkTarget)
+				return err
+			}
+			return copyFile(ctx, f.Path, target, f.Info.Mode())
+		})
+	})
+}
+
+// copyFile copies the file src to dst with the permissions of mode, creating the directories dst is in
+func copyFile(ctx context.Context, src, dst string, mode os.FileMode) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("failed to copy %s to %s: %w", src, dst, err)
+	}
+	return out.Close()
+}
+
+// Delete removes the file or directory of the artifact, or the symlink at its path rather than what it links to
+func (d *ArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	art := artifact.Filesystem
+	d.Log().Infof("Filesystem Delete base path: %s, artifact path: %s", art.BasePath, art.Path)
+	_, p, err := resolve(art)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(p)
+}
+
+// Capabilities returns the optional operations on filesystem artifacts the driver supports
+func (d *ArtifactDriver) Capabilities() common.DriverCapabilities {
+	return common.DriverCapabilities{
+		Delete: true,
+	}
+}
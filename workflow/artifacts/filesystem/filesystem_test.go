@@ -0,0 +1,157 @@
+package filesystem
+
+import (
+	"context"
+	stderrors "errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+func newArtifact(basePath, path string) *wfv1.Artifact {
+	return &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{Filesystem: &wfv1.FilesystemArtifact{BasePath: basePath, Path: path}}}
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestArtifactDriver(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "filesystem")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	// the base path is that of the mounted file system, next to which are files it does not include
+	base := filepath.Join(tmp, "efs")
+	local := filepath.Join(tmp, "local")
+	outside := filepath.Join(tmp, "outside")
+	for _, dir := range []string{filepath.Join(base, "my-dir", "sub"), local, outside} {
+		assert.NoError(t, os.MkdirAll(dir, 0700))
+	}
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(base, "my-file"), []byte("my-data"), 0640))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(base, "my-dir", "a"), []byte("a"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(base, "my-dir", "sub", "b"), []byte("bb"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(outside, "secret"), []byte("my-secret"), 0600))
+	driver := &ArtifactDriver{}
+	ctx := context.Background()
+
+	t.Run("LoadFile", func(t *testing.T) {
+		path := filepath.Join(local, "load-file")
+		assert.NoError(t, driver.Load(ctx, newArtifact(base, "my-file"), path))
+		assert.Equal(t, "my-data", readFile(t, path))
+		info, err := os.Stat(path)
+		if assert.NoError(t, err) {
+			assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+		}
+	})
+	t.Run("LoadDirectory", func(t *testing.T) {
+		path := filepath.Join(local, "load-dir")
+		assert.NoError(t, driver.Load(ctx, newArtifact(base, "my-dir"), path))
+		assert.Equal(t, "a", readFile(t, filepath.Join(path, "a")))
+		assert.Equal(t, "bb", readFile(t, filepath.Join(path, "sub", "b")))
+	})
+	t.Run("LoadNotFound", func(t *testing.T) {
+		err := driver.Load(ctx, newArtifact(base, "missing"), filepath.Join(local, "missing"))
+		assert.True(t, stderrors.Is(err, common.ErrArtifactNotFound), err)
+	})
+	t.Run("SaveFile", func(t *testing.T) {
+		src := filepath.Join(local, "save-file")
+		assert.NoError(t, ioutil.WriteFile(src, []byte("saved"), 0600))
+		assert.NoError(t, driver.Save(ctx, src, newArtifact(base, "out/my-file")))
+		assert.Equal(t, "saved", readFile(t, filepath.Join(base, "out", "my-file")))
+		// what was there is replaced
+		assert.NoError(t, ioutil.WriteFile(src, []byte("saved again"), 0600))
+		assert.NoError(t, driver.Save(ctx, src, newArtifact(base, "out/my-file")))
+		assert.Equal(t, "saved again", readFile(t, filepath.Join(base, "out", "my-file")))
+		files, err := ioutil.ReadDir(filepath.Join(base, "out"))
+		if assert.NoError(t, err) {
+			// the temporary files are removed
+			assert.Len(t, files, 1)
+		}
+	})
+	t.Run("SaveDirectory", func(t *testing.T) {
+		src := filepath.Join(local, "save-dir")
+		assert.NoError(t, os.MkdirAll(filepath.Join(src, "sub"), 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0600))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "sub", "b.log"), []byte("bb"), 0600))
+		art := newArtifact(base, "out/my-dir")
+		art.Exclude = []string{"**/*.log"}
+		assert.NoError(t, driver.Save(ctx, src, art))
+		assert.Equal(t, "a", readFile(t, filepath.Join(base, "out", "my-dir", "a.txt")))
+		_, err := os.Stat(filepath.Join(base, "out", "my-dir", "sub", "b.log"))
+		assert.True(t, os.IsNotExist(err))
+		// and what is saved is loaded back
+		path := filepath.Join(local, "load-saved-dir")
+		assert.NoError(t, driver.Load(ctx, art, path))
+		assert.Equal(t, "a", readFile(t, filepath.Join(path, "a.txt")))
+	})
+	t.Run("Delete", func(t *testing.T) {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(base, "deleted"), []byte("deleted"), 0600))
+		assert.NoError(t, driver.Delete(newArtifact(base, "deleted")))
+		_, err := os.Stat(filepath.Join(base, "deleted"))
+		assert.True(t, os.IsNotExist(err))
+	})
+	t.Run("Traversal", func(t *testing.T) {
+		path := filepath.Join(local, "traversal")
+		for _, p := range []string{"../outside/secret", "my-dir/../../outside/secret", "/etc/passwd", ".", ""} {
+			err := driver.Load(ctx, newArtifact(base, p), path)
+			assert.True(t, errors.IsCode(errors.CodeBadRequest, err), "%s: %v", p, err)
+		}
+		src := filepath.Join(local, "save-file")
+		err := driver.Save(ctx, src, newArtifact(base, "../outside/saved"))
+		assert.True(t, errors.IsCode(errors.CodeBadRequest, err), err)
+		_, err = os.Stat(filepath.Join(outside, "saved"))
+		assert.True(t, os.IsNotExist(err))
+		assert.Error(t, driver.Delete(newArtifact(base, "../outside/secret")))
+		assert.FileExists(t, filepath.Join(outside, "secret"))
+	})
+	t.Run("Symlinks", func(t *testing.T) {
+		assert.NoError(t, os.Symlink(outside, filepath.Join(base, "out-link")))
+		assert.NoError(t, os.Symlink(filepath.Join(outside, "secret"), filepath.Join(base, "my-dir", "secret-link")))
+		assert.NoError(t, os.Symlink("a", filepath.Join(base, "my-dir", "a-link")))
+		defer func() { _ = os.Remove(filepath.Join(base, "my-dir", "secret-link")) }()
+		// a symlink cannot lead outside of the base path, whether it is the artifact, or a directory it is under
+		err := driver.Load(ctx, newArtifact(base, "out-link"), filepath.Join(local, "out-link"))
+		assert.True(t, errors.IsCode(errors.CodeForbidden, err), err)
+		err = driver.Load(ctx, newArtifact(base, "out-link/secret"), filepath.Join(local, "out-link-secret"))
+		assert.True(t, errors.IsCode(errors.CodeForbidden, err), err)
+		src := filepath.Join(local, "save-file")
+		err = driver.Save(ctx, src, newArtifact(base, "out-link/saved"))
+		assert.True(t, errors.IsCode(errors.CodeForbidden, err), err)
+		_, err = os.Stat(filepath.Join(outside, "saved"))
+		assert.True(t, os.IsNotExist(err))
+		// nor can one under a directory which is loaded
+		err = driver.Load(ctx, newArtifact(base, "my-dir"), filepath.Join(local, "secret-dir"))
+		assert.True(t, errors.IsCode(errors.CodeForbidden, err), err)
+		_, err = os.Stat(filepath.Join(local, "secret-dir"))
+		assert.True(t, os.IsNotExist(err))
+		// but one which links to a file under the base path is followed
+		assert.NoError(t, os.Remove(filepath.Join(base, "my-dir", "secret-link")))
+		path := filepath.Join(local, "linked-dir")
+		assert.NoError(t, driver.Load(ctx, newArtifact(base, "my-dir"), path))
+		assert.Equal(t, "a", readFile(t, filepath.Join(path, "a-link")))
+		// and deleting a symlink deletes it, not what it links to
+		assert.NoError(t, driver.Delete(newArtifact(base, "out-link")))
+		assert.FileExists(t, filepath.Join(outside, "secret"))
+	})
+}
+
+func TestValidateArtifact(t *testing.T) {
+	assert.NoError(t, ValidateArtifact("inputs.artifacts.my-art.filesystem", &wfv1.FilesystemArtifact{BasePath: "/mnt/efs", Path: "my-dir/my-file"}))
+	assert.EqualError(t, ValidateArtifact("inputs.artifacts.my-art.filesystem", &wfv1.FilesystemArtifact{BasePath: "mnt/efs", Path: "my-file"}),
+		"inputs.artifacts.my-art.filesystem.basePath must be an absolute path, e.g. /mnt/efs")
+	for _, p := range []string{"", ".", "/my-file", "../my-file", "my-dir/../../my-file"} {
+		assert.EqualError(t, ValidateArtifact("inputs.artifacts.my-art.filesystem", &wfv1.FilesystemArtifact{BasePath: "/mnt/efs", Path: p}),
+			`inputs.artifacts.my-art.filesystem.path "`+p+`" must be a relative path under inputs.artifacts.my-art.filesystem.basePath`)
+	}
+}
@@ -0,0 +1,319 @@
+package ftp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPort = "21"
+	// the time a server in active mode has to connect to the client
+	acceptTimeout = 30 * time.Second
+)
+
+// conn is the control connection of an FTP session
+type conn struct {
+	ctx     context.Context
+	netConn net.Conn
+	text    *textproto.Conn
+	// tlsConfig protects the data connections of FTPS, and is nil for FTP
+	tlsConfig *tls.Config
+	active    bool
+	// home is the directory the user logged in to, or "" if the server did not reply to PWD
+	home string
+	// stop stops the closing of the connection when the context of the session is done
+	stop chan struct{}
+}
+
+// dial connects and logs in to the server of the URL. FTPS URLs upgrade the control connection to TLS with
+// AUTH TLS before logging in, and protect the data connections too. The connection is closed when ctx is done.
+func (d *ArtifactDriver) dial(ctx context.Context, u *url.URL) (*conn, error) {
+	if u.Scheme != "ftp" && u.Scheme != "ftps" {
+		return nil, fmt.Errorf("unsupported scheme %q, must be ftp or ftps", u.Scheme)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+	var dialer net.Dialer
+	netConn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{ctx: ctx, netConn: netConn, text: textproto.NewConn(netConn), active: d.ActiveMode, stop: make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = netConn.Close()
+		case <-c.stop:
+		}
+	}()
+	if err := c.login(u, d); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *conn) login(u *url.URL, d *ArtifactDriver) error {
+	if _, _, err := c.text.ReadResponse(2); err != nil {
+		return err
+	}
+	if u.Scheme == "ftps" {
+		if _, err := c.cmd(2, "AUTH TLS"); err != nil {
+			return err
+		}
+		c.tlsConfig = &tls.Config{
+			ServerName:         u.Hostname(),
+			RootCAs:            d.RootCAs,
+			InsecureSkipVerify: d.InsecureSkipVerify,
+			// servers commonly require the TLS sessions of data connections to resume that of the control connection
+			ClientSessionCache: tls.NewLRUClientSessionCache(0),
+		}
+		tlsConn := tls.Client(c.netConn, c.tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			return err
+		}
+		c.netConn = tlsConn
+		c.text = textproto.NewConn(tlsConn)
+		if _, err := c.cmd(2, "PBSZ 0"); err != nil {
+			return err
+		}
+		if _, err := c.cmd(2, "PROT P"); err != nil {
+			return err
+		}
+	}
+	username, password := d.Username, d.Password
+	if username == "" {
+		username, password = "anonymous", "anonymous@"
+	}
+	code, err := c.cmd(0, "USER %s", username)
+	if err != nil {
+		return err
+	}
+	// servers which need no password respond with 230 User logged in
+	if code/100 == 3 {
+		if _, err := c.cmd(2, "PASS %s", password); err != nil {
+			return err
+		}
+	} else if code/100 != 2 {
+		return &textproto.Error{Code: code, Msg: "USER failed"}
+	}
+	if _, err := c.cmd(2, "TYPE I"); err != nil {
+		return err
+	}
+	c.home = c.pwd()
+	return nil
+}
+
+// pwd returns the current directory, e.g. /home/me of the reply 257 "/home/me" is the current directory, in which
+// quotes are doubled, or "" if it cannot be found out
+func (c *conn) pwd() string {
+	if err := c.text.PrintfLine("PWD"); err != nil {
+		return ""
+	}
+	_, msg, err := c.text.ReadResponse(257)
+	if err != nil || !strings.HasPrefix(msg, `"`) {
+		return ""
+	}
+	var dir strings.Builder
+	for i := 1; i < len(msg); i++ {
+		if msg[i] == '"' {
+			if i+1 < len(msg) && msg[i+1] == '"' {
+				dir.WriteByte('"')
+				i++
+				continue
+			}
+			return dir.String()
+		}
+		dir.WriteByte(msg[i])
+	}
+	return ""
+}
+
+// abs returns the absolute path of a path relative to the directory the user logged in to, so that paths do
+// not depend on the current directory, which CWD changes
+func (c *conn) abs(p string) string {
+	if path.IsAbs(p) {
+		return p
+	}
+	if c.home == "" {
+		if p == "" {
+			return "."
+		}
+		return p
+	}
+	return path.Join(c.home, p)
+}
+
+// cmd sends a command, and reads its response, which must have a code starting with the digits of expectCode,
+// unless it is zero
+func (c *conn) cmd(expectCode int, format string, args ...interface{}) (int, error) {
+	if err := c.text.PrintfLine(format, args...); err != nil {
+		return 0, err
+	}
+	code, _, err := c.text.ReadResponse(expectCode)
+	return code, err
+}
+
+// Close ends the session
+func (c *conn) Close() error {
+	close(c.stop)
+	_, _ = c.cmd(0, "QUIT")
+	return c.netConn.Close()
+}
+
+// transfer sends a command which transfers data, e.g. RETR, and calls fn with its data connection, then reads
+// the response to the end of the transfer. The data connection is closed when the context of the session is done.
+func (c *conn) transfer(fn func(io.ReadWriter) error, format string, args ...interface{}) error {
+	var dataConn net.Conn
+	var listener net.Listener
+	var err error
+	if c.active {
+		listener, err = c.port()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = listener.Close() }()
+	} else {
+		dataConn, err = c.passive()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = dataConn.Close() }()
+	}
+	if _, err := c.cmd(1, format, args...); err != nil {
+		return err
+	}
+	if listener != nil {
+		_ = listener.(*net.TCPListener).SetDeadline(time.Now().Add(acceptTimeout))
+		dataConn, err = listener.Accept()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = dataConn.Close() }()
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func(rawConn net.Conn) {
+		select {
+		case <-c.ctx.Done():
+			_ = rawConn.Close()
+		case <-done:
+		}
+	}(dataConn)
+	if c.tlsConfig != nil {
+		tlsConn := tls.Client(dataConn, c.tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			return err
+		}
+		dataConn = tlsConn
+	}
+	err = fn(dataConn)
+	// the server ends the transfer once the data connection is closed
+	if closeErr := dataConn.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	_, _, err = c.text.ReadResponse(2)
+	return err
+}
+
+// passive opens a data connection to the server, with EPSV, or PASV if the server does not support it
+func (c *conn) passive() (net.Conn, error) {
+	host, _, err := net.SplitHostPort(c.netConn.RemoteAddr().String())
+	if err != nil {
+		return nil, err
+	}
+	port, err := c.epsv()
+	if err != nil {
+		port, err = c.pasv()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return net.DialTimeout("tcp", net.JoinHostPort(host, port), acceptTimeout)
+}
+
+// epsv returns the port of the response to EPSV, e.g. "229 Entering Extended Passive Mode (|||6446|)"
+func (c *conn) epsv() (string, error) {
+	if err := c.text.PrintfLine("EPSV"); err != nil {
+		return "", err
+	}
+	_, msg, err := c.text.ReadResponse(229)
+	if err != nil {
+		return "", err
+	}
+	start, end := strings.Index(msg, "("), strings.LastIndex(msg, ")")
+	if start < 0 || end < start {
+		return "", fmt.Errorf("invalid EPSV response %q", msg)
+	}
+	fields := strings.Split(msg[start+1:end], "|")
+	if len(fields) != 5 || fields[3] == "" {
+		return "", fmt.Errorf("invalid EPSV response %q", msg)
+	}
+	return fields[3], nil
+}
+
+// pasv returns the port of the response to PASV, e.g. "227 Entering Passive Mode (192,168,0,1,25,46)". The
+// address is ignored in favour of that of the control connection, which is reachable, unlike the private
+// address some servers behind NAT respond with.
+func (c *conn) pasv() (string, error) {
+	if err := c.text.PrintfLine("PASV"); err != nil {
+		return "", err
+	}
+	_, msg, err := c.text.ReadResponse(227)
+	if err != nil {
+		return "", err
+	}
+	start := strings.Index(msg, "(")
+	end := strings.LastIndex(msg, ")")
+	if start < 0 || end < start {
+		return "", fmt.Errorf("invalid PASV response %q", msg)
+	}
+	fields := strings.Split(msg[start+1:end], ",")
+	if len(fields) != 6 {
+		return "", fmt.Errorf("invalid PASV response %q", msg)
+	}
+	high, err1 := strconv.Atoi(strings.TrimSpace(fields[4]))
+	low, err2 := strconv.Atoi(strings.TrimSpace(fields[5]))
+	if err1 != nil || err2 != nil {
+		return "", fmt.Errorf("invalid PASV response %q", msg)
+	}
+	return strconv.Itoa(high<<8 | low), nil
+}
+
+// port listens for the data connection of the server in active mode, on the address of the control connection,
+// and sends it with PORT, or EPRT for IPv6
+func (c *conn) port() (net.Listener, error) {
+	host, _, err := net.SplitHostPort(c.netConn.LocalAddr().String())
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return nil, err
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	if ip := net.ParseIP(host).To4(); ip != nil {
+		_, err = c.cmd(2, "PORT %d,%d,%d,%d,%d,%d", ip[0], ip[1], ip[2], ip[3], port>>8, port&0xff)
+	} else {
+		_, err = c.cmd(2, "EPRT |2|%s|%d|", host, port)
+	}
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
@@ -0,0 +1,386 @@
+package ftp
+
+import (
+	"bufio"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/argoproj/pkg/file"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+// ArtifactDriver is the artifact driver for an FTP or FTPS server
+type ArtifactDriver struct {
+	// Username and Password log in to the server, which is logged in to anonymously if Username is not set
+	Username string
+	Password string
+	// ActiveMode has the server connect to the client for transfers, rather than the client to the server
+	ActiveMode bool
+	// RootCAs verify the TLS connections of FTPS, if set, rather than the system CA certificates
+	RootCAs *x509.CertPool
+	// InsecureSkipVerify skips the verification of the TLS certificate of an FTPS server
+	InsecureSkipVerify bool
+	common.Logging
+}
+
+const (
+	// the codes of the replies to commands on files which are not found, or that the server does not implement
+	codeFileUnavailable     = 550
+	codeNotImplemented      = 502
+	codeSyntaxError         = 500
+	codeParamNotImplemented = 504
+)
+
+// isCode returns whether the error is a reply of the server with the code
+func isCode(err error, code int) bool {
+	protoErr, ok := err.(*textproto.Error)
+	return ok && protoErr.Code == code
+}
+
+// notFoundError returns the error as an artifact not found error if the server replied that the file is unavailable
+func notFoundError(err error, command, p string) error {
+	if isCode(err, codeFileUnavailable) {
+		return common.NewNotFoundError(fmt.Errorf("FTP %s %s failed: %v", command, p, err))
+	}
+	return fmt.Errorf("FTP %s %s failed: %v", command, p, err)
+}
+
+// parseURL returns the URL of the artifact, and its path on the server. As in RFC 1738, the path is relative to
+// the directory the user logs in to, unless it starts with an escaped slash (%2F).
+func parseURL(rawURL string) (*url.URL, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+	return u, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// entry is a file or directory listed in a directory
+type entry struct {
+	name string
+	dir  bool
+}
+
+// list lists the files and directories of a directory, with MLSD, or LIST if the server does not implement it.
+// Links, and the directory and its parent, are not listed.
+func (c *conn) list(dir string) ([]entry, error) {
+	command := "MLSD"
+	entries, err := c.listWith(command, dir, parseMLSDLine)
+	if isCode(err, codeSyntaxError) || isCode(err, codeNotImplemented) || isCode(err, codeParamNotImplemented) {
+		command = "LIST"
+		entries, err = c.listWith(command, dir, parseLISTLine)
+	}
+	if err != nil {
+		return nil, notFoundError(err, command, dir)
+	}
+	return entries, nil
+}
+
+func (c *conn) listWith(command, dir string, parse func(string) (entry, bool)) ([]entry, error) {
+	var entries []entry
+	err := c.transfer(func(data io.ReadWriter) error {
+		scanner := bufio.NewScanner(data)
+		for scanner.Scan() {
+			if e, ok := parse(strings.TrimRight(scanner.Text(), "\r")); ok {
+				entries = append(entries, e)
+			}
+		}
+		return scanner.Err()
+	}, "%s %s", command, dir)
+	return entries, err
+}
+
+// parseMLSDLine parses a line of the machine readable listing of RFC 3659, e.g. "type=file;size=10; my-file"
+func parseMLSDLine(line string) (entry, bool) {
+	i := strings.Index(line, " ")
+	if i < 0 {
+		return entry{}, false
+	}
+	facts, name := line[:i], line[i+1:]
+	for _, fact := range strings.Split(facts, ";") {
+		kv := strings.SplitN(fact, "=", 2)
+		if len(kv) != 2 || !strings.EqualFold(kv[0], "type") {
+			continue
+		}
+		switch strings.ToLower(kv[1]) {
+		case "file":
+			return entry{name: name}, true
+		case "dir":
+			return entry{name: name, dir: true}, true
+		}
+	}
+	// the directory itself, its parent, and links
+	return entry{}, false
+}
+
+// parseLISTLine parses a line of a Unix style listing, e.g. "-rw-r--r-- 1 owner group 10 Jan 01 00:00 my-file"
+func parseLISTLine(line string) (entry, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 9 {
+		return entry{}, false
+	}
+	// the name follows the eighth field, and may contain spaces
+	name := line
+	for i := 0; i < 8; i++ {
+		name = strings.TrimLeft(name, " ")
+		name = name[strings.Index(name, " "):]
+	}
+	name = strings.TrimLeft(name, " ")
+	if name == "." || name == ".." {
+		return entry{}, false
+	}
+	switch line[0] {
+	case '-':
+		return entry{name: name}, true
+	case 'd':
+		return entry{name: name, dir: true}, true
+	}
+	return entry{}, false
+}
+
+// isDir returns whether the path is a directory, which the server can change to
+func (c *conn) isDir(p string) (bool, error) {
+	_, err := c.cmd(2, "CWD %s", p)
+	if err == nil {
+		return true, nil
+	}
+	if isCode(err, codeFileUnavailable) {
+		return false, nil
+	}
+	return false, err
+}
+
+// Load downloads a file from an FTP server. Directories are downloaded recursively.
+func (d *ArtifactDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, localPath string) error {
+	d.Log().Infof("FTP Load path: %s, url: %s", localPath, common.RedactURL(inputArtifact.FTP.URL))
+	u, p, err := parseURL(inputArtifact.FTP.URL)
+	if err != nil {
+		return err
+	}
+	c, err := d.dial(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = c.Close() }()
+	p = c.abs(p)
+	isDir, err := c.isDir(p)
+	if err != nil {
+		return err
+	}
+	if isDir {
+		return c.loadDir(p, localPath)
+	}
+	return c.retrieve(p, localPath)
+}
+
+func (c *conn) loadDir(dir, localPath string) error {
+	if err := os.MkdirAll(localPath, 0700); err != nil {
+		return err
+	}
+	entries, err := c.list(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		// names are never paths, but a malicious server could send one
+		if strings.ContainsAny(e.name, "/\\") {
+			continue
+		}
+		dest := filepath.Join(localPath, e.name)
+		if e.dir {
+			err = c.loadDir(path.Join(dir, e.name), dest)
+		} else {
+			err = c.retrieve(path.Join(dir, e.name), dest)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *conn) retrieve(p, localPath string) error {
+	out, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("create %s: %v", localPath, err)
+	}
+	defer func() { _ = out.Close() }()
+	err = c.transfer(func(data io.ReadWriter) error {
+		_, err := io.Copy(out, data)
+		return err
+	}, "RETR %s", p)
+	if err != nil {
+		_ = out.Close()
+		_ = os.Remove(localPath)
+		return notFoundError(err, "RETR", p)
+	}
+	return out.Close()
+}
+
+// Save uploads a file or directory to an FTP server, creating any missing parent directories
+func (d *ArtifactDriver) Save(ctx context.Context, localPath string, outputArtifact *wfv1.Artifact) error {
+	d.Log().Infof("FTP Save path: %s, url: %s", localPath, common.RedactURL(outputArtifact.FTP.URL))
+	u, p, err := parseURL(outputArtifact.FTP.URL)
+	if err != nil {
+		return err
+	}
+	isDir, err := file.IsDirectory(localPath)
+	if err != nil {
+		return err
+	}
+	c, err := d.dial(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = c.Close() }()
+	p = c.abs(p)
+	if !isDir {
+		if err := c.mkdirAll(path.Dir(p)); err != nil {
+			return err
+		}
+		return c.store(localPath, p)
+	}
+	return filepath.Walk(localPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localPath, filePath)
+		if err != nil {
+			return err
+		}
+		dest := path.Join(p, filepath.ToSlash(rel))
+		if info.IsDir() {
+			return c.mkdirAll(dest)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return c.store(filePath, dest)
+	})
+}
+
+// mkdirAll creates the directory, and its missing parents. It is not an error if it exists.
+func (c *conn) mkdirAll(dir string) error {
+	if dir == "." || dir == "/" {
+		return nil
+	}
+	isDir, err := c.isDir(dir)
+	if err != nil || isDir {
+		return err
+	}
+	if err := c.mkdirAll(path.Dir(dir)); err != nil {
+		return err
+	}
+	if _, err := c.cmd(2, "MKD %s", dir); err != nil {
+		return fmt.Errorf("FTP MKD %s failed: %v", dir, err)
+	}
+	return nil
+}
+
+func (c *conn) store(localPath, p string) error {
+	in, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	err = c.transfer(func(data io.ReadWriter) error {
+		_, err := io.Copy(data, in)
+		return err
+	}, "STOR %s", p)
+	if err != nil {
+		return fmt.Errorf("FTP STOR %s failed: %v", p, err)
+	}
+	return nil
+}
+
+// Exists returns whether the file or directory exists
+func (d *ArtifactDriver) Exists(artifact *wfv1.Artifact) (bool, error) {
+	u, p, err := parseURL(artifact.FTP.URL)
+	if err != nil {
+		return false, err
+	}
+	c, err := d.dial(context.Background(), u)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = c.Close() }()
+	p = c.abs(p)
+	_, err = c.cmd(2, "SIZE %s", p)
+	if err == nil {
+		return true, nil
+	}
+	if !isCode(err, codeFileUnavailable) && !isCode(err, codeSyntaxError) && !isCode(err, codeNotImplemented) {
+		return false, err
+	}
+	// SIZE is not implemented, or the path is not a file
+	return c.isDir(p)
+}
+
+// Delete removes a file or directory, with its files, from an FTP server. It is not an error if it does not exist.
+func (d *ArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	d.Log().Infof("FTP Delete url: %s", common.RedactURL(artifact.FTP.URL))
+	u, p, err := parseURL(artifact.FTP.URL)
+	if err != nil {
+		return err
+	}
+	c, err := d.dial(context.Background(), u)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = c.Close() }()
+	p = c.abs(p)
+	isDir, err := c.isDir(p)
+	if err != nil {
+		return err
+	}
+	if isDir {
+		return c.removeAll(p)
+	}
+	if _, err := c.cmd(2, "DELE %s", p); err != nil && !isCode(err, codeFileUnavailable) {
+		return fmt.Errorf("FTP DELE %s failed: %v", p, err)
+	}
+	return nil
+}
+
+// Capabilities returns the optional operations on FTP artifacts the driver supports
+func (d *ArtifactDriver) Capabilities() common.DriverCapabilities {
+	return common.DriverCapabilities{
+		Delete: true,
+		Exists: true,
+	}
+}
+
+func (c *conn) removeAll(dir string) error {
+	entries, err := c.list(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if strings.ContainsAny(e.name, "/\\") {
+			continue
+		}
+		p := path.Join(dir, e.name)
+		if e.dir {
+			err = c.removeAll(p)
+		} else if _, err = c.cmd(2, "DELE %s", p); err != nil {
+			err = fmt.Errorf("FTP DELE %s failed: %v", p, err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := c.cmd(2, "RMD %s", dir); err != nil {
+		return fmt.Errorf("FTP RMD %s failed: %v", dir, err)
+	}
+	return nil
+}
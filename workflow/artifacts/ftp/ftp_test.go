@@ -0,0 +1,525 @@
+package ftp
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/textproto"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+// server is an in-process FTP server of a directory, which supports the commands the driver sends, in passive
+// and active mode, and explicit FTPS if tlsConfig is set
+type server struct {
+	root      string
+	listener  net.Listener
+	tlsConfig *tls.Config
+	// noMLSD and noEPSV reply that the commands are not implemented, like older servers
+	noMLSD bool
+	noEPSV bool
+
+	lock     sync.Mutex
+	commands []string
+}
+
+func newServer(t *testing.T, configure func(*server)) *server {
+	root, err := ioutil.TempDir("", "ftp-server")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	s := &server{root: root, listener: listener}
+	if configure != nil {
+		configure(s)
+	}
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *server) Close() {
+	_ = s.listener.Close()
+	_ = os.RemoveAll(s.root)
+}
+
+// url returns the URL of the path on the server
+func (s *server) url(scheme, p string) string {
+	return fmt.Sprintf("%s://%s/%s", scheme, s.listener.Addr(), p)
+}
+
+// sent returns whether the command was sent to the server
+func (s *server) sent(command string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, c := range s.commands {
+		if c == command {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *server) local(cwd, p string) string {
+	if !path.IsAbs(p) {
+		p = path.Join(cwd, p)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(path.Clean(p)))
+}
+
+func (s *server) serve(nc net.Conn) {
+	defer func() { _ = nc.Close() }()
+	text := textproto.NewConn(nc)
+	reply := func(code int, format string, args ...interface{}) {
+		_ = text.PrintfLine("%d %s", code, fmt.Sprintf(format, args...))
+	}
+	reply(220, "ready")
+	cwd := "/"
+	var user string
+	loggedIn, protected := false, false
+	var passive net.Listener
+	var activeAddr string
+	// openData opens the data connection of a transfer, which is closed once all the data is sent or received
+	openData := func() (net.Conn, error) {
+		var c net.Conn
+		var err error
+		if passive != nil {
+			c, err = passive.Accept()
+			_ = passive.Close()
+			passive = nil
+		} else {
+			c, err = net.Dial("tcp", activeAddr)
+		}
+		if err != nil || !protected {
+			return c, err
+		}
+		return tls.Server(c, s.tlsConfig), nil
+	}
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		parts := strings.SplitN(line, " ", 2)
+		command, arg := strings.ToUpper(parts[0]), ""
+		if len(parts) == 2 {
+			arg = parts[1]
+		}
+		s.lock.Lock()
+		s.commands = append(s.commands, command)
+		s.lock.Unlock()
+		switch {
+		case command == "AUTH" && s.tlsConfig != nil:
+			reply(234, "AUTH TLS ok")
+			tlsConn := tls.Server(nc, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			nc = tlsConn
+			text = textproto.NewConn(tlsConn)
+			continue
+		case command == "PBSZ":
+			reply(200, "PBSZ=0")
+			continue
+		case command == "PROT":
+			protected = arg == "P"
+			reply(200, "ok")
+			continue
+		case command == "USER":
+			user = arg
+			reply(331, "password required")
+			continue
+		case command == "PASS":
+			if user == "anonymous" || (user == "my-username" && arg == "my-password") {
+				loggedIn = true
+				reply(230, "logged in")
+			} else {
+				reply(530, "login incorrect")
+			}
+			continue
+		case command == "QUIT":
+			reply(221, "bye")
+			return
+		case !loggedIn:
+			reply(530, "not logged in")
+			continue
+		}
+		switch command {
+		case "TYPE":
+			reply(200, "ok")
+		case "PWD":
+			reply(257, `"%s" is the current directory`, cwd)
+		case "CWD":
+			if info, err := os.Stat(s.local(cwd, arg)); err == nil && info.IsDir() {
+				cwd = path.Join(cwd, arg)
+				if path.IsAbs(arg) {
+					cwd = path.Clean(arg)
+				}
+				reply(250, "ok")
+			} else {
+				reply(550, "not a directory")
+			}
+		case "EPSV", "PASV":
+			if command == "EPSV" && s.noEPSV {
+				reply(502, "not implemented")
+				continue
+			}
+			passive, err = net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				reply(425, "%v", err)
+				continue
+			}
+			port := passive.Addr().(*net.TCPAddr).Port
+			if command == "EPSV" {
+				reply(229, "Entering Extended Passive Mode (|||%d|)", port)
+			} else {
+				reply(227, "Entering Passive Mode (127,0,0,1,%d,%d)", port>>8, port&0xff)
+			}
+		case "PORT":
+			fields := strings.Split(arg, ",")
+			high, _ := strconv.Atoi(fields[4])
+			low, _ := strconv.Atoi(fields[5])
+			activeAddr = net.JoinHostPort(strings.Join(fields[:4], "."), strconv.Itoa(high<<8|low))
+			reply(200, "ok")
+		case "SIZE":
+			if info, err := os.Stat(s.local(cwd, arg)); err == nil && info.Mode().IsRegular() {
+				reply(213, "%d", info.Size())
+			} else {
+				reply(550, "not a file")
+			}
+		case "MKD":
+			if err := os.Mkdir(s.local(cwd, arg), 0700); err != nil {
+				reply(550, "%v", err)
+			} else {
+				reply(257, `"%s" created`, arg)
+			}
+		case "RMD", "DELE":
+			if err := os.Remove(s.local(cwd, arg)); err != nil {
+				reply(550, "%v", err)
+			} else {
+				reply(250, "ok")
+			}
+		case "RETR":
+			f, err := os.Open(s.local(cwd, arg))
+			if err != nil {
+				reply(550, "%v", err)
+				continue
+			}
+			reply(150, "opening data connection")
+			if data, err := openData(); err == nil {
+				_, _ = io.Copy(data, f)
+				_ = data.Close()
+			}
+			_ = f.Close()
+			reply(226, "transfer complete")
+		case "STOR":
+			f, err := os.Create(s.local(cwd, arg))
+			if err != nil {
+				reply(550, "%v", err)
+				continue
+			}
+			reply(150, "opening data connection")
+			if data, err := openData(); err == nil {
+				_, _ = io.Copy(f, data)
+				_ = data.Close()
+			}
+			_ = f.Close()
+			reply(226, "transfer complete")
+		case "MLSD", "LIST":
+			if command == "MLSD" && s.noMLSD {
+				reply(500, "unknown command")
+				continue
+			}
+			infos, err := ioutil.ReadDir(s.local(cwd, arg))
+			if err != nil {
+				reply(550, "%v", err)
+				continue
+			}
+			reply(150, "opening data connection")
+			data, err := openData()
+			if err != nil {
+				continue
+			}
+			if command == "MLSD" {
+				_, _ = fmt.Fprintf(data, "type=cdir;perm=el; .\r\ntype=pdir;perm=el; ..\r\n")
+			} else {
+				_, _ = fmt.Fprintf(data, "drwxr-xr-x 2 ftp ftp 4096 Jan 01 00:00 .\r\ndrwxr-xr-x 2 ftp ftp 4096 Jan 01 00:00 ..\r\n")
+			}
+			for _, info := range infos {
+				if command == "MLSD" {
+					kind := "file"
+					if info.IsDir() {
+						kind = "dir"
+					}
+					_, _ = fmt.Fprintf(data, "type=%s;size=%d; %s\r\n", kind, info.Size(), info.Name())
+				} else {
+					mode := "-rw-r--r--"
+					if info.IsDir() {
+						mode = "drwxr-xr-x"
+					}
+					_, _ = fmt.Fprintf(data, "%s 1 ftp ftp %d Jan 01 00:00 %s\r\n", mode, info.Size(), info.Name())
+				}
+			}
+			_ = data.Close()
+			reply(226, "transfer complete")
+		default:
+			reply(502, "not implemented")
+		}
+	}
+}
+
+// newCertificate returns a self-signed certificate of 127.0.0.1, and a pool which trusts it
+func newCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	cert, err := x509.ParseCertificate(der)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func artifact(u string) *wfv1.Artifact {
+	return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{FTP: &wfv1.FTPArtifact{URL: u}}}
+}
+
+// newLocalDirectory creates a directory with a file, and a file in a sub-directory
+func newLocalDirectory(t *testing.T, tmp string) string {
+	dir := filepath.Join(tmp, "src-dir")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "b"), []byte("b"), 0600))
+	return dir
+}
+
+// assertLocalDirectory asserts that the directory has the files of newLocalDirectory
+func assertLocalDirectory(t *testing.T, dir string) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "a"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(data))
+	data, err = ioutil.ReadFile(filepath.Join(dir, "sub", "b"))
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(data))
+}
+
+func TestArtifactDriver(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ftp")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+	ctx := context.Background()
+	d := &ArtifactDriver{Username: "my-username", Password: "my-password"}
+
+	t.Run("File", func(t *testing.T) {
+		s := newServer(t, nil)
+		defer s.Close()
+		src := filepath.Join(tmp, "src-file")
+		assert.NoError(t, ioutil.WriteFile(src, []byte("my-data"), 0600))
+		// the parent directories do not exist yet
+		art := artifact(s.url("ftp", "my-parent/my-sub/my-file"))
+		assert.NoError(t, d.Save(ctx, src, art))
+		dest := filepath.Join(tmp, "dest-file")
+		if assert.NoError(t, d.Load(ctx, art, dest)) {
+			data, err := ioutil.ReadFile(dest)
+			assert.NoError(t, err)
+			assert.Equal(t, "my-data", string(data))
+		}
+		ok, err := d.Exists(art)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.NoError(t, d.Delete(art))
+		ok, err = d.Exists(art)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+	t.Run("Directory", func(t *testing.T) {
+		s := newServer(t, nil)
+		defer s.Close()
+		art := artifact(s.url("ftp", "my-dir"))
+		assert.NoError(t, d.Save(ctx, newLocalDirectory(t, tmp), art))
+		dest, err := ioutil.TempDir(tmp, "dest-dir")
+		assert.NoError(t, err)
+		if assert.NoError(t, d.Load(ctx, art, dest)) {
+			assertLocalDirectory(t, dest)
+		}
+		assert.True(t, s.sent("MLSD"))
+		ok, err := d.Exists(art)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.NoError(t, d.Delete(art))
+		ok, err = d.Exists(art)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+	t.Run("LIST", func(t *testing.T) {
+		// neither MLSD nor EPSV is implemented
+		s := newServer(t, func(s *server) {
+			s.noMLSD = true
+			s.noEPSV = true
+		})
+		defer s.Close()
+		art := artifact(s.url("ftp", "my-dir"))
+		assert.NoError(t, d.Save(ctx, newLocalDirectory(t, tmp), art))
+		dest, err := ioutil.TempDir(tmp, "dest-dir")
+		assert.NoError(t, err)
+		if assert.NoError(t, d.Load(ctx, art, dest)) {
+			assertLocalDirectory(t, dest)
+		}
+		assert.True(t, s.sent("LIST"))
+		assert.True(t, s.sent("PASV"))
+	})
+	t.Run("ActiveMode", func(t *testing.T) {
+		s := newServer(t, nil)
+		defer s.Close()
+		d := &ArtifactDriver{ActiveMode: true}
+		art := artifact(s.url("ftp", "my-dir"))
+		assert.NoError(t, d.Save(ctx, newLocalDirectory(t, tmp), art))
+		dest, err := ioutil.TempDir(tmp, "dest-dir")
+		assert.NoError(t, err)
+		if assert.NoError(t, d.Load(ctx, art, dest)) {
+			assertLocalDirectory(t, dest)
+		}
+		assert.True(t, s.sent("PORT"))
+		assert.False(t, s.sent("EPSV"))
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		s := newServer(t, nil)
+		defer s.Close()
+		err := d.Load(ctx, artifact(s.url("ftp", "missing")), filepath.Join(tmp, "missing"))
+		assert.True(t, errors.Is(err, common.ErrArtifactNotFound))
+		assert.NoFileExists(t, filepath.Join(tmp, "missing"))
+	})
+	t.Run("Unauthorized", func(t *testing.T) {
+		s := newServer(t, nil)
+		defer s.Close()
+		d := &ArtifactDriver{Username: "my-username", Password: "wrong"}
+		err := d.Load(ctx, artifact(s.url("ftp", "my-file")), filepath.Join(tmp, "unauthorized"))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "530")
+		}
+	})
+	t.Run("Cancelled", func(t *testing.T) {
+		s := newServer(t, nil)
+		defer s.Close()
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		assert.Error(t, d.Load(ctx, artifact(s.url("ftp", "my-file")), filepath.Join(tmp, "cancelled")))
+	})
+}
+
+func TestArtifactDriver_FTPS(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ftps")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+	ctx := context.Background()
+	cert, pool := newCertificate(t)
+	s := newServer(t, func(s *server) {
+		s.tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	})
+	defer s.Close()
+	art := artifact(s.url("ftps", "my-dir"))
+
+	t.Run("RootCAs", func(t *testing.T) {
+		d := &ArtifactDriver{Username: "my-username", Password: "my-password", RootCAs: pool}
+		assert.NoError(t, d.Save(ctx, newLocalDirectory(t, tmp), art))
+		dest, err := ioutil.TempDir(tmp, "dest-dir")
+		assert.NoError(t, err)
+		if assert.NoError(t, d.Load(ctx, art, dest)) {
+			assertLocalDirectory(t, dest)
+		}
+		assert.True(t, s.sent("AUTH"))
+		assert.True(t, s.sent("PROT"))
+	})
+	t.Run("InsecureSkipVerify", func(t *testing.T) {
+		d := &ArtifactDriver{InsecureSkipVerify: true}
+		ok, err := d.Exists(art)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+	t.Run("UnknownAuthority", func(t *testing.T) {
+		d := &ArtifactDriver{}
+		_, err := d.Exists(art)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "certificate")
+		}
+	})
+}
+
+func TestParseListings(t *testing.T) {
+	for line, expected := range map[string]*entry{
+		"type=file;size=10;modify=20210101000000; my file": {name: "my file"},
+		"Type=Dir;perm=el; my-dir":                         {name: "my-dir", dir: true},
+		"type=cdir; .":                                     nil,
+		"type=OS.unix=symlink; my-link":                    nil,
+	} {
+		e, ok := parseMLSDLine(line)
+		if expected == nil {
+			assert.False(t, ok, line)
+		} else if assert.True(t, ok, line) {
+			assert.Equal(t, *expected, e, line)
+		}
+	}
+	for line, expected := range map[string]*entry{
+		"-rw-r--r--   1 owner group   10 Jan 01 00:00 my file": {name: "my file"},
+		"drwxr-xr-x 2 owner group 4096 Jan 01  2021 my-dir":    {name: "my-dir", dir: true},
+		"drwxr-xr-x 2 owner group 4096 Jan 01  2021 ..":        nil,
+		"lrwxrwxrwx 1 owner group 4 Jan 01 00:00 my-link -> a": nil,
+		"total 8": nil,
+	} {
+		e, ok := parseLISTLine(line)
+		if expected == nil {
+			assert.False(t, ok, line)
+		} else if assert.True(t, ok, line) {
+			assert.Equal(t, *expected, e, line)
+		}
+	}
+}
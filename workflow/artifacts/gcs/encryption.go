@@ -0,0 +1,83 @@
+package gcs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+)
+
+// encryptionKeySize is the size of customer-supplied encryption keys, which are AES-256 keys
+const encryptionKeySize = 32
+
+// ParseEncryptionKey decodes a base64 encoded customer-supplied encryption key, as gsutil and gcloud take them
+func ParseEncryptionKey(key string) ([]byte, error) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "the GCS encryption key must be base64 encoded: %v", err)
+	}
+	if len(decoded) != encryptionKeySize {
+		return nil, errors.Errorf(errors.CodeBadRequest, "the GCS encryption key must be a %d byte AES-256 key, not %d bytes", encryptionKeySize, len(decoded))
+	}
+	return decoded, nil
+}
+
+// encryptionKeySHA256 returns the base64 encoded SHA256 digest of a customer-supplied encryption key, by which GCS
+// identifies the key of an object
+func encryptionKeySHA256(encryptionKey []byte) string {
+	sum := sha256.Sum256(encryptionKey)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// object returns the handle of an object of the bucket, which reads and writes it with the customer-supplied
+// encryption key, if there is one
+func object(bucket *storage.BucketHandle, name string, encryptionKey []byte) *storage.ObjectHandle {
+	obj := bucket.Object(name)
+	if len(encryptionKey) > 0 {
+		obj = obj.Key(encryptionKey)
+	}
+	return obj
+}
+
+// checkEncryptionKey returns an error if an object cannot be read with the customer-supplied encryption key: if it
+// is encrypted with another key, e.g. one rotated since, with none, or with a key when there is none
+func checkEncryptionKey(attrs *storage.ObjectAttrs, encryptionKey []byte) error {
+	switch {
+	case attrs.CustomerKeySHA256 == "" && len(encryptionKey) == 0:
+		return nil
+	case attrs.CustomerKeySHA256 == "":
+		return errors.Errorf(errors.CodeBadRequest, "GCS object %s is not encrypted with a customer-supplied encryption key, but a key was supplied", attrs.Name)
+	case len(encryptionKey) == 0:
+		return errors.Errorf(errors.CodeBadRequest, "GCS object %s is encrypted with a customer-supplied encryption key (SHA256 %s), which must be supplied to read it", attrs.Name, attrs.CustomerKeySHA256)
+	case attrs.CustomerKeySHA256 != encryptionKeySHA256(encryptionKey):
+		return errors.Errorf(errors.CodeBadRequest, "GCS object %s is encrypted with the customer-supplied encryption key with SHA256 %s, not the supplied key with SHA256 %s: has the key been rotated?", attrs.Name, attrs.CustomerKeySHA256, encryptionKeySHA256(encryptionKey))
+	}
+	return nil
+}
+
+// encryptionKeyError returns the error of checkEncryptionKey for an object which could not be read, if the read
+// failed because of its encryption key, or otherwise err
+func encryptionKeyError(bucket *storage.BucketHandle, name string, encryptionKey []byte, err error) error {
+	apiErr, ok := err.(*googleapi.Error)
+	if !ok || apiErr.Code != http.StatusBadRequest {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	// the metadata of an object can be read without its key
+	attrs, attrsErr := bucket.Object(name).Attrs(ctx)
+	if attrsErr != nil {
+		return err
+	}
+	if keyErr := checkEncryptionKey(attrs, encryptionKey); keyErr != nil {
+		return keyErr
+	}
+	return err
+}
@@ -0,0 +1,220 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/option"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+// fakeEncryptedObjects is a fake of GCS, which records the SHA256 of the customer-supplied encryption key each object
+// was uploaded with, and only serves the content of an object to reads with the same key. The metadata of objects is
+// recorded and served too, along with their md5.
+type fakeEncryptedObjects struct {
+	lock     sync.Mutex
+	objects  map[string][]byte
+	keys     map[string]string
+	metadata map[string]map[string]string
+	// updated are the RFC 3339 times the objects were last updated at, if they are listed with one
+	updated map[string]string
+	reads   int
+	uploads []string
+}
+
+func (f *fakeEncryptedObjects) object(name string) map[string]interface{} {
+	md5Hash := md5.Sum(f.objects[name])
+	obj := map[string]interface{}{"bucket": "my-bucket", "name": name, "size": fmt.Sprint(len(f.objects[name])), "md5Hash": base64.StdEncoding.EncodeToString(md5Hash[:])}
+	if metadata := f.metadata[name]; metadata != nil {
+		obj["metadata"] = metadata
+	}
+	if updated := f.updated[name]; updated != "" {
+		obj["updated"] = updated
+	}
+	if sha256 := f.keys[name]; sha256 != "" {
+		obj["customerEncryption"] = map[string]string{"encryptionAlgorithm": "AES256", "keySha256": sha256}
+	}
+	return obj
+}
+
+func (f *fakeEncryptedObjects) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	keySHA256 := r.Header.Get("X-Goog-Encryption-Key-Sha256")
+	if keySHA256 != "" {
+		key, err := base64.StdEncoding.DecodeString(r.Header.Get("X-Goog-Encryption-Key"))
+		if err != nil || r.Header.Get("X-Goog-Encryption-Algorithm") != "AES256" || encryptionKeySHA256(key) != keySHA256 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	switch {
+	case r.Method == http.MethodPost && r.URL.Query().Get("uploadType") == "multipart":
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		parts := multipart.NewReader(r.Body, params["boundary"])
+		var attrs struct {
+			Name     string
+			Metadata map[string]string
+		}
+		part, err := parts.NextPart()
+		if err == nil {
+			err = json.NewDecoder(part).Decode(&attrs)
+		}
+		if err == nil {
+			part, err = parts.NextPart()
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.objects[attrs.Name], _ = ioutil.ReadAll(part)
+		f.keys[attrs.Name] = keySHA256
+		if f.metadata == nil {
+			f.metadata = map[string]map[string]string{}
+		}
+		f.metadata[attrs.Name] = attrs.Metadata
+		f.uploads = append(f.uploads, attrs.Name)
+		_ = json.NewEncoder(w).Encode(f.object(attrs.Name))
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/b/my-bucket/o"):
+		var items []map[string]interface{}
+		for name := range f.objects {
+			if strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+				items = append(items, f.object(name))
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"kind": "storage#objects", "items": items})
+	case r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/b/my-bucket/o/"):
+		name := r.URL.Path[strings.Index(r.URL.Path, "/b/my-bucket/o/")+len("/b/my-bucket/o/"):]
+		if _, ok := f.objects[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(f.object(name))
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/my-bucket/"):
+		name := strings.TrimPrefix(r.URL.Path, "/my-bucket/")
+		data, ok := f.objects[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		f.reads++
+		if keySHA256 != f.keys[name] {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprint(w, `<?xml version='1.0' encoding='UTF-8'?><Error><Code>InvalidArgument</Code><Message>The provided encryption key is incorrect.</Message></Error>`)
+			return
+		}
+		_, _ = w.Write(data)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestParseEncryptionKey(t *testing.T) {
+	key := bytes.Repeat([]byte{42}, 32)
+	parsed, err := ParseEncryptionKey(base64.StdEncoding.EncodeToString(key) + "\n")
+	if assert.NoError(t, err) {
+		assert.Equal(t, key, parsed)
+	}
+	_, err = ParseEncryptionKey(string(key))
+	assert.Error(t, err)
+	_, err = ParseEncryptionKey(base64.StdEncoding.EncodeToString(key[:16]))
+	assert.Error(t, err)
+}
+
+func TestEncryptionKey(t *testing.T) {
+	objects := &fakeEncryptedObjects{objects: map[string][]byte{}, keys: map[string]string{}}
+	server := httptest.NewServer(objects)
+	defer server.Close()
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx, option.WithHTTPClient(&http.Client{Transport: redirectTransport{host: strings.TrimPrefix(server.URL, "http://")}}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = client.Close() }()
+	tmp, err := ioutil.TempDir("", "gcs")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	bucket := client.Bucket("my-bucket")
+	key := bytes.Repeat([]byte{1}, 32)
+	rotatedKey := bytes.Repeat([]byte{2}, 32)
+	dir := filepath.Join(tmp, "my-dir")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "b"), []byte("b"), 0600))
+	if _, err := uploadObjects(ctx, bucket, "my-dir", dir, common.FileFilter{}, nil, "", defaultUploadChunkSize, key, false, nil); !assert.NoError(t, err) {
+		return
+	}
+	// each object was written with the key
+	assert.Equal(t, map[string]string{"my-dir/a": encryptionKeySHA256(key), "my-dir/sub/b": encryptionKeySHA256(key)}, objects.keys)
+
+	t.Run("Load", func(t *testing.T) {
+		path := filepath.Join(tmp, "loaded")
+		if assert.NoError(t, downloadObjects(ctx, bucket, "my-dir", path, key, 0, nil)) {
+			data, err := ioutil.ReadFile(filepath.Join(path, "sub", "b"))
+			assert.NoError(t, err)
+			assert.Equal(t, "b", string(data))
+		}
+	})
+	t.Run("Range", func(t *testing.T) {
+		path := filepath.Join(tmp, "range")
+		if assert.NoError(t, downloadRange(ctx, bucket, "my-dir/a", 0, -1, path, key)) {
+			data, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, "a", string(data))
+		}
+	})
+	t.Run("RotatedKey", func(t *testing.T) {
+		objects.reads = 0
+		err := downloadObjects(ctx, bucket, "my-dir", filepath.Join(tmp, "rotated"), rotatedKey, 0, nil)
+		assert.True(t, errors.IsCode(errors.CodeBadRequest, err))
+		assert.Contains(t, err.Error(), encryptionKeySHA256(key))
+		assert.Contains(t, err.Error(), encryptionKeySHA256(rotatedKey))
+		// the keys are checked before any object is read
+		assert.Equal(t, 0, objects.reads)
+		err = downloadRange(ctx, bucket, "my-dir/a", 0, -1, filepath.Join(tmp, "rotated-range"), rotatedKey)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "has the key been rotated?")
+		}
+	})
+	t.Run("NoKey", func(t *testing.T) {
+		err := downloadObjects(ctx, bucket, "my-dir", filepath.Join(tmp, "no-key"), nil, 0, nil)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "which must be supplied to read it")
+		}
+	})
+	t.Run("NotEncrypted", func(t *testing.T) {
+		path := filepath.Join(tmp, "plain")
+		assert.NoError(t, ioutil.WriteFile(path, []byte("plain"), 0600))
+		if _, err := uploadObject(ctx, bucket, "plain", path, nil, "", defaultUploadChunkSize, nil, false, nil); assert.NoError(t, err) {
+			assert.Empty(t, objects.keys["plain"])
+			err := downloadObjects(ctx, bucket, "plain", filepath.Join(tmp, "plain-loaded"), key, 0, nil)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), "is not encrypted with a customer-supplied encryption key")
+			}
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package gcs
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+)
+
+// defaultHost is the host of the Cloud Storage API, which the client sends the reads of objects to, whatever its
+// endpoint
+const defaultHost = "storage.googleapis.com"
+
+// ParseEndpoint parses the endpoint of a GCS bucket, which is nil if it is not set
+func ParseEndpoint(endpoint string) (*url.URL, error) {
+	if endpoint == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" || strings.Trim(u.Path, "/") != "" || u.RawQuery != "" || u.User != nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "must be the base URL of a Cloud Storage endpoint, e.g. https://storage-myendpoint.p.googleapis.com")
+	}
+	return &url.URL{Scheme: u.Scheme, Host: u.Host}, nil
+}
+
+// jsonEndpoint returns the endpoint of the JSON API at the endpoint
+func jsonEndpoint(endpoint *url.URL) string {
+	return endpoint.String() + "/storage/v1/"
+}
+
+// endpointTransport sends the requests to storage.googleapis.com to the endpoint instead, as the client sends the
+// reads of objects there even if it is given another endpoint
+type endpointTransport struct {
+	endpoint *url.URL
+	base     http.RoundTripper
+}
+
+func (t *endpointTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL.Host == defaultHost {
+		r = r.Clone(r.Context())
+		r.URL.Scheme = t.endpoint.Scheme
+		r.URL.Host = t.endpoint.Host
+		r.Host = ""
+	}
+	return t.base.RoundTrip(r)
+}
+
+// signedURL returns a V4 signed URL of the endpoint for requests of the method of the object, signed with the PEM
+// encoded private key of the service account with the email. The host of the endpoint is signed, so the URL cannot
+// be signed by storage.SignedURL, which only signs URLs of storage.googleapis.com.
+func signedURL(endpoint *url.URL, bucket, key, method, email string, privateKey []byte, now time.Time, expiry time.Duration) (string, error) {
+	rsaKey, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+	now = now.UTC()
+	timestamp := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/auto/storage/goog4_request"
+	u := &url.URL{Scheme: endpoint.Scheme, Host: endpoint.Host, Path: "/" + bucket + "/" + key}
+	query := url.Values{
+		"X-Goog-Algorithm":     {"GOOG4-RSA-SHA256"},
+		"X-Goog-Credential":    {email + "/" + scope},
+		"X-Goog-Date":          {timestamp},
+		"X-Goog-Expires":       {strconv.Itoa(int(expiry / time.Second))},
+		"X-Goog-SignedHeaders": {"host"},
+	}
+	canonicalRequest := strings.Join([]string{method, u.EscapedPath(), query.Encode(), "host:" + u.Host + "\n", "host", "UNSIGNED-PAYLOAD"}, "\n")
+	requestDigest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"GOOG4-RSA-SHA256", timestamp, scope, hex.EncodeToString(requestDigest[:])}, "\n")
+	digest := sha256.Sum256([]byte(stringToSign))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("sign URL: %w", err)
+	}
+	query.Set("X-Goog-Signature", hex.EncodeToString(signature))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// parsePrivateKey parses the PEM encoded PKCS #8 or PKCS #1 RSA private key of a service account key
+func parsePrivateKey(privateKey []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(privateKey)
+	if block == nil {
+		return nil, fmt.Errorf("the private key of the service account key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("the private key of the service account key is not an RSA key")
+		}
+		return rsaKey, nil
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse the private key of the service account key: %w", err)
+	}
+	return key, nil
+}
@@ -2,75 +2,243 @@ package gcs
 
 import (
 	"context"
+	"encoding/hex"
+	stderrors "errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/argoproj/pkg/file"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/wait"
 
 	"github.com/argoproj/argo-workflows/v3/errors"
 	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
 )
 
 // ArtifactDriver is a driver for GCS
 type ArtifactDriver struct {
 	ServiceAccountKey string
+	// RequesterPays bills requests to BillingProject, or if it is not set, the project of the service account key
+	RequesterPays  bool
+	BillingProject string
+	// ProxyURL is the proxy requests are sent through, if set, rather than that of the environment
+	ProxyURL *url.URL
+	// UploadChunkSize is the size in bytes of the chunks of resumable uploads, defaultUploadChunkSize if not set
+	UploadChunkSize int
+	// EncryptionKey is the AES-256 customer-supplied encryption key objects are written and read with, if set
+	EncryptionKey []byte
+	// Endpoint is the base URL requests, and signed URLs, are sent to, if set, rather than https://storage.googleapis.com
+	Endpoint *url.URL
+	// Anonymous loads public objects with unauthenticated requests, and refuses to save or delete artifacts
+	Anonymous bool
+	// Bucket is the bucket of the artifact the driver was created for, which HealthCheck checks. Loads and saves use
+	// the bucket of the artifact they are given.
+	Bucket string
+	common.Logging
+	common.Transporting
 }
 
-func (g *ArtifactDriver) newGCSClient() (*storage.Client, error) {
-	if g.ServiceAccountKey != "" {
-		return newGCSClientWithCredential(g.ServiceAccountKey)
+// defaultUploadChunkSize is the chunk size of resumable uploads, unless the artifact sets one
+const defaultUploadChunkSize = 16 * 1024 * 1024
+
+// ValidateArtifact validates the GCS artifact, beyond what the general artifact validation checks
+func ValidateArtifact(errPrefix string, art *wfv1.GCSArtifact) error {
+	if _, err := ParseUploadChunkSize(art.UploadChunkSize); err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s.uploadChunkSize %s", errPrefix, err.Error())
 	}
-	// Assume it uses Workload Identity
-	return newGCSClientDefault()
+	if _, err := ParseEndpoint(art.Endpoint); err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s.endpoint %s", errPrefix, err.Error())
+	}
+	if art.Anonymous {
+		if art.ServiceAccountKeySecret != nil && art.ServiceAccountKeySecret.Name != "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.anonymous cannot be used with %s.serviceAccountKeySecret", errPrefix, errPrefix)
+		}
+		if art.RequesterPays {
+			return errors.Errorf(errors.CodeBadRequest, "%s.anonymous cannot be used with %s.requesterPays, as requester pays buckets bill the requester", errPrefix, errPrefix)
+		}
+	}
+	return nil
 }
 
-func newGCSClientWithCredential(serviceAccountJSON string) (*storage.Client, error) {
-	ctx := context.Background()
-	creds, err := google.CredentialsFromJSON(ctx, []byte(serviceAccountJSON), storage.ScopeReadWrite)
-	if err != nil {
-		return nil, fmt.Errorf("GCS client CredentialsFromJSON: %v", err)
+// ParseUploadChunkSize returns the upload chunk size of a GCS bucket in bytes, or 0 if it is not set
+func ParseUploadChunkSize(size string) (int, error) {
+	if size == "" {
+		return 0, nil
 	}
-	client, err := storage.NewClient(ctx, option.WithCredentials(creds))
+	q, err := resource.ParseQuantity(size)
 	if err != nil {
-		return nil, fmt.Errorf("GCS storage.NewClient with credential: %v", err)
+		return 0, errors.Errorf(errors.CodeBadRequest, "must be a quantity of bytes, e.g. 16Mi: %v", err)
 	}
-	return client, nil
+	if q.Value() < 1 || q.Value() > math.MaxInt32 {
+		return 0, errors.Errorf(errors.CodeBadRequest, "must be between 1 byte and 2Gi")
+	}
+	return int(q.Value()), nil
+}
+
+func (g *ArtifactDriver) uploadChunkSize() int {
+	if g.UploadChunkSize > 0 {
+		return g.UploadChunkSize
+	}
+	return defaultUploadChunkSize
 }
 
-func newGCSClientDefault() (*storage.Client, error) {
+// findDefaultCredentials returns the application default credentials, e.g. those of Workload Identity
+var findDefaultCredentials = google.FindDefaultCredentials
+
+// loadsAnonymously returns whether objects are loaded with unauthenticated requests, which they are if the driver is
+// anonymous, or if it has no service account key and there are no default credentials
+func (g *ArtifactDriver) loadsAnonymously() bool {
+	if g.Anonymous {
+		return true
+	}
+	if g.ServiceAccountKey != "" {
+		return false
+	}
+	if _, err := findDefaultCredentials(context.Background(), storage.ScopeReadWrite); err != nil {
+		g.Log().WithError(err).Info("There are no default GCS credentials, so objects are loaded with unauthenticated requests")
+		return true
+	}
+	return false
+}
+
+// checkAuthenticated returns an error if the driver is anonymous, as objects cannot be written with unauthenticated
+// requests
+func (g *ArtifactDriver) checkAuthenticated(operation string) error {
+	if g.Anonymous {
+		return errors.Errorf(errors.CodeBadRequest, "GCS %s cannot be anonymous, only loads of public objects can be", operation)
+	}
+	return nil
+}
+
+// newGCSClient returns a client whose requests are unauthenticated if anonymous is set, or are otherwise authenticated
+// with the service account key, or if there is none, the default credentials
+func (g *ArtifactDriver) newGCSClient(anonymous bool) (*storage.Client, error) {
 	ctx := context.Background()
-	client, err := storage.NewClient(ctx)
+	if g.ProxyURL != nil || g.HasRoundTripper() {
+		// so that tokens are fetched through the proxy, and with the RoundTripper, too
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: g.RoundTripper(common.NewTransport(nil, g.ProxyURL))})
+	}
+	var opts []option.ClientOption
+	if anonymous {
+		opts = append(opts, option.WithoutAuthentication())
+	} else if g.ServiceAccountKey != "" {
+		creds, err := google.CredentialsFromJSON(ctx, []byte(g.ServiceAccountKey), storage.ScopeReadWrite)
+		if err != nil {
+			return nil, fmt.Errorf("GCS client CredentialsFromJSON: %v", err)
+		}
+		opts = append(opts, option.WithCredentials(creds))
+	}
+	// otherwise assume it uses Workload Identity
+	if g.ProxyURL != nil || g.Endpoint != nil || g.HasRoundTripper() {
+		base := g.RoundTripper(common.NewTransport(nil, g.ProxyURL))
+		if g.Endpoint != nil {
+			base = &endpointTransport{endpoint: g.Endpoint, base: base}
+		}
+		// clients given an HTTP client do not authenticate its requests, so the transport is given the credentials
+		transport, err := htransport.NewTransport(ctx, base, append(opts, option.WithScopes(storage.ScopeFullControl))...)
+		if err != nil {
+			return nil, fmt.Errorf("GCS client NewTransport: %v", err)
+		}
+		opts = []option.ClientOption{option.WithHTTPClient(&http.Client{Transport: transport})}
+		if g.Endpoint != nil {
+			opts = append(opts, option.WithEndpoint(jsonEndpoint(g.Endpoint)))
+		}
+	}
+	client, err := storage.NewClient(ctx, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("GCS storage.NewClient: %v", err)
 	}
 	return client, nil
 }
 
+// bucket returns a handle of the bucket, which bills its requests to the billing project if the bucket is requester pays
+func (g *ArtifactDriver) bucket(client *storage.Client, name string) (*storage.BucketHandle, error) {
+	bucket := client.Bucket(name)
+	if !g.RequesterPays {
+		return bucket, nil
+	}
+	project := g.BillingProject
+	if project == "" && g.ServiceAccountKey != "" {
+		creds, err := google.CredentialsFromJSON(context.Background(), []byte(g.ServiceAccountKey), storage.ScopeReadWrite)
+		if err != nil {
+			return nil, fmt.Errorf("GCS client CredentialsFromJSON: %v", err)
+		}
+		project = creds.ProjectID
+	}
+	if project == "" {
+		return nil, fmt.Errorf("a billing project is required to access requester pays bucket %s", name)
+	}
+	return bucket.UserProject(project), nil
+}
+
+// logger returns the logger of the driver with the bucket and key of the artifact
+func (g *ArtifactDriver) logger(art *wfv1.GCSArtifact) log.FieldLogger {
+	return g.Log().WithFields(log.Fields{"bucket": art.Bucket, "key": art.Key})
+}
+
 // Load function downloads objects from GCS
-func (g *ArtifactDriver) Load(inputArtifact *wfv1.Artifact, path string) error {
-	err := wait.ExponentialBackoff(wait.Backoff{Duration: time.Second * 2, Factor: 2.0, Steps: 5, Jitter: 0.1},
+func (g *ArtifactDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, path string) error {
+	return g.LoadWithProgress(ctx, inputArtifact, path, nil)
+}
+
+// LoadWithProgress downloads objects from GCS. The progress restarts if the download is retried. It downloads to a
+// temporary path, which only replaces path once it succeeds.
+func (g *ArtifactDriver) LoadWithProgress(ctx context.Context, inputArtifact *wfv1.Artifact, path string, progress common.ProgressFunc) error {
+	return common.LoadAtomically(path, func(path string) error {
+		return g.loadWithProgress(ctx, inputArtifact, path, progress)
+	})
+}
+
+func (g *ArtifactDriver) loadWithProgress(ctx context.Context, inputArtifact *wfv1.Artifact, path string, progress common.ProgressFunc) error {
+	maxSize, err := common.MaxSize(inputArtifact)
+	if err != nil {
+		return err
+	}
+	logger := g.logger(inputArtifact.GCS)
+	attempt := 0
+	err = wait.ExponentialBackoff(wait.Backoff{Duration: time.Second * 2, Factor: 2.0, Steps: 5, Jitter: 0.1},
 		func() (bool, error) {
-			log.Infof("GCS Load path: %s, key: %s", path, inputArtifact.GCS.Key)
-			gcsClient, err := g.newGCSClient()
+			logger.Infof("GCS Load path: %s, bucket: %s, key: %s", path, inputArtifact.GCS.Bucket, inputArtifact.GCS.Key)
+			attempt++
+			logger.WithField("attempt", attempt).Debug("Loading from GCS")
+			if err := ctx.Err(); err != nil {
+				return false, err
+			}
+			gcsClient, err := g.newGCSClient(g.loadsAnonymously())
 			if err != nil {
-				log.Warnf("Failed to create new GCS client: %v", err)
+				logger.Warnf("Failed to create new GCS client: %v", err)
 				return false, err
 			}
 			defer gcsClient.Close()
-			err = downloadObjects(gcsClient, inputArtifact.GCS.Bucket, inputArtifact.GCS.Key, path)
+			bucket, err := g.bucket(gcsClient, inputArtifact.GCS.Bucket)
 			if err != nil {
-				log.Warnf("Failed to download objects from GCS: %v", err)
+				return false, err
+			}
+			if inputArtifact.SelectLatest != "" {
+				err = downloadLatest(ctx, bucket, inputArtifact, path, g.EncryptionKey, maxSize, progress)
+			} else {
+				err = downloadObjects(ctx, bucket, inputArtifact.GCS.Key, path, g.EncryptionKey, maxSize, progress)
+			}
+			if err != nil {
+				logger.Warnf("Failed to download objects from GCS: %v", err)
 				return false, err
 			}
 			return true, nil
@@ -78,41 +246,227 @@ func (g *ArtifactDriver) Load(inputArtifact *wfv1.Artifact, path string) error {
 	return err
 }
 
-// download all the objects of a key from the bucket
-func downloadObjects(client *storage.Client, bucket, key, path string) error {
-	objNames, err := listByPrefix(client, bucket, key, "")
+// OpenStream opens a single object from GCS for reading. The client is closed along with the reader.
+func (g *ArtifactDriver) OpenStream(inputArtifact *wfv1.Artifact) (io.ReadCloser, error) {
+	g.Log().Infof("GCS OpenStream key: %s", inputArtifact.GCS.Key)
+	client, err := g.newGCSClient(g.loadsAnonymously())
+	if err != nil {
+		return nil, err
+	}
+	bucket, err := g.bucket(client, inputArtifact.GCS.Bucket)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	rc, err := object(bucket, inputArtifact.GCS.Key, g.EncryptionKey).NewReader(context.Background())
+	if err != nil {
+		defer client.Close()
+		if err == storage.ErrObjectNotExist {
+			return nil, common.NewNotFoundError(err)
+		}
+		return nil, fmt.Errorf("new bucket reader: %w", encryptionKeyError(bucket, inputArtifact.GCS.Key, g.EncryptionKey, err))
+	}
+	return &objectReadCloser{ReadCloser: rc, client: client}, nil
+}
+
+// LoadRange downloads a range of the bytes of a single object from GCS
+func (g *ArtifactDriver) LoadRange(artifact *wfv1.Artifact, start, length int64, path string) error {
+	g.logger(artifact.GCS).Infof("GCS LoadRange path: %s, key: %s, range: %s", path, artifact.GCS.Key, common.ByteRangeSpec(start, length))
+	client, err := g.newGCSClient(g.loadsAnonymously())
 	if err != nil {
 		return err
 	}
-	for _, objName := range objNames {
-		err = downloadObject(client, bucket, key, objName, path)
+	defer client.Close()
+	bucket, err := g.bucket(client, artifact.GCS.Bucket)
+	if err != nil {
+		return err
+	}
+	return downloadRange(context.Background(), bucket, artifact.GCS.Key, start, length, path, g.EncryptionKey)
+}
+
+// downloadRange downloads length bytes of an object from the offset start, or every byte from start if length is
+// negative, as NewRangeReader does
+func downloadRange(ctx context.Context, bucket *storage.BucketHandle, key string, start, length int64, path string, encryptionKey []byte) error {
+	rc, err := object(bucket, key, encryptionKey).NewRangeReader(ctx, start, length)
+	if err != nil {
+		if err == storage.ErrObjectNotExist {
+			return common.NewNotFoundError(err)
+		}
+		return fmt.Errorf("new bucket range reader: %w", encryptionKeyError(bucket, key, encryptionKey, err))
+	}
+	defer rc.Close()
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("os create %s: %v", path, err)
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, rc); err != nil {
+		return fmt.Errorf("io copy: %v", err)
+	}
+	return out.Close()
+}
+
+// objectReadCloser closes the GCS client once the object reader is closed
+type objectReadCloser struct {
+	io.ReadCloser
+	client *storage.Client
+}
+
+func (o *objectReadCloser) Close() error {
+	err := o.ReadCloser.Close()
+	if clientErr := o.client.Close(); err == nil {
+		err = clientErr
+	}
+	return err
+}
+
+// download all the objects of a key from the bucket. Their encryption keys, and their total size against maxSize, which
+// is not a limit if it is not positive, are checked before any is downloaded. An object under a symlink which an
+// earlier object was loaded as is not downloaded, but an error.
+func downloadObjects(ctx context.Context, bucket *storage.BucketHandle, key, path string, encryptionKey []byte, maxSize int64, progress common.ProgressFunc) error {
+	objs, err := listObjectsByPrefix(ctx, bucket, key, "")
+	if err != nil {
+		return err
+	}
+	if len(objs) == 0 {
+		return common.NewNotFoundError(fmt.Errorf("no objects found with key %s", key))
+	}
+	// the manifest of a directory is listed with its objects, as its key has that of the directory as a prefix
+	var manifest *storage.ObjectAttrs
+	if len(objs) > 1 {
+		for i, obj := range objs {
+			if obj.Name == common.ManifestKey(key) {
+				manifest = obj
+				objs = append(objs[:i:i], objs[i+1:]...)
+				break
+			}
+		}
+	}
+	var total int64
+	for _, obj := range objs {
+		if err := checkEncryptionKey(obj, encryptionKey); err != nil {
+			return err
+		}
+		total += obj.Size
+	}
+	if err := common.CheckSize(total, maxSize); err != nil {
+		return err
+	}
+	counter := common.NewProgressCounter(total, progress)
+	for _, obj := range objs {
+		if target := obj.Metadata[common.SymlinkTargetMetadataKey]; target != "" && obj.Size == 0 {
+			localPath := objectPath(key, obj.Name, path)
+			created, err := common.CreateSymlink(path, localPath, target)
+			if err != nil {
+				return err
+			}
+			if !created {
+				log.Warnf("Not creating the symlink %s to %s, which is outside of %s", localPath, target, path)
+			}
+			continue
+		}
+		if err := common.CheckLoadPath(path, objectPath(key, obj.Name, path)); err != nil {
+			return err
+		}
+		err = downloadObject(ctx, bucket, key, obj.Name, path, encryptionKey, counter)
 		if err != nil {
 			return err
 		}
 	}
+	counter.Done()
+	if manifest != nil {
+		return verifyManifest(ctx, bucket, manifest, path, encryptionKey)
+	}
 	return nil
 }
 
-// download an object from the bucket
-func downloadObject(client *storage.Client, bucket, key, objName, path string) error {
+// downloadLatest downloads the latest of the objects under the key prefix of the input artifact, by its policy, to path.
+// An object is last modified when it is updated.
+func downloadLatest(ctx context.Context, bucket *storage.BucketHandle, inputArtifact *wfv1.Artifact, path string, encryptionKey []byte, maxSize int64, progress common.ProgressFunc) error {
+	prefix := inputArtifact.GCS.Key
+	objs, err := listObjectsByPrefix(ctx, bucket, prefix, "")
+	if err != nil {
+		return err
+	}
+	var objects []common.ListedObject
+	attrs := map[string]*storage.ObjectAttrs{}
+	for _, obj := range objs {
+		if strings.HasSuffix(obj.Name, "/") {
+			continue
+		}
+		objects = append(objects, common.ListedObject{Key: obj.Name, LastModified: obj.Updated, Size: obj.Size})
+		attrs[obj.Name] = obj
+	}
+	name, err := common.SelectLatest(inputArtifact.SelectLatest, prefix, objects)
+	if err != nil {
+		return err
+	}
+	log.Infof("Selected the %s object %s under %s", inputArtifact.SelectLatest, name, prefix)
+	if err := checkEncryptionKey(attrs[name], encryptionKey); err != nil {
+		return err
+	}
+	if err := common.CheckSize(attrs[name].Size, maxSize); err != nil {
+		return err
+	}
+	counter := common.NewProgressCounter(attrs[name].Size, progress)
+	if err := downloadObject(ctx, bucket, name, name, path, encryptionKey, counter); err != nil {
+		return err
+	}
+	counter.Done()
+	return nil
+}
+
+// verifyManifest verifies the directory downloaded to path against its manifest
+func verifyManifest(ctx context.Context, bucket *storage.BucketHandle, attrs *storage.ObjectAttrs, path string, encryptionKey []byte) error {
+	if err := checkEncryptionKey(attrs, encryptionKey); err != nil {
+		return err
+	}
+	manifest, err := readManifest(ctx, bucket, attrs.Name, encryptionKey)
+	if err != nil {
+		return err
+	}
+	return manifest.Verify(path)
+}
+
+// readManifest reads and parses the manifest object with the name
+func readManifest(ctx context.Context, bucket *storage.BucketHandle, name string, encryptionKey []byte) (*common.Manifest, error) {
+	rc, err := object(bucket, name, encryptionKey).NewReader(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("new bucket reader: %v", err)
+	}
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %v", err)
+	}
+	return common.ParseManifest(data)
+}
+
+// objectPath returns the local path under path which the object with the name under the key is downloaded to
+func objectPath(key, objName, path string) string {
 	objPrefix := filepath.Clean(key)
 	if os.PathSeparator == '\\' {
 		objPrefix = strings.ReplaceAll(objPrefix, "\\", "/")
 	}
+	return filepath.Join(path, strings.TrimPrefix(objName, objPrefix))
+}
 
-	relObjPath := strings.TrimPrefix(objName, objPrefix)
-	localPath := filepath.Join(path, relObjPath)
+// download an object from the bucket
+func downloadObject(ctx context.Context, bucket *storage.BucketHandle, key, objName, path string, encryptionKey []byte, counter *common.ProgressCounter) error {
+	localPath := objectPath(key, objName, path)
 	objectDir, _ := filepath.Split(localPath)
 	if objectDir != "" {
 		if err := os.MkdirAll(objectDir, 0700); err != nil {
 			return fmt.Errorf("mkdir %s: %v", objectDir, err)
 		}
 	}
-	ctx := context.Background()
-	rc, err := client.Bucket(bucket).Object(objName).NewReader(ctx)
+	rc, err := object(bucket, objName, encryptionKey).NewReader(ctx)
 	if err != nil {
 		if err == storage.ErrObjectNotExist {
-			return errors.New(errors.CodeNotFound, err.Error())
+			return common.NewNotFoundError(err)
 		}
 		return fmt.Errorf("new bucket reader: %v", err)
 	}
@@ -122,7 +476,7 @@ func downloadObject(client *storage.Client, bucket, key, objName, path string) e
 		return fmt.Errorf("os create %s: %v", localPath, err)
 	}
 	defer out.Close()
-	_, err = io.Copy(out, rc)
+	_, err = io.Copy(out, counter.Reader(rc))
 	if err != nil {
 		return fmt.Errorf("io copy: %v", err)
 	}
@@ -130,15 +484,27 @@ func downloadObject(client *storage.Client, bucket, key, objName, path string) e
 }
 
 // list all the object names of the prefix in the bucket
-func listByPrefix(client *storage.Client, bucket, prefix, delim string) ([]string, error) {
-	ctx := context.Background()
+func listByPrefix(ctx context.Context, bucket *storage.BucketHandle, prefix, delim string) ([]string, error) {
+	objs, err := listObjectsByPrefix(ctx, bucket, prefix, delim)
+	if err != nil {
+		return nil, err
+	}
+	results := []string{}
+	for _, obj := range objs {
+		results = append(results, obj.Name)
+	}
+	return results, nil
+}
+
+// list the attributes of all the objects of the prefix in the bucket
+func listObjectsByPrefix(ctx context.Context, bucket *storage.BucketHandle, prefix, delim string) ([]*storage.ObjectAttrs, error) {
 	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
 	defer cancel()
-	it := client.Bucket(bucket).Objects(ctx, &storage.Query{
+	it := bucket.Objects(ctx, &storage.Query{
 		Prefix:    prefix,
 		Delimiter: delim,
 	})
-	results := []string{}
+	results := []*storage.ObjectAttrs{}
 	for {
 		attrs, err := it.Next()
 		if err == iterator.Done {
@@ -147,104 +513,697 @@ func listByPrefix(client *storage.Client, bucket, prefix, delim string) ([]strin
 		if err != nil {
 			return nil, err
 		}
-		results = append(results, attrs.Name)
+		results = append(results, attrs)
 	}
 	return results, nil
 }
 
 // Save an artifact to GCS compliant storage, e.g., uploading a local file to GCS bucket
-func (g *ArtifactDriver) Save(path string, outputArtifact *wfv1.Artifact) error {
+func (g *ArtifactDriver) Save(ctx context.Context, path string, outputArtifact *wfv1.Artifact) error {
+	return g.SaveWithProgress(ctx, path, outputArtifact, nil)
+}
+
+// SaveWithProgress saves an artifact to GCS. The progress restarts if the upload is retried.
+func (g *ArtifactDriver) SaveWithProgress(ctx context.Context, path string, outputArtifact *wfv1.Artifact, progress common.ProgressFunc) error {
+	_, err := g.save(ctx, path, outputArtifact, nil, progress)
+	return err
+}
+
+// SaveWithContentEncoding saves a file to GCS, recording its encoding in the metadata of the object
+func (g *ArtifactDriver) SaveWithContentEncoding(ctx context.Context, path string, outputArtifact *wfv1.Artifact, encoding string, progress common.ProgressFunc) error {
+	_, err := g.SaveWithResult(ctx, path, outputArtifact, encoding, progress)
+	return err
+}
+
+// SaveWithResult saves an artifact to GCS, returning the ETag, generation and size of each object, as GCS reported
+// them once it was uploaded. The generation is the version ID.
+func (g *ArtifactDriver) SaveWithResult(ctx context.Context, path string, outputArtifact *wfv1.Artifact, encoding string, progress common.ProgressFunc) (*common.SaveResult, error) {
+	var metadata map[string]string
+	if encoding != "" {
+		metadata = map[string]string{common.ContentEncodingMetadataKey: encoding}
+	}
+	return g.save(ctx, path, outputArtifact, metadata, progress)
+}
+
+// ContentEncoding returns the encoding recorded in the metadata of the object of an artifact
+func (g *ArtifactDriver) ContentEncoding(artifact *wfv1.Artifact) (string, error) {
+	client, err := g.newGCSClient(g.loadsAnonymously())
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+	bucket, err := g.bucket(client, artifact.GCS.Bucket)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	attrs, err := bucket.Object(artifact.GCS.Key).Attrs(ctx)
+	if err == storage.ErrObjectNotExist {
+		return "", common.NewNotFoundError(err)
+	}
+	if err != nil {
+		return "", err
+	}
+	return attrs.Metadata[common.ContentEncodingMetadataKey], nil
+}
+
+// Metadata returns the custom metadata of the object of an artifact, which a directory does not have
+func (g *ArtifactDriver) Metadata(artifact *wfv1.Artifact) (map[string]string, error) {
+	client, err := g.newGCSClient(g.loadsAnonymously())
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	bucket, err := g.bucket(client, artifact.GCS.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	return objectMetadata(ctx, bucket, artifact.GCS.Key)
+}
+
+// objectMetadata returns the custom metadata of the object with the key, without that the drivers record themselves
+func objectMetadata(ctx context.Context, bucket *storage.BucketHandle, key string) (map[string]string, error) {
+	attrs, err := bucket.Object(key).Attrs(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil, common.NewNotFoundError(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return common.UserMetadata(attrs.Metadata), nil
+}
+
+// save saves an artifact, with the metadata if it is a file, returning the objects it uploaded
+func (g *ArtifactDriver) save(ctx context.Context, path string, outputArtifact *wfv1.Artifact, metadata map[string]string, progress common.ProgressFunc) (*common.SaveResult, error) {
+	if err := g.checkAuthenticated("Save"); err != nil {
+		return nil, err
+	}
+	logger := g.logger(outputArtifact.GCS)
+	attempt := 0
+	var result *common.SaveResult
 	err := wait.ExponentialBackoff(wait.Backoff{Duration: time.Second * 2, Factor: 2.0, Steps: 5, Jitter: 0.1},
 		func() (bool, error) {
-			log.Infof("GCS Save path: %s, key: %s", path, outputArtifact.GCS.Key)
-			client, err := g.newGCSClient()
+			logger.Infof("GCS Save path: %s, key: %s", path, outputArtifact.GCS.Key)
+			attempt++
+			logger.WithField("attempt", attempt).Debug("Saving to GCS")
+			if err := ctx.Err(); err != nil {
+				return false, err
+			}
+			client, err := g.newGCSClient(false)
 			if err != nil {
 				return false, err
 			}
 			defer client.Close()
-			err = uploadObjects(client, outputArtifact.GCS.Bucket, outputArtifact.GCS.Key, path)
+			bucket, err := g.bucket(client, outputArtifact.GCS.Bucket)
+			if err != nil {
+				return false, err
+			}
+			result, err = saveToBucket(ctx, bucket, path, outputArtifact, metadata, g.uploadChunkSize(), g.EncryptionKey, progress)
 			if err != nil {
 				return false, err
 			}
 			return true, nil
 		})
-	return err
-}
-
-// list all the file relative paths under a dir
-// path is suppoese to be a dir
-// relPath is a given relative path to be inserted in front
-func listFileRelPaths(path string, relPath string) ([]string, error) {
-	results := []string{}
-	files, err := ioutil.ReadDir(path)
 	if err != nil {
 		return nil, err
 	}
-	for _, file := range files {
-		if file.IsDir() {
-			fs, err := listFileRelPaths(path+file.Name()+string(os.PathSeparator), relPath+file.Name()+string(os.PathSeparator))
-			if err != nil {
+	return result, nil
+}
+
+// fileFilter returns the filter of the files of a directory artifact, which follows symlinks, as GCS always has, unless
+// the artifact has another symlink policy
+func fileFilter(art *wfv1.Artifact) common.FileFilter {
+	filter := common.NewFileFilter(art)
+	if filter.Symlinks == "" {
+		filter.Symlinks = wfv1.ArtifactSymlinksFollow
+	}
+	return filter
+}
+
+// saveToBucket uploads path to the key of the artifact, unless its overwrite policy does not allow replacing the key
+// and it exists, or is skip-if-unchanged and it has the content of path, or a previous attempt with the same idempotency
+// key uploaded it, returning the objects it uploaded. A directory saved atomically is uploaded under a staging key, and only copied to the key once every file is uploaded.
+func saveToBucket(ctx context.Context, bucket *storage.BucketHandle, path string, outputArtifact *wfv1.Artifact, metadata map[string]string, chunkSize int, encryptionKey []byte, progress common.ProgressFunc) (*common.SaveResult, error) {
+	location := fmt.Sprintf("gs://%s/%s", outputArtifact.GCS.Bucket, outputArtifact.GCS.Key)
+	doesNotExist := common.IsOverwriteProtected(outputArtifact.Overwrite)
+	// whether path is a directory is tested again, and its error returned, by uploadObjects
+	isDir, _ := file.IsDirectory(path)
+	if common.SkipsUnchanged(outputArtifact.Overwrite) {
+		ok, err := unchanged(ctx, bucket, path, isDir, outputArtifact, encryptionKey)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return common.NewUnchangedResult(location), nil
+		}
+		if !isDir {
+			if metadata, err = common.WithContentSHA256(metadata, path); err != nil {
 				return nil, err
 			}
-			results = append(results, fs...)
+		}
+	}
+	idempotencyKey := common.IdempotencyKey(outputArtifact)
+	var manifestMetadata map[string]string
+	if idempotencyKey != "" {
+		ok, err := savedIdempotently(ctx, bucket, path, isDir, outputArtifact, encryptionKey, idempotencyKey)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			log.Infof("A previous attempt with the same idempotency key already saved %s", location)
+			return common.NewUnchangedResult(location), nil
+		}
+		// the key is recorded in the metadata of the object of a file, with its sha256, or of the manifest of a
+		// directory, which is uploaded once every file is
+		if isDir {
+			manifestMetadata = common.WithIdempotencyKey(nil, idempotencyKey)
 		} else {
-			results = append(results, relPath+file.Name())
+			if metadata[common.ContentSHA256MetadataKey] == "" {
+				if metadata, err = common.WithContentSHA256(metadata, path); err != nil {
+					return nil, err
+				}
+			}
+			metadata = common.WithIdempotencyKey(metadata, idempotencyKey)
 		}
 	}
-	return results, nil
+	metadata = common.WithMetadata(metadata, outputArtifact)
+	manifestMetadata = common.WithMetadata(manifestMetadata, outputArtifact)
+	// the directory exists if any object does under its key, not only those of its files
+	if isDir && doesNotExist {
+		exists, err := exists(bucket, outputArtifact.GCS.Key)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, common.NewExistsError(location)
+		}
+	}
+	key := outputArtifact.GCS.Key
+	staging := ""
+	if isDir && outputArtifact.AtomicSave {
+		var err error
+		if staging, err = common.StagingKey(key); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err := deleteObjects(bucket, staging); err != nil {
+				log.Warnf("Failed to delete the staging objects under %s: %v", staging, err)
+			}
+		}()
+		key = staging
+	}
+	objects, err := uploadObjects(ctx, bucket, key, path, fileFilter(outputArtifact), metadata, outputArtifact.ContentType, chunkSize, encryptionKey, doesNotExist, progress)
+	if err == nil && staging != "" {
+		objects, err = copyObjects(ctx, bucket, outputArtifact.GCS.Key, staging, objects, encryptionKey, doesNotExist)
+	}
+	if isPreconditionFailed(err) {
+		return nil, common.NewExistsError(location)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if format := common.ManifestFormat(outputArtifact); isDir && format != "" {
+		if err := uploadManifest(ctx, bucket, common.ManifestKey(outputArtifact.GCS.Key), path, format, objects, manifestMetadata, encryptionKey); err != nil {
+			return nil, fmt.Errorf("upload manifest: %w", err)
+		}
+	}
+	return common.NewSaveResult(location, objects), nil
+}
+
+// copyObjects copies the objects uploaded under the staging key of a directory to its key, only if they do not exist
+// if doesNotExist is set, returning the copies. If any copy fails, those which succeeded are deleted.
+func copyObjects(ctx context.Context, bucket *storage.BucketHandle, key, staging string, staged []common.SavedObject, encryptionKey []byte, doesNotExist bool) ([]common.SavedObject, error) {
+	objects := make([]common.SavedObject, 0, len(staged))
+	for _, stagedObject := range staged {
+		dst := object(bucket, common.StagedKey(key, staging, stagedObject.Key), encryptionKey)
+		if doesNotExist {
+			dst = dst.If(storage.Conditions{DoesNotExist: true})
+		}
+		attrs, err := dst.CopierFrom(object(bucket, stagedObject.Key, encryptionKey)).Run(ctx)
+		if err != nil {
+			for _, copied := range objects {
+				if err := bucket.Object(copied.Key).Delete(ctx); err != nil && err != storage.ErrObjectNotExist {
+					log.Warnf("Failed to delete %s, which a failed save was copied to: %v", copied.Key, err)
+				}
+			}
+			return nil, fmt.Errorf("copy %s: %w", stagedObject.Key, err)
+		}
+		objects = append(objects, common.SavedObject{Key: attrs.Name, Path: stagedObject.Path, Size: attrs.Size, ETag: attrs.Etag, VersionID: strconv.FormatInt(attrs.Generation, 10), LinkTarget: stagedObject.LinkTarget})
+	}
+	return objects, nil
+}
+
+// unchanged returns whether the key of the artifact has the content of the file or directory at path. A file is
+// compared by the sha256 recorded in the metadata of its object, or otherwise its md5, and a directory by its manifest.
+func unchanged(ctx context.Context, bucket *storage.BucketHandle, path string, isDir bool, outputArtifact *wfv1.Artifact, encryptionKey []byte) (bool, error) {
+	key := outputArtifact.GCS.Key
+	if isDir {
+		manifest, err := readManifest(ctx, bucket, common.ManifestKey(key), encryptionKey)
+		if err == storage.ErrObjectNotExist {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return common.DirectoryUnchanged(path, fileFilter(outputArtifact), manifest)
+	}
+	attrs, err := object(bucket, key, encryptionKey).Attrs(ctx)
+	if err == storage.ErrObjectNotExist {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return common.FileUnchanged(path, attrs.Metadata[common.ContentSHA256MetadataKey], hex.EncodeToString(attrs.MD5))
 }
 
-// upload a local file or dir to GCS
-func uploadObjects(client *storage.Client, bucket, key, path string) error {
+// savedIdempotently returns whether a previous attempt of the save of the artifact, with the same idempotency key,
+// uploaded the file or directory at path: the key is recorded in the metadata of the object of the file, or of the
+// manifest of the directory, which must also have its content
+func savedIdempotently(ctx context.Context, bucket *storage.BucketHandle, path string, isDir bool, outputArtifact *wfv1.Artifact, encryptionKey []byte, idempotencyKey string) (bool, error) {
+	key := outputArtifact.GCS.Key
+	if isDir {
+		key = common.ManifestKey(key)
+	}
+	attrs, err := object(bucket, key, encryptionKey).Attrs(ctx)
+	if err == storage.ErrObjectNotExist {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if attrs.Metadata[common.IdempotencyKeyMetadataKey] != idempotencyKey {
+		return false, nil
+	}
+	return unchanged(ctx, bucket, path, isDir, outputArtifact, encryptionKey)
+}
+
+// uploadManifest uploads the manifest of the objects uploaded from the files of the directory path, with the metadata,
+// replacing any manifest of an earlier save
+func uploadManifest(ctx context.Context, bucket *storage.BucketHandle, key, path string, format wfv1.ArtifactManifestFormat, objects []common.SavedObject, metadata map[string]string, encryptionKey []byte) error {
+	manifest, err := common.NewManifest(path, objects)
+	if err != nil {
+		return err
+	}
+	data, err := manifest.Marshal(format)
+	if err != nil {
+		return err
+	}
+	wc := object(bucket, key, encryptionKey).NewWriter(ctx)
+	wc.ContentType = common.ManifestContentType(format)
+	wc.Metadata = metadata
+	// the manifest is uploaded in a single request, rather than buffered as a chunk of a resumable upload
+	wc.ChunkSize = 0
+	if _, err := wc.Write(data); err != nil {
+		_ = wc.Close()
+		return err
+	}
+	return wc.Close()
+}
+
+// ProtectsOverwrite returns true: unless the overwrite policy of an artifact is allow, its objects are uploaded with
+// a DoesNotExist precondition, and a directory is not saved if any object exists under its key
+func (g *ArtifactDriver) ProtectsOverwrite() bool {
+	return true
+}
+
+// SkipsUnchanged returns true: a file is not uploaded if the sha256 recorded in the metadata of its object, or
+// otherwise its md5, is that of the file, nor a directory if its manifest is that of its files
+func (g *ArtifactDriver) SkipsUnchanged() bool {
+	return true
+}
+
+// SavesAtomically returns true: a directory is uploaded under a staging key, and its objects copied to its key by GCS
+func (g *ArtifactDriver) SavesAtomically() bool {
+	return true
+}
+
+// SavesIdempotently returns true: an artifact is not uploaded if the idempotency key recorded in the metadata of its
+// object, or of the manifest of a directory, is its own, and it has the content of the file or directory
+func (g *ArtifactDriver) SavesIdempotently() bool {
+	return true
+}
+
+// LimitsSize returns true: the total size of the objects of an artifact, as they are listed, is checked against its max
+// size before any is downloaded
+func (g *ArtifactDriver) LimitsSize() bool {
+	return true
+}
+
+// isPreconditionFailed returns whether an upload failed because the object exists, which its DoesNotExist
+// precondition forbids
+func isPreconditionFailed(err error) bool {
+	var apiErr *googleapi.Error
+	return stderrors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed
+}
+
+// upload a local file, or the files of a dir which match filter, to GCS, with the metadata and the content type, or
+// otherwise that detected for each file, only if they do not exist if doesNotExist is set, returning the objects
+// uploaded
+func uploadObjects(ctx context.Context, bucket *storage.BucketHandle, key, path string, filter common.FileFilter, metadata map[string]string, contentType string, chunkSize int, encryptionKey []byte, doesNotExist bool, progress common.ProgressFunc) ([]common.SavedObject, error) {
 	isDir, err := file.IsDirectory(path)
 	if err != nil {
-		return fmt.Errorf("test if %s is a dir: %v", path, err)
+		return nil, fmt.Errorf("test if %s is a dir: %v", path, err)
 	}
+	var objects []common.SavedObject
 	if isDir {
-		dirName := filepath.Clean(path) + string(os.PathSeparator)
 		keyPrefix := filepath.Clean(key) + "/"
-		fileRelPaths, err := listFileRelPaths(dirName, "")
+		if os.PathSeparator == '\\' {
+			keyPrefix = strings.ReplaceAll(keyPrefix, "\\", "/")
+		}
+		var files []common.WalkedFile
+		var total int64
+		err := filter.Walk(path, func(f common.WalkedFile) error {
+			files = append(files, f)
+			if f.LinkTarget == "" {
+				total += f.Info.Size()
+			}
+			return nil
+		})
 		if err != nil {
-			return err
+			return nil, err
 		}
-		for _, relPath := range fileRelPaths {
-			fullKey := keyPrefix + relPath
-			if os.PathSeparator == '\\' {
-				fullKey = strings.ReplaceAll(fullKey, "\\", "/")
+		counter := common.NewProgressCounter(total, progress)
+		for _, f := range files {
+			var object common.SavedObject
+			if f.LinkTarget != "" {
+				object, err = uploadSymlink(ctx, bucket, keyPrefix+f.RelPath, f.Path, f.LinkTarget, metadata, encryptionKey, doesNotExist)
+			} else {
+				object, err = uploadObject(ctx, bucket, keyPrefix+f.RelPath, f.Path, metadata, contentType, chunkSize, encryptionKey, doesNotExist, counter)
 			}
-
-			err = uploadObject(client, bucket, fullKey, dirName+relPath)
 			if err != nil {
-				return fmt.Errorf("upload %s: %v", dirName+relPath, err)
+				return nil, fmt.Errorf("upload %s: %w", f.Path, err)
 			}
+			objects = append(objects, object)
 		}
+		counter.Done()
 	} else {
 		objectKey := filepath.Clean(key)
 		if os.PathSeparator == '\\' {
 			objectKey = strings.ReplaceAll(objectKey, "\\", "/")
 		}
-		err = uploadObject(client, bucket, objectKey, path)
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		counter := common.NewProgressCounter(info.Size(), progress)
+		object, err := uploadObject(ctx, bucket, objectKey, path, metadata, contentType, chunkSize, encryptionKey, doesNotExist, counter)
 		if err != nil {
-			return fmt.Errorf("upload %s: %v", path, err)
+			return nil, fmt.Errorf("upload %s: %w", path, err)
 		}
+		objects = append(objects, object)
+		counter.Done()
 	}
-	return nil
+	return objects, nil
 }
 
-// upload an object to GCS
-func uploadObject(client *storage.Client, bucket, key, localPath string) error {
+// upload an object with the metadata and the content type, or otherwise that detected for the file, to GCS
+// uploadObject uploads a file in a resumable upload of chunks of chunkSize bytes, each of which the client retries
+// if it fails, so a transient failure resumes the upload rather than restarting it. Files smaller than a chunk are
+// uploaded in a single request instead, because resumable uploads buffer a whole chunk in memory. The object is
+// encrypted with the customer-supplied encryption key, if there is one, and is only created, with a DoesNotExist
+// precondition, if doesNotExist is set. The object is returned as GCS reported it was created.
+func uploadObject(ctx context.Context, bucket *storage.BucketHandle, key, localPath string, metadata map[string]string, contentType string, chunkSize int, encryptionKey []byte, doesNotExist bool, counter *common.ProgressCounter) (common.SavedObject, error) {
 	f, err := os.Open(localPath)
 	if err != nil {
-		return fmt.Errorf("os open: %v", err)
+		return common.SavedObject{}, fmt.Errorf("os open: %v", err)
 	}
 	defer f.Close()
-	ctx := context.Background()
-	wc := client.Bucket(bucket).Object(key).NewWriter(ctx)
+	info, err := f.Stat()
+	if err != nil {
+		return common.SavedObject{}, fmt.Errorf("os stat: %v", err)
+	}
+	contentType, err = common.ContentType(contentType, localPath)
+	if err != nil {
+		return common.SavedObject{}, fmt.Errorf("detect content type: %w", err)
+	}
+	obj := object(bucket, key, encryptionKey)
+	if doesNotExist {
+		obj = obj.If(storage.Conditions{DoesNotExist: true})
+	}
+	wc := obj.NewWriter(ctx)
+	wc.Metadata = metadata
+	wc.ContentType = contentType
+	wc.ChunkSize = chunkSize
+	if info.Size() < int64(chunkSize) {
+		wc.ChunkSize = 0
+	}
+	if counter != nil {
+		// the writer reports the bytes of this object uploaded so far
+		var uploaded int64
+		wc.ProgressFunc = func(n int64) {
+			counter.Add(n - uploaded)
+			uploaded = n
+		}
+	}
 	if _, err = io.Copy(wc, f); err != nil {
-		return fmt.Errorf("io copy: %v", err)
+		return common.SavedObject{}, fmt.Errorf("io copy: %w", err)
 	}
 	if err := wc.Close(); err != nil {
-		return fmt.Errorf("writer close: %v", err)
+		return common.SavedObject{}, fmt.Errorf("writer close: %w", err)
+	}
+	attrs := wc.Attrs()
+	return common.SavedObject{Key: key, Path: localPath, Size: attrs.Size, ETag: attrs.Etag, VersionID: strconv.FormatInt(attrs.Generation, 10)}, nil
+}
+
+// uploadSymlink uploads the symlink at localPath to the target as an empty object with the metadata and the target in
+// its metadata, only if it does not exist if doesNotExist is set
+func uploadSymlink(ctx context.Context, bucket *storage.BucketHandle, key, localPath, target string, metadata map[string]string, encryptionKey []byte, doesNotExist bool) (common.SavedObject, error) {
+	obj := object(bucket, key, encryptionKey)
+	if doesNotExist {
+		obj = obj.If(storage.Conditions{DoesNotExist: true})
+	}
+	wc := obj.NewWriter(ctx)
+	wc.Metadata = map[string]string{common.SymlinkTargetMetadataKey: target}
+	for k, v := range metadata {
+		wc.Metadata[k] = v
+	}
+	if err := wc.Close(); err != nil {
+		return common.SavedObject{}, fmt.Errorf("writer close: %w", err)
+	}
+	attrs := wc.Attrs()
+	return common.SavedObject{Key: key, Path: localPath, ETag: attrs.Etag, VersionID: strconv.FormatInt(attrs.Generation, 10), LinkTarget: target}, nil
+}
+
+// Presign returns a V4 signed URL for requests of the method of the object of an artifact, which is signed with the
+// private key of the service account key, so it cannot be signed with other credentials, e.g. those of the metadata
+// server. It is a URL of the endpoint, if the driver has one.
+func (g *ArtifactDriver) Presign(artifact *wfv1.Artifact, method string, expiry time.Duration) (string, error) {
+	g.Log().Infof("GCS Presign key: %s, method: %s, expiry: %v", artifact.GCS.Key, method, expiry)
+	if err := common.ValidatePresign(artifact.GCS.Key, method, expiry); err != nil {
+		return "", err
+	}
+	if g.ServiceAccountKey == "" {
+		return "", errors.Errorf(errors.CodeBadRequest, "GCS signed URLs are signed with the private key of a service account key, and there is none")
+	}
+	if len(g.EncryptionKey) > 0 {
+		// requests of objects encrypted with a customer-supplied key need the key in their headers
+		return "", errors.Errorf(errors.CodeBadRequest, "GCS signed URLs cannot be of objects encrypted with a customer-supplied key")
+	}
+	conf, err := google.JWTConfigFromJSON([]byte(g.ServiceAccountKey))
+	if err != nil {
+		return "", fmt.Errorf("parse service account key: %w", err)
+	}
+	if g.Endpoint != nil {
+		return signedURL(g.Endpoint, artifact.GCS.Bucket, artifact.GCS.Key, method, conf.Email, conf.PrivateKey, time.Now(), expiry)
+	}
+	return storage.SignedURL(artifact.GCS.Bucket, artifact.GCS.Key, &storage.SignedURLOptions{
+		GoogleAccessID: conf.Email,
+		PrivateKey:     conf.PrivateKey,
+		Method:         method,
+		Expires:        time.Now().Add(expiry),
+		Scheme:         storage.SigningSchemeV4,
+	})
+}
+
+// Exists returns whether an object, or a "directory" of objects, exists with the key of the artifact
+func (g *ArtifactDriver) Exists(artifact *wfv1.Artifact) (bool, error) {
+	g.Log().Infof("GCS Exists key: %s", artifact.GCS.Key)
+	client, err := g.newGCSClient(g.loadsAnonymously())
+	if err != nil {
+		return false, err
+	}
+	defer client.Close()
+	bucket, err := g.bucket(client, artifact.GCS.Bucket)
+	if err != nil {
+		return false, err
+	}
+	return exists(bucket, artifact.GCS.Key)
+}
+
+func exists(bucket *storage.BucketHandle, key string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	_, err := bucket.Object(key).Attrs(ctx)
+	if err == nil {
+		return true, nil
+	}
+	if err != storage.ErrObjectNotExist {
+		return false, err
+	}
+	prefix := filepath.Clean(key) + "/"
+	if os.PathSeparator == '\\' {
+		prefix = strings.ReplaceAll(prefix, "\\", "/")
+	}
+	it := bucket.Objects(ctx, &storage.Query{Prefix: prefix})
+	_, err = it.Next()
+	if err == iterator.Done {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// objectsCreatePermission is the permission to create objects in a bucket
+const objectsCreatePermission = "storage.objects.create"
+
+// DryRunSave checks that the bucket of an artifact exists, and that objects can be created in it, and returns the
+// objects saving path would upload
+func (g *ArtifactDriver) DryRunSave(ctx context.Context, path string, outputArtifact *wfv1.Artifact) (*common.DryRunResult, error) {
+	g.Log().Infof("GCS dry run of Save path: %s, key: %s", path, outputArtifact.GCS.Key)
+	if err := g.checkAuthenticated("Save"); err != nil {
+		return nil, err
+	}
+	client, err := g.newGCSClient(false)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	bucket, err := g.bucket(client, outputArtifact.GCS.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	return dryRunSave(ctx, bucket, path, outputArtifact)
+}
+
+func dryRunSave(ctx context.Context, bucket *storage.BucketHandle, path string, outputArtifact *wfv1.Artifact) (*common.DryRunResult, error) {
+	gcsArtifact := outputArtifact.GCS
+	location := fmt.Sprintf("gs://%s/%s", gcsArtifact.Bucket, gcsArtifact.Key)
+	result, err := common.NewDryRunResult(location, gcsArtifact.Key, path, fileFilter(outputArtifact))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+	if _, err := bucket.Attrs(ctx); err != nil {
+		if err == storage.ErrBucketNotExist {
+			return nil, errors.Errorf(errors.CodeNotFound, "bucket %s does not exist", gcsArtifact.Bucket)
+		}
+		return nil, err
+	}
+	permissions, err := bucket.IAM().TestPermissions(ctx, []string{objectsCreatePermission})
+	if err != nil {
+		return nil, err
+	}
+	if len(permissions) == 0 {
+		return nil, errors.Errorf(errors.CodeForbidden, "permission %s is required to save to bucket %s", objectsCreatePermission, gcsArtifact.Bucket)
+	}
+	result.WritePermissionChecked = true
+	return result, nil
+}
+
+// HealthCheck checks that the bucket of the driver exists, by getting its metadata, which is denied if the credentials
+// are not accepted
+func (g *ArtifactDriver) HealthCheck(ctx context.Context) error {
+	g.Log().Infof("GCS HealthCheck bucket: %s", g.Bucket)
+	client, err := g.newGCSClient(g.Anonymous)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	bucket, err := g.bucket(client, g.Bucket)
+	if err != nil {
+		return err
+	}
+	return healthCheck(ctx, bucket, g.Bucket)
+}
+
+func healthCheck(ctx context.Context, bucket *storage.BucketHandle, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+	if _, err := bucket.Attrs(ctx); err != nil {
+		if err == storage.ErrBucketNotExist {
+			return errors.Errorf(errors.CodeNotFound, "bucket %s does not exist", name)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete deletes every object of a key from GCS
+func (g *ArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	if err := g.checkAuthenticated("Delete"); err != nil {
+		return err
+	}
+	logger := g.logger(artifact.GCS)
+	attempt := 0
+	err := wait.ExponentialBackoff(wait.Backoff{Duration: time.Second * 2, Factor: 2.0, Steps: 5, Jitter: 0.1},
+		func() (bool, error) {
+			logger.Infof("GCS Delete key: %s", artifact.GCS.Key)
+			attempt++
+			logger.WithField("attempt", attempt).Debug("Deleting from GCS")
+			client, err := g.newGCSClient(false)
+			if err != nil {
+				return false, err
+			}
+			defer client.Close()
+			bucket, err := g.bucket(client, artifact.GCS.Bucket)
+			if err != nil {
+				return false, err
+			}
+			err = deleteObjects(bucket, artifact.GCS.Key)
+			if err != nil {
+				return false, err
+			}
+			return true, nil
+		})
+	return err
+}
+
+// Capabilities returns the optional operations on GCS artifacts the driver supports
+func (g *ArtifactDriver) Capabilities() common.DriverCapabilities {
+	return common.DriverCapabilities{
+		Delete:              true,
+		Stream:              true,
+		LoadRange:           true,
+		Exists:              true,
+		HealthCheck:         true,
+		Progress:            true,
+		ContentEncoding:     true,
+		OverwriteProtection: true,
+		SkipUnchanged:       true,
+		AtomicSave:          true,
+		IdempotentSave:      true,
+		MaxSize:             true,
+		Metadata:            true,
+		DryRunSave:          true,
+		SaveResult:          true,
+		Presign:             true,
+	}
+}
+
+// delete all the objects of a key from the bucket
+func deleteObjects(bucket *storage.BucketHandle, key string) error {
+	ctx := context.Background()
+	objNames, err := listByPrefix(ctx, bucket, key, "")
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, objName := range objNames {
+		err = bucket.Object(objName).Delete(ctx)
+		if err != nil && err != storage.ErrObjectNotExist {
+			log.Warnf("Failed to delete %s: %v", objName, err)
+			failed = append(failed, objName)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("delete objects %s: %d object(s) failed", strings.Join(failed, ", "), len(failed))
 	}
 	return nil
 }
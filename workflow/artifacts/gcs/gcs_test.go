@@ -0,0 +1,1206 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	apiv1 "k8s.io/api/core/v1"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+// newTestClient returns a client of a fake GCS JSON API, which has the object "my-file" and the
+// "directory" "my-dir" in the bucket "my-bucket", and denies access to the bucket "forbidden".
+// The userProject of each request is recorded in userProjects.
+func newTestClient(t *testing.T, userProjects *[]string) (*storage.Client, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if userProjects != nil {
+			*userProjects = append(*userProjects, r.URL.Query().Get("userProject"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.Contains(r.URL.Path, "/b/forbidden/"):
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"error":{"code":403,"message":"Forbidden"}}`))
+		case strings.HasSuffix(r.URL.Path, "/b/my-bucket/o/my-file"):
+			_, _ = w.Write([]byte(`{"kind":"storage#object","bucket":"my-bucket","name":"my-file"}`))
+		case strings.HasSuffix(r.URL.Path, "/b/my-bucket/o") && r.URL.Query().Get("prefix") == "my-dir/":
+			_, _ = w.Write([]byte(`{"kind":"storage#objects","items":[{"kind":"storage#object","bucket":"my-bucket","name":"my-dir/my-file"}]}`))
+		case strings.HasSuffix(r.URL.Path, "/b/my-bucket/o"):
+			_, _ = w.Write([]byte(`{"kind":"storage#objects"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"code":404,"message":"Not Found"}}`))
+		}
+	}))
+	client, err := storage.NewClient(context.Background(), option.WithEndpoint(server.URL+"/storage/v1/"), option.WithoutAuthentication())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return client, func() {
+		_ = client.Close()
+		server.Close()
+	}
+}
+
+func TestExists(t *testing.T) {
+	client, closer := newTestClient(t, nil)
+	defer closer()
+	t.Run("Object", func(t *testing.T) {
+		ok, err := exists(client.Bucket("my-bucket"), "my-file")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+	t.Run("Directory", func(t *testing.T) {
+		ok, err := exists(client.Bucket("my-bucket"), "my-dir")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+	t.Run("Absent", func(t *testing.T) {
+		ok, err := exists(client.Bucket("my-bucket"), "not-found")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+	t.Run("Error", func(t *testing.T) {
+		_, err := exists(client.Bucket("forbidden"), "my-file")
+		assert.Error(t, err)
+	})
+}
+
+func TestRequesterPays(t *testing.T) {
+	var userProjects []string
+	client, closer := newTestClient(t, &userProjects)
+	defer closer()
+	t.Run("BillingProject", func(t *testing.T) {
+		userProjects = nil
+		driver := &ArtifactDriver{RequesterPays: true, BillingProject: "my-project"}
+		bucket, err := driver.bucket(client, "my-bucket")
+		if assert.NoError(t, err) {
+			_, err = exists(bucket, "my-dir")
+			assert.NoError(t, err)
+			_, err = listObjectsByPrefix(context.Background(), bucket, "my-dir", "")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"my-project", "my-project", "my-project"}, userProjects)
+		}
+	})
+	t.Run("ServiceAccountKeyProject", func(t *testing.T) {
+		driver := &ArtifactDriver{RequesterPays: true, ServiceAccountKey: `{"type":"service_account","project_id":"key-project","client_email":"me@key-project.iam.gserviceaccount.com"}`}
+		bucket, err := driver.bucket(client, "my-bucket")
+		if assert.NoError(t, err) {
+			userProjects = nil
+			_, err = exists(bucket, "my-file")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"key-project"}, userProjects)
+		}
+	})
+	t.Run("NoBillingProject", func(t *testing.T) {
+		driver := &ArtifactDriver{RequesterPays: true}
+		_, err := driver.bucket(client, "my-bucket")
+		assert.Error(t, err)
+	})
+	t.Run("NotRequesterPays", func(t *testing.T) {
+		userProjects = nil
+		driver := &ArtifactDriver{BillingProject: "my-project"}
+		bucket, err := driver.bucket(client, "my-bucket")
+		if assert.NoError(t, err) {
+			_, err = exists(bucket, "my-file")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{""}, userProjects)
+		}
+	})
+}
+
+func TestProxyURL(t *testing.T) {
+	var requests []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.Host)
+		if r.Host == "oauth2.example.com" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token":"my-token","token_type":"Bearer","expires_in":3600}`))
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	assert.NoError(t, err)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	serviceAccountKey, err := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"project_id":   "my-project",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		"client_email": "my-account@my-project.iam.gserviceaccount.com",
+		"token_uri":    "http://oauth2.example.com/token",
+	})
+	assert.NoError(t, err)
+	g := &ArtifactDriver{ServiceAccountKey: string(serviceAccountKey), ProxyURL: proxyURL}
+	client, err := g.newGCSClient(false)
+	if assert.NoError(t, err) {
+		defer func() { _ = client.Close() }()
+		_, err = client.Bucket("my-bucket").Object("my-file").Attrs(context.Background())
+		assert.Error(t, err)
+		// both the token and the object were requested through the proxy
+		assert.Equal(t, []string{"POST oauth2.example.com", "CONNECT storage.googleapis.com:443"}, requests)
+	}
+}
+
+func TestArtifactDriver_Presign(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	serviceAccountKey, err := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"project_id":   "my-project",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		"client_email": "my-account@my-project.iam.gserviceaccount.com",
+		"token_uri":    "http://oauth2.example.com/token",
+	})
+	assert.NoError(t, err)
+	driver := &ArtifactDriver{ServiceAccountKey: string(serviceAccountKey)}
+	art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{GCS: &wfv1.GCSArtifact{GCSBucket: wfv1.GCSBucket{Bucket: "my-bucket"}, Key: "my-dir/my-file"}}}
+
+	t.Run("Get", func(t *testing.T) {
+		presigned, err := driver.Presign(art, http.MethodGet, 90*time.Minute)
+		if !assert.NoError(t, err) {
+			return
+		}
+		u, err := url.Parse(presigned)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "https", u.Scheme)
+		assert.Equal(t, "storage.googleapis.com", u.Host)
+		assert.Equal(t, "/my-bucket/my-dir/my-file", u.Path)
+		query := u.Query()
+		assert.Equal(t, "GOOG4-RSA-SHA256", query.Get("X-Goog-Algorithm"))
+		assert.Equal(t, "5400", query.Get("X-Goog-Expires"))
+		assert.True(t, strings.HasPrefix(query.Get("X-Goog-Credential"), "my-account@my-project.iam.gserviceaccount.com/"))
+		assert.Regexp(t, "^[0-9a-f]{512}$", query.Get("X-Goog-Signature"))
+		signed, err := time.Parse("20060102T150405Z", query.Get("X-Goog-Date"))
+		if assert.NoError(t, err) {
+			assert.WithinDuration(t, time.Now(), signed, time.Minute)
+		}
+	})
+	t.Run("Directory", func(t *testing.T) {
+		dir := art.DeepCopy()
+		dir.GCS.Key = "my-dir/"
+		_, err := driver.Presign(dir, http.MethodGet, time.Hour)
+		assert.Error(t, err)
+	})
+	t.Run("NoServiceAccountKey", func(t *testing.T) {
+		_, err := (&ArtifactDriver{}).Presign(art, http.MethodGet, time.Hour)
+		assert.Error(t, err)
+	})
+	t.Run("EncryptionKey", func(t *testing.T) {
+		_, err := (&ArtifactDriver{ServiceAccountKey: string(serviceAccountKey), EncryptionKey: make([]byte, 32)}).Presign(art, http.MethodGet, time.Hour)
+		assert.Error(t, err)
+	})
+}
+
+// redirectTransport sends every request to the host, whatever the host of its URL, e.g. the reads of objects, which
+// the client sends to storage.googleapis.com rather than its endpoint
+type redirectTransport struct {
+	host string
+}
+
+func (t redirectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme = "http"
+	r.URL.Host = t.host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestDownloadRange(t *testing.T) {
+	data := []byte("0123456789")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/my-bucket/my-file" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		http.ServeContent(w, r, "my-file", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx, option.WithHTTPClient(&http.Client{Transport: redirectTransport{host: strings.TrimPrefix(server.URL, "http://")}}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = client.Close() }()
+	dir, err := ioutil.TempDir("", "range")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	bucket := client.Bucket("my-bucket")
+	fullPath := filepath.Join(dir, "full")
+	if !assert.NoError(t, downloadRange(ctx, bucket, "my-file", 0, -1, fullPath, nil)) {
+		return
+	}
+	full, err := ioutil.ReadFile(fullPath)
+	if !assert.NoError(t, err) || !assert.Equal(t, data, full) {
+		return
+	}
+	for _, r := range []struct{ start, length, end int64 }{{0, 4, 4}, {3, 5, 8}, {6, -1, 10}, {8, 100, 10}} {
+		path := filepath.Join(dir, "range")
+		if assert.NoError(t, downloadRange(ctx, bucket, "my-file", r.start, r.length, path, nil)) {
+			got, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, string(full[r.start:r.end]), string(got), "%v", r)
+		}
+	}
+	err = downloadRange(ctx, bucket, "not-found", 0, 4, filepath.Join(dir, "not-found"), nil)
+	assert.True(t, errors.Is(err, common.ErrArtifactNotFound))
+}
+
+// fakeUploads is a fake of the GCS JSON API uploads of objects, which fails one chunk of a resumable upload after
+// the first, once. Uploads with a DoesNotExist precondition fail if the object exists, and objects can be got and
+// listed, so that whether they exist can be tested. Each object uploaded is reported with a new generation, and the
+// content type it was uploaded with is recorded. Objects can be copied, with the same precondition, and deleted, and
+// the multipart uploads of objects whose names end with failName are forbidden.
+type fakeUploads struct {
+	lock         sync.Mutex
+	uploadTypes  []string
+	objects      map[string][]byte
+	contentTypes map[string]string
+	failedChunks int
+	generation   int64
+	copies       []string
+	failName     string
+}
+
+// uploadAttrs are the attributes of an object uploaded
+type uploadAttrs struct {
+	Name        string `json:"name"`
+	ContentType string `json:"contentType"`
+}
+
+func (f *fakeUploads) recordContentType(attrs uploadAttrs) {
+	if f.contentTypes == nil {
+		f.contentTypes = map[string]string{}
+	}
+	f.contentTypes[attrs.Name] = attrs.ContentType
+}
+
+// created returns the JSON of the object once it is uploaded, with its size, a new generation and an ETag of it
+func (f *fakeUploads) created(name string) string {
+	f.generation++
+	return fmt.Sprintf(`{"bucket":"my-bucket","name":%q,"size":"%d","generation":"%d","etag":"etag-%d"}`, name, len(f.objects[name]), f.generation, f.generation)
+}
+
+func (f *fakeUploads) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodPost && r.URL.Query().Get("uploadType") == "resumable":
+		f.uploadTypes = append(f.uploadTypes, "resumable")
+		var attrs uploadAttrs
+		if err := json.NewDecoder(r.Body).Decode(&attrs); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if f.preconditionFailed(w, r, attrs.Name) {
+			return
+		}
+		f.recordContentType(attrs)
+		f.objects[attrs.Name] = nil
+		w.Header().Set("Location", "http://"+r.Host+"/upload/session?name="+url.QueryEscape(attrs.Name))
+	case r.Method == http.MethodPost && r.URL.Query().Get("uploadType") == "multipart":
+		f.uploadTypes = append(f.uploadTypes, "multipart")
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		parts := multipart.NewReader(r.Body, params["boundary"])
+		var attrs uploadAttrs
+		part, err := parts.NextPart()
+		if err == nil {
+			err = json.NewDecoder(part).Decode(&attrs)
+		}
+		if err == nil {
+			part, err = parts.NextPart()
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if f.preconditionFailed(w, r, attrs.Name) {
+			return
+		}
+		if f.failName != "" && strings.HasSuffix(attrs.Name, f.failName) {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"error":{"code":403,"message":"Forbidden"}}`))
+			return
+		}
+		f.recordContentType(attrs)
+		f.objects[attrs.Name], _ = ioutil.ReadAll(part)
+		_, _ = fmt.Fprint(w, f.created(attrs.Name))
+	case r.Method == http.MethodPut && r.URL.Path == "/upload/session":
+		name := r.URL.Query().Get("name")
+		data, _ := ioutil.ReadAll(r.Body)
+		// e.g. "bytes 0-262143/*" for a chunk which is not the last, and "bytes */614400" for an empty last chunk
+		var start int
+		contentRange := strings.TrimPrefix(r.Header.Get("Content-Range"), "bytes ")
+		if !strings.HasPrefix(contentRange, "*") {
+			start, _ = strconv.Atoi(contentRange[:strings.Index(contentRange, "-")])
+		}
+		if start > 0 && f.failedChunks == 0 {
+			f.failedChunks++
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		f.objects[name] = append(f.objects[name][:start], data...)
+		if strings.HasSuffix(contentRange, "/*") {
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(f.objects[name])-1))
+			if r.Header.Get("X-GUploader-No-308") == "yes" {
+				w.Header().Set("X-Http-Status-Code-Override", "308")
+				return
+			}
+			w.WriteHeader(http.StatusPermanentRedirect)
+			return
+		}
+		_, _ = fmt.Fprint(w, f.created(name))
+	case r.Method == http.MethodPost && strings.Contains(r.URL.Path, "/rewriteTo/b/my-bucket/o/"):
+		names := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/storage/v1/b/my-bucket/o/"), "/rewriteTo/b/my-bucket/o/", 2)
+		data, ok := f.objects[names[0]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"code":404,"message":"Not Found"}}`))
+			return
+		}
+		if f.preconditionFailed(w, r, names[1]) {
+			return
+		}
+		f.copies = append(f.copies, names[1])
+		f.objects[names[1]] = data
+		_, _ = fmt.Fprintf(w, `{"kind":"storage#rewriteResponse","totalBytesRewritten":"%d","objectSize":"%d","done":true,"resource":%s}`, len(data), len(data), f.created(names[1]))
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/storage/v1/b/my-bucket/o/"):
+		delete(f.objects, strings.TrimPrefix(r.URL.Path, "/storage/v1/b/my-bucket/o/"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/storage/v1/b/my-bucket/o/"):
+		name := strings.TrimPrefix(r.URL.Path, "/storage/v1/b/my-bucket/o/")
+		if _, ok := f.objects[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"code":404,"message":"Not Found"}}`))
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"kind":"storage#object","bucket":"my-bucket","name":%q}`, name)
+	case r.Method == http.MethodGet && r.URL.Path == "/storage/v1/b/my-bucket/o":
+		var items []string
+		for name := range f.objects {
+			if strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+				items = append(items, fmt.Sprintf(`{"kind":"storage#object","bucket":"my-bucket","name":%q}`, name))
+			}
+		}
+		_, _ = fmt.Fprintf(w, `{"kind":"storage#objects","items":[%s]}`, strings.Join(items, ","))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// preconditionFailed fails the upload of an object which exists, if it is conditional on the object not existing
+func (f *fakeUploads) preconditionFailed(w http.ResponseWriter, r *http.Request, name string) bool {
+	if _, ok := f.objects[name]; !ok || r.URL.Query().Get("ifGenerationMatch") != "0" {
+		return false
+	}
+	w.WriteHeader(http.StatusPreconditionFailed)
+	_, _ = w.Write([]byte(`{"error":{"code":412,"message":"Precondition Failed"}}`))
+	return true
+}
+
+func TestUploadObject(t *testing.T) {
+	uploads := &fakeUploads{objects: map[string][]byte{}}
+	server := httptest.NewServer(uploads)
+	defer server.Close()
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx, option.WithEndpoint(server.URL+"/storage/v1/"), option.WithoutAuthentication())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer func() { _ = client.Close() }()
+	tmp, err := ioutil.TempDir("", "gcs")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+	const chunkSize = 256 * 1024
+
+	t.Run("Resumable", func(t *testing.T) {
+		uploads.uploadTypes = nil
+		data := bytes.Repeat([]byte("0123456789"), 60*1024)
+		path := filepath.Join(tmp, "my-large-file")
+		assert.NoError(t, ioutil.WriteFile(path, data, 0600))
+		if _, err := uploadObject(ctx, client.Bucket("my-bucket"), "my-large-file", path, nil, "", chunkSize, nil, false, nil); assert.NoError(t, err) {
+			assert.Equal(t, []string{"resumable"}, uploads.uploadTypes)
+			// the failed chunk was retried, rather than the upload restarted
+			assert.Equal(t, 1, uploads.failedChunks)
+			assert.Equal(t, data, uploads.objects["my-large-file"])
+		}
+	})
+	t.Run("Small", func(t *testing.T) {
+		uploads.uploadTypes = nil
+		path := filepath.Join(tmp, "my-file")
+		assert.NoError(t, ioutil.WriteFile(path, []byte("my-data"), 0600))
+		if _, err := uploadObject(ctx, client.Bucket("my-bucket"), "my-file", path, nil, "", chunkSize, nil, false, nil); assert.NoError(t, err) {
+			assert.Equal(t, []string{"multipart"}, uploads.uploadTypes)
+			assert.Equal(t, "my-data", string(uploads.objects["my-file"]))
+		}
+	})
+	t.Run("Filter", func(t *testing.T) {
+		uploads.objects = map[string][]byte{}
+		dir := filepath.Join(tmp, "my-dir")
+		for _, rel := range []string{"app.jar", "lib/dep.jar", "test/app-test.jar", "README.md"} {
+			assert.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(rel)), 0700))
+			assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, rel), []byte(rel), 0600))
+		}
+		filter := common.FileFilter{Include: []string{"**/*.jar"}, Exclude: []string{"test/**"}}
+		if _, err := uploadObjects(ctx, client.Bucket("my-bucket"), "my-dir", dir, filter, nil, "", chunkSize, nil, false, nil); assert.NoError(t, err) {
+			assert.Equal(t, map[string][]byte{"my-dir/app.jar": []byte("app.jar"), "my-dir/lib/dep.jar": []byte("lib/dep.jar")}, uploads.objects)
+		}
+	})
+}
+
+func TestSaveToBucket_ContentType(t *testing.T) {
+	uploads := &fakeUploads{objects: map[string][]byte{}}
+	server := httptest.NewServer(uploads)
+	defer server.Close()
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx, option.WithEndpoint(server.URL+"/storage/v1/"), option.WithoutAuthentication())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer func() { _ = client.Close() }()
+	bucket := client.Bucket("my-bucket")
+	tmp, err := ioutil.TempDir("", "gcs")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+	const chunkSize = 256 * 1024
+	dir := filepath.Join(tmp, "my-dir")
+	assert.NoError(t, os.MkdirAll(dir, 0700))
+	for name, data := range map[string]string{"data.json": "{}", "image": "\x89PNG\r\n\x1a\n", "log": "plain text"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600))
+	}
+	// the large file is uploaded in a resumable upload, whose attributes are in the request which starts it
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "large.json"), bytes.Repeat([]byte("[0, 1]\n"), 100*1024), 0600))
+	newArtifact := func(key, contentType string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{GCS: &wfv1.GCSArtifact{GCSBucket: wfv1.GCSBucket{Bucket: "my-bucket"}, Key: key}}, ContentType: contentType}
+	}
+
+	t.Run("Detected", func(t *testing.T) {
+		if _, err := saveToBucket(ctx, bucket, dir, newArtifact("detected", ""), nil, chunkSize, nil, nil); assert.NoError(t, err) {
+			assert.Equal(t, "application/json", uploads.contentTypes["detected/data.json"])
+			assert.Equal(t, "image/png", uploads.contentTypes["detected/image"])
+			assert.Equal(t, "text/plain; charset=utf-8", uploads.contentTypes["detected/log"])
+			assert.Equal(t, "application/json", uploads.contentTypes["detected/large.json"])
+		}
+	})
+	t.Run("Override", func(t *testing.T) {
+		if _, err := saveToBucket(ctx, bucket, dir, newArtifact("override", "application/octet-stream"), nil, chunkSize, nil, nil); assert.NoError(t, err) {
+			for _, name := range []string{"data.json", "image", "log", "large.json"} {
+				assert.Equal(t, "application/octet-stream", uploads.contentTypes["override/"+name], name)
+			}
+		}
+	})
+}
+
+func TestSaveToBucket_Overwrite(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "gcs")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+	const chunkSize = 256 * 1024
+	path := filepath.Join(tmp, "my-file")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("new"), 0600))
+	largePath := filepath.Join(tmp, "my-large-file")
+	assert.NoError(t, ioutil.WriteFile(largePath, bytes.Repeat([]byte("0123456789"), 60*1024), 0600))
+	dir := filepath.Join(tmp, "my-dir")
+	assert.NoError(t, os.MkdirAll(dir, 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("new"), 0600))
+	newArtifact := func(key string, overwrite wfv1.ArtifactOverwrite) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{GCS: &wfv1.GCSArtifact{GCSBucket: wfv1.GCSBucket{Bucket: "my-bucket"}, Key: key}}, Overwrite: overwrite}
+	}
+	ctx := context.Background()
+	for _, overwrite := range []wfv1.ArtifactOverwrite{wfv1.ArtifactOverwriteAllow, wfv1.ArtifactOverwriteFailIfExists, wfv1.ArtifactOverwriteSkipIfExists} {
+		t.Run(string(overwrite), func(t *testing.T) {
+			uploads := &fakeUploads{objects: map[string][]byte{"my-file": []byte("old"), "my-large-file": []byte("old"), "my-dir/b": []byte("old")}}
+			server := httptest.NewServer(uploads)
+			defer server.Close()
+			client, err := storage.NewClient(ctx, option.WithEndpoint(server.URL+"/storage/v1/"), option.WithoutAuthentication())
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer func() { _ = client.Close() }()
+			bucket := client.Bucket("my-bucket")
+			// a destination which does not exist is saved whatever the policy
+			_, err = saveToBucket(ctx, bucket, path, newArtifact("my-new-file", overwrite), nil, chunkSize, nil, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, "new", string(uploads.objects["my-new-file"]))
+			_, err = saveToBucket(ctx, bucket, dir, newArtifact("my-new-dir", overwrite), nil, chunkSize, nil, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, "new", string(uploads.objects["my-new-dir/a"]))
+
+			_, err = saveToBucket(ctx, bucket, path, newArtifact("my-file", overwrite), nil, chunkSize, nil, nil)
+			_, largeErr := saveToBucket(ctx, bucket, largePath, newArtifact("my-large-file", overwrite), nil, chunkSize, nil, nil)
+			_, dirErr := saveToBucket(ctx, bucket, dir, newArtifact("my-dir", overwrite), nil, chunkSize, nil, nil)
+			if overwrite == wfv1.ArtifactOverwriteAllow {
+				assert.NoError(t, err)
+				assert.Equal(t, "new", string(uploads.objects["my-file"]))
+				assert.NoError(t, largeErr)
+				assert.NoError(t, dirErr)
+				assert.Equal(t, "new", string(uploads.objects["my-dir/a"]))
+				return
+			}
+			// the driver fails whether the policy is fail-if-exists or skip-if-exists, which the caller skips
+			assert.True(t, errors.Is(err, common.ErrArtifactExists))
+			assert.EqualError(t, err, "gs://my-bucket/my-file already exists, and the overwrite policy of the artifact does not allow replacing it")
+			assert.Equal(t, "old", string(uploads.objects["my-file"]))
+			// the precondition of a resumable upload fails when it is started
+			assert.True(t, errors.Is(largeErr, common.ErrArtifactExists))
+			assert.Equal(t, "old", string(uploads.objects["my-large-file"]))
+			// a directory exists if any object does under it
+			assert.True(t, errors.Is(dirErr, common.ErrArtifactExists))
+			assert.NotContains(t, uploads.objects, "my-dir/a")
+		})
+	}
+}
+
+func TestSaveToBucket_AtomicSave(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "gcs")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	dir := filepath.Join(tmp, "my-dir")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "b"), []byte("bb"), 0600))
+	art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{GCS: &wfv1.GCSArtifact{GCSBucket: wfv1.GCSBucket{Bucket: "my-bucket"}, Key: "my-prefix/my-dir"}}, AtomicSave: true}
+	ctx := context.Background()
+	newBucket := func(uploads *fakeUploads) (*storage.BucketHandle, func()) {
+		server := httptest.NewServer(uploads)
+		client, err := storage.NewClient(ctx, option.WithEndpoint(server.URL+"/storage/v1/"), option.WithoutAuthentication())
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return client.Bucket("my-bucket"), func() {
+			_ = client.Close()
+			server.Close()
+		}
+	}
+	names := func(objects map[string][]byte) []string {
+		var names []string
+		for name := range objects {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	t.Run("Saved", func(t *testing.T) {
+		uploads := &fakeUploads{objects: map[string][]byte{}}
+		bucket, closer := newBucket(uploads)
+		defer closer()
+		result, err := saveToBucket(ctx, bucket, dir, art, nil, defaultUploadChunkSize, nil, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		// the objects were copied to the key once both were uploaded, and the staging objects deleted
+		assert.ElementsMatch(t, []string{"my-prefix/my-dir/a", "my-prefix/my-dir/sub/b"}, uploads.copies)
+		assert.Equal(t, []string{"my-prefix/my-dir/a", "my-prefix/my-dir/sub/b"}, names(uploads.objects))
+		assert.Equal(t, "bb", string(uploads.objects["my-prefix/my-dir/sub/b"]))
+		if assert.Len(t, result.Objects, 2) {
+			sort.Slice(result.Objects, func(i, j int) bool { return result.Objects[i].Key < result.Objects[j].Key })
+			assert.Equal(t, "my-prefix/my-dir/sub/b", result.Objects[1].Key)
+			assert.Equal(t, filepath.Join(dir, "sub", "b"), result.Objects[1].Path)
+			assert.Equal(t, int64(2), result.Objects[1].Size)
+		}
+	})
+	t.Run("Failed", func(t *testing.T) {
+		uploads := &fakeUploads{objects: map[string][]byte{}, failName: "/sub/b"}
+		bucket, closer := newBucket(uploads)
+		defer closer()
+		_, err := saveToBucket(ctx, bucket, dir, art, nil, defaultUploadChunkSize, nil, nil)
+		assert.Error(t, err)
+		// nothing was copied to the key, and what was uploaded under the staging key was deleted
+		assert.Empty(t, uploads.copies)
+		assert.Empty(t, uploads.objects)
+	})
+}
+
+func TestSaveToBucket_Result(t *testing.T) {
+	uploads := &fakeUploads{objects: map[string][]byte{}}
+	server := httptest.NewServer(uploads)
+	defer server.Close()
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx, option.WithEndpoint(server.URL+"/storage/v1/"), option.WithoutAuthentication())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = client.Close() }()
+	tmp, err := ioutil.TempDir("", "gcs")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+	const chunkSize = 256 * 1024
+	bucket := client.Bucket("my-bucket")
+	newArtifact := func(key string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{GCS: &wfv1.GCSArtifact{GCSBucket: wfv1.GCSBucket{Bucket: "my-bucket"}, Key: key}}}
+	}
+
+	t.Run("File", func(t *testing.T) {
+		path := filepath.Join(tmp, "my-file")
+		assert.NoError(t, ioutil.WriteFile(path, []byte("my-data"), 0600))
+		uploads.generation = 0
+		result, err := saveToBucket(ctx, bucket, path, newArtifact("my-file"), nil, chunkSize, nil, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, &common.SaveResult{
+				Location: "gs://my-bucket/my-file",
+				Objects:  []common.SavedObject{{Key: "my-file", Path: path, Size: 7, ETag: "etag-1", VersionID: "1"}},
+				Size:     7,
+			}, result)
+		}
+	})
+	t.Run("Resumable", func(t *testing.T) {
+		path := filepath.Join(tmp, "my-large-file")
+		assert.NoError(t, ioutil.WriteFile(path, bytes.Repeat([]byte("0123456789"), 60*1024), 0600))
+		uploads.generation = 0
+		result, err := saveToBucket(ctx, bucket, path, newArtifact("my-large-file"), nil, chunkSize, nil, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []common.SavedObject{{Key: "my-large-file", Path: path, Size: 600 * 1024, ETag: "etag-1", VersionID: "1"}}, result.Objects)
+		}
+	})
+	t.Run("Directory", func(t *testing.T) {
+		dir := filepath.Join(tmp, "my-dir")
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "b"), []byte("b"), 0600))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "a"), []byte("sub/a"), 0600))
+		uploads.generation = 0
+		result, err := saveToBucket(ctx, bucket, dir, newArtifact("my-dir"), nil, chunkSize, nil, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		// each file of the directory is in the manifest, as GCS reported it was uploaded
+		assert.Equal(t, &common.SaveResult{
+			Location: "gs://my-bucket/my-dir",
+			Objects: []common.SavedObject{
+				{Key: "my-dir/b", Path: filepath.Join(dir, "b"), Size: 1, ETag: "etag-1", VersionID: "1"},
+				{Key: "my-dir/sub/a", Path: filepath.Join(dir, "sub", "a"), Size: 5, ETag: "etag-2", VersionID: "2"},
+			},
+			Size: 6,
+		}, result)
+	})
+}
+
+func TestParseUploadChunkSize(t *testing.T) {
+	size, err := ParseUploadChunkSize("")
+	assert.NoError(t, err)
+	assert.Zero(t, size)
+	size, err = ParseUploadChunkSize("32Mi")
+	assert.NoError(t, err)
+	assert.Equal(t, 32*1024*1024, size)
+	for _, invalid := range []string{"big", "0", "4Gi"} {
+		_, err = ParseUploadChunkSize(invalid)
+		assert.Error(t, err, invalid)
+	}
+	err = ValidateArtifact("inputs.artifacts.my-art.gcs", &wfv1.GCSArtifact{GCSBucket: wfv1.GCSBucket{UploadChunkSize: "big"}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "inputs.artifacts.my-art.gcs.uploadChunkSize must be a quantity of bytes")
+	}
+}
+
+func TestDryRunSave(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/storage/v1/b/my-bucket", "/storage/v1/b/read-only":
+			_, _ = w.Write([]byte(`{"kind":"storage#bucket"}`))
+		case "/storage/v1/b/my-bucket/iam/testPermissions":
+			_, _ = w.Write([]byte(`{"kind":"storage#testIamPermissionsResponse","permissions":["storage.objects.create"]}`))
+		case "/storage/v1/b/read-only/iam/testPermissions":
+			_, _ = w.Write([]byte(`{"kind":"storage#testIamPermissionsResponse"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"code":404,"message":"Not Found"}}`))
+		}
+	}))
+	defer server.Close()
+	client, err := storage.NewClient(context.Background(), option.WithEndpoint(server.URL+"/storage/v1/"), option.WithoutAuthentication())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer client.Close()
+	tmp, err := ioutil.TempDir("", "gcs")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+	for _, rel := range []string{"app.jar", "lib/dep.jar"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(tmp, filepath.Dir(rel)), 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(tmp, rel), []byte(rel), 0600))
+	}
+	newArtifact := func(bucket string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{GCS: &wfv1.GCSArtifact{GCSBucket: wfv1.GCSBucket{Bucket: bucket}, Key: "my-dir"}}}
+	}
+	ctx := context.Background()
+	t.Run("Permitted", func(t *testing.T) {
+		result, err := dryRunSave(ctx, client.Bucket("my-bucket"), tmp, newArtifact("my-bucket"))
+		if assert.NoError(t, err) {
+			assert.Equal(t, "gs://my-bucket/my-dir", result.Location)
+			assert.Equal(t, []common.DryRunObject{
+				{Key: "my-dir/app.jar", Path: filepath.Join(tmp, "app.jar"), Size: 7},
+				{Key: "my-dir/lib/dep.jar", Path: filepath.Join(tmp, "lib", "dep.jar"), Size: 11},
+			}, result.Objects)
+			assert.True(t, result.WritePermissionChecked)
+		}
+	})
+	t.Run("Forbidden", func(t *testing.T) {
+		_, err := dryRunSave(ctx, client.Bucket("read-only"), tmp, newArtifact("read-only"))
+		assert.EqualError(t, err, "permission storage.objects.create is required to save to bucket read-only")
+	})
+	t.Run("NoSuchBucket", func(t *testing.T) {
+		_, err := dryRunSave(ctx, client.Bucket("not-found"), tmp, newArtifact("not-found"))
+		assert.EqualError(t, err, "bucket not-found does not exist")
+	})
+	// nothing is uploaded
+	for _, method := range methods {
+		assert.Equal(t, http.MethodGet, method)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/storage/v1/b/my-bucket":
+			_, _ = w.Write([]byte(`{"kind":"storage#bucket","name":"my-bucket"}`))
+		case "/storage/v1/b/forbidden":
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":{"code":401,"message":"Unauthorized"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"code":404,"message":"Not Found"}}`))
+		}
+	}))
+	defer server.Close()
+	client, err := storage.NewClient(context.Background(), option.WithEndpoint(server.URL+"/storage/v1/"), option.WithoutAuthentication())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer client.Close()
+	ctx := context.Background()
+	t.Run("Healthy", func(t *testing.T) {
+		assert.NoError(t, healthCheck(ctx, client.Bucket("my-bucket"), "my-bucket"))
+	})
+	t.Run("NoSuchBucket", func(t *testing.T) {
+		assert.EqualError(t, healthCheck(ctx, client.Bucket("not-found"), "not-found"), "bucket not-found does not exist")
+	})
+	t.Run("Unauthorized", func(t *testing.T) {
+		err := healthCheck(ctx, client.Bucket("forbidden"), "forbidden")
+		var apiErr *googleapi.Error
+		if assert.True(t, errors.As(err, &apiErr)) {
+			assert.Equal(t, http.StatusUnauthorized, apiErr.Code)
+		}
+	})
+}
+
+func TestManifest(t *testing.T) {
+	objects := &fakeEncryptedObjects{objects: map[string][]byte{}, keys: map[string]string{}}
+	server := httptest.NewServer(objects)
+	defer server.Close()
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx, option.WithHTTPClient(&http.Client{Transport: redirectTransport{host: strings.TrimPrefix(server.URL, "http://")}}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = client.Close() }()
+	tmp, err := ioutil.TempDir("", "gcs")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	bucket := client.Bucket("my-bucket")
+	dir := filepath.Join(tmp, "my-dir")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "b"), []byte("bb"), 0600))
+	art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{GCS: &wfv1.GCSArtifact{GCSBucket: wfv1.GCSBucket{Bucket: "my-bucket"}, Key: "my-dir"}}, Manifest: wfv1.ArtifactManifestFormatJSON}
+	if _, err := saveToBucket(ctx, bucket, dir, art, nil, defaultUploadChunkSize, nil, nil); !assert.NoError(t, err) {
+		return
+	}
+	manifest, err := common.ParseManifest(objects.objects["my-dir.manifest"])
+	if assert.NoError(t, err) {
+		assert.Equal(t, []common.ManifestFile{
+			{Path: "a", Size: 1, SHA256: "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb"},
+			{Path: "sub/b", Size: 2, SHA256: "3b64db95cb55c763391c707108489ae18b4112d783300de38e033b4c98c3deaf"},
+		}, manifest.Files)
+	}
+
+	t.Run("Verified", func(t *testing.T) {
+		path := filepath.Join(tmp, "verified")
+		if assert.NoError(t, downloadObjects(ctx, bucket, "my-dir", path, nil, 0, nil)) {
+			data, err := ioutil.ReadFile(filepath.Join(path, "sub", "b"))
+			assert.NoError(t, err)
+			assert.Equal(t, "bb", string(data))
+			// the manifest is listed with the objects of the directory, but not downloaded with them
+			assert.NoFileExists(t, filepath.Join(path, ".manifest"))
+		}
+	})
+	t.Run("TooLarge", func(t *testing.T) {
+		// the objects are 3 bytes in total, which is checked before any is downloaded
+		path := filepath.Join(tmp, "too-large")
+		err := downloadObjects(ctx, bucket, "my-dir", path, nil, 2, nil)
+		assert.True(t, errors.Is(err, common.ErrArtifactTooLarge))
+		assert.NoDirExists(t, path)
+		assert.NoError(t, downloadObjects(ctx, bucket, "my-dir", filepath.Join(tmp, "at-limit"), nil, 3, nil))
+	})
+	t.Run("Corrupt", func(t *testing.T) {
+		objects.objects["my-dir/sub/b"] = []byte("cc")
+		defer func() { objects.objects["my-dir/sub/b"] = []byte("bb") }()
+		assert.Error(t, downloadObjects(ctx, bucket, "my-dir", filepath.Join(tmp, "corrupt"), nil, 0, nil))
+	})
+	t.Run("Missing", func(t *testing.T) {
+		delete(objects.objects, "my-dir/a")
+		assert.Error(t, downloadObjects(ctx, bucket, "my-dir", filepath.Join(tmp, "missing"), nil, 0, nil))
+	})
+}
+
+func TestSaveToBucket_SkipIfUnchanged(t *testing.T) {
+	objects := &fakeEncryptedObjects{objects: map[string][]byte{"my-md5-key": []byte("hello")}, keys: map[string]string{}}
+	server := httptest.NewServer(objects)
+	defer server.Close()
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx, option.WithHTTPClient(&http.Client{Transport: redirectTransport{host: strings.TrimPrefix(server.URL, "http://")}}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = client.Close() }()
+	tmp, err := ioutil.TempDir("", "gcs")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	bucket := client.Bucket("my-bucket")
+	path := filepath.Join(tmp, "my-file")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("hello"), 0600))
+	dir := filepath.Join(tmp, "my-dir")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "b"), []byte("bb"), 0600))
+	newArtifact := func(key string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{GCS: &wfv1.GCSArtifact{GCSBucket: wfv1.GCSBucket{Bucket: "my-bucket"}, Key: key}}, Overwrite: wfv1.ArtifactOverwriteSkipIfUnchanged}
+	}
+	save := func(path string, art *wfv1.Artifact) (*common.SaveResult, []string) {
+		objects.uploads = nil
+		result, err := saveToBucket(ctx, bucket, path, art, nil, defaultUploadChunkSize, nil, nil)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		sort.Strings(objects.uploads)
+		return result, objects.uploads
+	}
+
+	t.Run("File", func(t *testing.T) {
+		result, uploads := save(path, newArtifact("my-key"))
+		assert.False(t, result.Unchanged)
+		assert.Equal(t, []string{"my-key"}, uploads)
+		assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", objects.metadata["my-key"][common.ContentSHA256MetadataKey])
+		// an identical re-save uploads nothing
+		result, uploads = save(path, newArtifact("my-key"))
+		assert.True(t, result.Unchanged)
+		assert.Empty(t, uploads)
+		// a changed file is uploaded
+		changed := filepath.Join(tmp, "my-changed-file")
+		assert.NoError(t, ioutil.WriteFile(changed, []byte("world"), 0600))
+		result, uploads = save(changed, newArtifact("my-key"))
+		assert.False(t, result.Unchanged)
+		assert.Equal(t, []string{"my-key"}, uploads)
+		assert.Equal(t, "world", string(objects.objects["my-key"]))
+	})
+	t.Run("MD5", func(t *testing.T) {
+		// an object without a recorded sha256 is compared by its md5
+		result, uploads := save(path, newArtifact("my-md5-key"))
+		assert.True(t, result.Unchanged)
+		assert.Empty(t, uploads)
+	})
+	t.Run("Directory", func(t *testing.T) {
+		result, uploads := save(dir, newArtifact("my-dir"))
+		assert.False(t, result.Unchanged)
+		assert.Equal(t, []string{"my-dir.manifest", "my-dir/a", "my-dir/sub/b"}, uploads)
+		result, uploads = save(dir, newArtifact("my-dir"))
+		assert.True(t, result.Unchanged)
+		assert.Empty(t, uploads)
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "b"), []byte("cc"), 0600))
+		result, uploads = save(dir, newArtifact("my-dir"))
+		assert.False(t, result.Unchanged)
+		assert.Equal(t, []string{"my-dir.manifest", "my-dir/a", "my-dir/sub/b"}, uploads)
+		assert.Equal(t, "cc", string(objects.objects["my-dir/sub/b"]))
+	})
+}
+
+func TestSaveToBucket_Idempotency(t *testing.T) {
+	objects := &fakeEncryptedObjects{objects: map[string][]byte{}, keys: map[string]string{}}
+	server := httptest.NewServer(objects)
+	defer server.Close()
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx, option.WithHTTPClient(&http.Client{Transport: redirectTransport{host: strings.TrimPrefix(server.URL, "http://")}}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = client.Close() }()
+	tmp, err := ioutil.TempDir("", "gcs")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	bucket := client.Bucket("my-bucket")
+	path := filepath.Join(tmp, "my-file")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("hello"), 0600))
+	dir := filepath.Join(tmp, "my-dir")
+	assert.NoError(t, os.MkdirAll(dir, 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0600))
+	newArtifact := func(key, idempotencyKey string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{GCS: &wfv1.GCSArtifact{GCSBucket: wfv1.GCSBucket{Bucket: "my-bucket"}, Key: key}}, Idempotency: &wfv1.ArtifactIdempotency{Key: idempotencyKey}}
+	}
+	save := func(path string, art *wfv1.Artifact) (*common.SaveResult, []string) {
+		objects.uploads = nil
+		result, err := saveToBucket(ctx, bucket, path, art, nil, defaultUploadChunkSize, nil, nil)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		sort.Strings(objects.uploads)
+		return result, objects.uploads
+	}
+
+	t.Run("File", func(t *testing.T) {
+		result, uploads := save(path, newArtifact("my-key", "my-idempotency-key"))
+		assert.False(t, result.Unchanged)
+		assert.Equal(t, []string{"my-key"}, uploads)
+		assert.Equal(t, common.IdempotencyKey(newArtifact("my-key", "my-idempotency-key")), objects.metadata["my-key"][common.IdempotencyKeyMetadataKey])
+		// a retried save uploads nothing
+		result, uploads = save(path, newArtifact("my-key", "my-idempotency-key"))
+		assert.True(t, result.Unchanged)
+		assert.Empty(t, uploads)
+		// nor is a save with another key skipped, even though the object is unchanged
+		result, uploads = save(path, newArtifact("my-key", "my-other-idempotency-key"))
+		assert.False(t, result.Unchanged)
+		assert.Equal(t, []string{"my-key"}, uploads)
+		// nor is a changed file
+		changed := filepath.Join(tmp, "my-changed-file")
+		assert.NoError(t, ioutil.WriteFile(changed, []byte("world"), 0600))
+		result, uploads = save(changed, newArtifact("my-key", "my-other-idempotency-key"))
+		assert.False(t, result.Unchanged)
+		assert.Equal(t, []string{"my-key"}, uploads)
+		assert.Equal(t, "world", string(objects.objects["my-key"]))
+	})
+	t.Run("Directory", func(t *testing.T) {
+		result, uploads := save(dir, newArtifact("my-dir", "my-idempotency-key"))
+		assert.False(t, result.Unchanged)
+		assert.Equal(t, []string{"my-dir.manifest", "my-dir/a"}, uploads)
+		result, uploads = save(dir, newArtifact("my-dir", "my-idempotency-key"))
+		assert.True(t, result.Unchanged)
+		assert.Empty(t, uploads)
+		// a save which failed before its manifest was uploaded did not complete, so is retried
+		delete(objects.objects, "my-dir.manifest")
+		result, uploads = save(dir, newArtifact("my-dir", "my-idempotency-key"))
+		assert.False(t, result.Unchanged)
+		assert.Equal(t, []string{"my-dir.manifest", "my-dir/a"}, uploads)
+	})
+}
+
+func TestDownloadLatest(t *testing.T) {
+	// the object with the greatest name is not that updated last
+	objects := &fakeEncryptedObjects{
+		objects: map[string][]byte{"data/data-20240101.csv": []byte("1"), "data/data-20240102.csv": []byte("2"), "data/data-20240103.csv": []byte("3"), "other/data-20240104.csv": []byte("4")},
+		keys:    map[string]string{},
+		updated: map[string]string{"data/data-20240101.csv": "2024-01-01T00:00:00Z", "data/data-20240102.csv": "2024-01-05T00:00:00Z", "data/data-20240103.csv": "2024-01-03T00:00:00Z", "other/data-20240104.csv": "2024-01-06T00:00:00Z"},
+	}
+	server := httptest.NewServer(objects)
+	defer server.Close()
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx, option.WithHTTPClient(&http.Client{Transport: redirectTransport{host: strings.TrimPrefix(server.URL, "http://")}}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = client.Close() }()
+	tmp, err := ioutil.TempDir("", "gcs")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	bucket := client.Bucket("my-bucket")
+	newArtifact := func(key string, policy wfv1.ArtifactSelectLatest) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{GCS: &wfv1.GCSArtifact{GCSBucket: wfv1.GCSBucket{Bucket: "my-bucket"}, Key: key}}, SelectLatest: policy}
+	}
+
+	for policy, expected := range map[wfv1.ArtifactSelectLatest]string{wfv1.ArtifactSelectLatestLastModified: "2", wfv1.ArtifactSelectLatestLexical: "3"} {
+		t.Run(string(policy), func(t *testing.T) {
+			path := filepath.Join(tmp, string(policy))
+			if assert.NoError(t, downloadLatest(ctx, bucket, newArtifact("data/data-", policy), path, nil, 0, nil)) {
+				data, err := ioutil.ReadFile(path)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, string(data))
+			}
+		})
+	}
+	t.Run("None", func(t *testing.T) {
+		err := downloadLatest(ctx, bucket, newArtifact("data/report-", wfv1.ArtifactSelectLatestLexical), filepath.Join(tmp, "none"), nil, 0, nil)
+		assert.EqualError(t, err, "no object has the key prefix data/report- to select the latest of")
+		assert.True(t, errors.Is(err, common.ErrArtifactNotFound))
+	})
+}
+
+func TestSaveToBucket_Metadata(t *testing.T) {
+	objects := &fakeEncryptedObjects{objects: map[string][]byte{}, keys: map[string]string{}}
+	server := httptest.NewServer(objects)
+	defer server.Close()
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx, option.WithHTTPClient(&http.Client{Transport: redirectTransport{host: strings.TrimPrefix(server.URL, "http://")}}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = client.Close() }()
+	tmp, err := ioutil.TempDir("", "gcs")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	bucket := client.Bucket("my-bucket")
+	path := filepath.Join(tmp, "my-file")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("hello"), 0600))
+	dir := filepath.Join(tmp, "my-dir")
+	assert.NoError(t, os.MkdirAll(dir, 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0600))
+	metadata := map[string]string{"git-commit": "abc123", "build-id": "42"}
+	newArtifact := func(key string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{GCS: &wfv1.GCSArtifact{GCSBucket: wfv1.GCSBucket{Bucket: "my-bucket"}, Key: key}}, Metadata: metadata, Overwrite: wfv1.ArtifactOverwriteSkipIfUnchanged}
+	}
+
+	t.Run("File", func(t *testing.T) {
+		_, err := saveToBucket(ctx, bucket, path, newArtifact("my-key"), map[string]string{common.ContentEncodingMetadataKey: "gzip"}, defaultUploadChunkSize, nil, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		// the metadata of the artifact is sent with that the driver records
+		assert.Equal(t, "abc123", objects.metadata["my-key"]["git-commit"])
+		assert.Equal(t, "gzip", objects.metadata["my-key"][common.ContentEncodingMetadataKey])
+		assert.NotEmpty(t, objects.metadata["my-key"][common.ContentSHA256MetadataKey])
+		got, err := objectMetadata(ctx, bucket, "my-key")
+		if assert.NoError(t, err) {
+			assert.Equal(t, metadata, got)
+		}
+	})
+	t.Run("Directory", func(t *testing.T) {
+		_, err := saveToBucket(ctx, bucket, dir, newArtifact("my-dir"), nil, defaultUploadChunkSize, nil, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		// every object of a directory has the metadata
+		got, err := objectMetadata(ctx, bucket, "my-dir/a")
+		if assert.NoError(t, err) {
+			assert.Equal(t, metadata, got)
+		}
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := objectMetadata(ctx, bucket, "my-missing")
+		assert.True(t, errors.Is(err, common.ErrArtifactNotFound))
+	})
+}
+
+func TestArtifactDriver_Anonymous(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		// the requests of public objects are not authenticated
+		assert.Empty(t, r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/storage/v1/b/my-bucket/o":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"kind":"storage#objects","items":[{"kind":"storage#object","bucket":"my-bucket","name":"my-file","size":"5"}]}`))
+		case "/storage/v1/b/my-bucket/o/my-file":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"kind":"storage#object","bucket":"my-bucket","name":"my-file","size":"5"}`))
+		case "/my-bucket/my-file":
+			_, _ = w.Write([]byte("hello"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	endpoint, err := ParseEndpoint(server.URL)
+	if !assert.NoError(t, err) {
+		return
+	}
+	art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{GCS: &wfv1.GCSArtifact{GCSBucket: wfv1.GCSBucket{Bucket: "my-bucket"}, Key: "my-file"}}}
+	tmp, err := ioutil.TempDir("", "gcs")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	t.Run("Load", func(t *testing.T) {
+		requests = nil
+		g := &ArtifactDriver{Anonymous: true, Endpoint: endpoint}
+		path := filepath.Join(tmp, "my-file")
+		if assert.NoError(t, g.Load(context.Background(), art, path)) {
+			data, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, "hello", string(data))
+		}
+		assert.Equal(t, []string{"GET /storage/v1/b/my-bucket/o", "GET /my-bucket/my-file"}, requests)
+	})
+	t.Run("NoCredentials", func(t *testing.T) {
+		requests = nil
+		defer func(f func(context.Context, ...string) (*google.Credentials, error)) { findDefaultCredentials = f }(findDefaultCredentials)
+		findDefaultCredentials = func(context.Context, ...string) (*google.Credentials, error) {
+			return nil, fmt.Errorf("could not find default credentials")
+		}
+		g := &ArtifactDriver{Endpoint: endpoint}
+		ok, err := g.Exists(art)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []string{"GET /storage/v1/b/my-bucket/o/my-file"}, requests)
+	})
+	t.Run("Save", func(t *testing.T) {
+		requests = nil
+		g := &ArtifactDriver{Anonymous: true, Endpoint: endpoint}
+		path := filepath.Join(tmp, "my-file")
+		assert.EqualError(t, g.Save(context.Background(), path, art), "GCS Save cannot be anonymous, only loads of public objects can be")
+		assert.EqualError(t, g.Delete(art), "GCS Delete cannot be anonymous, only loads of public objects can be")
+		assert.Empty(t, requests)
+	})
+}
+
+func TestValidateArtifact_Anonymous(t *testing.T) {
+	validate := func(bucket wfv1.GCSBucket) error {
+		return ValidateArtifact("inputs.artifacts.my-art.gcs", &wfv1.GCSArtifact{GCSBucket: bucket, Key: "my-file"})
+	}
+	assert.NoError(t, validate(wfv1.GCSBucket{Bucket: "my-bucket", Anonymous: true}))
+	assert.EqualError(t, validate(wfv1.GCSBucket{Bucket: "my-bucket", Anonymous: true, ServiceAccountKeySecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "serviceAccountKey"}}),
+		"inputs.artifacts.my-art.gcs.anonymous cannot be used with inputs.artifacts.my-art.gcs.serviceAccountKeySecret")
+	assert.EqualError(t, validate(wfv1.GCSBucket{Bucket: "my-bucket", Anonymous: true, RequesterPays: true}),
+		"inputs.artifacts.my-art.gcs.anonymous cannot be used with inputs.artifacts.my-art.gcs.requesterPays, as requester pays buckets bill the requester")
+}
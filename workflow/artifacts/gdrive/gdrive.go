@@ -0,0 +1,232 @@
+package gdrive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const (
+	folderMimeType = "application/vnd.google-apps.folder"
+	// the MIME types of Google Docs, Sheets, Slides, Drawings etc. share this prefix, and they must be exported
+	googleAppsMimeTypePrefix = "application/vnd.google-apps."
+	// the ID of the root folder of the drive of the authenticated user
+	rootFolderID = "root"
+)
+
+// defaultExportMimeTypes are the formats Google Apps files are exported as, unless ExportMimeType is set
+var defaultExportMimeTypes = map[string]string{
+	"application/vnd.google-apps.document":     "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"application/vnd.google-apps.spreadsheet":  "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"application/vnd.google-apps.presentation": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	"application/vnd.google-apps.drawing":      "image/png",
+}
+
+// ArtifactDriver is a driver for Google Drive
+type ArtifactDriver struct {
+	ServiceAccountKey string
+}
+
+// driveClient is the set of Drive API operations the driver performs
+type driveClient interface {
+	// get returns the metadata of a file
+	get(ctx context.Context, fileID string) (*drive.File, error)
+	// list returns the files in a folder, optionally only those with the given name
+	list(ctx context.Context, folderID, name string) ([]*drive.File, error)
+	// download returns the content of a file
+	download(ctx context.Context, fileID string) (io.ReadCloser, error)
+	// export returns the content of a Google Apps file converted to mimeType
+	export(ctx context.Context, fileID, mimeType string) (io.ReadCloser, error)
+}
+
+func (d *ArtifactDriver) newDriveClient(ctx context.Context) (driveClient, error) {
+	opts := []option.ClientOption{option.WithScopes(drive.DriveReadonlyScope)}
+	if d.ServiceAccountKey != "" {
+		opts = append(opts, option.WithCredentialsJSON([]byte(d.ServiceAccountKey)))
+	}
+	// otherwise assume it uses Workload Identity
+	service, err := drive.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("Drive drive.NewService: %v", err)
+	}
+	return &serviceClient{service}, nil
+}
+
+// serviceClient calls the Drive API. Shared drives are supported.
+type serviceClient struct {
+	service *drive.Service
+}
+
+const fileFields = "id, name, mimeType"
+
+func (c *serviceClient) get(ctx context.Context, fileID string) (*drive.File, error) {
+	return c.service.Files.Get(fileID).SupportsAllDrives(true).Fields(fileFields).Context(ctx).Do()
+}
+
+func (c *serviceClient) list(ctx context.Context, folderID, name string) ([]*drive.File, error) {
+	q := fmt.Sprintf("'%s' in parents and trashed = false", escapeQuery(folderID))
+	if name != "" {
+		q += fmt.Sprintf(" and name = '%s'", escapeQuery(name))
+	}
+	var files []*drive.File
+	err := c.service.Files.List().
+		Q(q).
+		SupportsAllDrives(true).
+		IncludeItemsFromAllDrives(true).
+		Fields("nextPageToken, files("+fileFields+")").
+		Pages(ctx, func(list *drive.FileList) error {
+			files = append(files, list.Files...)
+			return nil
+		})
+	return files, err
+}
+
+func (c *serviceClient) download(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	res, err := c.service.Files.Get(fileID).SupportsAllDrives(true).Context(ctx).Download()
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func (c *serviceClient) export(ctx context.Context, fileID, mimeType string) (io.ReadCloser, error) {
+	res, err := c.service.Files.Export(fileID, mimeType).Context(ctx).Download()
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// escapeQuery escapes a value for a single quoted string of a Drive query
+func escapeQuery(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+}
+
+func isNotFoundErr(err error) bool {
+	apiErr, ok := err.(*googleapi.Error)
+	return ok && apiErr.Code == http.StatusNotFound
+}
+
+// Load downloads a file from Google Drive. Folders are downloaded as directories.
+func (d *ArtifactDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, path string) error {
+	log.Infof("Google Drive Load path: %s, file ID: %s, drive path: %s", path, inputArtifact.GDrive.FileID, inputArtifact.GDrive.Path)
+	client, err := d.newDriveClient(ctx)
+	if err != nil {
+		return err
+	}
+	return load(ctx, client, inputArtifact.GDrive, path)
+}
+
+func load(ctx context.Context, client driveClient, art *wfv1.GDriveArtifact, path string) error {
+	file, err := resolve(ctx, client, art.FileID, art.Path)
+	if err != nil {
+		if isNotFoundErr(err) {
+			return common.NewNotFoundError(err)
+		}
+		return err
+	}
+	return loadFile(ctx, client, file, path, art.ExportMimeType)
+}
+
+// resolve returns the file at the path relative to the folder fileID, or to the root folder
+func resolve(ctx context.Context, client driveClient, fileID, path string) (*drive.File, error) {
+	if fileID == "" {
+		fileID = rootFolderID
+	}
+	file, err := client.get(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range strings.Split(path, "/") {
+		if name == "" {
+			continue
+		}
+		if file.MimeType != folderMimeType {
+			return nil, errors.Errorf(errors.CodeBadRequest, "cannot resolve %s in %s, which is not a folder", name, file.Name)
+		}
+		files, err := client.list(ctx, file.Id, name)
+		if err != nil {
+			return nil, err
+		}
+		switch len(files) {
+		case 0:
+			return nil, common.NewNotFoundError(fmt.Errorf("%s not found in Google Drive folder %s", name, file.Name))
+		case 1:
+			file = files[0]
+		default:
+			return nil, errors.Errorf(errors.CodeBadRequest, "%s is ambiguous: Google Drive folder %s has %d files of that name", name, file.Name, len(files))
+		}
+	}
+	return file, nil
+}
+
+func loadFile(ctx context.Context, client driveClient, file *drive.File, path, exportMimeType string) error {
+	if file.MimeType == folderMimeType {
+		if err := os.MkdirAll(path, 0700); err != nil {
+			return err
+		}
+		files, err := client.list(ctx, file.Id, "")
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			if err := loadFile(ctx, client, f, filepath.Join(path, f.Name), exportMimeType); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var body io.ReadCloser
+	var err error
+	if strings.HasPrefix(file.MimeType, googleAppsMimeTypePrefix) {
+		mimeType := exportMimeType
+		if mimeType == "" {
+			mimeType = defaultExportMimeTypes[file.MimeType]
+		}
+		if mimeType == "" {
+			return errors.Errorf(errors.CodeBadRequest, "Google Drive file %s of type %s cannot be exported without an exportMimeType", file.Name, file.MimeType)
+		}
+		body, err = client.export(ctx, file.Id, mimeType)
+	} else {
+		body, err = client.download(ctx, file.Id)
+	}
+	if err != nil {
+		return fmt.Errorf("download %s: %v", file.Name, err)
+	}
+	defer func() { _ = body.Close() }()
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("os create %s: %v", path, err)
+	}
+	defer func() { _ = out.Close() }()
+	_, err = io.Copy(out, body)
+	return err
+}
+
+// Save is unsupported for Google Drive output artifacts
+func (d *ArtifactDriver) Save(context.Context, string, *wfv1.Artifact) error {
+	return errors.Errorf(errors.CodeBadRequest, "Google Drive output artifacts unsupported")
+}
+
+// Delete is unsupported for Google Drive artifacts
+func (d *ArtifactDriver) Delete(*wfv1.Artifact) error {
+	return common.ErrDeletionNotSupported
+}
+
+// Capabilities returns the optional operations on Google Drive artifacts the driver supports
+func (d *ArtifactDriver) Capabilities() common.DriverCapabilities {
+	return common.DriverCapabilities{}
+}
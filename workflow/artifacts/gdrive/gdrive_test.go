@@ -0,0 +1,143 @@
+package gdrive
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+type fakeFile struct {
+	*drive.File
+	parent  string
+	content string
+}
+
+// fakeDriveClient is a drive of files keyed by ID
+type fakeDriveClient struct {
+	files    map[string]fakeFile
+	exported []string
+}
+
+func (c *fakeDriveClient) get(_ context.Context, fileID string) (*drive.File, error) {
+	f, ok := c.files[fileID]
+	if !ok {
+		return nil, &googleapi.Error{Code: http.StatusNotFound}
+	}
+	return f.File, nil
+}
+
+func (c *fakeDriveClient) list(_ context.Context, folderID, name string) ([]*drive.File, error) {
+	var files []*drive.File
+	for _, f := range c.files {
+		if f.parent == folderID && (name == "" || f.Name == name) {
+			files = append(files, f.File)
+		}
+	}
+	return files, nil
+}
+
+func (c *fakeDriveClient) download(_ context.Context, fileID string) (io.ReadCloser, error) {
+	return ioutil.NopCloser(strings.NewReader(c.files[fileID].content)), nil
+}
+
+func (c *fakeDriveClient) export(_ context.Context, fileID, mimeType string) (io.ReadCloser, error) {
+	c.exported = append(c.exported, mimeType)
+	return ioutil.NopCloser(strings.NewReader(c.files[fileID].content + " as " + mimeType)), nil
+}
+
+func newFakeDriveClient() *fakeDriveClient {
+	return &fakeDriveClient{files: map[string]fakeFile{
+		"root":   {File: &drive.File{Id: "root", Name: "My Drive", MimeType: folderMimeType}},
+		"folder": {File: &drive.File{Id: "folder", Name: "folder", MimeType: folderMimeType}, parent: "root"},
+		"file":   {File: &drive.File{Id: "file", Name: "file.txt", MimeType: "text/plain"}, parent: "folder", content: "my-data"},
+		"sub":    {File: &drive.File{Id: "sub", Name: "sub", MimeType: folderMimeType}, parent: "folder"},
+		"doc":    {File: &drive.File{Id: "doc", Name: "doc", MimeType: "application/vnd.google-apps.document"}, parent: "sub", content: "my-doc"},
+		"form":   {File: &drive.File{Id: "form", Name: "form", MimeType: "application/vnd.google-apps.form"}, parent: "root"},
+	}}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gdrive")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	ctx := context.Background()
+
+	t.Run("FileID", func(t *testing.T) {
+		path := filepath.Join(dir, "by-id.txt")
+		err := load(ctx, newFakeDriveClient(), &wfv1.GDriveArtifact{FileID: "file"}, path)
+		if assert.NoError(t, err) {
+			data, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, "my-data", string(data))
+		}
+	})
+	t.Run("Path", func(t *testing.T) {
+		path := filepath.Join(dir, "by-path.txt")
+		err := load(ctx, newFakeDriveClient(), &wfv1.GDriveArtifact{Path: "folder/file.txt"}, path)
+		if assert.NoError(t, err) {
+			data, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, "my-data", string(data))
+		}
+	})
+	t.Run("PathInFolderID", func(t *testing.T) {
+		path := filepath.Join(dir, "in-folder.txt")
+		err := load(ctx, newFakeDriveClient(), &wfv1.GDriveArtifact{FileID: "folder", Path: "file.txt"}, path)
+		assert.NoError(t, err)
+	})
+	t.Run("Folder", func(t *testing.T) {
+		client := newFakeDriveClient()
+		path := filepath.Join(dir, "folder")
+		err := load(ctx, client, &wfv1.GDriveArtifact{FileID: "folder"}, path)
+		if assert.NoError(t, err) {
+			data, err := ioutil.ReadFile(filepath.Join(path, "file.txt"))
+			assert.NoError(t, err)
+			assert.Equal(t, "my-data", string(data))
+			data, err = ioutil.ReadFile(filepath.Join(path, "sub", "doc"))
+			assert.NoError(t, err)
+			assert.Equal(t, "my-doc as "+defaultExportMimeTypes["application/vnd.google-apps.document"], string(data))
+		}
+	})
+	t.Run("ExportMimeType", func(t *testing.T) {
+		client := newFakeDriveClient()
+		path := filepath.Join(dir, "doc.pdf")
+		err := load(ctx, client, &wfv1.GDriveArtifact{Path: "folder/sub/doc", ExportMimeType: "application/pdf"}, path)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{"application/pdf"}, client.exported)
+		}
+	})
+	t.Run("NoExportMimeType", func(t *testing.T) {
+		err := load(ctx, newFakeDriveClient(), &wfv1.GDriveArtifact{Path: "form"}, filepath.Join(dir, "form"))
+		assert.Error(t, err)
+	})
+	t.Run("NotFolder", func(t *testing.T) {
+		err := load(ctx, newFakeDriveClient(), &wfv1.GDriveArtifact{Path: "folder/file.txt/other"}, filepath.Join(dir, "other"))
+		assert.Error(t, err)
+		assert.False(t, errors.Is(err, common.ErrArtifactNotFound))
+	})
+	t.Run("PathNotFound", func(t *testing.T) {
+		err := load(ctx, newFakeDriveClient(), &wfv1.GDriveArtifact{Path: "folder/missing.txt"}, filepath.Join(dir, "missing.txt"))
+		assert.True(t, errors.Is(err, common.ErrArtifactNotFound))
+	})
+	t.Run("FileIDNotFound", func(t *testing.T) {
+		err := load(ctx, newFakeDriveClient(), &wfv1.GDriveArtifact{FileID: "missing"}, filepath.Join(dir, "missing"))
+		assert.True(t, errors.Is(err, common.ErrArtifactNotFound))
+	})
+}
+
+func TestEscapeQuery(t *testing.T) {
+	assert.Equal(t, `it\'s a \\ name`, escapeQuery(`it's a \ name`))
+}
@@ -1,24 +1,119 @@
 package git
 
 import (
-	"errors"
+	"context"
+	stderrors "errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/crypto/ssh"
 	"gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport/http"
 	ssh2 "gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
 
+	"github.com/argoproj/argo-workflows/v3/errors"
 	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
 )
 
+// commitHash matches a full, SHA-1 or SHA-256, commit hash
+var commitHash = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
+
+// abbreviatedCommitHash matches a commit hash which may be abbreviated
+var abbreviatedCommitHash = regexp.MustCompile(`^[0-9a-f]{4,64}$`)
+
+// ValidateArtifact validates that the ref type of a git artifact is consistent with its revision, that a file of it
+// is a path in the repository of a revision it can be loaded at, and that what it fetches are refspecs
+func ValidateArtifact(errPrefix string, art *wfv1.GitArtifact) error {
+	if _, err := refSpecs(errPrefix, art.Fetch); err != nil {
+		return err
+	}
+	if art.File != "" {
+		if err := validateFile(errPrefix, art); err != nil {
+			return err
+		}
+	}
+	switch art.RefType {
+	case "":
+		return nil
+	case wfv1.GitRefTypeCommit, wfv1.GitRefTypeBranch, wfv1.GitRefTypeTag:
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "%s.refType %q must be one of: commit, branch, tag", errPrefix, art.RefType)
+	}
+	if art.Revision == "" {
+		return errors.Errorf(errors.CodeBadRequest, "%s.refType requires %s.revision", errPrefix, errPrefix)
+	}
+	consistent := true
+	switch {
+	case strings.HasPrefix(art.Revision, "refs/heads/"):
+		consistent = art.RefType == wfv1.GitRefTypeBranch
+	case strings.HasPrefix(art.Revision, "refs/tags/"):
+		consistent = art.RefType == wfv1.GitRefTypeTag
+	case strings.HasPrefix(art.Revision, "refs/"):
+		consistent = false
+	case art.RefType == wfv1.GitRefTypeCommit:
+		consistent = abbreviatedCommitHash.MatchString(art.Revision)
+	}
+	if !consistent {
+		return errors.Errorf(errors.CodeBadRequest, "%s.revision %q is not a %s", errPrefix, art.Revision, art.RefType)
+	}
+	return nil
+}
+
+func validateFile(errPrefix string, art *wfv1.GitArtifact) error {
+	if cleanFile(art.File) == "" {
+		return errors.Errorf(errors.CodeBadRequest, "%s.file %q must be the path of a file in the repository", errPrefix, art.File)
+	}
+	if len(art.Fetch) > 0 || art.RecurseSubmodules || art.TextFilters {
+		return errors.Errorf(errors.CodeBadRequest, "%s.file cannot be combined with %s.fetch, %s.recurseSubmodules or %s.textFilters", errPrefix, errPrefix, errPrefix, errPrefix)
+	}
+	// only a ref or a full commit hash can be fetched, and not e.g. HEAD~1 or an abbreviated commit hash
+	refs := refsOf(art)
+	if art.Revision != "" && art.Revision != "HEAD" && (len(refs) == 0 || refs[0].refType == wfv1.GitRefTypeCommit && !commitHash.MatchString(art.Revision)) {
+		return errors.Errorf(errors.CodeBadRequest, "%s.file requires %s.revision %q to be a branch, tag or full commit hash", errPrefix, errPrefix, art.Revision)
+	}
+	return nil
+}
+
+// refSpecs returns the refspecs of the fetches of an artifact. One without a destination, e.g. the merge ref of a pull
+// request refs/pull/123/merge, which git would only fetch to FETCH_HEAD, is fetched to the ref of the same name, and by
+// force, as such refs are rewritten whenever what they merge changes.
+func refSpecs(errPrefix string, fetch []string) ([]config.RefSpec, error) {
+	specs := make([]config.RefSpec, len(fetch))
+	for i, spec := range fetch {
+		if !strings.Contains(spec, ":") {
+			src := strings.TrimPrefix(spec, "+")
+			if strings.HasPrefix(src, "refs/") {
+				spec = fmt.Sprintf("+%s:%s", src, src)
+			}
+		}
+		specs[i] = config.RefSpec(spec)
+		if err := specs[i].Validate(); err != nil || specs[i].IsDelete() {
+			return nil, errors.Errorf(errors.CodeBadRequest, "%s.fetch %q is neither a refspec, e.g. +refs/heads/*:refs/remotes/origin/*, nor a fully-qualified ref, e.g. refs/pull/123/merge", errPrefix, fetch[i])
+		}
+	}
+	return specs, nil
+}
+
+// cleanFile returns the path of a file in a repository, without leading or trailing slashes, or "" if it is not in it
+func cleanFile(file string) string {
+	p := path.Clean(strings.Trim(file, "/"))
+	if p == "." || p == ".." || strings.HasPrefix(p, "../") {
+		return ""
+	}
+	return p
+}
+
 // GitArtifactDriver is the artifact driver for a git repo
 type GitArtifactDriver struct {
 	Username              string
@@ -33,7 +128,7 @@ func (g *GitArtifactDriver) auth() (func(), transport.AuthMethod, []string, erro
 		if err != nil {
 			return nil, nil, nil, err
 		}
-		privateKeyFile, err := ioutil.TempFile("", "id_rsa.")
+		privateKeyFile, err := common.CreateTemp("id_rsa.")
 		if err != nil {
 			return nil, nil, nil, err
 		}
@@ -91,65 +186,339 @@ esac
 }
 
 // Save is unsupported for git output artifacts
-func (g *GitArtifactDriver) Save(string, *wfv1.Artifact) error {
-	return errors.New("git output artifacts unsupported")
+func (g *GitArtifactDriver) Save(context.Context, string, *wfv1.Artifact) error {
+	return stderrors.New("git output artifacts unsupported")
+}
+
+// Delete is unsupported for git artifacts
+func (g *GitArtifactDriver) Delete(*wfv1.Artifact) error {
+	return common.ErrDeletionNotSupported
+}
+
+// Capabilities returns the optional operations on git artifacts the driver supports
+func (g *GitArtifactDriver) Capabilities() common.DriverCapabilities {
+	return common.DriverCapabilities{}
 }
 
-func (g *GitArtifactDriver) Load(inputArtifact *wfv1.Artifact, path string) error {
+func (g *GitArtifactDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, path string) error {
 	closer, auth, env, err := g.auth()
 	if err != nil {
 		return err
 	}
 	defer closer()
-	repo, err := git.PlainClone(path, false, &git.CloneOptions{
+	if inputArtifact.Git.File != "" {
+		return g.loadFile(ctx, path, env, inputArtifact.Git)
+	}
+	depth := inputArtifact.Git.GetDepth()
+	recurseSubmodules := git.DefaultSubmoduleRecursionDepth
+	if inputArtifact.Git.RecurseSubmodules {
+		// go-git neither authenticates the fetches of submodules nor resolves relative submodule URLs, so git updates
+		// them once the repository is checked out
+		recurseSubmodules = git.NoRecurseSubmodules
+	}
+	repo, err := git.PlainCloneContext(ctx, path, false, &git.CloneOptions{
 		URL:               inputArtifact.Git.Repo,
-		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+		RecurseSubmodules: recurseSubmodules,
 		Auth:              auth,
-		Depth:             inputArtifact.Git.GetDepth(),
+		Depth:             depth,
+		SingleBranch:      inputArtifact.Git.SingleBranch,
 	})
 	if err != nil {
 		return err
 	}
+	if !inputArtifact.Git.TextFilters {
+		if err := disableTextFilters(path); err != nil {
+			return err
+		}
+	}
 	if inputArtifact.Git.Fetch != nil {
-		refSpecs := make([]config.RefSpec, len(inputArtifact.Git.Fetch))
-		for i, spec := range inputArtifact.Git.Fetch {
-			refSpecs[i] = config.RefSpec(spec)
+		specs, err := refSpecs("git", inputArtifact.Git.Fetch)
+		if err != nil {
+			return err
 		}
 		fetchOptions := git.FetchOptions{
 			Auth:     auth,
-			RefSpecs: refSpecs,
-			Depth:    inputArtifact.Git.GetDepth(),
+			RefSpecs: specs,
+			Depth:    depth,
 		}
 		err = fetchOptions.Validate()
 		if err != nil {
 			return err
 		}
-		err = repo.Fetch(&fetchOptions)
+		err = repo.FetchContext(ctx, &fetchOptions)
 		if isAlreadyUpToDateErr(err) {
 			return err
 		}
 	}
 	if inputArtifact.Git.Revision != "" {
+		checkout, err := g.resolveRevision(ctx, path, env, inputArtifact.Git, depth)
+		if err != nil {
+			return err
+		}
 		// We still rely on forking git for checkout, since go-git does not have a reliable
 		// way of resolving revisions (e.g. mybranch, HEAD^, v1.2.3)
 		log.Infof("Checking out revision %s", inputArtifact.Git.Revision)
-		cmd := exec.Command("git", "checkout", inputArtifact.Git.Revision)
+		if err := g.run(ctx, path, env, checkout...); err != nil {
+			return err
+		}
+	}
+	if inputArtifact.Git.Revision != "" || inputArtifact.Git.RecurseSubmodules {
+		// git runs with the environment of the credentials, so that it fetches submodules with them
+		log.Info("Updating submodules")
+		if err := g.run(ctx, path, env, "submodule", "update", "--init", "--recursive", "--force"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadFile loads the file of the artifact, as it is at its revision, to path, without cloning the repository. The
+// revision is fetched into a temporary bare partial clone, which fetches only its commit and trees, and then only the
+// blob of the file once it is read.
+func (g *GitArtifactDriver) loadFile(ctx context.Context, path string, env []string, art *wfv1.GitArtifact) error {
+	tmp, err := common.MkdirTemp("git")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	for _, args := range [][]string{
+		{"init", "--quiet", "--bare"},
+		{"remote", "add", "origin", art.Repo},
+		{"config", "core.repositoryformatversion", "1"},
+		{"config", "extensions.partialClone", "origin"},
+		{"config", "remote.origin.promisor", "true"},
+		{"config", "remote.origin.partialclonefilter", "blob:none"},
+	} {
+		if err := g.run(ctx, tmp, env, args...); err != nil {
+			return err
+		}
+	}
+	depth := art.GetDepth()
+	if depth == 0 {
+		depth = 1
+	}
+	revisionArt := art.DeepCopy()
+	if revisionArt.Revision == "" {
+		revisionArt.Revision = "HEAD"
+	}
+	revision, _, err := g.resolveCommit(ctx, tmp, env, revisionArt, depth)
+	if err != nil {
+		return err
+	}
+	file := cleanFile(art.File)
+	cmd := exec.CommandContext(ctx, "git", "ls-tree", "-z", revision, "--", file)
+	cmd.Dir = tmp
+	cmd.Env = env
+	entry, err := cmd.Output()
+	if err != nil {
+		return g.error(err, cmd)
+	}
+	// an entry is e.g. "100644 blob <hash>\t<file>"
+	fields := strings.Fields(strings.SplitN(string(entry), "\t", 2)[0])
+	if len(fields) != 3 {
+		return errors.Errorf(errors.CodeNotFound, "file %s does not exist at revision %s of repository %s", file, revisionArt.Revision, art.Repo)
+	}
+	if fields[1] != "blob" || fields[0] == "120000" {
+		return errors.Errorf(errors.CodeBadRequest, "%s is not a file at revision %s of repository %s", file, revisionArt.Revision, art.Repo)
+	}
+	log.Infof("Loading file %s of revision %s", file, revisionArt.Revision)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = out.Close() }()
+	cmd = exec.CommandContext(ctx, "git", "cat-file", "blob", fields[2])
+	cmd.Dir = tmp
+	cmd.Env = env
+	cmd.Stdout = out
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		_ = os.Remove(path)
+		log.Errorf("`%s` stderr:\n%s", cmd.Args, stderr.String())
+		return stderrors.New(strings.Split(stderr.String(), "\n")[0])
+	}
+	return out.Close()
+}
+
+// binaryAttributes are the git attributes which check out every file as binary, without converting its line endings or
+// filtering it
+const binaryAttributes = "* -text -eol -ident -filter -working-tree-encoding\n"
+
+// disableTextFilters makes git check out the files of the clone at path byte for byte, as go-git does, whatever the
+// git config and .gitattributes of the repository set. The attributes of $GIT_DIR/info/attributes take precedence over
+// those of any .gitattributes.
+func disableTextFilters(path string) error {
+	info := filepath.Join(path, ".git", "info")
+	if err := os.MkdirAll(info, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(info, "attributes"), []byte(binaryAttributes), 0644)
+}
+
+// gitRef is a ref a revision may be, which the clone has once it is fetched by refSpec
+type gitRef struct {
+	refType wfv1.GitRefType
+	name    string
+	refSpec string
+}
+
+// refsOf returns the refs the revision of the artifact may be, in the order it is resolved to them. A fully-qualified
+// revision is only that ref, which is the destination of a fetch of the artifact it is the source of, e.g.
+// refs/remotes/origin/pr/123 for refs/pull/123/merge and +refs/pull/*/merge:refs/remotes/origin/pr/*. One with a ref
+// type is only a ref of that type. Any other is a commit if it is a full commit hash, else a tag, else a branch, so
+// that a tag is not mistaken for a branch of the same name.
+func refsOf(art *wfv1.GitArtifact) []gitRef {
+	revision := art.Revision
+	commit := gitRef{wfv1.GitRefTypeCommit, revision, revision}
+	branch := gitRef{wfv1.GitRefTypeBranch, "refs/remotes/origin/" + revision, fmt.Sprintf("+refs/heads/%s:refs/remotes/origin/%s", revision, revision)}
+	tag := gitRef{wfv1.GitRefTypeTag, "refs/tags/" + revision, fmt.Sprintf("+refs/tags/%s:refs/tags/%s", revision, revision)}
+	switch {
+	case strings.HasPrefix(revision, "refs/heads/"):
+		name := strings.TrimPrefix(revision, "refs/heads/")
+		return []gitRef{{wfv1.GitRefTypeBranch, "refs/remotes/origin/" + name, fmt.Sprintf("+%s:refs/remotes/origin/%s", revision, name)}}
+	case strings.HasPrefix(revision, "refs/tags/"):
+		return []gitRef{{wfv1.GitRefTypeTag, revision, fmt.Sprintf("+%s:%s", revision, revision)}}
+	case strings.HasPrefix(revision, "refs/"):
+		name := revision
+		// the fetches are validated, so the refspecs which are not valid are only skipped
+		for _, spec := range art.Fetch {
+			specs, err := refSpecs("git", []string{spec})
+			if err == nil && specs[0].Match(plumbing.ReferenceName(revision)) {
+				name = specs[0].Dst(plumbing.ReferenceName(revision)).String()
+				break
+			}
+		}
+		return []gitRef{{"", name, fmt.Sprintf("+%s:%s", revision, name)}}
+	}
+	switch art.RefType {
+	case wfv1.GitRefTypeCommit:
+		return []gitRef{commit}
+	case wfv1.GitRefTypeBranch:
+		return []gitRef{branch}
+	case wfv1.GitRefTypeTag:
+		return []gitRef{tag}
+	}
+	if commitHash.MatchString(revision) {
+		return []gitRef{commit}
+	}
+	// a revision which cannot be the name of a ref, e.g. HEAD~1, is only resolved by git
+	if revision == "HEAD" || strings.ContainsAny(revision, "~^:?*[\\ ") || strings.Contains(revision, "@{") || strings.Contains(revision, "..") {
+		return nil
+	}
+	return []gitRef{tag, branch}
+}
+
+// resolveRevision returns the arguments of the git checkout of the revision of the artifact, fetching its ref if the
+// clone does not have it. A revision which is not any ref it may be is checked out as whatever git resolves it to
+// (e.g. HEAD~1, an abbreviated commit hash), unless it has a ref type, or is fully-qualified, which it must then be.
+func (g *GitArtifactDriver) resolveRevision(ctx context.Context, path string, env []string, art *wfv1.GitArtifact, depth int) ([]string, error) {
+	revision, branch, err := g.resolveCommit(ctx, path, env, art, depth)
+	if err != nil {
+		return nil, err
+	}
+	if branch {
+		// the branch is checked out as a local branch of the same name, as git would check out a remote branch
+		return []string{"checkout", "-B", strings.TrimPrefix(revision, "refs/remotes/origin/"), revision}, nil
+	}
+	return []string{"checkout", "--detach", revision}, nil
+}
+
+// resolveCommit returns what the revision of the artifact resolves to in the clone at path, once it is fetched, and
+// whether it is a branch, as resolveRevision does
+func (g *GitArtifactDriver) resolveCommit(ctx context.Context, path string, env []string, art *wfv1.GitArtifact, depth int) (string, bool, error) {
+	refs := refsOf(art)
+	for _, ref := range refs {
+		found, err := g.fetchRef(ctx, path, env, ref, depth)
+		if err != nil {
+			return "", false, err
+		}
+		if found {
+			return ref.name, ref.refType == wfv1.GitRefTypeBranch, nil
+		}
+	}
+	// a revision which may only be one ref must be that ref
+	if len(refs) == 1 {
+		refType := refs[0].refType
+		if refType == "" {
+			refType = "ref"
+		}
+		return "", false, errors.Errorf(errors.CodeNotFound, "%s %s does not exist in repository %s", refType, art.Revision, art.Repo)
+	}
+	revision := art.Revision
+	// a shallow or single branch clone may not contain the revision, so fetch just enough to check it out
+	if (depth > 0 || art.SingleBranch) && !g.hasRevision(ctx, path, env, revision) {
+		log.Infof("Fetching revision %s", revision)
+		args := []string{"fetch", "origin", revision}
+		if depth > 0 {
+			args = append(args, fmt.Sprintf("--depth=%d", depth))
+		}
+		if err := g.run(ctx, path, env, args...); err != nil {
+			return "", false, err
+		}
+		revision = "FETCH_HEAD"
+	}
+	if !g.hasRevision(ctx, path, env, revision) {
+		return "", false, errors.Errorf(errors.CodeNotFound, "revision %s does not exist in repository %s", art.Revision, art.Repo)
+	}
+	return revision, false, nil
+}
+
+// fetchRef returns whether the clone has the ref, once it is fetched if the clone does not already have it. A branch
+// or tag is fetched only if the repository has it, and a commit if it can be fetched at all.
+func (g *GitArtifactDriver) fetchRef(ctx context.Context, path string, env []string, ref gitRef, depth int) (bool, error) {
+	if g.hasRevision(ctx, path, env, ref.name) {
+		return true, nil
+	}
+	if ref.refType != wfv1.GitRefTypeCommit {
+		remote := strings.SplitN(strings.TrimPrefix(ref.refSpec, "+"), ":", 2)[0]
+		cmd := exec.CommandContext(ctx, "git", "ls-remote", "--exit-code", "origin", remote)
 		cmd.Dir = path
 		cmd.Env = env
-		output, err := cmd.Output()
-		if err != nil {
-			return g.error(err, cmd)
+		if _, err := cmd.Output(); err != nil {
+			// ls-remote exits with 2 if the repository has no matching ref
+			if exErr, ok := err.(*exec.ExitError); ok && exErr.ExitCode() == 2 {
+				return false, nil
+			}
+			return false, g.error(err, cmd)
 		}
-		log.Infof("`%s` stdout:\n%s", cmd.Args, string(output))
-		submodulesCmd := exec.Command("git", "submodule", "update", "--init", "--recursive", "--force")
-		submodulesCmd.Dir = path
-		submodulesCmd.Env = env
-		submoduleOutput, err := submodulesCmd.Output()
-		if err != nil {
-			return g.error(err, cmd)
+	}
+	log.Infof("Fetching %s", ref.refSpec)
+	args := []string{"fetch", "origin", ref.refSpec}
+	if depth > 0 {
+		args = append(args, fmt.Sprintf("--depth=%d", depth))
+	}
+	if err := g.run(ctx, path, env, args...); err != nil {
+		if ref.refType == wfv1.GitRefTypeCommit {
+			log.WithError(err).Infof("Failed to fetch commit %s", ref.name)
+			return false, nil
 		}
-		log.Infof("`%s` stdout:\n%s", cmd.Args, string(submoduleOutput))
+		return false, err
+	}
+	return g.hasRevision(ctx, path, env, ref.name), nil
+}
+
+// hasRevision returns whether the revision resolves to a commit of the clone
+func (g *GitArtifactDriver) hasRevision(ctx context.Context, path string, env []string, revision string) bool {
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--verify", "--quiet", revision+"^{commit}")
+	cmd.Dir = path
+	cmd.Env = env
+	return cmd.Run() == nil
+}
+
+// run runs a git command in the clone at path
+func (g *GitArtifactDriver) run(ctx context.Context, path string, env []string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = path
+	cmd.Env = env
+	output, err := cmd.Output()
+	if err != nil {
+		return g.error(err, cmd)
 	}
+	log.Infof("`%s` stdout:\n%s", cmd.Args, string(output))
 	return nil
 }
 
@@ -160,7 +529,7 @@ func isAlreadyUpToDateErr(err error) bool {
 func (g *GitArtifactDriver) error(err error, cmd *exec.Cmd) error {
 	if exErr, ok := err.(*exec.ExitError); ok {
 		log.Errorf("`%s` stderr:\n%s", cmd.Args, string(exErr.Stderr))
-		return errors.New(strings.Split(string(exErr.Stderr), "\n")[0])
+		return stderrors.New(strings.Split(string(exErr.Stderr), "\n")[0])
 	}
 	return err
 }
@@ -1,12 +1,21 @@
 package git
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"io/ioutil"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
 
+	"github.com/argoproj/argo-workflows/v3/errors"
 	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
 )
 
@@ -18,7 +27,7 @@ func TestGitArtifactDriver_Load(t *testing.T) {
 	path := "/tmp/git-found"
 	assert.NoError(t, os.RemoveAll(path))
 	assert.NoError(t, os.MkdirAll(path, 0777))
-	err := driver.Load(&wfv1.Artifact{
+	err := driver.Load(context.Background(), &wfv1.Artifact{
 		ArtifactLocation: wfv1.ArtifactLocation{
 			Git: &wfv1.GitArtifact{
 				Repo:     "https://github.com/argoproj/argoproj.git",
@@ -36,7 +45,7 @@ func TestGitArtifactDriver_Load(t *testing.T) {
 
 func TestGitArtifactDriver_Save(t *testing.T) {
 	driver := &GitArtifactDriver{}
-	err := driver.Save("", nil)
+	err := driver.Save(context.Background(), "", nil)
 	assert.Error(t, err)
 }
 
@@ -49,7 +58,7 @@ func TestGitArtifactDriverLoad_HTTPS(t *testing.T) {
 	assert.NoError(t, err)
 	driver := &GitArtifactDriver{Username: os.Getenv("GITHUB_TOKEN")}
 	assert.NotEmpty(t, driver.Username)
-	err = driver.Load(&wfv1.Artifact{
+	err = driver.Load(context.Background(), &wfv1.Artifact{
 		ArtifactLocation: wfv1.ArtifactLocation{
 			Git: &wfv1.GitArtifact{
 				Repo:     "https://github.com/argoproj/argo-workflows.git",
@@ -83,7 +92,7 @@ func TestGitArtifactDriverLoad_SSL(t *testing.T) {
 			assert.NoError(t, err)
 			println(tmp)
 			driver := &GitArtifactDriver{SSHPrivateKey: string(data)}
-			err = driver.Load(&wfv1.Artifact{
+			err = driver.Load(context.Background(), &wfv1.Artifact{
 				ArtifactLocation: wfv1.ArtifactLocation{
 					Git: &wfv1.GitArtifact{
 						Repo:                  "git@github.com:argoproj/argo-workflows.git",
@@ -98,3 +107,434 @@ func TestGitArtifactDriverLoad_SSL(t *testing.T) {
 		})
 	}
 }
+
+// newLocalRepo creates a repo with three commits on main, and a branch "feature" with one more, and
+// returns its path and a function which resolves a revision of it
+func newLocalRepo(t *testing.T) (string, func(revision string) string) {
+	dir, err := ioutil.TempDir("", "git-origin")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	run := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		if !assert.NoError(t, err, string(output)) {
+			t.FailNow()
+		}
+		return strings.TrimSpace(string(output))
+	}
+	run("init", "--quiet")
+	run("checkout", "--quiet", "-b", "main")
+	run("config", "uploadpack.allowAnySHA1InWant", "true")
+	for _, name := range []string{"a", "b", "c"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0600))
+		run("add", name)
+		run("commit", "--quiet", "-m", name)
+	}
+	run("checkout", "--quiet", "-b", "feature")
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "d"), []byte("d"), 0600))
+	run("add", "d")
+	run("commit", "--quiet", "-m", "d")
+	run("checkout", "--quiet", "main")
+	return dir, func(revision string) string { return run("rev-parse", revision) }
+}
+
+// countCommits returns the number of commits reachable from HEAD in the clone, and the hash of HEAD
+func countCommits(t *testing.T, path string) (int, string) {
+	repo, err := git.PlainOpen(path)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	head, err := repo.Head()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	commits, err := repo.Log(&git.LogOptions{From: head.Hash()})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	n := 0
+	_ = commits.ForEach(func(c *object.Commit) error {
+		n++
+		return nil
+	})
+	return n, head.Hash().String()
+}
+
+func TestGitArtifactDriver_LoadShallow(t *testing.T) {
+	origin, revParse := newLocalRepo(t)
+	defer func() { _ = os.RemoveAll(origin) }()
+	load := func(t *testing.T, art *wfv1.GitArtifact) string {
+		path, err := ioutil.TempDir("", "git-clone")
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		art.Repo = origin
+		err = (&GitArtifactDriver{}).Load(context.Background(), &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{Git: art}}, path)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return path
+	}
+	t.Run("Full", func(t *testing.T) {
+		path := load(t, &wfv1.GitArtifact{})
+		defer func() { _ = os.RemoveAll(path) }()
+		n, head := countCommits(t, path)
+		assert.Equal(t, 3, n)
+		assert.Equal(t, revParse("main"), head)
+	})
+	t.Run("Depth", func(t *testing.T) {
+		depth := uint64(1)
+		path := load(t, &wfv1.GitArtifact{Depth: &depth})
+		defer func() { _ = os.RemoveAll(path) }()
+		n, head := countCommits(t, path)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, revParse("main"), head)
+	})
+	t.Run("DepthRevisionNotCloned", func(t *testing.T) {
+		depth := uint64(1)
+		revision := revParse("main~2")
+		path := load(t, &wfv1.GitArtifact{Depth: &depth, Revision: revision})
+		defer func() { _ = os.RemoveAll(path) }()
+		n, head := countCommits(t, path)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, revision, head)
+	})
+	t.Run("SingleBranchRevision", func(t *testing.T) {
+		path := load(t, &wfv1.GitArtifact{SingleBranch: true, Revision: "feature"})
+		defer func() { _ = os.RemoveAll(path) }()
+		n, head := countCommits(t, path)
+		assert.Equal(t, 4, n)
+		assert.Equal(t, revParse("feature"), head)
+	})
+}
+
+func TestGitArtifactDriver_LoadSubmodules(t *testing.T) {
+	submodule, _ := newLocalRepo(t)
+	defer func() { _ = os.RemoveAll(submodule) }()
+	origin, err := ioutil.TempDir("", "git-origin")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(origin) }()
+	run := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "protocol.file.allow=always"}, args...)...)
+		cmd.Dir = origin
+		output, err := cmd.CombinedOutput()
+		if !assert.NoError(t, err, string(output)) {
+			t.FailNow()
+		}
+	}
+	run("init", "--quiet")
+	run("checkout", "--quiet", "-b", "main")
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(origin, "p"), []byte("p"), 0600))
+	run("add", "p")
+	// the URL of the submodule is relative to that of the repository
+	run("submodule", "add", "--quiet", "../"+filepath.Base(submodule), "sub")
+	run("commit", "--quiet", "-m", "sub")
+	// git clones submodules from local paths only if it is allowed to
+	for k, v := range map[string]string{"GIT_CONFIG_COUNT": "1", "GIT_CONFIG_KEY_0": "protocol.file.allow", "GIT_CONFIG_VALUE_0": "always"} {
+		assert.NoError(t, os.Setenv(k, v))
+		defer func(k string) { _ = os.Unsetenv(k) }(k)
+	}
+	path, err := ioutil.TempDir("", "git-clone")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(path) }()
+	err = (&GitArtifactDriver{}).Load(context.Background(), &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{Git: &wfv1.GitArtifact{Repo: origin, RecurseSubmodules: true}}}, path)
+	if assert.NoError(t, err) {
+		for _, name := range []string{"a", "b", "c"} {
+			data, err := ioutil.ReadFile(filepath.Join(path, "sub", name))
+			assert.NoError(t, err)
+			assert.Equal(t, name, string(data))
+		}
+	}
+}
+
+func TestValidateArtifact(t *testing.T) {
+	commit := "0123456789abcdef0123456789abcdef01234567"
+	for _, art := range []*wfv1.GitArtifact{
+		{Revision: "feature"},
+		{Revision: "feature", RefType: wfv1.GitRefTypeBranch},
+		{Revision: "v1.2.3", RefType: wfv1.GitRefTypeTag},
+		{Revision: commit, RefType: wfv1.GitRefTypeCommit},
+		{Revision: commit[:7], RefType: wfv1.GitRefTypeCommit},
+		{Revision: "refs/heads/feature", RefType: wfv1.GitRefTypeBranch},
+		{Revision: "refs/tags/v1.2.3", RefType: wfv1.GitRefTypeTag},
+		{Revision: "refs/pull/1/head"},
+		{Revision: "refs/pull/1/merge", Fetch: []string{"refs/pull/1/merge", "+refs/heads/*:refs/remotes/origin/*"}},
+	} {
+		assert.NoError(t, ValidateArtifact("my-art.git", art), art.Revision)
+	}
+	for _, spec := range []string{"pull/1/merge", "refs/pull/*/merge:refs/remotes/origin/pr", ":refs/heads/feature"} {
+		assert.EqualError(t, ValidateArtifact("my-art.git", &wfv1.GitArtifact{Fetch: []string{spec}}),
+			`my-art.git.fetch "`+spec+`" is neither a refspec, e.g. +refs/heads/*:refs/remotes/origin/*, nor a fully-qualified ref, e.g. refs/pull/123/merge`)
+	}
+	assert.EqualError(t, ValidateArtifact("my-art.git", &wfv1.GitArtifact{Revision: "v1.2.3", RefType: "release"}), `my-art.git.refType "release" must be one of: commit, branch, tag`)
+	assert.EqualError(t, ValidateArtifact("my-art.git", &wfv1.GitArtifact{RefType: wfv1.GitRefTypeTag}), "my-art.git.refType requires my-art.git.revision")
+	assert.EqualError(t, ValidateArtifact("my-art.git", &wfv1.GitArtifact{Revision: "v1.2.3", RefType: wfv1.GitRefTypeCommit}), `my-art.git.revision "v1.2.3" is not a commit`)
+	assert.EqualError(t, ValidateArtifact("my-art.git", &wfv1.GitArtifact{Revision: "refs/tags/v1.2.3", RefType: wfv1.GitRefTypeBranch}), `my-art.git.revision "refs/tags/v1.2.3" is not a branch`)
+	assert.EqualError(t, ValidateArtifact("my-art.git", &wfv1.GitArtifact{Revision: "refs/pull/1/head", RefType: wfv1.GitRefTypeTag}), `my-art.git.revision "refs/pull/1/head" is not a tag`)
+	for _, art := range []*wfv1.GitArtifact{
+		{File: "conf/app.yaml"},
+		{File: "/conf/app.yaml", Revision: "HEAD"},
+		{File: "conf/app.yaml", Revision: "v1.2.3"},
+		{File: "conf/app.yaml", Revision: commit, RefType: wfv1.GitRefTypeCommit},
+		{File: "conf/app.yaml", Revision: "refs/pull/1/head"},
+	} {
+		assert.NoError(t, ValidateArtifact("my-art.git", art), art.File)
+	}
+	assert.EqualError(t, ValidateArtifact("my-art.git", &wfv1.GitArtifact{File: "conf/../../app.yaml"}), `my-art.git.file "conf/../../app.yaml" must be the path of a file in the repository`)
+	assert.EqualError(t, ValidateArtifact("my-art.git", &wfv1.GitArtifact{File: "/"}), `my-art.git.file "/" must be the path of a file in the repository`)
+	assert.EqualError(t, ValidateArtifact("my-art.git", &wfv1.GitArtifact{File: "conf/app.yaml", TextFilters: true}), "my-art.git.file cannot be combined with my-art.git.fetch, my-art.git.recurseSubmodules or my-art.git.textFilters")
+	assert.EqualError(t, ValidateArtifact("my-art.git", &wfv1.GitArtifact{File: "conf/app.yaml", Revision: "HEAD~1"}), `my-art.git.file requires my-art.git.revision "HEAD~1" to be a branch, tag or full commit hash`)
+	assert.EqualError(t, ValidateArtifact("my-art.git", &wfv1.GitArtifact{File: "conf/app.yaml", Revision: commit[:7], RefType: wfv1.GitRefTypeCommit}), `my-art.git.file requires my-art.git.revision "0123456" to be a branch, tag or full commit hash`)
+}
+
+func TestGitArtifactDriver_LoadRefType(t *testing.T) {
+	origin, revParse := newLocalRepo(t)
+	defer func() { _ = os.RemoveAll(origin) }()
+	// the tag "feature" is of main~1, so it has the name of the branch "feature", but not its commit
+	cmd := exec.Command("git", "-c", "user.name=test", "-c", "user.email=test@example.com", "tag", "-a", "-m", "feature", "feature", "main~1")
+	cmd.Dir = origin
+	output, err := cmd.CombinedOutput()
+	if !assert.NoError(t, err, string(output)) {
+		return
+	}
+	tag, branch := revParse("refs/tags/feature^{commit}"), revParse("refs/heads/feature")
+	load := func(art *wfv1.GitArtifact) (string, error) {
+		path, err := ioutil.TempDir("", "git-clone")
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer func() { _ = os.RemoveAll(path) }()
+		art.Repo = origin
+		if err := (&GitArtifactDriver{}).Load(context.Background(), &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{Git: art}}, path); err != nil {
+			return "", err
+		}
+		_, head := countCommits(t, path)
+		return head, nil
+	}
+	depth := uint64(1)
+	for _, tt := range []struct {
+		name string
+		art  *wfv1.GitArtifact
+		head string
+	}{
+		{"TagBeforeBranch", &wfv1.GitArtifact{Revision: "feature"}, tag},
+		{"Tag", &wfv1.GitArtifact{Revision: "feature", RefType: wfv1.GitRefTypeTag}, tag},
+		{"Branch", &wfv1.GitArtifact{Revision: "feature", RefType: wfv1.GitRefTypeBranch}, branch},
+		{"Commit", &wfv1.GitArtifact{Revision: revParse("main~2"), RefType: wfv1.GitRefTypeCommit}, revParse("main~2")},
+		{"QualifiedTag", &wfv1.GitArtifact{Revision: "refs/tags/feature"}, tag},
+		{"QualifiedBranch", &wfv1.GitArtifact{Revision: "refs/heads/feature"}, branch},
+		{"ShallowTag", &wfv1.GitArtifact{Revision: "feature", Depth: &depth}, tag},
+		{"SingleBranch", &wfv1.GitArtifact{Revision: "feature", RefType: wfv1.GitRefTypeBranch, SingleBranch: true}, branch},
+		{"Relative", &wfv1.GitArtifact{Revision: "HEAD~1"}, revParse("main~1")},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			head, err := load(tt.art)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.head, head)
+			}
+		})
+	}
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := load(&wfv1.GitArtifact{Revision: "v9.9.9", RefType: wfv1.GitRefTypeTag})
+		assert.EqualError(t, err, "tag v9.9.9 does not exist in repository "+origin)
+		_, err = load(&wfv1.GitArtifact{Revision: "refs/heads/missing"})
+		assert.EqualError(t, err, "branch refs/heads/missing does not exist in repository "+origin)
+		_, err = load(&wfv1.GitArtifact{Revision: "missing"})
+		assert.EqualError(t, err, "revision missing does not exist in repository "+origin)
+	})
+}
+
+func TestGitArtifactDriver_LoadFetch(t *testing.T) {
+	origin, revParse := newLocalRepo(t)
+	defer func() { _ = os.RemoveAll(origin) }()
+	// the merge ref of a pull request is of a merge commit which no branch has, as GitHub creates
+	for _, args := range [][]string{
+		{"checkout", "--quiet", "--detach", "main"},
+		{"merge", "--quiet", "--no-ff", "-m", "merge feature", "feature"},
+		{"update-ref", "refs/pull/1/merge", "HEAD"},
+		{"checkout", "--quiet", "main"},
+	} {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = origin
+		output, err := cmd.CombinedOutput()
+		if !assert.NoError(t, err, string(output)) {
+			return
+		}
+	}
+	merge := revParse("refs/pull/1/merge")
+	// load returns the head of the clone of the artifact, and whether the clone has the ref
+	load := func(art *wfv1.GitArtifact, ref string) (string, bool, error) {
+		path, err := ioutil.TempDir("", "git-clone")
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer func() { _ = os.RemoveAll(path) }()
+		art.Repo = origin
+		if err := (&GitArtifactDriver{}).Load(context.Background(), &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{Git: art}}, path); err != nil {
+			return "", false, err
+		}
+		_, head := countCommits(t, path)
+		cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", ref)
+		cmd.Dir = path
+		return head, cmd.Run() == nil, nil
+	}
+	depth := uint64(1)
+	for _, tt := range []struct {
+		name string
+		art  *wfv1.GitArtifact
+		ref  string
+	}{
+		{"Ref", &wfv1.GitArtifact{Fetch: []string{"refs/pull/1/merge"}, Revision: "refs/pull/1/merge"}, "refs/pull/1/merge"},
+		{"RefSpec", &wfv1.GitArtifact{Fetch: []string{"+refs/pull/1/merge:refs/remotes/origin/pr/1"}, Revision: "refs/pull/1/merge"}, "refs/remotes/origin/pr/1"},
+		{"Wildcard", &wfv1.GitArtifact{Fetch: []string{"+refs/pull/*/merge:refs/remotes/origin/pr/*"}, Revision: "refs/pull/1/merge"}, "refs/remotes/origin/pr/1"},
+		{"Destination", &wfv1.GitArtifact{Fetch: []string{"+refs/pull/*/merge:refs/remotes/origin/pr/*"}, Revision: "refs/remotes/origin/pr/1"}, "refs/remotes/origin/pr/1"},
+		{"Shallow", &wfv1.GitArtifact{Fetch: []string{"refs/pull/1/merge"}, Revision: "refs/pull/1/merge", Depth: &depth}, "refs/pull/1/merge"},
+		{"NotFetched", &wfv1.GitArtifact{Revision: "refs/pull/1/merge"}, "refs/pull/1/merge"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			head, hasRef, err := load(tt.art, tt.ref)
+			if assert.NoError(t, err) {
+				assert.Equal(t, merge, head)
+				assert.True(t, hasRef, tt.ref)
+			}
+		})
+	}
+	t.Run("NotFound", func(t *testing.T) {
+		_, _, err := load(&wfv1.GitArtifact{Fetch: []string{"refs/pull/1/merge"}, Revision: "refs/pull/2/merge"}, "")
+		assert.EqualError(t, err, "ref refs/pull/2/merge does not exist in repository "+origin)
+	})
+}
+
+func TestGitArtifactDriver_LoadTextFilters(t *testing.T) {
+	origin, err := ioutil.TempDir("", "git-origin")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(origin) }()
+	run := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = origin
+		output, err := cmd.CombinedOutput()
+		if !assert.NoError(t, err, string(output)) {
+			t.FailNow()
+		}
+		return strings.TrimSpace(string(output))
+	}
+	run("init", "--quiet")
+	run("checkout", "--quiet", "-b", "main")
+	// the repository wrongly has its binary files converted to CRLF line endings as they are checked out
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(origin, ".gitattributes"), []byte("*.bin text eol=crlf\n"), 0600))
+	blob := []byte{0x89, 'P', 'N', 'G', '\n', 0x1a, '\n', 0x00, 0xff, '\n'}
+	for _, data := range [][]byte{blob, []byte("v2\n")} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(origin, "image.bin"), data, 0600))
+		run("add", ".gitattributes", "image.bin")
+		run("commit", "--quiet", "-m", "image")
+	}
+	// the blob is checked out when it is not that of the cloned HEAD
+	revision := run("rev-parse", "main~1")
+	load := func(textFilters bool) []byte {
+		path, err := ioutil.TempDir("", "git-clone")
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer func() { _ = os.RemoveAll(path) }()
+		err = (&GitArtifactDriver{}).Load(context.Background(), &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{Git: &wfv1.GitArtifact{Repo: origin, Revision: revision, TextFilters: textFilters}}}, path)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		data, err := ioutil.ReadFile(filepath.Join(path, "image.bin"))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return data
+	}
+	t.Run("Default", func(t *testing.T) {
+		assert.Equal(t, sha256.Sum256(blob), sha256.Sum256(load(false)))
+	})
+	t.Run("TextFilters", func(t *testing.T) {
+		assert.Equal(t, bytes.ReplaceAll(blob, []byte("\n"), []byte("\r\n")), load(true))
+	})
+}
+
+func TestGitArtifactDriver_LoadFile(t *testing.T) {
+	origin, err := ioutil.TempDir("", "git-origin")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(origin) }()
+	run := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = origin
+		output, err := cmd.CombinedOutput()
+		if !assert.NoError(t, err, string(output)) {
+			t.FailNow()
+		}
+		return strings.TrimSpace(string(output))
+	}
+	run("init", "--quiet")
+	run("checkout", "--quiet", "-b", "main")
+	run("config", "uploadpack.allowFilter", "true")
+	run("config", "uploadpack.allowAnySHA1InWant", "true")
+	assert.NoError(t, os.MkdirAll(filepath.Join(origin, "conf"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(origin, "large.bin"), bytes.Repeat([]byte("large"), 1024*1024), 0600))
+	for _, version := range []string{"v1", "v2"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(origin, "conf", "app.yaml"), []byte("version: "+version+"\n"), 0600))
+		run("add", ".")
+		run("commit", "--quiet", "-m", version)
+		run("tag", version)
+	}
+	v1 := run("rev-parse", "v1")
+	load := func(art *wfv1.GitArtifact) (string, error) {
+		dir, err := ioutil.TempDir("", "git-file")
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer func() { _ = os.RemoveAll(dir) }()
+		art.Repo = origin
+		path := filepath.Join(dir, "app.yaml")
+		if err := (&GitArtifactDriver{}).Load(context.Background(), &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{Git: art}}, path); err != nil {
+			return "", err
+		}
+		// only the file is loaded, and not the rest of the repository
+		entries, err := ioutil.ReadDir(dir)
+		if assert.NoError(t, err) && assert.Len(t, entries, 1) {
+			assert.Equal(t, "app.yaml", entries[0].Name())
+			assert.True(t, entries[0].Mode().IsRegular())
+		}
+		data, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		return string(data), nil
+	}
+	for _, tt := range []struct {
+		name string
+		art  *wfv1.GitArtifact
+		data string
+	}{
+		{"Head", &wfv1.GitArtifact{File: "conf/app.yaml"}, "version: v2\n"},
+		{"Tag", &wfv1.GitArtifact{File: "conf/app.yaml", Revision: "v1"}, "version: v1\n"},
+		{"Commit", &wfv1.GitArtifact{File: "/conf/app.yaml", Revision: v1, RefType: wfv1.GitRefTypeCommit}, "version: v1\n"},
+		{"Branch", &wfv1.GitArtifact{File: "conf/app.yaml", Revision: "main"}, "version: v2\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := load(tt.art)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.data, data)
+			}
+		})
+	}
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := load(&wfv1.GitArtifact{File: "conf/missing.yaml", Revision: "v1"})
+		assert.EqualError(t, err, "file conf/missing.yaml does not exist at revision v1 of repository "+origin)
+		assert.True(t, errors.IsCode(errors.CodeNotFound, err))
+		_, err = load(&wfv1.GitArtifact{File: "conf", Revision: "v1"})
+		assert.EqualError(t, err, "conf is not a file at revision v1 of repository "+origin)
+		_, err = load(&wfv1.GitArtifact{File: "conf/app.yaml", Revision: "v9", RefType: wfv1.GitRefTypeTag})
+		assert.EqualError(t, err, "tag v9 does not exist in repository "+origin)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 
 	"github.com/argoproj/pkg/file"
@@ -13,6 +14,7 @@ import (
 	"github.com/argoproj/argo-workflows/v3/errors"
 	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
 	"github.com/argoproj/argo-workflows/v3/util"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
 	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/resource"
 )
 
@@ -59,20 +61,25 @@ func ValidateArtifact(errPrefix string, art *wfv1.HDFSArtifact) error {
 
 	hasKrbCCache := art.KrbCCacheSecret != nil
 	hasKrbKeytab := art.KrbKeytabSecret != nil
+	hasKrbConfig := art.KrbConfigConfigMap != nil || art.KrbConfigSecret != nil
 
 	if art.HDFSUser == "" && !hasKrbCCache && !hasKrbKeytab {
 		return errors.Errorf(errors.CodeBadRequest, "either %s.hdfsUser, %s.krbCCacheSecret or %s.krbKeytabSecret is required", errPrefix, errPrefix, errPrefix)
 	}
-	if hasKrbKeytab && (art.KrbServicePrincipalName == "" || art.KrbConfigConfigMap == nil || art.KrbUsername == "" || art.KrbRealm == "") {
-		return errors.Errorf(errors.CodeBadRequest, "%s.krbServicePrincipalName, %s.krbConfigConfigMap, %s.krbUsername and %s.krbRealm are required with %s.krbKeytabSecret", errPrefix, errPrefix, errPrefix, errPrefix, errPrefix)
+	if art.KrbConfigConfigMap != nil && art.KrbConfigSecret != nil {
+		return errors.Errorf(errors.CodeBadRequest, "only one of %s.krbConfigConfigMap or %s.krbConfigSecret can be set", errPrefix, errPrefix)
 	}
-	if hasKrbCCache && (art.KrbServicePrincipalName == "" || art.KrbConfigConfigMap == nil) {
-		return errors.Errorf(errors.CodeBadRequest, "%s.krbServicePrincipalName and %s.krbConfigConfigMap are required with %s.krbCCacheSecret", errPrefix, errPrefix, errPrefix)
+	if hasKrbKeytab && (art.KrbServicePrincipalName == "" || !hasKrbConfig || art.KrbUsername == "" || art.KrbRealm == "") {
+		return errors.Errorf(errors.CodeBadRequest, "%s.krbServicePrincipalName, %s.krbConfigConfigMap or %s.krbConfigSecret, %s.krbUsername and %s.krbRealm are required with %s.krbKeytabSecret", errPrefix, errPrefix, errPrefix, errPrefix, errPrefix, errPrefix)
+	}
+	if hasKrbCCache && (art.KrbServicePrincipalName == "" || !hasKrbConfig) {
+		return errors.Errorf(errors.CodeBadRequest, "%s.krbServicePrincipalName and %s.krbConfigConfigMap or %s.krbConfigSecret are required with %s.krbCCacheSecret", errPrefix, errPrefix, errPrefix, errPrefix)
 	}
 	return nil
 }
 
-// CreateDriver constructs ArtifactDriver
+// CreateDriver constructs ArtifactDriver. The Kerberos ccache or keytab, and config, are read from their secrets and
+// config map, so no file is needed to create a Kerberos client.
 func CreateDriver(ctx context.Context, ci resource.Interface, art *wfv1.HDFSArtifact) (*ArtifactDriver, error) {
 	var krbConfig string
 	var krbOptions *KrbOptions
@@ -84,6 +91,12 @@ func CreateDriver(ctx context.Context, ci resource.Interface, art *wfv1.HDFSArti
 			return nil, err
 		}
 	}
+	if art.KrbConfigSecret != nil && art.KrbConfigSecret.Name != "" {
+		krbConfig, err = ci.GetSecret(ctx, art.KrbConfigSecret.Name, art.KrbConfigSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if art.KrbCCacheSecret != nil && art.KrbCCacheSecret.Name != "" {
 		bytes, err := ci.GetSecret(ctx, art.KrbCCacheSecret.Name, art.KrbCCacheSecret.Key)
 		if err != nil {
@@ -131,21 +144,37 @@ func CreateDriver(ctx context.Context, ci resource.Interface, art *wfv1.HDFSArti
 	return &driver, nil
 }
 
-// Load downloads artifacts from HDFS compliant storage
-func (driver *ArtifactDriver) Load(_ *wfv1.Artifact, path string) error {
-	hdfscli, err := createHDFSClient(driver.Addresses, driver.HDFSUser, driver.KrbOptions)
+// hdfsClient is the part of the HDFS client the driver uses. One client, and so one Kerberos session, transfers
+// every file of a directory, which is renewed between files if it is of a keytab.
+type hdfsClient interface {
+	Stat(name string) (os.FileInfo, error)
+	ReadDir(dirname string) ([]os.FileInfo, error)
+	CopyToLocal(src string, dst string) error
+	CopyToRemote(src string, dst string) error
+	MkdirAll(dirname string, perm os.FileMode) error
+	Remove(name string) error
+}
+
+// Load downloads artifacts from HDFS compliant storage. Directories are downloaded recursively.
+func (driver *ArtifactDriver) Load(ctx context.Context, _ *wfv1.Artifact, path string) error {
+	hdfscli, login, err := createHDFSClient(driver.Addresses, driver.HDFSUser, driver.KrbOptions)
 	if err != nil {
 		return err
 	}
 	defer util.Close(hdfscli)
+	// the HDFS client does not take a context, so the download is aborted by closing it
+	defer common.CloseOnCancel(ctx, func() { util.Close(hdfscli) })()
+	return driver.load(withKrbRenewal(hdfscli, login), path)
+}
 
+func (driver *ArtifactDriver) load(hdfscli hdfsClient, path string) error {
 	srcStat, err := hdfscli.Stat(driver.Path)
 	if err != nil {
+		if os.IsNotExist(err) {
+			return common.NewNotFoundError(err)
+		}
 		return err
 	}
-	if srcStat.IsDir() {
-		return fmt.Errorf("HDFS artifact does not suppot directory copy")
-	}
 
 	_, err = os.Stat(path)
 	if err != nil && !os.IsNotExist(err) {
@@ -153,7 +182,7 @@ func (driver *ArtifactDriver) Load(_ *wfv1.Artifact, path string) error {
 	}
 
 	if os.IsNotExist(err) {
-		dirPath := filepath.Dir(driver.Path)
+		dirPath := filepath.Dir(path)
 		if dirPath != "." && dirPath != "/" {
 			// Follow umask for the permission
 			err = os.MkdirAll(dirPath, 0777)
@@ -163,38 +192,68 @@ func (driver *ArtifactDriver) Load(_ *wfv1.Artifact, path string) error {
 		}
 	} else {
 		if driver.Force {
-			err = os.Remove(path)
+			err = os.RemoveAll(path)
 			if err != nil && !os.IsNotExist(err) {
 				return err
 			}
 		}
 	}
 
+	if srcStat.IsDir() {
+		return loadDir(hdfscli, driver.Path, path)
+	}
 	err = hdfscli.CopyToLocal(driver.Path, path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return errors.New(errors.CodeNotFound, err.Error())
+			return common.NewNotFoundError(err)
 		}
 		return err
 	}
 	return nil
 }
 
-// Save saves an artifact to HDFS compliant storage
-func (driver *ArtifactDriver) Save(path string, outputArtifact *wfv1.Artifact) error {
-	hdfscli, err := createHDFSClient(driver.Addresses, driver.HDFSUser, driver.KrbOptions)
+// loadDir downloads the files of a directory, and of its sub-directories, to the same relative paths under localDir
+func loadDir(hdfscli hdfsClient, dir, localDir string) error {
+	// Follow umask for the permission
+	if err := os.MkdirAll(localDir, 0777); err != nil {
+		return err
+	}
+	infos, err := hdfscli.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		src, dst := path.Join(dir, info.Name()), filepath.Join(localDir, info.Name())
+		if info.IsDir() {
+			err = loadDir(hdfscli, src, dst)
+		} else if info.Mode().IsRegular() {
+			err = hdfscli.CopyToLocal(src, dst)
+		}
+		if err != nil {
+			return fmt.Errorf("copy %s to %s: %w", src, dst, err)
+		}
+	}
+	return nil
+}
+
+// Save saves an artifact to HDFS compliant storage. The files of a directory which match the include and exclude
+// patterns of the artifact are uploaded recursively, to the same relative paths under the path of the artifact.
+func (driver *ArtifactDriver) Save(ctx context.Context, path string, outputArtifact *wfv1.Artifact) error {
+	hdfscli, login, err := createHDFSClient(driver.Addresses, driver.HDFSUser, driver.KrbOptions)
 	if err != nil {
 		return err
 	}
 	defer util.Close(hdfscli)
+	// the HDFS client does not take a context, so the upload is aborted by closing it
+	defer common.CloseOnCancel(ctx, func() { util.Close(hdfscli) })()
+	return driver.save(withKrbRenewal(hdfscli, login), path, common.NewFileFilter(outputArtifact))
+}
 
+func (driver *ArtifactDriver) save(hdfscli hdfsClient, path string, filter common.FileFilter) error {
 	isDir, err := file.IsDirectory(path)
 	if err != nil {
 		return err
 	}
-	if isDir {
-		return fmt.Errorf("HDFS artifact does not suppot directory copy")
-	}
 
 	_, err = hdfscli.Stat(driver.Path)
 	if err != nil && !os.IsNotExist(err) {
@@ -219,5 +278,77 @@ func (driver *ArtifactDriver) Save(path string, outputArtifact *wfv1.Artifact) e
 		}
 	}
 
+	if isDir {
+		return saveDir(hdfscli, path, driver.Path, filter)
+	}
 	return hdfscli.CopyToRemote(path, driver.Path)
 }
+
+// saveDir uploads the files of a local directory which match filter, and the directories they are in
+func saveDir(hdfscli hdfsClient, localDir, dir string, filter common.FileFilter) error {
+	// Follow umask for the permission
+	if err := hdfscli.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	return filepath.Walk(localDir, func(localPath string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		relPath, err := filepath.Rel(localDir, localPath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if !filter.Matches(relPath) {
+			return nil
+		}
+		dst := path.Join(dir, relPath)
+		if err := hdfscli.MkdirAll(path.Dir(dst), 0777); err != nil {
+			return err
+		}
+		if err := hdfscli.CopyToRemote(localPath, dst); err != nil {
+			return fmt.Errorf("copy %s to %s: %w", localPath, dst, err)
+		}
+		return nil
+	})
+}
+
+// Delete deletes an artifact from HDFS compliant storage
+func (driver *ArtifactDriver) Delete(_ *wfv1.Artifact) error {
+	hdfscli, _, err := createHDFSClient(driver.Addresses, driver.HDFSUser, driver.KrbOptions)
+	if err != nil {
+		return err
+	}
+	defer util.Close(hdfscli)
+
+	err = hdfscli.Remove(driver.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Capabilities returns the optional operations on HDFS artifacts the driver supports
+func (driver *ArtifactDriver) Capabilities() common.DriverCapabilities {
+	return common.DriverCapabilities{
+		Delete:      true,
+		HealthCheck: true,
+	}
+}
+
+// HealthCheck checks that the name nodes can be reached, and that the user, or the Kerberos principal, of the driver is
+// accepted, by getting the info of the root directory
+func (driver *ArtifactDriver) HealthCheck(ctx context.Context) error {
+	hdfscli, _, err := createHDFSClient(driver.Addresses, driver.HDFSUser, driver.KrbOptions)
+	if err != nil {
+		return err
+	}
+	defer util.Close(hdfscli)
+	defer common.CloseOnCancel(ctx, func() { util.Close(hdfscli) })()
+	return healthCheck(hdfscli)
+}
+
+func healthCheck(hdfscli hdfsClient) error {
+	_, err := hdfscli.Stat("/")
+	return err
+}
@@ -0,0 +1,301 @@
+package hdfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/resource/resourcetest"
+)
+
+// fakeHDFSClient is an HDFS client of a local directory, which counts the files copied
+type fakeHDFSClient struct {
+	root   string
+	copied int
+}
+
+func (c *fakeHDFSClient) local(name string) string {
+	return filepath.Join(c.root, filepath.FromSlash(name))
+}
+
+func (c *fakeHDFSClient) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(c.local(name))
+}
+
+func (c *fakeHDFSClient) ReadDir(dirname string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(c.local(dirname))
+}
+
+func (c *fakeHDFSClient) CopyToLocal(src string, dst string) error {
+	c.copied++
+	return copyFile(c.local(src), dst)
+}
+
+func (c *fakeHDFSClient) CopyToRemote(src string, dst string) error {
+	if _, err := os.Stat(c.local(dst)); err == nil {
+		return os.ErrExist
+	}
+	c.copied++
+	return copyFile(src, c.local(dst))
+}
+
+func (c *fakeHDFSClient) MkdirAll(dirname string, perm os.FileMode) error {
+	return os.MkdirAll(c.local(dirname), perm)
+}
+
+func (c *fakeHDFSClient) Remove(name string) error {
+	return os.RemoveAll(c.local(name))
+}
+
+func copyFile(src, dst string) error {
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst, data, 0600)
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0700))
+		assert.NoError(t, ioutil.WriteFile(p, []byte(data), 0600))
+	}
+}
+
+func readFiles(t *testing.T, dir string) map[string]string {
+	files := map[string]string{}
+	assert.NoError(t, filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(p)
+		files[filepath.ToSlash(rel)] = string(data)
+		return err
+	}))
+	return files
+}
+
+func TestArtifactDriver_Directory(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "hdfs")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	files := map[string]string{
+		"part-00000":             "a",
+		"part-00001":             "b",
+		"_SUCCESS":               "",
+		"logs/history/job.jhist": "c",
+	}
+	src := filepath.Join(tmp, "src")
+	writeFiles(t, src, files)
+	hdfscli := &fakeHDFSClient{root: filepath.Join(tmp, "hdfs")}
+	assert.NoError(t, os.MkdirAll(hdfscli.root, 0700))
+	driver := &ArtifactDriver{Path: "/user/me/output"}
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		if assert.NoError(t, driver.save(hdfscli, src, common.FileFilter{})) {
+			assert.Equal(t, files, readFiles(t, hdfscli.local("/user/me/output")))
+		}
+		assert.Equal(t, len(files), hdfscli.copied)
+		dst := filepath.Join(tmp, "dst", "output")
+		if assert.NoError(t, driver.load(hdfscli, dst)) {
+			assert.Equal(t, files, readFiles(t, dst))
+		}
+	})
+	t.Run("Exists", func(t *testing.T) {
+		err := driver.save(hdfscli, src, common.FileFilter{})
+		assert.True(t, errors.Is(err, os.ErrExist))
+		force := &ArtifactDriver{Path: "/user/me/output", Force: true}
+		assert.NoError(t, force.save(hdfscli, src, common.FileFilter{}))
+	})
+	t.Run("Filter", func(t *testing.T) {
+		driver := &ArtifactDriver{Path: "/user/me/filtered"}
+		assert.NoError(t, driver.save(hdfscli, src, common.FileFilter{Include: []string{"part-*"}}))
+		assert.Equal(t, map[string]string{"part-00000": "a", "part-00001": "b"}, readFiles(t, hdfscli.local("/user/me/filtered")))
+	})
+	t.Run("File", func(t *testing.T) {
+		driver := &ArtifactDriver{Path: "/user/me/file/part-00000"}
+		assert.NoError(t, driver.save(hdfscli, filepath.Join(src, "part-00000"), common.FileFilter{}))
+		dst := filepath.Join(tmp, "file-dst", "part-00000")
+		if assert.NoError(t, driver.load(hdfscli, dst)) {
+			data, err := ioutil.ReadFile(dst)
+			assert.NoError(t, err)
+			assert.Equal(t, "a", string(data))
+		}
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		driver := &ArtifactDriver{Path: "/user/me/missing"}
+		err := driver.load(hdfscli, filepath.Join(tmp, "missing"))
+		assert.True(t, errors.Is(err, common.ErrArtifactNotFound))
+	})
+}
+
+// deniedHDFSClient denies access to every file, as HDFS does to a user without permission
+type deniedHDFSClient struct {
+	hdfsClient
+}
+
+func (c *deniedHDFSClient) Stat(name string) (os.FileInfo, error) {
+	return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrPermission}
+}
+
+func TestArtifactDriver_HealthCheck(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "hdfs")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	assert.NoError(t, healthCheck(&fakeHDFSClient{root: tmp}))
+	err = healthCheck(&deniedHDFSClient{})
+	assert.True(t, os.IsPermission(err))
+}
+
+// testKeytab returns the bytes of a keytab, of format version 2, with an AES256 key of the principal
+func testKeytab(t *testing.T, username, realm string) []byte {
+	writeString := func(b *bytes.Buffer, s string) {
+		assert.NoError(t, binary.Write(b, binary.BigEndian, uint16(len(s))))
+		b.WriteString(s)
+	}
+	var entry bytes.Buffer
+	assert.NoError(t, binary.Write(&entry, binary.BigEndian, uint16(1))) // components
+	writeString(&entry, realm)
+	writeString(&entry, username)
+	assert.NoError(t, binary.Write(&entry, binary.BigEndian, uint32(1))) // KRB_NT_PRINCIPAL
+	assert.NoError(t, binary.Write(&entry, binary.BigEndian, uint32(time.Now().Unix())))
+	entry.WriteByte(1)                                                    // key version
+	assert.NoError(t, binary.Write(&entry, binary.BigEndian, uint16(18))) // aes256-cts-hmac-sha1-96
+	writeString(&entry, string(bytes.Repeat([]byte{0x2a}, 32)))
+	assert.NoError(t, binary.Write(&entry, binary.BigEndian, uint32(1)))
+	var kt bytes.Buffer
+	kt.Write([]byte{0x05, 0x02})
+	assert.NoError(t, binary.Write(&kt, binary.BigEndian, int32(entry.Len())))
+	kt.Write(entry.Bytes())
+	return kt.Bytes()
+}
+
+const testKrbConfig = `[libdefaults]
+  default_realm = EXAMPLE.COM
+  ticket_lifetime = 10h
+
+[realms]
+  EXAMPLE.COM = {
+    kdc = kdc.example.com:88
+  }
+`
+
+func TestCreateDriver_Keytab(t *testing.T) {
+	ci := resourcetest.NewFakeInterface(map[string]string{
+		"my-krb/keytab":    string(testKeytab(t, "my-user", "EXAMPLE.COM")),
+		"my-krb/krb5.conf": testKrbConfig,
+	})
+	ci.ConfigMaps = map[string]string{"my-config/krb5.conf": testKrbConfig}
+	art := &wfv1.HDFSArtifact{
+		HDFSConfig: wfv1.HDFSConfig{
+			HDFSKrbConfig: wfv1.HDFSKrbConfig{
+				KrbKeytabSecret:         &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-krb"}, Key: "keytab"},
+				KrbConfigSecret:         &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-krb"}, Key: "krb5.conf"},
+				KrbUsername:             "my-user",
+				KrbRealm:                "EXAMPLE.COM",
+				KrbServicePrincipalName: "hdfs/namenode",
+			},
+			Addresses: []string{"namenode:8020"},
+		},
+		Path: "/user/my-user/output",
+	}
+	assert.NoError(t, ValidateArtifact("my-art", art))
+
+	t.Run("Secret", func(t *testing.T) {
+		driver, err := CreateDriver(context.Background(), ci, art)
+		if !assert.NoError(t, err) {
+			return
+		}
+		if assert.NotNil(t, driver.KrbOptions) && assert.NotNil(t, driver.KrbOptions.KeytabOptions) {
+			assert.Len(t, driver.KrbOptions.KeytabOptions.Keytab.Entries, 1)
+			assert.Equal(t, testKrbConfig, driver.KrbOptions.Config)
+			assert.Equal(t, "hdfs/namenode", driver.KrbOptions.ServicePrincipalName)
+		}
+		client, err := newKrbClient(driver.KrbOptions)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "my-user", client.Credentials.Username)
+		assert.Equal(t, "EXAMPLE.COM", client.Credentials.Realm)
+		assert.True(t, client.Credentials.HasKeytab())
+		assert.Equal(t, "EXAMPLE.COM", client.Config.LibDefaults.DefaultRealm)
+		assert.Equal(t, 10*time.Hour, newKrbLogin(client).lifetime)
+	})
+	t.Run("ConfigMap", func(t *testing.T) {
+		art := art.DeepCopy()
+		art.KrbConfigSecret = nil
+		art.KrbConfigConfigMap = &apiv1.ConfigMapKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-config"}, Key: "krb5.conf"}
+		assert.NoError(t, ValidateArtifact("my-art", art))
+		driver, err := CreateDriver(context.Background(), ci, art)
+		if assert.NoError(t, err) {
+			assert.Equal(t, testKrbConfig, driver.KrbOptions.Config)
+		}
+	})
+	t.Run("BothConfigs", func(t *testing.T) {
+		art := art.DeepCopy()
+		art.KrbConfigConfigMap = &apiv1.ConfigMapKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-config"}, Key: "krb5.conf"}
+		assert.Error(t, ValidateArtifact("my-art", art))
+	})
+	t.Run("InvalidKeytab", func(t *testing.T) {
+		ci := resourcetest.NewFakeInterface(map[string]string{"my-krb/keytab": "not a keytab", "my-krb/krb5.conf": testKrbConfig})
+		_, err := CreateDriver(context.Background(), ci, art)
+		assert.Error(t, err)
+	})
+}
+
+func TestKrbLogin_Renew(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "hdfs")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	src := filepath.Join(tmp, "src")
+	writeFiles(t, src, map[string]string{"part-00000": "a", "part-00001": "b", "part-00002": "c"})
+	fake := &fakeHDFSClient{root: filepath.Join(tmp, "hdfs")}
+	assert.NoError(t, os.MkdirAll(fake.root, 0700))
+
+	// each file takes half an hour to copy, so the ticket has to be renewed before the last one
+	now := time.Now()
+	logins := 0
+	login := &krbLogin{
+		login:    func() error { logins++; return nil },
+		lifetime: time.Hour,
+		now:      func() time.Time { now = now.Add(30 * time.Minute); return now },
+		loggedIn: now,
+	}
+	driver := &ArtifactDriver{Path: "/user/me/output"}
+	if assert.NoError(t, driver.save(withKrbRenewal(fake, login), src, common.FileFilter{})) {
+		assert.Equal(t, 3, fake.copied)
+		assert.Equal(t, 1, logins)
+	}
+
+	t.Run("Failed", func(t *testing.T) {
+		login.login = func() error { return errors.New("KDC unreachable") }
+		driver := &ArtifactDriver{Path: "/user/me/failed"}
+		err := driver.save(withKrbRenewal(fake, login), src, common.FileFilter{})
+		assert.EqualError(t, err, "copy "+filepath.Join(src, "part-00000")+" to /user/me/failed/part-00000: renew Kerberos ticket: KDC unreachable")
+	})
+	t.Run("NoLogin", func(t *testing.T) {
+		assert.Equal(t, hdfsClient(fake), withKrbRenewal(fake, nil))
+	})
+}
@@ -2,21 +2,31 @@ package hdfs
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/colinmarc/hdfs"
 	krb "gopkg.in/jcmturner/gokrb5.v5/client"
 	"gopkg.in/jcmturner/gokrb5.v5/config"
 )
 
-func createHDFSClient(addresses []string, user string, krbOptions *KrbOptions) (*hdfs.Client, error) {
+// defaultTicketLifetime is the lifetime of a Kerberos ticket if the config does not set ticket_lifetime
+const defaultTicketLifetime = 24 * time.Hour
+
+// createHDFSClient creates an HDFS client, and the login of its Kerberos client if that is of a keytab, which is nil
+// otherwise
+func createHDFSClient(addresses []string, user string, krbOptions *KrbOptions) (*hdfs.Client, *krbLogin, error) {
 	options := hdfs.ClientOptions{
 		Addresses: addresses,
 	}
 
+	var login *krbLogin
 	if krbOptions != nil {
 		krbClient, err := createKrbClient(krbOptions)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		if krbOptions.KeytabOptions != nil {
+			login = newKrbLogin(krbClient)
 		}
 		options.KerberosClient = krbClient
 		options.KerberosServicePrincipleName = krbOptions.ServicePrincipalName
@@ -24,10 +34,26 @@ func createHDFSClient(addresses []string, user string, krbOptions *KrbOptions) (
 		options.User = user
 	}
 
-	return hdfs.NewClient(options)
+	hdfscli, err := hdfs.NewClient(options)
+	return hdfscli, login, err
 }
 
 func createKrbClient(krbOptions *KrbOptions) (*krb.Client, error) {
+	client, err := newKrbClient(krbOptions)
+	if err != nil {
+		return nil, err
+	}
+	if krbOptions.KeytabOptions != nil {
+		err = client.Login()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// newKrbClient creates the Kerberos client of the ccache or keytab of krbOptions, without logging in with a keytab
+func newKrbClient(krbOptions *KrbOptions) (*krb.Client, error) {
 	krbConfig, err := config.NewConfigFromString(krbOptions.Config)
 	if err != nil {
 		return nil, err
@@ -42,12 +68,68 @@ func createKrbClient(krbOptions *KrbOptions) (*krb.Client, error) {
 	} else if krbOptions.KeytabOptions != nil {
 		client := krb.NewClientWithKeytab(krbOptions.KeytabOptions.Username, krbOptions.KeytabOptions.Realm, krbOptions.KeytabOptions.Keytab)
 		client = *client.WithConfig(krbConfig)
-		err = client.Login()
-		if err != nil {
-			return nil, err
-		}
 		return &client, nil
 	}
 
 	return nil, fmt.Errorf("Failed to get a Kerberos client")
 }
+
+// krbLogin is the login of the Kerberos client of a keytab, which is renewed by logging in again once most of the
+// lifetime of its ticket has passed. The HDFS client authenticates with the ticket whenever it connects to a name node,
+// so a transfer which outlasts the ticket would otherwise fail part way.
+type krbLogin struct {
+	login    func() error
+	lifetime time.Duration
+	now      func() time.Time
+	loggedIn time.Time
+}
+
+// newKrbLogin returns the login of a Kerberos client which has just logged in
+func newKrbLogin(client *krb.Client) *krbLogin {
+	lifetime := client.Config.LibDefaults.TicketLifetime
+	if lifetime <= 0 {
+		lifetime = defaultTicketLifetime
+	}
+	return &krbLogin{login: client.Login, lifetime: lifetime, now: time.Now, loggedIn: time.Now()}
+}
+
+// renew logs in again if 5/6 of the lifetime of the ticket has passed since the last login, which leaves time to
+// finish copying a file before it expires
+func (l *krbLogin) renew() error {
+	if l == nil || l.now().Sub(l.loggedIn) < l.lifetime*5/6 {
+		return nil
+	}
+	if err := l.login(); err != nil {
+		return fmt.Errorf("renew Kerberos ticket: %w", err)
+	}
+	l.loggedIn = l.now()
+	return nil
+}
+
+// krbRenewingClient is an HDFS client which renews its Kerberos login before each file it copies
+type krbRenewingClient struct {
+	hdfsClient
+	login *krbLogin
+}
+
+// withKrbRenewal returns hdfscli, renewing the Kerberos login before each file it copies if there is one
+func withKrbRenewal(hdfscli hdfsClient, login *krbLogin) hdfsClient {
+	if login == nil {
+		return hdfscli
+	}
+	return &krbRenewingClient{hdfsClient: hdfscli, login: login}
+}
+
+func (c *krbRenewingClient) CopyToLocal(src string, dst string) error {
+	if err := c.login.renew(); err != nil {
+		return err
+	}
+	return c.hdfsClient.CopyToLocal(src, dst)
+}
+
+func (c *krbRenewingClient) CopyToRemote(src string, dst string) error {
+	if err := c.login.renew(); err != nil {
+		return err
+	}
+	return c.hdfsClient.CopyToRemote(src, dst)
+}
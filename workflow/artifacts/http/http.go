@@ -1,46 +1,642 @@
 package http
 
 import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/x509"
+	stderrors "errors"
 	"fmt"
-	"os/exec"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
 	"strings"
+	"time"
 
+	"github.com/andybalholm/brotli"
 	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
 
 	"github.com/argoproj/argo-workflows/v3/errors"
 	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const (
+	defaultRetryLimit     = 4
+	defaultRetryBaseDelay = time.Second
+	// defaultRedirectLimit is the most redirects a request follows if the artifact does not set a limit
+	defaultRedirectLimit = 10
+	// acceptEncoding is the Accept-Encoding of loads, which are of the encodings decodeBody can decode
+	acceptEncoding = "gzip, deflate, br"
 )
 
 // HTTPArtifactDriver is the artifact driver for a HTTP URL
-type HTTPArtifactDriver struct{}
+type HTTPArtifactDriver struct {
+	// Context is the context of requests which are not given one, i.e. OpenStream and Exists
+	Context context.Context
+	// RootCAs verify TLS connections, if set, rather than the system CA certificates
+	RootCAs *x509.CertPool
+	// ProxyURL is the proxy requests are sent through, if set, rather than that of the environment
+	ProxyURL *url.URL
+	// BearerToken is sent in an "Authorization: Bearer" header, if set, otherwise Username and Password are
+	// sent with basic auth, if set. Neither is ever logged.
+	BearerToken string
+	Username    string
+	Password    string
+	// Timeout limits each request, including reading the response body, so that it also bounds OpenStream
+	// and Exists, which are not given a context. There is no limit if it is not set.
+	Timeout time.Duration
+	common.Logging
+	common.Transporting
+}
 
 // Load download artifacts from an HTTP URL
-func (h *HTTPArtifactDriver) Load(inputArtifact *wfv1.Artifact, path string) error {
-	// Download the file to a local file path
-	args := []string{"-fsS", "-L", "-o", path, inputArtifact.HTTP.URL}
-	headers := inputArtifact.HTTP.Headers
-	for _, v := range headers {
-		// Build curl -H string for each key-value header parameter
-		args = append(args, "-H", fmt.Sprintf("%s: %s", v.Name, v.Value))
-	}
-	log.Info(strings.Join(append([]string{"curl"}, args...), " "))
-	cmd := exec.Command("curl", args...)
-	output, err := cmd.CombinedOutput()
-	log.Info(string(output))
-	if err != nil {
-		log.WithError(err).Error()
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			// https://ec.haxx.se/usingcurl/usingcurl-returns
-			// 22 - HTTP page not retrieved.
-			if exitErr.ExitCode() == 22 {
-				return errors.New(errors.CodeNotFound, exitErr.Error())
+func (h *HTTPArtifactDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, path string) error {
+	return h.LoadWithProgress(ctx, inputArtifact, path, nil)
+}
+
+// LoadWithProgress downloads artifacts from an HTTP URL, reporting progress against the Content-Length
+// of the response, if it has one. A download which is interrupted is retried with the backoff of the artifact, and
+// resumed from the last byte written, with a Range request, if the server accepts ranges and the response has a
+// validator which the If-Range of the request checks the resource has not changed with. Otherwise it restarts. It
+// downloads to a temporary path, which only replaces path once it succeeds.
+func (h *HTTPArtifactDriver) LoadWithProgress(ctx context.Context, inputArtifact *wfv1.Artifact, path string, progress common.ProgressFunc) error {
+	return common.LoadAtomically(path, func(path string) error {
+		return h.loadWithProgress(ctx, inputArtifact, path, progress)
+	})
+}
+
+func (h *HTTPArtifactDriver) loadWithProgress(ctx context.Context, inputArtifact *wfv1.Artifact, path string, progress common.ProgressFunc) error {
+	h.Log().Infof("HTTP Load path: %s, url: %s", path, common.RedactURL(inputArtifact.HTTP.URL))
+	backoff, err := newBackoff(inputArtifact.HTTP.Retry)
+	if err != nil {
+		return err
+	}
+	maxSize, err := common.MaxSize(inputArtifact)
+	if err != nil {
+		return err
+	}
+	res, err := h.get(ctx, inputArtifact, "", "")
+	if err != nil {
+		return err
+	}
+	if err := checkContentLength(res, 0, maxSize); err != nil {
+		_ = res.Body.Close()
+		return err
+	}
+	lf, err := os.Create(path)
+	if err != nil {
+		_ = res.Body.Close()
+		return err
+	}
+	defer func() {
+		_ = lf.Close()
+	}()
+	// the Content-Length is that of the encoded body, so the progress is of the bytes received
+	counter := common.NewProgressCounter(res.ContentLength, progress)
+	validator := resumeValidator(res)
+	var written int64
+	for attempt := 1; ; attempt++ {
+		n, interrupted, err := h.copyBody(lf, inputArtifact.HTTP, res, counter, maxSize, written)
+		written += n
+		if err == nil {
+			break
+		}
+		if !interrupted || backoff.Steps < 1 || ctx.Err() != nil {
+			return err
+		}
+		delay := backoff.Step()
+		h.Log().WithFields(log.Fields{"url": common.RedactURL(inputArtifact.HTTP.URL), "attempt": attempt, "bytes": written}).Warnf("HTTP Load interrupted: %v, retrying in %v", err, delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		res, err = h.resume(ctx, inputArtifact, written, validator)
+		if err != nil {
+			return err
+		}
+		offset := written
+		if res.StatusCode != http.StatusPartialContent {
+			offset = 0
+		}
+		if err := checkContentLength(res, offset, maxSize); err != nil {
+			_ = res.Body.Close()
+			return err
+		}
+		if res.StatusCode != http.StatusPartialContent {
+			h.Log().Infof("HTTP Load of %s restarts from the first byte", common.RedactURL(inputArtifact.HTTP.URL))
+			if _, err := lf.Seek(0, io.SeekStart); err != nil {
+				_ = res.Body.Close()
+				return err
+			}
+			if err := lf.Truncate(0); err != nil {
+				_ = res.Body.Close()
+				return err
+			}
+			written = 0
+			counter = common.NewProgressCounter(res.ContentLength, progress)
+			validator = resumeValidator(res)
+		}
+	}
+	counter.Done()
+	h.Log().WithFields(log.Fields{"url": common.RedactURL(inputArtifact.HTTP.URL), "bytes": written}).Debug("Downloaded from HTTP")
+	return nil
+}
+
+// bodyReader records the error of reading the body of a response, so that a load can tell it being interrupted,
+// which is retried, from failing to write the file, which is not
+type bodyReader struct {
+	io.Reader
+	err error
+}
+
+func (r *bodyReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// copyBody writes the decoded body of the response to the file and closes it, returning the number of bytes
+// written, and whether an error was of reading the body. It fails once the offset bytes written before it, and those
+// it writes, are more than maxSize, which is not a limit if it is not positive.
+func (h *HTTPArtifactDriver) copyBody(lf *os.File, art *wfv1.HTTPArtifact, res *http.Response, counter *common.ProgressCounter, maxSize, offset int64) (int64, bool, error) {
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	body := &bodyReader{Reader: counter.Reader(res.Body)}
+	decoded, err := h.decodeBody(art, res, body)
+	if err != nil {
+		return 0, body.err != nil, err
+	}
+	n, err := io.Copy(lf, common.NewSizeLimitedReader(decoded, maxSize, offset))
+	return n, err != nil && body.err != nil, err
+}
+
+// checkContentLength returns an error which matches common.ErrArtifactTooLarge if the Content-Length of the response,
+// of the bytes of the artifact from offset, tells that the artifact is larger than maxSize. That of an encoded body is
+// not checked, as it is not the size of the decoded bytes written; those are limited as they are written instead.
+func checkContentLength(res *http.Response, offset, maxSize int64) error {
+	if res.ContentLength < 0 || isEncoded(res) {
+		return nil
+	}
+	return common.CheckSize(offset+res.ContentLength, maxSize)
+}
+
+// isEncoded returns whether the body of the response has a Content-Encoding other than identity
+func isEncoded(res *http.Response) bool {
+	encoding := strings.TrimSpace(res.Header.Get("Content-Encoding"))
+	return encoding != "" && !strings.EqualFold(encoding, "identity")
+}
+
+// resumeValidator returns the validator a load of the response can be resumed with, which is its strong ETag, or
+// else its Last-Modified, or "" if it cannot be resumed, as it cannot if the server does not accept ranges, or if
+// the body is encoded, as the bytes written are decoded
+func resumeValidator(res *http.Response) string {
+	if !strings.EqualFold(strings.TrimSpace(res.Header.Get("Accept-Ranges")), "bytes") {
+		return ""
+	}
+	if isEncoded(res) {
+		return ""
+	}
+	if etag := res.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return res.Header.Get("Last-Modified")
+}
+
+// resume returns the response to a request for the bytes of the artifact from offset, with the validator as its
+// If-Range, which is a 206 of those bytes, or else a response of every byte, as it is if the resource changed, the
+// load cannot be resumed, or the server responds with a range other than that requested
+func (h *HTTPArtifactDriver) resume(ctx context.Context, inputArtifact *wfv1.Artifact, offset int64, validator string) (*http.Response, error) {
+	if validator != "" && offset > 0 {
+		res, err := h.get(ctx, inputArtifact, common.ByteRange(offset, -1), validator)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode != http.StatusPartialContent {
+			return res, nil
+		}
+		var start int64
+		if _, err := fmt.Sscanf(res.Header.Get("Content-Range"), "bytes %d-", &start); err == nil && start == offset {
+			return res, nil
+		}
+		_ = res.Body.Close()
+	}
+	return h.get(ctx, inputArtifact, "", "")
+}
+
+// OpenStream opens an HTTP URL for reading, decoding the response body as Load does. The response body is closed
+// when the reader is closed.
+func (h *HTTPArtifactDriver) OpenStream(inputArtifact *wfv1.Artifact) (io.ReadCloser, error) {
+	res, err := h.get(h.context(), inputArtifact, "", "")
+	if err != nil {
+		return nil, err
+	}
+	body, err := h.decodeBody(inputArtifact.HTTP, res, res.Body)
+	if err != nil {
+		_ = res.Body.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{body, res.Body}, nil
+}
+
+// decodeBody returns a reader of body, that of the response, decoded with its Content-Encoding, unless decompression
+// is disabled. A response with an encoding which cannot be decoded, e.g. compress, which is not requested, is read as is.
+func (h *HTTPArtifactDriver) decodeBody(art *wfv1.HTTPArtifact, res *http.Response, body io.Reader) (io.Reader, error) {
+	if art.DisableDecompression {
+		return body, nil
+	}
+	var encodings []string
+	for _, encoding := range strings.Split(res.Header.Get("Content-Encoding"), ",") {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		switch encoding {
+		case "", "identity":
+		case "gzip", "x-gzip", "deflate", "br":
+			encodings = append(encodings, encoding)
+		default:
+			h.Log().Warnf("%s has a Content-Encoding of %q, which cannot be decoded, so it is loaded as is", common.RedactURL(art.URL), encoding)
+			return body, nil
+		}
+	}
+	// the encodings are listed in the order they were applied, and so are decoded in reverse
+	decoded := body
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var err error
+		switch encodings[i] {
+		case "deflate":
+			decoded, err = newDeflateReader(decoded)
+		case "br":
+			// an empty body is read as empty
+			decoded = brotli.NewReader(decoded)
+		default:
+			var gz *gzip.Reader
+			gz, err = gzip.NewReader(decoded)
+			if err == io.EOF {
+				// the body is empty, e.g. that of a 204, and gz is nil
+				return decoded, nil
 			}
+			decoded = gz
 		}
+		if err != nil {
+			return nil, errors.InternalErrorf("decoding the %s content of %s failed: %v", encodings[i], common.RedactURL(art.URL), err)
+		}
+	}
+	return decoded, nil
+}
+
+// newDeflateReader returns a reader of deflate encoded content, which is zlib wrapped, though some servers send it
+// unwrapped, as it is read if it does not start with a valid zlib header
+func newDeflateReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err == io.EOF {
+		return br, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// LoadRange downloads a range of the bytes at an HTTP URL, with a Range header. Only the bytes in the range are
+// written if the server responds with every byte, as servers which do not support ranges do.
+func (h *HTTPArtifactDriver) LoadRange(artifact *wfv1.Artifact, start, length int64, path string) error {
+	h.Log().Infof("HTTP LoadRange path: %s, url: %s, range: %s", path, common.RedactURL(artifact.HTTP.URL), common.ByteRangeSpec(start, length))
+	res, err := h.get(h.context(), artifact, common.ByteRange(start, length), "")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	var body io.Reader = res.Body
+	if res.StatusCode != http.StatusPartialContent {
+		if _, err := io.CopyN(ioutil.Discard, body, start); err == io.EOF {
+			return errors.Errorf(errors.CodeBadRequest, "the range %s starts after the end of %s", common.ByteRangeSpec(start, length), common.RedactURL(artifact.HTTP.URL))
+		} else if err != nil {
+			return err
+		}
+	}
+	if length >= 0 {
+		body = io.LimitReader(body, length)
+	}
+	lf, err := os.Create(path)
+	if err != nil {
 		return err
 	}
+	defer func() {
+		_ = lf.Close()
+	}()
+	_, err = io.Copy(lf, body)
+	return err
+}
+
+// newRequest returns a request for the URL of the artifact with its headers and the credentials of the driver,
+// which replace any Authorization header of the artifact, unless its URL is presigned
+func (h *HTTPArtifactDriver) newRequest(method string, art *wfv1.HTTPArtifact) (*http.Request, error) {
+	req, err := http.NewRequest(method, art.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range art.Headers {
+		req.Header.Add(v.Name, v.Value)
+	}
+	if art.Upload != nil && art.Upload.Presigned {
+		// the URL is the credential, and an Authorization header would be rejected as a second one
+		return req, nil
+	}
+	if h.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+h.BearerToken)
+	} else if h.Username != "" || h.Password != "" {
+		req.SetBasicAuth(h.Username, h.Password)
+	}
+	return req, nil
+}
+
+// get returns the successful response to a GET request for the URL of the artifact, with the Range header if
+// byteRange is set, and the If-Range header if ifRange is
+func (h *HTTPArtifactDriver) get(ctx context.Context, inputArtifact *wfv1.Artifact, byteRange, ifRange string) (*http.Response, error) {
+	res, err := h.do(ctx, inputArtifact.HTTP, func() (*http.Request, error) {
+		req, err := h.newRequest(http.MethodGet, inputArtifact.HTTP)
+		if err != nil {
+			return nil, err
+		}
+		// the Accept-Encoding is always set, so that the transport neither requests nor decodes gzip itself. A range
+		// is of the content as it is, which is not requested encoded.
+		if byteRange != "" {
+			req.Header.Set("Range", byteRange)
+			if ifRange != "" {
+				req.Header.Set("If-Range", ifRange)
+			}
+		} else if req.Header.Get("Accept-Encoding") == "" {
+			if inputArtifact.HTTP.DisableDecompression {
+				req.Header.Set("Accept-Encoding", "identity")
+			} else {
+				req.Header.Set("Accept-Encoding", acceptEncoding)
+			}
+		}
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		_ = res.Body.Close()
+		return nil, common.NewNotFoundError(fmt.Errorf("loading file from %s failed with reason:%s", common.RedactURL(inputArtifact.HTTP.URL), res.Status))
+	}
+	if res.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		_ = res.Body.Close()
+		return nil, errors.Errorf(errors.CodeBadRequest, "the range %s of %s is not satisfiable", byteRange, common.RedactURL(inputArtifact.HTTP.URL))
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		_ = res.Body.Close()
+		return nil, errors.InternalErrorf("loading file from %s failed with reason:%s", common.RedactURL(inputArtifact.HTTP.URL), res.Status)
+	}
+	return res, nil
+}
+
+// Exists sends a HEAD request to the URL of the artifact
+func (h *HTTPArtifactDriver) Exists(artifact *wfv1.Artifact) (bool, error) {
+	h.Log().Infof("HTTP Exists url: %s", common.RedactURL(artifact.HTTP.URL))
+	res, err := h.do(h.context(), artifact.HTTP, func() (*http.Request, error) {
+		return h.newRequest(http.MethodHead, artifact.HTTP)
+	})
+	if err != nil {
+		return false, err
+	}
+	_ = res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return false, errors.InternalErrorf("checking %s exists failed with reason:%s", common.RedactURL(artifact.HTTP.URL), res.Status)
+	}
+	return true, nil
+}
+
+// Save uploads the file at path to the URL of the artifact, if it has an upload
+func (h *HTTPArtifactDriver) Save(ctx context.Context, path string, outputArtifact *wfv1.Artifact) error {
+	return h.SaveWithProgress(ctx, path, outputArtifact, nil)
+}
+
+// SaveWithProgress uploads the file at path to the URL of the artifact in a single request, whose body is streamed
+// from the file, with its size as the Content-Length, as presigned URLs require, rather than chunked
+func (h *HTTPArtifactDriver) SaveWithProgress(ctx context.Context, path string, outputArtifact *wfv1.Artifact, progress common.ProgressFunc) error {
+	if outputArtifact == nil || outputArtifact.HTTP == nil || outputArtifact.HTTP.Upload == nil {
+		return errors.Errorf(errors.CodeBadRequest, "HTTP output artifacts unsupported without an upload")
+	}
+	art := outputArtifact.HTTP
+	method := art.Upload.Method
+	if method == "" {
+		method = http.MethodPut
+	}
+	h.Log().Infof("HTTP Save path: %s, url: %s, method: %s", path, common.RedactURL(art.URL), method)
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return errors.Errorf(errors.CodeBadRequest, "HTTP output artifact %s is a directory, which can only be saved archived", outputArtifact.Name)
+	}
+	// the progress restarts if the upload is retried
+	var counter *common.ProgressCounter
+	res, err := h.do(ctx, art, func() (*http.Request, error) {
+		req, err := h.newRequest(method, art)
+		if err != nil {
+			return nil, err
+		}
+		if outputArtifact.ContentType != "" {
+			req.Header.Set("Content-Type", outputArtifact.ContentType)
+		}
+		counter = common.NewProgressCounter(info.Size(), progress)
+		openBody := func() (io.ReadCloser, error) {
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, err
+			}
+			return struct {
+				io.Reader
+				io.Closer
+			}{counter.Reader(f), f}, nil
+		}
+		// a request without a body is sent with a Content-Length of 0, so an empty file is not opened
+		req.ContentLength = info.Size()
+		if req.ContentLength > 0 {
+			req.Body, err = openBody()
+			if err != nil {
+				return nil, err
+			}
+			// redirects, e.g. 307s, resend the body
+			req.GetBody = openBody
+		}
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	counter.Done()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return errors.InternalErrorf("saving file to %s failed with reason:%s %s", common.RedactURL(art.URL), res.Status, strings.TrimSpace(string(body)))
+	}
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+	h.Log().WithFields(log.Fields{"url": common.RedactURL(art.URL), "bytes": info.Size()}).Debug("Uploaded to HTTP")
 	return nil
 }
 
-func (h *HTTPArtifactDriver) Save(string, *wfv1.Artifact) error {
-	return errors.Errorf(errors.CodeBadRequest, "HTTP output artifacts unsupported")
+// Delete is unsupported for HTTP artifacts
+func (h *HTTPArtifactDriver) Delete(*wfv1.Artifact) error {
+	return common.ErrDeletionNotSupported
+}
+
+// LimitsSize returns true: an artifact is not loaded if the Content-Length of its response is larger than its max
+// size, and its load fails once more than it is written otherwise
+func (h *HTTPArtifactDriver) LimitsSize() bool {
+	return true
+}
+
+// Capabilities returns the optional operations on HTTP artifacts the driver supports
+func (h *HTTPArtifactDriver) Capabilities() common.DriverCapabilities {
+	return common.DriverCapabilities{
+		Stream:    true,
+		LoadRange: true,
+		Exists:    true,
+		Progress:  true,
+		MaxSize:   true,
+	}
+}
+
+func (h *HTTPArtifactDriver) context() context.Context {
+	if h.Context == nil {
+		return context.Background()
+	}
+	return h.Context
+}
+
+func (h *HTTPArtifactDriver) client(art *wfv1.HTTPArtifact) *http.Client {
+	client := &http.Client{Timeout: h.Timeout, CheckRedirect: checkRedirect(art.Redirects)}
+	if h.RootCAs != nil || h.ProxyURL != nil {
+		client.Transport = common.NewTransport(h.RootCAs, h.ProxyURL)
+	}
+	client.Transport = h.RoundTripper(client.Transport)
+	return client
+}
+
+// redirectError is the error of a redirect which the policy of the artifact does not follow, which is not retried
+type redirectError struct {
+	err error
+}
+
+func (e redirectError) Error() string {
+	return e.err.Error()
+}
+
+// checkRedirect returns the CheckRedirect of a client which follows redirects by the policy. A redirect to another
+// host than that of the URL fails if the policy only follows those to the same host, and otherwise does not have the
+// Authorization header of the request, unless the policy forwards it.
+func checkRedirect(redirects *wfv1.HTTPRedirects) func(req *http.Request, via []*http.Request) error {
+	if redirects == nil {
+		redirects = &wfv1.HTTPRedirects{}
+	}
+	limit := defaultRedirectLimit
+	if redirects.Limit != nil {
+		limit = int(*redirects.Limit)
+	}
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > limit {
+			return redirectError{errors.Errorf(errors.CodeBadRequest, "%s was redirected more than %d times", common.RedactURL(via[0].URL.String()), limit)}
+		}
+		origin := via[0]
+		if req.URL.Host == origin.URL.Host {
+			return nil
+		}
+		if redirects.SameHostOnly {
+			return redirectError{errors.Errorf(errors.CodeForbidden, "%s was redirected to %s, which is of another host", common.RedactURL(origin.URL.String()), common.RedactURL(req.URL.String()))}
+		}
+		// the client itself only drops the header on redirects to other domains, but not to their subdomains
+		if auth := origin.Header.Get("Authorization"); auth != "" && redirects.ForwardAuthorization {
+			req.Header.Set("Authorization", auth)
+		} else {
+			req.Header.Del("Authorization")
+		}
+		return nil
+	}
+}
+
+func newBackoff(retry *wfv1.HTTPRetry) (wait.Backoff, error) {
+	backoff := wait.Backoff{Duration: defaultRetryBaseDelay, Factor: 2.0, Steps: defaultRetryLimit, Jitter: 0.1}
+	if retry == nil {
+		return backoff, nil
+	}
+	if retry.Limit != nil {
+		backoff.Steps = int(*retry.Limit)
+	}
+	if retry.BaseDelay != "" {
+		delay, err := time.ParseDuration(retry.BaseDelay)
+		if err != nil {
+			return backoff, errors.Errorf(errors.CodeBadRequest, "invalid retry base delay %q: %v", retry.BaseDelay, err)
+		}
+		backoff.Duration = delay
+	}
+	return backoff, nil
+}
+
+// do sends the request returned by newRequest, retrying network errors and 5xx responses with an
+// exponential backoff. 4xx responses are returned to the caller without being retried, and neither are redirects which
+// the policy of the artifact does not follow, nor requests the circuit breaker of their endpoint rejects.
+func (h *HTTPArtifactDriver) do(ctx context.Context, art *wfv1.HTTPArtifact, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	backoff, err := newBackoff(art.Retry)
+	if err != nil {
+		return nil, err
+	}
+	client := h.client(art)
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		res, err := client.Do(req.WithContext(ctx))
+		if err == nil && res.StatusCode < 500 {
+			return res, nil
+		}
+		var redirectErr redirectError
+		if stderrors.As(err, &redirectErr) {
+			return nil, redirectErr.err
+		}
+		if stderrors.Is(err, common.ErrCircuitOpen) {
+			return nil, err
+		}
+		if err == nil {
+			_, _ = io.Copy(ioutil.Discard, res.Body)
+			_ = res.Body.Close()
+			err = errors.InternalErrorf("%s %s failed with reason:%s", req.Method, common.RedactURL(req.URL.String()), res.Status)
+		}
+		if backoff.Steps < 1 || ctx.Err() != nil {
+			return nil, err
+		}
+		delay := backoff.Step()
+		h.Log().WithFields(log.Fields{"url": common.RedactURL(req.URL.String()), "attempt": attempt}).Warnf("%v, retrying in %v", err, delay)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
 }
@@ -2,15 +2,33 @@ package http
 
 import (
 	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/x509"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/andybalholm/brotli"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/argoproj/argo-workflows/v3/errors"
 	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
 )
 
 func captureOutput(f func()) string {
@@ -27,7 +45,7 @@ func TestHTTPArtifactDriver_Load(t *testing.T) {
 		URL: "https://github.com/argoproj/argo-workflows",
 	}
 	t.Run("Found", func(t *testing.T) {
-		err := driver.Load(&wfv1.Artifact{
+		err := driver.Load(context.Background(), &wfv1.Artifact{
 			ArtifactLocation: wfv1.ArtifactLocation{HTTP: a},
 		}, "/tmp/found")
 		if assert.NoError(t, err) {
@@ -36,23 +54,29 @@ func TestHTTPArtifactDriver_Load(t *testing.T) {
 		}
 	})
 	t.Run("FoundWithRequestHeaders", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Accept") != "application/json" || r.Header.Get("Authorization") != "Bearer foo-bar" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = fmt.Fprint(w, "my-data")
+		}))
+		defer server.Close()
 		h1 := wfv1.Header{Name: "Accept", Value: "application/json"}
 		h2 := wfv1.Header{Name: "Authorization", Value: "Bearer foo-bar"}
-		a.Headers = []wfv1.Header{h1, h2}
 		output := captureOutput(func() {
-			err := driver.Load(&wfv1.Artifact{
-				ArtifactLocation: wfv1.ArtifactLocation{HTTP: a},
+			err := driver.Load(context.Background(), &wfv1.Artifact{
+				ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL, Headers: []wfv1.Header{h1, h2}}},
 			}, "/tmp/found-with-request-headers")
 			if assert.NoError(t, err) {
 				_, err := os.Stat("/tmp/found-with-request-headers")
 				assert.NoError(t, err)
 			}
 		})
-		curl := "curl -fsS -L -o /tmp/found-with-request-headers https://github.com/argoproj/argo-workflows -H Accept: application/json -H Authorization: Bearer foo-bar"
-		assert.Regexp(t, regexp.MustCompile(curl), output)
+		assert.Regexp(t, regexp.MustCompile("HTTP Load path: /tmp/found-with-request-headers, url: "+server.URL), output)
 	})
 	t.Run("NotFound", func(t *testing.T) {
-		err := driver.Load(&wfv1.Artifact{
+		err := driver.Load(context.Background(), &wfv1.Artifact{
 			ArtifactLocation: wfv1.ArtifactLocation{
 				HTTP: &wfv1.HTTPArtifact{URL: "https://github.com/argoproj/argo-workflows/not-found"},
 			},
@@ -66,7 +90,890 @@ func TestHTTPArtifactDriver_Load(t *testing.T) {
 	})
 }
 
+func TestHTTPArtifactDriver_LoadRange(t *testing.T) {
+	data := []byte("0123456789")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ranged":
+			http.ServeContent(w, r, "ranged", time.Time{}, bytes.NewReader(data))
+		case "/unranged":
+			// the Range header is ignored
+			_, _ = w.Write(data)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "range")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	driver := &HTTPArtifactDriver{}
+	newArtifact := func(p string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL + p}}}
+	}
+	for _, p := range []string{"/ranged", "/unranged"} {
+		for _, r := range []struct{ start, length, end int64 }{{0, 4, 4}, {3, 5, 8}, {6, -1, 10}, {0, -1, 10}, {8, 100, 10}} {
+			path := filepath.Join(dir, "range")
+			if assert.NoError(t, driver.LoadRange(newArtifact(p), r.start, r.length, path), p) {
+				got, err := ioutil.ReadFile(path)
+				assert.NoError(t, err)
+				assert.Equal(t, string(data[r.start:r.end]), string(got), "%s %v", p, r)
+			}
+		}
+		err := driver.LoadRange(newArtifact(p), 20, 5, filepath.Join(dir, "after-end"))
+		argoError, ok := err.(errors.ArgoError)
+		if assert.True(t, ok, p) {
+			assert.Equal(t, errors.CodeBadRequest, argoError.Code())
+		}
+	}
+	err = driver.LoadRange(newArtifact("/not-found"), 0, 5, filepath.Join(dir, "not-found"))
+	assert.True(t, stderrors.Is(err, common.ErrArtifactNotFound))
+}
+
+func TestHTTPArtifactDriver_LoadDecompressed(t *testing.T) {
+	data := "my-data, which is compressed by the server"
+	encode := func(t *testing.T, encoding string) []byte {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		switch encoding {
+		case "gzip", "x-gzip":
+			w = gzip.NewWriter(&buf)
+		case "deflate":
+			w = zlib.NewWriter(&buf)
+		case "raw-deflate":
+			var err error
+			w, err = flate.NewWriter(&buf, flate.DefaultCompression)
+			assert.NoError(t, err)
+		case "br":
+			w = brotli.NewWriter(&buf)
+		default:
+			return []byte(data)
+		}
+		_, err := io.WriteString(w, data)
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+		return buf.Bytes()
+	}
+	var acceptEncoding string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Get("Accept-Encoding")
+		encoding := r.URL.Query().Get("encoding")
+		switch encoding {
+		case "raw-deflate":
+			w.Header().Set("Content-Encoding", "deflate")
+		case "":
+		default:
+			w.Header().Set("Content-Encoding", encoding)
+		}
+		_, _ = w.Write(encode(t, encoding))
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "decompress")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	driver := &HTTPArtifactDriver{}
+	load := func(t *testing.T, art *wfv1.HTTPArtifact) string {
+		path := filepath.Join(dir, "loaded")
+		if !assert.NoError(t, driver.Load(context.Background(), &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: art}}, path)) {
+			return ""
+		}
+		loaded, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		return string(loaded)
+	}
+
+	for _, encoding := range []string{"gzip", "x-gzip", "deflate", "raw-deflate", "br", "identity", ""} {
+		t.Run("Encoding="+encoding, func(t *testing.T) {
+			assert.Equal(t, data, load(t, &wfv1.HTTPArtifact{URL: server.URL + "?encoding=" + encoding}))
+			assert.Equal(t, "gzip, deflate, br", acceptEncoding)
+		})
+	}
+	t.Run("Disabled", func(t *testing.T) {
+		loaded := load(t, &wfv1.HTTPArtifact{URL: server.URL + "?encoding=gzip", DisableDecompression: true})
+		assert.Equal(t, string(encode(t, "gzip")), loaded)
+		assert.Equal(t, "identity", acceptEncoding)
+	})
+	t.Run("Unsupported", func(t *testing.T) {
+		// the response is written as it is
+		assert.Equal(t, data, load(t, &wfv1.HTTPArtifact{URL: server.URL + "?encoding=compress"}))
+	})
+	t.Run("AcceptEncodingHeader", func(t *testing.T) {
+		headers := []wfv1.Header{{Name: "Accept-Encoding", Value: "gzip"}}
+		assert.Equal(t, data, load(t, &wfv1.HTTPArtifact{URL: server.URL + "?encoding=gzip", Headers: headers}))
+		assert.Equal(t, "gzip", acceptEncoding)
+	})
+	t.Run("Corrupt", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "gzip")
+			_, _ = fmt.Fprint(w, "not gzip")
+		}))
+		defer server.Close()
+		err := driver.Load(context.Background(), &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL}}}, filepath.Join(dir, "corrupt"))
+		assert.Error(t, err)
+	})
+	t.Run("Empty", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "gzip")
+		}))
+		defer server.Close()
+		art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL}}}
+		assert.Empty(t, load(t, art.HTTP))
+		stream, err := driver.OpenStream(art)
+		if assert.NoError(t, err) {
+			defer stream.Close()
+			streamed, err := ioutil.ReadAll(stream)
+			assert.NoError(t, err)
+			assert.Empty(t, streamed)
+		}
+	})
+	t.Run("OpenStream", func(t *testing.T) {
+		stream, err := driver.OpenStream(&wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL + "?encoding=gzip"}}})
+		if assert.NoError(t, err) {
+			defer stream.Close()
+			streamed, err := ioutil.ReadAll(stream)
+			assert.NoError(t, err)
+			assert.Equal(t, data, string(streamed))
+		}
+	})
+	t.Run("Range", func(t *testing.T) {
+		path := filepath.Join(dir, "range")
+		if assert.NoError(t, driver.LoadRange(&wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL}}}, 0, 5, path)) {
+			loaded, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, data[:5], string(loaded))
+			assert.Empty(t, acceptEncoding)
+		}
+	})
+}
+
 func TestHTTPArtifactDriver_Save(t *testing.T) {
 	driver := &HTTPArtifactDriver{}
-	assert.Error(t, driver.Save("", nil))
+	assert.Error(t, driver.Save(context.Background(), "", nil))
+}
+
+// upload is a request a server received
+type upload struct {
+	method, contentType, authorization, custom string
+	contentLength                              int64
+	transferEncoding                           []string
+	body                                       []byte
+}
+
+func TestHTTPArtifactDriver_SaveUpload(t *testing.T) {
+	var uploads []upload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		uploads = append(uploads, upload{
+			method:           r.Method,
+			contentType:      r.Header.Get("Content-Type"),
+			authorization:    r.Header.Get("Authorization"),
+			custom:           r.Header.Get("X-My-Header"),
+			contentLength:    r.ContentLength,
+			transferEncoding: r.TransferEncoding,
+			body:             body,
+		})
+		switch r.URL.Path {
+		case "/unavailable":
+			if len(uploads) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		case "/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("SignatureDoesNotMatch"))
+		}
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "http")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "my-file")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("my-data"), 0600))
+	noRetry := int32(0)
+	newArtifact := func(url string, upload *wfv1.HTTPUpload, contentType string) *wfv1.Artifact {
+		return &wfv1.Artifact{
+			Name: "my-artifact",
+			ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{
+				URL:     url,
+				Headers: []wfv1.Header{{Name: "X-My-Header", Value: "my-value"}},
+				Retry:   &wfv1.HTTPRetry{Limit: &noRetry},
+				Upload:  upload,
+			}},
+			ContentType: contentType,
+		}
+	}
+	driver := &HTTPArtifactDriver{BearerToken: "my-token"}
+
+	t.Run("Unsupported", func(t *testing.T) {
+		uploads = nil
+		assert.Error(t, driver.Save(context.Background(), path, newArtifact(server.URL, nil, "")))
+		assert.Empty(t, uploads)
+	})
+	t.Run("Presigned", func(t *testing.T) {
+		uploads = nil
+		art := newArtifact(server.URL+"/my-bucket/my-file?X-Amz-Signature=my-signature", &wfv1.HTTPUpload{Presigned: true}, "application/x-custom")
+		if assert.NoError(t, driver.Save(context.Background(), path, art)) && assert.Len(t, uploads, 1) {
+			assert.Equal(t, upload{
+				method:        http.MethodPut,
+				contentType:   "application/x-custom",
+				custom:        "my-value",
+				contentLength: int64(len("my-data")),
+				body:          []byte("my-data"),
+			}, uploads[0])
+		}
+	})
+	t.Run("Post", func(t *testing.T) {
+		uploads = nil
+		art := newArtifact(server.URL, &wfv1.HTTPUpload{Method: http.MethodPost}, "")
+		if assert.NoError(t, driver.Save(context.Background(), path, art)) && assert.Len(t, uploads, 1) {
+			assert.Equal(t, http.MethodPost, uploads[0].method)
+			// the content type is only sent if it is set
+			assert.Empty(t, uploads[0].contentType)
+			assert.Equal(t, "Bearer my-token", uploads[0].authorization)
+			assert.Equal(t, "my-data", string(uploads[0].body))
+		}
+	})
+	t.Run("Empty", func(t *testing.T) {
+		uploads = nil
+		empty := filepath.Join(dir, "empty")
+		assert.NoError(t, ioutil.WriteFile(empty, nil, 0600))
+		if assert.NoError(t, driver.Save(context.Background(), empty, newArtifact(server.URL, &wfv1.HTTPUpload{}, ""))) && assert.Len(t, uploads, 1) {
+			assert.Equal(t, int64(0), uploads[0].contentLength)
+			assert.Empty(t, uploads[0].transferEncoding)
+		}
+	})
+	t.Run("Retry", func(t *testing.T) {
+		uploads = nil
+		retry := int32(1)
+		art := newArtifact(server.URL+"/unavailable", &wfv1.HTTPUpload{Presigned: true}, "")
+		art.HTTP.Retry = &wfv1.HTTPRetry{Limit: &retry, BaseDelay: "1ms"}
+		if assert.NoError(t, driver.Save(context.Background(), path, art)) && assert.Len(t, uploads, 2) {
+			// the body is sent again in full
+			assert.Equal(t, "my-data", string(uploads[1].body))
+		}
+	})
+	t.Run("Forbidden", func(t *testing.T) {
+		art := newArtifact(server.URL+"/forbidden?X-Amz-Signature=my-signature", &wfv1.HTTPUpload{Presigned: true}, "")
+		err := driver.Save(context.Background(), path, art)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "403 Forbidden SignatureDoesNotMatch")
+			assert.NotContains(t, err.Error(), "my-signature")
+		}
+	})
+	t.Run("Directory", func(t *testing.T) {
+		assert.Error(t, driver.Save(context.Background(), dir, newArtifact(server.URL, &wfv1.HTTPUpload{}, "")))
+	})
+}
+
+func TestHTTPArtifactDriver_SaveStreamed(t *testing.T) {
+	const size = 32 << 20
+	var transferred, transferredAtStart int64
+	var received int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the server has the start of the body, so the whole of the file has not been read if it is streamed
+		first := make([]byte, 1)
+		_, _ = io.ReadFull(r.Body, first)
+		atomic.StoreInt64(&transferredAtStart, atomic.LoadInt64(&transferred))
+		n, _ := io.Copy(ioutil.Discard, r.Body)
+		received = n + 1
+	}))
+	defer server.Close()
+	tmp, err := ioutil.TempFile("", "streamed")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	assert.NoError(t, tmp.Truncate(size))
+	assert.NoError(t, tmp.Close())
+	driver := &HTTPArtifactDriver{}
+	art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL, Upload: &wfv1.HTTPUpload{}}}}
+	err = driver.SaveWithProgress(context.Background(), tmp.Name(), art, func(bytesTransferred, totalBytes int64) {
+		atomic.StoreInt64(&transferred, bytesTransferred)
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(size), received)
+		assert.Equal(t, int64(size), atomic.LoadInt64(&transferred))
+		assert.Less(t, atomic.LoadInt64(&transferredAtStart), int64(size))
+	}
+}
+
+func TestHTTPArtifactDriver_Delete(t *testing.T) {
+	driver := &HTTPArtifactDriver{}
+	assert.Equal(t, common.ErrDeletionNotSupported, driver.Delete(nil))
+}
+
+func TestHTTPArtifactDriver_OpenStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/my-artifact" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = fmt.Fprint(w, "my-data")
+	}))
+	defer server.Close()
+	driver := &HTTPArtifactDriver{}
+	t.Run("Found", func(t *testing.T) {
+		art := &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL + "/my-artifact"}},
+		}
+		path := "/tmp/open-stream"
+		if !assert.NoError(t, driver.Load(context.Background(), art, path)) {
+			return
+		}
+		defer func() { _ = os.Remove(path) }()
+		loaded, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		stream, err := driver.OpenStream(art)
+		if assert.NoError(t, err) {
+			defer stream.Close()
+			streamed, err := ioutil.ReadAll(stream)
+			assert.NoError(t, err)
+			assert.Equal(t, loaded, streamed)
+		}
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := driver.OpenStream(&wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL + "/not-found"}},
+		})
+		if assert.Error(t, err) {
+			assert.True(t, errors.IsCode(errors.CodeNotFound, err))
+		}
+	})
+}
+
+func TestHTTPArtifactDriver_Retry(t *testing.T) {
+	limit := int32(2)
+	retry := &wfv1.HTTPRetry{Limit: &limit, BaseDelay: "1ms"}
+	for _, tt := range []struct {
+		name         string
+		statusCodes  []int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{"OK", []int{http.StatusOK}, 1, false},
+		{"NotFound", []int{http.StatusNotFound}, 1, true},
+		{"BadRequest", []int{http.StatusBadRequest}, 1, true},
+		{"ServiceUnavailableThenOK", []int{http.StatusServiceUnavailable, http.StatusOK}, 2, false},
+		{"InternalServerError", []int{http.StatusInternalServerError}, 3, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := attempts
+				if i >= len(tt.statusCodes) {
+					i = len(tt.statusCodes) - 1
+				}
+				attempts++
+				w.WriteHeader(tt.statusCodes[i])
+			}))
+			defer server.Close()
+			driver := &HTTPArtifactDriver{}
+			err := driver.Load(context.Background(), &wfv1.Artifact{
+				ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL, Retry: retry}},
+			}, "/tmp/retry")
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantAttempts, attempts)
+		})
+	}
+	t.Run("NetworkError", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		url := server.URL
+		server.Close()
+		driver := &HTTPArtifactDriver{}
+		err := driver.Load(context.Background(), &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: url, Retry: retry}},
+		}, "/tmp/retry")
+		assert.Error(t, err)
+	})
+	t.Run("ContextCancelled", func(t *testing.T) {
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		driver := &HTTPArtifactDriver{}
+		err := driver.Load(ctx, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL, Retry: retry}},
+		}, "/tmp/retry")
+		assert.Error(t, err)
+		assert.Equal(t, 0, attempts)
+	})
+}
+
+// droppingWriter writes no more than limit bytes of a response, and then drops the connection
+type droppingWriter struct {
+	http.ResponseWriter
+	limit int
+}
+
+func (w *droppingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		_, _ = w.ResponseWriter.Write(p[:w.limit])
+		w.ResponseWriter.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+	w.limit -= len(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func TestHTTPArtifactDriver_LoadResumed(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	changed := bytes.Repeat([]byte("9876543210"), 1000)
+	var ranges, ifRanges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempt := len(ranges)
+		ranges = append(ranges, r.Header.Get("Range"))
+		ifRanges = append(ifRanges, r.Header.Get("If-Range"))
+		if attempt < 2 {
+			// the connection is dropped after 3000 bytes of each of the first two responses
+			w = &droppingWriter{ResponseWriter: w, limit: 3000}
+		}
+		switch r.URL.Path {
+		case "/ranged":
+			w.Header().Set("ETag", `"my-etag"`)
+			http.ServeContent(w, r, "ranged", time.Time{}, bytes.NewReader(data))
+		case "/last-modified":
+			http.ServeContent(w, r, "last-modified", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), bytes.NewReader(data))
+		case "/changed":
+			// the resource changes after the first response, so the If-Range of the resumption does not match
+			if attempt == 0 {
+				w.Header().Set("ETag", `"my-etag"`)
+				http.ServeContent(w, r, "changed", time.Time{}, bytes.NewReader(data))
+			} else {
+				w.Header().Set("ETag", `"my-changed-etag"`)
+				http.ServeContent(w, r, "changed", time.Time{}, bytes.NewReader(changed))
+			}
+		case "/unranged":
+			// the Range header is ignored
+			w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+			_, _ = w.Write(data)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "resumed")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	limit := int32(2)
+	driver := &HTTPArtifactDriver{}
+	load := func(p string, retry *wfv1.HTTPRetry) ([]byte, error) {
+		ranges, ifRanges = nil, nil
+		path := filepath.Join(dir, "resumed")
+		err := driver.Load(context.Background(), &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL + p, Retry: retry}},
+		}, path)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadFile(path)
+	}
+	retry := &wfv1.HTTPRetry{Limit: &limit, BaseDelay: "1ms"}
+	t.Run("ETag", func(t *testing.T) {
+		got, err := load("/ranged", retry)
+		if assert.NoError(t, err) {
+			assert.Equal(t, data, got)
+		}
+		assert.Equal(t, []string{"", "bytes=3000-", "bytes=6000-"}, ranges)
+		assert.Equal(t, []string{"", `"my-etag"`, `"my-etag"`}, ifRanges)
+	})
+	t.Run("LastModified", func(t *testing.T) {
+		got, err := load("/last-modified", retry)
+		if assert.NoError(t, err) {
+			assert.Equal(t, data, got)
+		}
+		assert.Equal(t, []string{"", "bytes=3000-", "bytes=6000-"}, ranges)
+		assert.Equal(t, "Fri, 01 Jan 2021 00:00:00 GMT", ifRanges[1])
+	})
+	t.Run("Changed", func(t *testing.T) {
+		// the body of the response is of the changed resource, and is then resumed with its ETag
+		got, err := load("/changed", retry)
+		if assert.NoError(t, err) {
+			assert.Equal(t, changed, got)
+		}
+		assert.Equal(t, []string{"", "bytes=3000-", "bytes=3000-"}, ranges)
+		assert.Equal(t, []string{"", `"my-etag"`, `"my-changed-etag"`}, ifRanges)
+	})
+	t.Run("Unranged", func(t *testing.T) {
+		got, err := load("/unranged", retry)
+		if assert.NoError(t, err) {
+			assert.Equal(t, data, got)
+		}
+		assert.Equal(t, []string{"", "", ""}, ranges)
+	})
+	t.Run("RetryLimit", func(t *testing.T) {
+		limit := int32(1)
+		_, err := load("/ranged", &wfv1.HTTPRetry{Limit: &limit, BaseDelay: "1ms"})
+		assert.Error(t, err)
+		assert.Equal(t, []string{"", "bytes=3000-"}, ranges)
+	})
+}
+
+func TestHTTPArtifactDriver_LoadInterrupted(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the connection is dropped after 3000 bytes of every response
+		http.ServeContent(&droppingWriter{ResponseWriter: w, limit: 3000}, r, "interrupted", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "interrupted")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	limit := int32(0)
+	driver := &HTTPArtifactDriver{}
+	art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL, Retry: &wfv1.HTTPRetry{Limit: &limit}}}}
+
+	t.Run("Absent", func(t *testing.T) {
+		path := filepath.Join(dir, "absent")
+		assert.Error(t, driver.Load(context.Background(), art, path))
+		_, err := os.Stat(path)
+		assert.True(t, os.IsNotExist(err))
+	})
+	t.Run("Prior", func(t *testing.T) {
+		path := filepath.Join(dir, "prior")
+		assert.NoError(t, ioutil.WriteFile(path, []byte("prior"), 0600))
+		assert.Error(t, driver.Load(context.Background(), art, path))
+		got, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "prior", string(got))
+	})
+	// nothing partially written is left next to the path either
+	infos, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	if assert.Len(t, infos, 1) {
+		assert.Equal(t, "prior", infos[0].Name())
+	}
+}
+
+func TestHTTPArtifactDriver_Exists(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodHead, r.Method)
+		switch r.URL.Path {
+		case "/present":
+			w.WriteHeader(http.StatusOK)
+		case "/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	driver := &HTTPArtifactDriver{}
+	newArtifact := func(path string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL + path}}}
+	}
+	t.Run("Present", func(t *testing.T) {
+		ok, err := driver.Exists(newArtifact("/present"))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+	t.Run("Absent", func(t *testing.T) {
+		ok, err := driver.Exists(newArtifact("/not-found"))
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+	t.Run("Error", func(t *testing.T) {
+		_, err := driver.Exists(newArtifact("/forbidden"))
+		assert.Error(t, err)
+	})
+}
+
+func TestHTTPArtifactDriver_LoadWithProgress(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 1<<20)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+	tmp, err := ioutil.TempFile("", "progress")
+	if !assert.NoError(t, err) {
+		return
+	}
+	_ = tmp.Close()
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	var transferred []int64
+	driver := &HTTPArtifactDriver{}
+	err = driver.LoadWithProgress(context.Background(), &wfv1.Artifact{
+		ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL}},
+	}, tmp.Name(), func(bytesTransferred, totalBytes int64) {
+		assert.Equal(t, int64(len(data)), totalBytes)
+		transferred = append(transferred, bytesTransferred)
+	})
+	if assert.NoError(t, err) && assert.True(t, len(transferred) > 1) {
+		for i := 1; i < len(transferred); i++ {
+			assert.Greater(t, transferred[i], transferred[i-1])
+		}
+		assert.Equal(t, int64(len(data)), transferred[len(transferred)-1])
+		// reported at intervals, not on every read
+		assert.LessOrEqual(t, len(transferred), 11)
+	}
+}
+
+func TestHTTPArtifactDriver_RootCAs(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("my-data"))
+	}))
+	defer server.Close()
+	noRetry := int32(0)
+	art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL, Retry: &wfv1.HTTPRetry{Limit: &noRetry}}}}
+	path, err := ioutil.TempDir("", "http")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(path) }()
+	t.Run("Trusted", func(t *testing.T) {
+		pool := x509.NewCertPool()
+		pool.AddCert(server.Certificate())
+		driver := &HTTPArtifactDriver{RootCAs: pool}
+		if assert.NoError(t, driver.Load(context.Background(), art, path+"/trusted")) {
+			data, err := ioutil.ReadFile(path + "/trusted")
+			assert.NoError(t, err)
+			assert.Equal(t, "my-data", string(data))
+		}
+	})
+	t.Run("Untrusted", func(t *testing.T) {
+		driver := &HTTPArtifactDriver{}
+		err := driver.Load(context.Background(), art, path+"/untrusted")
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "certificate")
+		}
+	})
+}
+
+func TestHTTPArtifactDriver_ProxyURL(t *testing.T) {
+	var hosts []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.URL.Host)
+		_, _ = w.Write([]byte("my-data"))
+	}))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	assert.NoError(t, err)
+	path, err := ioutil.TempDir("", "http")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(path) }()
+	driver := &HTTPArtifactDriver{ProxyURL: proxyURL}
+	art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: "http://example.com/my-file"}}}
+	if assert.NoError(t, driver.Load(context.Background(), art, path+"/proxied")) {
+		data, err := ioutil.ReadFile(path + "/proxied")
+		assert.NoError(t, err)
+		assert.Equal(t, "my-data", string(data))
+		assert.Equal(t, []string{"example.com"}, hosts)
+	}
+}
+
+func TestHTTPArtifactDriver_Auth(t *testing.T) {
+	var authorization, custom string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		custom = r.Header.Get("X-My-Header")
+		if authorization == "" || r.URL.Path == "/unauthorized" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("my-data"))
+	}))
+	defer server.Close()
+	path, err := ioutil.TempDir("", "http")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(path) }()
+	art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{
+		URL:     server.URL,
+		Headers: []wfv1.Header{{Name: "X-My-Header", Value: "my-value"}, {Name: "Authorization", Value: "Bearer from-header"}},
+	}}}
+	t.Run("BearerToken", func(t *testing.T) {
+		driver := &HTTPArtifactDriver{BearerToken: "my-token"}
+		assert.NoError(t, driver.Load(context.Background(), art, path+"/bearer"))
+		assert.Equal(t, "Bearer my-token", authorization)
+		assert.Equal(t, "my-value", custom)
+		ok, err := driver.Exists(art)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "Bearer my-token", authorization)
+	})
+	t.Run("BasicAuth", func(t *testing.T) {
+		driver := &HTTPArtifactDriver{Username: "my-username", Password: "my-password"}
+		stream, err := driver.OpenStream(art)
+		if assert.NoError(t, err) {
+			assert.NoError(t, stream.Close())
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			assert.NoError(t, err)
+			req.SetBasicAuth("my-username", "my-password")
+			assert.Equal(t, req.Header.Get("Authorization"), authorization)
+			assert.Equal(t, "my-value", custom)
+		}
+	})
+	t.Run("Headers", func(t *testing.T) {
+		driver := &HTTPArtifactDriver{}
+		assert.NoError(t, driver.Load(context.Background(), art, path+"/headers"))
+		assert.Equal(t, "Bearer from-header", authorization)
+		assert.Equal(t, "my-value", custom)
+	})
+	t.Run("Redacted", func(t *testing.T) {
+		u, err := url.Parse(server.URL)
+		assert.NoError(t, err)
+		u.User = url.UserPassword("my-username", "my-url-password")
+		noRetry := int32(0)
+		driver := &HTTPArtifactDriver{BearerToken: "my-token"}
+		var loadErr error
+		output := captureOutput(func() {
+			loadErr = driver.Load(context.Background(), &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{
+				URL:   u.String() + "/unauthorized",
+				Retry: &wfv1.HTTPRetry{Limit: &noRetry},
+			}}}, path+"/redacted")
+		})
+		if assert.Error(t, loadErr) {
+			assert.NotContains(t, loadErr.Error(), "my-url-password")
+			assert.NotContains(t, loadErr.Error(), "my-token")
+		}
+		assert.NotContains(t, output, "my-url-password")
+		assert.NotContains(t, output, "my-token")
+		assert.Contains(t, output, "xxxxx")
+	})
+}
+
+func TestHTTPArtifactDriver_Redirects(t *testing.T) {
+	// the other server is of another host, as it listens on another port
+	var otherAuthorization string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		otherAuthorization = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte("other-data"))
+	}))
+	defer other.Close()
+	var lock sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		requests = append(requests, r.URL.Path+" "+r.Header.Get("Authorization"))
+		lock.Unlock()
+		switch r.URL.Path {
+		case "/start":
+			http.Redirect(w, r, "/hop", http.StatusFound)
+		case "/hop":
+			http.Redirect(w, r, other.URL+"/file", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			_, _ = w.Write([]byte("my-data"))
+		}
+	}))
+	defer server.Close()
+	path, err := ioutil.TempDir("", "http")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(path) }()
+	newArtifact := func(p string, redirects *wfv1.HTTPRedirects) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL + p, Redirects: redirects}}}
+	}
+	load := func(art *wfv1.Artifact, name string) error {
+		lock.Lock()
+		requests = nil
+		lock.Unlock()
+		otherAuthorization = ""
+		driver := &HTTPArtifactDriver{BearerToken: "my-token"}
+		return driver.Load(context.Background(), art, filepath.Join(path, name))
+	}
+	limit := func(n int32) *int32 { return &n }
+
+	t.Run("CrossHost", func(t *testing.T) {
+		assert.NoError(t, load(newArtifact("/start", nil), "cross-host"))
+		data, err := ioutil.ReadFile(filepath.Join(path, "cross-host"))
+		assert.NoError(t, err)
+		assert.Equal(t, "other-data", string(data))
+		// the credentials are sent on the redirect to the same host, but not on that to the other one
+		assert.Equal(t, []string{"/start Bearer my-token", "/hop Bearer my-token"}, requests)
+		assert.Empty(t, otherAuthorization)
+	})
+	t.Run("ForwardAuthorization", func(t *testing.T) {
+		assert.NoError(t, load(newArtifact("/start", &wfv1.HTTPRedirects{ForwardAuthorization: true}), "forward"))
+		assert.Equal(t, "Bearer my-token", otherAuthorization)
+	})
+	t.Run("SameHostOnly", func(t *testing.T) {
+		err := load(newArtifact("/start", &wfv1.HTTPRedirects{SameHostOnly: true}), "same-host")
+		assert.True(t, errors.IsCode(errors.CodeForbidden, err), err)
+		// the redirect is not retried, nor followed
+		assert.Equal(t, []string{"/start Bearer my-token", "/hop Bearer my-token"}, requests)
+		assert.Empty(t, otherAuthorization)
+		assert.NoError(t, load(newArtifact("/same-host", &wfv1.HTTPRedirects{SameHostOnly: true}), "same-host"))
+	})
+	t.Run("Limit", func(t *testing.T) {
+		err := load(newArtifact("/start", &wfv1.HTTPRedirects{Limit: limit(1)}), "limit")
+		assert.True(t, errors.IsCode(errors.CodeBadRequest, err), err)
+		assert.Len(t, requests, 2)
+		assert.NoError(t, load(newArtifact("/start", &wfv1.HTTPRedirects{Limit: limit(2)}), "limit"))
+		// redirects are not followed if the limit is 0
+		err = load(newArtifact("/start", &wfv1.HTTPRedirects{Limit: limit(0)}), "no-redirects")
+		assert.True(t, errors.IsCode(errors.CodeBadRequest, err), err)
+		assert.Len(t, requests, 1)
+	})
+	t.Run("DefaultLimit", func(t *testing.T) {
+		err := load(newArtifact("/loop", nil), "loop")
+		assert.EqualError(t, err, server.URL+"/loop was redirected more than 10 times")
+		assert.Len(t, requests, 11)
+	})
+}
+
+func TestHTTPArtifactDriver_MaxSize(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/streamed" {
+			// a body which is flushed in chunks has no Content-Length
+			for i := 0; i < len(data); i += 1000 {
+				_, _ = w.Write(data[i : i+1000])
+				w.(http.Flusher).Flush()
+			}
+			return
+		}
+		http.ServeContent(w, r, "known", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "max-size")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	driver := &HTTPArtifactDriver{}
+	newArtifact := func(path, maxSize string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: server.URL + path}}, MaxSize: maxSize}
+	}
+
+	t.Run("ContentLength", func(t *testing.T) {
+		path := filepath.Join(dir, "known")
+		err := driver.Load(context.Background(), newArtifact("/known", "5000"), path)
+		assert.True(t, stderrors.Is(err, common.ErrArtifactTooLarge))
+		// the size is checked before the body is transferred
+		assert.Contains(t, err.Error(), "is 10000 bytes")
+		assert.NoFileExists(t, path)
+	})
+	t.Run("Streamed", func(t *testing.T) {
+		path := filepath.Join(dir, "streamed")
+		err := driver.Load(context.Background(), newArtifact("/streamed", "5000"), path)
+		assert.True(t, stderrors.Is(err, common.ErrArtifactTooLarge))
+		// the load is aborted once more than the max size is transferred
+		assert.Contains(t, err.Error(), "more than 5000 bytes of it were transferred")
+		assert.NoFileExists(t, path)
+	})
+	t.Run("AtLimit", func(t *testing.T) {
+		for _, p := range []string{"/known", "/streamed"} {
+			path := filepath.Join(dir, "at-limit"+filepath.Base(p))
+			if assert.NoError(t, driver.Load(context.Background(), newArtifact(p, "10000"), path), p) {
+				got, err := ioutil.ReadFile(path)
+				assert.NoError(t, err)
+				assert.Equal(t, data, got)
+			}
+		}
+	})
 }
@@ -0,0 +1,596 @@
+package huggingface
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const (
+	defaultEndpoint = "https://huggingface.co"
+	defaultRevision = "main"
+	// the most files a preupload or an LFS batch request is of
+	batchSize = 256
+	// the size of the sample of a file the Hub decides whether it is stored with LFS by, along with its size
+	sampleSize = 512
+	// the media type of the requests and responses of the LFS batch API
+	lfsMediaType = "application/vnd.git-lfs+json"
+	// the header the Hub returns the code of an error in, e.g. EntryNotFound
+	errorCodeHeader = "X-Error-Code"
+)
+
+// notFoundCodes are the codes of the errors of paths, revisions and repositories which do not exist. The Hub responds
+// to requests for private repositories which are not authorized as if they did not exist.
+var notFoundCodes = map[string]bool{"RepoNotFound": true, "RevisionNotFound": true, "EntryNotFound": true}
+
+// nextLinkRegex matches the URL of the next page of a paginated response in its Link header
+var nextLinkRegex = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// apiError is a response of the Hub with an error status
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *apiError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%s: %s (%d)", e.Code, e.Message, e.Status)
+	}
+	return fmt.Sprintf("%s (%d)", e.Message, e.Status)
+}
+
+// client is a client of a repository of the Hub at a revision
+type client struct {
+	ctx        context.Context
+	httpClient *http.Client
+	endpoint   *url.URL
+	token      string
+	repoType   wfv1.HuggingFaceRepoType
+	repoID     string
+	revision   string
+}
+
+func newClient(ctx context.Context, transport http.RoundTripper, endpoint *url.URL, token string, art *wfv1.HuggingFaceArtifact) *client {
+	c := &client{ctx: ctx, endpoint: endpoint, token: token, repoType: art.RepoType, repoID: art.RepoID, revision: art.Revision}
+	if c.repoType == "" {
+		c.repoType = wfv1.HuggingFaceRepoTypeModel
+	}
+	if c.revision == "" {
+		c.revision = defaultRevision
+	}
+	c.httpClient = &http.Client{
+		Transport: transport,
+		// files stored with LFS are redirected to presigned URLs of the LFS storage, which the token is not sent to
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return stderrors.New("stopped after 10 redirects")
+			}
+			if req.URL.Host != endpoint.Host {
+				req.Header.Del("Authorization")
+			}
+			return nil
+		},
+	}
+	return c
+}
+
+// repoPath returns the path of the repository in the URLs of its files, which are those of models, or of datasets and
+// spaces under their type
+func (c *client) repoPath() string {
+	switch c.repoType {
+	case wfv1.HuggingFaceRepoTypeDataset:
+		return "datasets/" + c.repoID
+	case wfv1.HuggingFaceRepoTypeSpace:
+		return "spaces/" + c.repoID
+	}
+	return c.repoID
+}
+
+// apiURL returns the URL of an endpoint of the API of the repository at the revision, e.g. its tree
+func (c *client) apiURL(endpoint, p string) string {
+	u := fmt.Sprintf("%s/api/%ss/%s/%s/%s", c.endpoint, c.repoType, c.repoID, endpoint, url.PathEscape(c.revision))
+	if p != "" {
+		u += "/" + escapePath(p)
+	}
+	return u
+}
+
+// fileURL returns the URL of the content of a file at the revision
+func (c *client) fileURL(p string) string {
+	return fmt.Sprintf("%s/%s/resolve/%s/%s", c.endpoint, c.repoPath(), url.PathEscape(c.revision), escapePath(p))
+}
+
+// escapePath escapes each component of a path
+func escapePath(p string) string {
+	components := strings.Split(p, "/")
+	for i, component := range components {
+		components[i] = url.PathEscape(component)
+	}
+	return strings.Join(components, "/")
+}
+
+func (c *client) newRequest(method, u string, body io.Reader, contentType string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(c.ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req, nil
+}
+
+// send sends the request, which is authorized with the token if it is to the Hub, rather than to the LFS storage, and
+// returns the response, or the error the Hub responded with
+func (c *client) send(req *http.Request) (*http.Response, error) {
+	if c.token != "" && req.URL.Host == c.endpoint.Host {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer func() { _ = resp.Body.Close() }()
+		return nil, decodeError(resp)
+	}
+	return resp, nil
+}
+
+// do sends the request, and decodes the JSON body of the response into res, if it is not nil
+func (c *client) do(req *http.Request, res interface{}) error {
+	resp, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if res == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
+
+// postJSON posts the request as JSON, and decodes the JSON response into res
+func (c *client) postJSON(u, contentType string, request, res interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := c.newRequest(http.MethodPost, u, bytes.NewReader(body), contentType)
+	if err != nil {
+		return err
+	}
+	if contentType == lfsMediaType {
+		req.Header.Set("Accept", lfsMediaType)
+	}
+	return c.do(req, res)
+}
+
+// decodeError returns the error of a response, which has a JSON body with the message of the error, and its code in
+// a header, for the errors of the API
+func decodeError(resp *http.Response) error {
+	e := &apiError{Status: resp.StatusCode, Code: resp.Header.Get(errorCodeHeader)}
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var body struct {
+		Error string `json:"error"`
+	}
+	switch {
+	case json.Unmarshal(data, &body) == nil && body.Error != "":
+		e.Message = body.Error
+	case len(bytes.TrimSpace(data)) > 0:
+		e.Message = string(bytes.TrimSpace(data))
+	default:
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+	if e.Status == http.StatusNotFound || notFoundCodes[e.Code] {
+		return common.NewNotFoundError(e)
+	}
+	return e
+}
+
+// entry is a file or directory of the repository
+type entry struct {
+	Type string `json:"type"`
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// OID is the Git object ID of the entry, which for a file not stored with LFS is the SHA-1 checksum of its blob
+	OID string `json:"oid"`
+	// LFS is set for a file stored with LFS, whose object ID is its SHA-256 checksum
+	LFS *struct {
+		OID  string `json:"oid"`
+		Size int64  `json:"size"`
+	} `json:"lfs,omitempty"`
+}
+
+// typeDirectory is the type of the entries of directories, rather than files
+const typeDirectory = "directory"
+
+// getEntry returns the file or directory at the path, which cannot be the root of the repository
+func (c *client) getEntry(p string) (entry, error) {
+	form := url.Values{"paths": {p}}
+	req, err := c.newRequest(http.MethodPost, c.apiURL("paths-info", ""), strings.NewReader(form.Encode()), "application/x-www-form-urlencoded")
+	if err != nil {
+		return entry{}, err
+	}
+	var entries []entry
+	if err := c.do(req, &entries); err != nil {
+		return entry{}, fmt.Errorf("Hugging Face info of %s failed: %w", p, err)
+	}
+	for _, e := range entries {
+		if e.Path == p {
+			return e, nil
+		}
+	}
+	return entry{}, common.NewNotFoundError(fmt.Errorf("Hugging Face repository %s has no %s at %s", c.repoID, p, c.revision))
+}
+
+// listTree lists every file and directory under the directory, or the root of the repository if it is empty,
+// recursively, following the pages of the tree
+func (c *client) listTree(dir string) ([]entry, error) {
+	var entries []entry
+	u := c.apiURL("tree", dir) + "?recursive=true"
+	for u != "" {
+		req, err := c.newRequest(http.MethodGet, u, nil, "")
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.send(req)
+		if err != nil {
+			return nil, fmt.Errorf("Hugging Face list of %s failed: %w", dir, err)
+		}
+		var page []entry
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Hugging Face list of %s failed: %w", dir, err)
+		}
+		entries = append(entries, page...)
+		u = ""
+		if m := nextLinkRegex.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+			u = m[1]
+		}
+	}
+	return entries, nil
+}
+
+// download downloads a file, and verifies its checksum: the SHA-256 checksum of a file stored with LFS, or otherwise
+// the SHA-1 checksum of its Git blob
+func (c *client) download(e entry, localPath string) error {
+	req, err := c.newRequest(http.MethodGet, c.fileURL(e.Path), nil, "")
+	if err != nil {
+		return err
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		return fmt.Errorf("Hugging Face download of %s failed: %w", e.Path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	out, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = out.Close() }()
+	var h hash.Hash
+	oid := e.OID
+	if e.LFS != nil {
+		h = sha256.New()
+		oid = e.LFS.OID
+	} else {
+		h = newBlobHash(e.Size)
+	}
+	if _, err := io.Copy(io.MultiWriter(out, h), resp.Body); err != nil {
+		return fmt.Errorf("Hugging Face download of %s failed: %w", e.Path, err)
+	}
+	if oid != "" && !strings.EqualFold(oid, hex.EncodeToString(h.Sum(nil))) {
+		return fmt.Errorf("Hugging Face download of %s failed: checksum mismatch", e.Path)
+	}
+	return out.Close()
+}
+
+// newBlobHash returns a hash of the Git blob of a file of the size, whose content is then written to it
+func newBlobHash(size int64) hash.Hash {
+	h := sha1.New()
+	_, _ = fmt.Fprintf(h, "blob %d\x00", size)
+	return h
+}
+
+// upload is a file committed to the repository
+type upload struct {
+	localPath string
+	path      string
+	size      int64
+	sample    []byte
+	// lfs is whether the Hub stores the file with LFS, and oid, if so, is its SHA-256 checksum
+	lfs bool
+	oid string
+}
+
+// preupload asks the Hub which of the files it stores with LFS, which it decides by their paths, sizes and samples
+func (c *client) preupload(uploads []*upload) error {
+	type file struct {
+		Path   string `json:"path"`
+		Sample string `json:"sample"`
+		Size   int64  `json:"size"`
+	}
+	for start := 0; start < len(uploads); start += batchSize {
+		batch := uploads[start:min(start+batchSize, len(uploads))]
+		files := make([]file, len(batch))
+		for i, u := range batch {
+			files[i] = file{Path: u.path, Sample: base64.StdEncoding.EncodeToString(u.sample), Size: u.size}
+		}
+		var res struct {
+			Files []struct {
+				Path       string `json:"path"`
+				UploadMode string `json:"uploadMode"`
+			} `json:"files"`
+		}
+		if err := c.postJSON(c.apiURL("preupload", ""), "application/json", map[string]interface{}{"files": files}, &res); err != nil {
+			return fmt.Errorf("Hugging Face preupload failed: %w", err)
+		}
+		modes := make(map[string]string, len(res.Files))
+		for _, f := range res.Files {
+			modes[f.Path] = f.UploadMode
+		}
+		for _, u := range batch {
+			u.lfs = modes[u.path] == "lfs"
+		}
+	}
+	return nil
+}
+
+// fileSHA256 returns the hex SHA-256 checksum of a file, which is its LFS object ID
+func fileSHA256(localPath string) (string, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// lfsAction is an action of an LFS object: a request to make, with its headers
+type lfsAction struct {
+	Href   string            `json:"href"`
+	Header map[string]string `json:"header"`
+}
+
+// lfsObject is an object of a request or response of the LFS batch API
+type lfsObject struct {
+	OID     string `json:"oid"`
+	Size    int64  `json:"size"`
+	Actions *struct {
+		Upload *lfsAction `json:"upload"`
+		Verify *lfsAction `json:"verify"`
+	} `json:"actions,omitempty"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// uploadLFS uploads the files the Hub stores with LFS to its LFS storage, unless it has them already, and verifies
+// them
+func (c *client) uploadLFS(uploads []*upload) error {
+	var objects []*upload
+	for _, u := range uploads {
+		if !u.lfs {
+			continue
+		}
+		oid, err := fileSHA256(u.localPath)
+		if err != nil {
+			return err
+		}
+		u.oid = oid
+		objects = append(objects, u)
+	}
+	for start := 0; start < len(objects); start += batchSize {
+		batch := objects[start:min(start+batchSize, len(objects))]
+		byOID := make(map[string]*upload, len(batch))
+		request := make([]lfsObject, len(batch))
+		for i, u := range batch {
+			request[i] = lfsObject{OID: u.oid, Size: u.size}
+			byOID[u.oid] = u
+		}
+		var res struct {
+			Transfer string      `json:"transfer"`
+			Objects  []lfsObject `json:"objects"`
+		}
+		u := fmt.Sprintf("%s/%s.git/info/lfs/objects/batch", c.endpoint, c.repoPath())
+		if err := c.postJSON(u, lfsMediaType, map[string]interface{}{
+			"operation": "upload",
+			"transfers": []string{"basic", "multipart"},
+			"objects":   request,
+			"hash_algo": "sha256",
+			"ref":       map[string]string{"name": c.revision},
+		}, &res); err != nil {
+			return fmt.Errorf("Hugging Face LFS batch failed: %w", err)
+		}
+		for _, object := range res.Objects {
+			u, ok := byOID[object.OID]
+			if !ok {
+				continue
+			}
+			if object.Error != nil {
+				return fmt.Errorf("Hugging Face LFS upload of %s failed: %s (%d)", u.path, object.Error.Message, object.Error.Code)
+			}
+			// the LFS storage has the objects without actions already
+			if object.Actions == nil || object.Actions.Upload == nil {
+				continue
+			}
+			if err := c.uploadObject(u, res.Transfer, object.Actions.Upload); err != nil {
+				return fmt.Errorf("Hugging Face LFS upload of %s failed: %w", u.path, err)
+			}
+			if verify := object.Actions.Verify; verify != nil {
+				if err := c.verifyObject(u, verify); err != nil {
+					return fmt.Errorf("Hugging Face LFS verify of %s failed: %w", u.path, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// uploadObject uploads a file to the LFS storage in a single request, or in parts, if the transfer is multipart and
+// the action has the size of the parts
+func (c *client) uploadObject(u *upload, transfer string, action *lfsAction) error {
+	f, err := os.Open(u.localPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	if chunkSize, ok := action.Header["chunk_size"]; ok && transfer == "multipart" {
+		return c.uploadParts(u, f, chunkSize, action)
+	}
+	req, err := c.newRequest(http.MethodPut, action.Href, io.NewSectionReader(f, 0, u.size), "")
+	if err != nil {
+		return err
+	}
+	req.ContentLength = u.size
+	for key, value := range action.Header {
+		req.Header.Set(key, value)
+	}
+	return c.do(req, nil)
+}
+
+// uploadParts uploads a file in parts of the chunk size, to the URLs of the parts, which are the headers of the
+// action named by their numbers, and then completes the upload with the ETags of the parts
+func (c *client) uploadParts(u *upload, f *os.File, chunkSize string, action *lfsAction) error {
+	size, err := strconv.ParseInt(chunkSize, 10, 64)
+	if err != nil || size < 1 {
+		return fmt.Errorf("invalid chunk size %q", chunkSize)
+	}
+	// the keys of the URLs may be zero padded, e.g. 00001
+	urls := map[int]string{}
+	var numbers []int
+	for key, value := range action.Header {
+		if n, err := strconv.Atoi(key); err == nil && n > 0 {
+			urls[n] = value
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+	if want := int((u.size + size - 1) / size); len(numbers) != want {
+		return fmt.Errorf("%d part URLs for %d parts", len(numbers), want)
+	}
+	type part struct {
+		PartNumber int    `json:"partNumber"`
+		ETag       string `json:"etag"`
+	}
+	parts := make([]part, len(numbers))
+	for i, n := range numbers {
+		offset := int64(i) * size
+		length := size
+		if offset+length > u.size {
+			length = u.size - offset
+		}
+		req, err := c.newRequest(http.MethodPut, urls[n], io.NewSectionReader(f, offset, length), "")
+		if err != nil {
+			return err
+		}
+		req.ContentLength = length
+		resp, err := c.send(req)
+		if err != nil {
+			return fmt.Errorf("part %d: %w", n, err)
+		}
+		_ = resp.Body.Close()
+		parts[i] = part{PartNumber: n, ETag: resp.Header.Get("ETag")}
+	}
+	return c.postJSON(action.Href, lfsMediaType, map[string]interface{}{"oid": u.oid, "parts": parts}, nil)
+}
+
+// verifyObject asks the Hub to verify that the LFS storage has the file
+func (c *client) verifyObject(u *upload, action *lfsAction) error {
+	body, err := json.Marshal(lfsObject{OID: u.oid, Size: u.size})
+	if err != nil {
+		return err
+	}
+	req, err := c.newRequest(http.MethodPost, action.Href, bytes.NewReader(body), lfsMediaType)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", lfsMediaType)
+	for key, value := range action.Header {
+		req.Header.Set(key, value)
+	}
+	return c.do(req, nil)
+}
+
+// operation is an operation of a commit, which is a line of its NDJSON body
+type operation struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// fileOperations returns the operations of committing the files: the contents of those which are not stored with
+// LFS, and the object IDs of those which are
+func fileOperations(uploads []*upload) ([]operation, error) {
+	operations := make([]operation, 0, len(uploads))
+	for _, u := range uploads {
+		if u.lfs {
+			operations = append(operations, operation{Key: "lfsFile", Value: map[string]string{"path": u.path, "algo": "sha256", "oid": u.oid}})
+			continue
+		}
+		data, err := ioutil.ReadFile(u.localPath)
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, operation{Key: "file", Value: map[string]string{"path": u.path, "content": base64.StdEncoding.EncodeToString(data), "encoding": "base64"}})
+	}
+	return operations, nil
+}
+
+// commit commits the operations to the branch of the revision, returning the ID of the commit
+func (c *client) commit(summary string, operations []operation) (string, error) {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	if err := encoder.Encode(operation{Key: "header", Value: map[string]string{"summary": summary, "description": ""}}); err != nil {
+		return "", err
+	}
+	for _, op := range operations {
+		if err := encoder.Encode(op); err != nil {
+			return "", err
+		}
+	}
+	req, err := c.newRequest(http.MethodPost, c.apiURL("commit", ""), &body, "application/x-ndjson")
+	if err != nil {
+		return "", err
+	}
+	var res struct {
+		CommitOID string `json:"commitOid"`
+	}
+	if err := c.do(req, &res); err != nil {
+		return "", fmt.Errorf("Hugging Face commit to %s failed: %w", c.revision, err)
+	}
+	return res.CommitOID, nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
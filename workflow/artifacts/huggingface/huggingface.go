@@ -0,0 +1,283 @@
+package huggingface
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+// repoIDRegex matches the IDs of repositories: a name, which may be under the user or organization it belongs to
+var repoIDRegex = regexp.MustCompile(`^([\w.-]+/)?[\w.-]+$`)
+
+// ArtifactDriver is the artifact driver for the Hugging Face Hub, which loads files and directories from model,
+// dataset and space repositories, and commits them to their branches
+type ArtifactDriver struct {
+	// Token is the user access token requests to the Hub are authorized with, if set
+	Token string
+	common.Logging
+	common.Transporting
+}
+
+// ValidateArtifact validates the Hugging Face artifact, beyond what the general artifact validation checks
+func ValidateArtifact(errPrefix string, art *wfv1.HuggingFaceArtifact) error {
+	if !repoIDRegex.MatchString(art.RepoID) {
+		return errors.Errorf(errors.CodeBadRequest, "%s.repoID must be the ID of a repository, e.g. my-org/my-model", errPrefix)
+	}
+	switch art.RepoType {
+	case "", wfv1.HuggingFaceRepoTypeModel, wfv1.HuggingFaceRepoTypeDataset, wfv1.HuggingFaceRepoTypeSpace:
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "%s.repoType must be one of: model, dataset, space", errPrefix)
+	}
+	if art.Endpoint != "" {
+		if u, err := url.Parse(art.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.endpoint must be the URL of the Hub, e.g. https://huggingface.co", errPrefix)
+		}
+	}
+	for _, component := range strings.Split(art.Path, "/") {
+		if component == ".." {
+			return errors.Errorf(errors.CodeBadRequest, "%s.path must not contain ..", errPrefix)
+		}
+	}
+	return nil
+}
+
+// cleanPath returns the path of an artifact in its repository, without leading or trailing slashes
+func cleanPath(p string) string {
+	return strings.Trim(path.Clean("/"+p), "/")
+}
+
+func (d *ArtifactDriver) newClient(ctx context.Context, art *wfv1.HuggingFaceArtifact) (*client, error) {
+	endpoint := art.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	return newClient(ctx, d.RoundTripper(nil), u, d.Token, art), nil
+}
+
+// Load downloads the file, or every file under the directory, at the revision. Files stored with LFS are downloaded
+// from the LFS storage the Hub redirects to.
+func (d *ArtifactDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, localPath string) error {
+	art := inputArtifact.HuggingFace
+	p := cleanPath(art.Path)
+	c, err := d.newClient(ctx, art)
+	if err != nil {
+		return err
+	}
+	d.Log().Infof("Hugging Face Load path: %s, repo: %s, revision: %s, hugging face path: %s", localPath, art.RepoID, c.revision, p)
+	// the root of the repository has no entry
+	if p == "" {
+		return d.loadDirectory(c, p, localPath)
+	}
+	e, err := c.getEntry(p)
+	if err != nil {
+		return err
+	}
+	if e.Type == typeDirectory {
+		return d.loadDirectory(c, p, localPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(localPath), 0700); err != nil {
+		return err
+	}
+	if err := c.download(e, localPath); err != nil {
+		_ = os.Remove(localPath)
+		return err
+	}
+	return nil
+}
+
+func (d *ArtifactDriver) loadDirectory(c *client, dir, localPath string) error {
+	entries, err := c.listTree(dir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(localPath, 0700); err != nil {
+		return err
+	}
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Path, prefix) {
+			continue
+		}
+		rel := strings.TrimPrefix(e.Path, prefix)
+		if cleanPath(rel) != rel || rel == "" {
+			return fmt.Errorf("Hugging Face directory %s has an entry with an invalid path %q", dir, e.Path)
+		}
+		p := filepath.Join(localPath, filepath.FromSlash(rel))
+		if e.Type == typeDirectory {
+			if err := os.MkdirAll(p, 0700); err != nil {
+				return err
+			}
+			continue
+		}
+		d.Log().Debugf("Hugging Face downloading %s to %s", e.Path, p)
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			return err
+		}
+		if err := c.download(e, p); err != nil {
+			_ = os.Remove(p)
+			return err
+		}
+	}
+	return nil
+}
+
+// Save commits the file, or every file under the directory, to the branch of the revision in a single commit, which
+// replaces the files at their paths. The files the Hub stores with LFS, which it decides by their paths and sizes,
+// are uploaded to its LFS storage before the commit.
+func (d *ArtifactDriver) Save(ctx context.Context, localPath string, outputArtifact *wfv1.Artifact) error {
+	art := outputArtifact.HuggingFace
+	p := cleanPath(art.Path)
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() && p == "" {
+		return errors.Errorf(errors.CodeBadRequest, "Hugging Face artifact %s has no path, which a file must be saved to", outputArtifact.Name)
+	}
+	uploads, err := collectUploads(localPath, p, info)
+	if err != nil {
+		return err
+	}
+	c, err := d.newClient(ctx, art)
+	if err != nil {
+		return err
+	}
+	d.Log().Infof("Hugging Face Save path: %s, repo: %s, revision: %s, hugging face path: %s", localPath, art.RepoID, c.revision, p)
+	if len(uploads) == 0 {
+		// Git has no empty directories
+		d.Log().Infof("Hugging Face has no files of %s to commit", localPath)
+		return nil
+	}
+	if err := c.preupload(uploads); err != nil {
+		return err
+	}
+	if err := c.uploadLFS(uploads); err != nil {
+		return err
+	}
+	operations, err := fileOperations(uploads)
+	if err != nil {
+		return err
+	}
+	oid, err := c.commit(fmt.Sprintf("Save artifact %s", outputArtifact.Name), operations)
+	if err != nil {
+		return err
+	}
+	d.Log().Infof("Hugging Face committed %d file(s) to %s as %s", len(uploads), c.revision, oid)
+	return nil
+}
+
+// collectUploads returns the uploads of the file, or of every regular file under the directory, to their paths in the
+// repository under p
+func collectUploads(localPath, p string, info os.FileInfo) ([]*upload, error) {
+	if !info.IsDir() {
+		u, err := newUpload(localPath, p, info.Size())
+		if err != nil {
+			return nil, err
+		}
+		return []*upload{u}, nil
+	}
+	var uploads []*upload
+	err := filepath.Walk(localPath, func(f string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(localPath, f)
+		if err != nil {
+			return err
+		}
+		u, err := newUpload(f, path.Join(p, filepath.ToSlash(rel)), fi.Size())
+		if err != nil {
+			return err
+		}
+		uploads = append(uploads, u)
+		return nil
+	})
+	return uploads, err
+}
+
+// newUpload returns the upload of a file, with the sample of it the Hub decides whether it is stored with LFS by
+func newUpload(localPath, p string, size int64) (*upload, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	sample := make([]byte, sampleSize)
+	n, err := io.ReadFull(f, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return &upload{localPath: localPath, path: p, size: size, sample: sample[:n]}, nil
+}
+
+// Exists returns whether there is a file or directory at the path of the artifact at the revision
+func (d *ArtifactDriver) Exists(artifact *wfv1.Artifact) (bool, error) {
+	p := cleanPath(artifact.HuggingFace.Path)
+	if p == "" {
+		return true, nil
+	}
+	c, err := d.newClient(context.Background(), artifact.HuggingFace)
+	if err != nil {
+		return false, err
+	}
+	_, err = c.getEntry(p)
+	if stderrors.Is(err, common.ErrArtifactNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Delete commits the deletion of the file, or of the directory and everything under it, to the branch of the
+// revision, which is not an error if there is none
+func (d *ArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	p := cleanPath(artifact.HuggingFace.Path)
+	d.Log().Infof("Hugging Face Delete repo: %s, hugging face path: %s", artifact.HuggingFace.RepoID, p)
+	if p == "" {
+		return errors.Errorf(errors.CodeBadRequest, "Hugging Face cannot delete the root of a repository")
+	}
+	c, err := d.newClient(context.Background(), artifact.HuggingFace)
+	if err != nil {
+		return err
+	}
+	e, err := c.getEntry(p)
+	if stderrors.Is(err, common.ErrArtifactNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	op := operation{Key: "deletedFile", Value: map[string]string{"path": p}}
+	if e.Type == typeDirectory {
+		op = operation{Key: "deletedFolder", Value: map[string]string{"path": p + "/"}}
+	}
+	if _, err := c.commit(fmt.Sprintf("Delete artifact %s", artifact.Name), []operation{op}); err != nil {
+		return fmt.Errorf("Hugging Face delete of %s failed: %w", p, err)
+	}
+	return nil
+}
+
+// Capabilities returns the optional operations on Hugging Face artifacts the driver supports
+func (d *ArtifactDriver) Capabilities() common.DriverCapabilities {
+	return common.DriverCapabilities{
+		Delete: true,
+		Exists: true,
+	}
+}
@@ -0,0 +1,715 @@
+package huggingface
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const (
+	testRepoID    = "my-org/my-model"
+	testToken     = "hf_my-token"
+	testSignature = "my-signature"
+	// files larger than lfsThreshold are stored with LFS, and LFS objects larger than partSize are uploaded in parts
+	lfsThreshold = 10
+	partSize     = 16
+	// the number of entries the fake lists per page of a tree
+	treePageSize = 2
+)
+
+// fakeHub is the Hub API of a single repository, whose files at main are keyed by their paths, and its LFS storage,
+// whose presigned URLs are on another server
+type fakeHub struct {
+	hub, storage *httptest.Server
+	mu           sync.Mutex
+	repoType     wfv1.HuggingFaceRepoType
+	// the files of the repository, and the objects of the LFS storage, keyed by their IDs
+	files   map[string][]byte
+	objects map[string][]byte
+	// the parts of multipart uploads, keyed by the IDs of their objects and their numbers
+	parts map[string]map[int][]byte
+	// the summaries of the commits, and the number of objects uploaded in a single request, uploaded in parts and
+	// verified
+	commits                      []string
+	uploads, multipart, verified int
+	// whether the repository requires the token, whether files are served with content which does not match their
+	// checksums, and whether the token was sent to the storage
+	private, corrupt, leakedToken bool
+}
+
+func newFakeHub(repoType wfv1.HuggingFaceRepoType) *fakeHub {
+	f := &fakeHub{repoType: repoType, files: map[string][]byte{}, objects: map[string][]byte{}, parts: map[string]map[int][]byte{}}
+	f.hub = httptest.NewServer(http.HandlerFunc(f.serveHub))
+	f.storage = httptest.NewServer(http.HandlerFunc(f.serveStorage))
+	return f
+}
+
+func (f *fakeHub) Close() {
+	f.hub.Close()
+	f.storage.Close()
+}
+
+func (f *fakeHub) artifact(p string) *wfv1.Artifact {
+	return &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{
+		HuggingFace: &wfv1.HuggingFaceArtifact{RepoID: testRepoID, RepoType: f.repoType, Path: p, Endpoint: f.hub.URL},
+	}}
+}
+
+func (f *fakeHub) repoPath() string {
+	if f.repoType == wfv1.HuggingFaceRepoTypeModel {
+		return "/" + testRepoID
+	}
+	return "/" + string(f.repoType) + "s/" + testRepoID
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// testBlobOID computes the ID of the Git blob of a file, as Git documents it
+func testBlobOID(data []byte) string {
+	sum := sha1.Sum(append([]byte(fmt.Sprintf("blob %d\x00", len(data))), data...))
+	return hex.EncodeToString(sum[:])
+}
+
+func (f *fakeHub) put(p string, data []byte) {
+	f.files[p] = data
+	if len(data) > lfsThreshold {
+		f.objects[sha256Hex(data)] = data
+	}
+}
+
+// isDir returns whether the path, which is empty for the root, is a directory, which it is if a file is under it
+func (f *fakeHub) isDir(p string) bool {
+	for name := range f.files {
+		if p == "" || strings.HasPrefix(name, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// entry returns the JSON of the entry of a file or directory
+func (f *fakeHub) entry(p string) map[string]interface{} {
+	data, ok := f.files[p]
+	if !ok {
+		return map[string]interface{}{"type": "directory", "path": p, "oid": "0000", "size": 0}
+	}
+	e := map[string]interface{}{"type": "file", "path": p, "oid": testBlobOID(data), "size": len(data)}
+	if len(data) > lfsThreshold {
+		// the object ID of a file stored with LFS is that of its pointer
+		e["oid"] = "1111"
+		e["lfs"] = map[string]interface{}{"oid": sha256Hex(data), "size": len(data), "pointerSize": 134}
+	}
+	return e
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	if code != "" {
+		w.Header().Set(errorCodeHeader, code)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (f *fakeHub) serveHub(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	auth := r.Header.Get("Authorization")
+	if auth != "" && auth != "Bearer "+testToken {
+		writeError(w, http.StatusUnauthorized, "", "Invalid credentials in Authorization header")
+		return
+	}
+	authorized := auth != ""
+	api := "/api/" + string(f.repoType) + "s/" + testRepoID + "/"
+	switch {
+	case strings.HasPrefix(r.URL.Path, api):
+		if f.private && !authorized {
+			writeError(w, http.StatusUnauthorized, "RepoNotFound", "Repository Not Found")
+			return
+		}
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, api), "/", 3)
+		if len(parts) < 2 || parts[1] != defaultRevision {
+			writeError(w, http.StatusNotFound, "RevisionNotFound", "Invalid rev id")
+			return
+		}
+		p := ""
+		if len(parts) == 3 {
+			p = parts[2]
+		}
+		switch parts[0] {
+		case "paths-info":
+			f.pathsInfo(w, r)
+		case "tree":
+			f.tree(w, r, p)
+		case "preupload":
+			f.preupload(w, r)
+		case "commit":
+			if !authorized {
+				writeError(w, http.StatusUnauthorized, "", "You must be authenticated to commit")
+				return
+			}
+			f.commit(w, r)
+		default:
+			writeError(w, http.StatusNotFound, "", "Not Found")
+		}
+	case strings.HasPrefix(r.URL.Path, f.repoPath()+"/resolve/"+defaultRevision+"/"):
+		if f.private && !authorized {
+			writeError(w, http.StatusUnauthorized, "RepoNotFound", "Repository Not Found")
+			return
+		}
+		f.resolve(w, r, strings.TrimPrefix(r.URL.Path, f.repoPath()+"/resolve/"+defaultRevision+"/"))
+	case r.URL.Path == f.repoPath()+".git/info/lfs/objects/batch":
+		if !authorized {
+			writeError(w, http.StatusUnauthorized, "", "You must be authenticated to upload")
+			return
+		}
+		f.batch(w, r)
+	case strings.HasPrefix(r.URL.Path, "/lfs/complete/"):
+		f.complete(w, r, strings.TrimPrefix(r.URL.Path, "/lfs/complete/"))
+	case r.URL.Path == "/lfs/verify":
+		f.verify(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "", "Not Found")
+	}
+}
+
+func (f *fakeHub) pathsInfo(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "", err.Error())
+		return
+	}
+	entries := []map[string]interface{}{}
+	for _, p := range r.PostForm["paths"] {
+		if _, ok := f.files[p]; ok || (p != "" && f.isDir(p)) {
+			entries = append(entries, f.entry(p))
+		}
+	}
+	writeJSON(w, entries)
+}
+
+func (f *fakeHub) tree(w http.ResponseWriter, r *http.Request, dir string) {
+	if r.URL.Query().Get("recursive") != "true" {
+		writeError(w, http.StatusBadRequest, "", "the driver lists trees recursively")
+		return
+	}
+	if !f.isDir(dir) {
+		writeError(w, http.StatusNotFound, "EntryNotFound", dir+" does not exist")
+		return
+	}
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+	paths := map[string]bool{}
+	for name := range f.files {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		paths[name] = true
+		for d := filepath.Dir(name); d != "." && d != dir; d = filepath.Dir(d) {
+			paths[d] = true
+		}
+	}
+	var sorted []string
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+	cursor, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+	end := cursor + treePageSize
+	if end < len(sorted) {
+		w.Header().Set("Link", fmt.Sprintf(`<%s%s?recursive=true&cursor=%d>; rel="next"`, f.hub.URL, r.URL.Path, end))
+	} else {
+		end = len(sorted)
+	}
+	entries := []map[string]interface{}{}
+	for _, p := range sorted[cursor:end] {
+		entries = append(entries, f.entry(p))
+	}
+	writeJSON(w, entries)
+}
+
+func (f *fakeHub) resolve(w http.ResponseWriter, r *http.Request, p string) {
+	data, ok := f.files[p]
+	if !ok {
+		writeError(w, http.StatusNotFound, "EntryNotFound", p+" does not exist")
+		return
+	}
+	if len(data) > lfsThreshold {
+		http.Redirect(w, r, fmt.Sprintf("%s/objects/%s?sig=%s", f.storage.URL, sha256Hex(data), testSignature), http.StatusFound)
+		return
+	}
+	if f.corrupt {
+		data = append([]byte("corrupt"), data...)
+	}
+	_, _ = w.Write(data)
+}
+
+func (f *fakeHub) preupload(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Files []struct {
+			Path   string `json:"path"`
+			Sample string `json:"sample"`
+			Size   int    `json:"size"`
+		} `json:"files"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "", err.Error())
+		return
+	}
+	files := []map[string]interface{}{}
+	for _, file := range req.Files {
+		sample, err := base64.StdEncoding.DecodeString(file.Sample)
+		if err != nil || len(sample) > sampleSize || (len(sample) < sampleSize && len(sample) != file.Size) {
+			writeError(w, http.StatusBadRequest, "", "invalid sample of "+file.Path)
+			return
+		}
+		mode := "regular"
+		if file.Size > lfsThreshold {
+			mode = "lfs"
+		}
+		files = append(files, map[string]interface{}{"path": file.Path, "uploadMode": mode, "shouldIgnore": false})
+	}
+	writeJSON(w, map[string]interface{}{"files": files})
+}
+
+func (f *fakeHub) batch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Operation string      `json:"operation"`
+		Transfers []string    `json:"transfers"`
+		Objects   []lfsObject `json:"objects"`
+		HashAlgo  string      `json:"hash_algo"`
+		Ref       struct {
+			Name string `json:"name"`
+		} `json:"ref"`
+	}
+	if r.Header.Get("Accept") != lfsMediaType || json.NewDecoder(r.Body).Decode(&req) != nil || req.Operation != "upload" || req.HashAlgo != "sha256" || req.Ref.Name != defaultRevision {
+		writeError(w, http.StatusBadRequest, "", "invalid batch request")
+		return
+	}
+	transfer := "basic"
+	objects := []map[string]interface{}{}
+	for _, object := range req.Objects {
+		res := map[string]interface{}{"oid": object.OID, "size": object.Size}
+		objects = append(objects, res)
+		if _, ok := f.objects[object.OID]; ok {
+			continue
+		}
+		verify := map[string]interface{}{"href": f.hub.URL + "/lfs/verify", "header": map[string]string{}}
+		if object.Size <= partSize {
+			upload := map[string]interface{}{"href": fmt.Sprintf("%s/upload/%s?sig=%s", f.storage.URL, object.OID, testSignature), "header": map[string]string{}}
+			res["actions"] = map[string]interface{}{"upload": upload, "verify": verify}
+			continue
+		}
+		transfer = "multipart"
+		header := map[string]string{"chunk_size": strconv.Itoa(partSize)}
+		for n := 1; int64(n-1)*partSize < object.Size; n++ {
+			header[fmt.Sprintf("%05d", n)] = fmt.Sprintf("%s/upload/%s/%d?sig=%s", f.storage.URL, object.OID, n, testSignature)
+		}
+		upload := map[string]interface{}{"href": f.hub.URL + "/lfs/complete/" + object.OID, "header": header}
+		res["actions"] = map[string]interface{}{"upload": upload, "verify": verify}
+	}
+	w.Header().Set("Content-Type", lfsMediaType)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"transfer": transfer, "objects": objects})
+}
+
+func (f *fakeHub) complete(w http.ResponseWriter, r *http.Request, oid string) {
+	var req struct {
+		OID   string `json:"oid"`
+		Parts []struct {
+			PartNumber int    `json:"partNumber"`
+			ETag       string `json:"etag"`
+		} `json:"parts"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.OID != oid {
+		writeError(w, http.StatusBadRequest, "", "invalid completion")
+		return
+	}
+	var data []byte
+	for i, part := range req.Parts {
+		if part.PartNumber != i+1 || part.ETag != fmt.Sprintf(`"etag-%d"`, part.PartNumber) {
+			writeError(w, http.StatusBadRequest, "", "invalid part")
+			return
+		}
+		data = append(data, f.parts[oid][part.PartNumber]...)
+	}
+	if sha256Hex(data) != oid {
+		writeError(w, http.StatusBadRequest, "", "checksum mismatch")
+		return
+	}
+	f.objects[oid] = data
+	f.multipart++
+	writeJSON(w, map[string]string{})
+}
+
+func (f *fakeHub) verify(w http.ResponseWriter, r *http.Request) {
+	var req lfsObject
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "", err.Error())
+		return
+	}
+	if data, ok := f.objects[req.OID]; !ok || int64(len(data)) != req.Size {
+		writeError(w, http.StatusNotFound, "", "object not found")
+		return
+	}
+	f.verified++
+	writeJSON(w, map[string]string{})
+}
+
+func (f *fakeHub) commit(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != "application/x-ndjson" {
+		writeError(w, http.StatusBadRequest, "", "invalid content type")
+		return
+	}
+	files := map[string][]byte{}
+	for k, v := range f.files {
+		files[k] = v
+	}
+	summary := ""
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var op struct {
+			Key   string            `json:"key"`
+			Value map[string]string `json:"value"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &op); err != nil {
+			writeError(w, http.StatusBadRequest, "", err.Error())
+			return
+		}
+		switch op.Key {
+		case "header":
+			summary = op.Value["summary"]
+		case "file":
+			data, err := base64.StdEncoding.DecodeString(op.Value["content"])
+			if err != nil || op.Value["encoding"] != "base64" {
+				writeError(w, http.StatusBadRequest, "", "invalid content")
+				return
+			}
+			files[op.Value["path"]] = data
+		case "lfsFile":
+			data, ok := f.objects[op.Value["oid"]]
+			if !ok || op.Value["algo"] != "sha256" {
+				writeError(w, http.StatusUnprocessableEntity, "", "LFS object not found")
+				return
+			}
+			files[op.Value["path"]] = data
+		case "deletedFile":
+			delete(files, op.Value["path"])
+		case "deletedFolder":
+			for name := range files {
+				if strings.HasPrefix(name, op.Value["path"]) {
+					delete(files, name)
+				}
+			}
+		default:
+			writeError(w, http.StatusBadRequest, "", "invalid operation "+op.Key)
+			return
+		}
+	}
+	if summary == "" {
+		writeError(w, http.StatusBadRequest, "", "a commit must have a summary")
+		return
+	}
+	f.files = files
+	f.commits = append(f.commits, summary)
+	writeJSON(w, map[string]string{"commitOid": fmt.Sprintf("commit-%d", len(f.commits)), "commitUrl": f.hub.URL + "/commit"})
+}
+
+func (f *fakeHub) serveStorage(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "" {
+		f.leakedToken = true
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("sig") != testSignature {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "objects":
+		data, ok := f.objects[parts[1]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if f.corrupt {
+			data = append([]byte("corrupt"), data...)
+		}
+		_, _ = w.Write(data)
+	case r.Method == http.MethodPut && len(parts) == 2 && parts[0] == "upload":
+		data, _ := ioutil.ReadAll(r.Body)
+		if sha256Hex(data) != parts[1] {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.objects[parts[1]] = data
+		f.uploads++
+	case r.Method == http.MethodPut && len(parts) == 3 && parts[0] == "upload":
+		n, _ := strconv.Atoi(parts[2])
+		data, _ := ioutil.ReadAll(r.Body)
+		if f.parts[parts[1]] == nil {
+			f.parts[parts[1]] = map[int][]byte{}
+		}
+		f.parts[parts[1]][n] = data
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, n))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeHub) contents(dir string) map[string]string {
+	files := map[string]string{}
+	for k, data := range f.files {
+		if strings.HasPrefix(k, dir+"/") {
+			files[strings.TrimPrefix(k, dir+"/")] = string(data)
+		}
+	}
+	return files
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0700))
+		assert.NoError(t, ioutil.WriteFile(p, []byte(data), 0600))
+	}
+}
+
+func readFiles(t *testing.T, dir string) map[string]string {
+	files := map[string]string{}
+	assert.NoError(t, filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(p)
+		files[filepath.ToSlash(rel)] = string(data)
+		return err
+	}))
+	return files
+}
+
+// testModel is the files of a model repository, of which the weights and the merges are stored with LFS
+var testModel = map[string]string{
+	"README.md":            "# my-model",
+	"config.json":          `{"a":1}`,
+	"model.safetensors":    "the weights of the model",
+	"tokenizer/vocab.txt":  "a b c",
+	"tokenizer/merges.txt": "more than ten bytes",
+	"tokenizer/sub/x.txt":  "x",
+}
+
+func newTestModel(repoType wfv1.HuggingFaceRepoType) *fakeHub {
+	f := newFakeHub(repoType)
+	for name, data := range testModel {
+		f.put(name, []byte(data))
+	}
+	return f
+}
+
+func TestArtifactDriver_Load(t *testing.T) {
+	f := newTestModel(wfv1.HuggingFaceRepoTypeModel)
+	defer f.Close()
+	tmp, err := ioutil.TempDir("", "huggingface")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	driver := &ArtifactDriver{Token: testToken}
+
+	t.Run("File", func(t *testing.T) {
+		dst := filepath.Join(tmp, "file", "config.json")
+		if assert.NoError(t, driver.Load(context.Background(), f.artifact("config.json"), dst)) {
+			data, err := ioutil.ReadFile(dst)
+			assert.NoError(t, err)
+			assert.Equal(t, testModel["config.json"], string(data))
+		}
+	})
+	t.Run("LFSFile", func(t *testing.T) {
+		// the file is redirected to the LFS storage, which the token is not sent to
+		dst := filepath.Join(tmp, "lfs", "model.safetensors")
+		if assert.NoError(t, driver.Load(context.Background(), f.artifact("/model.safetensors"), dst)) {
+			data, err := ioutil.ReadFile(dst)
+			assert.NoError(t, err)
+			assert.Equal(t, testModel["model.safetensors"], string(data))
+		}
+		assert.False(t, f.leakedToken)
+	})
+	t.Run("Directory", func(t *testing.T) {
+		dst := filepath.Join(tmp, "dir")
+		if assert.NoError(t, driver.Load(context.Background(), f.artifact("tokenizer/"), dst)) {
+			assert.Equal(t, map[string]string{"vocab.txt": "a b c", "merges.txt": "more than ten bytes", "sub/x.txt": "x"}, readFiles(t, dst))
+		}
+	})
+	t.Run("Root", func(t *testing.T) {
+		// the tree of the root is listed in several pages
+		dst := filepath.Join(tmp, "root")
+		if assert.NoError(t, driver.Load(context.Background(), f.artifact(""), dst)) {
+			assert.Equal(t, testModel, readFiles(t, dst))
+		}
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		err := driver.Load(context.Background(), f.artifact("missing.bin"), filepath.Join(tmp, "missing"))
+		assert.True(t, errors.Is(err, common.ErrArtifactNotFound), err)
+		art := f.artifact("config.json")
+		art.HuggingFace.Revision = "v2"
+		err = driver.Load(context.Background(), art, filepath.Join(tmp, "missing"))
+		assert.True(t, errors.Is(err, common.ErrArtifactNotFound), err)
+	})
+	t.Run("Corrupt", func(t *testing.T) {
+		f.corrupt = true
+		defer func() { f.corrupt = false }()
+		for _, p := range []string{"config.json", "model.safetensors"} {
+			dst := filepath.Join(tmp, "corrupt", p)
+			err := driver.Load(context.Background(), f.artifact(p), dst)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), "checksum mismatch")
+			}
+			assert.NoFileExists(t, dst)
+		}
+	})
+	t.Run("Private", func(t *testing.T) {
+		f.private = true
+		defer func() { f.private = false }()
+		err := (&ArtifactDriver{}).Load(context.Background(), f.artifact("config.json"), filepath.Join(tmp, "private", "config.json"))
+		assert.True(t, errors.Is(err, common.ErrArtifactNotFound), err)
+		assert.NoError(t, driver.Load(context.Background(), f.artifact("config.json"), filepath.Join(tmp, "private", "config.json")))
+	})
+	t.Run("Dataset", func(t *testing.T) {
+		f := newTestModel(wfv1.HuggingFaceRepoTypeDataset)
+		defer f.Close()
+		dst := filepath.Join(tmp, "dataset")
+		if assert.NoError(t, (&ArtifactDriver{}).Load(context.Background(), f.artifact("tokenizer"), dst)) {
+			assert.Len(t, readFiles(t, dst), 3)
+		}
+	})
+}
+
+func TestArtifactDriver_Save(t *testing.T) {
+	f := newFakeHub(wfv1.HuggingFaceRepoTypeModel)
+	defer f.Close()
+	// the LFS storage has the object of the file which is unchanged already
+	f.put("old/unchanged.bin", []byte("already uploaded"))
+	tmp, err := ioutil.TempDir("", "huggingface")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	files := map[string]string{
+		"config.json":       `{"a":1}`,
+		"weights/model.bin": "forty bytes of weights, in three parts!!",
+		"weights/small.bin": "twelve bytes",
+		"unchanged.bin":     "already uploaded",
+		"empty.txt":         "",
+	}
+	src := filepath.Join(tmp, "src")
+	writeFiles(t, src, files)
+	driver := &ArtifactDriver{Token: testToken}
+
+	t.Run("Directory", func(t *testing.T) {
+		if !assert.NoError(t, driver.Save(context.Background(), src, f.artifact("/out/"))) {
+			return
+		}
+		assert.Equal(t, files, f.contents("out"))
+		// the small LFS file is uploaded in a single request, the large one in parts, and the unchanged one not at all
+		assert.Equal(t, 1, f.uploads)
+		assert.Equal(t, 1, f.multipart)
+		assert.Equal(t, 2, f.verified)
+		assert.Equal(t, []string{"Save artifact my-art"}, f.commits)
+		assert.False(t, f.leakedToken)
+	})
+	t.Run("File", func(t *testing.T) {
+		if assert.NoError(t, driver.Save(context.Background(), filepath.Join(src, "config.json"), f.artifact("out/config-2.json"))) {
+			assert.Equal(t, `{"a":1}`, string(f.files["out/config-2.json"]))
+		}
+	})
+	t.Run("Load", func(t *testing.T) {
+		dst := filepath.Join(tmp, "dst")
+		if assert.NoError(t, driver.Load(context.Background(), f.artifact("out/weights"), dst)) {
+			assert.Equal(t, map[string]string{"model.bin": files["weights/model.bin"], "small.bin": files["weights/small.bin"]}, readFiles(t, dst))
+		}
+	})
+	t.Run("NoPath", func(t *testing.T) {
+		assert.Error(t, driver.Save(context.Background(), filepath.Join(src, "config.json"), f.artifact("")))
+	})
+	t.Run("Unauthorized", func(t *testing.T) {
+		err := (&ArtifactDriver{}).Save(context.Background(), src, f.artifact("unauthorized"))
+		assert.Error(t, err)
+		assert.Empty(t, f.contents("unauthorized"))
+	})
+}
+
+func TestArtifactDriver_ExistsAndDelete(t *testing.T) {
+	f := newTestModel(wfv1.HuggingFaceRepoTypeModel)
+	defer f.Close()
+	driver := &ArtifactDriver{Token: testToken}
+	for p, want := range map[string]bool{"": true, "config.json": true, "tokenizer": true, "missing": false} {
+		exists, err := driver.Exists(f.artifact(p))
+		assert.NoError(t, err)
+		assert.Equal(t, want, exists, p)
+	}
+	assert.NoError(t, driver.Delete(f.artifact("tokenizer")))
+	assert.Empty(t, f.contents("tokenizer"))
+	assert.NoError(t, driver.Delete(f.artifact("config.json")))
+	assert.NotContains(t, f.files, "config.json")
+	assert.Len(t, f.files, 2)
+	assert.Equal(t, []string{"Delete artifact my-art", "Delete artifact my-art"}, f.commits)
+	// there is nothing to delete
+	assert.NoError(t, driver.Delete(f.artifact("tokenizer")))
+	assert.Len(t, f.commits, 2)
+	assert.Error(t, driver.Delete(f.artifact("/")))
+}
+
+func TestValidateArtifact(t *testing.T) {
+	valid := wfv1.HuggingFaceArtifact{RepoID: testRepoID, RepoType: wfv1.HuggingFaceRepoTypeDataset, Path: "data/train", Endpoint: "https://huggingface.co"}
+	assert.NoError(t, ValidateArtifact("my-art", &valid))
+	assert.NoError(t, ValidateArtifact("my-art", &wfv1.HuggingFaceArtifact{RepoID: "gpt2"}))
+	for name, mutate := range map[string]func(a *wfv1.HuggingFaceArtifact){
+		"RepoID":   func(a *wfv1.HuggingFaceArtifact) { a.RepoID = "my-org/my-model/extra" },
+		"NoRepoID": func(a *wfv1.HuggingFaceArtifact) { a.RepoID = "" },
+		"RepoType": func(a *wfv1.HuggingFaceArtifact) { a.RepoType = "models" },
+		"Endpoint": func(a *wfv1.HuggingFaceArtifact) { a.Endpoint = "huggingface.co" },
+		"Path":     func(a *wfv1.HuggingFaceArtifact) { a.Path = "../other" },
+	} {
+		t.Run(name, func(t *testing.T) {
+			art := valid
+			mutate(&art)
+			assert.Error(t, ValidateArtifact("my-art", &art))
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package ipfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+// the types of the links of a directory, as the ls endpoint returns them, which are those of UnixFS
+const (
+	linkTypeRaw       = 0
+	linkTypeDirectory = 1
+	linkTypeFile      = 2
+	linkTypeHAMTShard = 5
+)
+
+// apiError is the error the RPC API responds with, with a status which is not 2xx
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Status)
+}
+
+// client is a client of the RPC API of an IPFS node, e.g. Kubo, whose endpoints are all POSTs under /api/v0
+type client struct {
+	ctx         context.Context
+	httpClient  *http.Client
+	url         string
+	username    string
+	password    string
+	bearerToken string
+}
+
+// request returns a request of the endpoint, with the arguments in its query, as the RPC API expects them
+func (c *client) request(endpoint string, args url.Values, body io.Reader) (*http.Request, error) {
+	u := strings.TrimSuffix(c.url, "/") + "/api/v0/" + endpoint
+	if len(args) > 0 {
+		u += "?" + args.Encode()
+	}
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, u, body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c.bearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	case c.username != "" || c.password != "":
+		req.SetBasicAuth(c.username, c.password)
+	}
+	return req, nil
+}
+
+// do sends the request, and returns the response if it has a 2xx status, which the caller must close, or the error
+// the node responded with
+func (c *client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer func() { _ = resp.Body.Close() }()
+		return nil, decodeError(resp)
+	}
+	return resp, nil
+}
+
+// decodeError returns the error of a response, which has a JSON body with a message for the errors of the node,
+// and a plain text one otherwise, e.g. for those of a proxy in front of it
+func decodeError(resp *http.Response) error {
+	e := &apiError{Status: resp.StatusCode}
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var body struct {
+		Message string `json:"Message"`
+	}
+	switch {
+	case json.Unmarshal(data, &body) == nil && body.Message != "":
+		e.Message = body.Message
+	case len(strings.TrimSpace(string(data))) > 0:
+		e.Message = strings.TrimSpace(string(data))
+	default:
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+	if e.Status == http.StatusNotFound || strings.Contains(e.Message, "not found") || strings.Contains(e.Message, "no link named") {
+		return common.NewNotFoundError(e)
+	}
+	return e
+}
+
+// call calls an endpoint, and decodes the JSON body of its response into res
+func (c *client) call(endpoint string, args url.Values, res interface{}) error {
+	req, err := c.request(endpoint, args, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("IPFS %s failed: %w", endpoint, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if res == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return fmt.Errorf("IPFS %s failed: %w", endpoint, err)
+	}
+	return nil
+}
+
+// stat is the type and CID of a file or directory
+type stat struct {
+	Hash string `json:"Hash"`
+	Type string `json:"Type"`
+}
+
+const statTypeDirectory = "directory"
+
+// stat returns whether the CID is that of a file or a directory
+func (c *client) stat(cid string) (stat, error) {
+	var s stat
+	err := c.call("files/stat", url.Values{"arg": {"/ipfs/" + cid}}, &s)
+	return s, err
+}
+
+// link is an entry of a directory
+type link struct {
+	Name string `json:"Name"`
+	Hash string `json:"Hash"`
+	Type int    `json:"Type"`
+}
+
+// ls lists the entries of a directory
+func (c *client) ls(cid string) ([]link, error) {
+	var res struct {
+		Objects []struct {
+			Links []link `json:"Links"`
+		} `json:"Objects"`
+	}
+	if err := c.call("ls", url.Values{"arg": {cid}, "stream": {"false"}}, &res); err != nil {
+		return nil, err
+	}
+	var links []link
+	for _, object := range res.Objects {
+		links = append(links, object.Links...)
+	}
+	return links, nil
+}
+
+// cat downloads a file
+func (c *client) cat(cid, localPath string) error {
+	req, err := c.request("cat", url.Values{"arg": {cid}}, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("IPFS cat of %s failed: %w", cid, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	out, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = out.Close() }()
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		return fmt.Errorf("IPFS cat of %s failed: %w", cid, err)
+	}
+	return out.Close()
+}
+
+// added is a file or directory the add endpoint added, whose name is its path under the root added
+type added struct {
+	Name string `json:"Name"`
+	Hash string `json:"Hash"`
+}
+
+// add adds a file, or a directory and everything under it, and pins it. The parts of the multipart body are
+// streamed, rather than buffered, with the directories before the files in them, as the node expects.
+func (c *client) add(localPath, root string) ([]added, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		err := writeParts(mw, localPath, root, info)
+		if err == nil {
+			err = mw.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+	req, err := c.request("add", url.Values{"pin": {"true"}, "progress": {"false"}}, pr)
+	if err != nil {
+		_ = pr.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := c.do(req)
+	if err != nil {
+		_ = pr.Close()
+		return nil, fmt.Errorf("IPFS add of %s failed: %w", localPath, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	// the response is a JSON object for each file and directory added, in the order they were
+	var entries []added
+	decoder := json.NewDecoder(resp.Body)
+	for decoder.More() {
+		var entry added
+		if err := decoder.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("IPFS add of %s failed: %w", localPath, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// writeParts writes a part for the file, or for the directory and for everything under it, with the path under
+// root as its file name
+func writeParts(mw *multipart.Writer, localPath, root string, info os.FileInfo) error {
+	if !info.IsDir() {
+		return writeFile(mw, localPath, root)
+	}
+	return filepath.Walk(localPath, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localPath, p)
+		if err != nil {
+			return err
+		}
+		name := path.Join(root, filepath.ToSlash(rel))
+		if fi.IsDir() {
+			_, err := mw.CreatePart(partHeader(name, "application/x-directory"))
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		return writeFile(mw, p, name)
+	})
+}
+
+func writeFile(mw *multipart.Writer, localPath, name string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	part, err := mw.CreatePart(partHeader(name, "application/octet-stream"))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, f)
+	return err
+}
+
+// partHeader returns the header of a part, whose file name is escaped, as the node unescapes it
+func partHeader(name, contentType string) textproto.MIMEHeader {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, url.QueryEscape(name)))
+	h.Set("Content-Type", contentType)
+	return h
+}
+
+// unpin unpins a file or directory, and everything under it, after which the node may garbage collect it
+func (c *client) unpin(cid string) error {
+	return c.call("pin/rm", url.Values{"arg": {cid}, "recursive": {"true"}}, nil)
+}
@@ -0,0 +1,166 @@
+package ipfs
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+// ArtifactDriver is the artifact driver for IPFS, which adds and fetches artifacts with the RPC API of an IPFS node
+type ArtifactDriver struct {
+	Username    string
+	Password    string
+	BearerToken string
+	common.Logging
+}
+
+func (d *ArtifactDriver) newClient(ctx context.Context, art *wfv1.IPFSArtifact) *client {
+	return &client{
+		ctx:         ctx,
+		httpClient:  &http.Client{},
+		url:         art.URL,
+		username:    d.Username,
+		password:    d.Password,
+		bearerToken: d.BearerToken,
+	}
+}
+
+// Load fetches the file, or the directory and everything under it, of the CID of the artifact
+func (d *ArtifactDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, localPath string) error {
+	cid := inputArtifact.IPFS.CID
+	d.Log().Infof("IPFS Load path: %s, cid: %s", localPath, cid)
+	if cid == "" {
+		return errors.Errorf(errors.CodeBadRequest, "IPFS artifact %s has no CID", inputArtifact.Name)
+	}
+	c := d.newClient(ctx, inputArtifact.IPFS)
+	s, err := c.stat(cid)
+	if err != nil {
+		return err
+	}
+	if s.Type == statTypeDirectory {
+		return d.loadDirectory(c, cid, localPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(localPath), 0700); err != nil {
+		return err
+	}
+	if err := c.cat(cid, localPath); err != nil {
+		_ = os.Remove(localPath)
+		return err
+	}
+	return nil
+}
+
+// loadDirectory fetches every file under a directory, and creates every directory under it, even if it is empty
+func (d *ArtifactDriver) loadDirectory(c *client, cid, localPath string) error {
+	if err := os.MkdirAll(localPath, 0700); err != nil {
+		return err
+	}
+	links, err := c.ls(cid)
+	if err != nil {
+		return err
+	}
+	for _, l := range links {
+		if l.Name == "" || l.Name == "." || l.Name == ".." || strings.ContainsAny(l.Name, `/\`) {
+			return fmt.Errorf("IPFS directory %s has an entry with an invalid name %q", cid, l.Name)
+		}
+		p := filepath.Join(localPath, l.Name)
+		switch l.Type {
+		case linkTypeDirectory, linkTypeHAMTShard:
+			if err := d.loadDirectory(c, l.Hash, p); err != nil {
+				return err
+			}
+		case linkTypeFile, linkTypeRaw:
+			d.Log().Debugf("IPFS fetching %s to %s", l.Hash, p)
+			if err := c.cat(l.Hash, p); err != nil {
+				_ = os.Remove(p)
+				return err
+			}
+		default:
+			d.Log().Warnf("IPFS skipping %s of directory %s, which is neither a file nor a directory", l.Name, cid)
+		}
+	}
+	return nil
+}
+
+// Save adds the file, or the directory and everything under it, and sets the CID of the artifact to the CID it was
+// added as
+func (d *ArtifactDriver) Save(ctx context.Context, localPath string, outputArtifact *wfv1.Artifact) error {
+	_, err := d.SaveWithResult(ctx, localPath, outputArtifact, "", nil)
+	return err
+}
+
+// SaveWithResult saves the artifact as Save does, returning its CID as its location, and the CID of each of its
+// files as their ETag
+func (d *ArtifactDriver) SaveWithResult(ctx context.Context, localPath string, outputArtifact *wfv1.Artifact, _ string, _ common.ProgressFunc) (*common.SaveResult, error) {
+	d.Log().Infof("IPFS Save path: %s", localPath)
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return nil, err
+	}
+	root := filepath.Base(localPath)
+	entries, err := d.newClient(ctx, outputArtifact.IPFS).add(localPath, root)
+	if err != nil {
+		return nil, err
+	}
+	// the entry of the root is the file or directory added, and those under it of a directory are what is in it
+	cid := ""
+	var objects []common.SavedObject
+	for _, entry := range entries {
+		if entry.Name == root {
+			cid = entry.Hash
+		}
+		key, local := entry.Name, localPath
+		if info.IsDir() {
+			if !strings.HasPrefix(entry.Name, root+"/") {
+				continue
+			}
+			key = strings.TrimPrefix(entry.Name, root+"/")
+			local = filepath.Join(localPath, filepath.FromSlash(key))
+		} else if entry.Name != root {
+			continue
+		}
+		fi, err := os.Stat(local)
+		if err != nil || fi.IsDir() {
+			continue
+		}
+		objects = append(objects, common.SavedObject{Key: key, Path: local, Size: fi.Size(), ETag: entry.Hash})
+	}
+	if cid == "" {
+		return nil, fmt.Errorf("IPFS add of %s did not return its CID", localPath)
+	}
+	d.Log().Infof("IPFS added %s as %s", localPath, cid)
+	outputArtifact.IPFS.CID = cid
+	return common.NewSaveResult("ipfs://"+cid, objects), nil
+}
+
+// Delete unpins the CID of the artifact from the node, which may then garbage collect it, unless it is pinned
+// otherwise. Other nodes may still have it.
+func (d *ArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	cid := artifact.IPFS.CID
+	d.Log().Infof("IPFS Delete cid: %s", cid)
+	if cid == "" {
+		return nil
+	}
+	err := d.newClient(context.Background(), artifact.IPFS).unpin(cid)
+	var e *apiError
+	if stderrors.As(err, &e) && strings.Contains(e.Message, "not pinned") {
+		return nil
+	}
+	return err
+}
+
+// Capabilities returns the optional operations on IPFS artifacts the driver supports
+func (d *ArtifactDriver) Capabilities() common.DriverCapabilities {
+	return common.DriverCapabilities{
+		Delete:     true,
+		SaveResult: true,
+	}
+}
@@ -0,0 +1,332 @@
+package ipfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const (
+	testUsername = "my-username"
+	testPassword = "my-password"
+)
+
+// fakeNode is a file, or a directory with links to its entries
+type fakeNode struct {
+	data  []byte
+	dir   bool
+	links map[string]string
+}
+
+// fakeIPFS is the RPC API of an IPFS node, whose CIDs are the checksums of what they are of, and which requires
+// basic auth
+type fakeIPFS struct {
+	*httptest.Server
+	mu     sync.Mutex
+	nodes  map[string]*fakeNode
+	pinned map[string]bool
+}
+
+func newFakeIPFS() *fakeIPFS {
+	f := &fakeIPFS{nodes: map[string]*fakeNode{}, pinned: map[string]bool{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+// put stores the node, and returns its CID
+func (f *fakeIPFS) put(n *fakeNode) string {
+	h := sha256.New()
+	if n.dir {
+		var names []string
+		for name := range n.links {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			_, _ = io.WriteString(h, name+"="+n.links[name]+"\n")
+		}
+	} else {
+		_, _ = h.Write(n.data)
+	}
+	prefix := "file-"
+	if n.dir {
+		prefix = "dir-"
+	}
+	cid := prefix + hex.EncodeToString(h.Sum(nil))[:16]
+	f.nodes[cid] = n
+	return cid
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"Message": message, "Code": 0, "Type": "error"})
+}
+
+func (f *fakeIPFS) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if username, password, ok := r.BasicAuth(); !ok || username != testUsername || password != testPassword {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = io.WriteString(w, "unauthorized")
+		return
+	}
+	arg := r.URL.Query().Get("arg")
+	switch strings.TrimPrefix(r.URL.Path, "/api/v0/") {
+	case "add":
+		f.add(w, r)
+	case "files/stat":
+		n, ok := f.nodes[strings.TrimPrefix(arg, "/ipfs/")]
+		if !ok {
+			writeError(w, http.StatusInternalServerError, "merkledag: not found")
+			return
+		}
+		typ := "file"
+		if n.dir {
+			typ = "directory"
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"Hash": arg, "Type": typ})
+	case "ls":
+		n, ok := f.nodes[arg]
+		if !ok {
+			writeError(w, http.StatusInternalServerError, "merkledag: not found")
+			return
+		}
+		var links []map[string]interface{}
+		for name, cid := range n.links {
+			typ := linkTypeFile
+			if f.nodes[cid] != nil && f.nodes[cid].dir {
+				typ = linkTypeDirectory
+			}
+			links = append(links, map[string]interface{}{"Name": name, "Hash": cid, "Type": typ})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"Objects": []interface{}{map[string]interface{}{"Hash": arg, "Links": links}}})
+	case "cat":
+		n, ok := f.nodes[arg]
+		if !ok || n.dir {
+			writeError(w, http.StatusInternalServerError, "merkledag: not found")
+			return
+		}
+		_, _ = w.Write(n.data)
+	case "pin/rm":
+		if !f.pinned[arg] {
+			writeError(w, http.StatusInternalServerError, "not pinned or pinned indirectly")
+			return
+		}
+		delete(f.pinned, arg)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"Pins": []string{arg}})
+	default:
+		writeError(w, http.StatusNotFound, "404 page not found")
+	}
+}
+
+// add adds the files and directories of the parts, whose file names are their escaped paths, and responds with
+// the CID of each of them, the root last
+func (f *fakeIPFS) add(w http.ResponseWriter, r *http.Request) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	files := map[string][]byte{}
+	dirs := map[string]bool{}
+	var root string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		_, params, err := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		name, err := url.QueryUnescape(params["filename"])
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if root == "" {
+			root = name
+		}
+		if part.Header.Get("Content-Type") == "application/x-directory" {
+			dirs[name] = true
+			continue
+		}
+		data, err := ioutil.ReadAll(part)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		files[name] = data
+	}
+	// the CIDs of the directories are those of their entries, so the deepest are stored first
+	cids := map[string]string{}
+	for name, data := range files {
+		cids[name] = f.put(&fakeNode{data: data})
+	}
+	var names []string
+	for name := range dirs {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return strings.Count(names[i], "/") > strings.Count(names[j], "/") })
+	for _, name := range names {
+		n := &fakeNode{dir: true, links: map[string]string{}}
+		for p, cid := range cids {
+			if path.Dir(p) == name {
+				n.links[path.Base(p)] = cid
+			}
+		}
+		cids[name] = f.put(n)
+	}
+	f.pinned[cids[root]] = true
+	encoder := json.NewEncoder(w)
+	for name, cid := range cids {
+		if name != root {
+			_ = encoder.Encode(added{Name: name, Hash: cid})
+		}
+	}
+	_ = encoder.Encode(added{Name: root, Hash: cids[root]})
+}
+
+func newArtifact(url, cid string) *wfv1.Artifact {
+	return &wfv1.Artifact{Name: "my-artifact", ArtifactLocation: wfv1.ArtifactLocation{IPFS: &wfv1.IPFSArtifact{URL: url, CID: cid}}}
+}
+
+func TestArtifactDriver(t *testing.T) {
+	f := newFakeIPFS()
+	defer f.Close()
+	driver := &ArtifactDriver{Username: testUsername, Password: testPassword}
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "ipfs")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	t.Run("File", func(t *testing.T) {
+		localPath := filepath.Join(dir, "file")
+		assert.NoError(t, ioutil.WriteFile(localPath, []byte("hello"), 0600))
+		art := newArtifact(f.URL, "")
+		result, err := driver.SaveWithResult(ctx, localPath, art, "", nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		cid := art.IPFS.CID
+		if assert.NotEmpty(t, cid) {
+			assert.Equal(t, "hello", string(f.nodes[cid].data))
+			assert.True(t, f.pinned[cid])
+		}
+		assert.Equal(t, "ipfs://"+cid, result.Location)
+		assert.Equal(t, []common.SavedObject{{Key: "file", Path: localPath, Size: 5, ETag: cid}}, result.Objects)
+		loadPath := filepath.Join(dir, "loaded", "file")
+		if assert.NoError(t, driver.Load(ctx, newArtifact(f.URL, cid), loadPath)) {
+			data, err := ioutil.ReadFile(loadPath)
+			assert.NoError(t, err)
+			assert.Equal(t, "hello", string(data))
+		}
+	})
+
+	t.Run("Directory", func(t *testing.T) {
+		localPath := filepath.Join(dir, "directory")
+		files := map[string]string{"a": "a", "b/c": "bc", "b/d/e": "bde", "héllo wörld": "hello"}
+		for name, data := range files {
+			p := filepath.Join(localPath, filepath.FromSlash(name))
+			assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0700))
+			assert.NoError(t, ioutil.WriteFile(p, []byte(data), 0600))
+		}
+		assert.NoError(t, os.MkdirAll(filepath.Join(localPath, "empty"), 0700))
+		art := newArtifact(f.URL, "")
+		result, err := driver.SaveWithResult(ctx, localPath, art, "", nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		cid := art.IPFS.CID
+		assert.True(t, strings.HasPrefix(cid, "dir-"))
+		var keys []string
+		for _, object := range result.Objects {
+			keys = append(keys, object.Key)
+		}
+		assert.Equal(t, []string{"a", "b/c", "b/d/e", "héllo wörld"}, keys)
+		assert.Equal(t, int64(11), result.Size)
+		loadPath := filepath.Join(dir, "loaded", "directory")
+		if !assert.NoError(t, driver.Load(ctx, newArtifact(f.URL, cid), loadPath)) {
+			return
+		}
+		for name, data := range files {
+			loaded, err := ioutil.ReadFile(filepath.Join(loadPath, filepath.FromSlash(name)))
+			assert.NoError(t, err)
+			assert.Equal(t, data, string(loaded), name)
+		}
+		info, err := os.Stat(filepath.Join(loadPath, "empty"))
+		if assert.NoError(t, err) {
+			assert.True(t, info.IsDir())
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		err := driver.Load(ctx, newArtifact(f.URL, "file-missing"), filepath.Join(dir, "missing"))
+		assert.True(t, errors.Is(err, common.ErrArtifactNotFound), err)
+	})
+
+	t.Run("NoCID", func(t *testing.T) {
+		err := driver.Load(ctx, newArtifact(f.URL, ""), filepath.Join(dir, "missing"))
+		assert.Error(t, err)
+	})
+
+	t.Run("InvalidName", func(t *testing.T) {
+		f.mu.Lock()
+		cid := f.put(&fakeNode{dir: true, links: map[string]string{"..": f.put(&fakeNode{data: []byte("escaped")})}})
+		f.mu.Unlock()
+		err := driver.Load(ctx, newArtifact(f.URL, cid), filepath.Join(dir, "invalid"))
+		assert.EqualError(t, err, `IPFS directory `+cid+` has an entry with an invalid name ".."`)
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		unauthorized := &ArtifactDriver{Username: testUsername, Password: "wrong"}
+		err := unauthorized.Load(ctx, newArtifact(f.URL, "file-missing"), filepath.Join(dir, "unauthorized"))
+		assert.EqualError(t, err, "IPFS files/stat failed: unauthorized (401)")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		localPath := filepath.Join(dir, "deleted")
+		assert.NoError(t, ioutil.WriteFile(localPath, []byte("deleted"), 0600))
+		art := newArtifact(f.URL, "")
+		if !assert.NoError(t, driver.Save(ctx, localPath, art)) {
+			return
+		}
+		assert.True(t, f.pinned[art.IPFS.CID])
+		assert.NoError(t, driver.Delete(art))
+		assert.False(t, f.pinned[art.IPFS.CID])
+		// deleting it again is not an error
+		assert.NoError(t, driver.Delete(art))
+	})
+}
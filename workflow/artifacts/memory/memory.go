@@ -0,0 +1,265 @@
+// Package memory is an in-memory artifact driver for unit tests, so that artifacts can be seeded and
+// asserted on without real storage. It is not registered as a driver of any artifact location; tests
+// pass it wherever they would create a driver, e.g. by registering it with executor.RegisterDriver.
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+// ArtifactDriver stores artifacts in memory. The files of a directory artifact are stored under its URL
+// followed by their paths relative to the directory, e.g. memory://my-key/dir/file.
+type ArtifactDriver struct {
+	lock  sync.RWMutex
+	files map[string][]byte
+	// encodings are the content encodings of the compressed files, keyed by URL
+	encodings map[string]string
+}
+
+// NewArtifactDriver returns a driver which stores no artifacts
+func NewArtifactDriver() *ArtifactDriver {
+	return &ArtifactDriver{files: map[string][]byte{}, encodings: map[string]string{}}
+}
+
+// URL returns the URL an artifact is stored at, which is made from the key of its location, e.g. the
+// key of an S3 artifact or the path of an HDFS one
+func URL(art *wfv1.Artifact) (string, error) {
+	key, err := art.GetKey()
+	if err != nil {
+		return "", err
+	}
+	if key == "" {
+		return "", errors.Errorf(errors.CodeBadRequest, "artifact %s has no key", art.Name)
+	}
+	return "memory://" + strings.TrimPrefix(path.Clean(key), "/"), nil
+}
+
+// Seed stores data at the URL, replacing anything already there
+func (d *ArtifactDriver) Seed(url string, data []byte) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.files[url] = data
+	delete(d.encodings, url)
+}
+
+// Content returns the data stored at the URL
+func (d *ArtifactDriver) Content(url string) ([]byte, bool) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	data, ok := d.files[url]
+	return data, ok
+}
+
+// URLs returns the URLs of the stored files, sorted
+func (d *ArtifactDriver) URLs() []string {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	urls := make([]string, 0, len(d.files))
+	for url := range d.files {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// dir returns the files stored under the URL, keyed by their relative paths. d.lock must be held.
+func (d *ArtifactDriver) dir(url string) map[string][]byte {
+	files := map[string][]byte{}
+	for u, data := range d.files {
+		if strings.HasPrefix(u, url+"/") {
+			files[strings.TrimPrefix(u, url+"/")] = data
+		}
+	}
+	return files
+}
+
+// Load writes the file or directory stored at the URL of the artifact to path
+func (d *ArtifactDriver) Load(_ context.Context, inputArtifact *wfv1.Artifact, path string) error {
+	url, err := URL(inputArtifact)
+	if err != nil {
+		return err
+	}
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	if data, ok := d.files[url]; ok {
+		return ioutil.WriteFile(path, data, 0600)
+	}
+	files := d.dir(url)
+	if len(files) == 0 {
+		return common.NewNotFoundError(fmt.Errorf("no artifact stored at %s", url))
+	}
+	for rel, data := range files {
+		localPath := filepath.Join(path, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(localPath), 0700); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(localPath, data, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OpenStream returns a reader of the file stored at the URL of the artifact. Directories are not supported.
+func (d *ArtifactDriver) OpenStream(inputArtifact *wfv1.Artifact) (io.ReadCloser, error) {
+	url, err := URL(inputArtifact)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := d.Content(url)
+	if !ok {
+		return nil, common.NewNotFoundError(fmt.Errorf("no file stored at %s", url))
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// LoadRange writes a range of the bytes of the file stored at the URL of the artifact to path. Directories are not
+// supported.
+func (d *ArtifactDriver) LoadRange(artifact *wfv1.Artifact, start, length int64, path string) error {
+	url, err := URL(artifact)
+	if err != nil {
+		return err
+	}
+	data, ok := d.Content(url)
+	if !ok {
+		return common.NewNotFoundError(fmt.Errorf("no file stored at %s", url))
+	}
+	if start >= int64(len(data)) {
+		return errors.Errorf(errors.CodeBadRequest, "the range %s starts after the end of %s", common.ByteRangeSpec(start, length), url)
+	}
+	end := int64(len(data))
+	if length >= 0 && start+length < end {
+		end = start + length
+	}
+	return ioutil.WriteFile(path, data[start:end], 0600)
+}
+
+// Save stores the file or directory at path, replacing any artifact already stored at the URL of the artifact
+func (d *ArtifactDriver) Save(_ context.Context, path string, outputArtifact *wfv1.Artifact) error {
+	url, err := URL(outputArtifact)
+	if err != nil {
+		return err
+	}
+	files := map[string][]byte{}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files[url] = data
+	} else {
+		err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(path, p)
+			if err != nil {
+				return err
+			}
+			data, err := ioutil.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			files[url+"/"+filepath.ToSlash(rel)] = data
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.delete(url)
+	for u, data := range files {
+		d.files[u] = data
+	}
+	return nil
+}
+
+// SaveWithContentEncoding stores the file at path, recording that its content is compressed with the encoding
+func (d *ArtifactDriver) SaveWithContentEncoding(ctx context.Context, path string, outputArtifact *wfv1.Artifact, encoding string, _ common.ProgressFunc) error {
+	if err := d.Save(ctx, path, outputArtifact); err != nil {
+		return err
+	}
+	url, err := URL(outputArtifact)
+	if err != nil {
+		return err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.encodings[url] = encoding
+	return nil
+}
+
+// ContentEncoding returns the encoding of the file stored at the URL of the artifact, or "" if it is not compressed
+func (d *ArtifactDriver) ContentEncoding(artifact *wfv1.Artifact) (string, error) {
+	url, err := URL(artifact)
+	if err != nil {
+		return "", err
+	}
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.encodings[url], nil
+}
+
+// Exists returns whether a file or directory is stored at the URL of the artifact
+func (d *ArtifactDriver) Exists(artifact *wfv1.Artifact) (bool, error) {
+	url, err := URL(artifact)
+	if err != nil {
+		return false, err
+	}
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	_, ok := d.files[url]
+	return ok || len(d.dir(url)) > 0, nil
+}
+
+// Delete removes the file or directory stored at the URL of the artifact
+func (d *ArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	url, err := URL(artifact)
+	if err != nil {
+		return err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.delete(url)
+	return nil
+}
+
+// Capabilities returns the optional operations on memory artifacts the driver supports
+func (d *ArtifactDriver) Capabilities() common.DriverCapabilities {
+	return common.DriverCapabilities{
+		Delete:          true,
+		Stream:          true,
+		LoadRange:       true,
+		Exists:          true,
+		ContentEncoding: true,
+	}
+}
+
+// delete removes the file or directory stored at the URL. d.lock must be held.
+func (d *ArtifactDriver) delete(url string) {
+	delete(d.files, url)
+	delete(d.encodings, url)
+	for rel := range d.dir(url) {
+		delete(d.files, url+"/"+rel)
+	}
+}
@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+func s3Artifact(key string) *wfv1.Artifact {
+	return &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: key}}}
+}
+
+func TestURL(t *testing.T) {
+	url, err := URL(s3Artifact("/my-dir/my-file/"))
+	assert.NoError(t, err)
+	assert.Equal(t, "memory://my-dir/my-file", url)
+	_, err = URL(s3Artifact(""))
+	assert.Error(t, err)
+	_, err = URL(&wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{Raw: &wfv1.RawArtifact{Data: "my-data"}}})
+	assert.Error(t, err)
+}
+
+func TestArtifactDriver(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "memory")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+	ctx := context.Background()
+
+	t.Run("File", func(t *testing.T) {
+		d := NewArtifactDriver()
+		src := filepath.Join(tmp, "src-file")
+		assert.NoError(t, ioutil.WriteFile(src, []byte("my-data"), 0600))
+		art := s3Artifact("my-file")
+		assert.NoError(t, d.Save(ctx, src, art))
+		data, ok := d.Content("memory://my-file")
+		assert.True(t, ok)
+		assert.Equal(t, "my-data", string(data))
+		dest := filepath.Join(tmp, "dest-file")
+		if assert.NoError(t, d.Load(ctx, art, dest)) {
+			data, err := ioutil.ReadFile(dest)
+			assert.NoError(t, err)
+			assert.Equal(t, "my-data", string(data))
+		}
+		stream, err := d.OpenStream(art)
+		if assert.NoError(t, err) {
+			data, err := ioutil.ReadAll(stream)
+			assert.NoError(t, err)
+			assert.Equal(t, "my-data", string(data))
+		}
+	})
+	t.Run("Directory", func(t *testing.T) {
+		d := NewArtifactDriver()
+		src := filepath.Join(tmp, "src-dir")
+		assert.NoError(t, os.MkdirAll(filepath.Join(src, "sub"), 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "a"), []byte("a"), 0600))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "sub", "b"), []byte("b"), 0600))
+		art := s3Artifact("my-dir")
+		d.Seed("memory://my-dir/stale", []byte("stale"))
+		assert.NoError(t, d.Save(ctx, src, art))
+		assert.Equal(t, []string{"memory://my-dir/a", "memory://my-dir/sub/b"}, d.URLs())
+		dest := filepath.Join(tmp, "dest-dir")
+		if assert.NoError(t, d.Load(ctx, art, dest)) {
+			data, err := ioutil.ReadFile(filepath.Join(dest, "sub", "b"))
+			assert.NoError(t, err)
+			assert.Equal(t, "b", string(data))
+		}
+		ok, err := d.Exists(art)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.NoError(t, d.Delete(art))
+		assert.Empty(t, d.URLs())
+	})
+	t.Run("Seed", func(t *testing.T) {
+		d := NewArtifactDriver()
+		d.Seed("memory://my-input", []byte("my-input-data"))
+		dest := filepath.Join(tmp, "seeded")
+		if assert.NoError(t, d.Load(ctx, s3Artifact("my-input"), dest)) {
+			data, err := ioutil.ReadFile(dest)
+			assert.NoError(t, err)
+			assert.Equal(t, "my-input-data", string(data))
+		}
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		d := NewArtifactDriver()
+		d.Seed("memory://my-file-other", []byte("other"))
+		err := d.Load(ctx, s3Artifact("my-file"), filepath.Join(tmp, "not-found"))
+		assert.True(t, errors.Is(err, common.ErrArtifactNotFound))
+		ok, err := d.Exists(s3Artifact("my-file"))
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		_, err = d.OpenStream(s3Artifact("my-file"))
+		assert.True(t, errors.Is(err, common.ErrArtifactNotFound))
+	})
+}
@@ -0,0 +1,106 @@
+package executor
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+// The statuses of the operations of the metrics
+const (
+	metricStatusSucceeded = "succeeded"
+	metricStatusFailed    = "failed"
+	// a save is skipped if its destination exists, or has the same content, as its overwrite policy allows
+	metricStatusSkipped = "skipped"
+)
+
+var (
+	operationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "argo",
+			Subsystem: "workflows",
+			Name:      "artifact_operations_total",
+			Help:      "Number of artifact loads and saves, by driver, operation and status",
+		},
+		[]string{"driver", "operation", "status"},
+	)
+	operationDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "argo",
+			Subsystem: "workflows",
+			Name:      "artifact_operation_duration_seconds",
+			Help:      "Duration of artifact loads and saves, whether or not they succeeded, by driver and operation",
+			// 100ms to about 14 minutes
+			Buckets: prometheus.ExponentialBuckets(0.1, 2, 14),
+		},
+		[]string{"driver", "operation"},
+	)
+	transferredBytes = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "argo",
+			Subsystem: "workflows",
+			Name:      "artifact_transferred_bytes",
+			Help:      "Size of the local files and directories of artifacts loaded and saved, by driver and operation",
+			// 1KiB to 4GiB
+			Buckets: prometheus.ExponentialBuckets(1024, 4, 12),
+		},
+		[]string{"driver", "operation"},
+	)
+	circuitBreakerStateDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("argo", "workflows", "artifact_circuit_breaker_state"),
+		"State of the circuit breaker of each endpoint of artifact drivers, which is 1 for its current state and 0 for the others",
+		[]string{"endpoint", "state"},
+		nil,
+	)
+)
+
+// circuitBreakerCollector collects the states of common.DefaultCircuitBreakers, if they are set, when it is collected
+type circuitBreakerCollector struct{}
+
+func (circuitBreakerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- circuitBreakerStateDesc
+}
+
+func (circuitBreakerCollector) Collect(ch chan<- prometheus.Metric) {
+	breakers := common.DefaultCircuitBreakers
+	if breakers == nil {
+		return
+	}
+	for endpoint, current := range breakers.States() {
+		for _, state := range []common.CircuitState{common.CircuitClosed, common.CircuitOpen, common.CircuitHalfOpen} {
+			value := 0.0
+			if state == current {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(circuitBreakerStateDesc, prometheus.GaugeValue, value, endpoint, string(state))
+		}
+	}
+}
+
+// RegisterMetrics registers the metrics of the loads and saves of LoadWithProgress and SaveWithResult, which every
+// driver's are, and the states of the circuit breakers of common.DefaultCircuitBreakers, with the registerer, e.g.
+// prometheus.DefaultRegisterer. They are recorded whether or not they are registered.
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{operationsTotal, operationDuration, transferredBytes, circuitBreakerCollector{}} {
+		if err := registerer.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// observeOperation records an operation, e.g. Load, of the artifact in the metrics, with the number of bytes
+// transferred, unless it is negative, as it is if the operation did not succeed
+func observeOperation(art *wfv1.Artifact, operation, status string, duration time.Duration, bytes int64) {
+	driver := driverName(art)
+	operation = strings.ToLower(operation)
+	operationsTotal.WithLabelValues(driver, operation, status).Inc()
+	operationDuration.WithLabelValues(driver, operation).Observe(duration.Seconds())
+	if bytes >= 0 {
+		transferredBytes.WithLabelValues(driver, operation).Observe(float64(bytes))
+	}
+}
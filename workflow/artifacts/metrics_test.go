@@ -0,0 +1,110 @@
+package executor
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/memory"
+)
+
+// histogramSamples returns the number and sum of the samples of each series of the named histogram, keyed by their
+// operations
+func histogramSamples(t *testing.T, registry *prometheus.Registry, name string) (map[string]uint64, map[string]float64) {
+	counts, sums := map[string]uint64{}, map[string]float64{}
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "operation" {
+					counts[label.GetValue()] = metric.GetHistogram().GetSampleCount()
+					sums[label.GetValue()] = metric.GetHistogram().GetSampleSum()
+				}
+			}
+		}
+	}
+	return counts, sums
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	operationsTotal.Reset()
+	operationDuration.Reset()
+	transferredBytes.Reset()
+	registry := prometheus.NewRegistry()
+	if !assert.NoError(t, RegisterMetrics(registry)) {
+		return
+	}
+	// the metrics are registered once
+	assert.Error(t, RegisterMetrics(registry))
+	tmp, err := ioutil.TempDir("", "metrics")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	ctx := context.Background()
+	src := filepath.Join(tmp, "src")
+	assert.NoError(t, ioutil.WriteFile(src, []byte("my-data"), 0600))
+	newArtifact := func(key string) *wfv1.Artifact {
+		return &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: key}}}
+	}
+	driver := memory.NewArtifactDriver()
+	assert.NoError(t, SaveWithProgress(ctx, driver, src, newArtifact("my-key"), nil))
+	assert.NoError(t, LoadWithProgress(ctx, driver, newArtifact("my-key"), filepath.Join(tmp, "dst"), nil))
+	assert.Error(t, LoadWithProgress(ctx, driver, newArtifact("not-found"), filepath.Join(tmp, "not-found"), nil))
+	art := newArtifact("my-key")
+	art.Overwrite = wfv1.ArtifactOverwriteSkipIfExists
+	assert.NoError(t, SaveWithProgress(ctx, &existingDriver{}, src, art, nil))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(operationsTotal.WithLabelValues(DriverS3, "save", metricStatusSucceeded)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(operationsTotal.WithLabelValues(DriverS3, "save", metricStatusSkipped)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(operationsTotal.WithLabelValues(DriverS3, "load", metricStatusSucceeded)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(operationsTotal.WithLabelValues(DriverS3, "load", metricStatusFailed)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(operationsTotal.WithLabelValues(DriverS3, "save", metricStatusFailed)))
+
+	// the duration of every load and save is observed, whether or not it succeeded, but only the bytes of those which
+	// transferred them
+	counts, _ := histogramSamples(t, registry, "argo_workflows_artifact_operation_duration_seconds")
+	assert.Equal(t, map[string]uint64{"load": 2, "save": 2}, counts)
+	counts, sums := histogramSamples(t, registry, "argo_workflows_artifact_transferred_bytes")
+	assert.Equal(t, map[string]uint64{"load": 1, "save": 1}, counts)
+	assert.Equal(t, map[string]float64{"load": 7, "save": 7}, sums)
+}
+
+func TestCircuitBreakerCollector(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	if !assert.NoError(t, registry.Register(circuitBreakerCollector{})) {
+		return
+	}
+	// no breakers, no series
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	assert.Empty(t, families)
+	common.DefaultCircuitBreakers = common.NewCircuitBreakers(common.CircuitBreakerConfig{FailureRate: 1, MinRequests: 1})
+	defer func() { common.DefaultCircuitBreakers = nil }()
+	common.DefaultCircuitBreakers.Breaker("https://my-endpoint").Record(true)
+	families, err = registry.Gather()
+	if !assert.NoError(t, err) || !assert.Len(t, families, 1) {
+		return
+	}
+	states := map[string]float64{}
+	for _, metric := range families[0].GetMetric() {
+		for _, label := range metric.GetLabel() {
+			if label.GetName() == "state" {
+				states[label.GetValue()] = metric.GetGauge().GetValue()
+			}
+		}
+	}
+	assert.Equal(t, map[string]float64{"closed": 0, "open": 1, "half-open": 0}, states)
+}
@@ -0,0 +1,153 @@
+package executor
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/resource"
+)
+
+// ErrMirrorSkipped is the error of the locations an artifact with fail fast mirrors was not saved to, because it
+// failed to be saved to another location first
+var ErrMirrorSkipped = stderrors.New("not saved, as saving to another location failed")
+
+// MirrorError is the error of saving an artifact with mirrors which failed to be saved to some of its locations.
+// errors.Is and errors.As find the error of the first location it failed to be saved to.
+type MirrorError struct {
+	// Artifact is the name of the artifact
+	Artifact string
+	// Locations is the number of locations of the artifact, including its own
+	Locations int
+	// Failures are the locations the artifact was not saved to, in the order of its locations
+	Failures []MirrorFailure
+}
+
+// MirrorFailure is a location an artifact was not saved to
+type MirrorFailure struct {
+	// Location is "location" for the own location of the artifact, or e.g. "mirrors.locations[0]" for a mirror
+	Location string
+	// Err is the error of saving the artifact to the location, or ErrMirrorSkipped if it was not saved to it
+	Err error
+}
+
+// Error returns e.g. "artifact my-art was saved to 1 of 2 locations: mirrors.locations[0]: gcs save my-key: denied"
+func (e *MirrorError) Error() string {
+	failures := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		failures[i] = fmt.Sprintf("%s: %v", f.Location, f.Err)
+	}
+	return fmt.Sprintf("artifact %s was saved to %d of %d locations: %s", e.Artifact, e.Locations-len(e.Failures), e.Locations, strings.Join(failures, "; "))
+}
+
+func (e *MirrorError) Unwrap() error {
+	for _, f := range e.Failures {
+		if f.Err != ErrMirrorSkipped {
+			return f.Err
+		}
+	}
+	return nil
+}
+
+// mirrorDestination is a location an artifact is saved to
+type mirrorDestination struct {
+	// location is the name of the location in errors
+	location string
+	art      *wfv1.Artifact
+}
+
+// mirrorDestinations returns the own location of the artifact, and then its mirror locations, each as a copy of the
+// artifact with that location and without mirrors
+func mirrorDestinations(outputArtifact *wfv1.Artifact) []mirrorDestination {
+	destinations := []mirrorDestination{{location: "location", art: outputArtifact}}
+	for i, location := range outputArtifact.Mirrors.Locations {
+		art := outputArtifact.DeepCopy()
+		art.Mirrors = nil
+		art.ArtifactLocation = *location.DeepCopy()
+		destinations = append(destinations, mirrorDestination{location: fmt.Sprintf("mirrors.locations[%d]", i), art: art})
+	}
+	return destinations
+}
+
+// SaveMirrored saves the output artifact to its location with the driver, and to each of its mirror locations with
+// a driver NewDriver creates with ri, each as SaveWithProgress does, reporting the progress of only its own
+// location. An artifact without mirrors is only saved with the driver. It succeeds only once the artifact is saved
+// to every location, and otherwise the error is a *MirrorError with the error of each location it was not saved to.
+func SaveMirrored(ctx context.Context, driver ArtifactDriver, ri resource.Interface, path string, outputArtifact *wfv1.Artifact, progress common.ProgressFunc) error {
+	if outputArtifact.Mirrors == nil || len(outputArtifact.Mirrors.Locations) == 0 {
+		return SaveWithProgress(ctx, driver, path, outputArtifact, progress)
+	}
+	mirrors := outputArtifact.Mirrors
+	parallelism := 1
+	if mirrors.Parallelism != nil && *mirrors.Parallelism > 1 {
+		parallelism = int(*mirrors.Parallelism)
+	}
+	// the saves left are cancelled once one fails, if the mirrors fail fast
+	saveCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	destinations := mirrorDestinations(outputArtifact)
+	errs := make([]error, len(destinations))
+	var lock sync.Mutex
+	failed := false
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, d := range destinations {
+		sem <- struct{}{}
+		lock.Lock()
+		skip := mirrors.FailFast && failed
+		lock.Unlock()
+		if skip {
+			<-sem
+			errs[i] = ErrMirrorSkipped
+			continue
+		}
+		wg.Add(1)
+		go func(i int, d mirrorDestination) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			err := saveDestination(saveCtx, driver, ri, path, d, i, progress)
+			if err == nil {
+				return
+			}
+			if mirrors.FailFast && ctx.Err() == nil && saveCtx.Err() != nil && stderrors.Is(err, context.Canceled) {
+				err = ErrMirrorSkipped
+			} else {
+				driverLogger(driver, outputArtifact).Warnf("Failed to save artifact %s to its %s: %v", outputArtifact.Name, d.location, err)
+			}
+			lock.Lock()
+			failed = true
+			errs[i] = err
+			lock.Unlock()
+			if mirrors.FailFast {
+				cancel()
+			}
+		}(i, d)
+	}
+	wg.Wait()
+	mirrorErr := &MirrorError{Artifact: outputArtifact.Name, Locations: len(destinations)}
+	for i, err := range errs {
+		if err != nil {
+			mirrorErr.Failures = append(mirrorErr.Failures, MirrorFailure{Location: destinations[i].location, Err: err})
+		}
+	}
+	if len(mirrorErr.Failures) > 0 {
+		return mirrorErr
+	}
+	return nil
+}
+
+// saveDestination saves the artifact to the i-th of its destinations, the first of which is its own location
+func saveDestination(ctx context.Context, driver ArtifactDriver, ri resource.Interface, path string, d mirrorDestination, i int, progress common.ProgressFunc) error {
+	if i == 0 {
+		return SaveWithProgress(ctx, driver, path, d.art, progress)
+	}
+	mirrorDriver, err := NewDriver(ctx, d.art, ri)
+	if err != nil {
+		return err
+	}
+	return SaveWithProgress(ctx, mirrorDriver, path, d.art, nil)
+}
@@ -0,0 +1,191 @@
+package executor
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/memory"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/resource"
+)
+
+// registerMirrorDriver registers the driver as that of GCS and OSS artifacts, which are the mirrors of the tests,
+// until the returned function restores the builtin drivers
+func registerMirrorDriver(driver ArtifactDriver) (restore func()) {
+	gcsDriver, ossDriver := drivers[DriverGCS], drivers[DriverOSS]
+	fn := func(context.Context, *wfv1.Artifact, resource.Interface) (ArtifactDriver, error) { return driver, nil }
+	RegisterDriver(DriverGCS, fn)
+	RegisterDriver(DriverOSS, fn)
+	return func() {
+		RegisterDriver(DriverGCS, gcsDriver)
+		RegisterDriver(DriverOSS, ossDriver)
+	}
+}
+
+func newMirroredArtifact(mirrors *wfv1.ArtifactMirrors) *wfv1.Artifact {
+	mirrors.Locations = []wfv1.ArtifactLocation{
+		{GCS: &wfv1.GCSArtifact{GCSBucket: wfv1.GCSBucket{Bucket: "my-bucket"}, Key: "gcs-key"}},
+		{OSS: &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{Bucket: "my-bucket"}, Key: "oss-key"}},
+	}
+	return &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "my-key"}}, Mirrors: mirrors}
+}
+
+// recordingDriver records the keys of the artifacts it saves, in the order it saves them
+type recordingDriver struct {
+	ArtifactDriver
+	lock  *sync.Mutex
+	saved *[]string
+}
+
+func (d *recordingDriver) Save(_ context.Context, _ string, outputArtifact *wfv1.Artifact) error {
+	key, err := outputArtifact.GetKey()
+	if err != nil {
+		return err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	*d.saved = append(*d.saved, key)
+	return nil
+}
+
+// barrierDriver saves only once the other saves of the group start, before a timeout
+type barrierDriver struct {
+	ArtifactDriver
+	group *sync.WaitGroup
+}
+
+func (d *barrierDriver) Save(context.Context, string, *wfv1.Artifact) error {
+	d.group.Done()
+	started := make(chan struct{})
+	go func() {
+		d.group.Wait()
+		close(started)
+	}()
+	select {
+	case <-started:
+		return nil
+	case <-time.After(10 * time.Second):
+		return fmt.Errorf("the other saves did not start")
+	}
+}
+
+func TestSaveMirrored(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "artifact")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	path := filepath.Join(tmp, "my-file")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("my-data"), 0600))
+	ctx := context.Background()
+
+	t.Run("Saved", func(t *testing.T) {
+		primary, mirror := memory.NewArtifactDriver(), memory.NewArtifactDriver()
+		defer registerMirrorDriver(mirror)()
+		progressed := false
+		err := SaveMirrored(ctx, primary, fakeResources{}, path, newMirroredArtifact(&wfv1.ArtifactMirrors{}), func(int64, int64) { progressed = true })
+		if assert.NoError(t, err) {
+			for driver, url := range map[*memory.ArtifactDriver]string{primary: "memory://my-key", mirror: "memory://gcs-key"} {
+				data, ok := driver.Content(url)
+				if assert.True(t, ok, url) {
+					assert.Equal(t, "my-data", string(data))
+				}
+			}
+			data, _ := mirror.Content("memory://oss-key")
+			assert.Equal(t, "my-data", string(data))
+		}
+		assert.True(t, progressed)
+	})
+	t.Run("ExpandEnv", func(t *testing.T) {
+		var lock sync.Mutex
+		var saved []string
+		defer registerMirrorDriver(&recordingDriver{lock: &lock, saved: &saved})()
+		assert.NoError(t, os.Setenv("WORKSPACE", "workspaces/1"))
+		defer func() { _ = os.Unsetenv("WORKSPACE") }()
+		art := &wfv1.Artifact{
+			Name:             "my-art",
+			ExpandEnv:        true,
+			ArtifactLocation: wfv1.ArtifactLocation{GCS: &wfv1.GCSArtifact{GCSBucket: wfv1.GCSBucket{Bucket: "my-bucket"}, Key: "${WORKSPACE}/gcs-key"}},
+			Mirrors: &wfv1.ArtifactMirrors{Locations: []wfv1.ArtifactLocation{
+				{OSS: &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{Bucket: "my-bucket"}, Key: "${WORKSPACE}/oss-key"}},
+			}},
+		}
+		// the mirrors are copies of the artifact its driver is created with, which expands its environment variables
+		driver, err := NewDriver(ctx, art, fakeResources{})
+		if assert.NoError(t, err) && assert.NoError(t, SaveMirrored(ctx, driver, fakeResources{}, path, art, nil)) {
+			assert.Equal(t, []string{"workspaces/1/gcs-key", "workspaces/1/oss-key"}, saved)
+		}
+	})
+	t.Run("Failed", func(t *testing.T) {
+		primary := memory.NewArtifactDriver()
+		original := &quotaError{bucket: "my-bucket"}
+		defer registerMirrorDriver(&failingDriver{err: original})()
+		err := SaveMirrored(ctx, primary, fakeResources{}, path, newMirroredArtifact(&wfv1.ArtifactMirrors{}), nil)
+		var mirrorErr *MirrorError
+		if assert.True(t, stderrors.As(err, &mirrorErr)) {
+			// every location is saved to, even once one fails
+			if assert.Len(t, mirrorErr.Failures, 2) {
+				assert.Equal(t, "mirrors.locations[0]", mirrorErr.Failures[0].Location)
+				assert.Equal(t, "mirrors.locations[1]", mirrorErr.Failures[1].Location)
+			}
+			assert.Equal(t, "artifact my-art was saved to 1 of 3 locations: "+
+				"mirrors.locations[0]: gcs save gcs-key: the quota of my-bucket is exceeded; "+
+				"mirrors.locations[1]: oss save oss-key: the quota of my-bucket is exceeded", err.Error())
+		}
+		assert.True(t, stderrors.Is(err, original))
+		var driverErr *DriverError
+		if assert.True(t, stderrors.As(err, &driverErr)) {
+			assert.Equal(t, DriverGCS, driverErr.Driver)
+		}
+		_, ok := primary.Content("memory://my-key")
+		assert.True(t, ok)
+	})
+	t.Run("FailFast", func(t *testing.T) {
+		var lock sync.Mutex
+		var saved []string
+		defer registerMirrorDriver(&recordingDriver{lock: &lock, saved: &saved})()
+		original := &quotaError{bucket: "my-bucket"}
+		err := SaveMirrored(ctx, &failingDriver{err: original}, fakeResources{}, path, newMirroredArtifact(&wfv1.ArtifactMirrors{FailFast: true}), nil)
+		var mirrorErr *MirrorError
+		if assert.True(t, stderrors.As(err, &mirrorErr)) && assert.Len(t, mirrorErr.Failures, 3) {
+			assert.True(t, stderrors.Is(mirrorErr.Failures[0].Err, original))
+			assert.Equal(t, ErrMirrorSkipped, mirrorErr.Failures[1].Err)
+			assert.Equal(t, ErrMirrorSkipped, mirrorErr.Failures[2].Err)
+		}
+		assert.True(t, stderrors.Is(err, original))
+		assert.Empty(t, saved)
+	})
+	t.Run("InOrder", func(t *testing.T) {
+		var lock sync.Mutex
+		var saved []string
+		driver := &recordingDriver{lock: &lock, saved: &saved}
+		defer registerMirrorDriver(driver)()
+		assert.NoError(t, SaveMirrored(ctx, driver, fakeResources{}, path, newMirroredArtifact(&wfv1.ArtifactMirrors{}), nil))
+		assert.Equal(t, []string{"my-key", "gcs-key", "oss-key"}, saved)
+	})
+	t.Run("Parallelism", func(t *testing.T) {
+		// the saves wait for each other, so they only succeed if they are at once
+		var group sync.WaitGroup
+		group.Add(3)
+		driver := &barrierDriver{group: &group}
+		defer registerMirrorDriver(driver)()
+		parallelism := int32(3)
+		assert.NoError(t, SaveMirrored(ctx, driver, fakeResources{}, path, newMirroredArtifact(&wfv1.ArtifactMirrors{Parallelism: &parallelism}), nil))
+	})
+	t.Run("NoMirrors", func(t *testing.T) {
+		primary := memory.NewArtifactDriver()
+		art := &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "my-key"}}}
+		assert.NoError(t, SaveMirrored(ctx, primary, fakeResources{}, path, art, nil))
+		_, ok := primary.Content("memory://my-key")
+		assert.True(t, ok)
+	})
+}
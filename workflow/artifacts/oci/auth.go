@@ -0,0 +1,168 @@
+package oci
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+)
+
+// dockerHubHost is the host of the API of Docker Hub, whose repositories are those of docker.io
+const dockerHubHost = "registry-1.docker.io"
+
+// challengeParamRegex matches a parameter of a challenge, whose value is either a quoted string or a token
+var challengeParamRegex = regexp.MustCompile(`(\w+)=("(?:[^"\\]|\\.)*"|([^,\s]*))`)
+
+// dockerAuth is the entry of a registry in a docker config, with either its username and password, or their base64
+// encoding, joined by a colon, as auth
+type dockerAuth struct {
+	Auth     string `json:"auth"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// registryKey returns the host of a key of the auths of a docker config, which may be a URL, e.g.
+// https://index.docker.io/v1/, with the hosts of Docker Hub as docker.io
+func registryKey(key string) string {
+	if i := strings.Index(key, "://"); i >= 0 {
+		key = key[i+3:]
+	}
+	if i := strings.Index(key, "/"); i >= 0 {
+		key = key[:i]
+	}
+	switch key {
+	case "index.docker.io", dockerHubHost:
+		return "docker.io"
+	}
+	return key
+}
+
+// parseDockerConfig returns the username and password of the registry in the docker config, which is either a
+// config.json, e.g. the .dockerconfigjson of a secret, whose registries are its auths, or a legacy .dockercfg, whose
+// registries are its top-level keys. It returns no credentials if the config has none of the registry.
+func parseDockerConfig(config, registry string) (username, password string, err error) {
+	var auths struct {
+		Auths map[string]dockerAuth `json:"auths"`
+	}
+	if err := json.Unmarshal([]byte(config), &auths); err != nil {
+		return "", "", errors.Errorf(errors.CodeBadRequest, "the OCI credentials are not a docker config: %v", err)
+	}
+	if auths.Auths == nil {
+		if err := json.Unmarshal([]byte(config), &auths.Auths); err != nil {
+			return "", "", errors.Errorf(errors.CodeBadRequest, "the OCI credentials are not a docker config: %v", err)
+		}
+	}
+	for key, auth := range auths.Auths {
+		if registryKey(key) != registryKey(registry) {
+			continue
+		}
+		if auth.Auth == "" {
+			return auth.Username, auth.Password, nil
+		}
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return "", "", errors.Errorf(errors.CodeBadRequest, "the auth of %s in the docker config is not base64", key)
+		}
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 {
+			return "", "", errors.Errorf(errors.CodeBadRequest, "the auth of %s in the docker config is not a username and password", key)
+		}
+		return parts[0], parts[1], nil
+	}
+	return "", "", nil
+}
+
+// challenge is the scheme and parameters of a WWW-Authenticate header, e.g. Bearer realm="...",service="..."
+type challenge struct {
+	scheme string
+	params map[string]string
+}
+
+// parseChallenge parses the WWW-Authenticate header of a response
+func parseChallenge(header string) challenge {
+	c := challenge{params: map[string]string{}}
+	parts := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	c.scheme = strings.ToLower(parts[0])
+	if len(parts) == 2 {
+		for _, m := range challengeParamRegex.FindAllStringSubmatch(parts[1], -1) {
+			value := m[3]
+			if strings.HasPrefix(m[2], `"`) {
+				value = strings.ReplaceAll(m[2][1:len(m[2])-1], `\"`, `"`)
+			}
+			c.params[strings.ToLower(m[1])] = value
+		}
+	}
+	return c
+}
+
+// authenticate authenticates the client as the challenge of a response with the 401 status asks, by fetching a token
+// of the scope of its actions on the repository from the realm of a bearer challenge, with its credentials if it has
+// them, or by sending them with every request for a basic challenge
+func (c *client) authenticate(header string) error {
+	ch := parseChallenge(header)
+	switch ch.scheme {
+	case "basic":
+		if c.username == "" {
+			return errors.Errorf(errors.CodeUnauthorized, "OCI registry %s requires credentials", c.host)
+		}
+		c.basic = true
+		return nil
+	case "bearer":
+	default:
+		return errors.Errorf(errors.CodeUnauthorized, "OCI registry %s responded with an unsupported challenge %q", c.host, header)
+	}
+	realm, err := url.Parse(ch.params["realm"])
+	if err != nil || realm.Scheme == "" || realm.Host == "" {
+		return errors.Errorf(errors.CodeUnauthorized, "OCI registry %s responded with a bearer challenge without a realm", c.host)
+	}
+	query := realm.Query()
+	if service := ch.params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	query.Set("scope", "repository:"+c.name+":"+c.actions)
+	realm.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf(errors.CodeUnauthorized, "OCI registry %s refused a token of %s: %v", c.host, query.Get("scope"), decodeError(resp))
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return err
+	}
+	c.token = token.Token
+	if c.token == "" {
+		c.token = token.AccessToken
+	}
+	if c.token == "" {
+		return errors.Errorf(errors.CodeUnauthorized, "OCI registry %s responded with no token", c.host)
+	}
+	return nil
+}
+
+// authorize authorizes the request with the token of the client, or its credentials if the registry asked for them
+func (c *client) authorize(req *http.Request) {
+	switch {
+	case c.token != "":
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	case c.basic:
+		req.SetBasicAuth(c.username, c.password)
+	}
+}
@@ -0,0 +1,398 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const (
+	mediaTypeImageManifest  = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeImageIndex     = "application/vnd.oci.image.index.v1+json"
+	mediaTypeDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+	// the media type of the empty config of artifacts, whose content is {}
+	mediaTypeEmpty = "application/vnd.oci.empty.v1+json"
+	// the media types of the layers of files and of directories, which are tarred and gzipped
+	mediaTypeLayer     = "application/vnd.oci.image.layer.v1.tar"
+	mediaTypeLayerGzip = "application/vnd.oci.image.layer.v1.tar+gzip"
+	// the type of the artifacts ORAS pushes without a type
+	artifactTypeUnknown = "application/vnd.unknown.artifact.v1"
+	// the annotation of the name of the file or directory of a layer, which ORAS pulls it to
+	annotationTitle = "org.opencontainers.image.title"
+	// the annotation of the layers of directories, which ORAS unpacks once it pulls them
+	annotationUnpack = "io.deis.oras.content.unpack"
+	// the header registries return the digest of manifests in
+	digestHeader = "Docker-Content-Digest"
+	// the largest manifest which is pulled, as registries reject larger ones
+	maxManifestSize = 4 * 1024 * 1024
+)
+
+// emptyConfig is the content of the config of artifacts
+var emptyConfig = []byte("{}")
+
+// digestRegex matches the SHA-256 digests of manifests and blobs
+var digestRegex = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// manifestMediaTypes are the media types of the manifests which are pulled, in the Accept header of their requests
+var manifestMediaTypes = strings.Join([]string{mediaTypeImageManifest, mediaTypeDockerManifest, mediaTypeImageIndex, mediaTypeDockerList}, ", ")
+
+// descriptor describes the content of a blob
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// manifest is an OCI image manifest, whose layers are the files and directories of an artifact
+type manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        descriptor        `json:"config"`
+	Layers        []descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// registryError is a response of a registry with an error status, with the errors of its body
+type registryError struct {
+	Status int
+	Errors []struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func (e *registryError) Error() string {
+	var messages []string
+	for _, err := range e.Errors {
+		messages = append(messages, fmt.Sprintf("%s: %s", err.Code, err.Message))
+	}
+	if len(messages) == 0 {
+		return fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status))
+	}
+	return fmt.Sprintf("%s (%d)", strings.Join(messages, "; "), e.Status)
+}
+
+// decodeError returns the error of a response, which is a not found error for the 404 status
+func decodeError(resp *http.Response) error {
+	e := &registryError{Status: resp.StatusCode}
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	_ = json.Unmarshal(data, e)
+	if resp.StatusCode == http.StatusNotFound {
+		return common.NewNotFoundError(e)
+	}
+	return e
+}
+
+// digestOf returns the SHA-256 digest of the data
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// fileDigest returns the SHA-256 digest and size of the file
+func fileDigest(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// client is a client of a repository of a registry, with the distribution API. It authenticates once a request is
+// challenged, and then authorizes every request.
+type client struct {
+	ctx        context.Context
+	httpClient *http.Client
+	// the base URL of the API of the registry, e.g. https://ghcr.io/v2, and its host
+	baseURL string
+	host    string
+	// the name of the repository in the registry, e.g. my-org/my-model
+	name string
+	// the actions on the repository the tokens of the client are of, e.g. pull,push
+	actions            string
+	username, password string
+	token              string
+	// whether the registry asked for the credentials with a basic challenge
+	basic bool
+}
+
+func newClient(ctx context.Context, transport http.RoundTripper, host, name string, insecure bool, actions string) *client {
+	scheme := "https"
+	if insecure {
+		scheme = "http"
+	}
+	return &client{
+		ctx: ctx,
+		httpClient: &http.Client{
+			Transport: transport,
+			// blobs may be redirected to storage, e.g. S3, which the credentials are not sent to
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 10 {
+					return stderrors.New("stopped after 10 redirects")
+				}
+				if req.URL.Host != host {
+					req.Header.Del("Authorization")
+				}
+				return nil
+			},
+		},
+		baseURL: scheme + "://" + host + "/v2",
+		host:    host,
+		name:    name,
+		actions: actions,
+	}
+}
+
+// url returns the URL of a manifest or blob of the repository, e.g. manifests/latest
+func (c *client) url(p string) string {
+	return c.baseURL + "/" + c.name + "/" + p
+}
+
+// send sends the request newRequest returns, and returns the response, or the error the registry responded with.
+// The request is sent again, with a new body, once the client authenticates, if the registry challenges it.
+func (c *client) send(newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for authenticated := false; ; authenticated = true {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		c.authorize(req)
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && !authenticated {
+			header := resp.Header.Get("WWW-Authenticate")
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			_ = resp.Body.Close()
+			if err := c.authenticate(header); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			defer func() { _ = resp.Body.Close() }()
+			return nil, decodeError(resp)
+		}
+		return resp, nil
+	}
+}
+
+// do sends a request without a body, and discards the body of its response
+func (c *client) do(method, u string, header http.Header) (*http.Response, error) {
+	resp, err := c.send(func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(c.ctx, method, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return resp, nil
+}
+
+// resolve returns the digest of the manifest of the reference, a tag or a digest
+func (c *client) resolve(reference string) (string, error) {
+	resp, err := c.do(http.MethodHead, c.url("manifests/"+reference), http.Header{"Accept": {manifestMediaTypes}})
+	if err != nil {
+		return "", err
+	}
+	if digest := resp.Header.Get(digestHeader); digest != "" {
+		return digest, nil
+	}
+	// the header is optional, so the manifest is pulled to compute its digest
+	_, digest, err := c.getManifest(reference)
+	return digest, err
+}
+
+// getManifest pulls the image manifest of the reference, a tag or a digest, and returns it with its digest. The
+// manifest of a digest is verified against it.
+func (c *client) getManifest(reference string) (*manifest, string, error) {
+	resp, err := c.send(func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, c.url("manifests/"+reference), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", manifestMediaTypes)
+		return req, nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxManifestSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxManifestSize {
+		return nil, "", fmt.Errorf("OCI manifest %s of %s is larger than %d bytes", reference, c.name, maxManifestSize)
+	}
+	digest := digestOf(data)
+	if strings.HasPrefix(reference, "sha256:") && digest != reference {
+		return nil, "", fmt.Errorf("OCI manifest %s of %s has the digest %s", reference, c.name, digest)
+	}
+	m := &manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, "", fmt.Errorf("OCI manifest %s of %s is invalid: %w", reference, c.name, err)
+	}
+	mediaType := m.MediaType
+	if mediaType == "" {
+		mediaType = strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0])
+	}
+	switch mediaType {
+	case mediaTypeImageManifest, mediaTypeDockerManifest:
+	case mediaTypeImageIndex, mediaTypeDockerList:
+		return nil, "", errors.Errorf(errors.CodeBadRequest, "OCI manifest %s of %s is an index, rather than the manifest of an artifact", reference, c.name)
+	default:
+		return nil, "", errors.Errorf(errors.CodeBadRequest, "OCI manifest %s of %s has the unsupported media type %q", reference, c.name, mediaType)
+	}
+	return m, digest, nil
+}
+
+// putManifest pushes the manifest to the tag, and returns its digest
+func (c *client) putManifest(tag string, m *manifest) (string, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.send(func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(c.ctx, http.MethodPut, c.url("manifests/"+tag), bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", m.MediaType)
+		return req, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	_ = resp.Body.Close()
+	digest := digestOf(data)
+	if header := resp.Header.Get(digestHeader); header != "" && header != digest {
+		return "", fmt.Errorf("OCI registry %s stored the manifest %s of %s as %s", c.host, digest, c.name, header)
+	}
+	return digest, nil
+}
+
+// deleteManifest deletes the manifest of the digest, and so its tags
+func (c *client) deleteManifest(digest string) error {
+	_, err := c.do(http.MethodDelete, c.url("manifests/"+digest), nil)
+	return err
+}
+
+// blobExists returns whether the repository has the blob
+func (c *client) blobExists(digest string) (bool, error) {
+	_, err := c.do(http.MethodHead, c.url("blobs/"+digest), nil)
+	if stderrors.Is(err, common.ErrArtifactNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// pullBlob pulls the blob to the file at path, which is removed unless its content has the digest and size of desc
+func (c *client) pullBlob(desc descriptor, path string) error {
+	if !digestRegex.MatchString(desc.Digest) {
+		return errors.Errorf(errors.CodeBadRequest, "OCI blob of %s has the unsupported digest %q", c.name, desc.Digest)
+	}
+	resp, err := c.send(func() (*http.Request, error) {
+		return http.NewRequestWithContext(c.ctx, http.MethodGet, c.url("blobs/"+desc.Digest), nil)
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && ("sha256:"+hex.EncodeToString(h.Sum(nil)) != desc.Digest || size != desc.Size) {
+		err = fmt.Errorf("OCI blob %s of %s does not match its digest and size", desc.Digest, c.name)
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// pushBlob pushes the blob of desc, whose content open returns, unless the repository already has it
+func (c *client) pushBlob(desc descriptor, open func() (io.ReadCloser, error)) error {
+	exists, err := c.blobExists(desc.Digest)
+	if err != nil || exists {
+		return err
+	}
+	resp, err := c.do(http.MethodPost, c.url("blobs/uploads/"), nil)
+	if err != nil {
+		return err
+	}
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.Header.Get("Location") == "" {
+		return fmt.Errorf("OCI registry %s started an upload without a location", c.host)
+	}
+	query := location.Query()
+	query.Set("digest", desc.Digest)
+	location.RawQuery = query.Encode()
+	resp, err = c.send(func() (*http.Request, error) {
+		body, err := open()
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(c.ctx, http.MethodPut, location.String(), body)
+		if err != nil {
+			_ = body.Close()
+			return nil, err
+		}
+		req.ContentLength = desc.Size
+		req.Header.Set("Content-Type", "application/octet-stream")
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("OCI push of blob %s to %s failed: %w", desc.Digest, c.name, err)
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+// openFile returns a function which opens the file, for pushBlob
+func openFile(path string) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) { return os.Open(path) }
+}
+
+// openBytes returns a function which reads the data, for pushBlob
+func openBytes(data []byte) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader(data)), nil }
+}
@@ -0,0 +1,257 @@
+// Package oci pulls and pushes artifacts from and to the repositories of OCI registries, e.g. GHCR, ECR or Harbor, as
+// ORAS does, so that the artifacts of workflows and those of ORAS are interchangeable.
+package oci
+
+import (
+	"compress/gzip"
+	"context"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/util/archive"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const defaultTag = "latest"
+
+var (
+	// nameRegex matches the names of repositories in their registries, e.g. my-org/my-model
+	nameRegex = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+	// tagRegex matches tags, e.g. v1.0
+	tagRegex = regexp.MustCompile(`^\w[\w.-]{0,127}$`)
+)
+
+// ArtifactDriver is the artifact driver for OCI registries. A file is pushed as the single layer of the manifest of an
+// artifact, and a directory as a tar.gz layer, which is unpacked once it is pulled, with the name of the file or
+// directory as their title.
+type ArtifactDriver struct {
+	// DockerConfig is the docker config with the credentials of the registry, if set
+	DockerConfig string
+	common.Logging
+	common.Transporting
+}
+
+// ValidateArtifact validates the OCI artifact, beyond what the general artifact validation checks
+func ValidateArtifact(errPrefix string, art *wfv1.OCIArtifact) error {
+	if _, _, err := parseRepository(art.Repository); err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s.repository must be a repository with the host of its registry, e.g. ghcr.io/my-org/my-model", errPrefix)
+	}
+	if art.Tag != "" && !tagRegex.MatchString(art.Tag) {
+		return errors.Errorf(errors.CodeBadRequest, "%s.tag %q is not a valid tag", errPrefix, art.Tag)
+	}
+	if art.Digest != "" && !digestRegex.MatchString(art.Digest) {
+		return errors.Errorf(errors.CodeBadRequest, "%s.digest must be a SHA-256 digest, e.g. sha256:<64 hex characters>", errPrefix)
+	}
+	return nil
+}
+
+// parseRepository returns the host of the API of the registry of the repository, and the name of the repository in
+// it. The repositories of docker.io are those of Docker Hub, where a single name is that of an official image.
+func parseRepository(repository string) (host, name string, err error) {
+	parts := strings.SplitN(repository, "/", 2)
+	if len(parts) != 2 || !(strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return "", "", errors.Errorf(errors.CodeBadRequest, "OCI repository %q has no registry host", repository)
+	}
+	host, name = parts[0], parts[1]
+	if registryKey(host) == "docker.io" {
+		host = dockerHubHost
+		if !strings.Contains(name, "/") {
+			name = "library/" + name
+		}
+	}
+	if !nameRegex.MatchString(name) {
+		return "", "", errors.Errorf(errors.CodeBadRequest, "OCI repository %q has an invalid name", repository)
+	}
+	return host, name, nil
+}
+
+// reference returns the digest of the artifact, if it is pinned to one, or otherwise its tag
+func reference(art *wfv1.OCIArtifact) string {
+	switch {
+	case art.Digest != "":
+		return art.Digest
+	case art.Tag != "":
+		return art.Tag
+	}
+	return defaultTag
+}
+
+// newClient returns a client of the repository of the artifact, whose tokens are of the actions
+func (d *ArtifactDriver) newClient(ctx context.Context, art *wfv1.OCIArtifact, actions string) (*client, error) {
+	host, name, err := parseRepository(art.Repository)
+	if err != nil {
+		return nil, err
+	}
+	c := newClient(ctx, d.RoundTripper(nil), host, name, art.Insecure, actions)
+	if d.DockerConfig != "" {
+		c.username, c.password, err = parseDockerConfig(d.DockerConfig, strings.SplitN(art.Repository, "/", 2)[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Load pulls the manifest of the digest of the artifact, if it is pinned to one, or otherwise of its tag, and then its
+// layers. A single layer is pulled to the path, which is a directory if it is unpacked, and several to the paths of
+// their titles under it.
+func (d *ArtifactDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, path string) error {
+	art := inputArtifact.OCI
+	ref := reference(art)
+	d.Log().Infof("OCI Load path: %s, repository: %s, reference: %s", path, art.Repository, ref)
+	c, err := d.newClient(ctx, art, "pull")
+	if err != nil {
+		return err
+	}
+	m, digest, err := c.getManifest(ref)
+	if err != nil {
+		return err
+	}
+	d.Log().Infof("OCI pulling %d layer(s) of %s@%s", len(m.Layers), art.Repository, digest)
+	switch len(m.Layers) {
+	case 0:
+		return errors.Errorf(errors.CodeBadRequest, "OCI artifact %s@%s has no layers", art.Repository, digest)
+	case 1:
+		return d.loadLayer(c, m.Layers[0], path)
+	}
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return err
+	}
+	for _, layer := range m.Layers {
+		title := layer.Annotations[annotationTitle]
+		if title == "" || filepath.Base(title) != title || title == "." || title == ".." {
+			return errors.Errorf(errors.CodeBadRequest, "OCI layer %s of %s@%s has no title it can be pulled to", layer.Digest, art.Repository, digest)
+		}
+		if err := d.loadLayer(c, layer, filepath.Join(path, title)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadLayer pulls the blob of the layer to the path, or to a temporary file which is unpacked to it for the layers of
+// directories
+func (d *ArtifactDriver) loadLayer(c *client, layer descriptor, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if layer.Annotations[annotationUnpack] != "true" {
+		return c.pullBlob(layer, path)
+	}
+	tmp, err := common.CreateTemp("oci-")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	_ = tmp.Close()
+	defer func() { _ = os.Remove(tmpPath) }()
+	if err := c.pullBlob(layer, tmpPath); err != nil {
+		return err
+	}
+	// the directory is the root of its archive, with its title as its name
+	return archive.ExtractPath(tmpPath, archive.FormatTarGz, layer.Annotations[annotationTitle], path)
+}
+
+// Save pushes the file, or the directory as a tar.gz, as the single layer of a manifest with an empty config, which
+// it pushes to the tag of the artifact, and then sets the digest of the artifact to that of the manifest, so that
+// what was pushed is what is pulled, even once the tag is pushed again
+func (d *ArtifactDriver) Save(ctx context.Context, path string, outputArtifact *wfv1.Artifact) error {
+	art := outputArtifact.OCI
+	tag := art.Tag
+	if tag == "" {
+		tag = defaultTag
+	}
+	d.Log().Infof("OCI Save path: %s, repository: %s, tag: %s", path, art.Repository, tag)
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	blob, mediaType := path, mediaTypeLayer
+	annotations := map[string]string{annotationTitle: filepath.Base(path)}
+	if info.IsDir() {
+		tmp, err := common.CreateTemp("oci-*.tar.gz")
+		if err != nil {
+			return err
+		}
+		blob, mediaType = tmp.Name(), mediaTypeLayerGzip
+		defer func() { _ = os.Remove(blob) }()
+		err = archive.TarGzToWriter(path, gzip.DefaultCompression, tmp)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		annotations[annotationUnpack] = "true"
+	}
+	digest, size, err := fileDigest(blob)
+	if err != nil {
+		return err
+	}
+	layer := descriptor{MediaType: mediaType, Digest: digest, Size: size, Annotations: annotations}
+	config := descriptor{MediaType: mediaTypeEmpty, Digest: digestOf(emptyConfig), Size: int64(len(emptyConfig))}
+	c, err := d.newClient(ctx, art, "pull,push")
+	if err != nil {
+		return err
+	}
+	if err := c.pushBlob(config, openBytes(emptyConfig)); err != nil {
+		return err
+	}
+	if err := c.pushBlob(layer, openFile(blob)); err != nil {
+		return err
+	}
+	m := &manifest{SchemaVersion: 2, MediaType: mediaTypeImageManifest, ArtifactType: artifactTypeUnknown, Config: config, Layers: []descriptor{layer}}
+	manifestDigest, err := c.putManifest(tag, m)
+	if err != nil {
+		return err
+	}
+	d.Log().Infof("OCI pushed %s to %s:%s as %s", path, art.Repository, tag, manifestDigest)
+	art.Digest = manifestDigest
+	return nil
+}
+
+// Exists returns whether the repository has the manifest of the digest of the artifact, or of its tag
+func (d *ArtifactDriver) Exists(artifact *wfv1.Artifact) (bool, error) {
+	c, err := d.newClient(context.Background(), artifact.OCI, "pull")
+	if err != nil {
+		return false, err
+	}
+	_, err = c.resolve(reference(artifact.OCI))
+	if stderrors.Is(err, common.ErrArtifactNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Delete deletes the manifest of the digest of the artifact, or of its tag, which deletes every tag of the manifest,
+// and is not an error if there is none. The registry garbage collects the blobs of the manifest.
+func (d *ArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	art := artifact.OCI
+	ref := reference(art)
+	d.Log().Infof("OCI Delete repository: %s, reference: %s", art.Repository, ref)
+	c, err := d.newClient(context.Background(), art, "delete")
+	if err != nil {
+		return err
+	}
+	digest, err := c.resolve(ref)
+	if err == nil {
+		err = c.deleteManifest(digest)
+	}
+	if stderrors.Is(err, common.ErrArtifactNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Capabilities returns the optional operations on OCI artifacts the driver supports
+func (d *ArtifactDriver) Capabilities() common.DriverCapabilities {
+	return common.DriverCapabilities{
+		Delete: true,
+		Exists: true,
+	}
+}
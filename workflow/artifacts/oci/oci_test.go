@@ -0,0 +1,483 @@
+package oci
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const (
+	testName     = "my-org/my-model"
+	testUsername = "my-user"
+	testPassword = "my-password"
+	testToken    = "my-token"
+)
+
+// fakeRegistry is the distribution API of a registry with a single repository, which authorizes requests with the
+// tokens of its token endpoint if it is private, and every push
+type fakeRegistry struct {
+	server *httptest.Server
+	mu     sync.Mutex
+	// the blobs and manifests of the repository, keyed by their digests, and the digests of its tags
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	tags      map[string]string
+	// the scopes of the tokens requested, and the number of blobs uploaded
+	scopes  []string
+	uploads int
+	// whether pulls must be authorized, whether the registry asks for credentials with a basic challenge rather than
+	// tokens, and whether blobs are served with content which does not match their digests
+	private, basic, corrupt bool
+}
+
+func newFakeRegistry() *fakeRegistry {
+	f := &fakeRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}, tags: map[string]string{}}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+func (f *fakeRegistry) Close() {
+	f.server.Close()
+}
+
+// repository returns the repository of the registry, with its host
+func (f *fakeRegistry) repository() string {
+	return strings.TrimPrefix(f.server.URL, "http://") + "/" + testName
+}
+
+func (f *fakeRegistry) artifact(tag, digest string) *wfv1.Artifact {
+	return &wfv1.Artifact{Name: "my-art", ArtifactLocation: wfv1.ArtifactLocation{
+		OCI: &wfv1.OCIArtifact{Repository: f.repository(), Tag: tag, Digest: digest, Insecure: true},
+	}}
+}
+
+// dockerConfig returns a docker config with the credentials of the registry, as the auth of its host
+func (f *fakeRegistry) dockerConfig() string {
+	auth := base64.StdEncoding.EncodeToString([]byte(testUsername + ":" + testPassword))
+	return fmt.Sprintf(`{"auths":{"%s":{"auth":"%s"}}}`, strings.TrimPrefix(f.server.URL, "http://"), auth)
+}
+
+// push stores the manifest of the layers, whose content is keyed by their titles, at the tag, and returns its digest
+func (f *fakeRegistry) push(tag string, layers map[string][]byte) string {
+	f.blobs[digestOf(emptyConfig)] = emptyConfig
+	m := manifest{SchemaVersion: 2, MediaType: mediaTypeImageManifest, Config: descriptor{MediaType: mediaTypeEmpty, Digest: digestOf(emptyConfig), Size: 2}}
+	for title, data := range layers {
+		f.blobs[digestOf(data)] = data
+		m.Layers = append(m.Layers, descriptor{MediaType: mediaTypeLayer, Digest: digestOf(data), Size: int64(len(data)), Annotations: map[string]string{annotationTitle: title}})
+	}
+	data, _ := json.Marshal(m)
+	f.manifests[digestOf(data)] = data
+	f.tags[tag] = digestOf(data)
+	return digestOf(data)
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": []map[string]string{{"code": code, "message": strings.ToLower(code)}}})
+}
+
+func (f *fakeRegistry) authorized(r *http.Request) bool {
+	if f.basic {
+		username, password, ok := r.BasicAuth()
+		return ok && username == testUsername && password == testPassword
+	}
+	return r.Header.Get("Authorization") == "Bearer "+testToken
+}
+
+func (f *fakeRegistry) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path == "/token" {
+		f.token(w, r)
+		return
+	}
+	push := r.Method != http.MethodGet && r.Method != http.MethodHead
+	if (f.private || push) && !f.authorized(r) {
+		if f.basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="fake"`)
+		} else {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="fake-registry",scope="repository:%s:pull"`, f.server.URL, testName))
+		}
+		writeError(w, http.StatusUnauthorized, "UNAUTHORIZED")
+		return
+	}
+	prefix := "/v2/" + testName + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		writeError(w, http.StatusNotFound, "NAME_UNKNOWN")
+		return
+	}
+	p := strings.TrimPrefix(r.URL.Path, prefix)
+	switch {
+	case strings.HasPrefix(p, "manifests/"):
+		f.manifest(w, r, strings.TrimPrefix(p, "manifests/"))
+	case p == "blobs/uploads/" && r.Method == http.MethodPost:
+		// the location is relative, and has a query the digest is added to
+		w.Header().Set("Location", fmt.Sprintf("%s/blobs/uploads/%d?state=my-state", strings.TrimSuffix(prefix, "/"), f.uploads))
+		w.WriteHeader(http.StatusAccepted)
+	case strings.HasPrefix(p, "blobs/uploads/") && r.Method == http.MethodPut:
+		f.upload(w, r)
+	case strings.HasPrefix(p, "blobs/"):
+		data, ok := f.blobs[strings.TrimPrefix(p, "blobs/")]
+		if !ok {
+			writeError(w, http.StatusNotFound, "BLOB_UNKNOWN")
+			return
+		}
+		if f.corrupt {
+			data = []byte(strings.ToUpper(string(data)))
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	default:
+		writeError(w, http.StatusNotFound, "NAME_UNKNOWN")
+	}
+}
+
+func (f *fakeRegistry) token(w http.ResponseWriter, r *http.Request) {
+	username, password, ok := r.BasicAuth()
+	if r.URL.Query().Get("service") != "fake-registry" || !ok || username != testUsername || password != testPassword {
+		writeError(w, http.StatusUnauthorized, "UNAUTHORIZED")
+		return
+	}
+	f.scopes = append(f.scopes, r.URL.Query().Get("scope"))
+	_ = json.NewEncoder(w).Encode(map[string]string{"token": testToken})
+}
+
+func (f *fakeRegistry) manifest(w http.ResponseWriter, r *http.Request, ref string) {
+	digest := ref
+	if !strings.HasPrefix(ref, "sha256:") {
+		digest = f.tags[ref]
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		data, ok := f.manifests[digest]
+		if !ok {
+			writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN")
+			return
+		}
+		if !strings.Contains(r.Header.Get("Accept"), mediaTypeImageManifest) {
+			writeError(w, http.StatusBadRequest, "MANIFEST_INVALID")
+			return
+		}
+		w.Header().Set("Content-Type", mediaTypeImageManifest)
+		w.Header().Set(digestHeader, digest)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	case http.MethodPut:
+		data, _ := ioutil.ReadAll(r.Body)
+		var m manifest
+		if r.Header.Get("Content-Type") != mediaTypeImageManifest || json.Unmarshal(data, &m) != nil {
+			writeError(w, http.StatusBadRequest, "MANIFEST_INVALID")
+			return
+		}
+		for _, desc := range append(m.Layers, m.Config) {
+			if _, ok := f.blobs[desc.Digest]; !ok {
+				writeError(w, http.StatusBadRequest, "MANIFEST_BLOB_UNKNOWN")
+				return
+			}
+		}
+		f.manifests[digestOf(data)] = data
+		f.tags[ref] = digestOf(data)
+		w.Header().Set(digestHeader, digestOf(data))
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		if _, ok := f.manifests[ref]; !ok {
+			writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN")
+			return
+		}
+		delete(f.manifests, ref)
+		for tag, digest := range f.tags {
+			if digest == ref {
+				delete(f.tags, tag)
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func (f *fakeRegistry) upload(w http.ResponseWriter, r *http.Request) {
+	data, _ := ioutil.ReadAll(r.Body)
+	digest := r.URL.Query().Get("digest")
+	if r.URL.Query().Get("state") != "my-state" || digest != digestOf(data) {
+		writeError(w, http.StatusBadRequest, "DIGEST_INVALID")
+		return
+	}
+	f.blobs[digest] = data
+	f.uploads++
+	w.WriteHeader(http.StatusCreated)
+}
+
+func tempDir(t *testing.T) string {
+	tmp, err := ioutil.TempDir("", "oci")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(tmp) })
+	return tmp
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestArtifactDriver_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeRegistry()
+	defer f.Close()
+	driver := &ArtifactDriver{DockerConfig: f.dockerConfig()}
+	t.Run("File", func(t *testing.T) {
+		tmp := tempDir(t)
+		src := filepath.Join(tmp, "model.bin")
+		assert.NoError(t, ioutil.WriteFile(src, []byte("my-model"), 0600))
+		art := f.artifact("v1", "")
+		if !assert.NoError(t, driver.Save(ctx, src, art)) {
+			return
+		}
+		// the digest of the artifact is that of the manifest of its tag
+		assert.Equal(t, f.tags["v1"], art.OCI.Digest)
+		var m manifest
+		assert.NoError(t, json.Unmarshal(f.manifests[art.OCI.Digest], &m))
+		assert.Equal(t, mediaTypeEmpty, m.Config.MediaType)
+		if assert.Len(t, m.Layers, 1) {
+			assert.Equal(t, mediaTypeLayer, m.Layers[0].MediaType)
+			assert.Equal(t, map[string]string{annotationTitle: "model.bin"}, m.Layers[0].Annotations)
+		}
+		for _, a := range []*wfv1.Artifact{f.artifact("v1", ""), art} {
+			dst := filepath.Join(tmp, "dst")
+			if assert.NoError(t, driver.Load(ctx, a, dst)) {
+				assert.Equal(t, "my-model", readFile(t, dst))
+			}
+			_ = os.Remove(dst)
+		}
+	})
+	t.Run("Directory", func(t *testing.T) {
+		tmp := tempDir(t)
+		src := filepath.Join(tmp, "src")
+		assert.NoError(t, os.MkdirAll(filepath.Join(src, "sub"), 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0600))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "sub", "b.txt"), []byte("b"), 0600))
+		art := f.artifact("", "")
+		if !assert.NoError(t, driver.Save(ctx, src, art)) {
+			return
+		}
+		assert.Equal(t, f.tags[defaultTag], art.OCI.Digest)
+		var m manifest
+		assert.NoError(t, json.Unmarshal(f.manifests[art.OCI.Digest], &m))
+		if assert.Len(t, m.Layers, 1) {
+			assert.Equal(t, mediaTypeLayerGzip, m.Layers[0].MediaType)
+			assert.Equal(t, map[string]string{annotationTitle: "src", annotationUnpack: "true"}, m.Layers[0].Annotations)
+		}
+		dst := filepath.Join(tmp, "dst")
+		if assert.NoError(t, driver.Load(ctx, f.artifact("", ""), dst)) {
+			assert.Equal(t, "a", readFile(t, filepath.Join(dst, "a.txt")))
+			assert.Equal(t, "b", readFile(t, filepath.Join(dst, "sub", "b.txt")))
+		}
+	})
+	t.Run("ExistingBlobs", func(t *testing.T) {
+		tmp := tempDir(t)
+		src := filepath.Join(tmp, "model.bin")
+		assert.NoError(t, ioutil.WriteFile(src, []byte("my-model"), 0600))
+		uploads := f.uploads
+		// the config and layer were pushed with the file
+		assert.NoError(t, driver.Save(ctx, src, f.artifact("v1-copy", "")))
+		assert.Equal(t, uploads, f.uploads)
+	})
+}
+
+func TestArtifactDriver_Load(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeRegistry()
+	defer f.Close()
+	driver := &ArtifactDriver{}
+	v1 := f.push("latest", map[string][]byte{"model.bin": []byte("my-model-v1")})
+	t.Run("DigestPinning", func(t *testing.T) {
+		tmp := tempDir(t)
+		// the tag is pushed again, which does not change what the digest pulls
+		f.push("latest", map[string][]byte{"model.bin": []byte("my-model-v2")})
+		if assert.NoError(t, driver.Load(ctx, f.artifact("latest", v1), filepath.Join(tmp, "pinned"))) {
+			assert.Equal(t, "my-model-v1", readFile(t, filepath.Join(tmp, "pinned")))
+		}
+		if assert.NoError(t, driver.Load(ctx, f.artifact("latest", ""), filepath.Join(tmp, "tagged"))) {
+			assert.Equal(t, "my-model-v2", readFile(t, filepath.Join(tmp, "tagged")))
+		}
+		// a manifest which does not match the digest it is pinned to is not pulled
+		f.manifests[v1] = []byte(strings.Replace(string(f.manifests[v1]), `"schemaVersion":2`, `"schemaVersion": 2`, 1))
+		err := driver.Load(ctx, f.artifact("latest", v1), filepath.Join(tmp, "tampered"))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "has the digest")
+		}
+		_, err = os.Stat(filepath.Join(tmp, "tampered"))
+		assert.True(t, os.IsNotExist(err))
+	})
+	t.Run("Layers", func(t *testing.T) {
+		tmp := tempDir(t)
+		// as ORAS pushes several files
+		f.push("files", map[string][]byte{"a.txt": []byte("a"), "b.txt": []byte("b")})
+		dst := filepath.Join(tmp, "dst")
+		if assert.NoError(t, driver.Load(ctx, f.artifact("files", ""), dst)) {
+			assert.Equal(t, "a", readFile(t, filepath.Join(dst, "a.txt")))
+			assert.Equal(t, "b", readFile(t, filepath.Join(dst, "b.txt")))
+		}
+		f.push("escape", map[string][]byte{"../a.txt": []byte("a"), "b.txt": []byte("b")})
+		assert.Error(t, driver.Load(ctx, f.artifact("escape", ""), filepath.Join(tmp, "escape")))
+		_, err := os.Stat(filepath.Join(tmp, "a.txt"))
+		assert.True(t, os.IsNotExist(err))
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		err := driver.Load(ctx, f.artifact("my-tag", ""), filepath.Join(tempDir(t), "dst"))
+		assert.True(t, errors.Is(err, common.ErrArtifactNotFound))
+	})
+	t.Run("CorruptBlob", func(t *testing.T) {
+		f.corrupt = true
+		defer func() { f.corrupt = false }()
+		dst := filepath.Join(tempDir(t), "dst")
+		err := driver.Load(ctx, f.artifact("latest", ""), dst)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "does not match its digest")
+		}
+		_, err = os.Stat(dst)
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+func TestArtifactDriver_Auth(t *testing.T) {
+	ctx := context.Background()
+	tmp := tempDir(t)
+	src := filepath.Join(tmp, "src")
+	assert.NoError(t, ioutil.WriteFile(src, []byte("my-model"), 0600))
+	t.Run("Bearer", func(t *testing.T) {
+		f := newFakeRegistry()
+		defer f.Close()
+		f.private = true
+		err := (&ArtifactDriver{}).Load(ctx, f.artifact("", ""), filepath.Join(tmp, "anonymous"))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "refused a token")
+		}
+		driver := &ArtifactDriver{DockerConfig: f.dockerConfig()}
+		if assert.NoError(t, driver.Save(ctx, src, f.artifact("", ""))) {
+			assert.Equal(t, []string{"repository:" + testName + ":pull,push"}, f.scopes)
+		}
+		if assert.NoError(t, driver.Load(ctx, f.artifact("", ""), filepath.Join(tmp, "bearer"))) {
+			assert.Equal(t, "my-model", readFile(t, filepath.Join(tmp, "bearer")))
+			assert.Equal(t, "repository:"+testName+":pull", f.scopes[1])
+		}
+	})
+	t.Run("Basic", func(t *testing.T) {
+		f := newFakeRegistry()
+		defer f.Close()
+		f.private, f.basic = true, true
+		err := (&ArtifactDriver{}).Save(ctx, src, f.artifact("", ""))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "requires credentials")
+		}
+		driver := &ArtifactDriver{DockerConfig: f.dockerConfig()}
+		assert.NoError(t, driver.Save(ctx, src, f.artifact("", "")))
+		if assert.NoError(t, driver.Load(ctx, f.artifact("", ""), filepath.Join(tmp, "basic"))) {
+			assert.Equal(t, "my-model", readFile(t, filepath.Join(tmp, "basic")))
+		}
+	})
+}
+
+func TestArtifactDriver_ExistsAndDelete(t *testing.T) {
+	f := newFakeRegistry()
+	defer f.Close()
+	driver := &ArtifactDriver{DockerConfig: f.dockerConfig()}
+	digest := f.push("v1", map[string][]byte{"model.bin": []byte("my-model")})
+	f.tags["stable"] = digest
+	exists, err := driver.Exists(f.artifact("v1", ""))
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = driver.Exists(f.artifact("v2", ""))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	// deleting the manifest of a tag deletes its other tags
+	assert.NoError(t, driver.Delete(f.artifact("v1", "")))
+	assert.Empty(t, f.tags)
+	exists, err = driver.Exists(f.artifact("", digest))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.NoError(t, driver.Delete(f.artifact("v1", "")))
+}
+
+func TestParseDockerConfig(t *testing.T) {
+	auth := base64.StdEncoding.EncodeToString([]byte("my-user:my:password"))
+	for name, tt := range map[string]struct {
+		config, registry   string
+		username, password string
+	}{
+		"Auth":                {`{"auths":{"ghcr.io":{"auth":"` + auth + `"}}}`, "ghcr.io", "my-user", "my:password"},
+		"UsernameAndPassword": {`{"auths":{"https://ghcr.io":{"username":"my-user","password":"my-password"}}}`, "ghcr.io", "my-user", "my-password"},
+		"DockerHub":           {`{"auths":{"https://index.docker.io/v1/":{"auth":"` + auth + `"}}}`, "docker.io", "my-user", "my:password"},
+		"Legacy":              {`{"ghcr.io":{"auth":"` + auth + `"}}`, "ghcr.io", "my-user", "my:password"},
+		"OtherRegistry":       {`{"auths":{"quay.io":{"auth":"` + auth + `"}}}`, "ghcr.io", "", ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			username, password, err := parseDockerConfig(tt.config, tt.registry)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.username, username)
+			assert.Equal(t, tt.password, password)
+		})
+	}
+	_, _, err := parseDockerConfig("my-password", "ghcr.io")
+	if assert.Error(t, err) {
+		assert.NotContains(t, err.Error(), "my-password")
+	}
+	_, _, err = parseDockerConfig(`{"auths":{"ghcr.io":{"auth":"my-auth"}}}`, "ghcr.io")
+	assert.Error(t, err)
+}
+
+func TestParseChallenge(t *testing.T) {
+	c := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/alpine:pull,push"`)
+	assert.Equal(t, "bearer", c.scheme)
+	assert.Equal(t, map[string]string{"realm": "https://auth.docker.io/token", "service": "registry.docker.io", "scope": "repository:library/alpine:pull,push"}, c.params)
+	c = parseChallenge(`Basic realm=registry`)
+	assert.Equal(t, "basic", c.scheme)
+	assert.Equal(t, map[string]string{"realm": "registry"}, c.params)
+}
+
+func TestParseRepository(t *testing.T) {
+	for repository, expected := range map[string][2]string{
+		"ghcr.io/my-org/my-model":   {"ghcr.io", "my-org/my-model"},
+		"localhost:5000/my-model":   {"localhost:5000", "my-model"},
+		"localhost/my-model":        {"localhost", "my-model"},
+		"docker.io/alpine":          {dockerHubHost, "library/alpine"},
+		"docker.io/my-org/my-model": {dockerHubHost, "my-org/my-model"},
+	} {
+		host, name, err := parseRepository(repository)
+		if assert.NoError(t, err, repository) {
+			assert.Equal(t, expected, [2]string{host, name}, repository)
+		}
+	}
+	for _, repository := range []string{"my-model", "my-org/my-model", "ghcr.io/My-Org/my-model", "ghcr.io/"} {
+		_, _, err := parseRepository(repository)
+		assert.Error(t, err, repository)
+	}
+}
+
+func TestValidateArtifact(t *testing.T) {
+	art := &wfv1.OCIArtifact{Repository: "ghcr.io/my-org/my-model", Tag: "v1.0", Digest: digestOf([]byte("my-manifest"))}
+	assert.NoError(t, ValidateArtifact("my-art.oci", art))
+	assert.EqualError(t, ValidateArtifact("my-art.oci", &wfv1.OCIArtifact{Repository: "my-model"}), "my-art.oci.repository must be a repository with the host of its registry, e.g. ghcr.io/my-org/my-model")
+	assert.Error(t, ValidateArtifact("my-art.oci", &wfv1.OCIArtifact{Repository: "ghcr.io/my-org/my-model", Tag: "-v1"}))
+	assert.Error(t, ValidateArtifact("my-art.oci", &wfv1.OCIArtifact{Repository: "ghcr.io/my-org/my-model", Digest: "sha256:1234"}))
+}
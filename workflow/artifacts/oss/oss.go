@@ -1,36 +1,218 @@
 package oss
 
 import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/argoproj/pkg/file"
 	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/wait"
 
+	"github.com/argoproj/argo-workflows/v3/errors"
 	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const (
+	// minPartSize and maxPartSize are the sizes parts of multipart uploads are between, but for the last part
+	minPartSize = 100 * 1024
+	maxPartSize = 5 * 1024 * 1024 * 1024
+	// maxParts is the most parts a multipart upload has
+	maxParts = 10000
+	// defaultPartSize is the size of the parts of files larger than it, unless PartSize is set
+	defaultPartSize = 16 * 1024 * 1024
+	// partConcurrency is the number of parts of a multipart upload uploaded at once
+	partConcurrency = 4
+	// ramRoleCredentialsURL lists the RAM role of the ECS instance, and followed by its name, returns its credentials
+	ramRoleCredentialsURL = "http://100.100.100.200/latest/meta-data/ram/security-credentials/"
+	// RAM role credentials are refreshed this long before they expire, so that they do not expire during a transfer
+	ramRoleCredentialsExpiryWindow = 5 * time.Minute
 )
 
 // OSSArtifactDriver is a driver for OSS
 type OSSArtifactDriver struct {
-	Endpoint  string
-	AccessKey string
-	SecretKey string
+	Endpoint      string
+	AccessKey     string
+	SecretKey     string
+	SecurityToken string
+	// UseSDKCreds uses the credentials of the RAM role of the ECS instance, unless AccessKey is set
+	UseSDKCreds bool
+	// RAMRole is the name of the RAM role, which is looked up from the instance metadata if not set
+	RAMRole string
+	// PartSize is the size of the parts files larger than it are put in, with a multipart upload, or 0 for the default
+	PartSize int64
+	// Bucket is the bucket of the artifact the driver was created for, which HealthCheck checks. Loads and saves use
+	// the bucket of the artifact they are given.
+	Bucket string
+
+	// metadataURL is the URL of the RAM role credentials, ramRoleCredentialsURL if not set
+	metadataURL string
+	lock        sync.Mutex
+	roleCreds   *ramRoleCredentials
+	common.Logging
+}
+
+// ramRoleCredentials are the temporary credentials of a RAM role returned by the instance metadata
+type ramRoleCredentials struct {
+	Code            string    `json:"Code"`
+	AccessKeyID     string    `json:"AccessKeyId"`
+	AccessKeySecret string    `json:"AccessKeySecret"`
+	SecurityToken   string    `json:"SecurityToken"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+// ValidateArtifact validates the OSS artifact, beyond what the general artifact validation checks
+func ValidateArtifact(errPrefix string, art *wfv1.OSSArtifact) error {
+	if _, err := ParsePartSize(art.PartSize); err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s.partSize %v", errPrefix, err)
+	}
+	return nil
+}
+
+// ParsePartSize parses the part size of multipart uploads, e.g. "64Mi", which is 0 if it is not set
+func ParsePartSize(size string) (int64, error) {
+	if size == "" {
+		return 0, nil
+	}
+	q, err := resource.ParseQuantity(size)
+	if err != nil {
+		return 0, fmt.Errorf("must be a quantity of bytes, e.g. 64Mi: %w", err)
+	}
+	if q.Value() < minPartSize || q.Value() > maxPartSize {
+		return 0, fmt.Errorf("must be between 100Ki and 5Gi")
+	}
+	return q.Value(), nil
 }
 
 func (ossDriver *OSSArtifactDriver) newOSSClient() (*oss.Client, error) {
-	client, err := oss.New(ossDriver.Endpoint, ossDriver.AccessKey, ossDriver.SecretKey)
+	accessKey, secretKey, securityToken := ossDriver.AccessKey, ossDriver.SecretKey, ossDriver.SecurityToken
+	if accessKey == "" && ossDriver.UseSDKCreds {
+		creds, err := ossDriver.ramRoleCredentials()
+		if err != nil {
+			ossDriver.Log().Warnf("Failed to get the RAM role credentials: %v", err)
+			return nil, err
+		}
+		accessKey, secretKey, securityToken = creds.AccessKeyID, creds.AccessKeySecret, creds.SecurityToken
+	}
+	var options []oss.ClientOption
+	if securityToken != "" {
+		options = append(options, oss.SecurityToken(securityToken))
+	}
+	client, err := oss.New(ossDriver.Endpoint, accessKey, secretKey, options...)
 	if err != nil {
-		log.Warnf("Failed to create new OSS client: %v", err)
+		ossDriver.Log().Warnf("Failed to create new OSS client: %v", err)
 		return nil, err
 	}
 	return client, err
 }
 
-// Downloads artifacts from OSS compliant storage, e.g., downloading an artifact into local path
-func (ossDriver *OSSArtifactDriver) Load(inputArtifact *wfv1.Artifact, path string) error {
-	err := wait.ExponentialBackoff(wait.Backoff{Duration: time.Second * 2, Factor: 2.0, Steps: 5, Jitter: 0.1},
+// ramRoleCredentials returns the credentials of the RAM role, which are cached until shortly before they expire
+func (ossDriver *OSSArtifactDriver) ramRoleCredentials() (*ramRoleCredentials, error) {
+	ossDriver.lock.Lock()
+	defer ossDriver.lock.Unlock()
+	if ossDriver.roleCreds != nil && time.Until(ossDriver.roleCreds.Expiration) > ramRoleCredentialsExpiryWindow {
+		return ossDriver.roleCreds, nil
+	}
+	metadataURL := ossDriver.metadataURL
+	if metadataURL == "" {
+		metadataURL = ramRoleCredentialsURL
+	}
+	role := ossDriver.RAMRole
+	if role == "" {
+		roles, err := getMetadata(metadataURL)
+		if err != nil {
+			return nil, err
+		}
+		role = strings.TrimSpace(strings.SplitN(string(roles), "\n", 2)[0])
+		if role == "" {
+			return nil, fmt.Errorf("the ECS instance has no RAM role")
+		}
+	}
+	data, err := getMetadata(metadataURL + url.PathEscape(role))
+	if err != nil {
+		return nil, err
+	}
+	creds := &ramRoleCredentials{}
+	if err := json.Unmarshal(data, creds); err != nil {
+		return nil, fmt.Errorf("invalid credentials of RAM role %s: %v", role, err)
+	}
+	if creds.Code != "Success" {
+		return nil, fmt.Errorf("failed to get the credentials of RAM role %s: %s", role, creds.Code)
+	}
+	ossDriver.roleCreds = creds
+	return creds, nil
+}
+
+func getMetadata(u string) ([]byte, error) {
+	res, err := (&http.Client{Timeout: 10 * time.Second}).Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("instance metadata %s failed with reason:%s", u, res.Status)
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+// logger returns the logger of the driver with the endpoint, bucket and key of the artifact, but never its credentials
+func (ossDriver *OSSArtifactDriver) logger(art *wfv1.OSSArtifact) log.FieldLogger {
+	return ossDriver.Log().WithFields(log.Fields{"endpoint": ossDriver.Endpoint, "bucket": art.Bucket, "key": art.Key})
+}
+
+func isNotFoundErr(err error) bool {
+	serviceErr, ok := err.(oss.ServiceError)
+	return ok && serviceErr.StatusCode == http.StatusNotFound
+}
+
+// isExistsErr returns whether a put with ForbidOverWrite failed because an object exists with its key
+func isExistsErr(err error) bool {
+	var serviceErr oss.ServiceError
+	return stderrors.As(err, &serviceErr) && serviceErr.StatusCode == http.StatusConflict && serviceErr.Code == "FileAlreadyExists"
+}
+
+// Downloads artifacts from OSS compliant storage, e.g., downloading an artifact into local path. A key which is not an
+// object is loaded as a directory of the objects under it, if there are any. The OSS client does not take a context,
+// so cancelling it aborts retries rather than the download. It downloads to a temporary path, which only replaces
+// path once it succeeds.
+func (ossDriver *OSSArtifactDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, path string) error {
+	return common.LoadAtomically(path, func(path string) error {
+		return ossDriver.load(ctx, inputArtifact, path)
+	})
+}
+
+func (ossDriver *OSSArtifactDriver) load(ctx context.Context, inputArtifact *wfv1.Artifact, path string) error {
+	maxSize, err := common.MaxSize(inputArtifact)
+	if err != nil {
+		return err
+	}
+	logger := ossDriver.logger(inputArtifact.OSS)
+	attempt := 0
+	err = wait.ExponentialBackoff(wait.Backoff{Duration: time.Second * 2, Factor: 2.0, Steps: 5, Jitter: 0.1},
 		func() (bool, error) {
-			log.Infof("OSS Load path: %s, key: %s", path, inputArtifact.OSS.Key)
+			logger.Infof("OSS Load path: %s, key: %s", path, inputArtifact.OSS.Key)
+			attempt++
+			logger.WithField("attempt", attempt).Debug("Loading from OSS")
+			if err := ctx.Err(); err != nil {
+				return false, err
+			}
 			osscli, err := ossDriver.newOSSClient()
 			if err != nil {
 				return false, err
@@ -41,7 +223,38 @@ func (ossDriver *OSSArtifactDriver) Load(inputArtifact *wfv1.Artifact, path stri
 				return false, err
 			}
 			objectName := inputArtifact.OSS.Key
+			if inputArtifact.SelectLatest != "" {
+				objectName, err = selectLatest(bucket, inputArtifact)
+				if err != nil {
+					return false, err
+				}
+				logger.Infof("Selected the %s object %s under %s", inputArtifact.SelectLatest, objectName, inputArtifact.OSS.Key)
+			}
+			// an object which is not found is not an error here, as the key might be a directory
+			if err := checkObjectSize(bucket, objectName, maxSize); err != nil && !isNotFoundErr(err) {
+				return false, err
+			}
 			err = bucket.GetObjectToFile(objectName, path)
+			if isNotFoundErr(err) {
+				// the key might be a directory, which is verified against its manifest if it has one
+				found, dirErr := getDirectory(bucket, objectName, path, maxSize)
+				if dirErr != nil && !isNotFoundErr(dirErr) {
+					return false, dirErr
+				}
+				if !found {
+					return false, common.NewNotFoundError(err)
+				}
+				manifest, err := getManifest(bucket, objectName)
+				if err != nil {
+					return false, err
+				}
+				if manifest != nil {
+					if err := manifest.Verify(path); err != nil {
+						return false, err
+					}
+				}
+				return true, nil
+			}
 			if err != nil {
 				return false, err
 			}
@@ -50,14 +263,145 @@ func (ossDriver *OSSArtifactDriver) Load(inputArtifact *wfv1.Artifact, path stri
 	return err
 }
 
-// Saves an artifact to OSS compliant storage, e.g., uploading a local file to OSS bucket
-func (ossDriver *OSSArtifactDriver) Save(path string, outputArtifact *wfv1.Artifact) error {
+// selectLatest returns the key of the latest of the objects under the key prefix of the input artifact, by its policy
+func selectLatest(bucket *oss.Bucket, inputArtifact *wfv1.Artifact) (string, error) {
+	prefix := inputArtifact.OSS.Key
+	var objects []common.ListedObject
+	marker := ""
+	for {
+		result, err := bucket.ListObjects(oss.Prefix(prefix), oss.Marker(marker))
+		if err != nil {
+			return "", err
+		}
+		for _, object := range result.Objects {
+			if !strings.HasSuffix(object.Key, "/") {
+				objects = append(objects, common.ListedObject{Key: object.Key, LastModified: object.LastModified, Size: object.Size})
+			}
+		}
+		if !result.IsTruncated {
+			return common.SelectLatest(inputArtifact.SelectLatest, prefix, objects)
+		}
+		marker = result.NextMarker
+	}
+}
+
+// LoadRange downloads a range of the bytes of an object from OSS compliant storage, with a Range header
+func (ossDriver *OSSArtifactDriver) LoadRange(artifact *wfv1.Artifact, start, length int64, path string) error {
+	byteRange := common.ByteRangeSpec(start, length)
+	ossDriver.logger(artifact.OSS).Infof("OSS LoadRange path: %s, key: %s, range: %s", path, artifact.OSS.Key, byteRange)
+	osscli, err := ossDriver.newOSSClient()
+	if err != nil {
+		return err
+	}
+	bucket, err := osscli.Bucket(artifact.OSS.Bucket)
+	if err != nil {
+		return err
+	}
+	err = bucket.GetObjectToFile(artifact.OSS.Key, path, oss.NormalizedRange(byteRange))
+	if isNotFoundErr(err) {
+		return common.NewNotFoundError(err)
+	}
+	return err
+}
+
+// Saves an artifact to OSS compliant storage, e.g., uploading a local file to OSS bucket. The files of a
+// directory which match the include and exclude patterns of the artifact are uploaded as separate objects.
+// The OSS client does not take a context, so cancelling it aborts retries rather than the upload.
+func (ossDriver *OSSArtifactDriver) Save(ctx context.Context, path string, outputArtifact *wfv1.Artifact) error {
+	_, err := ossDriver.save(ctx, path, outputArtifact)
+	return err
+}
+
+// SaveWithContentEncoding saves a file to OSS, recording its encoding in the user metadata of the object.
+// Progress is not reported.
+func (ossDriver *OSSArtifactDriver) SaveWithContentEncoding(ctx context.Context, path string, outputArtifact *wfv1.Artifact, encoding string, progress common.ProgressFunc) error {
+	_, err := ossDriver.SaveWithResult(ctx, path, outputArtifact, encoding, progress)
+	return err
+}
+
+// SaveWithResult saves an artifact to OSS, returning the ETag and version ID of each object, as OSS reported them
+// when it was put. The version ID is empty unless the bucket is versioned. Progress is not reported.
+func (ossDriver *OSSArtifactDriver) SaveWithResult(ctx context.Context, path string, outputArtifact *wfv1.Artifact, encoding string, _ common.ProgressFunc) (*common.SaveResult, error) {
+	if encoding == "" {
+		return ossDriver.save(ctx, path, outputArtifact)
+	}
+	return ossDriver.save(ctx, path, outputArtifact, oss.Meta(common.ContentEncodingMetadataKey, encoding))
+}
+
+// ProtectsOverwrite returns true: unless the overwrite policy of an artifact is allow, its objects are put with
+// ForbidOverWrite, and a directory is not saved if any object exists under its key
+func (ossDriver *OSSArtifactDriver) ProtectsOverwrite() bool {
+	return true
+}
+
+// SkipsUnchanged returns true: a file is not put if the sha256 recorded in the user metadata of its object, or
+// otherwise its ETag, is that of the file, nor a directory if its manifest is that of its files
+func (ossDriver *OSSArtifactDriver) SkipsUnchanged() bool {
+	return true
+}
+
+// SavesAtomically returns true: a directory is put under a staging key, and its objects copied to its key by OSS
+func (ossDriver *OSSArtifactDriver) SavesAtomically() bool {
+	return true
+}
+
+// LimitsSize returns true: the size of the object of an artifact is checked against its max size before it is
+// loaded, as is the total size of the objects under the key of a directory before each is
+func (ossDriver *OSSArtifactDriver) LimitsSize() bool {
+	return true
+}
+
+// ContentEncoding returns the encoding recorded in the user metadata of the object of an artifact
+func (ossDriver *OSSArtifactDriver) ContentEncoding(artifact *wfv1.Artifact) (string, error) {
+	osscli, err := ossDriver.newOSSClient()
+	if err != nil {
+		return "", err
+	}
+	bucket, err := osscli.Bucket(artifact.OSS.Bucket)
+	if err != nil {
+		return "", err
+	}
+	meta, err := bucket.GetObjectDetailedMeta(artifact.OSS.Key)
+	if isNotFoundErr(err) {
+		return "", common.NewNotFoundError(err)
+	}
+	if err != nil {
+		return "", err
+	}
+	return meta.Get(oss.HTTPHeaderOssMetaPrefix + common.ContentEncodingMetadataKey), nil
+}
+
+// save saves an artifact, with the options if it is a file, returning the objects it put
+func (ossDriver *OSSArtifactDriver) save(ctx context.Context, path string, outputArtifact *wfv1.Artifact, options ...oss.Option) (*common.SaveResult, error) {
+	logger := ossDriver.logger(outputArtifact.OSS)
+	location := fmt.Sprintf("oss://%s/%s", outputArtifact.OSS.Bucket, outputArtifact.OSS.Key)
+	// the objects of a directory are put without the options of a file, other than the metadata of the artifact and
+	// ForbidOverWrite
+	var dirOptions []oss.Option
+	// the metadata of the artifact is that of every object
+	metadata := metadataOptions(outputArtifact)
+	options = append(options, metadata...)
+	dirOptions = append(dirOptions, metadata...)
+	forbidOverwrite := common.IsOverwriteProtected(outputArtifact.Overwrite)
+	if forbidOverwrite {
+		options = append(options, oss.ForbidOverWrite(true))
+		dirOptions = append(dirOptions, oss.ForbidOverWrite(true))
+	}
+	skipUnchanged := common.SkipsUnchanged(outputArtifact.Overwrite)
+	attempt := 0
+	var objects []common.SavedObject
+	isUnchanged := false
 	err := wait.ExponentialBackoff(wait.Backoff{Duration: time.Second * 2, Factor: 2.0, Steps: 5, Jitter: 0.1},
 		func() (bool, error) {
-			log.Infof("OSS Save path: %s, key: %s", path, outputArtifact.OSS.Key)
+			logger.Infof("OSS Save path: %s, key: %s", path, outputArtifact.OSS.Key)
+			attempt++
+			logger.WithField("attempt", attempt).Debug("Saving to OSS")
+			if err := ctx.Err(); err != nil {
+				return false, err
+			}
 			osscli, err := ossDriver.newOSSClient()
 			if err != nil {
-				log.Warnf("Failed to create new OSS client: %v", err)
+				logger.Warnf("Failed to create new OSS client: %v", err)
 				return false, nil
 			}
 			bucketName := outputArtifact.OSS.Bucket
@@ -66,11 +410,554 @@ func (ossDriver *OSSArtifactDriver) Save(path string, outputArtifact *wfv1.Artif
 				return false, err
 			}
 			objectName := outputArtifact.OSS.Key
-			err = bucket.PutObjectFromFile(objectName, path)
+			isDir, err := file.IsDirectory(path)
+			if err != nil {
+				return false, err
+			}
+			if skipUnchanged {
+				isUnchanged, err = unchanged(bucket, path, isDir, outputArtifact)
+				if err != nil {
+					return false, err
+				}
+				if isUnchanged {
+					return true, nil
+				}
+			}
+			if isDir && forbidOverwrite {
+				// the directory exists if any object does under its key, not only those of its files
+				result, err := bucket.ListObjects(oss.Prefix(strings.TrimSuffix(objectName, "/")+"/"), oss.MaxKeys(1))
+				if err != nil {
+					return false, err
+				}
+				if len(result.Objects) > 0 {
+					return false, common.NewExistsError(location)
+				}
+			}
+			if isDir {
+				if outputArtifact.AtomicSave {
+					objects, err = putDirectoryAtomically(logger, bucket, objectName, path, common.NewFileFilter(outputArtifact), outputArtifact.ContentType, ossDriver.PartSize, dirOptions...)
+				} else {
+					objects, err = putDirectory(bucket, objectName, path, common.NewFileFilter(outputArtifact), outputArtifact.ContentType, ossDriver.PartSize, dirOptions...)
+				}
+				if format := common.ManifestFormat(outputArtifact); err == nil && format != "" {
+					err = putManifest(bucket, objectName, path, format, objects, dirOptions...)
+				}
+			} else {
+				fileOptions := options
+				if skipUnchanged {
+					digest, err := common.FileChecksum(wfv1.ChecksumAlgorithmSHA256, path)
+					if err != nil {
+						return false, err
+					}
+					fileOptions = append(append([]oss.Option{}, options...), oss.Meta(common.ContentSHA256MetadataKey, digest))
+				}
+				var object common.SavedObject
+				object, err = putObject(bucket, objectName, path, outputArtifact.ContentType, ossDriver.PartSize, fileOptions...)
+				objects = []common.SavedObject{object}
+			}
+			if isExistsErr(err) {
+				return false, common.NewExistsError(location)
+			}
 			if err != nil {
 				return false, err
 			}
 			return true, nil
 		})
+	if err != nil {
+		return nil, err
+	}
+	if isUnchanged {
+		return common.NewUnchangedResult(location), nil
+	}
+	return common.NewSaveResult(location, objects), nil
+}
+
+// metadataOptions returns the options which put objects with the metadata of the artifact, in the order of its keys
+func metadataOptions(art *wfv1.Artifact) []oss.Option {
+	keys := make([]string, 0, len(art.Metadata))
+	for key := range art.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	options := make([]oss.Option, len(keys))
+	for i, key := range keys {
+		options[i] = oss.Meta(key, art.Metadata[key])
+	}
+	return options
+}
+
+// Metadata returns the user metadata of the object of an artifact, which a directory does not have
+func (ossDriver *OSSArtifactDriver) Metadata(artifact *wfv1.Artifact) (map[string]string, error) {
+	osscli, err := ossDriver.newOSSClient()
+	if err != nil {
+		return nil, err
+	}
+	bucket, err := osscli.Bucket(artifact.OSS.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := bucket.GetObjectDetailedMeta(artifact.OSS.Key)
+	if isNotFoundErr(err) {
+		return nil, common.NewNotFoundError(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	metadata := map[string]string{}
+	for k, v := range meta {
+		if strings.HasPrefix(k, oss.HTTPHeaderOssMetaPrefix) && len(v) > 0 {
+			metadata[strings.TrimPrefix(k, oss.HTTPHeaderOssMetaPrefix)] = v[0]
+		}
+	}
+	return common.UserMetadata(metadata), nil
+}
+
+// unchanged returns whether the key of the artifact has the content of the file or directory at path. A file is
+// compared by the sha256 recorded in the user metadata of its object, or otherwise its ETag if that is its md5, which
+// it is unless the object was put in parts, and a directory by its manifest.
+func unchanged(bucket *oss.Bucket, path string, isDir bool, outputArtifact *wfv1.Artifact) (bool, error) {
+	key := outputArtifact.OSS.Key
+	if isDir {
+		manifest, err := getManifest(bucket, key)
+		if err != nil {
+			return false, err
+		}
+		return common.DirectoryUnchanged(path, common.NewFileFilter(outputArtifact), manifest)
+	}
+	meta, err := bucket.GetObjectDetailedMeta(key)
+	if isNotFoundErr(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	contentMD5 := strings.Trim(meta.Get(oss.HTTPHeaderEtag), `"`)
+	if _, err := hex.DecodeString(contentMD5); err != nil || len(contentMD5) != 32 {
+		contentMD5 = ""
+	}
+	return common.FileUnchanged(path, meta.Get(oss.HTTPHeaderOssMetaPrefix+common.ContentSHA256MetadataKey), contentMD5)
+}
+
+// putObject puts the file as the object with the options and the content type, or otherwise that detected for the
+// file, returning it as OSS reported it was put. A file larger than a part is put in a multipart upload. OSS does not
+// report the size of the object when it is put, which is that of the file.
+func putObject(bucket *oss.Bucket, key, localPath, contentType string, partSize int64, options ...oss.Option) (common.SavedObject, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return common.SavedObject{}, err
+	}
+	// the SDK only detects the content type by the extension of the file
+	contentType, err = common.ContentType(contentType, localPath)
+	if err != nil {
+		return common.SavedObject{}, err
+	}
+	if partSize = objectPartSize(partSize, info.Size()); info.Size() > partSize {
+		return putMultipart(bucket, key, localPath, info.Size(), partSize, contentType, options...)
+	}
+	var header http.Header
+	options = append(append([]oss.Option{}, options...), oss.ContentType(contentType), oss.GetResponseHeader(&header))
+	if err := bucket.PutObjectFromFile(key, localPath, options...); err != nil {
+		return common.SavedObject{}, err
+	}
+	return common.SavedObject{Key: key, Path: localPath, Size: info.Size(), ETag: strings.Trim(header.Get(oss.HTTPHeaderEtag), `"`), VersionID: header.Get("X-Oss-Version-Id")}, nil
+}
+
+// objectPartSize returns the size of the parts a file of the size is put in: partSize, or defaultPartSize if it is 0,
+// unless the file would have more than maxParts of them, in which case it is the smallest whole number of MiB it has at
+// most maxParts of
+func objectPartSize(partSize, size int64) int64 {
+	if partSize == 0 {
+		partSize = defaultPartSize
+	}
+	if smallest := (size + maxParts - 1) / maxParts; partSize < smallest {
+		partSize = (smallest + 1<<20 - 1) >> 20 << 20
+	}
+	return partSize
+}
+
+// putMultipart puts the file of the size as the object in a multipart upload of parts of partSize bytes,
+// partConcurrency of which are uploaded at once. The options, e.g. the user metadata, are those of the initiation of
+// the upload, and of its completion, which is when an object it would overwrite is checked for. The upload is aborted
+// if any part fails to be uploaded.
+func putMultipart(bucket *oss.Bucket, key, localPath string, size, partSize int64, contentType string, options ...oss.Option) (common.SavedObject, error) {
+	imur, err := bucket.InitiateMultipartUpload(key, append(append([]oss.Option{}, options...), oss.ContentType(contentType))...)
+	if err != nil {
+		return common.SavedObject{}, fmt.Errorf("initiate multipart upload: %w", err)
+	}
+	n := int((size + partSize - 1) / partSize)
+	parts := make([]oss.UploadPart, n)
+	errs := make([]error, n)
+	numbers := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < partConcurrency && i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for number := range numbers {
+				start := int64(number-1) * partSize
+				length := partSize
+				if start+length > size {
+					length = size - start
+				}
+				parts[number-1], errs[number-1] = bucket.UploadPartFromFile(imur, localPath, start, length, number)
+			}
+		}()
+	}
+	for number := 1; number <= n; number++ {
+		numbers <- number
+	}
+	close(numbers)
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			abort(bucket, imur)
+			return common.SavedObject{}, fmt.Errorf("upload part %d: %w", i+1, err)
+		}
+	}
+	var header http.Header
+	result, err := bucket.CompleteMultipartUpload(imur, parts, append(append([]oss.Option{}, options...), oss.GetResponseHeader(&header))...)
+	if err != nil {
+		abort(bucket, imur)
+		return common.SavedObject{}, fmt.Errorf("complete multipart upload: %w", err)
+	}
+	return common.SavedObject{Key: key, Path: localPath, Size: size, ETag: strings.Trim(result.ETag, `"`), VersionID: header.Get("X-Oss-Version-Id")}, nil
+}
+
+// abort aborts the multipart upload, so that the parts uploaded are not stored, and billed, until they expire
+func abort(bucket *oss.Bucket, imur oss.InitiateMultipartUploadResult) {
+	if err := bucket.AbortMultipartUpload(imur); err != nil {
+		log.Warnf("Failed to abort the multipart upload %s of %s: %v", imur.UploadID, imur.Key, err)
+	}
+}
+
+// putManifest puts the manifest of the objects put from the files of the directory dir in the format, next to them,
+// with the options they were put with
+func putManifest(bucket *oss.Bucket, key, dir string, format wfv1.ArtifactManifestFormat, objects []common.SavedObject, options ...oss.Option) error {
+	manifest, err := common.NewManifest(dir, objects)
+	if err != nil {
+		return err
+	}
+	data, err := manifest.Marshal(format)
+	if err != nil {
+		return err
+	}
+	options = append([]oss.Option{oss.ContentType(common.ManifestContentType(format))}, options...)
+	if err := bucket.PutObject(common.ManifestKey(key), bytes.NewReader(data), options...); err != nil {
+		return fmt.Errorf("put manifest: %w", err)
+	}
+	return nil
+}
+
+// putDirectory puts the files of a directory which match filter under the key prefix, each as a separate object
+// with the options and the content type, or otherwise that detected for it, returning the objects put
+func putDirectory(bucket *oss.Bucket, key, dir string, filter common.FileFilter, contentType string, partSize int64, options ...oss.Option) ([]common.SavedObject, error) {
+	var objects []common.SavedObject
+	err := filter.Walk(dir, func(f common.WalkedFile) error {
+		var object common.SavedObject
+		var err error
+		if f.LinkTarget != "" {
+			object, err = putSymlink(bucket, path.Join(key, f.RelPath), f.Path, f.LinkTarget, options...)
+		} else {
+			object, err = putObject(bucket, path.Join(key, f.RelPath), f.Path, contentType, partSize, options...)
+		}
+		if err != nil {
+			return fmt.Errorf("put %s: %w", f.Path, err)
+		}
+		objects = append(objects, object)
+		return nil
+	})
+	return objects, err
+}
+
+// putSymlink puts the symlink at localPath to the target at the key, with the options, as an empty object with the
+// target in its user metadata
+func putSymlink(bucket *oss.Bucket, key, localPath, target string, options ...oss.Option) (common.SavedObject, error) {
+	var header http.Header
+	options = append(append([]oss.Option{}, options...), oss.Meta(common.SymlinkTargetMetadataKey, target), oss.GetResponseHeader(&header))
+	if err := bucket.PutObject(key, bytes.NewReader(nil), options...); err != nil {
+		return common.SavedObject{}, err
+	}
+	return common.SavedObject{Key: key, Path: localPath, ETag: strings.Trim(header.Get(oss.HTTPHeaderEtag), `"`), VersionID: header.Get("X-Oss-Version-Id"), LinkTarget: target}, nil
+}
+
+// putDirectoryAtomically puts the files of a directory which match filter under a staging key, and only once every
+// file is put copies their objects to the key with the options, returning the copies. The staging objects are deleted
+// whether or not it succeeds, as are the copies if any copy fails.
+func putDirectoryAtomically(logger log.FieldLogger, bucket *oss.Bucket, key, dir string, filter common.FileFilter, contentType string, partSize int64, options ...oss.Option) ([]common.SavedObject, error) {
+	staging, err := common.StagingKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := deleteDirectory(bucket, staging); err != nil {
+			logger.Warnf("Failed to delete the staging objects under %s: %v", staging, err)
+		}
+	}()
+	staged, err := putDirectory(bucket, staging, dir, filter, contentType, partSize)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]common.SavedObject, 0, len(staged))
+	for _, stagedObject := range staged {
+		objectKey := common.StagedKey(key, staging, stagedObject.Key)
+		var header http.Header
+		result, err := bucket.CopyObject(stagedObject.Key, objectKey, append(append([]oss.Option{}, options...), oss.GetResponseHeader(&header))...)
+		if err != nil {
+			for _, copied := range objects {
+				if err := bucket.DeleteObject(copied.Key); err != nil {
+					logger.Warnf("Failed to delete %s, which a failed save was copied to: %v", copied.Key, err)
+				}
+			}
+			return nil, fmt.Errorf("copy %s: %w", stagedObject.Key, err)
+		}
+		objects = append(objects, common.SavedObject{Key: objectKey, Path: stagedObject.Path, Size: stagedObject.Size, ETag: strings.Trim(result.ETag, `"`), VersionID: header.Get("X-Oss-Version-Id"), LinkTarget: stagedObject.LinkTarget})
+	}
+	return objects, nil
+}
+
+// deleteDirectory deletes every object under the key prefix
+func deleteDirectory(bucket *oss.Bucket, key string) error {
+	prefix := strings.TrimSuffix(key, "/") + "/"
+	marker := ""
+	for {
+		result, err := bucket.ListObjects(oss.Prefix(prefix), oss.Marker(marker))
+		if err != nil {
+			return err
+		}
+		for _, object := range result.Objects {
+			if err := bucket.DeleteObject(object.Key); err != nil {
+				return fmt.Errorf("delete %s: %w", object.Key, err)
+			}
+		}
+		if !result.IsTruncated {
+			return nil
+		}
+		marker = result.NextMarker
+	}
+}
+
+// getManifest returns the manifest of the directory of the key, which is nil if it has none
+func getManifest(bucket *oss.Bucket, key string) (*common.Manifest, error) {
+	r, err := bucket.GetObject(common.ManifestKey(key))
+	if isNotFoundErr(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return common.ParseManifest(data)
+}
+
+// getDirectory downloads every object under the key prefix to the directory dir, each to its path relative to the
+// key, returning whether there were any. It fails before downloading an object which makes the objects larger than
+// maxSize in total, which is not a limit if it is not positive, or which is under a symlink an earlier object was
+// loaded as.
+func getDirectory(bucket *oss.Bucket, key, dir string, maxSize int64) (bool, error) {
+	prefix := strings.TrimSuffix(key, "/") + "/"
+	found := false
+	marker := ""
+	var size int64
+	for {
+		result, err := bucket.ListObjects(oss.Prefix(prefix), oss.Marker(marker))
+		if err != nil {
+			return false, err
+		}
+		for _, object := range result.Objects {
+			found = true
+			relPath := strings.TrimPrefix(object.Key, prefix)
+			if relPath == "" || strings.HasSuffix(relPath, "/") {
+				// a directory placeholder
+				continue
+			}
+			localPath := filepath.Join(dir, filepath.FromSlash(relPath))
+			if !strings.HasPrefix(localPath, filepath.Clean(dir)+string(os.PathSeparator)) {
+				return false, fmt.Errorf("object %s is outside of the directory %s", object.Key, key)
+			}
+			size += object.Size
+			if err := common.CheckSize(size, maxSize); err != nil {
+				return false, err
+			}
+			if err := common.CheckLoadPath(dir, localPath); err != nil {
+				return false, err
+			}
+			if err := os.MkdirAll(filepath.Dir(localPath), 0700); err != nil {
+				return false, err
+			}
+			if err := bucket.GetObjectToFile(object.Key, localPath); err != nil {
+				return false, fmt.Errorf("get %s: %w", object.Key, err)
+			}
+			if err := createSymlink(bucket, object.Key, dir, localPath); err != nil {
+				return false, err
+			}
+		}
+		if !result.IsTruncated {
+			return found, nil
+		}
+		marker = result.NextMarker
+	}
+}
+
+// checkObjectSize returns an error which matches common.ErrArtifactTooLarge if the object with the key is larger than
+// maxSize, as its Content-Length tells, which is not a limit if it is not positive, in which case its metadata is not
+// got
+func checkObjectSize(bucket *oss.Bucket, key string, maxSize int64) error {
+	if maxSize <= 0 {
+		return nil
+	}
+	meta, err := bucket.GetObjectDetailedMeta(key)
+	if err != nil {
+		return err
+	}
+	size, err := strconv.ParseInt(meta.Get(oss.HTTPHeaderContentLength), 10, 64)
+	if err != nil {
+		// a size which is not reported is not checked
+		return nil
+	}
+	return common.CheckSize(size, maxSize)
+}
+
+// createSymlink replaces the file of the object with the key loaded to localPath under the directory dir with the
+// symlink the object preserves, if it is one. Only empty objects can be, so only their metadata is got, as listings
+// of objects do not have it.
+func createSymlink(bucket *oss.Bucket, key, dir, localPath string) error {
+	if info, err := os.Stat(localPath); err != nil || info.Size() > 0 {
+		return err
+	}
+	meta, err := bucket.GetObjectDetailedMeta(key)
+	if err != nil {
+		return fmt.Errorf("get the metadata of %s: %w", key, err)
+	}
+	target := meta.Get(oss.HTTPHeaderOssMetaPrefix + common.SymlinkTargetMetadataKey)
+	if target == "" {
+		return nil
+	}
+	created, err := common.CreateSymlink(dir, localPath, target)
+	if err == nil && !created {
+		log.Warnf("Not creating the symlink %s to %s, which is outside of %s", localPath, target, dir)
+		err = os.Remove(localPath)
+	}
 	return err
 }
+
+// DryRunSave checks that the bucket of an artifact exists, and returns the objects saving path would put. OSS
+// cannot check permission to put an object without putting one, so it is not checked.
+func (ossDriver *OSSArtifactDriver) DryRunSave(_ context.Context, path string, outputArtifact *wfv1.Artifact) (*common.DryRunResult, error) {
+	ossDriver.Log().Infof("OSS dry run of Save path: %s, key: %s", path, outputArtifact.OSS.Key)
+	ossArtifact := outputArtifact.OSS
+	location := fmt.Sprintf("oss://%s/%s", ossArtifact.Bucket, ossArtifact.Key)
+	result, err := common.NewDryRunResult(location, ossArtifact.Key, path, common.NewFileFilter(outputArtifact))
+	if err != nil {
+		return nil, err
+	}
+	osscli, err := ossDriver.newOSSClient()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := osscli.GetBucketInfo(ossArtifact.Bucket); err != nil {
+		if isNotFoundErr(err) {
+			return nil, errors.Errorf(errors.CodeNotFound, "bucket %s does not exist", ossArtifact.Bucket)
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// HealthCheck checks that the bucket of the driver exists, by getting its info, which is denied if the credentials are
+// not accepted
+func (ossDriver *OSSArtifactDriver) HealthCheck(_ context.Context) error {
+	ossDriver.Log().Infof("OSS HealthCheck bucket: %s", ossDriver.Bucket)
+	osscli, err := ossDriver.newOSSClient()
+	if err != nil {
+		return err
+	}
+	if _, err := osscli.GetBucketInfo(ossDriver.Bucket); err != nil {
+		if isNotFoundErr(err) {
+			return errors.Errorf(errors.CodeNotFound, "bucket %s does not exist", ossDriver.Bucket)
+		}
+		return err
+	}
+	return nil
+}
+
+// Deletes an artifact from OSS compliant storage
+func (ossDriver *OSSArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	logger := ossDriver.logger(artifact.OSS)
+	attempt := 0
+	err := wait.ExponentialBackoff(wait.Backoff{Duration: time.Second * 2, Factor: 2.0, Steps: 5, Jitter: 0.1},
+		func() (bool, error) {
+			logger.Infof("OSS Delete key: %s", artifact.OSS.Key)
+			attempt++
+			logger.WithField("attempt", attempt).Debug("Deleting from OSS")
+			osscli, err := ossDriver.newOSSClient()
+			if err != nil {
+				return false, err
+			}
+			bucket, err := osscli.Bucket(artifact.OSS.Bucket)
+			if err != nil {
+				return false, err
+			}
+			err = bucket.DeleteObject(artifact.OSS.Key)
+			if err != nil {
+				return false, err
+			}
+			return true, nil
+		})
+	return err
+}
+
+// Capabilities returns the optional operations on OSS artifacts the driver supports
+func (ossDriver *OSSArtifactDriver) Capabilities() common.DriverCapabilities {
+	return common.DriverCapabilities{
+		Delete:              true,
+		LoadRange:           true,
+		Exists:              true,
+		HealthCheck:         true,
+		ContentEncoding:     true,
+		OverwriteProtection: true,
+		SkipUnchanged:       true,
+		AtomicSave:          true,
+		MaxSize:             true,
+		Metadata:            true,
+		DryRunSave:          true,
+		SaveResult:          true,
+		Presign:             true,
+	}
+}
+
+// Exists returns whether an object exists in OSS compliant storage
+func (ossDriver *OSSArtifactDriver) Exists(artifact *wfv1.Artifact) (bool, error) {
+	ossDriver.Log().Infof("OSS Exists key: %s", artifact.OSS.Key)
+	osscli, err := ossDriver.newOSSClient()
+	if err != nil {
+		return false, err
+	}
+	bucket, err := osscli.Bucket(artifact.OSS.Bucket)
+	if err != nil {
+		return false, err
+	}
+	return bucket.IsObjectExist(artifact.OSS.Key)
+}
+
+// Presign returns a presigned URL for requests of the method of the object of an artifact. A URL signed with the
+// credentials of a RAM role is only valid until they expire, which may be before the expiry.
+func (ossDriver *OSSArtifactDriver) Presign(artifact *wfv1.Artifact, method string, expiry time.Duration) (string, error) {
+	ossDriver.Log().Infof("OSS Presign key: %s, method: %s, expiry: %v", artifact.OSS.Key, method, expiry)
+	if err := common.ValidatePresign(artifact.OSS.Key, method, expiry); err != nil {
+		return "", err
+	}
+	osscli, err := ossDriver.newOSSClient()
+	if err != nil {
+		return "", err
+	}
+	bucket, err := osscli.Bucket(artifact.OSS.Bucket)
+	if err != nil {
+		return "", err
+	}
+	// OSS expiries are whole seconds
+	return bucket.SignURL(artifact.OSS.Key, oss.HTTPMethod(method), int64(expiry/time.Second))
+}
@@ -0,0 +1,1055 @@
+package oss
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+func TestOSSArtifactDriver_Exists(t *testing.T) {
+	// the endpoint is an IP address, so the bucket is addressed in the path
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/my-bucket/my-file":
+			w.WriteHeader(http.StatusOK)
+		case "/forbidden/my-file":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	driver := &OSSArtifactDriver{Endpoint: server.URL, AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	newArtifact := func(bucket, key string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{OSS: &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{Bucket: bucket}, Key: key}}}
+	}
+	t.Run("Present", func(t *testing.T) {
+		ok, err := driver.Exists(newArtifact("my-bucket", "my-file"))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+	t.Run("Absent", func(t *testing.T) {
+		ok, err := driver.Exists(newArtifact("my-bucket", "not-found"))
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+	t.Run("Error", func(t *testing.T) {
+		_, err := driver.Exists(newArtifact("forbidden", "my-file"))
+		assert.Error(t, err)
+	})
+}
+
+func TestOSSArtifactDriver_Credentials(t *testing.T) {
+	var requests []string
+	expiration := time.Now().Add(time.Hour)
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		switch r.URL.Path {
+		case "/":
+			_, _ = w.Write([]byte("my-role\n"))
+		case "/my-role":
+			_, _ = fmt.Fprintf(w, `{"Code":"Success","AccessKeyId":"role-access-key","AccessKeySecret":"role-secret-key","SecurityToken":"role-token","Expiration":%q}`, expiration.UTC().Format(time.RFC3339))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer metadata.Close()
+	t.Run("StaticKeys", func(t *testing.T) {
+		requests = nil
+		driver := &OSSArtifactDriver{Endpoint: "http://127.0.0.1", AccessKey: "my-access-key", SecretKey: "my-secret-key", SecurityToken: "my-token", UseSDKCreds: true, metadataURL: metadata.URL + "/"}
+		client, err := driver.newOSSClient()
+		if assert.NoError(t, err) {
+			assert.Equal(t, "my-access-key", client.Config.AccessKeyID)
+			assert.Equal(t, "my-secret-key", client.Config.AccessKeySecret)
+			assert.Equal(t, "my-token", client.Config.SecurityToken)
+			// the access keys take precedence
+			assert.Empty(t, requests)
+		}
+	})
+	t.Run("RAMRole", func(t *testing.T) {
+		requests = nil
+		driver := &OSSArtifactDriver{Endpoint: "http://127.0.0.1", UseSDKCreds: true, metadataURL: metadata.URL + "/"}
+		for i := 0; i < 2; i++ {
+			client, err := driver.newOSSClient()
+			if assert.NoError(t, err) {
+				assert.Equal(t, "role-access-key", client.Config.AccessKeyID)
+				assert.Equal(t, "role-secret-key", client.Config.AccessKeySecret)
+				assert.Equal(t, "role-token", client.Config.SecurityToken)
+			}
+		}
+		// the credentials were cached
+		assert.Equal(t, []string{"/", "/my-role"}, requests)
+	})
+	t.Run("RAMRoleRefresh", func(t *testing.T) {
+		requests = nil
+		expiration = time.Now().Add(time.Minute)
+		defer func() { expiration = time.Now().Add(time.Hour) }()
+		driver := &OSSArtifactDriver{Endpoint: "http://127.0.0.1", UseSDKCreds: true, RAMRole: "my-role", metadataURL: metadata.URL + "/"}
+		for i := 0; i < 2; i++ {
+			_, err := driver.newOSSClient()
+			assert.NoError(t, err)
+		}
+		// the credentials expire too soon to be cached
+		assert.Equal(t, []string{"/my-role", "/my-role"}, requests)
+	})
+	t.Run("UnknownRAMRole", func(t *testing.T) {
+		driver := &OSSArtifactDriver{Endpoint: "http://127.0.0.1", UseSDKCreds: true, RAMRole: "unknown", metadataURL: metadata.URL + "/"}
+		_, err := driver.newOSSClient()
+		assert.Error(t, err)
+	})
+}
+
+func TestOSSArtifactDriver_SaveDirectory(t *testing.T) {
+	var lock sync.Mutex
+	objects := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		// the client verifies the CRC of the uploaded data
+		w.Header().Set("X-Oss-Hash-Crc64ecma", strconv.FormatUint(crc64.Checksum(data, crc64.MakeTable(crc64.ECMA)), 10))
+		lock.Lock()
+		defer lock.Unlock()
+		objects[r.URL.Path] = string(data)
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "oss")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	for _, rel := range []string{"app.jar", "lib/dep.jar", "lib/test/dep-test.jar", "README.md"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(rel)), 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, rel), []byte(rel), 0600))
+	}
+	driver := &OSSArtifactDriver{Endpoint: server.URL, AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	art := &wfv1.Artifact{
+		ArtifactLocation: wfv1.ArtifactLocation{OSS: &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{Bucket: "my-bucket"}, Key: "my-dir"}},
+		Include:          []string{"**/*.jar"},
+		Exclude:          []string{"**/test/**"},
+	}
+	if assert.NoError(t, driver.Save(context.Background(), dir, art)) {
+		var keys []string
+		for key := range objects {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		assert.Equal(t, []string{"/my-bucket/my-dir/app.jar", "/my-bucket/my-dir/lib/dep.jar"}, keys)
+		assert.Equal(t, "lib/dep.jar", objects["/my-bucket/my-dir/lib/dep.jar"])
+	}
+}
+
+func TestOSSArtifactDriver_ContentType(t *testing.T) {
+	var lock sync.Mutex
+	contentTypes := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Oss-Hash-Crc64ecma", strconv.FormatUint(crc64.Checksum(data, crc64.MakeTable(crc64.ECMA)), 10))
+		lock.Lock()
+		defer lock.Unlock()
+		contentTypes[strings.TrimPrefix(r.URL.Path, "/my-bucket/")] = r.Header.Get("Content-Type")
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "oss")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	for name, data := range map[string]string{"data.json": "{}", "image": "\x89PNG\r\n\x1a\n", "log": "plain text"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600))
+	}
+	driver := &OSSArtifactDriver{Endpoint: server.URL, AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	newArtifact := func(key, contentType string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{OSS: &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{Bucket: "my-bucket"}, Key: key}}, ContentType: contentType}
+	}
+
+	t.Run("Detected", func(t *testing.T) {
+		if !assert.NoError(t, driver.Save(context.Background(), dir, newArtifact("detected", ""))) {
+			return
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		assert.Equal(t, "application/json", contentTypes["detected/data.json"])
+		assert.Equal(t, "image/png", contentTypes["detected/image"])
+		assert.Equal(t, "text/plain; charset=utf-8", contentTypes["detected/log"])
+	})
+	t.Run("Override", func(t *testing.T) {
+		if assert.NoError(t, driver.Save(context.Background(), filepath.Join(dir, "image"), newArtifact("override", "image/x-custom"))) {
+			lock.Lock()
+			defer lock.Unlock()
+			assert.Equal(t, "image/x-custom", contentTypes["override"])
+		}
+	})
+}
+
+func TestOSSArtifactDriver_Presign(t *testing.T) {
+	driver := &OSSArtifactDriver{Endpoint: "https://oss-cn-hangzhou.aliyuncs.com", AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{OSS: &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{Bucket: "my-bucket"}, Key: "my-dir/my-file"}}}
+
+	t.Run("Get", func(t *testing.T) {
+		presigned, err := driver.Presign(art, http.MethodGet, 90*time.Minute)
+		if !assert.NoError(t, err) {
+			return
+		}
+		u, err := url.Parse(presigned)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "https", u.Scheme)
+		assert.Equal(t, "my-bucket.oss-cn-hangzhou.aliyuncs.com", u.Host)
+		assert.Equal(t, "/my-dir/my-file", u.Path)
+		query := u.Query()
+		assert.Equal(t, "my-access-key", query.Get("OSSAccessKeyId"))
+		assert.NotEmpty(t, query.Get("Signature"))
+		expires, err := strconv.ParseInt(query.Get("Expires"), 10, 64)
+		if assert.NoError(t, err) {
+			assert.WithinDuration(t, time.Now().Add(90*time.Minute), time.Unix(expires, 0), time.Minute)
+		}
+	})
+	t.Run("SecurityToken", func(t *testing.T) {
+		driver := &OSSArtifactDriver{Endpoint: driver.Endpoint, AccessKey: "my-access-key", SecretKey: "my-secret-key", SecurityToken: "my-security-token"}
+		presigned, err := driver.Presign(art, http.MethodPut, time.Hour)
+		if assert.NoError(t, err) {
+			u, err := url.Parse(presigned)
+			if assert.NoError(t, err) {
+				assert.Equal(t, "my-security-token", u.Query().Get("security-token"))
+			}
+		}
+	})
+	t.Run("Directory", func(t *testing.T) {
+		dir := art.DeepCopy()
+		dir.OSS.Key = "my-dir/"
+		_, err := driver.Presign(dir, http.MethodGet, time.Hour)
+		assert.Error(t, err)
+	})
+}
+
+// reportedObject is the ETag and version ID a server reported an object was put with
+type reportedObject struct {
+	etag, versionID string
+}
+
+func TestOSSArtifactDriver_SaveWithResult(t *testing.T) {
+	var lock sync.Mutex
+	reported := map[string]reportedObject{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Oss-Hash-Crc64ecma", strconv.FormatUint(crc64.Checksum(data, crc64.MakeTable(crc64.ECMA)), 10))
+		lock.Lock()
+		defer lock.Unlock()
+		key := strings.TrimPrefix(r.URL.Path, "/my-bucket/")
+		object := reportedObject{etag: fmt.Sprintf("%X", md5.Sum(data)), versionID: fmt.Sprintf("version-%d", len(reported))}
+		reported[key] = object
+		w.Header().Set("ETag", `"`+object.etag+`"`)
+		w.Header().Set("X-Oss-Version-Id", object.versionID)
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "oss")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	for _, rel := range []string{"a", "sub/b"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(rel)), 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, rel), []byte(rel), 0600))
+	}
+	driver := &OSSArtifactDriver{Endpoint: server.URL, AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	newArtifact := func(key string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{OSS: &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{Bucket: "my-bucket"}, Key: key}}}
+	}
+
+	t.Run("File", func(t *testing.T) {
+		path := filepath.Join(dir, "sub", "b")
+		result, err := driver.SaveWithResult(context.Background(), path, newArtifact("my-file"), "", nil)
+		if assert.NoError(t, err) {
+			lock.Lock()
+			defer lock.Unlock()
+			assert.Equal(t, &common.SaveResult{
+				Location: "oss://my-bucket/my-file",
+				Objects:  []common.SavedObject{{Key: "my-file", Path: path, Size: 5, ETag: reported["my-file"].etag, VersionID: reported["my-file"].versionID}},
+				Size:     5,
+			}, result)
+		}
+	})
+	t.Run("Directory", func(t *testing.T) {
+		result, err := driver.SaveWithResult(context.Background(), dir, newArtifact("my-dir"), "", nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		// each file of the directory is in the manifest, as OSS reported it was put
+		assert.Equal(t, &common.SaveResult{
+			Location: "oss://my-bucket/my-dir",
+			Objects: []common.SavedObject{
+				{Key: "my-dir/a", Path: filepath.Join(dir, "a"), Size: 1, ETag: reported["my-dir/a"].etag, VersionID: reported["my-dir/a"].versionID},
+				{Key: "my-dir/sub/b", Path: filepath.Join(dir, "sub", "b"), Size: 5, ETag: reported["my-dir/sub/b"].etag, VersionID: reported["my-dir/sub/b"].versionID},
+			},
+			Size: 6,
+		}, result)
+		assert.NotEmpty(t, reported["my-dir/a"].etag)
+	})
+}
+
+func TestOSSArtifactDriver_Overwrite(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "oss")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+	path := filepath.Join(tmp, "my-file")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("new"), 0600))
+	dir := filepath.Join(tmp, "my-dir")
+	assert.NoError(t, os.MkdirAll(dir, 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("new"), 0600))
+	newArtifact := func(key string, overwrite wfv1.ArtifactOverwrite) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{OSS: &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{Bucket: "my-bucket"}, Key: key}}, Overwrite: overwrite}
+	}
+	for _, overwrite := range []wfv1.ArtifactOverwrite{wfv1.ArtifactOverwriteAllow, wfv1.ArtifactOverwriteFailIfExists, wfv1.ArtifactOverwriteSkipIfExists} {
+		t.Run(string(overwrite), func(t *testing.T) {
+			var lock sync.Mutex
+			objects := map[string]string{"/my-bucket/my-file": "old", "/my-bucket/my-dir/b": "old"}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				lock.Lock()
+				defer lock.Unlock()
+				switch {
+				case r.Method == http.MethodGet && r.URL.Path == "/my-bucket/":
+					prefix := r.URL.Query().Get("prefix")
+					_, _ = fmt.Fprintf(w, `<ListBucketResult><Name>my-bucket</Name><Prefix>%s</Prefix><MaxKeys>1</MaxKeys><IsTruncated>false</IsTruncated>`, prefix)
+					for key := range objects {
+						if strings.HasPrefix(key, "/my-bucket/"+prefix) {
+							_, _ = fmt.Fprintf(w, `<Contents><Key>%s</Key></Contents>`, strings.TrimPrefix(key, "/my-bucket/"))
+						}
+					}
+					_, _ = fmt.Fprint(w, `</ListBucketResult>`)
+				case r.Method == http.MethodPut:
+					if _, ok := objects[r.URL.Path]; ok && r.Header.Get("X-Oss-Forbid-Overwrite") == "true" {
+						w.WriteHeader(http.StatusConflict)
+						_, _ = fmt.Fprint(w, `<Error><Code>FileAlreadyExists</Code><Message>The object you specified already exists and can not be overwritten.</Message></Error>`)
+						return
+					}
+					data, _ := ioutil.ReadAll(r.Body)
+					w.Header().Set("X-Oss-Hash-Crc64ecma", strconv.FormatUint(crc64.Checksum(data, crc64.MakeTable(crc64.ECMA)), 10))
+					objects[r.URL.Path] = string(data)
+				default:
+					w.WriteHeader(http.StatusMethodNotAllowed)
+				}
+			}))
+			defer server.Close()
+			driver := &OSSArtifactDriver{Endpoint: server.URL, AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+			// a destination which does not exist is saved whatever the policy
+			assert.NoError(t, driver.Save(context.Background(), path, newArtifact("my-new-file", overwrite)))
+			assert.Equal(t, "new", objects["/my-bucket/my-new-file"])
+			assert.NoError(t, driver.Save(context.Background(), dir, newArtifact("my-new-dir", overwrite)))
+			assert.Equal(t, "new", objects["/my-bucket/my-new-dir/a"])
+
+			err := driver.Save(context.Background(), path, newArtifact("my-file", overwrite))
+			dirErr := driver.Save(context.Background(), dir, newArtifact("my-dir", overwrite))
+			// the manifest of a directory is next to its objects, so it can exist without any object under its key
+			objects["/my-bucket/my-manifest-dir.manifest"] = "old"
+			manifestArt := newArtifact("my-manifest-dir", overwrite)
+			manifestArt.Manifest = wfv1.ArtifactManifestFormatText
+			manifestErr := driver.Save(context.Background(), dir, manifestArt)
+			if overwrite == wfv1.ArtifactOverwriteAllow {
+				assert.NoError(t, err)
+				assert.Equal(t, "new", objects["/my-bucket/my-file"])
+				assert.NoError(t, dirErr)
+				assert.Equal(t, "new", objects["/my-bucket/my-dir/a"])
+				assert.NoError(t, manifestErr)
+				assert.NotEqual(t, "old", objects["/my-bucket/my-manifest-dir.manifest"])
+				return
+			}
+			assert.True(t, errors.Is(manifestErr, common.ErrArtifactExists))
+			assert.Equal(t, "old", objects["/my-bucket/my-manifest-dir.manifest"])
+			// the driver fails whether the policy is fail-if-exists or skip-if-exists, which the caller skips
+			assert.True(t, errors.Is(err, common.ErrArtifactExists))
+			assert.Equal(t, "old", objects["/my-bucket/my-file"])
+			// a directory exists if any object does under it
+			assert.True(t, errors.Is(dirErr, common.ErrArtifactExists))
+			assert.NotContains(t, objects, "/my-bucket/my-dir/a")
+		})
+	}
+}
+
+func TestOSSArtifactDriver_LoadRange(t *testing.T) {
+	data := []byte("0123456789")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/my-bucket/my-file" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Range") == "" {
+			// the client verifies the CRC of objects which are downloaded in full
+			w.Header().Set("X-Oss-Hash-Crc64ecma", strconv.FormatUint(crc64.Checksum(data, crc64.MakeTable(crc64.ECMA)), 10))
+		}
+		http.ServeContent(w, r, "my-file", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "range")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	driver := &OSSArtifactDriver{Endpoint: server.URL, AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	newArtifact := func(key string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{OSS: &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{Bucket: "my-bucket"}, Key: key}}}
+	}
+	fullPath := filepath.Join(dir, "full")
+	if !assert.NoError(t, driver.Load(context.Background(), newArtifact("my-file"), fullPath)) {
+		return
+	}
+	full, err := ioutil.ReadFile(fullPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, r := range []struct{ start, length, end int64 }{{0, 4, 4}, {3, 5, 8}, {6, -1, 10}, {8, 100, 10}} {
+		path := filepath.Join(dir, "range")
+		if assert.NoError(t, driver.LoadRange(newArtifact("my-file"), r.start, r.length, path)) {
+			got, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, string(full[r.start:r.end]), string(got), "%v", r)
+		}
+	}
+	err = driver.LoadRange(newArtifact("not-found"), 0, 4, filepath.Join(dir, "not-found"))
+	assert.True(t, errors.Is(err, common.ErrArtifactNotFound))
+}
+
+func TestOSSArtifactDriver_DryRunSave(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		_, bucketInfo := r.URL.Query()["bucketInfo"]
+		switch {
+		case bucketInfo && strings.Trim(r.URL.Path, "/") == "my-bucket":
+			_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><BucketInfo><Bucket><Name>my-bucket</Name></Bucket></BucketInfo>`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchBucket</Code></Error>`))
+		}
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "oss")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "my-file")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("my-data"), 0600))
+	driver := &OSSArtifactDriver{Endpoint: server.URL, AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	newArtifact := func(bucket string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{OSS: &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{Bucket: bucket}, Key: "my-key"}}}
+	}
+	t.Run("Exists", func(t *testing.T) {
+		result, err := driver.DryRunSave(context.Background(), path, newArtifact("my-bucket"))
+		if assert.NoError(t, err) {
+			assert.Equal(t, "oss://my-bucket/my-key", result.Location)
+			assert.Equal(t, []common.DryRunObject{{Key: "my-key", Path: path, Size: 7}}, result.Objects)
+			assert.False(t, result.WritePermissionChecked)
+		}
+	})
+	t.Run("NoSuchBucket", func(t *testing.T) {
+		_, err := driver.DryRunSave(context.Background(), path, newArtifact("not-found"))
+		assert.EqualError(t, err, "bucket not-found does not exist")
+	})
+	// nothing is put
+	for _, method := range methods {
+		assert.Equal(t, http.MethodGet, method)
+	}
+}
+
+func TestOSSArtifactDriver_HealthCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		_, bucketInfo := r.URL.Query()["bucketInfo"]
+		switch {
+		case !bucketInfo:
+			w.WriteHeader(http.StatusBadRequest)
+		case !strings.Contains(r.Header.Get("Authorization"), "my-access-key"):
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><Error><Code>InvalidAccessKeyId</Code></Error>`))
+		case strings.Trim(r.URL.Path, "/") == "my-bucket":
+			_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><BucketInfo><Bucket><Name>my-bucket</Name></Bucket></BucketInfo>`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchBucket</Code></Error>`))
+		}
+	}))
+	defer server.Close()
+	newDriver := func(accessKey, bucket string) *OSSArtifactDriver {
+		return &OSSArtifactDriver{Endpoint: server.URL, AccessKey: accessKey, SecretKey: "my-secret-key", Bucket: bucket}
+	}
+	t.Run("Healthy", func(t *testing.T) {
+		assert.NoError(t, newDriver("my-access-key", "my-bucket").HealthCheck(context.Background()))
+	})
+	t.Run("NoSuchBucket", func(t *testing.T) {
+		assert.EqualError(t, newDriver("my-access-key", "not-found").HealthCheck(context.Background()), "bucket not-found does not exist")
+	})
+	t.Run("Unauthorized", func(t *testing.T) {
+		err := newDriver("other-access-key", "my-bucket").HealthCheck(context.Background())
+		var serviceErr oss.ServiceError
+		if assert.True(t, errors.As(err, &serviceErr)) {
+			assert.Equal(t, http.StatusForbidden, serviceErr.StatusCode)
+		}
+	})
+}
+
+func TestOSSArtifactDriver_Manifest(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "oss")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+	dir := filepath.Join(tmp, "my-dir")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "b"), []byte("bb"), 0600))
+	var lock sync.Mutex
+	objects := map[string]string{}
+	crc := func(data string) string {
+		return strconv.FormatUint(crc64.Checksum([]byte(data), crc64.MakeTable(crc64.ECMA)), 10)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		key := strings.TrimPrefix(r.URL.Path, "/my-bucket/")
+		switch {
+		case r.Method == http.MethodGet && key == "":
+			prefix := r.URL.Query().Get("prefix")
+			_, _ = fmt.Fprintf(w, `<ListBucketResult><Name>my-bucket</Name><Prefix>%s</Prefix><MaxKeys>100</MaxKeys><IsTruncated>false</IsTruncated>`, prefix)
+			for k := range objects {
+				if strings.HasPrefix(k, prefix) {
+					_, _ = fmt.Fprintf(w, `<Contents><Key>%s</Key></Contents>`, k)
+				}
+			}
+			_, _ = fmt.Fprint(w, `</ListBucketResult>`)
+		case r.Method == http.MethodGet:
+			data, ok := objects[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+				return
+			}
+			w.Header().Set("X-Oss-Hash-Crc64ecma", crc(data))
+			_, _ = w.Write([]byte(data))
+		case r.Method == http.MethodPut:
+			data, _ := ioutil.ReadAll(r.Body)
+			w.Header().Set("X-Oss-Hash-Crc64ecma", crc(string(data)))
+			objects[key] = string(data)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+	driver := &OSSArtifactDriver{Endpoint: server.URL, AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{OSS: &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{Bucket: "my-bucket"}, Key: "my-dir"}}, Manifest: wfv1.ArtifactManifestFormatText}
+	if !assert.NoError(t, driver.Save(context.Background(), dir, art)) {
+		return
+	}
+	assert.Equal(t, "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb 1 a\n"+
+		"3b64db95cb55c763391c707108489ae18b4112d783300de38e033b4c98c3deaf 2 sub/b\n", objects["my-dir.manifest"])
+
+	t.Run("Verified", func(t *testing.T) {
+		path := filepath.Join(tmp, "verified")
+		if assert.NoError(t, driver.Load(context.Background(), art, path)) {
+			data, err := ioutil.ReadFile(filepath.Join(path, "sub", "b"))
+			assert.NoError(t, err)
+			assert.Equal(t, "bb", string(data))
+		}
+	})
+	t.Run("Corrupt", func(t *testing.T) {
+		objects["my-dir/sub/b"] = "cc"
+		defer func() { objects["my-dir/sub/b"] = "bb" }()
+		assert.Error(t, driver.Load(context.Background(), art, filepath.Join(tmp, "corrupt")))
+	})
+	t.Run("Missing", func(t *testing.T) {
+		delete(objects, "my-dir/a")
+		assert.Error(t, driver.Load(context.Background(), art, filepath.Join(tmp, "missing")))
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		err := driver.Load(context.Background(), &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{OSS: &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{Bucket: "my-bucket"}, Key: "not-found"}}}, filepath.Join(tmp, "not-found"))
+		assert.True(t, errors.Is(err, common.ErrArtifactNotFound), err)
+	})
+}
+
+func TestOSSArtifactDriver_SkipIfUnchanged(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "oss")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+	path := filepath.Join(tmp, "my-file")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("hello"), 0600))
+	dir := filepath.Join(tmp, "my-dir")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "b"), []byte("bb"), 0600))
+	var lock sync.Mutex
+	objects := map[string]string{"my-md5-file": "hello"}
+	metadata := map[string]http.Header{}
+	var puts []string
+	crc := func(data string) string {
+		return strconv.FormatUint(crc64.Checksum([]byte(data), crc64.MakeTable(crc64.ECMA)), 10)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		key := strings.TrimPrefix(r.URL.Path, "/my-bucket/")
+		data, ok := objects[key]
+		switch {
+		case r.Method == http.MethodHead || r.Method == http.MethodGet && key != "":
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			for k, v := range metadata[key] {
+				w.Header()[k] = v
+			}
+			w.Header().Set("ETag", fmt.Sprintf(`"%X"`, md5.Sum([]byte(data))))
+			w.Header().Set("X-Oss-Hash-Crc64ecma", crc(data))
+			if r.Method == http.MethodGet {
+				_, _ = w.Write([]byte(data))
+			}
+		case r.Method == http.MethodPut:
+			body, _ := ioutil.ReadAll(r.Body)
+			objects[key] = string(body)
+			metadata[key] = http.Header{}
+			for k, v := range r.Header {
+				if strings.HasPrefix(k, "X-Oss-Meta-") {
+					metadata[key][k] = v
+				}
+			}
+			puts = append(puts, key)
+			w.Header().Set("ETag", fmt.Sprintf(`"%X"`, md5.Sum(body)))
+			w.Header().Set("X-Oss-Hash-Crc64ecma", crc(string(body)))
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+	driver := &OSSArtifactDriver{Endpoint: server.URL, AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	newArtifact := func(key string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{OSS: &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{Bucket: "my-bucket"}, Key: key}}, Overwrite: wfv1.ArtifactOverwriteSkipIfUnchanged}
+	}
+	save := func(path string, art *wfv1.Artifact) (*common.SaveResult, []string) {
+		puts = nil
+		result, err := driver.SaveWithResult(context.Background(), path, art, "", nil)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		sort.Strings(puts)
+		return result, puts
+	}
+	assert.True(t, driver.SkipsUnchanged())
+
+	t.Run("File", func(t *testing.T) {
+		result, puts := save(path, newArtifact("my-file"))
+		assert.False(t, result.Unchanged)
+		assert.Equal(t, []string{"my-file"}, puts)
+		assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", metadata["my-file"].Get("X-Oss-Meta-"+common.ContentSHA256MetadataKey))
+		// an identical re-save puts nothing
+		result, puts = save(path, newArtifact("my-file"))
+		assert.True(t, result.Unchanged)
+		assert.Empty(t, puts)
+		// a changed file is put
+		changed := filepath.Join(tmp, "my-changed-file")
+		assert.NoError(t, ioutil.WriteFile(changed, []byte("world"), 0600))
+		result, puts = save(changed, newArtifact("my-file"))
+		assert.False(t, result.Unchanged)
+		assert.Equal(t, []string{"my-file"}, puts)
+		assert.Equal(t, "world", objects["my-file"])
+	})
+	t.Run("ETag", func(t *testing.T) {
+		// an object without a recorded sha256 is compared by its ETag
+		result, puts := save(path, newArtifact("my-md5-file"))
+		assert.True(t, result.Unchanged)
+		assert.Empty(t, puts)
+	})
+	t.Run("Directory", func(t *testing.T) {
+		result, puts := save(dir, newArtifact("my-dir"))
+		assert.False(t, result.Unchanged)
+		assert.Equal(t, []string{"my-dir.manifest", "my-dir/a", "my-dir/sub/b"}, puts)
+		result, puts = save(dir, newArtifact("my-dir"))
+		assert.True(t, result.Unchanged)
+		assert.Empty(t, puts)
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "b"), []byte("cc"), 0600))
+		result, puts = save(dir, newArtifact("my-dir"))
+		assert.False(t, result.Unchanged)
+		assert.Equal(t, []string{"my-dir.manifest", "my-dir/a", "my-dir/sub/b"}, puts)
+		assert.Equal(t, "cc", objects["my-dir/sub/b"])
+	})
+}
+
+func TestOSSArtifactDriver_AtomicSave(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "oss")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+	dir := filepath.Join(tmp, "my-dir")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "b"), []byte("bb"), 0600))
+	var lock sync.Mutex
+	var objects map[string]string
+	var puts, copies []string
+	failKey := ""
+	crc := func(data string) string {
+		return strconv.FormatUint(crc64.Checksum([]byte(data), crc64.MakeTable(crc64.ECMA)), 10)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		key := strings.TrimPrefix(r.URL.Path, "/my-bucket/")
+		switch {
+		case r.Method == http.MethodGet && key == "":
+			prefix := r.URL.Query().Get("prefix")
+			_, _ = fmt.Fprintf(w, `<ListBucketResult><Name>my-bucket</Name><Prefix>%s</Prefix><MaxKeys>100</MaxKeys><IsTruncated>false</IsTruncated>`, prefix)
+			for k := range objects {
+				if strings.HasPrefix(k, prefix) {
+					_, _ = fmt.Fprintf(w, `<Contents><Key>%s</Key></Contents>`, k)
+				}
+			}
+			_, _ = fmt.Fprint(w, `</ListBucketResult>`)
+		case r.Method == http.MethodPut && r.Header.Get("X-Oss-Copy-Source") != "":
+			src, _ := url.QueryUnescape(strings.TrimPrefix(r.Header.Get("X-Oss-Copy-Source"), "/my-bucket/"))
+			data, ok := objects[src]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+				return
+			}
+			copies = append(copies, key)
+			objects[key] = data
+			_, _ = fmt.Fprintf(w, `<CopyObjectResult><LastModified>2020-09-13T12:26:40.000Z</LastModified><ETag>"%X"</ETag></CopyObjectResult>`, md5.Sum([]byte(data)))
+		case r.Method == http.MethodPut:
+			if failKey != "" && strings.HasSuffix(key, failKey) {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = fmt.Fprint(w, `<Error><Code>AccessDenied</Code><Message>Access denied.</Message></Error>`)
+				return
+			}
+			data, _ := ioutil.ReadAll(r.Body)
+			puts = append(puts, key)
+			w.Header().Set("X-Oss-Hash-Crc64ecma", crc(string(data)))
+			objects[key] = string(data)
+		case r.Method == http.MethodDelete:
+			delete(objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+	driver := &OSSArtifactDriver{Endpoint: server.URL, AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{OSS: &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{Bucket: "my-bucket"}, Key: "my-prefix/my-dir"}}, AtomicSave: true}
+	keys := func() []string {
+		var keys []string
+		for key := range objects {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	assert.True(t, driver.SavesAtomically())
+
+	t.Run("Saved", func(t *testing.T) {
+		objects, puts, copies, failKey = map[string]string{}, nil, nil, ""
+		result, err := driver.SaveWithResult(context.Background(), dir, art, "", nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		// the files were put under the staging key, copied to the key, and the staging objects deleted
+		if assert.Len(t, puts, 2) {
+			for _, put := range puts {
+				assert.True(t, strings.HasPrefix(put, "my-prefix/.my-dir.argo-staging-"), put)
+			}
+		}
+		assert.ElementsMatch(t, []string{"my-prefix/my-dir/a", "my-prefix/my-dir/sub/b"}, copies)
+		assert.Equal(t, []string{"my-prefix/my-dir/a", "my-prefix/my-dir/sub/b"}, keys())
+		assert.Equal(t, "bb", objects["my-prefix/my-dir/sub/b"])
+		if assert.Len(t, result.Objects, 2) {
+			sort.Slice(result.Objects, func(i, j int) bool { return result.Objects[i].Key < result.Objects[j].Key })
+			assert.Equal(t, common.SavedObject{Key: "my-prefix/my-dir/sub/b", Path: filepath.Join(dir, "sub", "b"), Size: 2, ETag: fmt.Sprintf("%X", md5.Sum([]byte("bb")))}, result.Objects[1])
+		}
+	})
+	t.Run("Failed", func(t *testing.T) {
+		objects, puts, copies, failKey = map[string]string{}, nil, nil, "/sub/b"
+		err := driver.Save(context.Background(), dir, art)
+		assert.Error(t, err)
+		// nothing was copied to the key, and what was put under the staging key was deleted
+		assert.Empty(t, copies)
+		assert.Empty(t, keys())
+	})
+}
+
+func TestParsePartSize(t *testing.T) {
+	for size, expected := range map[string]int64{"": 0, "100Ki": 100 * 1024, "64Mi": 64 * 1024 * 1024, "5Gi": 5 * 1024 * 1024 * 1024} {
+		partSize, err := ParsePartSize(size)
+		assert.NoError(t, err, size)
+		assert.Equal(t, expected, partSize, size)
+	}
+	for _, size := range []string{"99Ki", "6Gi", "big"} {
+		_, err := ParsePartSize(size)
+		assert.Error(t, err, size)
+	}
+	assert.NoError(t, ValidateArtifact("my-art.oss", &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{PartSize: "64Mi"}}))
+	assert.EqualError(t, ValidateArtifact("my-art.oss", &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{PartSize: "1Ki"}}), "my-art.oss.partSize must be between 100Ki and 5Gi")
+}
+
+func Test_objectPartSize(t *testing.T) {
+	assert.Equal(t, int64(defaultPartSize), objectPartSize(0, 1024))
+	assert.Equal(t, int64(minPartSize), objectPartSize(minPartSize, 1024*1024))
+	// a file which would have more than maxParts parts has parts of whole MiB
+	assert.Equal(t, int64(2*1024*1024), objectPartSize(minPartSize, maxParts*1024*1024+1))
+}
+
+func TestOSSArtifactDriver_Multipart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oss")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	large := bytes.Repeat([]byte("0123456789"), 25600)
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "large"), large, 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "small"), []byte("small"), 0600))
+	var lock sync.Mutex
+	objects := map[string]string{}
+	// parts are the parts of the multipart uploads in progress by their upload IDs and part numbers
+	parts := map[string]map[int]string{}
+	var puts, uploads []string
+	aborted := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		key := strings.TrimPrefix(r.URL.Path, "/my-bucket/")
+		query := r.URL.Query()
+		_, initiate := query["uploads"]
+		uploadID := query.Get("uploadId")
+		switch {
+		case r.Method == http.MethodGet && key == "":
+			// list a single object a page, so that listing a directory takes more than one
+			prefix, marker := query.Get("prefix"), query.Get("marker")
+			var keys []string
+			for k := range objects {
+				if strings.HasPrefix(k, prefix) && k > marker {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			_, _ = fmt.Fprintf(w, `<ListBucketResult><Name>my-bucket</Name><Prefix>%s</Prefix><MaxKeys>1</MaxKeys>`, prefix)
+			if len(keys) > 1 {
+				_, _ = fmt.Fprintf(w, `<IsTruncated>true</IsTruncated><NextMarker>%s</NextMarker>`, keys[0])
+			} else {
+				_, _ = fmt.Fprint(w, `<IsTruncated>false</IsTruncated>`)
+			}
+			if len(keys) > 0 {
+				_, _ = fmt.Fprintf(w, `<Contents><Key>%s</Key></Contents>`, keys[0])
+			}
+			_, _ = fmt.Fprint(w, `</ListBucketResult>`)
+		case r.Method == http.MethodGet:
+			data, ok := objects[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+				return
+			}
+			_, _ = fmt.Fprint(w, data)
+		case r.Method == http.MethodPost && initiate:
+			uploadID := fmt.Sprintf("upload-%d", len(uploads))
+			uploads = append(uploads, key)
+			parts[uploadID] = map[int]string{}
+			_, _ = fmt.Fprintf(w, `<InitiateMultipartUploadResult><Bucket>my-bucket</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>`, key, uploadID)
+		case r.Method == http.MethodPut && uploadID != "":
+			number, _ := strconv.Atoi(query.Get("partNumber"))
+			data, _ := ioutil.ReadAll(r.Body)
+			parts[uploadID][number] = string(data)
+			w.Header().Set("ETag", fmt.Sprintf(`"%X"`, md5.Sum(data)))
+			w.Header().Set("X-Oss-Hash-Crc64ecma", strconv.FormatUint(crc64.Checksum(data, crc64.MakeTable(crc64.ECMA)), 10))
+		case r.Method == http.MethodPost && uploadID != "":
+			var data strings.Builder
+			for number := 1; number <= len(parts[uploadID]); number++ {
+				data.WriteString(parts[uploadID][number])
+			}
+			objects[key] = data.String()
+			delete(parts, uploadID)
+			_, _ = fmt.Fprintf(w, `<CompleteMultipartUploadResult><Bucket>my-bucket</Bucket><Key>%s</Key><ETag>"my-etag"</ETag></CompleteMultipartUploadResult>`, key)
+		case r.Method == http.MethodDelete && uploadID != "":
+			aborted++
+			delete(parts, uploadID)
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPut:
+			data, _ := ioutil.ReadAll(r.Body)
+			puts = append(puts, key)
+			w.Header().Set("X-Oss-Hash-Crc64ecma", strconv.FormatUint(crc64.Checksum(data, crc64.MakeTable(crc64.ECMA)), 10))
+			objects[key] = string(data)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+	driver := &OSSArtifactDriver{Endpoint: server.URL, AccessKey: "my-access-key", SecretKey: "my-secret-key", PartSize: minPartSize}
+	art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{OSS: &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{Bucket: "my-bucket"}, Key: "my-dir"}}}
+
+	result, err := driver.SaveWithResult(context.Background(), dir, art, "", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// the large file was uploaded in three parts, and the small one put
+	assert.Equal(t, []string{"my-dir/sub/large"}, uploads)
+	assert.Equal(t, []string{"my-dir/small"}, puts)
+	assert.Empty(t, parts)
+	assert.Zero(t, aborted)
+	assert.Equal(t, string(large), objects["my-dir/sub/large"])
+	if assert.Len(t, result.Objects, 2) {
+		sort.Slice(result.Objects, func(i, j int) bool { return result.Objects[i].Key < result.Objects[j].Key })
+		assert.Equal(t, common.SavedObject{Key: "my-dir/sub/large", Path: filepath.Join(dir, "sub", "large"), Size: int64(len(large)), ETag: "my-etag"}, result.Objects[1])
+	}
+
+	loaded, err := ioutil.TempDir("", "oss")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(loaded) }()
+	if assert.NoError(t, driver.Load(context.Background(), art, loaded)) {
+		data, err := ioutil.ReadFile(filepath.Join(loaded, "sub", "large"))
+		assert.NoError(t, err)
+		assert.Equal(t, large, data)
+		data, err = ioutil.ReadFile(filepath.Join(loaded, "small"))
+		assert.NoError(t, err)
+		assert.Equal(t, "small", string(data))
+	}
+}
+
+func TestOSSArtifactDriver_SelectLatest(t *testing.T) {
+	objects := map[string]string{
+		"data/data-20240101.csv":  "1",
+		"data/data-20240102.csv":  "2",
+		"data/data-20240103.csv":  "3",
+		"other/data-20240104.csv": "4",
+	}
+	// the object with the greatest key is not that modified last
+	lastModified := map[string]string{
+		"data/data-20240101.csv":  "2024-01-01T00:00:00.000Z",
+		"data/data-20240102.csv":  "2024-01-05T00:00:00.000Z",
+		"data/data-20240103.csv":  "2024-01-03T00:00:00.000Z",
+		"other/data-20240104.csv": "2024-01-06T00:00:00.000Z",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/my-bucket/")
+		switch {
+		case r.Method == http.MethodGet && key == "":
+			prefix := r.URL.Query().Get("prefix")
+			_, _ = fmt.Fprintf(w, `<ListBucketResult><Name>my-bucket</Name><Prefix>%s</Prefix><MaxKeys>100</MaxKeys><IsTruncated>false</IsTruncated>`, prefix)
+			for k := range objects {
+				if strings.HasPrefix(k, prefix) {
+					_, _ = fmt.Fprintf(w, `<Contents><Key>%s</Key><LastModified>%s</LastModified><Size>%d</Size></Contents>`, k, lastModified[k], len(objects[k]))
+				}
+			}
+			_, _ = fmt.Fprint(w, `</ListBucketResult>`)
+		case r.Method == http.MethodGet:
+			data, ok := objects[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+				return
+			}
+			_, _ = fmt.Fprint(w, data)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+	tmp, err := ioutil.TempDir("", "oss")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+	driver := &OSSArtifactDriver{Endpoint: server.URL, AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	newArtifact := func(key string, policy wfv1.ArtifactSelectLatest) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{OSS: &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{Bucket: "my-bucket"}, Key: key}}, SelectLatest: policy}
+	}
+
+	for policy, expected := range map[wfv1.ArtifactSelectLatest]string{wfv1.ArtifactSelectLatestLastModified: "2", wfv1.ArtifactSelectLatestLexical: "3"} {
+		t.Run(string(policy), func(t *testing.T) {
+			path := filepath.Join(tmp, string(policy))
+			if assert.NoError(t, driver.Load(context.Background(), newArtifact("data/data-", policy), path)) {
+				data, err := ioutil.ReadFile(path)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, string(data))
+			}
+		})
+	}
+	t.Run("None", func(t *testing.T) {
+		err := driver.Load(context.Background(), newArtifact("data/report-", wfv1.ArtifactSelectLatestLexical), filepath.Join(tmp, "none"))
+		assert.EqualError(t, err, "no object has the key prefix data/report- to select the latest of")
+		assert.True(t, errors.Is(err, common.ErrArtifactNotFound))
+	})
+}
+
+func TestOSSArtifactDriver_LoadInterrupted(t *testing.T) {
+	data := "0123456789"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the connection is dropped after half of the object
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		_, _ = fmt.Fprint(w, data[:5])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "interrupted")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	driver := &OSSArtifactDriver{Endpoint: server.URL, AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{OSS: &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{Bucket: "my-bucket"}, Key: "my-file"}}}
+
+	path := filepath.Join(dir, "my-file")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("prior"), 0600))
+	assert.Error(t, driver.Load(context.Background(), art, path))
+	got, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "prior", string(got))
+	// nothing partially written is left next to the path, including the temporary file of the client
+	infos, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	if assert.Len(t, infos, 1) {
+		assert.Equal(t, "my-file", infos[0].Name())
+	}
+}
+
+func TestOSSArtifactDriver_MaxSize(t *testing.T) {
+	var gets int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			gets++
+		}
+		w.Header().Set("Content-Length", "10")
+		if r.Method == http.MethodGet {
+			_, _ = fmt.Fprint(w, "0123456789")
+		}
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "max-size")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	driver := &OSSArtifactDriver{Endpoint: server.URL, AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	newArtifact := func(maxSize string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{OSS: &wfv1.OSSArtifact{OSSBucket: wfv1.OSSBucket{Bucket: "my-bucket"}, Key: "my-file"}}, MaxSize: maxSize}
+	}
+
+	err = driver.Load(context.Background(), newArtifact("9"), filepath.Join(dir, "too-large"))
+	assert.True(t, errors.Is(err, common.ErrArtifactTooLarge))
+	// the object is not downloaded, as its size is checked first
+	assert.Equal(t, 0, gets)
+	assert.NoFileExists(t, filepath.Join(dir, "too-large"))
+	assert.NoError(t, driver.Load(context.Background(), newArtifact("10"), filepath.Join(dir, "at-limit")))
+	assert.Equal(t, 1, gets)
+}
@@ -1,29 +1,53 @@
 package raw
 
 import (
+	"context"
+	"io"
+	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/argoproj/argo-workflows/v3/errors"
 	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
 )
 
 type RawArtifactDriver struct{}
 
-// Store raw content as artifact
-func (a *RawArtifactDriver) Load(artifact *wfv1.Artifact, path string) error {
-	lf, err := os.Create(path)
-	if err != nil {
+// Store raw content as artifact, in a temporary file which only replaces path once it is written
+func (a *RawArtifactDriver) Load(_ context.Context, artifact *wfv1.Artifact, path string) error {
+	return common.LoadAtomically(path, func(path string) error {
+		lf, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = lf.Close()
+		}()
+
+		_, err = lf.WriteString(artifact.Raw.Data)
 		return err
-	}
-	defer func() {
-		_ = lf.Close()
-	}()
+	})
+}
 
-	_, err = lf.WriteString(artifact.Raw.Data)
-	return err
+// OpenStream returns a reader of the raw content
+func (a *RawArtifactDriver) OpenStream(artifact *wfv1.Artifact) (io.ReadCloser, error) {
+	return ioutil.NopCloser(strings.NewReader(artifact.Raw.Data)), nil
 }
 
 // Save is unsupported for raw output artifacts
-func (g *RawArtifactDriver) Save(string, *wfv1.Artifact) error {
+func (g *RawArtifactDriver) Save(context.Context, string, *wfv1.Artifact) error {
 	return errors.Errorf(errors.CodeBadRequest, "Raw output artifacts unsupported")
 }
+
+// Delete is unsupported for raw artifacts
+func (a *RawArtifactDriver) Delete(*wfv1.Artifact) error {
+	return common.ErrDeletionNotSupported
+}
+
+// Capabilities returns the optional operations on raw artifacts the driver supports
+func (a *RawArtifactDriver) Capabilities() common.DriverCapabilities {
+	return common.DriverCapabilities{
+		Stream: true,
+	}
+}
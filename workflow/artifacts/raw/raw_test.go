@@ -1,6 +1,7 @@
 package raw_test
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -28,10 +29,35 @@ func TestLoad(t *testing.T) {
 		Data: content,
 	}
 	driver := &raw.RawArtifactDriver{}
-	err = driver.Load(art, lf.Name())
+	err = driver.Load(context.Background(), art, lf.Name())
 	assert.NoError(t, err)
 
 	dat, err := ioutil.ReadFile(lf.Name())
 	assert.NoError(t, err)
 	assert.Equal(t, content, string(dat))
 }
+
+func TestOpenStream(t *testing.T) {
+	content := fmt.Sprintf("time: %v", time.Now().UnixNano())
+	lf, err := ioutil.TempFile("", LoadFileName)
+	assert.NoError(t, err)
+	defer os.Remove(lf.Name())
+
+	art := &wfv1.Artifact{}
+	art.Raw = &wfv1.RawArtifact{
+		Data: content,
+	}
+	driver := &raw.RawArtifactDriver{}
+	err = driver.Load(context.Background(), art, lf.Name())
+	assert.NoError(t, err)
+	loaded, err := ioutil.ReadFile(lf.Name())
+	assert.NoError(t, err)
+
+	stream, err := driver.OpenStream(art)
+	if assert.NoError(t, err) {
+		defer stream.Close()
+		streamed, err := ioutil.ReadAll(stream)
+		assert.NoError(t, err)
+		assert.Equal(t, loaded, streamed)
+	}
+}
@@ -0,0 +1,143 @@
+package rclone
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const (
+	// the exit codes of rclone when the directory, or the file, it is given is not found
+	exitCodeDirNotFound  = 3
+	exitCodeFileNotFound = 4
+)
+
+// ArtifactDriver is the artifact driver for the remotes of rclone, which it runs with the config file of the
+// artifact. rclone must be on the PATH of the executor.
+type ArtifactDriver struct {
+	// Config is the content of the rclone config file which configures the remotes
+	Config string
+	// binary is the rclone binary which is run, "rclone" if not set
+	binary string
+	common.Logging
+}
+
+// remotePath returns the remote:path argument of rclone for the artifact
+func remotePath(art *wfv1.RcloneArtifact) string {
+	return art.Remote + ":" + art.Path
+}
+
+// Load copies a file or directory from the remote to path
+func (d *ArtifactDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, path string) error {
+	d.Log().Infof("Rclone Load path: %s, remote: %s, path: %s", path, inputArtifact.Rclone.Remote, inputArtifact.Rclone.Path)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	// copyto copies a file to a file, and a directory to a directory, of the name it is given
+	if _, err := d.run(ctx, "copyto", remotePath(inputArtifact.Rclone), path); err != nil {
+		_ = os.RemoveAll(path)
+		return err
+	}
+	return nil
+}
+
+// Save copies the file or directory at path to the remote
+func (d *ArtifactDriver) Save(ctx context.Context, path string, outputArtifact *wfv1.Artifact) error {
+	d.Log().Infof("Rclone Save path: %s, remote: %s, path: %s", path, outputArtifact.Rclone.Remote, outputArtifact.Rclone.Path)
+	_, err := d.run(ctx, "copyto", path, remotePath(outputArtifact.Rclone))
+	return err
+}
+
+// Delete deletes the file, or the directory and everything in it, from the remote
+func (d *ArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	d.Log().Infof("Rclone Delete remote: %s, path: %s", artifact.Rclone.Remote, artifact.Rclone.Path)
+	ctx := context.Background()
+	isDir, err := d.isDir(ctx, artifact.Rclone)
+	if err != nil {
+		return err
+	}
+	command := "deletefile"
+	if isDir {
+		command = "purge"
+	}
+	if _, err := d.run(ctx, command, remotePath(artifact.Rclone)); err != nil {
+		return fmt.Errorf("Rclone delete of %s failed: %w", remotePath(artifact.Rclone), err)
+	}
+	return nil
+}
+
+// Capabilities returns the optional operations on rclone artifacts the driver supports
+func (d *ArtifactDriver) Capabilities() common.DriverCapabilities {
+	return common.DriverCapabilities{
+		Delete: true,
+	}
+}
+
+// isDir returns whether the path of the artifact is a directory of the remote
+func (d *ArtifactDriver) isDir(ctx context.Context, art *wfv1.RcloneArtifact) (bool, error) {
+	output, err := d.run(ctx, "lsjson", "--stat", remotePath(art))
+	if err != nil {
+		return false, err
+	}
+	var stat struct {
+		IsDir bool `json:"IsDir"`
+	}
+	if err := json.Unmarshal(output, &stat); err != nil {
+		return false, fmt.Errorf("failed to parse the stat of %s: %w", remotePath(art), err)
+	}
+	return stat.IsDir, nil
+}
+
+// run runs rclone with the config file, returning its output. If it fails, the error is the last line rclone
+// logged, and it matches common.ErrArtifactNotFound if the file or directory it was given was not found.
+func (d *ArtifactDriver) run(ctx context.Context, args ...string) ([]byte, error) {
+	config, err := common.CreateTemp("rclone.conf.")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.Remove(config.Name()) }()
+	if _, err := config.WriteString(d.Config); err != nil {
+		_ = config.Close()
+		return nil, err
+	}
+	if err := config.Close(); err != nil {
+		return nil, err
+	}
+	binary := d.binary
+	if binary == "" {
+		binary = "rclone"
+	}
+	cmd := exec.CommandContext(ctx, binary, append([]string{"--config", config.Name()}, args...)...)
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !stderrors.As(err, &exitErr) {
+			return nil, err
+		}
+		d.Log().Errorf("`rclone %s` stderr:\n%s", strings.Join(args, " "), string(exitErr.Stderr))
+		msg := lastLine(string(exitErr.Stderr))
+		if msg == "" {
+			msg = exitErr.Error()
+		}
+		err = fmt.Errorf("rclone %s failed: %s", args[0], msg)
+		if code := exitErr.ExitCode(); code == exitCodeDirNotFound || code == exitCodeFileNotFound {
+			return nil, common.NewNotFoundError(err)
+		}
+		return nil, err
+	}
+	return output, nil
+}
+
+// lastLine returns the last line of the output which is not blank
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,163 @@
+package rclone
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const testConfig = `[my-remote]
+type = local
+`
+
+// fakeRclone is a script which stands in for rclone, with the remote my-remote being the directory root. It
+// records the arguments it is run with, other than the config file, which it copies.
+type fakeRclone struct {
+	dir string
+	// root is the directory of my-remote
+	root string
+}
+
+func newFakeRclone(t *testing.T) *fakeRclone {
+	dir, err := ioutil.TempDir("", "rclone")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	f := &fakeRclone{dir: dir, root: filepath.Join(dir, "remote")}
+	script := fmt.Sprintf(`#!/bin/sh
+[ "$1" = --config ] || exit 2
+cp "$2" %[1]s/config
+shift 2
+echo "$@" >> %[1]s/args
+remote() { echo "$1" | sed "s#^my-remote:#%[2]s/#"; }
+cmd=$1
+shift
+case "$cmd" in
+copyto)
+  src=$(remote "$1")
+  dst=$(remote "$2")
+  if [ ! -e "$src" ]; then echo "ERROR : $1: directory not found" >&2; exit 3; fi
+  rm -rf "$dst" && mkdir -p "$(dirname "$dst")" && cp -R "$src" "$dst" ;;
+lsjson)
+  p=$(remote "$2")
+  if [ ! -e "$p" ]; then echo "ERROR : $2: object not found" >&2; exit 3; fi
+  if [ -d "$p" ]; then echo '{"Path":"","IsDir":true}'; else echo '{"Path":"","IsDir":false}'; fi ;;
+deletefile)
+  rm "$(remote "$1")" ;;
+purge)
+  rm -r "$(remote "$1")" ;;
+*)
+  echo "Fatal error: unknown command \"$cmd\"" >&2
+  exit 1 ;;
+esac
+`, dir, f.root)
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "rclone"), []byte(script), 0700)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, os.MkdirAll(f.root, 0700)) {
+		t.FailNow()
+	}
+	return f
+}
+
+func (f *fakeRclone) driver() *ArtifactDriver {
+	return &ArtifactDriver{Config: testConfig, binary: filepath.Join(f.dir, "rclone")}
+}
+
+// args returns the arguments rclone was run with, without the config file
+func (f *fakeRclone) args(t *testing.T) []string {
+	data, err := ioutil.ReadFile(filepath.Join(f.dir, "args"))
+	assert.NoError(t, err)
+	_ = os.Remove(filepath.Join(f.dir, "args"))
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func newArtifact(path string) *wfv1.Artifact {
+	return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{Rclone: &wfv1.RcloneArtifact{Remote: "my-remote", Path: path}}}
+}
+
+func TestArtifactDriver_File(t *testing.T) {
+	f := newFakeRclone(t)
+	defer func() { _ = os.RemoveAll(f.dir) }()
+	driver := f.driver()
+	src := filepath.Join(f.dir, "src")
+	assert.NoError(t, ioutil.WriteFile(src, []byte("my-data"), 0600))
+	art := newArtifact("my-bucket/my-key")
+
+	if assert.NoError(t, driver.Save(context.Background(), src, art)) {
+		assert.Equal(t, []string{"copyto " + src + " my-remote:my-bucket/my-key"}, f.args(t))
+		// the config file is that of the artifact, and is removed once rclone exits
+		config, err := ioutil.ReadFile(filepath.Join(f.dir, "config"))
+		assert.NoError(t, err)
+		assert.Equal(t, testConfig, string(config))
+	}
+	dst := filepath.Join(f.dir, "loaded", "dst")
+	if assert.NoError(t, driver.Load(context.Background(), art, dst)) {
+		assert.Equal(t, []string{"copyto my-remote:my-bucket/my-key " + dst}, f.args(t))
+		data, err := ioutil.ReadFile(dst)
+		assert.NoError(t, err)
+		assert.Equal(t, "my-data", string(data))
+	}
+	if assert.NoError(t, driver.Delete(art)) {
+		assert.Equal(t, []string{"lsjson --stat my-remote:my-bucket/my-key", "deletefile my-remote:my-bucket/my-key"}, f.args(t))
+		assert.NoFileExists(t, filepath.Join(f.root, "my-bucket", "my-key"))
+	}
+}
+
+func TestArtifactDriver_Directory(t *testing.T) {
+	f := newFakeRclone(t)
+	defer func() { _ = os.RemoveAll(f.dir) }()
+	driver := f.driver()
+	src := filepath.Join(f.dir, "src")
+	assert.NoError(t, os.MkdirAll(filepath.Join(src, "sub"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "a"), []byte("a"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "sub", "b"), []byte("b"), 0600))
+	art := newArtifact("my-bucket/my-dir")
+
+	if assert.NoError(t, driver.Save(context.Background(), src, art)) {
+		assert.Equal(t, []string{"copyto " + src + " my-remote:my-bucket/my-dir"}, f.args(t))
+	}
+	dst := filepath.Join(f.dir, "dst")
+	if assert.NoError(t, driver.Load(context.Background(), art, dst)) {
+		data, err := ioutil.ReadFile(filepath.Join(dst, "sub", "b"))
+		assert.NoError(t, err)
+		assert.Equal(t, "b", string(data))
+		f.args(t)
+	}
+	if assert.NoError(t, driver.Delete(art)) {
+		assert.Equal(t, []string{"lsjson --stat my-remote:my-bucket/my-dir", "purge my-remote:my-bucket/my-dir"}, f.args(t))
+		assert.NoFileExists(t, filepath.Join(f.root, "my-bucket", "my-dir"))
+	}
+}
+
+func TestArtifactDriver_Errors(t *testing.T) {
+	f := newFakeRclone(t)
+	defer func() { _ = os.RemoveAll(f.dir) }()
+	driver := f.driver()
+	t.Run("NotFound", func(t *testing.T) {
+		dst := filepath.Join(f.dir, "missing")
+		err := driver.Load(context.Background(), newArtifact("my-bucket/missing"), dst)
+		assert.True(t, stderrors.Is(err, common.ErrArtifactNotFound))
+		assert.EqualError(t, err, "rclone copyto failed: ERROR : my-remote:my-bucket/missing: directory not found")
+		assert.NoFileExists(t, dst)
+	})
+	t.Run("Failed", func(t *testing.T) {
+		_, err := driver.run(context.Background(), "unknown")
+		assert.EqualError(t, err, `rclone unknown failed: Fatal error: unknown command "unknown"`)
+		assert.False(t, stderrors.Is(err, common.ErrArtifactNotFound))
+	})
+	t.Run("NoBinary", func(t *testing.T) {
+		driver := &ArtifactDriver{binary: filepath.Join(f.dir, "missing")}
+		assert.Error(t, driver.Save(context.Background(), f.dir, newArtifact("my-bucket/my-key")))
+	})
+}
@@ -1,8 +1,18 @@
 package resource
 
-import "context"
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+)
 
 type Interface interface {
 	GetSecret(ctx context.Context, name, key string) (string, error)
 	GetConfigMapKey(ctx context.Context, name, key string) (string, error)
 }
+
+// LoggerProvider is implemented by an Interface which provides the logger of the drivers created with it, e.g. one
+// with the fields of the pod. Drivers log to the standard logger otherwise.
+type LoggerProvider interface {
+	Logger() log.FieldLogger
+}
@@ -0,0 +1,84 @@
+// Package resourcetest provides a fake resource.Interface for the tests of artifact drivers.
+package resourcetest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/resource"
+)
+
+// Kinds of the resources of lookups
+const (
+	KindSecret    = "Secret"
+	KindConfigMap = "ConfigMap"
+)
+
+// Lookup is a lookup of the key of a secret or config map
+type Lookup struct {
+	Kind string
+	Name string
+	Key  string
+}
+
+// FakeInterface is a resource.Interface of the keys seeded in its maps, which records every lookup made of it. It is
+// safe for concurrent use, as long as the maps are not changed during lookups.
+type FakeInterface struct {
+	// Secrets and ConfigMaps are the values of the keys of secrets and config maps, by "name/key"
+	Secrets    map[string]string
+	ConfigMaps map[string]string
+	// Err, if set, is returned for the lookup of a key which is not seeded, rather than a not found error
+	Err error
+
+	mu      sync.Mutex
+	lookups []Lookup
+}
+
+var _ resource.Interface = &FakeInterface{}
+
+// NewFakeInterface returns a FakeInterface seeded with the keys of secrets, by "name/key"
+func NewFakeInterface(secrets map[string]string) *FakeInterface {
+	return &FakeInterface{Secrets: secrets}
+}
+
+// GetSecret returns the value of the key of the secret
+func (f *FakeInterface) GetSecret(_ context.Context, name, key string) (string, error) {
+	return f.get(KindSecret, f.Secrets, name, key)
+}
+
+// GetConfigMapKey returns the value of the key of the config map
+func (f *FakeInterface) GetConfigMapKey(_ context.Context, name, key string) (string, error) {
+	return f.get(KindConfigMap, f.ConfigMaps, name, key)
+}
+
+func (f *FakeInterface) get(kind string, values map[string]string, name, key string) (string, error) {
+	f.mu.Lock()
+	f.lookups = append(f.lookups, Lookup{Kind: kind, Name: name, Key: key})
+	f.mu.Unlock()
+	if value, ok := values[name+"/"+key]; ok {
+		return value, nil
+	}
+	if f.Err != nil {
+		return "", f.Err
+	}
+	return "", errors.Errorf(errors.CodeNotFound, "%s '%s' does not have the key '%s'", kind, name, key)
+}
+
+// Lookups returns the lookups made, in the order they were made
+func (f *FakeInterface) Lookups() []Lookup {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Lookup(nil), f.lookups...)
+}
+
+// SecretLookups returns the secrets looked up, as "name/key", in the order they were looked up
+func (f *FakeInterface) SecretLookups() []string {
+	var keys []string
+	for _, l := range f.Lookups() {
+		if l.Kind == KindSecret {
+			keys = append(keys, l.Name+"/"+l.Key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,47 @@
+package resourcetest
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+)
+
+func TestFakeInterface(t *testing.T) {
+	ctx := context.Background()
+	f := NewFakeInterface(map[string]string{"my-secret/token": "my-token"})
+	f.ConfigMaps = map[string]string{"my-config-map/krb5.conf": "my-config"}
+
+	value, err := f.GetSecret(ctx, "my-secret", "token")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "my-token", value)
+	}
+	value, err = f.GetConfigMapKey(ctx, "my-config-map", "krb5.conf")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "my-config", value)
+	}
+	t.Run("Missing", func(t *testing.T) {
+		_, err := f.GetSecret(ctx, "my-secret", "password")
+		assert.True(t, errors.IsCode(errors.CodeNotFound, err), err)
+		// a config map is not a secret
+		_, err = f.GetSecret(ctx, "my-config-map", "krb5.conf")
+		assert.Error(t, err)
+	})
+	t.Run("Err", func(t *testing.T) {
+		f := &FakeInterface{Err: stderrors.New("forbidden")}
+		_, err := f.GetConfigMapKey(ctx, "my-config-map", "krb5.conf")
+		assert.EqualError(t, err, "forbidden")
+	})
+	t.Run("Lookups", func(t *testing.T) {
+		assert.Equal(t, []Lookup{
+			{Kind: KindSecret, Name: "my-secret", Key: "token"},
+			{Kind: KindConfigMap, Name: "my-config-map", Key: "krb5.conf"},
+			{Kind: KindSecret, Name: "my-secret", Key: "password"},
+			{Kind: KindSecret, Name: "my-config-map", Key: "krb5.conf"},
+		}, f.Lookups())
+		assert.Equal(t, []string{"my-secret/token", "my-secret/password", "my-config-map/krb5.conf"}, f.SecretLookups())
+	})
+}
@@ -0,0 +1,1229 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awscredentials "github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	artifactscommon "github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+// S3Client is the set of operations the S3 driver performs against S3 compliant storage
+type S3Client interface {
+	// PutFile puts a single file to a bucket at the specified key
+	PutFile(bucket, key, path string) error
+
+	// PutDirectory puts the files of a directory which match filter into a bucket key prefix, with each
+	// file a separate key in the bucket. Files are uploaded concurrently.
+	PutDirectory(bucket, key, path string, filter artifactscommon.FileFilter) error
+
+	// PutFileWithProgress puts a single file to a bucket at the specified key with the user metadata, calling
+	// progress as it is uploaded
+	PutFileWithProgress(bucket, key, path string, metadata map[string]string, progress artifactscommon.ProgressFunc) error
+
+	// PutFileWithResult is PutFileWithProgress, returning the object as the storage reported it was put
+	PutFileWithResult(bucket, key, path string, metadata map[string]string, progress artifactscommon.ProgressFunc) (artifactscommon.SavedObject, error)
+
+	// PutDirectoryWithResult is PutDirectory, returning the objects as the storage reported they were put, in any order
+	PutDirectoryWithResult(bucket, key, path string, filter artifactscommon.FileFilter) ([]artifactscommon.SavedObject, error)
+
+	// CopyObject copies an object to a key of a bucket, which may be the same bucket, returning the object as the
+	// storage reported it was copied
+	CopyObject(srcBucket, srcKey, dstBucket, dstKey string) (artifactscommon.SavedObject, error)
+
+	// GetFile downloads a file to a local file path
+	GetFile(bucket, key, path string) error
+
+	// GetFileWithProgress downloads a file to a local file path, calling progress as it is downloaded
+	GetFileWithProgress(bucket, key, path string, progress artifactscommon.ProgressFunc) error
+
+	// GetFileRange downloads length bytes of a file from the offset start to a local file path, or every byte
+	// from start if length is negative
+	GetFileRange(bucket, key, path string, start, length int64) error
+
+	// GetDirectory downloads a directory to a local file path
+	GetDirectory(bucket, key, path string) error
+
+	// OpenFile opens a file for reading
+	OpenFile(bucket, key string) (io.ReadCloser, error)
+
+	// StatObject returns the metadata of an object
+	StatObject(bucket, key string) (minio.ObjectInfo, error)
+
+	// PresignedURL returns a URL which allows requests of the method for an object, or its version if the client
+	// has one, until the expiry passes. It is signed without a request, if the client has a region.
+	PresignedURL(method, bucket, key string, expiry time.Duration) (string, error)
+
+	// PresignedPostPolicy returns the URL and form fields of a POST policy which allows uploads of objects of
+	// between minSize and maxSize bytes whose keys start with keyPrefix, until the expiry passes. It is signed without
+	// a request, if the client has a region.
+	PresignedPostPolicy(bucket, keyPrefix string, minSize, maxSize int64, expiry time.Duration) (string, map[string]string, error)
+
+	// ListDirectory list the contents of a directory/bucket
+	ListDirectory(bucket, keyPrefix string) ([]string, error)
+
+	// ListKeys lists, in order, the keys of the objects which start with prefix, including "directory" objects
+	// whose keys end with "/". It stops once there are more than maxKeys, and returns whether it did.
+	ListKeys(bucket, prefix string, maxKeys int) ([]string, bool, error)
+
+	// ListObjects lists the objects whose keys start with prefix, other than "directory" objects
+	ListObjects(bucket, prefix string) ([]artifactscommon.ListedObject, error)
+
+	// IsDirectory tests if the key is acting like a s3 directory
+	IsDirectory(bucket, key string) (bool, error)
+
+	// IsVersioned returns whether versioning is enabled on the bucket, or was and is suspended
+	IsVersioned(bucket string) (bool, error)
+
+	// IsObjectLockEnabled returns whether object lock is enabled on the bucket
+	IsObjectLockEnabled(bucket string) (bool, error)
+
+	// Delete deletes a single object
+	Delete(bucket, key string) error
+
+	// DeleteDirectory deletes every object under the key prefix
+	DeleteDirectory(bucket, keyPrefix string) error
+
+	// BucketExists returns whether a bucket exists
+	BucketExists(bucket string) (bool, error)
+
+	// MakeBucket creates a bucket with name bucketName and options opts
+	MakeBucket(bucketName string, opts minio.MakeBucketOptions) error
+}
+
+const (
+	// SSEAlgorithmAES256 encrypts objects with S3 managed keys (SSE-S3)
+	SSEAlgorithmAES256 = "AES256"
+	// SSEAlgorithmKMS encrypts objects with AWS KMS keys (SSE-KMS)
+	SSEAlgorithmKMS = "aws:kms"
+)
+
+// accelerateEndpoint is the endpoint of S3 Transfer Acceleration
+const accelerateEndpoint = "s3-accelerate.amazonaws.com"
+
+// S3ClientOpts are the options used to create a S3Client
+type S3ClientOpts struct {
+	Endpoint     string
+	Region       string
+	Secure       bool
+	AccessKey    string
+	SecretKey    string
+	RoleARN      string
+	Trace        bool
+	UseSDKCreds  bool
+	SSEAlgorithm string
+	KMSKeyID     string
+	PathStyle    *bool
+	// UploadParallelism is the number of files of a directory uploaded at once, defaultUploadParallelism if not set
+	UploadParallelism int
+	// RequesterPays acknowledges that the requester is billed for gets and stats of objects, which are
+	// the only requests minio-go can add the header to
+	RequesterPays bool
+	// RootCAs verify TLS connections to the endpoint, if set, rather than the system CA certificates
+	RootCAs *x509.CertPool
+	// ProxyURL is the proxy requests are sent through, if set, rather than that of the environment
+	ProxyURL *url.URL
+	// ObjectTags are the tags of the objects put, including every object of a directory and multipart uploads
+	ObjectTags map[string]string
+	// ObjectMetadata is the user metadata of the objects put, including every object of a directory and multipart
+	// uploads, which minio-go sends as x-amz-meta-* headers
+	ObjectMetadata map[string]string
+	// RoleExternalID is the external ID passed when assuming RoleARN
+	RoleExternalID string
+	// RoleChain are the ARNs of roles assumed in turn before RoleARN, each with the credentials of the one before
+	RoleChain []string
+	// RoleSessionName is the name of the sessions of assumed roles, a timestamp if not set
+	RoleSessionName string
+	// RoleSessionDuration is the duration of the sessions of assumed roles, stscreds.DefaultDuration if not set
+	RoleSessionDuration time.Duration
+	// Retry is the backoff of the retries of the lists, gets and puts of objects which fail with a 5xx response or
+	// a network error, none if it has no steps. Each operation is retried on its own, so that, for example, a failed
+	// get does not fail the load of the rest of a directory.
+	Retry wait.Backoff
+	// IfNoneMatch puts objects, including every object of a directory and multipart uploads, only if no object has
+	// their key. A put of an existing object fails with an error which matches common.ErrArtifactExists.
+	IfNoneMatch bool
+	// UseAccelerateEndpoint sends requests to accelerateEndpoint rather than the endpoint, which must be that of AWS,
+	// with buckets addressed in the hostname
+	UseAccelerateEndpoint bool
+	// VersionID is the version of the objects got and stated, rather than their latest, if set
+	VersionID string
+	// PartSize is the size of the parts of multipart uploads, and PartConcurrency the number of them uploaded at
+	// once, the minio-go defaults if not set
+	PartSize        uint64
+	PartConcurrency uint
+	// ContentType is the Content-Type of the objects put, rather than that detected for each of their files
+	ContentType string
+	// CredentialProviders are tried in turn for the credentials of the client, if set, rather than the access key,
+	// or otherwise RoleARN or the IAM role
+	CredentialProviders []wfv1.S3CredentialProvider
+	// RoundTripper returns the RoundTripper requests are sent with, given the transport of the client, if set
+	RoundTripper func(base http.RoundTripper) http.RoundTripper
+	// ACL is the access control list of the objects put, including every object of a directory and multipart
+	// uploads, rather than that of the bucket, if set
+	ACL *wfv1.S3ObjectACL
+	// ObjectLock is the retention and legal hold of the objects put, if set
+	ObjectLock *wfv1.S3ObjectLock
+	// Anonymous sends unsigned requests, which can only get the public objects of buckets
+	Anonymous bool
+	// AnonymousFallback sends unsigned requests if there are no credentials but those of the IAM role, and the IAM
+	// role has none, unless UseSDKCreds is set
+	AnonymousFallback bool
+}
+
+type s3client struct {
+	S3ClientOpts
+	minioClient *minio.Client
+	ctx         context.Context
+	sse         encrypt.ServerSide
+}
+
+var _ S3Client = &s3client{}
+
+// NewS3Client instantiates a new S3 client object backed by minio
+func NewS3Client(ctx context.Context, opts S3ClientOpts) (S3Client, error) {
+	s3cli := s3client{
+		S3ClientOpts: opts,
+	}
+	s3cli.AccessKey = strings.TrimSpace(s3cli.AccessKey)
+	s3cli.SecretKey = strings.TrimSpace(s3cli.SecretKey)
+
+	creds, err := getCredentials(s3cli.S3ClientOpts)
+	if err != nil {
+		return nil, err
+	}
+	s3cli.sse, err = newServerSideEncryption(s3cli.SSEAlgorithm, s3cli.KMSKeyID)
+	if err != nil {
+		return nil, err
+	}
+	if opts.UseAccelerateEndpoint {
+		// minio-go ignores the accelerate endpoint, rather than failing, if it cannot be used
+		if err := checkAccelerateEndpoint(opts.Endpoint, opts.PathStyle); err != nil {
+			return nil, errors.Errorf(errors.CodeBadRequest, "useAccelerateEndpoint %v", err)
+		}
+	}
+
+	minioOpts := &minio.Options{Creds: creds, Secure: s3cli.Secure, Region: s3cli.Region, BucketLookup: bucketLookupType(s3cli.S3ClientOpts)}
+	transport := artifactscommon.NewTransport(opts.RootCAs, opts.ProxyURL)
+	// like minio's default transport, so that objects with a gzip Content-Encoding are not decompressed
+	transport.DisableCompression = true
+	minioOpts.Transport = transport
+	if opts.RoundTripper != nil {
+		minioOpts.Transport = opts.RoundTripper(transport)
+	}
+	if opts.IfNoneMatch {
+		minioOpts.Transport = ifNoneMatchTransport{minioOpts.Transport}
+	}
+	minioClient, err := minio.New(s3cli.Endpoint, minioOpts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.UseAccelerateEndpoint {
+		minioClient.SetS3TransferAccelerate(accelerateEndpoint)
+	}
+	if opts.Trace {
+		minioClient.TraceOn(log.StandardLogger().Out)
+	}
+	s3cli.minioClient = minioClient
+	s3cli.ctx = ctx
+	return &s3cli, nil
+}
+
+// bucketLookupType returns how buckets are addressed: virtual-hosted-style for the accelerate endpoint, as requested
+// by PathStyle, or if it is not set, path-style for s3.amazonaws.com and detected from the endpoint otherwise
+func bucketLookupType(opts S3ClientOpts) minio.BucketLookupType {
+	switch {
+	case opts.UseAccelerateEndpoint:
+		// the accelerate endpoint only addresses buckets in the hostname
+		return minio.BucketLookupDNS
+	case opts.PathStyle != nil && *opts.PathStyle:
+		return minio.BucketLookupPath
+	case opts.PathStyle != nil:
+		return minio.BucketLookupDNS
+	case opts.Endpoint == "s3.amazonaws.com":
+		return minio.BucketLookupPath
+	default:
+		return minio.BucketLookupAuto
+	}
+}
+
+// assumedRole is a role assumed to get the credentials of the client
+type assumedRole struct {
+	roleARN     string
+	externalID  string
+	sessionName string
+	duration    time.Duration
+}
+
+// assumedRoles returns the roles assumed in turn, each with the credentials of the one before: those of RoleChain,
+// then RoleARN, which is the only one assumed with the external ID
+func assumedRoles(opts S3ClientOpts) []assumedRole {
+	var roles []assumedRole
+	for _, roleARN := range opts.RoleChain {
+		roles = append(roles, assumedRole{roleARN: roleARN, sessionName: opts.RoleSessionName, duration: opts.RoleSessionDuration})
+	}
+	return append(roles, assumedRole{roleARN: opts.RoleARN, externalID: opts.RoleExternalID, sessionName: opts.RoleSessionName, duration: opts.RoleSessionDuration})
+}
+
+// configure sets the parameters of the AssumeRole request of the provider
+func (r assumedRole) configure(p *stscreds.AssumeRoleProvider) {
+	if r.externalID != "" {
+		p.ExternalID = aws.String(r.externalID)
+	}
+	if r.sessionName != "" {
+		p.RoleSessionName = r.sessionName
+	}
+	if r.duration > 0 {
+		p.Duration = r.duration
+	}
+}
+
+func getAssumeRoleCredentials(opts S3ClientOpts) (*credentials.Credentials, error) {
+	return assumeRoles(session.Must(session.NewSession()), opts)
+}
+
+// assumeRoles assumes the roles in turn, starting with the credentials of the session
+func assumeRoles(sess *session.Session, opts S3ClientOpts) (*credentials.Credentials, error) {
+	var creds *awscredentials.Credentials
+	for _, role := range assumedRoles(opts) {
+		config := sess
+		if creds != nil {
+			config = sess.Copy(&aws.Config{Credentials: creds})
+		}
+		log.WithField("roleArn", role.roleARN).Debug("Assuming role")
+		creds = stscreds.NewCredentials(config, role.roleARN, role.configure)
+	}
+	value, err := creds.Get()
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewStaticV4(value.AccessKeyID, value.SecretAccessKey, value.SessionToken), nil
+}
+
+// namedCredentialProvider is a provider of credentials, and the source of them it is
+type namedCredentialProvider struct {
+	name wfv1.S3CredentialProvider
+	awscredentials.Provider
+}
+
+// unavailableProvider is the provider of a source which cannot yield credentials, failing with its error
+type unavailableProvider struct {
+	err error
+}
+
+func (p unavailableProvider) Retrieve() (awscredentials.Value, error) {
+	return awscredentials.Value{}, p.err
+}
+
+func (p unavailableProvider) IsExpired() bool {
+	return true
+}
+
+// credentialProviders returns the credential providers of the options in order, which send their requests to STS and
+// the instance metadata service with the session
+func credentialProviders(sess *session.Session, opts S3ClientOpts) []namedCredentialProvider {
+	providers := make([]namedCredentialProvider, len(opts.CredentialProviders))
+	for i, name := range opts.CredentialProviders {
+		var provider awscredentials.Provider
+		switch name {
+		case wfv1.S3CredentialProviderStatic:
+			provider = &awscredentials.StaticProvider{Value: awscredentials.Value{AccessKeyID: opts.AccessKey, SecretAccessKey: opts.SecretKey}}
+		case wfv1.S3CredentialProviderWebIdentity:
+			tokenFile, roleARN := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"), os.Getenv("AWS_ROLE_ARN")
+			if tokenFile == "" || roleARN == "" {
+				provider = unavailableProvider{fmt.Errorf("AWS_WEB_IDENTITY_TOKEN_FILE and AWS_ROLE_ARN are not set")}
+			} else {
+				provider = stscreds.NewWebIdentityRoleProvider(sts.New(sess), roleARN, os.Getenv("AWS_ROLE_SESSION_NAME"), tokenFile)
+			}
+		case wfv1.S3CredentialProviderInstanceProfile:
+			provider = &ec2rolecreds.EC2RoleProvider{Client: ec2metadata.New(sess)}
+		case wfv1.S3CredentialProviderEnv:
+			provider = &awscredentials.EnvProvider{}
+		default:
+			provider = unavailableProvider{fmt.Errorf("unknown credential provider")}
+		}
+		providers[i] = namedCredentialProvider{name: name, Provider: provider}
+	}
+	return providers
+}
+
+// firstCredentials returns the credentials of the first of the providers which yields them, and its name
+func firstCredentials(providers []namedCredentialProvider) (wfv1.S3CredentialProvider, *awscredentials.Credentials, error) {
+	var unavailable []string
+	for _, provider := range providers {
+		creds := awscredentials.NewCredentials(provider)
+		if _, err := creds.Get(); err != nil {
+			log.WithField("credentialProvider", provider.name).WithError(err).Debug("Credential provider is unavailable")
+			unavailable = append(unavailable, fmt.Sprintf("%s: %v", provider.name, err))
+			continue
+		}
+		return provider.name, creds, nil
+	}
+	return "", nil, errors.Errorf(errors.CodeBadRequest, "none of the credential providers yielded credentials: %s", strings.Join(unavailable, "; "))
+}
+
+// sdkCredentials are the credentials of an AWS SDK provider, which minio-go retrieves again once they expire, e.g.
+// those of a web identity or instance profile
+type sdkCredentials struct {
+	creds *awscredentials.Credentials
+}
+
+func (c sdkCredentials) Retrieve() (credentials.Value, error) {
+	value, err := c.creds.Get()
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	return credentials.Value{AccessKeyID: value.AccessKeyID, SecretAccessKey: value.SecretAccessKey, SessionToken: value.SessionToken, SignerType: credentials.SignatureV4}, nil
+}
+
+func (c sdkCredentials) IsExpired() bool {
+	return c.creds.IsExpired()
+}
+
+// getProviderCredentials returns the credentials of the first of the credential providers of the options which yields
+// them, with which RoleARN, if set, is assumed
+func getProviderCredentials(sess *session.Session, opts S3ClientOpts) (*credentials.Credentials, error) {
+	name, creds, err := firstCredentials(credentialProviders(sess, opts))
+	if err != nil {
+		return nil, err
+	}
+	log.WithFields(log.Fields{"endpoint": opts.Endpoint, "credentialProvider": name}).Info("Creating minio client using the credentials of the first available provider")
+	if opts.RoleARN != "" {
+		log.WithField("roleArn", opts.RoleARN).Info("Assuming role with the credentials")
+		return assumeRoles(sess.Copy(&aws.Config{Credentials: creds}), opts)
+	}
+	return credentials.New(sdkCredentials{creds}), nil
+}
+
+func getCredentials(opts S3ClientOpts) (*credentials.Credentials, error) {
+	if opts.Anonymous {
+		log.WithField("endpoint", opts.Endpoint).Info("Creating minio client without credentials")
+		return anonymousCredentials(), nil
+	} else if len(opts.CredentialProviders) > 0 {
+		return getProviderCredentials(session.Must(session.NewSession()), opts)
+	} else if opts.AccessKey != "" {
+		log.WithField("endpoint", opts.Endpoint).Info("Creating minio client using static credentials")
+		return credentials.NewStaticV4(opts.AccessKey, opts.SecretKey, ""), nil
+	} else if opts.RoleARN != "" {
+		log.WithField("roleArn", opts.RoleARN).Info("Creating minio client using assumed-role credentials")
+		return getAssumeRoleCredentials(opts)
+	} else {
+		log.Info("Creating minio client using IAM role")
+		creds := iamCredentials("")
+		if opts.AnonymousFallback && !opts.UseSDKCreds {
+			if value, err := creds.Get(); err != nil || value.AccessKeyID == "" {
+				log.WithField("endpoint", opts.Endpoint).WithError(err).Info("The IAM role has no credentials, so the minio client sends unsigned requests")
+				return anonymousCredentials(), nil
+			}
+		}
+		return creds, nil
+	}
+}
+
+// iamCredentials returns the credentials of the IAM role, from the endpoint if it is set
+var iamCredentials = credentials.NewIAM
+
+// anonymousCredentials returns credentials with which requests are not signed
+func anonymousCredentials() *credentials.Credentials {
+	return credentials.NewStatic("", "", "", credentials.SignatureAnonymous)
+}
+
+// newServerSideEncryption returns the encryption to request when putting objects, or nil for none.
+// Objects encrypted with SSE-S3 or SSE-KMS are decrypted transparently, so gets need no options.
+func newServerSideEncryption(algorithm, kmsKeyID string) (encrypt.ServerSide, error) {
+	switch algorithm {
+	case "":
+		return nil, nil
+	case SSEAlgorithmAES256:
+		return encrypt.NewSSE(), nil
+	case SSEAlgorithmKMS:
+		return encrypt.NewSSEKMS(kmsKeyID, nil)
+	default:
+		return nil, fmt.Errorf("unsupported server-side encryption algorithm %q", algorithm)
+	}
+}
+
+// retry calls f, retrying it with the Retry backoff of the client
+func (s *s3client) retry(bucket, key string, f func() error) error {
+	return retry(s.ctx, s.Retry, log.WithFields(log.Fields{"endpoint": s.Endpoint, "bucket": bucket, "key": key}), f)
+}
+
+func (s *s3client) putObjectOptions() minio.PutObjectOptions {
+	opts := minio.PutObjectOptions{ServerSideEncryption: s.sse, UserTags: s.ObjectTags, PartSize: s.PartSize, NumThreads: s.PartConcurrency, UserMetadata: aclHeaders(s.ACL)}
+	if len(s.ObjectMetadata) > 0 {
+		// a copy, as the metadata of each object is added to it
+		if opts.UserMetadata == nil {
+			opts.UserMetadata = map[string]string{}
+		}
+		for k, v := range s.ObjectMetadata {
+			opts.UserMetadata[k] = v
+		}
+	}
+	if lock := s.ObjectLock; lock != nil {
+		if lock.Mode != "" && lock.RetainUntilDate != nil {
+			opts.Mode = minio.RetentionMode(lock.Mode)
+			opts.RetainUntilDate = lock.RetainUntilDate.UTC()
+		}
+		if lock.LegalHold {
+			opts.LegalHold = minio.LegalHoldEnabled
+		}
+		// which S3 requires of puts of objects with object lock
+		opts.SendContentMd5 = true
+	}
+	return opts
+}
+
+// aclHeaders returns the headers which set the ACL of an object as it is put, or nil if acl is nil. minio-go sends
+// user metadata which are x-amz-acl or x-amz-grant-* headers as they are, including when it initiates multipart
+// uploads, rather than as x-amz-meta-* headers.
+func aclHeaders(acl *wfv1.S3ObjectACL) map[string]string {
+	if acl == nil {
+		return nil
+	}
+	headers := map[string]string{}
+	if acl.Canned != "" {
+		headers["x-amz-acl"] = string(acl.Canned)
+	}
+	for header, grantees := range map[string][]string{
+		"x-amz-grant-read":         acl.GrantRead,
+		"x-amz-grant-read-acp":     acl.GrantReadACP,
+		"x-amz-grant-write-acp":    acl.GrantWriteACP,
+		"x-amz-grant-full-control": acl.GrantFullControl,
+	} {
+		if len(grantees) > 0 {
+			headers[header] = strings.Join(grantees, ", ")
+		}
+	}
+	return headers
+}
+
+// putFileOptions returns the options of putting the file at path, whose Content-Type is detected by its extension
+// or its content, as minio-go only detects it by its extension, unless the client sets it
+func (s *s3client) putFileOptions(path string) (minio.PutObjectOptions, error) {
+	opts := s.putObjectOptions()
+	contentType, err := artifactscommon.ContentType(s.ContentType, path)
+	if err != nil {
+		return opts, err
+	}
+	opts.ContentType = contentType
+	return opts, nil
+}
+
+// requestPayerHeader is the header which acknowledges that the requester is billed for the request
+const requestPayerHeader = "x-amz-request-payer"
+
+func (s *s3client) getObjectOptions() minio.GetObjectOptions {
+	opts := minio.GetObjectOptions{}
+	if s.RequesterPays {
+		opts.Set(requestPayerHeader, "requester")
+	}
+	opts.VersionID = s.VersionID
+	return opts
+}
+
+// ifNoneMatchKey marks the context of a put of an object, whose requests ifNoneMatchTransport makes conditional
+type ifNoneMatchKey struct{}
+
+// putContext returns the context of the requests of a put of an object
+func (s *s3client) putContext(ctx context.Context) context.Context {
+	if !s.IfNoneMatch {
+		return ctx
+	}
+	return context.WithValue(ctx, ifNoneMatchKey{}, true)
+}
+
+// ifNoneMatchTransport adds "If-None-Match: *" to the requests which create objects, in puts of objects: the put of
+// the object, or the completion of its multipart upload, but not the upload of its parts. minio-go cannot add the
+// header itself, and does not sign it, which S3 allows of standard headers.
+type ifNoneMatchTransport struct {
+	http.RoundTripper
+}
+
+func (t ifNoneMatchTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Context().Value(ifNoneMatchKey{}) == nil {
+		return t.RoundTripper.RoundTrip(r)
+	}
+	query := r.URL.Query()
+	_, isPart := query["partNumber"]
+	_, isUpload := query["uploadId"]
+	if (r.Method == http.MethodPut && !isPart) || (r.Method == http.MethodPost && isUpload) {
+		r = r.Clone(r.Context())
+		r.Header.Set("If-None-Match", "*")
+	}
+	return t.RoundTripper.RoundTrip(r)
+}
+
+// existsError returns the error of a put of an object as one which matches common.ErrArtifactExists if the object
+// exists, so the precondition of its If-None-Match failed
+func existsError(bucket, key string, err error) error {
+	var response minio.ErrorResponse
+	if stderrors.As(err, &response) && response.StatusCode == http.StatusPreconditionFailed {
+		return artifactscommon.NewExistsError(fmt.Sprintf("s3://%s/%s", bucket, key))
+	}
+	return err
+}
+
+// PutFile puts a single file to a bucket at the specified key
+func (s *s3client) PutFile(bucket, key, path string) error {
+	_, err := s.putFile(s.ctx, bucket, key, path)
+	return err
+}
+
+func (s *s3client) putFile(ctx context.Context, bucket, key, path string) (artifactscommon.SavedObject, error) {
+	log.Infof("Saving from %s to s3 (endpoint: %s, bucket: %s, key: %s)", path, s.Endpoint, bucket, key)
+	opts, err := s.putFileOptions(path)
+	if err != nil {
+		return artifactscommon.SavedObject{}, err
+	}
+	var info minio.UploadInfo
+	err = s.retry(bucket, key, func() error {
+		var err error
+		info, err = s.minioClient.FPutObject(s.putContext(ctx), bucket, key, path, opts)
+		return err
+	})
+	return savedObject(key, path, info), existsError(bucket, key, err)
+}
+
+// putSymlink puts the symlink at path to the target at key, as an empty object with the target in its user metadata
+func (s *s3client) putSymlink(ctx context.Context, bucket, key, path, target string) (artifactscommon.SavedObject, error) {
+	log.Infof("Saving symlink %s to %s to s3 (endpoint: %s, bucket: %s, key: %s)", path, target, s.Endpoint, bucket, key)
+	opts := s.putObjectOptions()
+	if opts.UserMetadata == nil {
+		opts.UserMetadata = map[string]string{}
+	}
+	opts.UserMetadata[artifactscommon.SymlinkTargetMetadataKey] = target
+	var info minio.UploadInfo
+	err := s.retry(bucket, key, func() error {
+		var err error
+		info, err = s.minioClient.PutObject(s.putContext(ctx), bucket, key, bytes.NewReader(nil), 0, opts)
+		return err
+	})
+	object := savedObject(key, path, info)
+	object.LinkTarget = target
+	return object, existsError(bucket, key, err)
+}
+
+// savedObject returns the object of the file at path put at key, as minio reported it was uploaded
+func savedObject(key, path string, info minio.UploadInfo) artifactscommon.SavedObject {
+	return artifactscommon.SavedObject{Key: key, Path: path, Size: info.Size, ETag: strings.Trim(info.ETag, `"`), VersionID: info.VersionID}
+}
+
+// PutFileWithProgress puts a single file to a bucket at the specified key with the user metadata, calling
+// progress as it is uploaded
+func (s *s3client) PutFileWithProgress(bucket, key, path string, metadata map[string]string, progress artifactscommon.ProgressFunc) error {
+	_, err := s.PutFileWithResult(bucket, key, path, metadata, progress)
+	return err
+}
+
+// PutFileWithResult is PutFileWithProgress, returning the object as the storage reported it was put
+func (s *s3client) PutFileWithResult(bucket, key, path string, metadata map[string]string, progress artifactscommon.ProgressFunc) (artifactscommon.SavedObject, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return artifactscommon.SavedObject{}, err
+	}
+	log.Infof("Saving from %s to s3 (endpoint: %s, bucket: %s, key: %s)", path, s.Endpoint, bucket, key)
+	opts, err := s.putFileOptions(path)
+	if err != nil {
+		return artifactscommon.SavedObject{}, err
+	}
+	if opts.UserMetadata == nil {
+		opts.UserMetadata = map[string]string{}
+	}
+	for key, value := range metadata {
+		opts.UserMetadata[key] = value
+	}
+	var info minio.UploadInfo
+	// the progress restarts if the put is retried
+	err = s.retry(bucket, key, func() error {
+		counter := artifactscommon.NewProgressCounter(stat.Size(), progress)
+		opts.Progress = nil
+		if counter != nil {
+			opts.Progress = progressHook{counter}
+		}
+		var err error
+		info, err = s.minioClient.FPutObject(s.putContext(s.ctx), bucket, key, path, opts)
+		counter.Done()
+		return err
+	})
+	return savedObject(key, path, info), existsError(bucket, key, err)
+}
+
+// progressHook counts the bytes minio uploads. minio reads as many bytes from its progress reader as it
+// has uploaded.
+type progressHook struct {
+	counter *artifactscommon.ProgressCounter
+}
+
+func (h progressHook) Read(p []byte) (int, error) {
+	h.counter.Add(int64(len(p)))
+	return len(p), nil
+}
+
+func (s *s3client) BucketExists(bucketName string) (bool, error) {
+	log.Infof("Checking if bucket %s exists.", bucketName)
+	return s.minioClient.BucketExists(s.ctx, bucketName)
+}
+
+func (s *s3client) MakeBucket(bucketName string, opts minio.MakeBucketOptions) error {
+	log.Infof("Creating bucket: %s. (Region: %s, ObjectLocking: %t)", bucketName, opts.Region, opts.ObjectLocking)
+	return s.minioClient.MakeBucket(s.ctx, bucketName, opts)
+}
+
+type uploadTask struct {
+	key  string
+	path string
+	// linkTarget is the target of the file if it is a preserved symlink
+	linkTarget string
+}
+
+// generatePutTasks walks the files under rootPath which match filter, until ctx is done, sending the error the walk
+// fails with, if any, once it is done
+func generatePutTasks(ctx context.Context, keyPrefix, rootPath string, filter artifactscommon.FileFilter) (chan uploadTask, chan error) {
+	uploadTasks := make(chan uploadTask)
+	walkErr := make(chan error, 1)
+	visit := func(f artifactscommon.WalkedFile) error {
+		t := uploadTask{
+			key:        path.Join(keyPrefix, f.RelPath),
+			path:       f.Path,
+			linkTarget: f.LinkTarget,
+		}
+		select {
+		case uploadTasks <- t:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	go func() {
+		walkErr <- filter.Walk(rootPath, visit)
+		close(uploadTasks)
+	}()
+	return uploadTasks, walkErr
+}
+
+// defaultUploadParallelism is the number of files of a directory uploaded at once, unless UploadParallelism is set
+const defaultUploadParallelism = 5
+
+// PutDirectory puts the files of a directory which match filter into a bucket key prefix, with each
+// file a separate key in the bucket. Files are uploaded concurrently.
+func (s *s3client) PutDirectory(bucket, key, path string, filter artifactscommon.FileFilter) error {
+	_, err := s.PutDirectoryWithResult(bucket, key, path, filter)
+	return err
+}
+
+// PutDirectoryWithResult is PutDirectory, returning the objects as the storage reported they were put, in any order
+func (s *s3client) PutDirectoryWithResult(bucket, key, path string, filter artifactscommon.FileFilter) ([]artifactscommon.SavedObject, error) {
+	parallelism := s.UploadParallelism
+	if parallelism < 1 {
+		parallelism = defaultUploadParallelism
+	}
+	var lock sync.Mutex
+	var objects []artifactscommon.SavedObject
+	err := putDirectory(s.ctx, key, path, filter, parallelism, func(ctx context.Context, task uploadTask) error {
+		var object artifactscommon.SavedObject
+		var err error
+		if task.linkTarget != "" {
+			object, err = s.putSymlink(ctx, bucket, task.key, task.path, task.linkTarget)
+		} else {
+			object, err = s.putFile(ctx, bucket, task.key, task.path)
+		}
+		if err != nil {
+			return err
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		objects = append(objects, object)
+		return nil
+	})
+	return objects, err
+}
+
+// putDirectory puts the files under path which match filter with parallelism workers. The first failure
+// cancels the remaining uploads, and the returned error lists the files which failed.
+func putDirectory(ctx context.Context, key, path string, filter artifactscommon.FileFilter, parallelism int, put func(context.Context, uploadTask) error) error {
+	putCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	tasks, walkErr := generatePutTasks(putCtx, key, path, filter)
+	var lock sync.Mutex
+	var failed []string
+	var firstErr error
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range tasks {
+				if putCtx.Err() != nil {
+					continue
+				}
+				err := put(putCtx, task)
+				if err == nil {
+					continue
+				}
+				lock.Lock()
+				// uploads cancelled because another one failed are not failures themselves
+				if len(failed) == 0 || !stderrors.Is(err, context.Canceled) {
+					log.Warnf("Failed to put %s: %v", task.path, err)
+					failed = append(failed, fmt.Sprintf("%s (%v)", task.path, err))
+					if firstErr == nil {
+						firstErr = err
+					}
+				}
+				lock.Unlock()
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		// the first failure is wrapped, so that whether it can be retried can be told
+		return errors.InternalWrapErrorf(firstErr, "failed to put %d file(s) under %s: %s", len(failed), path, strings.Join(failed, ", "))
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return <-walkErr
+}
+
+// CopyObject copies an object to a key of a bucket, which may be the same bucket, returning the object as the storage
+// reported it was copied. Objects larger than 5GiB are copied in parts.
+func (s *s3client) CopyObject(srcBucket, srcKey, dstBucket, dstKey string) (artifactscommon.SavedObject, error) {
+	log.Infof("Copying in s3 (endpoint: %s, bucket: %s, key: %s, to bucket: %s, key: %s)", s.Endpoint, srcBucket, srcKey, dstBucket, dstKey)
+	var info minio.UploadInfo
+	err := s.retry(dstBucket, dstKey, func() error {
+		var err error
+		info, err = s.minioClient.ComposeObject(s.ctx, minio.CopyDestOptions{Bucket: dstBucket, Object: dstKey, Encryption: s.sse}, minio.CopySrcOptions{Bucket: srcBucket, Object: srcKey})
+		return err
+	})
+	return artifactscommon.SavedObject{Key: dstKey, Size: info.Size, ETag: strings.Trim(info.ETag, `"`), VersionID: info.VersionID}, err
+}
+
+// GetFile downloads a file to a local file path
+func (s *s3client) GetFile(bucket, key, path string) error {
+	log.Infof("Getting from s3 (endpoint: %s, bucket: %s, key: %s) to %s", s.Endpoint, bucket, key, path)
+	return s.retry(bucket, key, func() error {
+		return s.minioClient.FGetObject(s.ctx, bucket, key, path, s.getObjectOptions())
+	})
+}
+
+// GetFileWithProgress downloads a file to a local file path, calling progress as it is downloaded
+func (s *s3client) GetFileWithProgress(bucket, key, path string, progress artifactscommon.ProgressFunc) error {
+	log.Infof("Getting from s3 (endpoint: %s, bucket: %s, key: %s) to %s", s.Endpoint, bucket, key, path)
+	// the progress restarts if the get is retried
+	return s.retry(bucket, key, func() error {
+		return s.getFileWithProgress(bucket, key, path, progress)
+	})
+}
+
+func (s *s3client) getFileWithProgress(bucket, key, path string, progress artifactscommon.ProgressFunc) error {
+	obj, err := s.minioClient.GetObject(s.ctx, bucket, key, s.getObjectOptions())
+	if err != nil {
+		return err
+	}
+	defer func() { _ = obj.Close() }()
+	info, err := obj.Stat()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	counter := artifactscommon.NewProgressCounter(info.Size, progress)
+	_, err = io.Copy(f, counter.Reader(obj))
+	counter.Done()
+	return err
+}
+
+// GetFileRange downloads length bytes of a file from the offset start to a local file path, or every byte from start
+// if length is negative
+func (s *s3client) GetFileRange(bucket, key, path string, start, length int64) error {
+	byteRange := artifactscommon.ByteRange(start, length)
+	log.Infof("Getting %s from s3 (endpoint: %s, bucket: %s, key: %s) to %s", byteRange, s.Endpoint, bucket, key, path)
+	return s.retry(bucket, key, func() error {
+		return s.getFileRange(bucket, key, path, byteRange)
+	})
+}
+
+func (s *s3client) getFileRange(bucket, key, path, byteRange string) error {
+	opts := s.getObjectOptions()
+	opts.Set("Range", byteRange)
+	obj, err := s.minioClient.GetObject(s.ctx, bucket, key, opts)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = obj.Close() }()
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	if _, err = io.Copy(f, obj); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// OpenFile opens a file for reading. The object is stat-ed first so that a missing key is reported
+// here rather than on the first read.
+func (s *s3client) OpenFile(bucket, key string) (io.ReadCloser, error) {
+	log.Infof("Opening file from s3 (endpoint: %s, bucket: %s, key: %s)", s.Endpoint, bucket, key)
+	var obj *minio.Object
+	err := s.retry(bucket, key, func() error {
+		var err error
+		obj, err = s.minioClient.GetObject(s.ctx, bucket, key, s.getObjectOptions())
+		if err != nil {
+			return err
+		}
+		if _, err := obj.Stat(); err != nil {
+			_ = obj.Close()
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// StatObject returns the metadata of an object
+func (s *s3client) StatObject(bucket, key string) (minio.ObjectInfo, error) {
+	var info minio.ObjectInfo
+	err := s.retry(bucket, key, func() error {
+		var err error
+		info, err = s.minioClient.StatObject(s.ctx, bucket, key, minio.StatObjectOptions(s.getObjectOptions()))
+		return err
+	})
+	return info, err
+}
+
+func (s *s3client) PresignedURL(method, bucket, key string, expiry time.Duration) (string, error) {
+	var params url.Values
+	if s.VersionID != "" {
+		params = url.Values{"versionId": {s.VersionID}}
+	}
+	u, err := s.minioClient.Presign(s.ctx, method, bucket, key, expiry, params)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (s *s3client) PresignedPostPolicy(bucket, keyPrefix string, minSize, maxSize int64, expiry time.Duration) (string, map[string]string, error) {
+	policy := minio.NewPostPolicy()
+	if err := policy.SetBucket(bucket); err != nil {
+		return "", nil, err
+	}
+	if err := policy.SetKeyStartsWith(keyPrefix); err != nil {
+		return "", nil, err
+	}
+	if err := policy.SetContentLengthRange(minSize, maxSize); err != nil {
+		return "", nil, err
+	}
+	if err := policy.SetExpires(time.Now().UTC().Add(expiry)); err != nil {
+		return "", nil, err
+	}
+	u, fields, err := s.minioClient.PresignedPostPolicy(s.ctx, policy)
+	if err != nil {
+		return "", nil, err
+	}
+	return u.String(), fields, nil
+}
+
+// GetDirectory downloads a s3 directory to a local path, each object to its key relative to the key prefix. An object
+// under a symlink which an earlier object was loaded as is not downloaded, but an error.
+func (s *s3client) GetDirectory(bucket, keyPrefix, path string) error {
+	log.Infof("Getting directory from s3 (endpoint: %s, bucket: %s, key: %s) to %s", s.Endpoint, bucket, keyPrefix, path)
+	keys, err := s.ListDirectory(bucket, keyPrefix)
+	if err != nil {
+		return err
+	}
+	prefix := dirKeyPrefix(keyPrefix)
+	for _, objKey := range keys {
+		relKeyPath := strings.TrimPrefix(objKey, prefix)
+		localPath := filepath.Join(path, filepath.FromSlash(relKeyPath))
+		if !strings.HasPrefix(localPath, filepath.Clean(path)+string(os.PathSeparator)) {
+			return fmt.Errorf("object %s is outside of the directory %s", objKey, keyPrefix)
+		}
+		if err := artifactscommon.CheckLoadPath(path, localPath); err != nil {
+			return err
+		}
+		err := s.retry(bucket, objKey, func() error {
+			return s.minioClient.FGetObject(s.ctx, bucket, objKey, localPath, s.getObjectOptions())
+		})
+		if err != nil {
+			return err
+		}
+		if err := s.createSymlink(bucket, objKey, path, localPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createSymlink replaces the file of the object at key loaded to localPath under the directory dir with the symlink
+// the object preserves, if it is one. Only empty objects can be, so only they are stat-ed.
+func (s *s3client) createSymlink(bucket, key, dir, localPath string) error {
+	if info, err := os.Stat(localPath); err != nil || info.Size() > 0 {
+		return err
+	}
+	info, err := s.StatObject(bucket, key)
+	if err != nil {
+		return err
+	}
+	target := info.Metadata.Get("X-Amz-Meta-" + artifactscommon.SymlinkTargetMetadataKey)
+	if target == "" {
+		return nil
+	}
+	created, err := artifactscommon.CreateSymlink(dir, localPath, target)
+	if err == nil && !created {
+		log.Warnf("Not creating the symlink %s to %s, which is outside of %s", localPath, target, dir)
+		err = os.Remove(localPath)
+	}
+	return err
+}
+
+// IsDirectory tests if the key is acting like a s3 directory. This just means it has at least one
+// object which is prefixed with the given key
+func (s *s3client) IsDirectory(bucket, keyPrefix string) (bool, error) {
+	var isDir bool
+	err := s.retry(bucket, keyPrefix, func() error {
+		var err error
+		isDir, err = s.isDirectory(bucket, keyPrefix)
+		return err
+	})
+	return isDir, err
+}
+
+func (s *s3client) isDirectory(bucket, keyPrefix string) (bool, error) {
+	keyPrefix = dirKeyPrefix(keyPrefix)
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	listOpts := minio.ListObjectsOptions{
+		Prefix:    keyPrefix,
+		Recursive: false,
+	}
+	for obj := range s.minioClient.ListObjects(ctx, bucket, listOpts) {
+		if obj.Err != nil {
+			return false, obj.Err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// IsVersioned returns whether versioning is enabled on the bucket, or was and is suspended
+func (s *s3client) IsVersioned(bucket string) (bool, error) {
+	var config minio.BucketVersioningConfiguration
+	err := s.retry(bucket, "", func() error {
+		var err error
+		config, err = s.minioClient.GetBucketVersioning(s.ctx, bucket)
+		return err
+	})
+	// the status is empty if versioning was never enabled
+	return config.Status != "", err
+}
+
+// IsObjectLockEnabled returns whether object lock is enabled on the bucket, which it is only if it was when the bucket
+// was created
+func (s *s3client) IsObjectLockEnabled(bucket string) (bool, error) {
+	var objectLock string
+	err := s.retry(bucket, "", func() error {
+		var err error
+		objectLock, _, _, _, err = s.minioClient.GetObjectLockConfig(s.ctx, bucket)
+		return err
+	})
+	if IsS3ErrCode(err, "ObjectLockConfigurationNotFoundError") {
+		return false, nil
+	}
+	return objectLock == "Enabled", err
+}
+
+// ListDirectory lists the keys of all objects under a key prefix. A listing which fails is retried from its start.
+func (s *s3client) ListDirectory(bucket, keyPrefix string) ([]string, error) {
+	log.Infof("Listing directory from s3 (endpoint: %s, bucket: %s, key: %s)", s.Endpoint, bucket, keyPrefix)
+	var keys []string
+	err := s.retry(bucket, keyPrefix, func() error {
+		var err error
+		keys, err = s.listDirectory(bucket, keyPrefix)
+		return err
+	})
+	return keys, err
+}
+
+func (s *s3client) listDirectory(bucket, keyPrefix string) ([]string, error) {
+	keyPrefix = dirKeyPrefix(keyPrefix)
+	listOpts := minio.ListObjectsOptions{
+		Prefix:    keyPrefix,
+		Recursive: true,
+	}
+	var out []string
+	for obj := range s.minioClient.ListObjects(s.ctx, bucket, listOpts) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		if strings.HasSuffix(obj.Key, "/") {
+			// When a dir is created through AWS S3 console, a nameless obj will be created
+			// automatically, its key will be {dir_name} + "/". This obj does not display in the
+			// console, but you can see it when using aws cli.
+			// If obj.Key ends with "/" means it's a dir obj, we need to skip it, otherwise it
+			// will be downloaded as a regular file with the same name as the dir, and it will
+			// creates error when downloading the files under the dir.
+			continue
+		}
+		out = append(out, obj.Key)
+	}
+	return out, nil
+}
+
+// ListObjects lists the keys and last modified times of the objects which start with prefix
+func (s *s3client) ListObjects(bucket, prefix string) ([]artifactscommon.ListedObject, error) {
+	var objects []artifactscommon.ListedObject
+	err := s.retry(bucket, prefix, func() error {
+		var err error
+		objects, err = s.listObjects(bucket, prefix)
+		return err
+	})
+	return objects, err
+}
+
+func (s *s3client) listObjects(bucket, prefix string) ([]artifactscommon.ListedObject, error) {
+	var objects []artifactscommon.ListedObject
+	for obj := range s.minioClient.ListObjects(s.ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		if strings.HasSuffix(obj.Key, "/") {
+			continue
+		}
+		objects = append(objects, artifactscommon.ListedObject{Key: obj.Key, LastModified: obj.LastModified, Size: obj.Size})
+	}
+	return objects, nil
+}
+
+// ListKeys lists the keys of the objects which start with prefix with ListObjectsV2, stopping once there are more
+// than maxKeys
+func (s *s3client) ListKeys(bucket, prefix string, maxKeys int) ([]string, bool, error) {
+	var keys []string
+	var truncated bool
+	err := s.retry(bucket, prefix, func() error {
+		var err error
+		keys, truncated, err = s.listKeys(bucket, prefix, maxKeys)
+		return err
+	})
+	return keys, truncated, err
+}
+
+func (s *s3client) listKeys(bucket, prefix string, maxKeys int) ([]string, bool, error) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	listOpts := minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	}
+	var keys []string
+	for obj := range s.minioClient.ListObjects(ctx, bucket, listOpts) {
+		if obj.Err != nil {
+			return nil, false, obj.Err
+		}
+		if len(keys) == maxKeys {
+			return keys, true, nil
+		}
+		keys = append(keys, obj.Key)
+	}
+	return keys, false, nil
+}
+
+// Delete deletes a single object
+func (s *s3client) Delete(bucket, key string) error {
+	log.Infof("Deleting from s3 (endpoint: %s, bucket: %s, key: %s)", s.Endpoint, bucket, key)
+	return s.minioClient.RemoveObject(s.ctx, bucket, key, minio.RemoveObjectOptions{})
+}
+
+// DeleteDirectory deletes every object under the key prefix, including the "directory" objects
+// created by the AWS S3 console. If some objects cannot be removed, the returned error lists their keys.
+func (s *s3client) DeleteDirectory(bucket, keyPrefix string) error {
+	log.Infof("Deleting directory from s3 (endpoint: %s, bucket: %s, key: %s)", s.Endpoint, bucket, keyPrefix)
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	listOpts := minio.ListObjectsOptions{
+		Prefix:    dirKeyPrefix(keyPrefix),
+		Recursive: true,
+	}
+	objectsCh := make(chan minio.ObjectInfo)
+	var listErr error
+	go func() {
+		defer close(objectsCh)
+		for obj := range s.minioClient.ListObjects(ctx, bucket, listOpts) {
+			if obj.Err != nil {
+				listErr = obj.Err
+				return
+			}
+			objectsCh <- obj
+		}
+	}()
+	var failedKeys []string
+	for removeErr := range s.minioClient.RemoveObjects(ctx, bucket, objectsCh, minio.RemoveObjectsOptions{}) {
+		log.Warnf("Failed to delete %s: %v", removeErr.ObjectName, removeErr.Err)
+		failedKeys = append(failedKeys, removeErr.ObjectName)
+	}
+	if listErr != nil {
+		return listErr
+	}
+	if len(failedKeys) > 0 {
+		return errors.InternalErrorf("failed to delete %d object(s) under %s: %s", len(failedKeys), keyPrefix, strings.Join(failedKeys, ", "))
+	}
+	return nil
+}
+
+// dirKeyPrefix turns a key into a prefix that only matches objects "inside" it
+func dirKeyPrefix(keyPrefix string) string {
+	if keyPrefix == "" {
+		return keyPrefix
+	}
+	keyPrefix = filepath.Clean(keyPrefix) + "/"
+	if os.PathSeparator == '\\' {
+		keyPrefix = strings.ReplaceAll(keyPrefix, "\\", "/")
+	}
+	return keyPrefix
+}
+
+// IsS3ErrCode returns if the supplied error is of a specific S3 error code
+func IsS3ErrCode(err error, code string) bool {
+	return minio.ToErrorResponse(err).Code == code
+}
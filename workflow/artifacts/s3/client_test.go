@@ -0,0 +1,775 @@
+package s3
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awscredentials "github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	artifactscommon "github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+func TestPutObjectOptions(t *testing.T) {
+	t.Run("NoEncryption", func(t *testing.T) {
+		sse, err := newServerSideEncryption("", "")
+		if assert.NoError(t, err) {
+			s3cli := &s3client{sse: sse}
+			assert.Nil(t, s3cli.putObjectOptions().ServerSideEncryption)
+		}
+	})
+	t.Run("AES256", func(t *testing.T) {
+		sse, err := newServerSideEncryption(SSEAlgorithmAES256, "")
+		if assert.NoError(t, err) {
+			s3cli := &s3client{sse: sse}
+			opts := s3cli.putObjectOptions()
+			if assert.NotNil(t, opts.ServerSideEncryption) {
+				assert.Equal(t, encrypt.S3, opts.ServerSideEncryption.Type())
+				h := http.Header{}
+				opts.ServerSideEncryption.Marshal(h)
+				assert.Equal(t, "AES256", h.Get("X-Amz-Server-Side-Encryption"))
+			}
+		}
+	})
+	t.Run("KMS", func(t *testing.T) {
+		sse, err := newServerSideEncryption(SSEAlgorithmKMS, "my-key")
+		if assert.NoError(t, err) {
+			s3cli := &s3client{sse: sse}
+			opts := s3cli.putObjectOptions()
+			if assert.NotNil(t, opts.ServerSideEncryption) {
+				assert.Equal(t, encrypt.KMS, opts.ServerSideEncryption.Type())
+				h := http.Header{}
+				opts.ServerSideEncryption.Marshal(h)
+				assert.Equal(t, "aws:kms", h.Get("X-Amz-Server-Side-Encryption"))
+				assert.Equal(t, "my-key", h.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+			}
+		}
+	})
+	t.Run("Unsupported", func(t *testing.T) {
+		_, err := newServerSideEncryption("rot13", "")
+		assert.Error(t, err)
+	})
+	t.Run("Multipart", func(t *testing.T) {
+		s3cli := &s3client{S3ClientOpts: S3ClientOpts{PartSize: 64 * 1024 * 1024, PartConcurrency: 8}}
+		opts := s3cli.putObjectOptions()
+		assert.Equal(t, uint64(64*1024*1024), opts.PartSize)
+		assert.Equal(t, uint(8), opts.NumThreads)
+	})
+	t.Run("DefaultMultipart", func(t *testing.T) {
+		// minio-go picks the part size and concurrency
+		opts := (&s3client{}).putObjectOptions()
+		assert.Equal(t, uint64(0), opts.PartSize)
+		assert.Equal(t, uint(0), opts.NumThreads)
+	})
+}
+
+func TestGetObjectOptions(t *testing.T) {
+	t.Run("RequesterPays", func(t *testing.T) {
+		s3cli := &s3client{S3ClientOpts: S3ClientOpts{RequesterPays: true}}
+		assert.Equal(t, "requester", s3cli.getObjectOptions().Header().Get(requestPayerHeader))
+	})
+	t.Run("BucketOwnerPays", func(t *testing.T) {
+		s3cli := &s3client{}
+		assert.Empty(t, s3cli.getObjectOptions().Header().Get(requestPayerHeader))
+	})
+}
+
+func TestBucketLookupType(t *testing.T) {
+	pathStyle, virtualHostedStyle := true, false
+	for _, tt := range []struct {
+		name      string
+		endpoint  string
+		pathStyle *bool
+		want      minio.BucketLookupType
+	}{
+		{"CustomEndpointDefault", "minio.example.com:9000", nil, minio.BucketLookupAuto},
+		{"CustomEndpointPathStyle", "minio.example.com:9000", &pathStyle, minio.BucketLookupPath},
+		{"CustomEndpointVirtualHostedStyle", "minio.example.com:9000", &virtualHostedStyle, minio.BucketLookupDNS},
+		{"AWSDefault", "s3.amazonaws.com", nil, minio.BucketLookupPath},
+		{"AWSPathStyle", "s3.amazonaws.com", &pathStyle, minio.BucketLookupPath},
+		{"AWSVirtualHostedStyle", "s3.amazonaws.com", &virtualHostedStyle, minio.BucketLookupDNS},
+		{"AWSRegionDefault", "s3.us-west-2.amazonaws.com", nil, minio.BucketLookupAuto},
+		{"AWSRegionPathStyle", "s3.us-west-2.amazonaws.com", &pathStyle, minio.BucketLookupPath},
+		{"AWSRegionVirtualHostedStyle", "s3.us-west-2.amazonaws.com", &virtualHostedStyle, minio.BucketLookupDNS},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, bucketLookupType(S3ClientOpts{Endpoint: tt.endpoint, PathStyle: tt.pathStyle}))
+		})
+	}
+	t.Run("AWSAccelerateEndpoint", func(t *testing.T) {
+		assert.Equal(t, minio.BucketLookupDNS, bucketLookupType(S3ClientOpts{Endpoint: "s3.amazonaws.com", UseAccelerateEndpoint: true}))
+	})
+}
+
+func TestProgressHook(t *testing.T) {
+	var transferred []int64
+	counter := artifactscommon.NewProgressCounter(100, func(bytesTransferred, totalBytes int64) {
+		assert.Equal(t, int64(100), totalBytes)
+		transferred = append(transferred, bytesTransferred)
+	})
+	hook := progressHook{counter}
+	for i := 0; i < 4; i++ {
+		n, err := hook.Read(make([]byte, 25))
+		assert.NoError(t, err)
+		assert.Equal(t, 25, n)
+	}
+	counter.Done()
+	assert.Equal(t, []int64{25, 50, 75, 100}, transferred)
+}
+
+// newTestDirectory creates a directory of files, and returns the keys they are expected to be put at under "my-key"
+func newTestDirectory(t *testing.T, n int) (string, []string) {
+	dir, err := ioutil.TempDir("", "s3-put")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	var keys []string
+	for i := 0; i < n; i++ {
+		rel := filepath.Join(fmt.Sprintf("dir-%d", i%3), fmt.Sprintf("file-%d", i))
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(rel)), 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, rel), []byte(rel), 0600))
+		keys = append(keys, "my-key/"+filepath.ToSlash(rel))
+	}
+	return dir, keys
+}
+
+func TestPutDirectory(t *testing.T) {
+	t.Run("Keys", func(t *testing.T) {
+		dir, keys := newTestDirectory(t, 20)
+		defer func() { _ = os.RemoveAll(dir) }()
+		var lock sync.Mutex
+		var put []string
+		err := putDirectory(context.Background(), "my-key", dir, artifactscommon.FileFilter{}, 5, func(_ context.Context, task uploadTask) error {
+			lock.Lock()
+			defer lock.Unlock()
+			put = append(put, task.key)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, keys, put)
+	})
+	t.Run("Filter", func(t *testing.T) {
+		dir, _ := newTestDirectory(t, 20)
+		defer func() { _ = os.RemoveAll(dir) }()
+		var lock sync.Mutex
+		var put []string
+		filter := artifactscommon.FileFilter{Include: []string{"dir-1/**"}, Exclude: []string{"**/file-4"}}
+		err := putDirectory(context.Background(), "my-key", dir, filter, 5, func(_ context.Context, task uploadTask) error {
+			lock.Lock()
+			defer lock.Unlock()
+			put = append(put, task.key)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"my-key/dir-1/file-1", "my-key/dir-1/file-7", "my-key/dir-1/file-10", "my-key/dir-1/file-13", "my-key/dir-1/file-16", "my-key/dir-1/file-19"}, put)
+	})
+	t.Run("Bounded", func(t *testing.T) {
+		dir, _ := newTestDirectory(t, 20)
+		defer func() { _ = os.RemoveAll(dir) }()
+		var running, maxRunning int32
+		err := putDirectory(context.Background(), "my-key", dir, artifactscommon.FileFilter{}, 3, func(context.Context, uploadTask) error {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, maxRunning, int32(3))
+		assert.Greater(t, maxRunning, int32(1))
+	})
+	t.Run("Failure", func(t *testing.T) {
+		dir, _ := newTestDirectory(t, 20)
+		defer func() { _ = os.RemoveAll(dir) }()
+		failing := filepath.Join(dir, "dir-1", "file-4")
+		var attempted int32
+		err := putDirectory(context.Background(), "my-key", dir, artifactscommon.FileFilter{}, 2, func(ctx context.Context, task uploadTask) error {
+			atomic.AddInt32(&attempted, 1)
+			if task.path == failing {
+				return fmt.Errorf("access denied")
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(10 * time.Millisecond):
+				return nil
+			}
+		})
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), failing+" (access denied)")
+			assert.Contains(t, err.Error(), "1 file(s)")
+		}
+		assert.Less(t, attempted, int32(20))
+	})
+	t.Run("Cancelled", func(t *testing.T) {
+		dir, _ := newTestDirectory(t, 5)
+		defer func() { _ = os.RemoveAll(dir) }()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := putDirectory(ctx, "my-key", dir, artifactscommon.FileFilter{}, 2, func(context.Context, uploadTask) error { return nil })
+		assert.Equal(t, context.Canceled, err)
+	})
+}
+
+func TestNewS3Client_RootCAs(t *testing.T) {
+	var requests int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	s3cli, err := NewS3Client(context.Background(), S3ClientOpts{
+		Endpoint:  strings.TrimPrefix(server.URL, "https://"),
+		Region:    "us-east-1",
+		Secure:    true,
+		AccessKey: "my-access-key",
+		SecretKey: "my-secret-key",
+		RootCAs:   pool,
+	})
+	if assert.NoError(t, err) {
+		_, err = s3cli.StatObject("my-bucket", "my-key")
+		// the TLS connection was verified, and the server responded
+		assert.Error(t, err)
+		assert.NotContains(t, err.Error(), "certificate")
+		assert.NotZero(t, atomic.LoadInt32(&requests))
+	}
+}
+
+func TestNewS3Client_ProxyURL(t *testing.T) {
+	var lock sync.Mutex
+	var hosts []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		hosts = append(hosts, r.URL.Host)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	assert.NoError(t, err)
+	s3cli, err := NewS3Client(context.Background(), S3ClientOpts{
+		Endpoint:  "s3.example.com",
+		Region:    "us-east-1",
+		AccessKey: "my-access-key",
+		SecretKey: "my-secret-key",
+		ProxyURL:  proxyURL,
+	})
+	if assert.NoError(t, err) {
+		_, err = s3cli.StatObject("my-bucket", "my-key")
+		assert.Error(t, err)
+		lock.Lock()
+		defer lock.Unlock()
+		if assert.NotEmpty(t, hosts) {
+			assert.Contains(t, hosts[0], "s3.example.com")
+		}
+	}
+}
+
+func TestNewS3Client_AccelerateEndpoint(t *testing.T) {
+	var lock sync.Mutex
+	var hosts []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		hosts = append(hosts, r.URL.Host)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	assert.NoError(t, err)
+	opts := S3ClientOpts{
+		Endpoint:              "s3.amazonaws.com",
+		Region:                "us-east-1",
+		AccessKey:             "my-access-key",
+		SecretKey:             "my-secret-key",
+		ProxyURL:              proxyURL,
+		UseAccelerateEndpoint: true,
+	}
+	s3cli, err := NewS3Client(context.Background(), opts)
+	if assert.NoError(t, err) {
+		_, err = s3cli.StatObject("my-bucket", "my-key")
+		assert.Error(t, err)
+		lock.Lock()
+		defer lock.Unlock()
+		if assert.NotEmpty(t, hosts) {
+			assert.Equal(t, "my-bucket.s3-accelerate.amazonaws.com", hosts[0])
+		}
+	}
+	t.Run("CustomEndpoint", func(t *testing.T) {
+		opts := opts
+		opts.Endpoint = "minio.example.com:9000"
+		_, err := NewS3Client(context.Background(), opts)
+		assert.EqualError(t, err, "useAccelerateEndpoint cannot be used with the custom endpoint minio.example.com:9000, only with that of AWS")
+	})
+	t.Run("PathStyle", func(t *testing.T) {
+		opts := opts
+		pathStyle := true
+		opts.PathStyle = &pathStyle
+		_, err := NewS3Client(context.Background(), opts)
+		assert.EqualError(t, err, "useAccelerateEndpoint cannot be used with path-style addressing, as the accelerate endpoint only addresses buckets in the hostname")
+	})
+}
+
+func TestNewS3Client_ObjectTags(t *testing.T) {
+	var lock sync.Mutex
+	var tagging []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			lock.Lock()
+			tagging = append(tagging, r.Header.Get("X-Amz-Tagging"))
+			lock.Unlock()
+		}
+		w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	tags := map[string]string{"CostCenter": "1234", "Team": "data eng"}
+	s3cli, err := NewS3Client(context.Background(), S3ClientOpts{
+		Endpoint:   strings.TrimPrefix(server.URL, "http://"),
+		Region:     "us-east-1",
+		AccessKey:  "my-access-key",
+		SecretKey:  "my-secret-key",
+		ObjectTags: tags,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	dir, keys := newTestDirectory(t, 3)
+	defer func() { _ = os.RemoveAll(dir) }()
+	assert.NoError(t, s3cli.PutFile("my-bucket", "my-key", filepath.Join(dir, "dir-0", "file-0")))
+	assert.NoError(t, s3cli.PutDirectory("my-bucket", "my-key", dir, artifactscommon.FileFilter{}))
+	lock.Lock()
+	defer lock.Unlock()
+	// the file, and every file of the directory, is tagged
+	assert.Len(t, tagging, 1+len(keys))
+	for _, header := range tagging {
+		// the tags are URL encoded, as S3 requires
+		assert.NotContains(t, header, " ")
+		values, err := url.ParseQuery(header)
+		if assert.NoError(t, err) {
+			assert.Equal(t, url.Values{"CostCenter": {"1234"}, "Team": {"data eng"}}, values)
+		}
+	}
+}
+
+func TestNewS3Client_ACL(t *testing.T) {
+	var lock sync.Mutex
+	var headers []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			lock.Lock()
+			headers = append(headers, r.Header)
+			lock.Unlock()
+		}
+		w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	newClient := func(acl *wfv1.S3ObjectACL) S3Client {
+		s3cli, err := NewS3Client(context.Background(), S3ClientOpts{
+			Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+			Region:    "us-east-1",
+			AccessKey: "my-access-key",
+			SecretKey: "my-secret-key",
+			ACL:       acl,
+		})
+		assert.NoError(t, err)
+		return s3cli
+	}
+	dir, keys := newTestDirectory(t, 3)
+	defer func() { _ = os.RemoveAll(dir) }()
+	t.Run("Canned", func(t *testing.T) {
+		headers = nil
+		s3cli := newClient(&wfv1.S3ObjectACL{Canned: wfv1.S3CannedACLBucketOwnerFullControl})
+		assert.NoError(t, s3cli.PutFile("my-bucket", "my-key", filepath.Join(dir, "dir-0", "file-0")))
+		assert.NoError(t, s3cli.PutDirectory("my-bucket", "my-key", dir, artifactscommon.FileFilter{}))
+		lock.Lock()
+		defer lock.Unlock()
+		// the file, and every file of the directory, has the ACL
+		assert.Len(t, headers, 1+len(keys))
+		for _, header := range headers {
+			assert.Equal(t, "bucket-owner-full-control", header.Get("X-Amz-Acl"))
+			assert.Empty(t, header.Get("X-Amz-Meta-X-Amz-Acl"))
+		}
+	})
+	t.Run("Grants", func(t *testing.T) {
+		headers = nil
+		s3cli := newClient(&wfv1.S3ObjectACL{
+			GrantRead:        []string{`id="my-canonical-user-id"`, `uri="http://acs.amazonaws.com/groups/global/AllUsers"`},
+			GrantFullControl: []string{`emailAddress="me@example.com"`},
+		})
+		// the ACL is set with the user metadata of the file
+		metadata := map[string]string{artifactscommon.ContentEncodingMetadataKey: "gzip"}
+		assert.NoError(t, s3cli.PutFileWithProgress("my-bucket", "my-key", filepath.Join(dir, "dir-0", "file-0"), metadata, nil))
+		lock.Lock()
+		defer lock.Unlock()
+		if assert.Len(t, headers, 1) {
+			header := headers[0]
+			assert.Equal(t, `id="my-canonical-user-id", uri="http://acs.amazonaws.com/groups/global/AllUsers"`, header.Get("X-Amz-Grant-Read"))
+			assert.Equal(t, `emailAddress="me@example.com"`, header.Get("X-Amz-Grant-Full-Control"))
+			assert.Empty(t, header.Get("X-Amz-Acl"))
+			assert.Equal(t, "gzip", header.Get("X-Amz-Meta-"+artifactscommon.ContentEncodingMetadataKey))
+		}
+	})
+	t.Run("None", func(t *testing.T) {
+		headers = nil
+		assert.NoError(t, newClient(nil).PutFile("my-bucket", "my-key", filepath.Join(dir, "dir-0", "file-0")))
+		lock.Lock()
+		defer lock.Unlock()
+		if assert.Len(t, headers, 1) {
+			assert.Empty(t, headers[0].Get("X-Amz-Acl"))
+		}
+	})
+}
+
+func TestNewS3Client_ObjectLock(t *testing.T) {
+	var lock sync.Mutex
+	var headers []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["object-lock"]; ok {
+			if strings.Trim(r.URL.Path, "/") != "locked" {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><Error><Code>ObjectLockConfigurationNotFoundError</Code><Message>Object Lock configuration does not exist for this bucket</Message></Error>`))
+				return
+			}
+			_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled></ObjectLockConfiguration>`))
+			return
+		}
+		if r.Method == http.MethodPut {
+			lock.Lock()
+			headers = append(headers, r.Header)
+			lock.Unlock()
+		}
+		w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	newClient := func(objectLock *wfv1.S3ObjectLock) S3Client {
+		s3cli, err := NewS3Client(context.Background(), S3ClientOpts{
+			Endpoint:   strings.TrimPrefix(server.URL, "http://"),
+			Region:     "us-east-1",
+			AccessKey:  "my-access-key",
+			SecretKey:  "my-secret-key",
+			ObjectLock: objectLock,
+		})
+		assert.NoError(t, err)
+		return s3cli
+	}
+	dir, keys := newTestDirectory(t, 3)
+	defer func() { _ = os.RemoveAll(dir) }()
+	t.Run("Retention", func(t *testing.T) {
+		headers = nil
+		s3cli := newClient(&wfv1.S3ObjectLock{
+			Mode:            wfv1.S3RetentionModeCompliance,
+			RetainUntilDate: &metav1.Time{Time: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)},
+			LegalHold:       true,
+		})
+		assert.NoError(t, s3cli.PutFile("locked", "my-key", filepath.Join(dir, "dir-0", "file-0")))
+		assert.NoError(t, s3cli.PutDirectory("locked", "my-key", dir, artifactscommon.FileFilter{}))
+		lock.Lock()
+		defer lock.Unlock()
+		// the file, and every file of the directory, is locked
+		assert.Len(t, headers, 1+len(keys))
+		for _, header := range headers {
+			assert.Equal(t, "COMPLIANCE", header.Get("X-Amz-Object-Lock-Mode"))
+			assert.Equal(t, "2030-01-02T03:04:05Z", header.Get("X-Amz-Object-Lock-Retain-Until-Date"))
+			assert.Equal(t, "ON", header.Get("X-Amz-Object-Lock-Legal-Hold"))
+			assert.NotEmpty(t, header.Get("Content-Md5"))
+		}
+	})
+	t.Run("LegalHold", func(t *testing.T) {
+		headers = nil
+		assert.NoError(t, newClient(&wfv1.S3ObjectLock{LegalHold: true}).PutFile("locked", "my-key", filepath.Join(dir, "dir-0", "file-0")))
+		lock.Lock()
+		defer lock.Unlock()
+		if assert.Len(t, headers, 1) {
+			assert.Equal(t, "ON", headers[0].Get("X-Amz-Object-Lock-Legal-Hold"))
+			assert.Empty(t, headers[0].Get("X-Amz-Object-Lock-Mode"))
+			assert.Empty(t, headers[0].Get("X-Amz-Object-Lock-Retain-Until-Date"))
+		}
+	})
+	t.Run("None", func(t *testing.T) {
+		headers = nil
+		assert.NoError(t, newClient(nil).PutFile("locked", "my-key", filepath.Join(dir, "dir-0", "file-0")))
+		lock.Lock()
+		defer lock.Unlock()
+		if assert.Len(t, headers, 1) {
+			assert.Empty(t, headers[0].Get("X-Amz-Object-Lock-Mode"))
+			assert.Empty(t, headers[0].Get("X-Amz-Object-Lock-Legal-Hold"))
+		}
+	})
+	t.Run("IsObjectLockEnabled", func(t *testing.T) {
+		s3cli := newClient(nil)
+		enabled, err := s3cli.IsObjectLockEnabled("locked")
+		assert.NoError(t, err)
+		assert.True(t, enabled)
+		enabled, err = s3cli.IsObjectLockEnabled("unlocked")
+		assert.NoError(t, err)
+		assert.False(t, enabled)
+	})
+}
+
+func TestAssumedRoles(t *testing.T) {
+	t.Run("Single", func(t *testing.T) {
+		roles := assumedRoles(S3ClientOpts{RoleARN: "arn:aws:iam::1:role/my-role", RoleExternalID: "my-external-id"})
+		assert.Equal(t, []assumedRole{{roleARN: "arn:aws:iam::1:role/my-role", externalID: "my-external-id"}}, roles)
+		p := &stscreds.AssumeRoleProvider{RoleSessionName: "default", Duration: stscreds.DefaultDuration}
+		roles[0].configure(p)
+		if assert.NotNil(t, p.ExternalID) {
+			assert.Equal(t, "my-external-id", *p.ExternalID)
+		}
+		assert.Equal(t, "default", p.RoleSessionName)
+		assert.Equal(t, stscreds.DefaultDuration, p.Duration)
+	})
+	t.Run("Chained", func(t *testing.T) {
+		roles := assumedRoles(S3ClientOpts{
+			RoleARN:             "arn:aws:iam::2:role/my-role",
+			RoleExternalID:      "my-external-id",
+			RoleChain:           []string{"arn:aws:iam::1:role/intermediate"},
+			RoleSessionName:     "my-session",
+			RoleSessionDuration: time.Hour,
+		})
+		assert.Equal(t, []assumedRole{
+			{roleARN: "arn:aws:iam::1:role/intermediate", sessionName: "my-session", duration: time.Hour},
+			{roleARN: "arn:aws:iam::2:role/my-role", externalID: "my-external-id", sessionName: "my-session", duration: time.Hour},
+		}, roles)
+		p := &stscreds.AssumeRoleProvider{}
+		roles[0].configure(p)
+		assert.Nil(t, p.ExternalID)
+		assert.Equal(t, "my-session", p.RoleSessionName)
+		assert.Equal(t, time.Hour, p.Duration)
+	})
+}
+
+// assumeRoleRequest is the parameters of an STS AssumeRole request, and the access key it was signed with
+type assumeRoleRequest struct {
+	accessKey, roleARN, externalID, sessionName, durationSeconds string
+}
+
+func TestAssumeRoles(t *testing.T) {
+	var lock sync.Mutex
+	var requests []assumeRoleRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		lock.Lock()
+		defer lock.Unlock()
+		credential := strings.SplitN(strings.SplitN(r.Header.Get("Authorization"), "Credential=", 2)[1], "/", 2)[0]
+		requests = append(requests, assumeRoleRequest{
+			accessKey:       credential,
+			roleARN:         r.Form.Get("RoleArn"),
+			externalID:      r.Form.Get("ExternalId"),
+			sessionName:     r.Form.Get("RoleSessionName"),
+			durationSeconds: r.Form.Get("DurationSeconds"),
+		})
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = fmt.Fprintf(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><AssumeRoleResult><Credentials>`+
+			`<AccessKeyId>assumed-%d</AccessKeyId><SecretAccessKey>my-secret-key</SecretAccessKey><SessionToken>my-token</SessionToken>`+
+			`<Expiration>2100-01-01T00:00:00Z</Expiration></Credentials></AssumeRoleResult></AssumeRoleResponse>`, len(requests))
+	}))
+	defer server.Close()
+	sess, err := session.NewSession(&aws.Config{
+		Endpoint:    aws.String(server.URL),
+		Region:      aws.String("us-east-1"),
+		Credentials: awscredentials.NewStaticCredentials("my-access-key", "my-secret-key", ""),
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	t.Run("Single", func(t *testing.T) {
+		requests = nil
+		creds, err := assumeRoles(sess, S3ClientOpts{RoleARN: "arn:aws:iam::1:role/my-role", RoleExternalID: "my-external-id", RoleSessionName: "my-session"})
+		if assert.NoError(t, err) {
+			value, err := creds.Get()
+			assert.NoError(t, err)
+			assert.Equal(t, "assumed-1", value.AccessKeyID)
+			assert.Equal(t, "my-token", value.SessionToken)
+		}
+		assert.Equal(t, []assumeRoleRequest{
+			{accessKey: "my-access-key", roleARN: "arn:aws:iam::1:role/my-role", externalID: "my-external-id", sessionName: "my-session", durationSeconds: "900"},
+		}, requests)
+	})
+	t.Run("Chained", func(t *testing.T) {
+		requests = nil
+		creds, err := assumeRoles(sess, S3ClientOpts{
+			RoleARN:             "arn:aws:iam::2:role/my-role",
+			RoleExternalID:      "my-external-id",
+			RoleChain:           []string{"arn:aws:iam::1:role/intermediate"},
+			RoleSessionName:     "my-session",
+			RoleSessionDuration: time.Hour,
+		})
+		if assert.NoError(t, err) {
+			value, err := creds.Get()
+			assert.NoError(t, err)
+			assert.Equal(t, "assumed-2", value.AccessKeyID)
+		}
+		// the role is assumed with the credentials of the intermediate role
+		assert.Equal(t, []assumeRoleRequest{
+			{accessKey: "my-access-key", roleARN: "arn:aws:iam::1:role/intermediate", sessionName: "my-session", durationSeconds: "3600"},
+			{accessKey: "assumed-1", roleARN: "arn:aws:iam::2:role/my-role", externalID: "my-external-id", sessionName: "my-session", durationSeconds: "3600"},
+		}, requests)
+	})
+}
+
+// setEnv sets the environment variables, unsetting those which are empty, returning a function which restores them
+func setEnv(t *testing.T, env map[string]string) func() {
+	restore := map[string]*string{}
+	for k, v := range env {
+		if old, ok := os.LookupEnv(k); ok {
+			restore[k] = &old
+		} else {
+			restore[k] = nil
+		}
+		if v == "" {
+			assert.NoError(t, os.Unsetenv(k))
+		} else {
+			assert.NoError(t, os.Setenv(k, v))
+		}
+	}
+	return func() {
+		for k, v := range restore {
+			if v == nil {
+				_ = os.Unsetenv(k)
+			} else {
+				_ = os.Setenv(k, *v)
+			}
+		}
+	}
+}
+
+func TestCredentialProviders(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "s3")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	tokenFile := filepath.Join(tmp, "token")
+	assert.NoError(t, ioutil.WriteFile(tokenFile, []byte("my-web-identity-token"), 0600))
+	// the server is STS and the instance metadata service, of an instance which has an instance profile if it is set
+	var lock sync.Mutex
+	instanceProfile := false
+	var assumedWith []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch {
+		case r.Method == http.MethodPost:
+			assert.NoError(t, r.ParseForm())
+			w.Header().Set("Content-Type", "text/xml")
+			action := r.Form.Get("Action")
+			accessKey := "web-identity-key"
+			if action == "AssumeRoleWithWebIdentity" {
+				assert.Equal(t, "my-web-identity-token", r.Form.Get("WebIdentityToken"))
+				assert.Equal(t, "arn:aws:iam::1:role/my-web-identity-role", r.Form.Get("RoleArn"))
+			} else {
+				assumedWith = append(assumedWith, strings.SplitN(strings.SplitN(r.Header.Get("Authorization"), "Credential=", 2)[1], "/", 2)[0])
+				accessKey = "assumed-key"
+			}
+			_, _ = fmt.Fprintf(w, `<%[1]sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><%[1]sResult><Credentials>`+
+				`<AccessKeyId>%[2]s</AccessKeyId><SecretAccessKey>my-secret-key</SecretAccessKey><SessionToken>my-token</SessionToken>`+
+				`<Expiration>2100-01-01T00:00:00Z</Expiration></Credentials></%[1]sResult></%[1]sResponse>`, action, accessKey)
+		case !instanceProfile || r.Method != http.MethodGet:
+			// the instance metadata service is then used without a session token, as IMDSv1
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/":
+			_, _ = fmt.Fprint(w, "my-instance-role")
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/my-instance-role":
+			_, _ = fmt.Fprint(w, `{"Code":"Success","Type":"AWS-HMAC","AccessKeyId":"instance-profile-key","SecretAccessKey":"my-secret-key","Token":"my-token","Expiration":"2100-01-01T00:00:00Z"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	sess, err := session.NewSession(&aws.Config{Endpoint: aws.String(server.URL), Region: aws.String("us-east-1")})
+	if !assert.NoError(t, err) {
+		return
+	}
+	all := []wfv1.S3CredentialProvider{wfv1.S3CredentialProviderStatic, wfv1.S3CredentialProviderWebIdentity, wfv1.S3CredentialProviderInstanceProfile, wfv1.S3CredentialProviderEnv}
+	// available are the providers which yield credentials
+	tests := []struct {
+		name      string
+		providers []wfv1.S3CredentialProvider
+		available []wfv1.S3CredentialProvider
+		selected  wfv1.S3CredentialProvider
+		accessKey string
+	}{
+		{"All", all, all, wfv1.S3CredentialProviderStatic, "static-key"},
+		{"WebIdentity", all, []wfv1.S3CredentialProvider{wfv1.S3CredentialProviderWebIdentity, wfv1.S3CredentialProviderInstanceProfile, wfv1.S3CredentialProviderEnv}, wfv1.S3CredentialProviderWebIdentity, "web-identity-key"},
+		{"InstanceProfile", all, []wfv1.S3CredentialProvider{wfv1.S3CredentialProviderInstanceProfile, wfv1.S3CredentialProviderEnv}, wfv1.S3CredentialProviderInstanceProfile, "instance-profile-key"},
+		{"Env", all, []wfv1.S3CredentialProvider{wfv1.S3CredentialProviderEnv}, wfv1.S3CredentialProviderEnv, "env-key"},
+		{"Order", []wfv1.S3CredentialProvider{wfv1.S3CredentialProviderEnv, wfv1.S3CredentialProviderStatic}, all, wfv1.S3CredentialProviderEnv, "env-key"},
+		{"Unlisted", []wfv1.S3CredentialProvider{wfv1.S3CredentialProviderWebIdentity}, []wfv1.S3CredentialProvider{wfv1.S3CredentialProviderStatic, wfv1.S3CredentialProviderEnv}, "", ""},
+		{"None", all, nil, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			available := map[wfv1.S3CredentialProvider]bool{}
+			for _, provider := range tt.available {
+				available[provider] = true
+			}
+			env := map[string]string{"AWS_ACCESS_KEY_ID": "", "AWS_ACCESS_KEY": "", "AWS_SECRET_ACCESS_KEY": "", "AWS_SECRET_KEY": "", "AWS_SESSION_TOKEN": "", "AWS_WEB_IDENTITY_TOKEN_FILE": "", "AWS_ROLE_ARN": "", "AWS_ROLE_SESSION_NAME": ""}
+			if available[wfv1.S3CredentialProviderEnv] {
+				env["AWS_ACCESS_KEY_ID"], env["AWS_SECRET_ACCESS_KEY"] = "env-key", "my-secret-key"
+			}
+			if available[wfv1.S3CredentialProviderWebIdentity] {
+				env["AWS_WEB_IDENTITY_TOKEN_FILE"], env["AWS_ROLE_ARN"] = tokenFile, "arn:aws:iam::1:role/my-web-identity-role"
+			}
+			defer setEnv(t, env)()
+			lock.Lock()
+			instanceProfile = available[wfv1.S3CredentialProviderInstanceProfile]
+			lock.Unlock()
+			opts := S3ClientOpts{CredentialProviders: tt.providers}
+			if available[wfv1.S3CredentialProviderStatic] {
+				opts.AccessKey, opts.SecretKey = "static-key", "my-secret-key"
+			}
+			selected, creds, err := firstCredentials(credentialProviders(sess, opts))
+			if tt.selected == "" {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.selected, selected)
+				value, err := creds.Get()
+				assert.NoError(t, err)
+				assert.Equal(t, tt.accessKey, value.AccessKeyID)
+			}
+		})
+	}
+	t.Run("AssumeRole", func(t *testing.T) {
+		defer setEnv(t, map[string]string{"AWS_ACCESS_KEY_ID": "env-key", "AWS_SECRET_ACCESS_KEY": "my-secret-key"})()
+		assumedWith = nil
+		// the role is assumed with the credentials of the first available provider
+		creds, err := getProviderCredentials(sess, S3ClientOpts{CredentialProviders: []wfv1.S3CredentialProvider{wfv1.S3CredentialProviderStatic, wfv1.S3CredentialProviderEnv}, RoleARN: "arn:aws:iam::1:role/my-role"})
+		if assert.NoError(t, err) {
+			value, err := creds.Get()
+			assert.NoError(t, err)
+			assert.Equal(t, "assumed-key", value.AccessKeyID)
+		}
+		assert.Equal(t, []string{"env-key"}, assumedWith)
+	})
+}
@@ -0,0 +1,85 @@
+package s3
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	artifactscommon "github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const (
+	defaultRetryLimit     = 4
+	defaultRetryBaseDelay = time.Second
+)
+
+// NewRetryBackoff returns the exponential backoff of the retries of the operations of a client. No operation is
+// retried if retry is nil.
+func NewRetryBackoff(retry *wfv1.S3Retry) (wait.Backoff, error) {
+	if retry == nil {
+		return wait.Backoff{}, nil
+	}
+	backoff := wait.Backoff{Duration: defaultRetryBaseDelay, Factor: 2.0, Steps: defaultRetryLimit, Jitter: 0.1}
+	if retry.Limit != nil {
+		if *retry.Limit < 0 {
+			return backoff, fmt.Errorf("limit must not be negative")
+		}
+		backoff.Steps = int(*retry.Limit)
+	}
+	if retry.BaseDelay != "" {
+		delay, err := time.ParseDuration(retry.BaseDelay)
+		if err != nil {
+			return backoff, fmt.Errorf("invalid base delay %q: %v", retry.BaseDelay, err)
+		}
+		if delay < 0 {
+			return backoff, fmt.Errorf("base delay %q must not be negative", retry.BaseDelay)
+		}
+		backoff.Duration = delay
+	}
+	return backoff, nil
+}
+
+// retry calls f until it succeeds, fails with an error which is not retryable, or the steps of backoff run out.
+// minio-go retries each request itself, briefly, so every call of f includes those retries.
+func retry(ctx context.Context, backoff wait.Backoff, logger log.FieldLogger, f func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || backoff.Steps < 1 || !isRetryable(err) || ctx.Err() != nil {
+			return err
+		}
+		delay := backoff.Step()
+		logger.WithField("attempt", attempt).Warnf("%v, retrying in %v", err, delay)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// isRetryable returns whether an operation which failed with err can be retried: the lists, gets and puts of the
+// client are idempotent, so they are retried on 5xx responses, timeouts and dropped connections, but not once the
+// circuit breaker of the endpoint is open
+func isRetryable(err error) bool {
+	if err == nil || stderrors.Is(err, context.Canceled) || stderrors.Is(err, context.DeadlineExceeded) || stderrors.Is(err, artifactscommon.ErrCircuitOpen) {
+		return false
+	}
+	var response minio.ErrorResponse
+	if stderrors.As(err, &response) {
+		return response.StatusCode >= 500
+	}
+	var netErr net.Error
+	if stderrors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return stderrors.Is(err, syscall.ECONNRESET) || stderrors.Is(err, syscall.EPIPE) || stderrors.Is(err, io.ErrUnexpectedEOF)
+}
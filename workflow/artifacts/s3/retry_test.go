@@ -0,0 +1,228 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	artifactscommon "github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+var (
+	internalError = minio.ErrorResponse{StatusCode: 500, Code: "InternalError", Message: "We encountered an internal error, please try again."}
+	accessDenied  = minio.ErrorResponse{StatusCode: 403, Code: "AccessDenied", Message: "Access Denied."}
+	noSuchKey     = minio.ErrorResponse{StatusCode: 404, Code: "NoSuchKey", Message: "The specified key does not exist."}
+	connReset     = &url.Error{Op: "Get", URL: "https://minio.example.com/my-bucket", Err: &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}}
+)
+
+// failingOperation fails with err the first failures times it is called
+type failingOperation struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (o *failingOperation) call() error {
+	o.calls++
+	if o.calls <= o.failures {
+		return o.err
+	}
+	return nil
+}
+
+func TestRetry(t *testing.T) {
+	backoff := wait.Backoff{Duration: time.Millisecond, Factor: 2.0, Steps: 3}
+	logger := log.NewEntry(log.StandardLogger())
+	t.Run("SucceedsAfterFailures", func(t *testing.T) {
+		op := &failingOperation{failures: 3, err: internalError}
+		assert.NoError(t, retry(context.Background(), backoff, logger, op.call))
+		assert.Equal(t, 4, op.calls)
+	})
+	t.Run("RetriesRunOut", func(t *testing.T) {
+		op := &failingOperation{failures: 4, err: internalError}
+		err := retry(context.Background(), backoff, logger, op.call)
+		assert.True(t, IsS3ErrCode(err, "InternalError"))
+		assert.Equal(t, 4, op.calls)
+	})
+	t.Run("NetworkError", func(t *testing.T) {
+		op := &failingOperation{failures: 2, err: connReset}
+		assert.NoError(t, retry(context.Background(), backoff, logger, op.call))
+		assert.Equal(t, 3, op.calls)
+	})
+	t.Run("NotRetryable", func(t *testing.T) {
+		for _, err := range []error{accessDenied, noSuchKey} {
+			op := &failingOperation{failures: 1, err: err}
+			assert.Equal(t, err, retry(context.Background(), backoff, logger, op.call))
+			assert.Equal(t, 1, op.calls)
+		}
+	})
+	t.Run("NoBackoff", func(t *testing.T) {
+		op := &failingOperation{failures: 1, err: internalError}
+		assert.Error(t, retry(context.Background(), wait.Backoff{}, logger, op.call))
+		assert.Equal(t, 1, op.calls)
+	})
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		op := &failingOperation{failures: 2, err: internalError}
+		assert.Error(t, retry(ctx, backoff, logger, func() error {
+			cancel()
+			return op.call()
+		}))
+		assert.Equal(t, 1, op.calls)
+	})
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, isRetryable(internalError))
+	assert.True(t, isRetryable(minio.ErrorResponse{StatusCode: 503, Code: "SlowDown"}))
+	assert.True(t, isRetryable(connReset))
+	assert.True(t, isRetryable(fmt.Errorf("failed to list: %w", internalError)))
+	assert.False(t, isRetryable(nil))
+	assert.False(t, isRetryable(accessDenied))
+	assert.False(t, isRetryable(noSuchKey))
+	assert.False(t, isRetryable(context.Canceled))
+	assert.False(t, isRetryable(&url.Error{Op: "Get", URL: "https://minio.example.com/my-bucket", Err: context.DeadlineExceeded}))
+	assert.False(t, isRetryable(&url.Error{Op: "Get", URL: "https://minio.example.com/my-bucket", Err: fmt.Errorf("%w for https://minio.example.com", artifactscommon.ErrCircuitOpen)}))
+}
+
+func TestPutDirectory_Retryable(t *testing.T) {
+	dir, _ := newTestDirectory(t, 3)
+	defer func() { _ = os.RemoveAll(dir) }()
+	err := putDirectory(context.Background(), "my-key", dir, artifactscommon.FileFilter{}, 1, func(context.Context, uploadTask) error {
+		return internalError
+	})
+	assert.Error(t, err)
+	assert.True(t, isRetryable(err))
+}
+
+func TestNewRetryBackoff(t *testing.T) {
+	t.Run("NotSet", func(t *testing.T) {
+		backoff, err := NewRetryBackoff(nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, 0, backoff.Steps)
+		}
+	})
+	t.Run("Defaults", func(t *testing.T) {
+		backoff, err := NewRetryBackoff(&wfv1.S3Retry{})
+		if assert.NoError(t, err) {
+			assert.Equal(t, defaultRetryLimit, backoff.Steps)
+			assert.Equal(t, defaultRetryBaseDelay, backoff.Duration)
+		}
+	})
+	t.Run("Configured", func(t *testing.T) {
+		limit := int32(10)
+		backoff, err := NewRetryBackoff(&wfv1.S3Retry{Limit: &limit, BaseDelay: "500ms"})
+		if assert.NoError(t, err) {
+			assert.Equal(t, 10, backoff.Steps)
+			assert.Equal(t, 500*time.Millisecond, backoff.Duration)
+		}
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		limit := int32(-1)
+		_, err := NewRetryBackoff(&wfv1.S3Retry{Limit: &limit})
+		assert.Error(t, err)
+		_, err = NewRetryBackoff(&wfv1.S3Retry{BaseDelay: "soon"})
+		assert.Error(t, err)
+	})
+}
+
+func TestValidateArtifact_Retry(t *testing.T) {
+	art := &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Retry: &wfv1.S3Retry{BaseDelay: "soon"}}}
+	assert.Error(t, ValidateArtifact("inputs.artifacts.foo.s3", art))
+	art.Retry.BaseDelay = "2s"
+	assert.NoError(t, ValidateArtifact("inputs.artifacts.foo.s3", art))
+}
+
+func TestS3ArtifactDriver_Retry(t *testing.T) {
+	// minio-go retries requests itself too, which would hide the retries of the client
+	maxRetry := minio.MaxRetry
+	minio.MaxRetry = 1
+	defer func() { minio.MaxRetry = maxRetry }()
+	files := map[string]string{"dir/a": "a", "dir/sub/b": "b"}
+	var lock sync.Mutex
+	listFailures, getFailures, lists := 2, 1, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		fail := func() {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = fmt.Fprint(w, `<Error><Code>InternalError</Code><Message>We encountered an internal error, please try again.</Message></Error>`)
+		}
+		if r.URL.Path == "/my-bucket/" && r.URL.Query().Get("list-type") == "2" {
+			// the recursive listing of the directory fails, rather than the test of whether it is one
+			if r.URL.Query().Get("delimiter") == "" {
+				lists++
+				if listFailures > 0 {
+					listFailures--
+					fail()
+					return
+				}
+			}
+			_, _ = fmt.Fprint(w, `<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Name>my-bucket</Name><Prefix>dir/</Prefix><KeyCount>2</KeyCount><MaxKeys>1000</MaxKeys><IsTruncated>false</IsTruncated>`+
+				`<Contents><Key>dir/a</Key><Size>1</Size></Contents><Contents><Key>dir/sub/b</Key><Size>1</Size></Contents></ListBucketResult>`)
+			return
+		}
+		data, ok := files[strings.TrimPrefix(r.URL.Path, "/my-bucket/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodGet && getFailures > 0 {
+			getFailures--
+			fail()
+			return
+		}
+		w.Header().Set("ETag", `"0cc175b9c0f1b6a831c399e269772661"`)
+		http.ServeContent(w, r, "", time.Unix(1600000000, 0), strings.NewReader(data))
+	}))
+	defer server.Close()
+	tmp, err := ioutil.TempDir("", "s3")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	driver := &S3ArtifactDriver{
+		Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+		Region:    "us-east-1",
+		AccessKey: "my-access-key",
+		SecretKey: "my-secret-key",
+		Retry:     wait.Backoff{Duration: time.Millisecond, Factor: 2.0, Steps: 2},
+	}
+	art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: "dir"}}}
+	path := filepath.Join(tmp, "dir")
+	if assert.NoError(t, driver.Load(context.Background(), art, path)) {
+		for key, data := range files {
+			got, err := ioutil.ReadFile(filepath.Join(tmp, filepath.FromSlash(key)))
+			assert.NoError(t, err)
+			assert.Equal(t, data, string(got))
+		}
+	}
+	// the listing is retried on its own, rather than the whole load
+	assert.Equal(t, 3, lists)
+	assert.Equal(t, 0, getFailures)
+
+	t.Run("RetriesRunOut", func(t *testing.T) {
+		listFailures, lists = 3, 0
+		err := driver.Load(context.Background(), art, filepath.Join(tmp, "run-out"))
+		assert.True(t, IsS3ErrCode(err, "InternalError"))
+		// the driver does not retry the load once the retries of the client run out
+		assert.Equal(t, 3, lists)
+	})
+}
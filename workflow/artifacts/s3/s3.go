@@ -2,132 +2,1500 @@ package s3
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
 	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/argoproj/pkg/file"
-	argos3 "github.com/argoproj/pkg/s3"
 	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/s3utils"
 	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/wait"
 
 	"github.com/argoproj/argo-workflows/v3/errors"
 	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	artifactscommon "github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
 	"github.com/argoproj/argo-workflows/v3/workflow/common"
 )
 
 // S3ArtifactDriver is a driver for AWS S3
 type S3ArtifactDriver struct {
-	Endpoint    string
-	Region      string
-	Secure      bool
-	AccessKey   string
-	SecretKey   string
-	RoleARN     string
-	UseSDKCreds bool
-	Context     context.Context
+	Endpoint          string
+	Region            string
+	Secure            bool
+	AccessKey         string
+	SecretKey         string
+	RoleARN           string
+	UseSDKCreds       bool
+	SSEAlgorithm      string
+	KMSKeyID          string
+	PathStyle         *bool
+	RequesterPays     bool
+	UploadParallelism int
+	RootCAs           *x509.CertPool
+	ProxyURL          *url.URL
+	Context           context.Context
+	// RoleExternalID, RoleChain, RoleSessionName and RoleSessionDuration configure the assuming of RoleARN
+	RoleExternalID      string
+	RoleChain           []string
+	RoleSessionName     string
+	RoleSessionDuration time.Duration
+	// Retry is the backoff of the retries of each list, get and put of the client. Those which fail once its steps
+	// run out fail the operation of the driver, which does not retry them again.
+	Retry wait.Backoff
+	// UseAccelerateEndpoint sends requests to the S3 Transfer Acceleration endpoint
+	UseAccelerateEndpoint bool
+	// PartSize is the size of the parts of multipart uploads, and PartConcurrency the number of them uploaded at once,
+	// the minio-go defaults if not set
+	PartSize        int64
+	PartConcurrency int
+	// CredentialProviders are tried in turn for credentials, if set, rather than AccessKey, RoleARN or the IAM role
+	CredentialProviders []wfv1.S3CredentialProvider
+	// Anonymous loads public objects with unsigned requests, and refuses to save, copy or delete artifacts
+	Anonymous bool
+	// Bucket is the bucket of the artifact the driver was created for, which HealthCheck checks. Loads and saves use
+	// the bucket of the artifact they are given.
+	Bucket string
+	artifactscommon.Logging
+	artifactscommon.Transporting
+}
+
+// ValidateArtifact validates S3 artifact
+func ValidateArtifact(errPrefix string, art *wfv1.S3Artifact) error {
+	if sse := art.ServerSideEncryption; sse != nil {
+		switch sse.SSEAlgorithm {
+		case SSEAlgorithmAES256:
+			if sse.KMSKeyID != "" {
+				return errors.Errorf(errors.CodeBadRequest, "%s.serverSideEncryption.kmsKeyId is only valid with %s", errPrefix, SSEAlgorithmKMS)
+			}
+		case SSEAlgorithmKMS:
+		default:
+			return errors.Errorf(errors.CodeBadRequest, "%s.serverSideEncryption.sseAlgorithm must be one of %s or %s", errPrefix, SSEAlgorithmAES256, SSEAlgorithmKMS)
+		}
+	}
+	if art.UploadParallelism != nil && *art.UploadParallelism < 1 {
+		return errors.Errorf(errors.CodeBadRequest, "%s.uploadParallelism must be at least 1", errPrefix)
+	}
+	if _, err := ParsePartSize(art.PartSize); err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s.partSize %v", errPrefix, err)
+	}
+	if art.PartConcurrency != nil && *art.PartConcurrency < 1 {
+		return errors.Errorf(errors.CodeBadRequest, "%s.partConcurrency must be at least 1", errPrefix)
+	}
+	if err := validateAssumeRole(errPrefix, &art.S3Bucket); err != nil {
+		return err
+	}
+	if err := validateAccelerateEndpoint(errPrefix, &art.S3Bucket); err != nil {
+		return err
+	}
+	if err := validateCredentialProviders(errPrefix, &art.S3Bucket); err != nil {
+		return err
+	}
+	if err := validateAnonymous(errPrefix, &art.S3Bucket); err != nil {
+		return err
+	}
+	if _, err := NewRetryBackoff(art.Retry); err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s.retry is invalid: %v", errPrefix, err)
+	}
+	if err := validateACL(errPrefix+".acl", art.ACL); err != nil {
+		return err
+	}
+	if err := validateObjectLock(errPrefix+".objectLock", art.ObjectLock); err != nil {
+		return err
+	}
+	return validateTags(errPrefix+".tags", art.Tags)
+}
+
+// validateObjectLock checks that the object lock of objects sets retention, with both a mode and a date, or legal hold,
+// as S3 rejects puts which set only one of the mode and date
+func validateObjectLock(errPrefix string, lock *wfv1.S3ObjectLock) error {
+	if lock == nil {
+		return nil
+	}
+	switch lock.Mode {
+	case "", wfv1.S3RetentionModeGovernance, wfv1.S3RetentionModeCompliance:
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "%s.mode %q must be one of: %s, %s", errPrefix, lock.Mode, wfv1.S3RetentionModeGovernance, wfv1.S3RetentionModeCompliance)
+	}
+	if (lock.Mode != "") != (lock.RetainUntilDate != nil) {
+		return errors.Errorf(errors.CodeBadRequest, "%s.mode and %s.retainUntilDate must be set together", errPrefix, errPrefix)
+	}
+	if lock.Mode == "" && !lock.LegalHold {
+		return errors.Errorf(errors.CodeBadRequest, "%s must set mode and retainUntilDate, or legalHold", errPrefix)
+	}
+	return nil
+}
+
+// ValidateAtomicSave checks that an output artifact saved atomically sets no ACL or object lock, as its objects are
+// copied from their staging keys, which does not set their ACL, and its staging objects would be locked, and so could
+// not be deleted once they are copied
+func ValidateAtomicSave(errPrefix string, art *wfv1.Artifact) error {
+	if art.S3 == nil || !art.AtomicSave {
+		return nil
+	}
+	if art.S3.ACL != nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s.s3.acl cannot be used with atomicSave", errPrefix)
+	}
+	if art.S3.ObjectLock != nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s.s3.objectLock cannot be used with atomicSave", errPrefix)
+	}
+	return nil
+}
+
+// cannedACLs are the canned ACLs S3 objects can have
+var cannedACLs = []wfv1.S3CannedACL{
+	wfv1.S3CannedACLPrivate,
+	wfv1.S3CannedACLPublicRead,
+	wfv1.S3CannedACLPublicReadWrite,
+	wfv1.S3CannedACLAuthenticatedRead,
+	wfv1.S3CannedACLAWSExecRead,
+	wfv1.S3CannedACLBucketOwnerRead,
+	wfv1.S3CannedACLBucketOwnerFullControl,
+}
+
+// validateACL checks that the ACL of objects is either one of the canned ACLs or grants, as S3 rejects puts which
+// set both
+func validateACL(errPrefix string, acl *wfv1.S3ObjectACL) error {
+	if acl == nil {
+		return nil
+	}
+	hasGrants := false
+	for _, grant := range []struct {
+		name     string
+		grantees []string
+	}{
+		{"grantRead", acl.GrantRead},
+		{"grantReadACP", acl.GrantReadACP},
+		{"grantWriteACP", acl.GrantWriteACP},
+		{"grantFullControl", acl.GrantFullControl},
+	} {
+		for _, grantee := range grant.grantees {
+			if strings.TrimSpace(grantee) == "" {
+				return errors.Errorf(errors.CodeBadRequest, "%s.%s must not have an empty grantee", errPrefix, grant.name)
+			}
+			hasGrants = true
+		}
+	}
+	if acl.Canned == "" {
+		if !hasGrants {
+			return errors.Errorf(errors.CodeBadRequest, "%s must set canned or at least one grant", errPrefix)
+		}
+		return nil
+	}
+	if hasGrants {
+		return errors.Errorf(errors.CodeBadRequest, "%s.canned cannot be used with grants", errPrefix)
+	}
+	for _, canned := range cannedACLs {
+		if acl.Canned == canned {
+			return nil
+		}
+	}
+	names := make([]string, len(cannedACLs))
+	for i, canned := range cannedACLs {
+		names[i] = string(canned)
+	}
+	return errors.Errorf(errors.CodeBadRequest, "%s.canned %q must be one of: %s", errPrefix, acl.Canned, strings.Join(names, ", "))
+}
+
+const (
+	// the limits of the duration of the session of an assumed role, and of a chained role
+	minRoleSessionDuration        = 15 * time.Minute
+	maxRoleSessionDuration        = 12 * time.Hour
+	maxChainedRoleSessionDuration = time.Hour
+)
+
+// roleSessionNameRegex matches the names STS accepts for the sessions of assumed roles
+var roleSessionNameRegex = regexp.MustCompile(`^[\w+=,.@-]{2,64}$`)
+
+// ParseRoleSessionDuration parses the duration of the sessions of assumed roles, which is zero, for the STS
+// default, if not set
+func ParseRoleSessionDuration(duration string) (time.Duration, error) {
+	if duration == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return 0, err
+	}
+	if d < minRoleSessionDuration || d > maxRoleSessionDuration {
+		return 0, fmt.Errorf("must be between %v and %v", minRoleSessionDuration, maxRoleSessionDuration)
+	}
+	return d, nil
+}
+
+const (
+	// the limits of the size of the parts of multipart uploads S3 accepts
+	minPartSize = 5 * 1024 * 1024
+	maxPartSize = 5 * 1024 * 1024 * 1024
+)
+
+// ParsePartSize returns the size of the parts of multipart uploads in bytes, or 0, for the minio-go default, if it
+// is not set
+func ParsePartSize(size string) (int64, error) {
+	if size == "" {
+		return 0, nil
+	}
+	q, err := resource.ParseQuantity(size)
+	if err != nil {
+		return 0, fmt.Errorf("must be a quantity of bytes, e.g. 64Mi: %w", err)
+	}
+	if q.Value() < minPartSize || q.Value() > maxPartSize {
+		return 0, fmt.Errorf("must be between 5Mi and 5Gi")
+	}
+	return q.Value(), nil
+}
+
+// validateAssumeRole validates the options of assuming the role of a bucket
+func validateAssumeRole(errPrefix string, bucket *wfv1.S3Bucket) error {
+	if bucket.RoleARN == "" {
+		if bucket.RoleExternalID != "" || len(bucket.RoleChain) > 0 {
+			return errors.Errorf(errors.CodeBadRequest, "%s.roleExternalID and %s.roleChain require %s.roleARN", errPrefix, errPrefix, errPrefix)
+		}
+		return nil
+	}
+	for i, roleARN := range bucket.RoleChain {
+		if roleARN == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.roleChain[%d] must not be empty", errPrefix, i)
+		}
+	}
+	if bucket.RoleSessionName != "" && !roleSessionNameRegex.MatchString(bucket.RoleSessionName) {
+		return errors.Errorf(errors.CodeBadRequest, "%s.roleSessionName %q must be 2 to 64 letters, numbers or + = , . @ _ -", errPrefix, bucket.RoleSessionName)
+	}
+	duration, err := ParseRoleSessionDuration(bucket.RoleSessionDuration)
+	if err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s.roleSessionDuration %q is invalid: %v", errPrefix, bucket.RoleSessionDuration, err)
+	}
+	if len(bucket.RoleChain) > 0 && duration > maxChainedRoleSessionDuration {
+		return errors.Errorf(errors.CodeBadRequest, "%s.roleSessionDuration must be at most %v when roles are chained", errPrefix, maxChainedRoleSessionDuration)
+	}
+	return nil
+}
+
+// validateAnonymous validates that an anonymous bucket has no credentials, and is not requester pays, which S3 only
+// allows of signed requests
+func validateAnonymous(errPrefix string, bucket *wfv1.S3Bucket) error {
+	if !bucket.Anonymous {
+		return nil
+	}
+	switch {
+	case bucket.AccessKeySecret != nil && bucket.AccessKeySecret.Name != "", bucket.SecretKeySecret != nil && bucket.SecretKeySecret.Name != "":
+		return errors.Errorf(errors.CodeBadRequest, "%s.anonymous cannot be used with %s.accessKeySecret or %s.secretKeySecret", errPrefix, errPrefix, errPrefix)
+	case bucket.RoleARN != "", bucket.UseSDKCreds, len(bucket.CredentialProviders) > 0:
+		return errors.Errorf(errors.CodeBadRequest, "%s.anonymous cannot be used with %s.roleARN, %s.useSDKCreds or %s.credentialProviders", errPrefix, errPrefix, errPrefix, errPrefix)
+	case bucket.RequesterPays:
+		return errors.Errorf(errors.CodeBadRequest, "%s.anonymous cannot be used with %s.requesterPays, as requester pays buckets bill the requester", errPrefix, errPrefix)
+	}
+	return nil
+}
+
+// validateCredentialProviders validates that each credential provider is known and listed once, and that the providers
+// are not combined with UseSDKCreds. Static may be listed without secrets, which may be those of the artifact repository.
+func validateCredentialProviders(errPrefix string, bucket *wfv1.S3Bucket) error {
+	if len(bucket.CredentialProviders) == 0 {
+		return nil
+	}
+	if bucket.UseSDKCreds {
+		return errors.Errorf(errors.CodeBadRequest, "%s.credentialProviders cannot be combined with useSDKCreds", errPrefix)
+	}
+	listed := map[wfv1.S3CredentialProvider]bool{}
+	for i, provider := range bucket.CredentialProviders {
+		switch provider {
+		case wfv1.S3CredentialProviderStatic, wfv1.S3CredentialProviderWebIdentity, wfv1.S3CredentialProviderInstanceProfile, wfv1.S3CredentialProviderEnv:
+		default:
+			return errors.Errorf(errors.CodeBadRequest, "%s.credentialProviders[%d] must be one of %s, %s, %s or %s", errPrefix, i,
+				wfv1.S3CredentialProviderStatic, wfv1.S3CredentialProviderWebIdentity, wfv1.S3CredentialProviderInstanceProfile, wfv1.S3CredentialProviderEnv)
+		}
+		if listed[provider] {
+			return errors.Errorf(errors.CodeBadRequest, "%s.credentialProviders[%d] %s is listed more than once", errPrefix, i, provider)
+		}
+		listed[provider] = true
+	}
+	return nil
+}
+
+// validateAccelerateEndpoint checks that a bucket which uses the accelerate endpoint is one of AWS, whose name has
+// no dots, addressed in the hostname of requests, as the accelerate endpoint cannot be used otherwise
+func validateAccelerateEndpoint(errPrefix string, bucket *wfv1.S3Bucket) error {
+	if !bucket.UseAccelerateEndpoint {
+		return nil
+	}
+	if err := checkAccelerateEndpoint(bucket.Endpoint, bucket.PathStyle); err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s.useAccelerateEndpoint %v", errPrefix, err)
+	}
+	if strings.Contains(bucket.Bucket, ".") {
+		return errors.Errorf(errors.CodeBadRequest, "%s.useAccelerateEndpoint cannot be used with the bucket %s, as the names of accelerated buckets cannot contain dots", errPrefix, bucket.Bucket)
+	}
+	return nil
+}
+
+// checkAccelerateEndpoint returns why the accelerate endpoint cannot be used with the endpoint and addressing of a
+// bucket, if it cannot
+func checkAccelerateEndpoint(endpoint string, pathStyle *bool) error {
+	if endpoint != "" && !s3utils.IsAmazonEndpoint(url.URL{Host: endpoint}) {
+		return fmt.Errorf("cannot be used with the custom endpoint %s, only with that of AWS", endpoint)
+	}
+	if pathStyle != nil && *pathStyle {
+		return fmt.Errorf("cannot be used with path-style addressing, as the accelerate endpoint only addresses buckets in the hostname")
+	}
+	return nil
+}
+
+const (
+	// the limits of the tags of an S3 object
+	maxTags           = 10
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+)
+
+// validateTags checks the tags against the limits of S3 object tagging. Keys and values may contain letters,
+// numbers, spaces and + - = . _ : / @, and keys must not have the reserved prefix "aws:".
+func validateTags(errPrefix string, tags map[string]string) error {
+	if len(tags) > maxTags {
+		return errors.Errorf(errors.CodeBadRequest, "%s has %d tags, but objects can have at most %d", errPrefix, len(tags), maxTags)
+	}
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := tags[key]
+		switch {
+		case key == "" || utf8.RuneCountInString(key) > maxTagKeyLength:
+			return errors.Errorf(errors.CodeBadRequest, "%s key %q must be 1 to %d characters", errPrefix, key, maxTagKeyLength)
+		case strings.HasPrefix(strings.ToLower(key), "aws:"):
+			return errors.Errorf(errors.CodeBadRequest, "%s key %q must not start with aws:", errPrefix, key)
+		case !isValidTag(key):
+			return errors.Errorf(errors.CodeBadRequest, "%s key %q may only contain letters, numbers, spaces and + - = . _ : / @", errPrefix, key)
+		case utf8.RuneCountInString(value) > maxTagValueLength:
+			return errors.Errorf(errors.CodeBadRequest, "%s.%s value must be at most %d characters", errPrefix, key, maxTagValueLength)
+		case !isValidTag(value):
+			return errors.Errorf(errors.CodeBadRequest, "%s.%s value %q may only contain letters, numbers, spaces and + - = . _ : / @", errPrefix, key, value)
+		}
+	}
+	return nil
+}
+
+func isValidTag(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != ' ' && !strings.ContainsRune("+-=._:/@", r) {
+			return false
+		}
+	}
+	return true
 }
 
 // newMinioClient instantiates a new minio client object.
-func (s3Driver *S3ArtifactDriver) newS3Client(ctx context.Context) (argos3.S3Client, error) {
-	opts := argos3.S3ClientOpts{
-		Endpoint:    s3Driver.Endpoint,
-		Region:      s3Driver.Region,
-		Secure:      s3Driver.Secure,
-		AccessKey:   s3Driver.AccessKey,
-		SecretKey:   s3Driver.SecretKey,
-		RoleARN:     s3Driver.RoleARN,
-		Trace:       os.Getenv(common.EnvVarArgoTrace) == "1",
-		UseSDKCreds: s3Driver.UseSDKCreds,
+func (s3Driver *S3ArtifactDriver) newS3Client(ctx context.Context) (S3Client, error) {
+	return NewS3Client(ctx, s3Driver.clientOpts())
+}
+
+// newVersionS3Client returns a client which gets and stats the version of the object of the artifact, if it has one.
+// Its requests are unsigned if there are no credentials, so that public objects can be loaded.
+func (s3Driver *S3ArtifactDriver) newVersionS3Client(ctx context.Context, art *wfv1.S3Artifact) (S3Client, error) {
+	opts := s3Driver.clientOpts()
+	opts.VersionID = art.VersionID
+	opts.AnonymousFallback = true
+	return NewS3Client(ctx, opts)
+}
+
+// checkSigned returns an error if the driver is anonymous, as objects cannot be written with unsigned requests
+func (s3Driver *S3ArtifactDriver) checkSigned(operation string) error {
+	if s3Driver.Anonymous {
+		return errors.Errorf(errors.CodeBadRequest, "S3 %s cannot be anonymous, only loads of public objects can be", operation)
 	}
-	return argos3.NewS3Client(ctx, opts)
+	return nil
+}
+
+func (s3Driver *S3ArtifactDriver) clientOpts() S3ClientOpts {
+	return S3ClientOpts{
+		Endpoint:              s3Driver.Endpoint,
+		Region:                s3Driver.Region,
+		Secure:                s3Driver.Secure,
+		AccessKey:             s3Driver.AccessKey,
+		SecretKey:             s3Driver.SecretKey,
+		RoleARN:               s3Driver.RoleARN,
+		Trace:                 os.Getenv(common.EnvVarArgoTrace) == "1",
+		UseSDKCreds:           s3Driver.UseSDKCreds,
+		SSEAlgorithm:          s3Driver.SSEAlgorithm,
+		KMSKeyID:              s3Driver.KMSKeyID,
+		PathStyle:             s3Driver.PathStyle,
+		RequesterPays:         s3Driver.RequesterPays,
+		UploadParallelism:     s3Driver.UploadParallelism,
+		RootCAs:               s3Driver.RootCAs,
+		ProxyURL:              s3Driver.ProxyURL,
+		RoleExternalID:        s3Driver.RoleExternalID,
+		RoleChain:             s3Driver.RoleChain,
+		RoleSessionName:       s3Driver.RoleSessionName,
+		RoleSessionDuration:   s3Driver.RoleSessionDuration,
+		Retry:                 s3Driver.Retry,
+		UseAccelerateEndpoint: s3Driver.UseAccelerateEndpoint,
+		PartSize:              uint64(s3Driver.PartSize),
+		PartConcurrency:       uint(s3Driver.PartConcurrency),
+		CredentialProviders:   s3Driver.CredentialProviders,
+		RoundTripper:          s3Driver.RoundTripper,
+		Anonymous:             s3Driver.Anonymous,
+	}
+}
+
+// retried returns whether the client already retried the operation which failed with err, in which case the
+// driver fails rather than retrying it again
+func (s3Driver *S3ArtifactDriver) retried(err error) bool {
+	return s3Driver.Retry.Steps > 0 && isRetryable(err)
+}
+
+// logger returns the logger of the driver with the endpoint, bucket and key of the artifact, but never its credentials
+func (s3Driver *S3ArtifactDriver) logger(art *wfv1.S3Artifact) log.FieldLogger {
+	return s3Driver.Log().WithFields(log.Fields{"endpoint": s3Driver.Endpoint, "bucket": art.Bucket, "key": art.Key})
 }
 
 // Load downloads artifacts from S3 compliant storage
-func (s3Driver *S3ArtifactDriver) Load(inputArtifact *wfv1.Artifact, path string) error {
-	ctx, cancel := context.WithCancel(context.Background())
+func (s3Driver *S3ArtifactDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, path string) error {
+	return s3Driver.LoadWithProgress(ctx, inputArtifact, path, nil)
+}
+
+// LoadWithProgress downloads artifacts from S3 compliant storage. The progress of files is reported, and
+// restarts if the download is retried. It downloads to a temporary path, which only replaces path once it succeeds.
+func (s3Driver *S3ArtifactDriver) LoadWithProgress(ctx context.Context, inputArtifact *wfv1.Artifact, path string, progress artifactscommon.ProgressFunc) error {
+	return artifactscommon.LoadAtomically(path, func(path string) error {
+		return s3Driver.loadWithProgress(ctx, inputArtifact, path, progress)
+	})
+}
+
+func (s3Driver *S3ArtifactDriver) loadWithProgress(ctx context.Context, inputArtifact *wfv1.Artifact, path string, progress artifactscommon.ProgressFunc) error {
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	err := wait.ExponentialBackoff(wait.Backoff{Duration: time.Second * 2, Factor: 2.0, Steps: 5, Jitter: 0.1},
+	maxSize, err := artifactscommon.MaxSize(inputArtifact)
+	if err != nil {
+		return err
+	}
+	logger := s3Driver.logger(inputArtifact.S3)
+	attempt := 0
+	err = wait.ExponentialBackoff(wait.Backoff{Duration: time.Second * 2, Factor: 2.0, Steps: 5, Jitter: 0.1},
 		func() (bool, error) {
-			log.Infof("S3 Load path: %s, key: %s", path, inputArtifact.S3.Key)
-			s3cli, err := s3Driver.newS3Client(ctx)
+			logger.Infof("S3 Load path: %s, key: %s", path, inputArtifact.S3.Key)
+			attempt++
+			logger.WithField("attempt", attempt).Debug("Loading from S3")
+			if err := ctx.Err(); err != nil {
+				return false, err
+			}
+			s3cli, err := s3Driver.newVersionS3Client(ctx, inputArtifact.S3)
 			if err != nil {
-				log.Warnf("Failed to create new S3 client: %v", err)
+				logger.Warnf("Failed to create new S3 client: %v", err)
 				return false, nil
 			}
-			origErr := s3cli.GetFile(inputArtifact.S3.Bucket, inputArtifact.S3.Key, path)
+			art := inputArtifact.S3
+			if inputArtifact.SelectLatest != "" {
+				objects, err := s3cli.ListObjects(art.Bucket, art.Key)
+				if err != nil {
+					logger.Warnf("Failed to list the objects under %s: %v", art.Key, err)
+					if s3Driver.retried(err) {
+						return false, err
+					}
+					return false, nil
+				}
+				key, err := artifactscommon.SelectLatest(inputArtifact.SelectLatest, art.Key, objects)
+				if err != nil {
+					return false, err
+				}
+				logger.Infof("Selected the %s object %s under %s", inputArtifact.SelectLatest, key, art.Key)
+				art = art.DeepCopy()
+				art.Key = key
+			}
+			var origErr error
+			if strings.HasSuffix(art.Key, "/") {
+				// a key ending in a slash is a prefix: the object at it, if any, is the marker of a folder
+				origErr = minio.ErrorResponse{Code: "NoSuchKey", BucketName: art.Bucket, Key: art.Key}
+			} else if err := checkObjectSize(s3cli, art, maxSize); err != nil {
+				if stderrors.Is(err, artifactscommon.ErrArtifactTooLarge) {
+					return false, err
+				}
+				// the object is not loaded, so that the error of getting its size is handled as that of loading it
+				origErr = err
+			} else if progress == nil {
+				origErr = s3cli.GetFile(art.Bucket, art.Key, path)
+			} else {
+				origErr = s3cli.GetFileWithProgress(art.Bucket, art.Key, path, progress)
+			}
 			if origErr == nil {
 				return true, nil
 			}
-			if !argos3.IsS3ErrCode(origErr, "NoSuchKey") {
-				log.Warnf("Failed get file: %v", origErr)
+			if art.VersionID != "" && isVersionError(origErr) {
+				// only a file can be loaded by version, so whether the key is a "directory" is not tested
+				return false, versionError(s3cli, art, origErr)
+			}
+			if !IsS3ErrCode(origErr, "NoSuchKey") {
+				logger.Warnf("Failed get file: %v", origErr)
+				if s3Driver.retried(origErr) {
+					return false, origErr
+				}
 				return false, nil
 			}
 			// If we get here, the error was a NoSuchKey. The key might be a s3 "directory"
-			isDir, err := s3cli.IsDirectory(inputArtifact.S3.Bucket, inputArtifact.S3.Key)
+			isDir, err := s3cli.IsDirectory(art.Bucket, art.Key)
 			if err != nil {
-				log.Warnf("Failed to test if %s is a directory: %v", inputArtifact.S3.Bucket, err)
+				logger.Warnf("Failed to test if %s is a directory: %v", art.Bucket, err)
+				if s3Driver.retried(err) {
+					return false, err
+				}
 				return false, nil
 			}
 			if !isDir {
 				// It's neither a file, nor a directory. Return the original NoSuchKey error
-				return false, errors.New(errors.CodeNotFound, origErr.Error())
+				return false, artifactscommon.NewNotFoundError(origErr)
 			}
-
-			if err = s3cli.GetDirectory(inputArtifact.S3.Bucket, inputArtifact.S3.Key, path); err != nil {
-				log.Warnf("Failed get directory: %v", err)
+			// an object at the key is preferred, so the objects under it are only loaded if there is none
+			logger.Infof("S3 key %s is not an object, loading every object under it", art.Key)
+			if err := checkDirectorySize(s3cli, art, maxSize); err != nil {
+				if stderrors.Is(err, artifactscommon.ErrArtifactTooLarge) || s3Driver.retried(err) {
+					return false, err
+				}
+				logger.Warnf("Failed to get the size of the objects under %s: %v", art.Key, err)
+				return false, nil
+			}
+			if err = s3cli.GetDirectory(art.Bucket, art.Key, path); err != nil {
+				logger.Warnf("Failed get directory: %v", err)
+				if s3Driver.retried(err) || stderrors.Is(err, artifactscommon.ErrSymlinkNotFollowed) {
+					return false, err
+				}
+				return false, nil
+			}
+			manifest, err := getManifest(s3cli, art)
+			if err != nil {
+				logger.Warnf("Failed to get the manifest of the directory: %v", err)
+				if s3Driver.retried(err) {
+					return false, err
+				}
 				return false, nil
 			}
+			if manifest != nil {
+				if err := manifest.Verify(path); err != nil {
+					return false, err
+				}
+			}
 			return true, nil
 		})
 
 	return err
 }
 
+// checkObjectSize returns an error which matches artifactscommon.ErrArtifactTooLarge if the object of the artifact is
+// larger than maxSize, which is not a limit if it is not positive, in which case the object is not stat-ed
+func checkObjectSize(s3cli S3Client, art *wfv1.S3Artifact, maxSize int64) error {
+	if maxSize <= 0 {
+		return nil
+	}
+	info, err := s3cli.StatObject(art.Bucket, art.Key)
+	if err != nil {
+		return err
+	}
+	return artifactscommon.CheckSize(info.Size, maxSize)
+}
+
+// checkDirectorySize returns an error which matches artifactscommon.ErrArtifactTooLarge if the objects under the key
+// of the directory artifact are larger than maxSize in total, which is not a limit if it is not positive
+func checkDirectorySize(s3cli S3Client, art *wfv1.S3Artifact, maxSize int64) error {
+	if maxSize <= 0 {
+		return nil
+	}
+	objects, err := s3cli.ListObjects(art.Bucket, dirKeyPrefix(art.Key))
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, object := range objects {
+		size += object.Size
+	}
+	return artifactscommon.CheckSize(size, maxSize)
+}
+
+// getManifest returns the manifest of the directory artifact, which is nil if it has none
+func getManifest(s3cli S3Client, art *wfv1.S3Artifact) (*artifactscommon.Manifest, error) {
+	r, err := s3cli.OpenFile(art.Bucket, artifactscommon.ManifestKey(art.Key))
+	if IsS3ErrCode(err, "NoSuchKey") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return artifactscommon.ParseManifest(data)
+}
+
+// putManifest puts the manifest of the objects put from the files of the directory next to them
+func putManifest(s3cli S3Client, path string, outputArtifact *wfv1.Artifact, objects []artifactscommon.SavedObject, metadata map[string]string) error {
+	manifest, err := artifactscommon.NewManifest(path, objects)
+	if err != nil {
+		return err
+	}
+	data, err := manifest.Marshal(artifactscommon.ManifestFormat(outputArtifact))
+	if err != nil {
+		return err
+	}
+	tmp, err := artifactscommon.CreateTemp("manifest")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return s3cli.PutFileWithProgress(outputArtifact.S3.Bucket, artifactscommon.ManifestKey(outputArtifact.S3.Key), tmp.Name(), metadata, nil)
+}
+
+// OpenStream opens an artifact from S3 compliant storage for reading. Directories are not supported.
+func (s3Driver *S3ArtifactDriver) OpenStream(inputArtifact *wfv1.Artifact) (io.ReadCloser, error) {
+	s3Driver.Log().Infof("S3 OpenStream key: %s", inputArtifact.S3.Key)
+	s3cli, err := s3Driver.newVersionS3Client(context.Background(), inputArtifact.S3)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := s3cli.OpenFile(inputArtifact.S3.Bucket, inputArtifact.S3.Key)
+	err = versionError(s3cli, inputArtifact.S3, err)
+	if err != nil {
+		if IsS3ErrCode(err, "NoSuchKey") {
+			return nil, artifactscommon.NewNotFoundError(err)
+		}
+		return nil, err
+	}
+	return stream, nil
+}
+
+// LoadRange downloads a range of the bytes of an object from S3 compliant storage, with a Range header
+func (s3Driver *S3ArtifactDriver) LoadRange(artifact *wfv1.Artifact, start, length int64, path string) error {
+	s3Driver.logger(artifact.S3).Infof("S3 LoadRange path: %s, key: %s, range: %s", path, artifact.S3.Key, artifactscommon.ByteRangeSpec(start, length))
+	s3cli, err := s3Driver.newVersionS3Client(context.Background(), artifact.S3)
+	if err != nil {
+		return err
+	}
+	err = versionError(s3cli, artifact.S3, s3cli.GetFileRange(artifact.S3.Bucket, artifact.S3.Key, path, start, length))
+	if IsS3ErrCode(err, "NoSuchKey") {
+		return artifactscommon.NewNotFoundError(err)
+	}
+	return err
+}
+
+// Presign returns a presigned URL for requests of the method of the object of an artifact, or of its version if it
+// has one
+func (s3Driver *S3ArtifactDriver) Presign(artifact *wfv1.Artifact, method string, expiry time.Duration) (string, error) {
+	s3Driver.Log().Infof("S3 Presign key: %s, method: %s, expiry: %v", artifact.S3.Key, method, expiry)
+	if err := artifactscommon.ValidatePresign(artifact.S3.Key, method, expiry); err != nil {
+		return "", err
+	}
+	if method != http.MethodGet && method != http.MethodHead {
+		if err := s3Driver.checkSigned(method + " presign"); err != nil {
+			return "", err
+		}
+	}
+	s3cli, err := s3Driver.newVersionS3Client(context.Background(), artifact.S3)
+	if err != nil {
+		return "", err
+	}
+	return s3cli.PresignedURL(method, artifact.S3.Bucket, artifact.S3.Key, expiry)
+}
+
+// PresignPostPolicy returns a presigned POST policy which allows uploads of objects whose keys start with the key of
+// an artifact, e.g. from a browser. The key field of the form is that of the artifact, followed by ${filename}, which
+// S3 replaces with the name of the uploaded file, if the key is that of a "directory".
+func (s3Driver *S3ArtifactDriver) PresignPostPolicy(artifact *wfv1.Artifact, policy artifactscommon.PostPolicy) (*artifactscommon.PresignedPost, error) {
+	s3Driver.Log().Infof("S3 PresignPostPolicy key: %s, size: %d-%d, expiry: %v", artifact.S3.Key, policy.MinSize, policy.MaxSize, policy.Expiry)
+	if err := artifactscommon.ValidatePostPolicy(artifact.S3.Key, policy); err != nil {
+		return nil, err
+	}
+	if err := s3Driver.checkSigned("POST policy"); err != nil {
+		return nil, err
+	}
+	s3cli, err := s3Driver.newS3Client(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	u, fields, err := s3cli.PresignedPostPolicy(artifact.S3.Bucket, artifact.S3.Key, policy.MinSize, policy.MaxSize, policy.Expiry)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(artifact.S3.Key, "/") {
+		fields["key"] = artifact.S3.Key + "${filename}"
+	}
+	return &artifactscommon.PresignedPost{URL: u, Fields: fields}, nil
+}
+
+// Exists returns whether an artifact, either an object or a "directory" of objects, exists in S3 compliant storage
+func (s3Driver *S3ArtifactDriver) Exists(artifact *wfv1.Artifact) (bool, error) {
+	s3Driver.Log().Infof("S3 Exists key: %s", artifact.S3.Key)
+	s3cli, err := s3Driver.newVersionS3Client(context.Background(), artifact.S3)
+	if err != nil {
+		return false, err
+	}
+	if artifact.S3.VersionID != "" {
+		return versionExists(s3cli, artifact.S3)
+	}
+	return exists(s3cli, artifact.S3.Bucket, artifact.S3.Key)
+}
+
+// versionExists returns whether the version of the object of an artifact exists, with a client of that version. Only
+// a file can have a version, so whether the key is a "directory" is not tested.
+func versionExists(s3cli S3Client, art *wfv1.S3Artifact) (bool, error) {
+	_, err := s3cli.StatObject(art.Bucket, art.Key)
+	if err = versionError(s3cli, art, err); stderrors.Is(err, artifactscommon.ErrArtifactNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// isVersionError returns whether getting a version of an object failed because the version does not exist, or could
+// not be got, as is the case if the bucket is not versioned
+func isVersionError(err error) bool {
+	response := minio.ToErrorResponse(err)
+	return response.Code == "NoSuchKey" || response.Code == "NoSuchVersion" || response.StatusCode == http.StatusBadRequest
+}
+
+// versionError returns the error of getting the version of the object of an artifact, which is clearer if the bucket
+// is not versioned, or the version does not exist. Other errors, and those of artifacts without a version, are
+// returned as they are.
+func versionError(s3cli S3Client, art *wfv1.S3Artifact, err error) error {
+	if err == nil || art.VersionID == "" || !isVersionError(err) {
+		return err
+	}
+	location := fmt.Sprintf("s3://%s/%s", art.Bucket, art.Key)
+	if versioned, versionedErr := s3cli.IsVersioned(art.Bucket); versionedErr == nil && !versioned {
+		return errors.Errorf(errors.CodeBadRequest, "version %s of %s cannot be loaded, as bucket %s is not versioned", art.VersionID, location, art.Bucket)
+	}
+	if minio.ToErrorResponse(err).StatusCode == http.StatusBadRequest {
+		return err
+	}
+	return artifactscommon.NewNotFoundError(fmt.Errorf("version %s of %s does not exist: %w", art.VersionID, location, err))
+}
+
+// listPageSize is the number of keys ListObjectsV2 returns per request
+const listPageSize = 1000
+
+// ExistsBatch returns whether each of the artifacts exists, as Exists does. The keys of artifacts in the same bucket
+// are checked by listing the "directory" they share, which takes fewer requests than checking each key unless it
+// has more objects than a page of keys per artifact. Keys which share no directory, or whose directory has too
+// many objects, are checked one by one, as are the versions of artifacts with one, which a listing does not tell.
+func (s3Driver *S3ArtifactDriver) ExistsBatch(artifacts []*wfv1.Artifact) ([]bool, error) {
+	s3Driver.Log().Infof("S3 Exists of %d artifacts", len(artifacts))
+	s3cli, err := s3Driver.newS3Client(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return existsBatch(s3cli, artifacts, func(art *wfv1.S3Artifact) (S3Client, error) {
+		return s3Driver.newVersionS3Client(context.Background(), art)
+	})
+}
+
+// existsBatch checks the artifacts with s3cli, but for those with a version, which are checked with the client
+// newVersionClient returns for them
+func existsBatch(s3cli S3Client, artifacts []*wfv1.Artifact, newVersionClient func(*wfv1.S3Artifact) (S3Client, error)) ([]bool, error) {
+	result := make([]bool, len(artifacts))
+	var buckets []string
+	indexes := map[string][]int{}
+	for i, art := range artifacts {
+		if art.S3 == nil {
+			return nil, errors.Errorf(errors.CodeBadRequest, "artifact %s is not an S3 artifact", art.Name)
+		}
+		if art.S3.VersionID != "" {
+			versionCli, err := newVersionClient(art.S3)
+			if err != nil {
+				return nil, err
+			}
+			if result[i], err = versionExists(versionCli, art.S3); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if _, ok := indexes[art.S3.Bucket]; !ok {
+			buckets = append(buckets, art.S3.Bucket)
+		}
+		indexes[art.S3.Bucket] = append(indexes[art.S3.Bucket], i)
+	}
+	for _, bucket := range buckets {
+		var keys []string
+		for _, i := range indexes[bucket] {
+			keys = append(keys, artifacts[i].S3.Key)
+		}
+		if len(keys) > 1 {
+			if prefix := sharedDirectory(keys); prefix != "" {
+				listed, truncated, err := s3cli.ListKeys(bucket, prefix, len(keys)*listPageSize)
+				if err != nil {
+					return nil, err
+				}
+				if !truncated {
+					sort.Strings(listed)
+					for j, i := range indexes[bucket] {
+						result[i] = listedExists(listed, keys[j])
+					}
+					continue
+				}
+			}
+		}
+		for j, i := range indexes[bucket] {
+			ok, err := exists(s3cli, bucket, keys[j])
+			if err != nil {
+				return nil, err
+			}
+			result[i] = ok
+		}
+	}
+	return result, nil
+}
+
+// sharedDirectory returns the longest "directory", ending with "/", that every key is under, or "" if they are not
+// all under one
+func sharedDirectory(keys []string) string {
+	prefix := keys[0]
+	for _, key := range keys[1:] {
+		for !strings.HasPrefix(key, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix[:strings.LastIndex(prefix, "/")+1]
+}
+
+// listedExists returns whether the key is that of a listed object, or a "directory" of them, as exists does
+func listedExists(listed []string, key string) bool {
+	i := sort.SearchStrings(listed, key)
+	if i < len(listed) && listed[i] == key {
+		return true
+	}
+	dir := dirKeyPrefix(key)
+	i = sort.SearchStrings(listed, dir)
+	return i < len(listed) && strings.HasPrefix(listed[i], dir)
+}
+
+func exists(s3cli S3Client, bucket, key string) (bool, error) {
+	_, err := s3cli.StatObject(bucket, key)
+	if err == nil {
+		return true, nil
+	}
+	if !IsS3ErrCode(err, "NoSuchKey") {
+		return false, err
+	}
+	return s3cli.IsDirectory(bucket, key)
+}
+
+// VerifyChecksum verifies the checksum of an artifact loaded to path. The ETag of an object is compared
+// with an expected md5 checksum when it is a digest of the object's content, otherwise the checksum is
+// computed over the loaded bytes.
+func (s3Driver *S3ArtifactDriver) VerifyChecksum(inputArtifact *wfv1.Artifact, path string) error {
+	checksum := inputArtifact.Checksum
+	isDir, err := file.IsDirectory(path)
+	if err != nil {
+		return err
+	}
+	if isDir || checksum.Algorithm != wfv1.ChecksumAlgorithmMD5 {
+		return artifactscommon.VerifyChecksum(checksum, path)
+	}
+	s3cli, err := s3Driver.newVersionS3Client(context.Background(), inputArtifact.S3)
+	if err != nil {
+		return err
+	}
+	info, err := s3cli.StatObject(inputArtifact.S3.Bucket, inputArtifact.S3.Key)
+	if err != nil {
+		return versionError(s3cli, inputArtifact.S3, err)
+	}
+	etag, ok := contentMD5(info)
+	// the ETag of a compressed object is the digest of the compressed content
+	if !ok || contentEncoding(info) != "" {
+		return artifactscommon.VerifyChecksum(checksum, path)
+	}
+	if !strings.EqualFold(etag, checksum.Value) {
+		return errors.InternalErrorf("md5 checksum of s3 key %s does not match: expected %s, got %s", inputArtifact.S3.Key, checksum.Value, etag)
+	}
+	return nil
+}
+
+// contentMD5 returns the ETag of an object if it is the md5 digest of its content. That is not the case
+// for objects uploaded in multiple parts, whose ETag has a "-<parts>" suffix, nor for objects encrypted
+// with SSE-KMS or SSE-C.
+func contentMD5(info minio.ObjectInfo) (string, bool) {
+	etag := strings.Trim(info.ETag, "\"")
+	if len(etag) != 32 || strings.Contains(etag, "-") {
+		return "", false
+	}
+	if _, err := hex.DecodeString(etag); err != nil {
+		return "", false
+	}
+	if info.Metadata.Get("X-Amz-Server-Side-Encryption") == SSEAlgorithmKMS || info.Metadata.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") != "" {
+		return "", false
+	}
+	return etag, true
+}
+
 // Save saves an artifact to S3 compliant storage
-func (s3Driver *S3ArtifactDriver) Save(path string, outputArtifact *wfv1.Artifact) error {
-	ctx, cancel := context.WithCancel(context.Background())
+func (s3Driver *S3ArtifactDriver) Save(ctx context.Context, path string, outputArtifact *wfv1.Artifact) error {
+	return s3Driver.SaveWithProgress(ctx, path, outputArtifact, nil)
+}
+
+// SaveWithProgress saves an artifact to S3 compliant storage. The progress of files is reported, and
+// restarts if the upload is retried.
+func (s3Driver *S3ArtifactDriver) SaveWithProgress(ctx context.Context, path string, outputArtifact *wfv1.Artifact, progress artifactscommon.ProgressFunc) error {
+	_, err := s3Driver.save(ctx, path, outputArtifact, nil, progress)
+	return err
+}
+
+// SaveWithContentEncoding saves a file to S3 compliant storage, recording its encoding in the user metadata of the object
+func (s3Driver *S3ArtifactDriver) SaveWithContentEncoding(ctx context.Context, path string, outputArtifact *wfv1.Artifact, encoding string, progress artifactscommon.ProgressFunc) error {
+	_, err := s3Driver.SaveWithResult(ctx, path, outputArtifact, encoding, progress)
+	return err
+}
+
+// SaveWithResult saves an artifact to S3 compliant storage, returning the ETag, version ID and size each object was
+// put with, as S3 reported them. The version ID is empty unless the bucket is versioned.
+func (s3Driver *S3ArtifactDriver) SaveWithResult(ctx context.Context, path string, outputArtifact *wfv1.Artifact, encoding string, progress artifactscommon.ProgressFunc) (*artifactscommon.SaveResult, error) {
+	var metadata map[string]string
+	if encoding != "" {
+		metadata = map[string]string{artifactscommon.ContentEncodingMetadataKey: encoding}
+	}
+	return s3Driver.save(ctx, path, outputArtifact, metadata, progress)
+}
+
+// ProtectsOverwrite returns true: unless the overwrite policy of an artifact is allow, its objects are put with
+// If-None-Match, and a directory is not saved if any object exists under its key
+func (s3Driver *S3ArtifactDriver) ProtectsOverwrite() bool {
+	return true
+}
+
+// SkipsUnchanged returns true: a file is not put if the sha256 recorded in the user metadata of its object, or
+// otherwise its ETag if that is its md5, is that of the file, nor a directory if its manifest is that of its files
+func (s3Driver *S3ArtifactDriver) SkipsUnchanged() bool {
+	return true
+}
+
+// SavesAtomically returns true: a directory is put under a staging key, and its objects copied to its key
+func (s3Driver *S3ArtifactDriver) SavesAtomically() bool {
+	return true
+}
+
+// unchanged returns whether the destination of an artifact has the content of the file or directory at path
+func unchanged(s3cli S3Client, path string, isDir bool, outputArtifact *wfv1.Artifact) (bool, error) {
+	if isDir {
+		manifest, err := getManifest(s3cli, outputArtifact.S3)
+		if err != nil {
+			return false, err
+		}
+		return artifactscommon.DirectoryUnchanged(path, artifactscommon.NewFileFilter(outputArtifact), manifest)
+	}
+	info, err := s3cli.StatObject(outputArtifact.S3.Bucket, outputArtifact.S3.Key)
+	if IsS3ErrCode(err, "NoSuchKey") {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	etag, _ := contentMD5(info)
+	return artifactscommon.FileUnchanged(path, info.Metadata.Get("X-Amz-Meta-"+artifactscommon.ContentSHA256MetadataKey), etag)
+}
+
+// savedIdempotently returns whether a previous attempt of the save of the artifact, with the same idempotency key,
+// saved the file or directory at path: the key is recorded in the user metadata of the object of the file, with its
+// sha256, or in that of the manifest of the directory, which is that of its files
+func savedIdempotently(s3cli S3Client, path string, isDir bool, outputArtifact *wfv1.Artifact, idempotencyKey string) (bool, error) {
+	key := outputArtifact.S3.Key
+	if isDir {
+		key = artifactscommon.ManifestKey(key)
+	}
+	info, err := s3cli.StatObject(outputArtifact.S3.Bucket, key)
+	if IsS3ErrCode(err, "NoSuchKey") {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if info.Metadata.Get("X-Amz-Meta-"+artifactscommon.IdempotencyKeyMetadataKey) != idempotencyKey {
+		return false, nil
+	}
+	return unchanged(s3cli, path, isDir, outputArtifact)
+}
+
+// SavesIdempotently returns true: an artifact is not saved if the idempotency key recorded in the user metadata of its
+// object, or of the manifest of a directory, is its own, and it has the content of the file or directory
+func (s3Driver *S3ArtifactDriver) SavesIdempotently() bool {
+	return true
+}
+
+// LimitsSize returns true: the size of the object of an artifact, or the total size of the objects under the key of a
+// directory, is checked against its max size before it is loaded
+func (s3Driver *S3ArtifactDriver) LimitsSize() bool {
+	return true
+}
+
+// ContentEncoding returns the encoding recorded in the user metadata of the object of an artifact
+func (s3Driver *S3ArtifactDriver) ContentEncoding(artifact *wfv1.Artifact) (string, error) {
+	s3cli, err := s3Driver.newVersionS3Client(context.Background(), artifact.S3)
+	if err != nil {
+		return "", err
+	}
+	info, err := s3cli.StatObject(artifact.S3.Bucket, artifact.S3.Key)
+	err = versionError(s3cli, artifact.S3, err)
+	if IsS3ErrCode(err, "NoSuchKey") {
+		return "", artifactscommon.NewNotFoundError(err)
+	}
+	if err != nil {
+		return "", err
+	}
+	return contentEncoding(info), nil
+}
+
+func contentEncoding(info minio.ObjectInfo) string {
+	return info.Metadata.Get("X-Amz-Meta-" + artifactscommon.ContentEncodingMetadataKey)
+}
+
+// Metadata returns the user metadata of the object of an artifact, which a directory does not have
+func (s3Driver *S3ArtifactDriver) Metadata(artifact *wfv1.Artifact) (map[string]string, error) {
+	s3cli, err := s3Driver.newVersionS3Client(context.Background(), artifact.S3)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s3cli.StatObject(artifact.S3.Bucket, artifact.S3.Key)
+	err = versionError(s3cli, artifact.S3, err)
+	if IsS3ErrCode(err, "NoSuchKey") {
+		return nil, artifactscommon.NewNotFoundError(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	metadata := map[string]string{}
+	for k, v := range info.Metadata {
+		if strings.HasPrefix(k, "X-Amz-Meta-") && len(v) > 0 {
+			metadata[strings.TrimPrefix(k, "X-Amz-Meta-")] = v[0]
+		}
+	}
+	return artifactscommon.UserMetadata(metadata), nil
+}
+
+// save saves an artifact, with the user metadata if it is a file, returning the objects it put
+func (s3Driver *S3ArtifactDriver) save(ctx context.Context, path string, outputArtifact *wfv1.Artifact, metadata map[string]string, progress artifactscommon.ProgressFunc) (*artifactscommon.SaveResult, error) {
+	if err := s3Driver.checkSigned("Save"); err != nil {
+		return nil, err
+	}
+	// the ACL and object lock of the artifact repository are not validated with the template
+	if err := ValidateAtomicSave(outputArtifact.Name, outputArtifact); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	logger := s3Driver.logger(outputArtifact.S3)
+	location := fmt.Sprintf("s3://%s/%s", outputArtifact.S3.Bucket, outputArtifact.S3.Key)
+	skipUnchanged := artifactscommon.SkipsUnchanged(outputArtifact.Overwrite)
+	idempotencyKey := artifactscommon.IdempotencyKey(outputArtifact)
+	attempt := 0
+	var objects []artifactscommon.SavedObject
+	isUnchanged := false
 	err := wait.ExponentialBackoff(wait.Backoff{Duration: time.Second * 2, Factor: 2.0, Steps: 5, Jitter: 0.1},
 		func() (bool, error) {
-			log.Infof("S3 Save path: %s, key: %s", path, outputArtifact.S3.Key)
-			s3cli, err := s3Driver.newS3Client(ctx)
+			logger.Infof("S3 Save path: %s, key: %s", path, outputArtifact.S3.Key)
+			attempt++
+			logger.WithField("attempt", attempt).Debug("Saving to S3")
+			if err := ctx.Err(); err != nil {
+				return false, err
+			}
+			opts := s3Driver.clientOpts()
+			opts.ObjectTags = outputArtifact.S3.Tags
+			opts.ObjectMetadata = outputArtifact.Metadata
+			opts.ACL = outputArtifact.S3.ACL
+			opts.ObjectLock = outputArtifact.S3.ObjectLock
+			opts.IfNoneMatch = artifactscommon.IsOverwriteProtected(outputArtifact.Overwrite)
+			opts.ContentType = outputArtifact.ContentType
+			s3cli, err := NewS3Client(ctx, opts)
 			if err != nil {
-				log.Warnf("Failed to create new S3 client: %v", err)
+				logger.Warnf("Failed to create new S3 client: %v", err)
 				return false, nil
 			}
 			isDir, err := file.IsDirectory(path)
 			if err != nil {
-				log.Warnf("Failed to test if %s is a directory: %v", path, err)
+				logger.Warnf("Failed to test if %s is a directory: %v", path, err)
 				return false, nil
 			}
 
 			createBucketIfNotPresent := outputArtifact.S3.CreateBucketIfNotPresent
 			if createBucketIfNotPresent != nil {
-				log.Infof("Trying to create bucket: %s", outputArtifact.S3.Bucket)
+				logger.Infof("Trying to create bucket: %s", outputArtifact.S3.Bucket)
 				err := s3cli.MakeBucket(outputArtifact.S3.Bucket, minio.MakeBucketOptions{
 					Region:        outputArtifact.S3.Region,
 					ObjectLocking: outputArtifact.S3.CreateBucketIfNotPresent.ObjectLocking,
 				})
 				if err != nil {
-					log.Warnf("Failed to create bucket: %v. Error: %v", outputArtifact.S3.Bucket, err)
+					logger.Warnf("Failed to create bucket: %v. Error: %v", outputArtifact.S3.Bucket, err)
+				}
+			}
+
+			if outputArtifact.S3.ObjectLock != nil {
+				if err := checkObjectLock(s3cli, outputArtifact.S3.Bucket); err != nil {
+					logger.Warnf("Failed to check the object lock of the bucket: %v", err)
+					if s3Driver.retried(err) || errors.IsCode(errors.CodeBadRequest, err) {
+						return false, err
+					}
+					return false, nil
+				}
+			}
+
+			if skipUnchanged {
+				isUnchanged, err = unchanged(s3cli, path, isDir, outputArtifact)
+				if err != nil {
+					logger.Warnf("Failed to compare the artifact with its destination: %v", err)
+					if s3Driver.retried(err) {
+						return false, err
+					}
+					return false, nil
+				}
+				if isUnchanged {
+					return true, nil
+				}
+			}
+
+			if idempotencyKey != "" {
+				isUnchanged, err = savedIdempotently(s3cli, path, isDir, outputArtifact, idempotencyKey)
+				if err != nil {
+					logger.Warnf("Failed to check whether a previous attempt saved the artifact: %v", err)
+					if s3Driver.retried(err) {
+						return false, err
+					}
+					return false, nil
+				}
+				if isUnchanged {
+					logger.Infof("A previous attempt with the same idempotency key already saved %s", location)
+					return true, nil
+				}
+			}
+
+			if isDir && opts.IfNoneMatch {
+				// the directory exists if any object does under its key, not only those of its files
+				exists, err := s3cli.IsDirectory(outputArtifact.S3.Bucket, outputArtifact.S3.Key)
+				if err != nil {
+					logger.Warnf("Failed to test if the directory exists: %v", err)
+					if s3Driver.retried(err) {
+						return false, err
+					}
+					return false, nil
+				}
+				if exists {
+					return false, artifactscommon.NewExistsError(location)
 				}
 			}
 
 			if isDir {
-				if err = s3cli.PutDirectory(outputArtifact.S3.Bucket, outputArtifact.S3.Key, path); err != nil {
-					log.Warnf("Failed to put directory: %v", err)
+				filter := artifactscommon.NewFileFilter(outputArtifact)
+				if outputArtifact.AtomicSave {
+					objects, err = putDirectoryAtomically(logger, s3cli, outputArtifact.S3.Bucket, outputArtifact.S3.Key, path, filter)
+				} else {
+					objects, err = s3cli.PutDirectoryWithResult(outputArtifact.S3.Bucket, outputArtifact.S3.Key, path, filter)
+				}
+				if err != nil {
+					logger.Warnf("Failed to put directory: %v", err)
+					if s3Driver.retried(err) || stderrors.Is(err, artifactscommon.ErrArtifactExists) || stderrors.Is(err, artifactscommon.ErrSymlinkNotFollowed) {
+						return false, err
+					}
 					return false, nil
 				}
+				if artifactscommon.ManifestFormat(outputArtifact) != "" {
+					// the manifest is put once every file is, so the idempotency key it records marks the save complete
+					var manifestMetadata map[string]string
+					if idempotencyKey != "" {
+						manifestMetadata = artifactscommon.WithIdempotencyKey(nil, idempotencyKey)
+					}
+					if err := putManifest(s3cli, path, outputArtifact, objects, manifestMetadata); err != nil {
+						logger.Warnf("Failed to put the manifest of the directory: %v", err)
+						if s3Driver.retried(err) || stderrors.Is(err, artifactscommon.ErrArtifactExists) {
+							return false, err
+						}
+						return false, nil
+					}
+				}
 			} else {
-				if err = s3cli.PutFile(outputArtifact.S3.Bucket, outputArtifact.S3.Key, path); err != nil {
-					log.Warnf("Failed to put file: %v", err)
+				fileMetadata := metadata
+				if skipUnchanged || idempotencyKey != "" {
+					if fileMetadata, err = artifactscommon.WithContentSHA256(metadata, path); err != nil {
+						return false, err
+					}
+				}
+				if idempotencyKey != "" {
+					fileMetadata = artifactscommon.WithIdempotencyKey(fileMetadata, idempotencyKey)
+				}
+				object, err := s3cli.PutFileWithResult(outputArtifact.S3.Bucket, outputArtifact.S3.Key, path, fileMetadata, progress)
+				if err != nil {
+					logger.Warnf("Failed to put file: %v", err)
+					if s3Driver.retried(err) || stderrors.Is(err, artifactscommon.ErrArtifactExists) {
+						return false, err
+					}
 					return false, nil
 				}
+				objects = []artifactscommon.SavedObject{object}
+			}
+			return true, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	if isUnchanged {
+		return artifactscommon.NewUnchangedResult(location), nil
+	}
+	return artifactscommon.NewSaveResult(location, objects), nil
+}
+
+// checkObjectLock returns an error if the bucket does not have object lock enabled, as S3 rejects puts of objects with
+// object lock to it
+func checkObjectLock(s3cli S3Client, bucket string) error {
+	enabled, err := s3cli.IsObjectLockEnabled(bucket)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return errors.Errorf(errors.CodeBadRequest, "bucket %s does not have object lock enabled, which objectLock requires", bucket)
+	}
+	return nil
+}
+
+// putDirectoryAtomically puts the files of the directory at path which match filter under a staging key, and only
+// once every file is put copies their objects to key, so that no object is put under key if any file fails. The
+// staging objects are deleted whether or not it succeeds, as are the copies if any copy fails.
+func putDirectoryAtomically(logger log.FieldLogger, s3cli S3Client, bucket, key, path string, filter artifactscommon.FileFilter) ([]artifactscommon.SavedObject, error) {
+	staging, err := artifactscommon.StagingKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := s3cli.DeleteDirectory(bucket, staging); err != nil {
+			logger.Warnf("Failed to delete the staging objects under %s: %v", staging, err)
+		}
+	}()
+	staged, err := s3cli.PutDirectoryWithResult(bucket, staging, path, filter)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]artifactscommon.SavedObject, 0, len(staged))
+	for _, object := range staged {
+		copied, err := s3cli.CopyObject(bucket, object.Key, bucket, artifactscommon.StagedKey(key, staging, object.Key))
+		if err != nil {
+			for _, object := range objects {
+				if err := s3cli.Delete(bucket, object.Key); err != nil {
+					logger.Warnf("Failed to delete %s, which a failed save was copied to: %v", object.Key, err)
+				}
+			}
+			return nil, err
+		}
+		copied.Path = object.Path
+		copied.LinkTarget = object.LinkTarget
+		copied.Size = object.Size
+		objects = append(objects, copied)
+	}
+	return objects, nil
+}
+
+// DryRunSave checks that the bucket of an artifact exists, or would be created, and returns the objects saving path
+// would put. S3 cannot check permission to put an object without putting one, so it is not checked.
+func (s3Driver *S3ArtifactDriver) DryRunSave(ctx context.Context, path string, outputArtifact *wfv1.Artifact) (*artifactscommon.DryRunResult, error) {
+	s3Driver.Log().Infof("S3 dry run of Save path: %s, key: %s", path, outputArtifact.S3.Key)
+	if err := s3Driver.checkSigned("Save"); err != nil {
+		return nil, err
+	}
+	s3cli, err := s3Driver.newS3Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return dryRunSave(s3cli, path, outputArtifact)
+}
+
+func dryRunSave(s3cli S3Client, path string, outputArtifact *wfv1.Artifact) (*artifactscommon.DryRunResult, error) {
+	s3Artifact := outputArtifact.S3
+	location := fmt.Sprintf("s3://%s/%s", s3Artifact.Bucket, s3Artifact.Key)
+	result, err := artifactscommon.NewDryRunResult(location, s3Artifact.Key, path, artifactscommon.NewFileFilter(outputArtifact))
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s3cli.BucketExists(s3Artifact.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if s3Artifact.CreateBucketIfNotPresent == nil {
+			return nil, errors.Errorf(errors.CodeNotFound, "bucket %s does not exist", s3Artifact.Bucket)
+		}
+		result.CreateBucket = true
+	}
+	return result, nil
+}
+
+// HealthCheck checks that the bucket of the driver exists, with a HEAD request of it, which is denied if the credentials
+// are not accepted
+func (s3Driver *S3ArtifactDriver) HealthCheck(ctx context.Context) error {
+	s3Driver.Log().Infof("S3 HealthCheck bucket: %s", s3Driver.Bucket)
+	s3cli, err := s3Driver.newS3Client(ctx)
+	if err != nil {
+		return err
+	}
+	return healthCheck(s3cli, s3Driver.Bucket)
+}
+
+func healthCheck(s3cli S3Client, bucket string) error {
+	ok, err := s3cli.BucketExists(bucket)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.Errorf(errors.CodeNotFound, "bucket %s does not exist", bucket)
+	}
+	return nil
+}
+
+// CopiesFrom returns whether an artifact can be copied to another with CopyObject, which it can if their buckets are
+// configured the same but for their names, so that both are reachable with the credentials of the driver, and the
+// destination sets no tags, ACL or object lock, which copies would not have. A version of an object is not copied.
+func (s3Driver *S3ArtifactDriver) CopiesFrom(src, dst *wfv1.Artifact) bool {
+	if src.S3 == nil || dst.S3 == nil || src.S3.VersionID != "" {
+		return false
+	}
+	if len(dst.S3.Tags) > 0 || dst.S3.ACL != nil || dst.S3.ObjectLock != nil {
+		return false
+	}
+	srcBucket, dstBucket := src.S3.S3Bucket, dst.S3.S3Bucket
+	srcBucket.Bucket, dstBucket.Bucket = "", ""
+	return reflect.DeepEqual(srcBucket, dstBucket)
+}
+
+// Copy copies an artifact to another with CopyObject, without downloading it. If the key of the source is a
+// "directory", every object under it is copied under the key of the destination.
+func (s3Driver *S3ArtifactDriver) Copy(ctx context.Context, src, dst *wfv1.Artifact) error {
+	s3Driver.logger(src.S3).Infof("S3 Copy to bucket: %s, key: %s", dst.S3.Bucket, dst.S3.Key)
+	if err := s3Driver.checkSigned("Copy"); err != nil {
+		return err
+	}
+	s3cli, err := s3Driver.newS3Client(ctx)
+	if err != nil {
+		return err
+	}
+	return copyObjects(s3cli, src.S3, dst.S3)
+}
+
+// copyObjects copies the object at the key of src to that of dst, or if there is none, the objects under it, as
+// loading src would prefer the object
+func copyObjects(s3cli S3Client, src, dst *wfv1.S3Artifact) error {
+	var origErr error
+	if strings.HasSuffix(src.Key, "/") {
+		// a key ending in a slash is a prefix: the object at it, if any, is the marker of a folder
+		origErr = minio.ErrorResponse{Code: "NoSuchKey", BucketName: src.Bucket, Key: src.Key}
+	} else {
+		_, origErr = s3cli.CopyObject(src.Bucket, src.Key, dst.Bucket, dst.Key)
+	}
+	if origErr == nil || !IsS3ErrCode(origErr, "NoSuchKey") {
+		return origErr
+	}
+	isDir, err := s3cli.IsDirectory(src.Bucket, src.Key)
+	if err != nil {
+		return err
+	}
+	if !isDir {
+		return artifactscommon.NewNotFoundError(origErr)
+	}
+	prefix := dirKeyPrefix(src.Key)
+	objects, err := s3cli.ListObjects(src.Bucket, prefix)
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		if _, err := s3cli.CopyObject(src.Bucket, object.Key, dst.Bucket, dirKeyPrefix(dst.Key)+strings.TrimPrefix(object.Key, prefix)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete deletes an artifact from S3 compliant storage. If the key is a "directory", every object under it is deleted
+func (s3Driver *S3ArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	if err := s3Driver.checkSigned("Delete"); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger := s3Driver.logger(artifact.S3)
+	attempt := 0
+	var lastErr error
+	err := wait.ExponentialBackoff(wait.Backoff{Duration: time.Second * 2, Factor: 2.0, Steps: 5, Jitter: 0.1},
+		func() (bool, error) {
+			logger.Infof("S3 Delete key: %s", artifact.S3.Key)
+			attempt++
+			logger.WithField("attempt", attempt).Debug("Deleting from S3")
+			s3cli, err := s3Driver.newS3Client(ctx)
+			if err != nil {
+				logger.Warnf("Failed to create new S3 client: %v", err)
+				lastErr = err
+				return false, nil
+			}
+			isDir, err := s3cli.IsDirectory(artifact.S3.Bucket, artifact.S3.Key)
+			if err != nil {
+				logger.Warnf("Failed to test if %s is a directory: %v", artifact.S3.Key, err)
+				lastErr = err
+				return false, nil
+			}
+			if isDir {
+				err = s3cli.DeleteDirectory(artifact.S3.Bucket, artifact.S3.Key)
+				if err == nil {
+					// the manifest of the directory, if it has one, is deleted with it
+					err = s3cli.Delete(artifact.S3.Bucket, artifactscommon.ManifestKey(artifact.S3.Key))
+				}
+			} else {
+				err = s3cli.Delete(artifact.S3.Bucket, artifact.S3.Key)
+			}
+			if err != nil {
+				logger.Warnf("Failed to delete %s: %v", artifact.S3.Key, err)
+				lastErr = err
+				return false, nil
 			}
 			return true, nil
 		})
+	if err == wait.ErrWaitTimeout && lastErr != nil {
+		return lastErr
+	}
 	return err
 }
+
+// Capabilities returns the optional operations on S3 artifacts the driver supports
+func (s3Driver *S3ArtifactDriver) Capabilities() artifactscommon.DriverCapabilities {
+	return artifactscommon.DriverCapabilities{
+		Delete:              true,
+		Stream:              true,
+		LoadRange:           true,
+		Exists:              true,
+		ExistsBatch:         true,
+		VerifyChecksum:      true,
+		HealthCheck:         true,
+		Progress:            true,
+		ContentEncoding:     true,
+		OverwriteProtection: true,
+		SkipUnchanged:       true,
+		AtomicSave:          true,
+		IdempotentSave:      true,
+		MaxSize:             true,
+		Metadata:            true,
+		DryRunSave:          true,
+		SaveResult:          true,
+		Copy:                true,
+		Presign:             true,
+		PresignPostPolicy:   true,
+	}
+}
@@ -0,0 +1,1879 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	artifactscommon "github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+func TestContentMD5(t *testing.T) {
+	t.Run("SinglePart", func(t *testing.T) {
+		etag, ok := contentMD5(minio.ObjectInfo{ETag: "5d41402abc4b2a76b9719d911017c592"})
+		assert.True(t, ok)
+		assert.Equal(t, "5d41402abc4b2a76b9719d911017c592", etag)
+	})
+	t.Run("Quoted", func(t *testing.T) {
+		etag, ok := contentMD5(minio.ObjectInfo{ETag: `"5d41402abc4b2a76b9719d911017c592"`})
+		assert.True(t, ok)
+		assert.Equal(t, "5d41402abc4b2a76b9719d911017c592", etag)
+	})
+	t.Run("AES256", func(t *testing.T) {
+		_, ok := contentMD5(minio.ObjectInfo{ETag: "5d41402abc4b2a76b9719d911017c592", Metadata: http.Header{"X-Amz-Server-Side-Encryption": {SSEAlgorithmAES256}}})
+		assert.True(t, ok)
+	})
+	t.Run("MultiPart", func(t *testing.T) {
+		_, ok := contentMD5(minio.ObjectInfo{ETag: "d41d8cd98f00b204e9800998ecf8427e-2"})
+		assert.False(t, ok)
+	})
+	t.Run("KMS", func(t *testing.T) {
+		_, ok := contentMD5(minio.ObjectInfo{ETag: "5d41402abc4b2a76b9719d911017c592", Metadata: http.Header{"X-Amz-Server-Side-Encryption": {SSEAlgorithmKMS}}})
+		assert.False(t, ok)
+	})
+	t.Run("CustomerKey", func(t *testing.T) {
+		_, ok := contentMD5(minio.ObjectInfo{ETag: "5d41402abc4b2a76b9719d911017c592", Metadata: http.Header{"X-Amz-Server-Side-Encryption-Customer-Algorithm": {"AES256"}}})
+		assert.False(t, ok)
+	})
+}
+
+// fakeS3Client serves objects, "directories" and buckets from memory, and records the keys objects are put at
+type fakeS3Client struct {
+	S3Client
+	objects map[string]bool
+	dirs    map[string]bool
+	buckets map[string]bool
+	puts    []string
+	err     error
+}
+
+func (c *fakeS3Client) BucketExists(bucket string) (bool, error) {
+	return c.buckets[bucket], c.err
+}
+
+func (c *fakeS3Client) MakeBucket(bucket string, _ minio.MakeBucketOptions) error {
+	c.puts = append(c.puts, bucket)
+	return nil
+}
+
+func (c *fakeS3Client) PutFile(_, key, _ string) error {
+	c.puts = append(c.puts, key)
+	return nil
+}
+
+func (c *fakeS3Client) PutFileWithProgress(_, key, _ string, _ map[string]string, _ artifactscommon.ProgressFunc) error {
+	c.puts = append(c.puts, key)
+	return nil
+}
+
+func (c *fakeS3Client) PutDirectory(_, key, _ string, _ artifactscommon.FileFilter) error {
+	c.puts = append(c.puts, key)
+	return nil
+}
+
+func (c *fakeS3Client) StatObject(_, key string) (minio.ObjectInfo, error) {
+	if c.err != nil {
+		return minio.ObjectInfo{}, c.err
+	}
+	if !c.objects[key] {
+		return minio.ObjectInfo{}, minio.ErrorResponse{Code: "NoSuchKey", Key: key}
+	}
+	return minio.ObjectInfo{Key: key}, nil
+}
+
+func (c *fakeS3Client) IsDirectory(_, key string) (bool, error) {
+	return c.dirs[key], nil
+}
+
+func TestExists(t *testing.T) {
+	s3cli := &fakeS3Client{objects: map[string]bool{"my-file": true}, dirs: map[string]bool{"my-dir": true}}
+	t.Run("Object", func(t *testing.T) {
+		ok, err := exists(s3cli, "my-bucket", "my-file")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+	t.Run("Directory", func(t *testing.T) {
+		ok, err := exists(s3cli, "my-bucket", "my-dir")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+	t.Run("Absent", func(t *testing.T) {
+		ok, err := exists(s3cli, "my-bucket", "not-found")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+	t.Run("Error", func(t *testing.T) {
+		_, err := exists(&fakeS3Client{err: minio.ErrorResponse{Code: "AccessDenied"}}, "my-bucket", "my-file")
+		assert.True(t, IsS3ErrCode(err, "AccessDenied"))
+		_, err = exists(&fakeS3Client{err: fmt.Errorf("connection refused")}, "my-bucket", "my-file")
+		assert.EqualError(t, err, "connection refused")
+	})
+}
+
+// keysS3Client serves a bucket of objects with the keys, and counts the requests made for them
+type keysS3Client struct {
+	S3Client
+	keys     []string
+	requests int
+}
+
+func (c *keysS3Client) StatObject(_, key string) (minio.ObjectInfo, error) {
+	c.requests++
+	for _, k := range c.keys {
+		if k == key {
+			return minio.ObjectInfo{Key: key}, nil
+		}
+	}
+	return minio.ObjectInfo{}, minio.ErrorResponse{Code: "NoSuchKey", Key: key}
+}
+
+func (c *keysS3Client) IsDirectory(_, key string) (bool, error) {
+	c.requests++
+	for _, k := range c.keys {
+		if strings.HasPrefix(k, dirKeyPrefix(key)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *keysS3Client) ListKeys(_, prefix string, maxKeys int) ([]string, bool, error) {
+	var keys []string
+	for _, k := range c.keys {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) > maxKeys {
+		c.requests += maxKeys/listPageSize + 1
+		return keys[:maxKeys], true, nil
+	}
+	c.requests += len(keys)/listPageSize + 1
+	return keys, false, nil
+}
+
+// versionedKeysS3Client serves the keys of a version of the objects of a versioned bucket
+type versionedKeysS3Client struct {
+	keysS3Client
+}
+
+func (c *versionedKeysS3Client) IsVersioned(string) (bool, error) {
+	return true, nil
+}
+
+func TestExistsBatch(t *testing.T) {
+	newArtifacts := func(keys ...string) []*wfv1.Artifact {
+		var artifacts []*wfv1.Artifact
+		for _, key := range keys {
+			artifacts = append(artifacts, &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: key}}})
+		}
+		return artifacts
+	}
+	// existsEach checks each artifact with exists, returning the results and the number of requests
+	existsEach := func(keys []string, artifacts []*wfv1.Artifact) ([]bool, int) {
+		s3cli := &keysS3Client{keys: keys}
+		var result []bool
+		for _, art := range artifacts {
+			ok, err := exists(s3cli, art.S3.Bucket, art.S3.Key)
+			assert.NoError(t, err)
+			result = append(result, ok)
+		}
+		return result, s3cli.requests
+	}
+	keys := []string{"out/a.txt", "out/b/1", "out/b/2", "out/dir/", "out/c.txt.gz", "logs/main.log"}
+
+	t.Run("SharedDirectory", func(t *testing.T) {
+		artifacts := newArtifacts("out/a.txt", "out/b", "out/b/1", "out/c.txt", "out/dir", "out/missing/")
+		s3cli := &keysS3Client{keys: keys}
+		result, err := existsBatch(s3cli, artifacts, nil)
+		if assert.NoError(t, err) {
+			expected, requests := existsEach(keys, artifacts)
+			assert.Equal(t, expected, result)
+			assert.Equal(t, []bool{true, true, true, false, true, false}, result)
+			assert.Equal(t, 1, s3cli.requests)
+			assert.Less(t, s3cli.requests, requests)
+		}
+	})
+	t.Run("NoSharedDirectory", func(t *testing.T) {
+		artifacts := newArtifacts("out/a.txt", "logs/main.log", "tmp")
+		s3cli := &keysS3Client{keys: keys}
+		result, err := existsBatch(s3cli, artifacts, nil)
+		if assert.NoError(t, err) {
+			expected, requests := existsEach(keys, artifacts)
+			assert.Equal(t, expected, result)
+			assert.Equal(t, requests, s3cli.requests)
+		}
+	})
+	t.Run("LargeDirectory", func(t *testing.T) {
+		keys := []string{"big/a", "big/b"}
+		for i := 0; i < 2*listPageSize; i++ {
+			keys = append(keys, fmt.Sprintf("big/part-%05d", i))
+		}
+		artifacts := newArtifacts("big/a", "big/c")
+		s3cli := &keysS3Client{keys: keys}
+		result, err := existsBatch(s3cli, artifacts, nil)
+		if assert.NoError(t, err) {
+			expected, _ := existsEach(keys, artifacts)
+			assert.Equal(t, expected, result)
+			assert.Equal(t, []bool{true, false}, result)
+		}
+	})
+	t.Run("Versions", func(t *testing.T) {
+		versions := map[string][]string{"v1": {"out/a.txt"}, "v2": {"out/b/1"}}
+		artifacts := newArtifacts("out/a.txt", "out/a.txt", "out/a.txt", "out/b/1")
+		artifacts[1].S3.VersionID = "v1"
+		artifacts[2].S3.VersionID = "v2"
+		s3cli := &keysS3Client{keys: keys}
+		var versioned []string
+		result, err := existsBatch(s3cli, artifacts, func(art *wfv1.S3Artifact) (S3Client, error) {
+			versioned = append(versioned, art.VersionID)
+			return &versionedKeysS3Client{keysS3Client{keys: versions[art.VersionID]}}, nil
+		})
+		if assert.NoError(t, err) {
+			// the key is listed, but its version v2 does not exist
+			assert.Equal(t, []bool{true, true, false, true}, result)
+			assert.Equal(t, []string{"v1", "v2"}, versioned)
+		}
+	})
+	t.Run("NotS3", func(t *testing.T) {
+		_, err := existsBatch(&keysS3Client{}, []*wfv1.Artifact{{Name: "my-art"}}, nil)
+		assert.EqualError(t, err, "artifact my-art is not an S3 artifact")
+	})
+}
+
+func TestS3ArtifactDriver_LoadRange(t *testing.T) {
+	data := []byte("0123456789")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/my-bucket/my-key" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", `"781e5e245d69b566979b86e28d23f2c7"`)
+		http.ServeContent(w, r, "my-key", time.Unix(1600000000, 0), bytes.NewReader(data))
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "range")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	driver := &S3ArtifactDriver{Endpoint: strings.TrimPrefix(server.URL, "http://"), Region: "us-east-1", AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	newArtifact := func(key string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: key}}}
+	}
+	fullPath := filepath.Join(dir, "full")
+	if !assert.NoError(t, driver.Load(context.Background(), newArtifact("my-key"), fullPath)) {
+		return
+	}
+	full, err := ioutil.ReadFile(fullPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, r := range []struct{ start, length, end int64 }{{0, 4, 4}, {3, 5, 8}, {6, -1, 10}, {8, 100, 10}} {
+		path := filepath.Join(dir, "range")
+		if assert.NoError(t, driver.LoadRange(newArtifact("my-key"), r.start, r.length, path)) {
+			got, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, string(full[r.start:r.end]), string(got), "%v", r)
+		}
+	}
+	err = driver.LoadRange(newArtifact("not-found"), 0, 4, filepath.Join(dir, "not-found"))
+	assert.True(t, errors.Is(err, artifactscommon.ErrArtifactNotFound))
+}
+
+func TestValidateArtifact_Tags(t *testing.T) {
+	validate := func(tags map[string]string) error {
+		return ValidateArtifact("outputs.artifacts.my-art.s3", &wfv1.S3Artifact{Tags: tags})
+	}
+	assert.NoError(t, validate(nil))
+	assert.NoError(t, validate(map[string]string{"CostCenter": "1234", "team": "data eng", "path": "a/b:c@d.e+f-g=h_i", "empty": ""}))
+	tooMany := map[string]string{}
+	for i := 0; i < 11; i++ {
+		tooMany[fmt.Sprintf("key-%d", i)] = "value"
+	}
+	assert.EqualError(t, validate(tooMany), "outputs.artifacts.my-art.s3.tags has 11 tags, but objects can have at most 10")
+	assert.EqualError(t, validate(map[string]string{"": "value"}), `outputs.artifacts.my-art.s3.tags key "" must be 1 to 128 characters`)
+	assert.EqualError(t, validate(map[string]string{strings.Repeat("k", 129): "value"}), fmt.Sprintf(`outputs.artifacts.my-art.s3.tags key %q must be 1 to 128 characters`, strings.Repeat("k", 129)))
+	assert.EqualError(t, validate(map[string]string{"aws:team": "value"}), `outputs.artifacts.my-art.s3.tags key "aws:team" must not start with aws:`)
+	assert.EqualError(t, validate(map[string]string{"team!": "value"}), `outputs.artifacts.my-art.s3.tags key "team!" may only contain letters, numbers, spaces and + - = . _ : / @`)
+	assert.EqualError(t, validate(map[string]string{"team": strings.Repeat("v", 257)}), "outputs.artifacts.my-art.s3.tags.team value must be at most 256 characters")
+	assert.EqualError(t, validate(map[string]string{"team": "a&b"}), `outputs.artifacts.my-art.s3.tags.team value "a&b" may only contain letters, numbers, spaces and + - = . _ : / @`)
+}
+
+func TestValidateArtifact_ACL(t *testing.T) {
+	validate := func(acl *wfv1.S3ObjectACL) error {
+		return ValidateArtifact("outputs.artifacts.my-art.s3", &wfv1.S3Artifact{ACL: acl})
+	}
+	assert.NoError(t, validate(nil))
+	assert.NoError(t, validate(&wfv1.S3ObjectACL{Canned: wfv1.S3CannedACLPublicRead}))
+	assert.NoError(t, validate(&wfv1.S3ObjectACL{GrantRead: []string{`id="my-canonical-user-id"`}, GrantWriteACP: []string{`emailAddress="me@example.com"`}}))
+	assert.EqualError(t, validate(&wfv1.S3ObjectACL{Canned: "public"}), `outputs.artifacts.my-art.s3.acl.canned "public" must be one of: private, public-read, public-read-write, authenticated-read, aws-exec-read, bucket-owner-read, bucket-owner-full-control`)
+	assert.EqualError(t, validate(&wfv1.S3ObjectACL{Canned: wfv1.S3CannedACLPrivate, GrantRead: []string{`id="my-canonical-user-id"`}}), "outputs.artifacts.my-art.s3.acl.canned cannot be used with grants")
+	assert.EqualError(t, validate(&wfv1.S3ObjectACL{GrantReadACP: []string{" "}}), "outputs.artifacts.my-art.s3.acl.grantReadACP must not have an empty grantee")
+	assert.EqualError(t, validate(&wfv1.S3ObjectACL{}), "outputs.artifacts.my-art.s3.acl must set canned or at least one grant")
+}
+
+func TestValidateArtifact_ObjectLock(t *testing.T) {
+	validate := func(lock *wfv1.S3ObjectLock) error {
+		return ValidateArtifact("outputs.artifacts.my-art.s3", &wfv1.S3Artifact{ObjectLock: lock})
+	}
+	retainUntilDate := &metav1.Time{Time: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+	assert.NoError(t, validate(nil))
+	assert.NoError(t, validate(&wfv1.S3ObjectLock{Mode: wfv1.S3RetentionModeGovernance, RetainUntilDate: retainUntilDate}))
+	assert.NoError(t, validate(&wfv1.S3ObjectLock{Mode: wfv1.S3RetentionModeCompliance, RetainUntilDate: retainUntilDate, LegalHold: true}))
+	assert.NoError(t, validate(&wfv1.S3ObjectLock{LegalHold: true}))
+	assert.EqualError(t, validate(&wfv1.S3ObjectLock{Mode: "governance", RetainUntilDate: retainUntilDate}), `outputs.artifacts.my-art.s3.objectLock.mode "governance" must be one of: GOVERNANCE, COMPLIANCE`)
+	assert.EqualError(t, validate(&wfv1.S3ObjectLock{Mode: wfv1.S3RetentionModeGovernance}), "outputs.artifacts.my-art.s3.objectLock.mode and outputs.artifacts.my-art.s3.objectLock.retainUntilDate must be set together")
+	assert.EqualError(t, validate(&wfv1.S3ObjectLock{RetainUntilDate: retainUntilDate, LegalHold: true}), "outputs.artifacts.my-art.s3.objectLock.mode and outputs.artifacts.my-art.s3.objectLock.retainUntilDate must be set together")
+	assert.EqualError(t, validate(&wfv1.S3ObjectLock{}), "outputs.artifacts.my-art.s3.objectLock must set mode and retainUntilDate, or legalHold")
+}
+
+func TestValidateAtomicSave(t *testing.T) {
+	retainUntilDate := &metav1.Time{Time: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+	newArtifact := func(atomicSave bool, acl *wfv1.S3ObjectACL, lock *wfv1.S3ObjectLock) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{ACL: acl, ObjectLock: lock}}, AtomicSave: atomicSave}
+	}
+	acl := &wfv1.S3ObjectACL{Canned: wfv1.S3CannedACLPublicRead}
+	lock := &wfv1.S3ObjectLock{Mode: wfv1.S3RetentionModeGovernance, RetainUntilDate: retainUntilDate}
+	assert.NoError(t, ValidateAtomicSave("outputs.artifacts.my-art", newArtifact(true, nil, nil)))
+	assert.NoError(t, ValidateAtomicSave("outputs.artifacts.my-art", newArtifact(false, acl, lock)))
+	assert.NoError(t, ValidateAtomicSave("outputs.artifacts.my-art", &wfv1.Artifact{AtomicSave: true}))
+	assert.EqualError(t, ValidateAtomicSave("outputs.artifacts.my-art", newArtifact(true, acl, nil)), "outputs.artifacts.my-art.s3.acl cannot be used with atomicSave")
+	assert.EqualError(t, ValidateAtomicSave("outputs.artifacts.my-art", newArtifact(true, nil, lock)), "outputs.artifacts.my-art.s3.objectLock cannot be used with atomicSave")
+	assert.EqualError(t, ValidateAtomicSave("outputs.artifacts.my-art", newArtifact(true, nil, &wfv1.S3ObjectLock{LegalHold: true})), "outputs.artifacts.my-art.s3.objectLock cannot be used with atomicSave")
+
+	t.Run("Save", func(t *testing.T) {
+		// the ACL and object lock of an artifact repository are only known when the artifact is saved
+		driver := &S3ArtifactDriver{Endpoint: "unreachable.invalid", AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+		art := newArtifact(true, acl, nil)
+		art.Name = "my-art"
+		art.S3.Bucket = "my-bucket"
+		art.S3.Key = "my-dir"
+		assert.EqualError(t, driver.Save(context.Background(), "/work/my-dir", art), "my-art.s3.acl cannot be used with atomicSave")
+		art.S3.ACL, art.S3.ObjectLock = nil, lock
+		assert.EqualError(t, driver.Save(context.Background(), "/work/my-dir", art), "my-art.s3.objectLock cannot be used with atomicSave")
+	})
+}
+
+// objectLockS3Client serves buckets which have object lock enabled, or not
+type objectLockS3Client struct {
+	S3Client
+	enabled bool
+	err     error
+}
+
+func (c *objectLockS3Client) IsObjectLockEnabled(string) (bool, error) {
+	return c.enabled, c.err
+}
+
+func TestCheckObjectLock(t *testing.T) {
+	assert.NoError(t, checkObjectLock(&objectLockS3Client{enabled: true}, "my-bucket"))
+	assert.EqualError(t, checkObjectLock(&objectLockS3Client{}, "my-bucket"), "bucket my-bucket does not have object lock enabled, which objectLock requires")
+	err := checkObjectLock(&objectLockS3Client{err: minio.ErrorResponse{Code: "AccessDenied"}}, "my-bucket")
+	assert.True(t, IsS3ErrCode(err, "AccessDenied"))
+}
+
+func TestDryRunSave(t *testing.T) {
+	dir, keys := newTestDirectory(t, 3)
+	defer func() { _ = os.RemoveAll(dir) }()
+	newArtifact := func(bucket string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: bucket}, Key: "my-key"}}}
+	}
+	t.Run("File", func(t *testing.T) {
+		s3cli := &fakeS3Client{buckets: map[string]bool{"my-bucket": true}}
+		path := filepath.Join(dir, "dir-0", "file-0")
+		result, err := dryRunSave(s3cli, path, newArtifact("my-bucket"))
+		if assert.NoError(t, err) {
+			assert.Equal(t, "s3://my-bucket/my-key", result.Location)
+			assert.Equal(t, []artifactscommon.DryRunObject{{Key: "my-key", Path: path, Size: int64(len("dir-0/file-0"))}}, result.Objects)
+			assert.False(t, result.CreateBucket)
+			assert.False(t, result.WritePermissionChecked)
+		}
+		assert.Empty(t, s3cli.puts)
+	})
+	t.Run("Directory", func(t *testing.T) {
+		s3cli := &fakeS3Client{buckets: map[string]bool{"my-bucket": true}}
+		result, err := dryRunSave(s3cli, dir, newArtifact("my-bucket"))
+		if assert.NoError(t, err) {
+			var objectKeys []string
+			for _, o := range result.Objects {
+				objectKeys = append(objectKeys, o.Key)
+			}
+			assert.ElementsMatch(t, keys, objectKeys)
+		}
+		assert.Empty(t, s3cli.puts)
+	})
+	t.Run("NoSuchBucket", func(t *testing.T) {
+		s3cli := &fakeS3Client{}
+		_, err := dryRunSave(s3cli, dir, newArtifact("my-bucket"))
+		assert.EqualError(t, err, "bucket my-bucket does not exist")
+		art := newArtifact("my-bucket")
+		art.S3.CreateBucketIfNotPresent = &wfv1.CreateS3BucketOptions{}
+		result, err := dryRunSave(s3cli, dir, art)
+		if assert.NoError(t, err) {
+			assert.True(t, result.CreateBucket)
+		}
+		assert.Empty(t, s3cli.puts)
+	})
+	t.Run("Error", func(t *testing.T) {
+		_, err := dryRunSave(&fakeS3Client{err: minio.ErrorResponse{Code: "AccessDenied"}}, dir, newArtifact("my-bucket"))
+		assert.True(t, IsS3ErrCode(err, "AccessDenied"))
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := dryRunSave(&fakeS3Client{buckets: map[string]bool{"my-bucket": true}}, filepath.Join(dir, "missing"), newArtifact("my-bucket"))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+func TestHealthCheck(t *testing.T) {
+	t.Run("Healthy", func(t *testing.T) {
+		s3cli := &fakeS3Client{buckets: map[string]bool{"my-bucket": true}}
+		assert.NoError(t, healthCheck(s3cli, "my-bucket"))
+		assert.Empty(t, s3cli.puts)
+	})
+	t.Run("NoSuchBucket", func(t *testing.T) {
+		assert.EqualError(t, healthCheck(&fakeS3Client{}, "my-bucket"), "bucket my-bucket does not exist")
+	})
+	t.Run("Unauthorized", func(t *testing.T) {
+		err := healthCheck(&fakeS3Client{err: minio.ErrorResponse{Code: "AccessDenied"}}, "my-bucket")
+		assert.True(t, IsS3ErrCode(err, "AccessDenied"))
+	})
+}
+
+func TestValidateArtifact_AccelerateEndpoint(t *testing.T) {
+	validate := func(bucket wfv1.S3Bucket) error {
+		bucket.UseAccelerateEndpoint = true
+		return ValidateArtifact("outputs.artifacts.my-art.s3", &wfv1.S3Artifact{S3Bucket: bucket})
+	}
+	pathStyle, virtualHostedStyle := true, false
+	assert.NoError(t, validate(wfv1.S3Bucket{Endpoint: "s3.amazonaws.com", Bucket: "my-bucket"}))
+	assert.NoError(t, validate(wfv1.S3Bucket{Endpoint: "s3.us-west-2.amazonaws.com", Bucket: "my-bucket", PathStyle: &virtualHostedStyle}))
+	// the endpoint and bucket may be those of the artifact repository
+	assert.NoError(t, validate(wfv1.S3Bucket{}))
+	assert.EqualError(t, validate(wfv1.S3Bucket{Endpoint: "minio.example.com:9000", Bucket: "my-bucket"}),
+		"outputs.artifacts.my-art.s3.useAccelerateEndpoint cannot be used with the custom endpoint minio.example.com:9000, only with that of AWS")
+	assert.EqualError(t, validate(wfv1.S3Bucket{Endpoint: "s3.amazonaws.com", Bucket: "my-bucket", PathStyle: &pathStyle}),
+		"outputs.artifacts.my-art.s3.useAccelerateEndpoint cannot be used with path-style addressing, as the accelerate endpoint only addresses buckets in the hostname")
+	assert.EqualError(t, validate(wfv1.S3Bucket{Endpoint: "s3.amazonaws.com", Bucket: "my.bucket"}),
+		"outputs.artifacts.my-art.s3.useAccelerateEndpoint cannot be used with the bucket my.bucket, as the names of accelerated buckets cannot contain dots")
+	assert.NoError(t, ValidateArtifact("outputs.artifacts.my-art.s3", &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Endpoint: "minio.example.com:9000", PathStyle: &pathStyle}}))
+}
+
+func TestValidateArtifact_CredentialProviders(t *testing.T) {
+	validate := func(bucket wfv1.S3Bucket) error {
+		return ValidateArtifact("outputs.artifacts.my-art.s3", &wfv1.S3Artifact{S3Bucket: bucket})
+	}
+	assert.NoError(t, validate(wfv1.S3Bucket{}))
+	assert.NoError(t, validate(wfv1.S3Bucket{CredentialProviders: []wfv1.S3CredentialProvider{wfv1.S3CredentialProviderStatic, wfv1.S3CredentialProviderWebIdentity, wfv1.S3CredentialProviderInstanceProfile, wfv1.S3CredentialProviderEnv}}))
+	assert.EqualError(t, validate(wfv1.S3Bucket{CredentialProviders: []wfv1.S3CredentialProvider{wfv1.S3CredentialProviderEnv}, UseSDKCreds: true}),
+		"outputs.artifacts.my-art.s3.credentialProviders cannot be combined with useSDKCreds")
+	assert.EqualError(t, validate(wfv1.S3Bucket{CredentialProviders: []wfv1.S3CredentialProvider{wfv1.S3CredentialProviderEnv, "irsa"}}),
+		"outputs.artifacts.my-art.s3.credentialProviders[1] must be one of static, webIdentity, instanceProfile or env")
+	assert.EqualError(t, validate(wfv1.S3Bucket{CredentialProviders: []wfv1.S3CredentialProvider{wfv1.S3CredentialProviderEnv, wfv1.S3CredentialProviderEnv}}),
+		"outputs.artifacts.my-art.s3.credentialProviders[1] env is listed more than once")
+}
+
+func TestValidateArtifact_Multipart(t *testing.T) {
+	validate := func(partSize string, partConcurrency int32) error {
+		return ValidateArtifact("outputs.artifacts.my-art.s3", &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{PartSize: partSize, PartConcurrency: &partConcurrency}})
+	}
+	for _, size := range []string{"", "5Mi", "64Mi", "5Gi"} {
+		assert.NoError(t, validate(size, 1), size)
+	}
+	assert.EqualError(t, validate("4Mi", 1), "outputs.artifacts.my-art.s3.partSize must be between 5Mi and 5Gi")
+	assert.EqualError(t, validate("6Gi", 1), "outputs.artifacts.my-art.s3.partSize must be between 5Mi and 5Gi")
+	assert.Error(t, validate("big", 1))
+	assert.EqualError(t, validate("", 0), "outputs.artifacts.my-art.s3.partConcurrency must be at least 1")
+}
+
+func TestParsePartSize(t *testing.T) {
+	size, err := ParsePartSize("")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+	size, err = ParsePartSize("64Mi")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(64*1024*1024), size)
+}
+
+func TestValidateArtifact_AssumeRole(t *testing.T) {
+	validate := func(bucket wfv1.S3Bucket) error {
+		return ValidateArtifact("outputs.artifacts.my-art.s3", &wfv1.S3Artifact{S3Bucket: bucket})
+	}
+	assert.NoError(t, validate(wfv1.S3Bucket{RoleARN: "my-role", RoleExternalID: "my-external-id", RoleSessionName: "my-session", RoleSessionDuration: "12h"}))
+	assert.NoError(t, validate(wfv1.S3Bucket{RoleARN: "my-role", RoleChain: []string{"intermediate"}, RoleSessionDuration: "1h"}))
+	assert.EqualError(t, validate(wfv1.S3Bucket{RoleExternalID: "my-external-id"}), "outputs.artifacts.my-art.s3.roleExternalID and outputs.artifacts.my-art.s3.roleChain require outputs.artifacts.my-art.s3.roleARN")
+	assert.EqualError(t, validate(wfv1.S3Bucket{RoleARN: "my-role", RoleChain: []string{""}}), "outputs.artifacts.my-art.s3.roleChain[0] must not be empty")
+	assert.EqualError(t, validate(wfv1.S3Bucket{RoleARN: "my-role", RoleSessionName: "my session"}), `outputs.artifacts.my-art.s3.roleSessionName "my session" must be 2 to 64 letters, numbers or + = , . @ _ -`)
+	assert.EqualError(t, validate(wfv1.S3Bucket{RoleARN: "my-role", RoleSessionDuration: "1m"}), `outputs.artifacts.my-art.s3.roleSessionDuration "1m" is invalid: must be between 15m0s and 12h0m0s`)
+	assert.EqualError(t, validate(wfv1.S3Bucket{RoleARN: "my-role", RoleChain: []string{"intermediate"}, RoleSessionDuration: "2h"}), "outputs.artifacts.my-art.s3.roleSessionDuration must be at most 1h0m0s when roles are chained")
+}
+
+// newOverwriteServer returns a fake S3 server which stores the objects put to the bucket "my-bucket", checking their
+// If-None-Match, and lists and serves them
+func newOverwriteServer(objects map[string]string) *httptest.Server {
+	return httptest.NewServer(newOverwriteHandler(objects))
+}
+
+// newOverwriteHandler returns the handler of newOverwriteServer, which serves the user metadata objects were put with
+func newOverwriteHandler(objects map[string]string) http.Handler {
+	var lock sync.Mutex
+	metadata := map[string]http.Header{}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		key := strings.TrimPrefix(r.URL.Path, "/my-bucket/")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+			prefix := r.URL.Query().Get("prefix")
+			_, _ = fmt.Fprintf(w, `<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Name>my-bucket</Name><Prefix>%s</Prefix><MaxKeys>1000</MaxKeys><IsTruncated>false</IsTruncated>`, prefix)
+			for k := range objects {
+				if strings.HasPrefix(k, prefix) {
+					_, _ = fmt.Fprintf(w, `<Contents><Key>%s</Key><Size>%d</Size></Contents>`, k, len(objects[k]))
+				}
+			}
+			_, _ = fmt.Fprint(w, `</ListBucketResult>`)
+		case r.Method == http.MethodPut:
+			if _, ok := objects[key]; ok && r.Header.Get("If-None-Match") == "*" {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				_, _ = fmt.Fprint(w, `<Error><Code>PreconditionFailed</Code><Message>At least one of the pre-conditions you specified did not hold</Message></Error>`)
+				return
+			}
+			data, _ := ioutil.ReadAll(r.Body)
+			objects[key] = string(data)
+			metadata[key] = http.Header{}
+			for k, v := range r.Header {
+				if strings.HasPrefix(k, "X-Amz-Meta-") {
+					metadata[key][k] = v
+				}
+			}
+			w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+		case r.Method == http.MethodGet || r.Method == http.MethodHead:
+			data, ok := objects[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+				return
+			}
+			for k, v := range metadata[key] {
+				w.Header()[k] = v
+			}
+			w.Header().Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum([]byte(data))))
+			http.ServeContent(w, r, "", time.Unix(1600000000, 0), strings.NewReader(data))
+		default:
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	})
+}
+
+func TestS3ArtifactDriver_Overwrite(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "overwrite")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	path := filepath.Join(tmp, "my-file")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("new"), 0600))
+	dir := filepath.Join(tmp, "my-dir")
+	assert.NoError(t, os.MkdirAll(dir, 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("new"), 0600))
+	newArtifact := func(key string, overwrite wfv1.ArtifactOverwrite) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: key}}, Overwrite: overwrite}
+	}
+	for _, overwrite := range []wfv1.ArtifactOverwrite{wfv1.ArtifactOverwriteAllow, wfv1.ArtifactOverwriteFailIfExists, wfv1.ArtifactOverwriteSkipIfExists} {
+		t.Run(string(overwrite), func(t *testing.T) {
+			objects := map[string]string{"my-key": "old", "my-dir/b": "old"}
+			server := newOverwriteServer(objects)
+			defer server.Close()
+			driver := &S3ArtifactDriver{Endpoint: strings.TrimPrefix(server.URL, "http://"), Region: "us-east-1", AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+			// a destination which does not exist is saved whatever the policy
+			assert.NoError(t, driver.Save(context.Background(), path, newArtifact("my-new-key", overwrite)))
+			assert.Equal(t, "new", objects["my-new-key"])
+			assert.NoError(t, driver.Save(context.Background(), dir, newArtifact("my-new-dir", overwrite)))
+			assert.Equal(t, "new", objects["my-new-dir/a"])
+
+			err := driver.Save(context.Background(), path, newArtifact("my-key", overwrite))
+			dirErr := driver.Save(context.Background(), dir, newArtifact("my-dir", overwrite))
+			if overwrite == wfv1.ArtifactOverwriteAllow {
+				assert.NoError(t, err)
+				assert.Equal(t, "new", objects["my-key"])
+				assert.NoError(t, dirErr)
+				assert.Equal(t, "new", objects["my-dir/a"])
+				return
+			}
+			// the driver fails whether the policy is fail-if-exists or skip-if-exists, which the caller skips
+			assert.True(t, errors.Is(err, artifactscommon.ErrArtifactExists))
+			assert.Equal(t, "old", objects["my-key"])
+			// a directory exists if any object does under it
+			assert.True(t, errors.Is(dirErr, artifactscommon.ErrArtifactExists))
+			assert.NotContains(t, objects, "my-dir/a")
+		})
+	}
+}
+
+func TestS3ArtifactDriver_Manifest(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "manifest")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	dir := filepath.Join(tmp, "my-dir")
+	files := map[string]string{"a": "a", "sub/b": "bb"}
+	for name, data := range files {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(data), 0600))
+	}
+	objects := map[string]string{}
+	server := newOverwriteServer(objects)
+	defer server.Close()
+	driver := &S3ArtifactDriver{Endpoint: strings.TrimPrefix(server.URL, "http://"), Region: "us-east-1", AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	newArtifact := func(key string, format wfv1.ArtifactManifestFormat) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: key}}, Manifest: format}
+	}
+	sha256Hex := func(data string) string { return fmt.Sprintf("%x", sha256.Sum256([]byte(data))) }
+
+	t.Run("JSON", func(t *testing.T) {
+		if !assert.NoError(t, driver.Save(context.Background(), dir, newArtifact("my-dir", wfv1.ArtifactManifestFormatJSON))) {
+			return
+		}
+		manifest, err := artifactscommon.ParseManifest([]byte(objects["my-dir.manifest"]))
+		if assert.NoError(t, err) {
+			assert.Equal(t, []artifactscommon.ManifestFile{
+				{Path: "a", Size: 1, SHA256: sha256Hex("a")},
+				{Path: "sub/b", Size: 2, SHA256: sha256Hex("bb")},
+			}, manifest.Files)
+		}
+	})
+	t.Run("Text", func(t *testing.T) {
+		if assert.NoError(t, driver.Save(context.Background(), dir, newArtifact("my-text-dir", wfv1.ArtifactManifestFormatText))) {
+			assert.Equal(t, sha256Hex("a")+" 1 a\n"+sha256Hex("bb")+" 2 sub/b\n", objects["my-text-dir.manifest"])
+		}
+	})
+	t.Run("None", func(t *testing.T) {
+		if assert.NoError(t, driver.Save(context.Background(), dir, newArtifact("my-other-dir", ""))) {
+			assert.NotContains(t, objects, "my-other-dir.manifest")
+		}
+	})
+	t.Run("Verified", func(t *testing.T) {
+		path := filepath.Join(tmp, "verified")
+		if assert.NoError(t, driver.Load(context.Background(), newArtifact("my-dir", ""), path)) {
+			for name, data := range files {
+				got, err := ioutil.ReadFile(filepath.Join(path, filepath.FromSlash(name)))
+				assert.NoError(t, err)
+				assert.Equal(t, data, string(got))
+			}
+			// the manifest is next to the directory, so it is not loaded with it
+			assert.NoFileExists(t, filepath.Join(path, ".manifest"))
+		}
+	})
+	t.Run("Corrupt", func(t *testing.T) {
+		objects["my-dir/sub/b"] = "cc"
+		defer func() { objects["my-dir/sub/b"] = "bb" }()
+		assert.Error(t, driver.Load(context.Background(), newArtifact("my-dir", ""), filepath.Join(tmp, "corrupt")))
+	})
+	t.Run("Missing", func(t *testing.T) {
+		delete(objects, "my-dir/a")
+		assert.Error(t, driver.Load(context.Background(), newArtifact("my-dir", ""), filepath.Join(tmp, "missing")))
+	})
+}
+
+func TestS3ArtifactDriver_LoadPrefix(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "prefix")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	objects := map[string]string{
+		"my-dir/":        "",
+		"my-dir/a":       "a",
+		"my-dir/sub/b":   "bb",
+		"my-dir-other/c": "c",
+		"my-key":         "file",
+		"my-key/x":       "x",
+	}
+	server := newOverwriteServer(objects)
+	defer server.Close()
+	driver := &S3ArtifactDriver{Endpoint: strings.TrimPrefix(server.URL, "http://"), Region: "us-east-1", AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	newArtifact := func(key string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: key}}}
+	}
+	// files returns the files under the directory, by their slash separated paths relative to it
+	files := func(dir string) map[string]string {
+		got := map[string]string{}
+		assert.NoError(t, filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+			data, err := ioutil.ReadFile(p)
+			got[filepath.ToSlash(rel)] = string(data)
+			return err
+		}))
+		return got
+	}
+
+	t.Run("Prefix", func(t *testing.T) {
+		for _, key := range []string{"my-dir", "my-dir/"} {
+			path := filepath.Join(tmp, "prefix", key)
+			if assert.NoError(t, driver.Load(context.Background(), newArtifact(key), path), key) {
+				// the keys are relative to the prefix, and objects which only share its name are not loaded
+				assert.Equal(t, map[string]string{"a": "a", "sub/b": "bb"}, files(path), key)
+			}
+		}
+	})
+	t.Run("ExactObject", func(t *testing.T) {
+		path := filepath.Join(tmp, "exact")
+		if assert.NoError(t, driver.Load(context.Background(), newArtifact("my-dir/a"), path)) {
+			data, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, "a", string(data))
+		}
+	})
+	t.Run("Ambiguous", func(t *testing.T) {
+		// the object at the key is loaded, rather than the objects under it
+		path := filepath.Join(tmp, "ambiguous")
+		if assert.NoError(t, driver.Load(context.Background(), newArtifact("my-key"), path)) {
+			data, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, "file", string(data))
+		}
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		err := driver.Load(context.Background(), newArtifact("my-missing"), filepath.Join(tmp, "missing"))
+		assert.True(t, errors.Is(err, artifactscommon.ErrArtifactNotFound))
+	})
+}
+
+func TestS3ArtifactDriver_Symlinks(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "symlinks")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	dir := filepath.Join(tmp, "my-dir")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "lib"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "lib", "b"), []byte("bb"), 0600))
+	assert.NoError(t, os.Symlink("a", filepath.Join(dir, "link-a")))
+	assert.NoError(t, os.Symlink("lib", filepath.Join(dir, "link-lib")))
+	assert.NoError(t, os.Symlink("missing", filepath.Join(dir, "dangling")))
+	objects := map[string]string{}
+	server := newOverwriteServer(objects)
+	defer server.Close()
+	driver := &S3ArtifactDriver{Endpoint: strings.TrimPrefix(server.URL, "http://"), Region: "us-east-1", AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	newArtifact := func(key string, symlinks wfv1.ArtifactSymlinks) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: key}}, Symlinks: symlinks}
+	}
+	// saved returns the objects under the key, by their keys relative to it
+	saved := func(key string) map[string]string {
+		got := map[string]string{}
+		for k, data := range objects {
+			if strings.HasPrefix(k, key+"/") {
+				got[strings.TrimPrefix(k, key+"/")] = data
+			}
+		}
+		return got
+	}
+
+	t.Run("Skip", func(t *testing.T) {
+		for _, symlinks := range []wfv1.ArtifactSymlinks{"", wfv1.ArtifactSymlinksSkip} {
+			key := "skip" + string(symlinks)
+			if assert.NoError(t, driver.Save(context.Background(), dir, newArtifact(key, symlinks))) {
+				assert.Equal(t, map[string]string{"a": "a", "lib/b": "bb"}, saved(key))
+			}
+		}
+	})
+	t.Run("Preserve", func(t *testing.T) {
+		if !assert.NoError(t, driver.Save(context.Background(), dir, newArtifact("preserve", wfv1.ArtifactSymlinksPreserve))) {
+			return
+		}
+		assert.Equal(t, map[string]string{"a": "a", "lib/b": "bb", "link-a": "", "link-lib": "", "dangling": ""}, saved("preserve"))
+		path := filepath.Join(tmp, "preserved")
+		if assert.NoError(t, driver.Load(context.Background(), newArtifact("preserve", ""), path)) {
+			for link, target := range map[string]string{"link-a": "a", "link-lib": "lib", "dangling": "missing"} {
+				got, err := os.Readlink(filepath.Join(path, link))
+				assert.NoError(t, err)
+				assert.Equal(t, target, got)
+			}
+			data, err := ioutil.ReadFile(filepath.Join(path, "link-lib", "b"))
+			assert.NoError(t, err)
+			assert.Equal(t, "bb", string(data))
+		}
+	})
+	t.Run("Follow", func(t *testing.T) {
+		err := driver.Save(context.Background(), dir, newArtifact("follow", wfv1.ArtifactSymlinksFollow))
+		assert.True(t, errors.Is(err, artifactscommon.ErrSymlinkNotFollowed))
+		assert.NoError(t, os.Remove(filepath.Join(dir, "dangling")))
+		if assert.NoError(t, driver.Save(context.Background(), dir, newArtifact("follow", wfv1.ArtifactSymlinksFollow))) {
+			assert.Equal(t, map[string]string{"a": "a", "lib/b": "bb", "link-a": "a", "link-lib/b": "bb"}, saved("follow"))
+		}
+	})
+	t.Run("Chain", func(t *testing.T) {
+		chain := filepath.Join(tmp, "chain")
+		assert.NoError(t, os.MkdirAll(filepath.Join(chain, "sub"), 0700))
+		assert.NoError(t, os.Symlink("..", filepath.Join(chain, "sub", "link")))
+		assert.NoError(t, os.Symlink(filepath.FromSlash("sub/link/.."), filepath.Join(chain, "escape")))
+		if !assert.NoError(t, driver.Save(context.Background(), chain, newArtifact("chain", wfv1.ArtifactSymlinksPreserve))) {
+			return
+		}
+		// an object under a preserved symlink, which no directory can have, is not loaded through it
+		assert.NoError(t, driver.Save(context.Background(), filepath.Join(dir, "a"), newArtifact("chain/sub/link/evil", "")))
+		path := filepath.Join(tmp, "chained")
+		err := driver.Load(context.Background(), newArtifact("chain", ""), path)
+		assert.True(t, errors.Is(err, artifactscommon.ErrSymlinkNotFollowed))
+		for _, evil := range []string{filepath.Join(tmp, "evil"), filepath.Join(path, "evil")} {
+			_, err = os.Lstat(evil)
+			assert.True(t, os.IsNotExist(err), evil)
+		}
+	})
+}
+
+func TestS3ArtifactDriver_SkipIfUnchanged(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "unchanged")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	path := filepath.Join(tmp, "my-file")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("hello"), 0600))
+	dir := filepath.Join(tmp, "my-dir")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "b"), []byte("bb"), 0600))
+	var lock sync.Mutex
+	var puts []string
+	objects := map[string]string{"my-etag-key": "hello"}
+	handler := newOverwriteHandler(objects)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			lock.Lock()
+			puts = append(puts, strings.TrimPrefix(r.URL.Path, "/my-bucket/"))
+			lock.Unlock()
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	driver := &S3ArtifactDriver{Endpoint: strings.TrimPrefix(server.URL, "http://"), Region: "us-east-1", AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	newArtifact := func(key string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: key}}, Overwrite: wfv1.ArtifactOverwriteSkipIfUnchanged}
+	}
+	save := func(path string, art *wfv1.Artifact) (*artifactscommon.SaveResult, []string) {
+		puts = nil
+		result, err := driver.SaveWithResult(context.Background(), path, art, "", nil)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		sort.Strings(puts)
+		return result, puts
+	}
+
+	t.Run("File", func(t *testing.T) {
+		result, puts := save(path, newArtifact("my-key"))
+		assert.False(t, result.Unchanged)
+		assert.Equal(t, []string{"my-key"}, puts)
+		// an identical re-save puts nothing
+		result, puts = save(path, newArtifact("my-key"))
+		assert.True(t, result.Unchanged)
+		assert.Empty(t, result.Objects)
+		assert.Empty(t, puts)
+		// a changed file is put
+		changed := filepath.Join(tmp, "my-changed-file")
+		assert.NoError(t, ioutil.WriteFile(changed, []byte("world"), 0600))
+		result, puts = save(changed, newArtifact("my-key"))
+		assert.False(t, result.Unchanged)
+		assert.Equal(t, []string{"my-key"}, puts)
+		assert.Equal(t, "world", objects["my-key"])
+	})
+	t.Run("ETag", func(t *testing.T) {
+		// an object without a recorded sha256 is compared by its ETag, which is its md5
+		result, puts := save(path, newArtifact("my-etag-key"))
+		assert.True(t, result.Unchanged)
+		assert.Empty(t, puts)
+	})
+	t.Run("Directory", func(t *testing.T) {
+		result, puts := save(dir, newArtifact("my-dir"))
+		assert.False(t, result.Unchanged)
+		assert.Equal(t, []string{"my-dir.manifest", "my-dir/a", "my-dir/sub/b"}, puts)
+		result, puts = save(dir, newArtifact("my-dir"))
+		assert.True(t, result.Unchanged)
+		assert.Empty(t, puts)
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "b"), []byte("cc"), 0600))
+		result, puts = save(dir, newArtifact("my-dir"))
+		assert.False(t, result.Unchanged)
+		assert.Equal(t, []string{"my-dir.manifest", "my-dir/a", "my-dir/sub/b"}, puts)
+		assert.Equal(t, "cc", objects["my-dir/sub/b"])
+	})
+}
+
+func TestS3ArtifactDriver_Idempotency(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "idempotency")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	path := filepath.Join(tmp, "my-file")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("hello"), 0600))
+	dir := filepath.Join(tmp, "my-dir")
+	assert.NoError(t, os.MkdirAll(dir, 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0600))
+	var lock sync.Mutex
+	var puts []string
+	objects := map[string]string{}
+	handler := newOverwriteHandler(objects)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			lock.Lock()
+			puts = append(puts, strings.TrimPrefix(r.URL.Path, "/my-bucket/"))
+			lock.Unlock()
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	driver := &S3ArtifactDriver{Endpoint: strings.TrimPrefix(server.URL, "http://"), Region: "us-east-1", AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	newArtifact := func(key, idempotencyKey string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: key}}, Idempotency: &wfv1.ArtifactIdempotency{Key: idempotencyKey}}
+	}
+	save := func(path string, art *wfv1.Artifact) (*artifactscommon.SaveResult, []string) {
+		puts = nil
+		result, err := driver.SaveWithResult(context.Background(), path, art, "", nil)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		sort.Strings(puts)
+		return result, puts
+	}
+
+	t.Run("File", func(t *testing.T) {
+		result, puts := save(path, newArtifact("my-key", "my-idempotency-key"))
+		assert.False(t, result.Unchanged)
+		assert.Equal(t, []string{"my-key"}, puts)
+		// a retried save puts nothing
+		result, puts = save(path, newArtifact("my-key", "my-idempotency-key"))
+		assert.True(t, result.Unchanged)
+		assert.Empty(t, puts)
+		// nor is a save with another key skipped, even though the object is unchanged
+		result, puts = save(path, newArtifact("my-key", "my-other-idempotency-key"))
+		assert.False(t, result.Unchanged)
+		assert.Equal(t, []string{"my-key"}, puts)
+		// nor is a changed file
+		changed := filepath.Join(tmp, "my-changed-file")
+		assert.NoError(t, ioutil.WriteFile(changed, []byte("world"), 0600))
+		result, puts = save(changed, newArtifact("my-key", "my-other-idempotency-key"))
+		assert.False(t, result.Unchanged)
+		assert.Equal(t, []string{"my-key"}, puts)
+		assert.Equal(t, "world", objects["my-key"])
+	})
+	t.Run("Directory", func(t *testing.T) {
+		result, puts := save(dir, newArtifact("my-dir", "my-idempotency-key"))
+		assert.False(t, result.Unchanged)
+		assert.Equal(t, []string{"my-dir.manifest", "my-dir/a"}, puts)
+		result, puts = save(dir, newArtifact("my-dir", "my-idempotency-key"))
+		assert.True(t, result.Unchanged)
+		assert.Empty(t, puts)
+		// a save which failed before its manifest was put did not complete, so is retried
+		delete(objects, "my-dir.manifest")
+		result, puts = save(dir, newArtifact("my-dir", "my-idempotency-key"))
+		assert.False(t, result.Unchanged)
+		assert.Equal(t, []string{"my-dir.manifest", "my-dir/a"}, puts)
+	})
+}
+
+// stagingS3Client stores the objects it puts, copies and deletes, failing to put the file failPut, or the copy
+// numbered failCopy
+type stagingS3Client struct {
+	S3Client
+	objects  map[string]string
+	failPut  string
+	failCopy int
+	copies   int
+}
+
+func (c *stagingS3Client) PutDirectoryWithResult(_, key, path string, _ artifactscommon.FileFilter) ([]artifactscommon.SavedObject, error) {
+	var objects []artifactscommon.SavedObject
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(path, p)
+		if rel == c.failPut {
+			return fmt.Errorf("access denied")
+		}
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		object := artifactscommon.SavedObject{Key: key + "/" + filepath.ToSlash(rel), Path: p, Size: info.Size()}
+		c.objects[object.Key] = string(data)
+		objects = append(objects, object)
+		return nil
+	})
+	return objects, err
+}
+
+func (c *stagingS3Client) CopyObject(_, srcKey, _, dstKey string) (artifactscommon.SavedObject, error) {
+	c.copies++
+	if c.copies == c.failCopy {
+		return artifactscommon.SavedObject{}, fmt.Errorf("access denied")
+	}
+	c.objects[dstKey] = c.objects[srcKey]
+	return artifactscommon.SavedObject{Key: dstKey, ETag: "my-etag"}, nil
+}
+
+func (c *stagingS3Client) Delete(_, key string) error {
+	delete(c.objects, key)
+	return nil
+}
+
+func (c *stagingS3Client) DeleteDirectory(_, keyPrefix string) error {
+	for key := range c.objects {
+		if strings.HasPrefix(key, keyPrefix+"/") {
+			delete(c.objects, key)
+		}
+	}
+	return nil
+}
+
+func TestPutDirectoryAtomically(t *testing.T) {
+	dir, err := ioutil.TempDir("", "atomic")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "b"), []byte("bb"), 0600))
+	logger := log.WithField("test", t.Name())
+	keys := func(objects map[string]string) []string {
+		var keys []string
+		for key := range objects {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	t.Run("Saved", func(t *testing.T) {
+		s3cli := &stagingS3Client{objects: map[string]string{"my-other-key": "other"}}
+		objects, err := putDirectoryAtomically(logger, s3cli, "my-bucket", "my-prefix/my-dir", dir, artifactscommon.FileFilter{})
+		if !assert.NoError(t, err) {
+			return
+		}
+		// the staging objects were deleted once they were copied
+		assert.Equal(t, []string{"my-other-key", "my-prefix/my-dir/a", "my-prefix/my-dir/sub/b"}, keys(s3cli.objects))
+		assert.Equal(t, "bb", s3cli.objects["my-prefix/my-dir/sub/b"])
+		sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+		assert.Equal(t, []artifactscommon.SavedObject{
+			{Key: "my-prefix/my-dir/a", Path: filepath.Join(dir, "a"), Size: 1, ETag: "my-etag"},
+			{Key: "my-prefix/my-dir/sub/b", Path: filepath.Join(dir, "sub", "b"), Size: 2, ETag: "my-etag"},
+		}, objects)
+	})
+	t.Run("PutFailed", func(t *testing.T) {
+		s3cli := &stagingS3Client{objects: map[string]string{}, failPut: filepath.Join("sub", "b")}
+		_, err := putDirectoryAtomically(logger, s3cli, "my-bucket", "my-dir", dir, artifactscommon.FileFilter{})
+		assert.EqualError(t, err, "access denied")
+		// nothing was copied to the key, and what was put under the staging key was deleted
+		assert.Empty(t, s3cli.objects)
+	})
+	t.Run("CopyFailed", func(t *testing.T) {
+		s3cli := &stagingS3Client{objects: map[string]string{}, failCopy: 2}
+		_, err := putDirectoryAtomically(logger, s3cli, "my-bucket", "my-dir", dir, artifactscommon.FileFilter{})
+		assert.EqualError(t, err, "access denied")
+		assert.Equal(t, 2, s3cli.copies)
+		// the first copy, which succeeded, was deleted with the staging objects
+		assert.Empty(t, s3cli.objects)
+	})
+}
+
+// reportedObject is the ETag and version ID a server reported an object was put with
+type reportedObject struct {
+	etag, versionID string
+}
+
+func TestS3ArtifactDriver_SaveWithResult(t *testing.T) {
+	var lock sync.Mutex
+	reported := map[string]reportedObject{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		lock.Lock()
+		defer lock.Unlock()
+		object := reportedObject{etag: fmt.Sprintf("%x", md5.Sum(data)), versionID: fmt.Sprintf("version-%d", len(reported))}
+		reported[strings.TrimPrefix(r.URL.Path, "/my-bucket/")] = object
+		w.Header().Set("ETag", `"`+object.etag+`"`)
+		w.Header().Set("X-Amz-Version-Id", object.versionID)
+	}))
+	defer server.Close()
+	driver := &S3ArtifactDriver{Endpoint: strings.TrimPrefix(server.URL, "http://"), Region: "us-east-1", AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	dir, keys := newTestDirectory(t, 4)
+	defer func() { _ = os.RemoveAll(dir) }()
+	newArtifact := func(key string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: key}}}
+	}
+
+	t.Run("File", func(t *testing.T) {
+		path := filepath.Join(dir, "dir-0", "file-0")
+		result, err := driver.SaveWithResult(context.Background(), path, newArtifact("my-file"), "", nil)
+		if assert.NoError(t, err) {
+			lock.Lock()
+			defer lock.Unlock()
+			object := reported["my-file"]
+			assert.Equal(t, &artifactscommon.SaveResult{
+				Location: "s3://my-bucket/my-file",
+				Objects:  []artifactscommon.SavedObject{{Key: "my-file", Path: path, Size: int64(len("dir-0/file-0")), ETag: object.etag, VersionID: object.versionID}},
+				Size:     int64(len("dir-0/file-0")),
+			}, result)
+		}
+	})
+	t.Run("Directory", func(t *testing.T) {
+		result, err := driver.SaveWithResult(context.Background(), dir, newArtifact("my-key"), "", nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "s3://my-bucket/my-key", result.Location)
+		sort.Strings(keys)
+		lock.Lock()
+		defer lock.Unlock()
+		// each file of the directory is in the manifest, in the order of the keys
+		if assert.Len(t, result.Objects, len(keys)) {
+			var size int64
+			for i, key := range keys {
+				object := result.Objects[i]
+				assert.Equal(t, key, object.Key)
+				assert.Equal(t, filepath.Join(dir, strings.TrimPrefix(key, "my-key/")), object.Path)
+				assert.Equal(t, int64(len(strings.TrimPrefix(key, "my-key/"))), object.Size)
+				assert.Equal(t, reported[key].etag, object.ETag)
+				assert.Equal(t, reported[key].versionID, object.VersionID)
+				size += object.Size
+			}
+			assert.Equal(t, size, result.Size)
+		}
+	})
+}
+
+func TestS3ArtifactDriver_ContentType(t *testing.T) {
+	var lock sync.Mutex
+	contentTypes := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+		_, _ = ioutil.ReadAll(r.Body)
+		lock.Lock()
+		defer lock.Unlock()
+		contentTypes[strings.TrimPrefix(r.URL.Path, "/my-bucket/")] = r.Header.Get("Content-Type")
+	}))
+	defer server.Close()
+	driver := &S3ArtifactDriver{Endpoint: strings.TrimPrefix(server.URL, "http://"), Region: "us-east-1", AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	dir, err := ioutil.TempDir("", "content-type")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	for name, data := range map[string]string{"data.json": "{}", "image": "\x89PNG\r\n\x1a\n", "log": "plain text"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600))
+	}
+	newArtifact := func(key, contentType string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: key}}, ContentType: contentType}
+	}
+
+	t.Run("Detected", func(t *testing.T) {
+		if !assert.NoError(t, driver.Save(context.Background(), dir, newArtifact("detected", ""))) {
+			return
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		assert.Equal(t, "application/json", contentTypes["detected/data.json"])
+		assert.Equal(t, "image/png", contentTypes["detected/image"])
+		assert.Equal(t, "text/plain; charset=utf-8", contentTypes["detected/log"])
+	})
+	t.Run("Override", func(t *testing.T) {
+		_, err := driver.SaveWithResult(context.Background(), filepath.Join(dir, "data.json"), newArtifact("override", "application/x-ndjson"), "", nil)
+		if assert.NoError(t, err) {
+			lock.Lock()
+			defer lock.Unlock()
+			assert.Equal(t, "application/x-ndjson", contentTypes["override"])
+		}
+	})
+}
+
+func TestS3ArtifactDriver_Presign(t *testing.T) {
+	pathStyle := true
+	driver := &S3ArtifactDriver{Endpoint: "minio.example.com:9000", Region: "us-east-1", AccessKey: "my-access-key", SecretKey: "my-secret-key", PathStyle: &pathStyle}
+	newArtifact := func(key, versionID string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: key, VersionID: versionID}}}
+	}
+	presign := func(t *testing.T, art *wfv1.Artifact, method string, expiry time.Duration) *url.URL {
+		presigned, err := driver.Presign(art, method, expiry)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		u, err := url.Parse(presigned)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		return u
+	}
+
+	t.Run("Get", func(t *testing.T) {
+		u := presign(t, newArtifact("my-dir/my-file", ""), http.MethodGet, 90*time.Minute)
+		if u == nil {
+			return
+		}
+		assert.Equal(t, "http", u.Scheme)
+		assert.Equal(t, "minio.example.com:9000", u.Host)
+		assert.Equal(t, "/my-bucket/my-dir/my-file", u.Path)
+		query := u.Query()
+		assert.Equal(t, "AWS4-HMAC-SHA256", query.Get("X-Amz-Algorithm"))
+		assert.Equal(t, "5400", query.Get("X-Amz-Expires"))
+		assert.True(t, strings.HasPrefix(query.Get("X-Amz-Credential"), "my-access-key/"))
+		assert.Regexp(t, "^[0-9a-f]{64}$", query.Get("X-Amz-Signature"))
+		signed, err := time.Parse("20060102T150405Z", query.Get("X-Amz-Date"))
+		if assert.NoError(t, err) {
+			assert.WithinDuration(t, time.Now(), signed, time.Minute)
+		}
+		assert.Empty(t, query.Get("versionId"))
+	})
+	t.Run("Version", func(t *testing.T) {
+		u := presign(t, newArtifact("my-file", "my-version"), http.MethodGet, time.Hour)
+		if u != nil {
+			assert.Equal(t, "my-version", u.Query().Get("versionId"))
+		}
+	})
+	t.Run("Put", func(t *testing.T) {
+		get := presign(t, newArtifact("my-file", ""), http.MethodGet, time.Hour)
+		put := presign(t, newArtifact("my-file", ""), http.MethodPut, time.Hour)
+		if get != nil && put != nil {
+			// the method is signed
+			assert.NotEqual(t, get.Query().Get("X-Amz-Signature"), put.Query().Get("X-Amz-Signature"))
+		}
+	})
+	t.Run("Directory", func(t *testing.T) {
+		_, err := driver.Presign(newArtifact("my-dir/", ""), http.MethodGet, time.Hour)
+		assert.Error(t, err)
+	})
+	t.Run("Expiry", func(t *testing.T) {
+		_, err := driver.Presign(newArtifact("my-file", ""), http.MethodGet, 8*24*time.Hour)
+		assert.Error(t, err)
+	})
+}
+
+func TestS3ArtifactDriver_PresignPostPolicy(t *testing.T) {
+	pathStyle := true
+	driver := &S3ArtifactDriver{Endpoint: "minio.example.com:9000", Region: "us-east-1", AccessKey: "my-access-key", SecretKey: "my-secret-key", PathStyle: &pathStyle}
+	newArtifact := func(key string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: key}}}
+	}
+
+	t.Run("Policy", func(t *testing.T) {
+		post, err := driver.PresignPostPolicy(newArtifact("uploads/"), artifactscommon.PostPolicy{MinSize: 1, MaxSize: 1024, Expiry: time.Hour})
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "http://minio.example.com:9000/my-bucket/", post.URL)
+		assert.Equal(t, "uploads/${filename}", post.Fields["key"])
+		assert.Equal(t, "AWS4-HMAC-SHA256", post.Fields["x-amz-algorithm"])
+		data, err := base64.StdEncoding.DecodeString(post.Fields["policy"])
+		if !assert.NoError(t, err) {
+			return
+		}
+		var policy struct {
+			Expiration time.Time       `json:"expiration"`
+			Conditions [][]interface{} `json:"conditions"`
+		}
+		if !assert.NoError(t, json.Unmarshal(data, &policy)) {
+			return
+		}
+		assert.WithinDuration(t, time.Now().Add(time.Hour), policy.Expiration, time.Minute)
+		assert.Contains(t, policy.Conditions, []interface{}{"eq", "$bucket", "my-bucket"})
+		assert.Contains(t, policy.Conditions, []interface{}{"starts-with", "$key", "uploads/"})
+		assert.Contains(t, policy.Conditions, []interface{}{"content-length-range", float64(1), float64(1024)})
+		// the signature is that of the policy, with the signing key of the secret key of the date and region
+		date := post.Fields["x-amz-date"]
+		if assert.Len(t, date, len("20060102T150405Z")) {
+			assert.Equal(t, "my-access-key/"+date[:8]+"/us-east-1/s3/aws4_request", post.Fields["x-amz-credential"])
+			key := []byte("AWS4my-secret-key")
+			for _, part := range []string{date[:8], "us-east-1", "s3", "aws4_request"} {
+				key = hmacSHA256(key, part)
+			}
+			assert.Equal(t, hex.EncodeToString(hmacSHA256(key, post.Fields["policy"])), post.Fields["x-amz-signature"])
+		}
+	})
+	t.Run("File", func(t *testing.T) {
+		post, err := driver.PresignPostPolicy(newArtifact("uploads/my-file"), artifactscommon.PostPolicy{MaxSize: 1024, Expiry: time.Hour})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "uploads/my-file", post.Fields["key"])
+		}
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		for name, policy := range map[string]artifactscommon.PostPolicy{
+			"Size":   {MinSize: 2048, MaxSize: 1024, Expiry: time.Hour},
+			"Expiry": {MaxSize: 1024, Expiry: 8 * 24 * time.Hour},
+		} {
+			_, err := driver.PresignPostPolicy(newArtifact("uploads/"), policy)
+			assert.Error(t, err, name)
+		}
+		_, err := driver.PresignPostPolicy(newArtifact(""), artifactscommon.PostPolicy{MaxSize: 1024, Expiry: time.Hour})
+		assert.Error(t, err)
+	})
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func TestIfNoneMatchTransport(t *testing.T) {
+	var headers []string
+	transport := ifNoneMatchTransport{roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		headers = append(headers, r.Method+" "+r.URL.RawQuery+" "+r.Header.Get("If-None-Match"))
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	})}
+	put := context.WithValue(context.Background(), ifNoneMatchKey{}, true)
+	for _, r := range []struct {
+		ctx           context.Context
+		method, query string
+	}{
+		{put, http.MethodPut, ""},
+		{put, http.MethodPost, "uploads="},
+		{put, http.MethodPut, "partNumber=1&uploadId=my-upload"},
+		{put, http.MethodPost, "uploadId=my-upload"},
+		{context.Background(), http.MethodPut, ""},
+	} {
+		req, err := http.NewRequestWithContext(r.ctx, r.method, "http://s3.example.com/my-bucket/my-key?"+r.query, nil)
+		if assert.NoError(t, err) {
+			_, err = transport.RoundTrip(req)
+			assert.NoError(t, err)
+		}
+	}
+	// only the requests which create the object are conditional
+	assert.Equal(t, []string{"PUT  *", "POST uploads= ", "PUT partNumber=1&uploadId=my-upload ", "POST uploadId=my-upload *", "PUT  "}, headers)
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// newVersionServer returns a fake S3 server which serves the versions of the object "my-key" in the bucket
+// "my-bucket", the latest of which has the version "", and records the versions got
+func newVersionServer(versioned bool, versions map[string]string, got *[]string) *httptest.Server {
+	var lock sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		query := r.URL.Query()
+		if _, ok := query["versioning"]; ok {
+			status := ""
+			if versioned {
+				status = "<Status>Enabled</Status>"
+			}
+			_, _ = fmt.Fprintf(w, `<VersioningConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">%s</VersioningConfiguration>`, status)
+			return
+		}
+		versionID := query.Get("versionId")
+		*got = append(*got, versionID)
+		if !versioned && versionID != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprint(w, `<Error><Code>InvalidArgument</Code><Message>Invalid version id specified</Message></Error>`)
+			return
+		}
+		data, ok := versions[versionID]
+		if r.URL.Path != "/my-bucket/my-key" || !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `<Error><Code>NoSuchVersion</Code><Message>The specified version does not exist.</Message></Error>`)
+			return
+		}
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum([]byte(data))))
+		http.ServeContent(w, r, "my-key", time.Unix(1600000000, 0), strings.NewReader(data))
+	}))
+}
+
+func TestS3ArtifactDriver_LoadVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "version")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "my-file")
+	newArtifact := func(versionID string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: "my-key", VersionID: versionID}}}
+	}
+	newDriver := func(server *httptest.Server) *S3ArtifactDriver {
+		return &S3ArtifactDriver{Endpoint: strings.TrimPrefix(server.URL, "http://"), Region: "us-east-1", AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	}
+
+	t.Run("Versioned", func(t *testing.T) {
+		var got []string
+		server := newVersionServer(true, map[string]string{"": "new", "my-version": "old"}, &got)
+		defer server.Close()
+		driver := newDriver(server)
+		if assert.NoError(t, driver.Load(context.Background(), newArtifact("my-version"), path)) {
+			data, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, "old", string(data))
+		}
+		// every request of the load is for the version
+		assert.NotEmpty(t, got)
+		for _, versionID := range got {
+			assert.Equal(t, "my-version", versionID)
+		}
+		stream, err := driver.OpenStream(newArtifact("my-version"))
+		if assert.NoError(t, err) {
+			data, err := ioutil.ReadAll(stream)
+			assert.NoError(t, err)
+			assert.Equal(t, "old", string(data))
+			assert.NoError(t, stream.Close())
+		}
+		got = nil
+		if assert.NoError(t, driver.Load(context.Background(), newArtifact(""), path)) {
+			data, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, "new", string(data))
+		}
+		assert.NotContains(t, got, "my-version")
+		exists, err := driver.Exists(newArtifact("my-version"))
+		assert.NoError(t, err)
+		assert.True(t, exists)
+	})
+
+	t.Run("NoSuchVersion", func(t *testing.T) {
+		var got []string
+		server := newVersionServer(true, map[string]string{"": "new"}, &got)
+		defer server.Close()
+		driver := newDriver(server)
+		err := driver.Load(context.Background(), newArtifact("my-version"), path)
+		assert.True(t, errors.Is(err, artifactscommon.ErrArtifactNotFound))
+		err = driver.LoadRange(newArtifact("my-version"), 0, 1, path)
+		assert.True(t, errors.Is(err, artifactscommon.ErrArtifactNotFound))
+		exists, err := driver.Exists(newArtifact("my-version"))
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("NotVersioned", func(t *testing.T) {
+		var got []string
+		server := newVersionServer(false, map[string]string{"": "new"}, &got)
+		defer server.Close()
+		driver := newDriver(server)
+		err := driver.Load(context.Background(), newArtifact("my-version"), path)
+		assert.EqualError(t, err, "version my-version of s3://my-bucket/my-key cannot be loaded, as bucket my-bucket is not versioned")
+		_, err = driver.OpenStream(newArtifact("my-version"))
+		assert.EqualError(t, err, "version my-version of s3://my-bucket/my-key cannot be loaded, as bucket my-bucket is not versioned")
+		// the latest version of an object in a bucket which is not versioned is loaded as ever
+		assert.NoError(t, driver.Load(context.Background(), newArtifact(""), path))
+	})
+}
+
+func TestS3ArtifactDriver_SelectLatest(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "latest")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	objects := map[string]string{
+		"data/data-20240101.csv":  "1",
+		"data/data-20240102.csv":  "2",
+		"data/data-20240103.csv":  "3",
+		"other/data-20240104.csv": "4",
+	}
+	// the object with the greatest key is not that modified last
+	lastModified := map[string]string{
+		"data/data-20240101.csv":  "2024-01-01T00:00:00.000Z",
+		"data/data-20240102.csv":  "2024-01-05T00:00:00.000Z",
+		"data/data-20240103.csv":  "2024-01-03T00:00:00.000Z",
+		"other/data-20240104.csv": "2024-01-06T00:00:00.000Z",
+	}
+	handler := newOverwriteHandler(objects)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Query().Get("list-type") != "2" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		prefix := r.URL.Query().Get("prefix")
+		_, _ = fmt.Fprintf(w, `<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Name>my-bucket</Name><Prefix>%s</Prefix><MaxKeys>1000</MaxKeys><IsTruncated>false</IsTruncated>`, prefix)
+		for k := range objects {
+			if strings.HasPrefix(k, prefix) {
+				_, _ = fmt.Fprintf(w, `<Contents><Key>%s</Key><LastModified>%s</LastModified><Size>%d</Size></Contents>`, k, lastModified[k], len(objects[k]))
+			}
+		}
+		_, _ = fmt.Fprint(w, `</ListBucketResult>`)
+	}))
+	defer server.Close()
+	driver := &S3ArtifactDriver{Endpoint: strings.TrimPrefix(server.URL, "http://"), Region: "us-east-1", AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	newArtifact := func(key string, policy wfv1.ArtifactSelectLatest) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: key}}, SelectLatest: policy}
+	}
+
+	for policy, expected := range map[wfv1.ArtifactSelectLatest]string{wfv1.ArtifactSelectLatestLastModified: "2", wfv1.ArtifactSelectLatestLexical: "3"} {
+		t.Run(string(policy), func(t *testing.T) {
+			path := filepath.Join(tmp, string(policy))
+			if assert.NoError(t, driver.Load(context.Background(), newArtifact("data/data-", policy), path)) {
+				data, err := ioutil.ReadFile(path)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, string(data))
+			}
+		})
+	}
+	t.Run("None", func(t *testing.T) {
+		err := driver.Load(context.Background(), newArtifact("data/report-", wfv1.ArtifactSelectLatestLexical), filepath.Join(tmp, "none"))
+		assert.EqualError(t, err, "no object has the key prefix data/report- to select the latest of")
+		assert.True(t, errors.Is(err, artifactscommon.ErrArtifactNotFound))
+	})
+}
+
+func TestS3ArtifactDriver_LoadInterrupted(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "interrupted")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	objects := map[string]string{"my-key": "0123456789", "my-dir/a": "a", "my-dir/b": "0123456789"}
+	handler := newOverwriteHandler(objects)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/my-bucket/")
+		if r.Method != http.MethodGet || (key != "my-key" && key != "my-dir/b") {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		// the connection is dropped after half of the object
+		w.Header().Set("Content-Length", fmt.Sprint(len(objects[key])))
+		_, _ = fmt.Fprint(w, objects[key][:5])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+	defer server.Close()
+	driver := &S3ArtifactDriver{Endpoint: strings.TrimPrefix(server.URL, "http://"), Region: "us-east-1", AccessKey: "my-access-key", SecretKey: "my-secret-key", Retry: wait.Backoff{Duration: time.Millisecond, Steps: 1}}
+	newArtifact := func(key string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: key}}}
+	}
+
+	t.Run("File", func(t *testing.T) {
+		path := filepath.Join(tmp, "my-file")
+		assert.Error(t, driver.Load(context.Background(), newArtifact("my-key"), path))
+		_, err := os.Stat(path)
+		assert.True(t, os.IsNotExist(err))
+	})
+	t.Run("Directory", func(t *testing.T) {
+		path := filepath.Join(tmp, "my-dir")
+		assert.NoError(t, os.Mkdir(path, 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(path, "prior"), []byte("prior"), 0600))
+		assert.Error(t, driver.Load(context.Background(), newArtifact("my-dir"), path))
+		infos, err := ioutil.ReadDir(path)
+		assert.NoError(t, err)
+		if assert.Len(t, infos, 1) {
+			assert.Equal(t, "prior", infos[0].Name())
+		}
+	})
+	// nothing partially written is left next to the paths either
+	infos, err := ioutil.ReadDir(tmp)
+	assert.NoError(t, err)
+	if assert.Len(t, infos, 1) {
+		assert.Equal(t, "my-dir", infos[0].Name())
+	}
+}
+
+// copyingS3Client serves objects from memory, keyed by their buckets and keys, e.g. "my-bucket/my-key", and records
+// the objects it copies
+type copyingS3Client struct {
+	S3Client
+	objects map[string]string
+	copies  []string
+}
+
+func (c *copyingS3Client) CopyObject(srcBucket, srcKey, dstBucket, dstKey string) (artifactscommon.SavedObject, error) {
+	data, ok := c.objects[srcBucket+"/"+srcKey]
+	if !ok {
+		return artifactscommon.SavedObject{}, minio.ErrorResponse{Code: "NoSuchKey", BucketName: srcBucket, Key: srcKey}
+	}
+	c.objects[dstBucket+"/"+dstKey] = data
+	c.copies = append(c.copies, srcBucket+"/"+srcKey+" -> "+dstBucket+"/"+dstKey)
+	return artifactscommon.SavedObject{Key: dstKey}, nil
+}
+
+func (c *copyingS3Client) IsDirectory(bucket, key string) (bool, error) {
+	objects, err := c.ListObjects(bucket, dirKeyPrefix(key))
+	return len(objects) > 0, err
+}
+
+func (c *copyingS3Client) ListObjects(bucket, prefix string) ([]artifactscommon.ListedObject, error) {
+	var objects []artifactscommon.ListedObject
+	for key := range c.objects {
+		if strings.HasPrefix(key, bucket+"/"+prefix) {
+			objects = append(objects, artifactscommon.ListedObject{Key: strings.TrimPrefix(key, bucket+"/")})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func TestCopyObjects(t *testing.T) {
+	newS3Client := func() *copyingS3Client {
+		return &copyingS3Client{objects: map[string]string{"staging/my-file": "a", "staging/my-dir/a": "a", "staging/my-dir/sub/b": "bb"}}
+	}
+	artifact := func(bucket, key string) *wfv1.S3Artifact {
+		return &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: bucket}, Key: key}
+	}
+	t.Run("File", func(t *testing.T) {
+		s3cli := newS3Client()
+		assert.NoError(t, copyObjects(s3cli, artifact("staging", "my-file"), artifact("release", "v1/my-file")))
+		assert.Equal(t, []string{"staging/my-file -> release/v1/my-file"}, s3cli.copies)
+	})
+	t.Run("Directory", func(t *testing.T) {
+		s3cli := newS3Client()
+		assert.NoError(t, copyObjects(s3cli, artifact("staging", "my-dir"), artifact("release", "v1/my-dir")))
+		assert.Equal(t, []string{"staging/my-dir/a -> release/v1/my-dir/a", "staging/my-dir/sub/b -> release/v1/my-dir/sub/b"}, s3cli.copies)
+		assert.Equal(t, "bb", s3cli.objects["release/v1/my-dir/sub/b"])
+	})
+	t.Run("Prefix", func(t *testing.T) {
+		s3cli := newS3Client()
+		assert.NoError(t, copyObjects(s3cli, artifact("staging", "my-dir/"), artifact("release", "v1/")))
+		assert.Equal(t, []string{"staging/my-dir/a -> release/v1/a", "staging/my-dir/sub/b -> release/v1/sub/b"}, s3cli.copies)
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		s3cli := newS3Client()
+		err := copyObjects(s3cli, artifact("staging", "missing"), artifact("release", "missing"))
+		assert.True(t, errors.Is(err, artifactscommon.ErrArtifactNotFound))
+		assert.Empty(t, s3cli.copies)
+	})
+}
+
+func TestS3ArtifactDriver_CopiesFrom(t *testing.T) {
+	driver := &S3ArtifactDriver{}
+	secret := &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "accessKey"}
+	artifact := func(bucket string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Endpoint: "s3.amazonaws.com", Bucket: bucket, AccessKeySecret: secret}, Key: "my-key"}}}
+	}
+	assert.True(t, driver.CopiesFrom(artifact("staging"), artifact("release")))
+	otherEndpoint := artifact("release")
+	otherEndpoint.S3.Endpoint = "minio:9000"
+	assert.False(t, driver.CopiesFrom(artifact("staging"), otherEndpoint))
+	otherCredentials := artifact("release")
+	otherCredentials.S3.AccessKeySecret = &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-other-secret"}, Key: "accessKey"}
+	assert.False(t, driver.CopiesFrom(artifact("staging"), otherCredentials))
+	version := artifact("staging")
+	version.S3.VersionID = "my-version"
+	assert.False(t, driver.CopiesFrom(version, artifact("release")))
+	tagged := artifact("release")
+	tagged.S3.Tags = map[string]string{"stage": "release"}
+	assert.False(t, driver.CopiesFrom(artifact("staging"), tagged))
+	assert.False(t, driver.CopiesFrom(&wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{GCS: &wfv1.GCSArtifact{}}}, artifact("release")))
+}
+
+func TestS3ArtifactDriver_MaxSize(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "max-size")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	objects := map[string]string{
+		"my-file":      "0123456789",
+		"my-dir/a":     "01234",
+		"my-dir/sub/b": "56789",
+	}
+	handler := newOverwriteHandler(objects)
+	var gets int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "" {
+			atomic.AddInt32(&gets, 1)
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	driver := &S3ArtifactDriver{Endpoint: strings.TrimPrefix(server.URL, "http://"), Region: "us-east-1", AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	newArtifact := func(key, maxSize string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: key}}, MaxSize: maxSize}
+	}
+
+	for _, key := range []string{"my-file", "my-dir"} {
+		atomic.StoreInt32(&gets, 0)
+		path := filepath.Join(tmp, "too-large", key)
+		err := driver.Load(context.Background(), newArtifact(key, "9"), path)
+		assert.True(t, errors.Is(err, artifactscommon.ErrArtifactTooLarge), key)
+		// the size is checked before any object is got
+		assert.Equal(t, int32(0), atomic.LoadInt32(&gets), key)
+		_, err = os.Stat(path)
+		assert.True(t, os.IsNotExist(err), key)
+
+		assert.NoError(t, driver.Load(context.Background(), newArtifact(key, "10"), filepath.Join(tmp, "at-limit", key)), key)
+	}
+}
+
+func TestS3ArtifactDriver_Metadata(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "metadata")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	path := filepath.Join(tmp, "my-file")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("hello"), 0600))
+	dir := filepath.Join(tmp, "my-dir")
+	assert.NoError(t, os.MkdirAll(dir, 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0600))
+	handler := newOverwriteHandler(map[string]string{})
+	var lock sync.Mutex
+	sent := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			lock.Lock()
+			sent[strings.TrimPrefix(r.URL.Path, "/my-bucket/")] = r.Header.Get("X-Amz-Meta-Git-Commit")
+			lock.Unlock()
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	driver := &S3ArtifactDriver{Endpoint: strings.TrimPrefix(server.URL, "http://"), Region: "us-east-1", AccessKey: "my-access-key", SecretKey: "my-secret-key"}
+	metadata := map[string]string{"git-commit": "abc123", "build-id": "42"}
+	newArtifact := func(key string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: key}}, Metadata: metadata}
+	}
+
+	// the metadata is sent as x-amz-meta-* headers, with every object of a directory
+	assert.NoError(t, driver.Save(context.Background(), path, newArtifact("my-key")))
+	assert.NoError(t, driver.Save(context.Background(), dir, newArtifact("my-dir")))
+	assert.Equal(t, map[string]string{"my-key": "abc123", "my-dir/a": "abc123"}, sent)
+	// and is got back as it was saved, without the metadata the driver records
+	assert.NoError(t, driver.SaveWithContentEncoding(context.Background(), path, newArtifact("my-encoded-key"), "gzip", nil))
+	for _, key := range []string{"my-key", "my-dir/a", "my-encoded-key"} {
+		got, err := driver.Metadata(newArtifact(key))
+		if assert.NoError(t, err, key) {
+			assert.Equal(t, metadata, got, key)
+		}
+	}
+	_, err = driver.Metadata(newArtifact("my-missing"))
+	assert.True(t, errors.Is(err, artifactscommon.ErrArtifactNotFound))
+}
+
+// errorProvider is a credentials provider which fails to retrieve credentials, as that of the IAM role does outside of
+// AWS
+type errorProvider struct{}
+
+func (errorProvider) Retrieve() (credentials.Value, error) {
+	return credentials.Value{}, fmt.Errorf("no IAM role")
+}
+
+func (errorProvider) IsExpired() bool {
+	return true
+}
+
+func TestS3ArtifactDriver_Anonymous(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		// the requests of public objects are not signed
+		assert.Empty(t, r.Header.Get("Authorization"))
+		assert.Empty(t, r.URL.Query().Get("X-Amz-Signature"))
+		if r.URL.Path != "/my-bucket/my-key" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", `"5d41402abc4b2a76b9719d911017c592"`)
+		http.ServeContent(w, r, "my-key", time.Unix(1600000000, 0), strings.NewReader("hello"))
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "anonymous")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	art := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: "my-key"}}}
+	newDriver := func() *S3ArtifactDriver {
+		return &S3ArtifactDriver{Endpoint: strings.TrimPrefix(server.URL, "http://"), Region: "us-east-1"}
+	}
+
+	t.Run("Load", func(t *testing.T) {
+		requests = nil
+		driver := newDriver()
+		driver.Anonymous = true
+		path := filepath.Join(dir, "load")
+		if assert.NoError(t, driver.Load(context.Background(), art, path)) {
+			data, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, "hello", string(data))
+		}
+		assert.NotEmpty(t, requests)
+	})
+	t.Run("NoCredentials", func(t *testing.T) {
+		defer func(f func(string) *credentials.Credentials) { iamCredentials = f }(iamCredentials)
+		iamCredentials = func(string) *credentials.Credentials { return credentials.New(errorProvider{}) }
+		requests = nil
+		ok, err := newDriver().Exists(art)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.NotEmpty(t, requests)
+	})
+	t.Run("UseSDKCreds", func(t *testing.T) {
+		defer func(f func(string) *credentials.Credentials) { iamCredentials = f }(iamCredentials)
+		iamCredentials = func(string) *credentials.Credentials { return credentials.New(errorProvider{}) }
+		requests = nil
+		driver := newDriver()
+		driver.UseSDKCreds = true
+		// the credentials are required, rather than falling back to unsigned requests
+		_, err := driver.Exists(art)
+		assert.Error(t, err)
+		assert.Empty(t, requests)
+	})
+	t.Run("Save", func(t *testing.T) {
+		requests = nil
+		driver := newDriver()
+		driver.Anonymous = true
+		path := filepath.Join(dir, "save")
+		if !assert.NoError(t, ioutil.WriteFile(path, []byte("hello"), 0600)) {
+			return
+		}
+		assert.EqualError(t, driver.Save(context.Background(), path, art), "S3 Save cannot be anonymous, only loads of public objects can be")
+		assert.EqualError(t, driver.Delete(art), "S3 Delete cannot be anonymous, only loads of public objects can be")
+		_, err := driver.Presign(art, http.MethodPut, time.Hour)
+		assert.EqualError(t, err, "S3 PUT presign cannot be anonymous, only loads of public objects can be")
+		assert.Empty(t, requests)
+	})
+}
+
+func TestValidateArtifact_Anonymous(t *testing.T) {
+	validate := func(bucket wfv1.S3Bucket) error {
+		return ValidateArtifact("inputs.artifacts.my-art.s3", &wfv1.S3Artifact{S3Bucket: bucket})
+	}
+	assert.NoError(t, validate(wfv1.S3Bucket{Anonymous: true}))
+	assert.EqualError(t, validate(wfv1.S3Bucket{Anonymous: true, AccessKeySecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-secret"}, Key: "accessKey"}}),
+		"inputs.artifacts.my-art.s3.anonymous cannot be used with inputs.artifacts.my-art.s3.accessKeySecret or inputs.artifacts.my-art.s3.secretKeySecret")
+	assert.EqualError(t, validate(wfv1.S3Bucket{Anonymous: true, UseSDKCreds: true}),
+		"inputs.artifacts.my-art.s3.anonymous cannot be used with inputs.artifacts.my-art.s3.roleARN, inputs.artifacts.my-art.s3.useSDKCreds or inputs.artifacts.my-art.s3.credentialProviders")
+	assert.EqualError(t, validate(wfv1.S3Bucket{Anonymous: true, RequesterPays: true}),
+		"inputs.artifacts.my-art.s3.anonymous cannot be used with inputs.artifacts.my-art.s3.requesterPays, as requester pays buckets bill the requester")
+}
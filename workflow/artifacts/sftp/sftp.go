@@ -0,0 +1,250 @@
+package sftp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/argoproj/pkg/file"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const defaultPort = "22"
+
+// ArtifactDriver is the artifact driver for an SFTP server
+type ArtifactDriver struct {
+	Username              string
+	Password              string
+	SSHPrivateKey         string
+	InsecureIgnoreHostKey bool
+	common.Logging
+}
+
+func (d *ArtifactDriver) auth() ([]ssh.AuthMethod, error) {
+	var auth []ssh.AuthMethod
+	if d.SSHPrivateKey != "" {
+		signer, err := ssh.ParsePrivateKey([]byte(d.SSHPrivateKey))
+		if err != nil {
+			return nil, err
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if d.Password != "" {
+		auth = append(auth, ssh.Password(d.Password))
+	}
+	return auth, nil
+}
+
+func (d *ArtifactDriver) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if d.InsecureIgnoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	knownHosts := os.Getenv("SSH_KNOWN_HOSTS")
+	if knownHosts == "" {
+		knownHosts = filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts")
+	}
+	return knownhosts.New(knownHosts)
+}
+
+// newSFTPClient connects to the SFTP server. The returned function closes both the SFTP session and
+// the underlying SSH connection. The SFTP client does not take a context, so cancelling ctx closes them too,
+// which aborts any transfer.
+func (d *ArtifactDriver) newSFTPClient(ctx context.Context, host string) (*sftp.Client, func(), error) {
+	auth, err := d.auth()
+	if err != nil {
+		return nil, nil, err
+	}
+	hostKeyCallback, err := d.hostKeyCallback()
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, defaultPort)
+	}
+	conn, err := ssh.Dial("tcp", host, &ssh.ClientConfig{
+		User:            d.Username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	closeClient := func() {
+		_ = client.Close()
+		_ = conn.Close()
+	}
+	stop := common.CloseOnCancel(ctx, closeClient)
+	return client, func() {
+		stop()
+		closeClient()
+	}, nil
+}
+
+// Load downloads a file or directory from an SFTP server
+func (d *ArtifactDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, localPath string) error {
+	d.Log().Infof("SFTP Load path: %s, host: %s, remote path: %s", localPath, inputArtifact.SFTP.Host, inputArtifact.SFTP.Path)
+	client, closer, err := d.newSFTPClient(ctx, inputArtifact.SFTP.Host)
+	if err != nil {
+		return err
+	}
+	defer closer()
+	remotePath := inputArtifact.SFTP.Path
+	info, err := client.Stat(remotePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return common.NewNotFoundError(err)
+		}
+		return err
+	}
+	if !info.IsDir() {
+		return downloadFile(client, remotePath, localPath)
+	}
+	walker := client.Walk(remotePath)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(remotePath, walker.Path())
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(localPath, rel)
+		if walker.Stat().IsDir() {
+			if err := os.MkdirAll(dest, 0700); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := downloadFile(client, walker.Path(), dest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func downloadFile(client *sftp.Client, remotePath, localPath string) error {
+	src, err := client.Open(remotePath)
+	if err != nil {
+		return fmt.Errorf("open %s: %v", remotePath, err)
+	}
+	defer func() { _ = src.Close() }()
+	dest, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("create %s: %v", localPath, err)
+	}
+	defer func() { _ = dest.Close() }()
+	_, err = io.Copy(dest, src)
+	return err
+}
+
+// Save uploads a file or directory to an SFTP server
+func (d *ArtifactDriver) Save(ctx context.Context, localPath string, outputArtifact *wfv1.Artifact) error {
+	d.Log().Infof("SFTP Save path: %s, host: %s, remote path: %s", localPath, outputArtifact.SFTP.Host, outputArtifact.SFTP.Path)
+	client, closer, err := d.newSFTPClient(ctx, outputArtifact.SFTP.Host)
+	if err != nil {
+		return err
+	}
+	defer closer()
+	remotePath := outputArtifact.SFTP.Path
+	isDir, err := file.IsDirectory(localPath)
+	if err != nil {
+		return err
+	}
+	if !isDir {
+		if err := client.MkdirAll(path.Dir(remotePath)); err != nil {
+			return err
+		}
+		return uploadFile(client, localPath, remotePath)
+	}
+	return filepath.Walk(localPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localPath, p)
+		if err != nil {
+			return err
+		}
+		dest := path.Join(remotePath, filepath.ToSlash(rel))
+		if info.IsDir() {
+			return client.MkdirAll(dest)
+		}
+		return uploadFile(client, p, dest)
+	})
+}
+
+func uploadFile(client *sftp.Client, localPath, remotePath string) error {
+	src, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("open %s: %v", localPath, err)
+	}
+	defer func() { _ = src.Close() }()
+	dest, err := client.Create(remotePath)
+	if err != nil {
+		return fmt.Errorf("create %s: %v", remotePath, err)
+	}
+	defer func() { _ = dest.Close() }()
+	_, err = io.Copy(dest, src)
+	return err
+}
+
+// Delete removes a file or directory from an SFTP server
+func (d *ArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	d.Log().Infof("SFTP Delete host: %s, remote path: %s", artifact.SFTP.Host, artifact.SFTP.Path)
+	client, closer, err := d.newSFTPClient(context.Background(), artifact.SFTP.Host)
+	if err != nil {
+		return err
+	}
+	defer closer()
+	info, err := client.Stat(artifact.SFTP.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if !info.IsDir() {
+		return client.Remove(artifact.SFTP.Path)
+	}
+	// directories must be empty before they can be removed, so remove them deepest first
+	var dirs []string
+	walker := client.Walk(artifact.SFTP.Path)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return err
+		}
+		if walker.Stat().IsDir() {
+			dirs = append(dirs, walker.Path())
+			continue
+		}
+		if err := client.Remove(walker.Path()); err != nil {
+			return err
+		}
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := client.RemoveDirectory(dirs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Capabilities returns the optional operations on SFTP artifacts the driver supports
+func (d *ArtifactDriver) Capabilities() common.DriverCapabilities {
+	return common.DriverCapabilities{
+		Delete: true,
+	}
+}
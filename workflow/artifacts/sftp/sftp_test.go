@@ -0,0 +1,40 @@
+package sftp
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArtifactDriver_auth(t *testing.T) {
+	t.Run("Password", func(t *testing.T) {
+		driver := &ArtifactDriver{Username: "my-user", Password: "my-password"}
+		auth, err := driver.auth()
+		if assert.NoError(t, err) {
+			assert.Len(t, auth, 1)
+		}
+	})
+	t.Run("InvalidPrivateKey", func(t *testing.T) {
+		driver := &ArtifactDriver{Username: "my-user", SSHPrivateKey: "not-a-key"}
+		_, err := driver.auth()
+		assert.Error(t, err)
+	})
+}
+
+func TestArtifactDriver_hostKeyCallback(t *testing.T) {
+	t.Run("InsecureIgnoreHostKey", func(t *testing.T) {
+		driver := &ArtifactDriver{InsecureIgnoreHostKey: true}
+		callback, err := driver.hostKeyCallback()
+		if assert.NoError(t, err) {
+			assert.NoError(t, callback("my-host:22", nil, nil))
+		}
+	})
+	t.Run("MissingKnownHosts", func(t *testing.T) {
+		_ = os.Setenv("SSH_KNOWN_HOSTS", "/not-found/known_hosts")
+		defer func() { _ = os.Unsetenv("SSH_KNOWN_HOSTS") }()
+		driver := &ArtifactDriver{}
+		_, err := driver.hostKeyCallback()
+		assert.Error(t, err)
+	})
+}
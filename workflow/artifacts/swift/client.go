@@ -0,0 +1,419 @@
+package swift
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const (
+	// the type of the Swift service in the Keystone service catalog
+	objectStoreType = "object-store"
+	// the header a static large object (SLO) is marked with, and the one with the segment prefix of a dynamic large
+	// object (DLO)
+	staticLargeObjectHeader  = "X-Static-Large-Object"
+	dynamicLargeObjectHeader = "X-Object-Manifest"
+	// the number of objects Swift lists per request by default
+	listLimit = 10000
+)
+
+// apiError is a Swift or Keystone response with an error status
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status))
+	}
+	return fmt.Sprintf("%d %s: %s", e.Status, http.StatusText(e.Status), e.Message)
+}
+
+// authRequest is the body of a Keystone v3 request for a token
+type authRequest struct {
+	Auth struct {
+		Identity identity `json:"identity"`
+		Scope    *scope   `json:"scope,omitempty"`
+	} `json:"auth"`
+}
+
+type identity struct {
+	Methods               []string               `json:"methods"`
+	Password              *passwordIdentity      `json:"password,omitempty"`
+	ApplicationCredential *applicationCredential `json:"application_credential,omitempty"`
+}
+
+type passwordIdentity struct {
+	User struct {
+		Name     string `json:"name"`
+		Domain   domain `json:"domain"`
+		Password string `json:"password"`
+	} `json:"user"`
+}
+
+type applicationCredential struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+}
+
+type scope struct {
+	Project struct {
+		Name   string `json:"name"`
+		Domain domain `json:"domain"`
+	} `json:"project"`
+}
+
+type domain struct {
+	Name string `json:"name"`
+}
+
+// authResponse is the part of the body of a Keystone v3 token the client uses
+type authResponse struct {
+	Token struct {
+		Catalog []struct {
+			Type      string `json:"type"`
+			Endpoints []struct {
+				Interface string `json:"interface"`
+				Region    string `json:"region"`
+				RegionID  string `json:"region_id"`
+				URL       string `json:"url"`
+			} `json:"endpoints"`
+		} `json:"catalog"`
+	} `json:"token"`
+}
+
+// client is a client of the Swift API of a project, authenticated with a Keystone v3 token
+type client struct {
+	ctx        context.Context
+	httpClient *http.Client
+	token      string
+	storageURL string
+}
+
+// authenticate requests a token from Keystone, and finds the public object-store endpoint of the region, or of any
+// region if it is not set, in its service catalog
+func authenticate(ctx context.Context, authURL, region string, auth authRequest) (*client, error) {
+	c := &client{ctx: ctx, httpClient: &http.Client{}}
+	body, err := json.Marshal(auth)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(authURL, "/")+"/auth/tokens", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Keystone authentication failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("Keystone authentication failed: %w", decodeError(resp))
+	}
+	var res authResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("Keystone authentication failed: %w", err)
+	}
+	c.token = resp.Header.Get("X-Subject-Token")
+	for _, service := range res.Token.Catalog {
+		if service.Type != objectStoreType {
+			continue
+		}
+		for _, endpoint := range service.Endpoints {
+			if endpoint.Interface == "public" && (region == "" || endpoint.Region == region || endpoint.RegionID == region) {
+				c.storageURL = strings.TrimSuffix(endpoint.URL, "/")
+				return c, nil
+			}
+		}
+	}
+	if region != "" {
+		return nil, fmt.Errorf("the Keystone service catalog has no public %s endpoint in region %s", objectStoreType, region)
+	}
+	return nil, fmt.Errorf("the Keystone service catalog has no public %s endpoint", objectStoreType)
+}
+
+func decodeError(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	e := &apiError{Status: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	if e.Status == http.StatusNotFound {
+		return common.NewNotFoundError(e)
+	}
+	return e
+}
+
+// objectURL returns the URL of an object, whose name is escaped except for the slashes of its "directories"
+func (c *client) objectURL(container, name string) string {
+	segments := strings.Split(name, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return c.storageURL + "/" + url.PathEscape(container) + "/" + strings.Join(segments, "/")
+}
+
+// do sends a request with the token, and returns the response if it has a success status, or otherwise the error
+// Swift responded with. The caller must close the body of the response.
+func (c *client) do(method, rawURL string, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(c.ctx, method, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("X-Auth-Token", c.token)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer func() { _ = resp.Body.Close() }()
+		return nil, decodeError(resp)
+	}
+	return resp, nil
+}
+
+// send sends a request with the token, and discards the body of the response
+func (c *client) send(method, rawURL string, body io.Reader, size int64, header http.Header) error {
+	resp, err := c.do(method, rawURL, body, size, header)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// head returns the headers of an object
+func (c *client) head(container, name string) (http.Header, error) {
+	resp, err := c.do(http.MethodHead, c.objectURL(container, name), nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	return resp.Header, nil
+}
+
+// isLargeObject returns whether the headers are those of a static or dynamic large object, whose content Swift
+// concatenates from its segments
+func isLargeObject(header http.Header) bool {
+	return strings.EqualFold(header.Get(staticLargeObjectHeader), "true") || header.Get(dynamicLargeObjectHeader) != ""
+}
+
+// download downloads an object, which may be a large object, and verifies the MD5 checksum of objects which are not
+func (c *client) download(container, name, localPath string) error {
+	resp, err := c.do(http.MethodGet, c.objectURL(container, name), nil, 0, nil)
+	if err != nil {
+		return fmt.Errorf("Swift download of %s failed: %w", name, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	out, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = out.Close() }()
+	h := md5.New()
+	if _, err := io.Copy(io.MultiWriter(out, h), resp.Body); err != nil {
+		return fmt.Errorf("Swift download of %s failed: %w", name, err)
+	}
+	// the ETag of a large object is not the checksum of its content
+	etag := strings.Trim(resp.Header.Get("Etag"), `"`)
+	if !isLargeObject(resp.Header) && etag != "" && !strings.EqualFold(etag, hex.EncodeToString(h.Sum(nil))) {
+		return fmt.Errorf("Swift download of %s failed: MD5 checksum mismatch", name)
+	}
+	return out.Close()
+}
+
+// put uploads a section of the file as an object, with its MD5 checksum, which Swift verifies
+func (c *client) put(container, name string, f *os.File, offset, size int64) (string, error) {
+	checksum, err := md5Section(f, offset, size)
+	if err != nil {
+		return "", err
+	}
+	err = c.send(http.MethodPut, c.objectURL(container, name), io.NewSectionReader(f, offset, size), size, http.Header{"Etag": {checksum}})
+	if err != nil {
+		return "", fmt.Errorf("Swift upload of %s failed: %w", name, err)
+	}
+	return checksum, nil
+}
+
+// createContainer creates a container, unless it exists
+func (c *client) createContainer(container string) error {
+	if err := c.send(http.MethodPut, c.storageURL+"/"+url.PathEscape(container), nil, 0, nil); err != nil {
+		return fmt.Errorf("Swift creation of container %s failed: %w", container, err)
+	}
+	return nil
+}
+
+// manifestSegment is a segment of the manifest of a static large object
+type manifestSegment struct {
+	Path      string `json:"path"`
+	ETag      string `json:"etag"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+// putLargeObject uploads a file as a static large object: its segments are uploaded to the segment container, as
+// the swift command line client names them, and then its manifest
+func (c *client) putLargeObject(container, name, segmentContainer string, f *os.File, size, segmentSize int64) error {
+	if err := c.createContainer(segmentContainer); err != nil {
+		return err
+	}
+	prefix := fmt.Sprintf("%s/slo/%d/%d/%d/", name, time.Now().UnixNano(), size, segmentSize)
+	var manifest []manifestSegment
+	for offset, i := int64(0), 0; offset < size; offset, i = offset+segmentSize, i+1 {
+		n := segmentSize
+		if offset+n > size {
+			n = size - offset
+		}
+		segment := fmt.Sprintf("%s%08d", prefix, i)
+		checksum, err := c.put(segmentContainer, segment, f, offset, n)
+		if err != nil {
+			return err
+		}
+		manifest = append(manifest, manifestSegment{Path: "/" + segmentContainer + "/" + segment, ETag: checksum, SizeBytes: n})
+	}
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	err = c.send(http.MethodPut, c.objectURL(container, name)+"?multipart-manifest=put", bytes.NewReader(body), int64(len(body)), http.Header{"Content-Type": {"application/json"}})
+	if err != nil {
+		return fmt.Errorf("Swift upload of the manifest of %s failed: %w", name, err)
+	}
+	return nil
+}
+
+// segments returns the paths, "/<container>/<name>", of the segments of a large object, if it is one
+func (c *client) segments(container, name string, header http.Header) ([]string, error) {
+	if strings.EqualFold(header.Get(staticLargeObjectHeader), "true") {
+		resp, err := c.do(http.MethodGet, c.objectURL(container, name)+"?multipart-manifest=get", nil, 0, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var manifest []struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+			return nil, fmt.Errorf("Swift manifest of %s is invalid: %w", name, err)
+		}
+		var paths []string
+		for _, segment := range manifest {
+			paths = append(paths, segment.Name)
+		}
+		return paths, nil
+	}
+	if manifest := header.Get(dynamicLargeObjectHeader); manifest != "" {
+		// e.g. "my-container_segments/my-key/", whose container and prefix are URL encoded
+		manifest, err := url.PathUnescape(manifest)
+		if err != nil {
+			return nil, fmt.Errorf("Swift manifest %s of %s is invalid: %w", manifest, name, err)
+		}
+		parts := strings.SplitN(manifest, "/", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Swift manifest %s of %s is invalid", manifest, name)
+		}
+		names, err := c.list(parts[0], parts[1])
+		if err != nil {
+			return nil, err
+		}
+		var paths []string
+		for _, segment := range names {
+			paths = append(paths, "/"+parts[0]+"/"+segment)
+		}
+		return paths, nil
+	}
+	return nil, nil
+}
+
+// list lists the names of the objects of a container with the prefix, a page at a time
+func (c *client) list(container, prefix string) ([]string, error) {
+	var names []string
+	marker := ""
+	for {
+		query := url.Values{"format": {"json"}, "prefix": {prefix}, "limit": {fmt.Sprint(listLimit)}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		resp, err := c.do(http.MethodGet, c.storageURL+"/"+url.PathEscape(container)+"?"+query.Encode(), nil, 0, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Swift list of container %s failed: %w", container, err)
+		}
+		var page []struct {
+			Name string `json:"name"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Swift list of container %s failed: %w", container, err)
+		}
+		for _, obj := range page {
+			names = append(names, obj.Name)
+		}
+		if len(page) < listLimit {
+			return names, nil
+		}
+		marker = page[len(page)-1].Name
+	}
+}
+
+// deletePaths deletes the objects at each of the paths, "/<container>/<name>", which have not been deleted already
+func (c *client) deletePaths(paths []string) error {
+	for _, p := range paths {
+		parts := strings.SplitN(strings.TrimPrefix(p, "/"), "/", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("Swift segment %s is invalid", p)
+		}
+		err := c.send(http.MethodDelete, c.objectURL(parts[0], parts[1]), nil, 0, nil)
+		if err != nil && !isNotFound(err) {
+			return fmt.Errorf("Swift delete of segment %s failed: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// delete deletes an object. The segments of a large object are deleted along with its manifest.
+func (c *client) delete(container, name string) error {
+	header, err := c.head(container, name)
+	if err != nil {
+		return err
+	}
+	segments, err := c.segments(container, name, header)
+	if err != nil {
+		return err
+	}
+	if err := c.send(http.MethodDelete, c.objectURL(container, name), nil, 0, nil); err != nil {
+		return err
+	}
+	return c.deletePaths(segments)
+}
+
+func isNotFound(err error) bool {
+	var e *apiError
+	return errors.As(err, &e) && e.Status == http.StatusNotFound
+}
+
+// md5Section returns the hex MD5 checksum of the section of the file
+func md5Section(f *os.File, offset, size int64) (string, error) {
+	h := md5.New()
+	if _, err := io.Copy(h, io.NewSectionReader(f, offset, size)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,192 @@
+package swift
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const (
+	// defaultSegmentSize is the size of the segments of large objects, unless the artifact sets one
+	defaultSegmentSize = 1024 * 1024 * 1024
+	// maxSegmentSize is the largest object Swift accepts with its default max_file_size
+	maxSegmentSize = 5 * 1024 * 1024 * 1024
+	// defaultDomainName is the name of the domain Keystone creates
+	defaultDomainName = "Default"
+)
+
+// ArtifactDriver is the artifact driver for OpenStack Swift, which authenticates with Keystone v3, with either a
+// password or an application credential
+type ArtifactDriver struct {
+	AuthURL                     string
+	Region                      string
+	Username                    string
+	Password                    string
+	ProjectName                 string
+	UserDomainName              string
+	ProjectDomainName           string
+	ApplicationCredentialID     string
+	ApplicationCredentialSecret string
+	// SegmentSize is the size of the segments files larger than it are uploaded in, defaultSegmentSize if not set
+	SegmentSize int64
+	// SegmentContainer is the container segments are uploaded to, "<container>_segments" if not set
+	SegmentContainer string
+	common.Logging
+}
+
+// ValidateArtifact validates the Swift artifact, beyond what the general artifact validation checks
+func ValidateArtifact(errPrefix string, art *wfv1.SwiftArtifact) error {
+	if _, err := ParseSegmentSize(art.SegmentSize); err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s.segmentSize %s", errPrefix, err.Error())
+	}
+	return nil
+}
+
+// ParseSegmentSize returns the segment size of a Swift artifact in bytes, or 0 if it is not set
+func ParseSegmentSize(size string) (int64, error) {
+	if size == "" {
+		return 0, nil
+	}
+	q, err := resource.ParseQuantity(size)
+	if err != nil {
+		return 0, errors.Errorf(errors.CodeBadRequest, "must be a quantity of bytes, e.g. 1Gi: %v", err)
+	}
+	if q.Value() < 1 || q.Value() > maxSegmentSize {
+		return 0, errors.Errorf(errors.CodeBadRequest, "must be between 1 byte and 5Gi")
+	}
+	return q.Value(), nil
+}
+
+func orDefault(name string) string {
+	if name == "" {
+		return defaultDomainName
+	}
+	return name
+}
+
+func (d *ArtifactDriver) authenticate(ctx context.Context) (*client, error) {
+	var auth authRequest
+	if d.ApplicationCredentialID != "" {
+		// an application credential is scoped to the project it was created in
+		auth.Auth.Identity.Methods = []string{"application_credential"}
+		auth.Auth.Identity.ApplicationCredential = &applicationCredential{ID: d.ApplicationCredentialID, Secret: d.ApplicationCredentialSecret}
+	} else {
+		password := &passwordIdentity{}
+		password.User.Name = d.Username
+		password.User.Domain.Name = orDefault(d.UserDomainName)
+		password.User.Password = d.Password
+		auth.Auth.Identity.Methods = []string{"password"}
+		auth.Auth.Identity.Password = password
+		auth.Auth.Scope = &scope{}
+		auth.Auth.Scope.Project.Name = d.ProjectName
+		auth.Auth.Scope.Project.Domain.Name = orDefault(d.ProjectDomainName)
+	}
+	return authenticate(ctx, d.AuthURL, d.Region, auth)
+}
+
+func (d *ArtifactDriver) segmentContainer(container string) string {
+	if d.SegmentContainer != "" {
+		return d.SegmentContainer
+	}
+	return container + "_segments"
+}
+
+// Load downloads an object from a Swift container. Large objects are downloaded as the concatenation of their
+// segments.
+func (d *ArtifactDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, localPath string) error {
+	d.Log().Infof("Swift Load path: %s, container: %s, key: %s", localPath, inputArtifact.Swift.Container, inputArtifact.Swift.Key)
+	c, err := d.authenticate(ctx)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(localPath), 0700); err != nil {
+		return err
+	}
+	if err := c.download(inputArtifact.Swift.Container, inputArtifact.Swift.Key, localPath); err != nil {
+		_ = os.Remove(localPath)
+		return err
+	}
+	return nil
+}
+
+// Save uploads a file to a Swift container. Files larger than the segment size are uploaded as static large
+// objects, whose segments are uploaded to the segment container. Directories cannot be uploaded, other than as
+// archives.
+func (d *ArtifactDriver) Save(ctx context.Context, localPath string, outputArtifact *wfv1.Artifact) error {
+	art := outputArtifact.Swift
+	d.Log().Infof("Swift Save path: %s, container: %s, key: %s", localPath, art.Container, art.Key)
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return errors.Errorf(errors.CodeBadRequest, "Swift cannot save the directory %s, which must be archived", localPath)
+	}
+	c, err := d.authenticate(ctx)
+	if err != nil {
+		return err
+	}
+	// the segments of the object being replaced, if it is a large object, are deleted once it has been
+	var oldSegments []string
+	header, err := c.head(art.Container, art.Key)
+	switch {
+	case err == nil:
+		oldSegments, err = c.segments(art.Container, art.Key, header)
+		if err != nil {
+			return err
+		}
+	case !isNotFound(err):
+		return err
+	}
+	segmentSize := d.SegmentSize
+	if segmentSize <= 0 {
+		segmentSize = defaultSegmentSize
+	}
+	if info.Size() > segmentSize {
+		d.Log().Debugf("Swift uploading %s as a static large object in segments of %d bytes", art.Key, segmentSize)
+		err = c.putLargeObject(art.Container, art.Key, d.segmentContainer(art.Container), f, info.Size(), segmentSize)
+	} else {
+		_, err = c.put(art.Container, art.Key, f, 0, info.Size())
+	}
+	if err != nil {
+		return err
+	}
+	if len(oldSegments) > 0 {
+		if err := c.deletePaths(oldSegments); err != nil {
+			d.Log().Warnf("Swift failed to delete the segments of the object %s replaced: %v", art.Key, err)
+		}
+	}
+	return nil
+}
+
+// Delete deletes an object from a Swift container, along with its segments if it is a large object
+func (d *ArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	d.Log().Infof("Swift Delete container: %s, key: %s", artifact.Swift.Container, artifact.Swift.Key)
+	c, err := d.authenticate(context.Background())
+	if err != nil {
+		return err
+	}
+	if err := c.delete(artifact.Swift.Container, artifact.Swift.Key); err != nil {
+		return fmt.Errorf("Swift delete of %s failed: %w", artifact.Swift.Key, err)
+	}
+	return nil
+}
+
+// Capabilities returns the optional operations on Swift artifacts the driver supports
+func (d *ArtifactDriver) Capabilities() common.DriverCapabilities {
+	return common.DriverCapabilities{
+		Delete: true,
+	}
+}
@@ -0,0 +1,387 @@
+package swift
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const (
+	testToken     = "my-token"
+	testContainer = "my-container"
+	testSegments  = "my-container_segments"
+	testRegion    = "RegionOne"
+	storagePath   = "/v1/AUTH_my-project"
+)
+
+// fakeObject is an object of fakeSwift, which is a static large object if it has segments, or a dynamic large
+// object if it has a manifest
+type fakeObject struct {
+	data     []byte
+	segments []string
+	manifest string
+}
+
+// fakeSwift is Keystone and Swift, with a single project in a single region
+type fakeSwift struct {
+	*httptest.Server
+	mu      sync.Mutex
+	objects map[string]*fakeObject
+	// the auth requests Keystone received
+	auths []authRequest
+}
+
+func newFakeSwift() *fakeSwift {
+	f := &fakeSwift{objects: map[string]*fakeObject{}}
+	f.Server = httptest.NewServer(f)
+	return f
+}
+
+func (f *fakeSwift) driver() *ArtifactDriver {
+	return &ArtifactDriver{AuthURL: f.URL + "/v3", Region: testRegion, Username: "my-user", Password: "my-password", ProjectName: "my-project"}
+}
+
+// content returns the content of an object, which is the concatenation of the segments of large objects
+func (f *fakeSwift) content(obj *fakeObject) []byte {
+	var segments []string
+	if obj.manifest != "" {
+		segments = f.list(obj.manifest)
+	} else {
+		segments = obj.segments
+	}
+	if len(segments) == 0 {
+		return obj.data
+	}
+	var data []byte
+	for _, s := range segments {
+		if segment, ok := f.objects[s]; ok {
+			data = append(data, segment.data...)
+		}
+	}
+	return data
+}
+
+// list returns the paths of the objects with the prefix, "<container>/<prefix>", in order
+func (f *fakeSwift) list(prefix string) []string {
+	var paths []string
+	for p := range f.objects {
+		if strings.HasPrefix(p, prefix) {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func md5Hex(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (f *fakeSwift) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path == "/v3/auth/tokens" {
+		f.authenticate(w, r)
+		return
+	}
+	if r.Header.Get("X-Auth-Token") != testToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, storagePath+"/") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	p := strings.TrimPrefix(r.URL.Path, storagePath+"/")
+	if !strings.Contains(p, "/") {
+		f.container(w, r, p)
+		return
+	}
+	obj, exists := f.objects[p]
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Query().Get("multipart-manifest") == "put" {
+			var manifest []manifestSegment
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			obj := &fakeObject{}
+			for _, s := range manifest {
+				segment, ok := f.objects[strings.TrimPrefix(s.Path, "/")]
+				if !ok || md5Hex(segment.data) != s.ETag || int64(len(segment.data)) != s.SizeBytes {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				obj.segments = append(obj.segments, strings.TrimPrefix(s.Path, "/"))
+			}
+			f.objects[p] = obj
+		} else {
+			if etag := r.Header.Get("Etag"); etag != "" && etag != md5Hex(data) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				return
+			}
+			f.objects[p] = &fakeObject{data: data, manifest: r.Header.Get(dynamicLargeObjectHeader)}
+		}
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodHead, http.MethodGet:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if len(obj.segments) > 0 {
+			w.Header().Set(staticLargeObjectHeader, "True")
+			if r.URL.Query().Get("multipart-manifest") == "get" {
+				var manifest []map[string]string
+				for _, s := range obj.segments {
+					manifest = append(manifest, map[string]string{"name": "/" + s})
+				}
+				_ = json.NewEncoder(w).Encode(manifest)
+				return
+			}
+		}
+		data := f.content(obj)
+		if obj.manifest != "" {
+			w.Header().Set(dynamicLargeObjectHeader, obj.manifest)
+		}
+		if len(obj.segments) > 0 || obj.manifest != "" {
+			// the ETag of a large object is that of its manifest
+			w.Header().Set("Etag", `"`+md5Hex([]byte("manifest"))+`"`)
+		} else {
+			w.Header().Set("Etag", md5Hex(data))
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	case http.MethodDelete:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.objects, p)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeSwift) authenticate(w http.ResponseWriter, r *http.Request) {
+	var auth authRequest
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&auth) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.auths = append(f.auths, auth)
+	identity := auth.Auth.Identity
+	switch {
+	case identity.Password != nil && identity.Password.User.Name == "my-user" && identity.Password.User.Password == "my-password" && auth.Auth.Scope != nil && auth.Auth.Scope.Project.Name == "my-project":
+	case identity.ApplicationCredential != nil && identity.ApplicationCredential.ID == "my-id" && identity.ApplicationCredential.Secret == "my-secret":
+	default:
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = fmt.Fprint(w, `{"error": {"code": 401, "message": "The request you have made requires authentication.", "title": "Unauthorized"}}`)
+		return
+	}
+	w.Header().Set("X-Subject-Token", testToken)
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"token": map[string]interface{}{"catalog": []interface{}{
+		map[string]interface{}{"type": "identity", "endpoints": []interface{}{
+			map[string]string{"interface": "public", "region": testRegion, "url": f.URL + "/v3"},
+		}},
+		map[string]interface{}{"type": objectStoreType, "endpoints": []interface{}{
+			map[string]string{"interface": "internal", "region": testRegion, "url": "http://swift.internal" + storagePath},
+			map[string]string{"interface": "public", "region": "RegionTwo", "url": "http://swift.region-two" + storagePath},
+			map[string]string{"interface": "public", "region": testRegion, "url": f.URL + storagePath},
+		}},
+	}}})
+}
+
+func (f *fakeSwift) container(w http.ResponseWriter, r *http.Request, container string) {
+	switch r.Method {
+	case http.MethodPut:
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet:
+		query := r.URL.Query()
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		page := []map[string]string{}
+		for _, p := range f.list(container + "/" + query.Get("prefix")) {
+			name := strings.TrimPrefix(p, container+"/")
+			if name > query.Get("marker") && (limit == 0 || len(page) < limit) {
+				page = append(page, map[string]string{"name": name})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(page)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func artifact(key string) *wfv1.Artifact {
+	return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{Swift: &wfv1.SwiftArtifact{Container: testContainer, Key: key}}}
+}
+
+func TestSwiftArtifactDriver(t *testing.T) {
+	f := newFakeSwift()
+	defer f.Close()
+	tmp, err := ioutil.TempDir("", "swift")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	small := filepath.Join(tmp, "small")
+	assert.NoError(t, ioutil.WriteFile(small, []byte("hello"), 0600))
+	large := filepath.Join(tmp, "large")
+	assert.NoError(t, ioutil.WriteFile(large, []byte("hello world"), 0600))
+
+	t.Run("Save", func(t *testing.T) {
+		driver := f.driver()
+		if assert.NoError(t, driver.Save(context.Background(), small, artifact("my-key"))) {
+			assert.Equal(t, "hello", string(f.objects[testContainer+"/my-key"].data))
+			assert.Empty(t, f.list(testSegments))
+			password := f.auths[len(f.auths)-1].Auth.Identity.Password
+			assert.Equal(t, defaultDomainName, password.User.Domain.Name)
+			assert.Equal(t, defaultDomainName, f.auths[len(f.auths)-1].Auth.Scope.Project.Domain.Name)
+		}
+		path := filepath.Join(tmp, "loaded", "small")
+		if assert.NoError(t, driver.Load(context.Background(), artifact("my-key"), path)) {
+			data, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, "hello", string(data))
+		}
+	})
+	t.Run("SaveSegmented", func(t *testing.T) {
+		driver := f.driver()
+		driver.SegmentSize = 4
+		if assert.NoError(t, driver.Save(context.Background(), large, artifact("my-large-key"))) {
+			obj := f.objects[testContainer+"/my-large-key"]
+			assert.Len(t, obj.segments, 3)
+			assert.Len(t, f.list(testSegments+"/my-large-key/slo/"), 3)
+			assert.Equal(t, "hello world", string(f.content(obj)))
+		}
+		path := filepath.Join(tmp, "loaded", "large")
+		if assert.NoError(t, driver.Load(context.Background(), artifact("my-large-key"), path)) {
+			data, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, "hello world", string(data))
+		}
+	})
+	t.Run("SegmentContainer", func(t *testing.T) {
+		driver := f.driver()
+		driver.SegmentSize = 4
+		driver.SegmentContainer = "my-other-segments"
+		if assert.NoError(t, driver.Save(context.Background(), large, artifact("my-other-key"))) {
+			assert.Len(t, f.list("my-other-segments/my-other-key/slo/"), 3)
+		}
+	})
+	t.Run("Overwrite", func(t *testing.T) {
+		driver := f.driver()
+		driver.SegmentSize = 4
+		assert.NoError(t, driver.Save(context.Background(), large, artifact("my-overwritten-key")))
+		assert.NoError(t, driver.Save(context.Background(), large, artifact("my-overwritten-key")))
+		// the segments of the replaced object are deleted, but not those of the new one
+		assert.Len(t, f.list(testSegments+"/my-overwritten-key/"), 3)
+		assert.Equal(t, "hello world", string(f.content(f.objects[testContainer+"/my-overwritten-key"])))
+		driver.SegmentSize = 0
+		assert.NoError(t, driver.Save(context.Background(), small, artifact("my-overwritten-key")))
+		assert.Empty(t, f.list(testSegments+"/my-overwritten-key/"))
+		assert.Equal(t, "hello", string(f.objects[testContainer+"/my-overwritten-key"].data))
+	})
+	t.Run("LoadDynamicLargeObject", func(t *testing.T) {
+		f.objects[testSegments+"/my-dlo/00000000"] = &fakeObject{data: []byte("dyn")}
+		f.objects[testSegments+"/my-dlo/00000001"] = &fakeObject{data: []byte("amic")}
+		f.objects[testContainer+"/my-dlo"] = &fakeObject{manifest: testSegments + "/my-dlo/"}
+		path := filepath.Join(tmp, "loaded", "dlo")
+		if assert.NoError(t, f.driver().Load(context.Background(), artifact("my-dlo"), path)) {
+			data, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, "dynamic", string(data))
+		}
+	})
+	t.Run("Delete", func(t *testing.T) {
+		driver := f.driver()
+		if assert.NoError(t, driver.Delete(artifact("my-key"))) {
+			assert.NotContains(t, f.objects, testContainer+"/my-key")
+		}
+		// the segments of large objects are deleted along with the manifest
+		if assert.NoError(t, driver.Delete(artifact("my-large-key"))) {
+			assert.NotContains(t, f.objects, testContainer+"/my-large-key")
+			assert.Empty(t, f.list(testSegments+"/my-large-key/"))
+		}
+		if assert.NoError(t, driver.Delete(artifact("my-dlo"))) {
+			assert.NotContains(t, f.objects, testContainer+"/my-dlo")
+			assert.Empty(t, f.list(testSegments+"/my-dlo/"))
+		}
+		err := driver.Delete(artifact("my-key"))
+		assert.True(t, errors.Is(err, common.ErrArtifactNotFound))
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		path := filepath.Join(tmp, "loaded", "missing")
+		err := f.driver().Load(context.Background(), artifact("missing"), path)
+		assert.True(t, errors.Is(err, common.ErrArtifactNotFound))
+		assert.NoFileExists(t, path)
+	})
+	t.Run("ApplicationCredential", func(t *testing.T) {
+		driver := &ArtifactDriver{AuthURL: f.URL + "/v3", Region: testRegion, ApplicationCredentialID: "my-id", ApplicationCredentialSecret: "my-secret"}
+		if assert.NoError(t, driver.Save(context.Background(), small, artifact("my-app-key"))) {
+			auth := f.auths[len(f.auths)-1].Auth
+			assert.Equal(t, []string{"application_credential"}, auth.Identity.Methods)
+			assert.Nil(t, auth.Scope)
+			assert.Equal(t, "hello", string(f.objects[testContainer+"/my-app-key"].data))
+		}
+	})
+	t.Run("Unauthorized", func(t *testing.T) {
+		driver := f.driver()
+		driver.Password = "wrong"
+		err := driver.Save(context.Background(), small, artifact("my-key"))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "401 Unauthorized")
+		}
+	})
+	t.Run("NoEndpoint", func(t *testing.T) {
+		driver := f.driver()
+		driver.Region = "RegionThree"
+		err := driver.Save(context.Background(), small, artifact("my-key"))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "no public object-store endpoint in region RegionThree")
+		}
+	})
+	t.Run("Directory", func(t *testing.T) {
+		err := f.driver().Save(context.Background(), tmp, artifact("my-dir"))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "which must be archived")
+		}
+	})
+}
+
+func TestParseSegmentSize(t *testing.T) {
+	size, err := ParseSegmentSize("")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+	size, err = ParseSegmentSize("100Mi")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100*1024*1024), size)
+	_, err = ParseSegmentSize("6Gi")
+	assert.Error(t, err)
+	_, err = ParseSegmentSize("lots")
+	assert.Error(t, err)
+}
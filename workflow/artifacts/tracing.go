@@ -0,0 +1,88 @@
+package executor
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+// tracerName is the name of the tracer of the spans of loads and saves, which is that of the package
+const tracerName = "github.com/argoproj/argo-workflows/v3/workflow/artifacts"
+
+// startSpan starts the span of an operation, e.g. Load, of the artifact, as a child of the span of ctx, if it has one,
+// with the tracer of its provider, so that it is exported as its parent is. Otherwise the tracer is that of the global
+// provider.
+func startSpan(ctx context.Context, operation string, art *wfv1.Artifact) (context.Context, trace.Span) {
+	provider := otel.GetTracerProvider()
+	if parent := trace.SpanFromContext(ctx); parent.SpanContext().IsValid() {
+		provider = parent.TracerProvider()
+	}
+	attributes := []attribute.KeyValue{
+		attribute.String("artifact.name", art.Name),
+		attribute.String("artifact.driver", driverName(art)),
+	}
+	if bucket := artifactBucket(art); bucket != "" {
+		attributes = append(attributes, attribute.String("artifact.bucket", bucket))
+	}
+	if host := artifactHost(art); host != "" {
+		attributes = append(attributes, attribute.String("artifact.host", host))
+	}
+	if key, err := art.GetKey(); err == nil && key != "" {
+		attributes = append(attributes, attribute.String("artifact.key", key))
+	}
+	return provider.Tracer(tracerName).Start(ctx, "artifact."+strings.ToLower(operation), trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+}
+
+// endSpan ends the span, recording the error, if there is one, or else the number of bytes transferred, unless it is
+// negative
+func endSpan(span trace.Span, err error, bytes int64) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else if bytes >= 0 {
+		span.SetAttributes(attribute.Int64("artifact.bytes", bytes))
+	}
+	span.End()
+}
+
+// artifactBucket returns the bucket of the artifact, if its location has one
+func artifactBucket(art *wfv1.Artifact) string {
+	switch {
+	case art.S3 != nil:
+		return art.S3.Bucket
+	case art.GCS != nil:
+		return art.GCS.Bucket
+	case art.OSS != nil:
+		return art.OSS.Bucket
+	case art.B2 != nil:
+		return art.B2.Bucket
+	}
+	return ""
+}
+
+// artifactHost returns the host of the URL of the artifact, if its location has one, without any credentials it has
+func artifactHost(art *wfv1.Artifact) string {
+	var rawURL string
+	switch {
+	case art.HTTP != nil:
+		rawURL = art.HTTP.URL
+	case art.Artifactory != nil:
+		rawURL = art.Artifactory.URL
+	case art.WebDAV != nil:
+		rawURL = art.WebDAV.URL
+	default:
+		return ""
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
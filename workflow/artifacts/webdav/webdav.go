@@ -0,0 +1,380 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/argoproj/pkg/file"
+
+	"github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+const (
+	methodPropfind = "PROPFIND"
+	methodMkcol    = "MKCOL"
+	// the number of redirects a PROPFIND request follows
+	maxRedirects = 10
+)
+
+// propfindBody requests only the resource type, which tells collections and resources apart
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?><D:propfind xmlns:D="DAV:"><D:prop><D:resourcetype/></D:prop></D:propfind>`
+
+// ArtifactDriver is the artifact driver for a WebDAV server
+type ArtifactDriver struct {
+	Username string
+	Password string
+	// ProxyURL is the proxy requests are sent through, if set, rather than that of the environment
+	ProxyURL *url.URL
+	common.Logging
+	common.Transporting
+}
+
+func (d *ArtifactDriver) client() *http.Client {
+	if d.ProxyURL == nil {
+		return &http.Client{Transport: d.RoundTripper(nil)}
+	}
+	return &http.Client{Transport: d.RoundTripper(common.NewTransport(nil, d.ProxyURL))}
+}
+
+// multistatus is the body of the 207 Multi-Status response to a PROPFIND request
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Collection *struct{} `xml:"DAV: prop>resourcetype>collection"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// entry is a resource or collection listed by a PROPFIND request
+type entry struct {
+	// the unescaped path of the entry. Servers usually, but not always, end the paths of collections with a slash.
+	path       string
+	collection bool
+}
+
+func (d *ArtifactDriver) newRequest(ctx context.Context, method string, u *url.URL, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if d.Username != "" || d.Password != "" {
+		req.SetBasicAuth(d.Username, d.Password)
+	}
+	return req, nil
+}
+
+// propfind lists the resource or collection at the URL, and with depth "1" the members of a collection too.
+// Redirects are followed with PROPFIND, because servers commonly redirect collection URLs without a trailing
+// slash, which the HTTP client would otherwise follow with GET.
+func (d *ArtifactDriver) propfind(ctx context.Context, u *url.URL, depth string) ([]entry, error) {
+	client := d.client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	for i := 0; ; i++ {
+		req, err := d.newRequest(ctx, methodPropfind, u, strings.NewReader(propfindBody))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Depth", depth)
+		req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+		res, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		location := res.Header.Get("Location")
+		if res.StatusCode >= 300 && res.StatusCode < 400 && location != "" && i < maxRedirects {
+			_ = res.Body.Close()
+			u, err = u.Parse(location)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		defer func() { _ = res.Body.Close() }()
+		if res.StatusCode == http.StatusNotFound {
+			return nil, common.NewNotFoundError(fmt.Errorf("WebDAV PROPFIND %s failed with reason:%s", u, res.Status))
+		}
+		if res.StatusCode != http.StatusMultiStatus {
+			return nil, errors.InternalErrorf("WebDAV PROPFIND %s failed with reason:%s", u, res.Status)
+		}
+		var status multistatus
+		if err := xml.NewDecoder(res.Body).Decode(&status); err != nil {
+			return nil, errors.InternalErrorf("WebDAV PROPFIND %s returned an invalid response: %v", u, err)
+		}
+		entries := make([]entry, 0, len(status.Responses))
+		for _, r := range status.Responses {
+			// hrefs are either absolute paths or absolute URLs
+			href, err := u.Parse(strings.TrimSpace(r.Href))
+			if err != nil {
+				return nil, errors.InternalErrorf("WebDAV PROPFIND %s returned an invalid href %s: %v", u, r.Href, err)
+			}
+			e := entry{path: href.Path}
+			for _, p := range r.Propstat {
+				e.collection = e.collection || p.Collection != nil
+			}
+			entries = append(entries, e)
+		}
+		return entries, nil
+	}
+}
+
+// withTrailingSlash returns the URL of a collection, which some servers require to end with a slash
+func withTrailingSlash(u *url.URL) *url.URL {
+	c := *u
+	if !strings.HasSuffix(c.Path, "/") {
+		c.Path += "/"
+		c.RawPath = ""
+	}
+	return &c
+}
+
+// parent returns the URL of the collection containing the URL, or false if the URL is the root collection
+func parent(u *url.URL) (*url.URL, bool) {
+	p := strings.TrimSuffix(u.Path, "/")
+	if p == "" {
+		return nil, false
+	}
+	return member(u, ".."), true
+}
+
+// member returns the URL of the member of the collection at the URL with the slash separated relative path
+func member(u *url.URL, rel string) *url.URL {
+	c := *u
+	c.Path = path.Join(u.Path, rel)
+	c.RawPath = ""
+	return &c
+}
+
+// Load downloads a resource from a WebDAV server. Collections are downloaded as directories.
+func (d *ArtifactDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, localPath string) error {
+	d.Log().Infof("WebDAV Load path: %s, url: %s", localPath, common.RedactURL(inputArtifact.WebDAV.URL))
+	u, err := url.Parse(inputArtifact.WebDAV.URL)
+	if err != nil {
+		return err
+	}
+	entries, err := d.propfind(ctx, u, "0")
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return errors.InternalErrorf("WebDAV PROPFIND %s returned no response", u)
+	}
+	if !entries[0].collection {
+		return d.download(ctx, u, localPath)
+	}
+	return d.loadCollection(ctx, u, localPath)
+}
+
+func (d *ArtifactDriver) loadCollection(ctx context.Context, u *url.URL, localPath string) error {
+	if err := os.MkdirAll(localPath, 0700); err != nil {
+		return err
+	}
+	u = withTrailingSlash(u)
+	entries, err := d.propfind(ctx, u, "1")
+	if err != nil {
+		return err
+	}
+	dir := strings.TrimSuffix(u.Path, "/")
+	for _, e := range entries {
+		// the collection itself, and anything which is not one of its members, is left with a slash or no name
+		name := strings.TrimPrefix(strings.TrimSuffix(e.path, "/"), dir+"/")
+		if name == "" || strings.Contains(name, "/") {
+			continue
+		}
+		dest := filepath.Join(localPath, name)
+		if e.collection {
+			err = d.loadCollection(ctx, member(u, name), dest)
+		} else {
+			err = d.download(ctx, member(u, name), dest)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *ArtifactDriver) download(ctx context.Context, u *url.URL, localPath string) error {
+	req, err := d.newRequest(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	res, err := d.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode == http.StatusNotFound {
+		return common.NewNotFoundError(fmt.Errorf("WebDAV GET %s failed with reason:%s", u, res.Status))
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.InternalErrorf("WebDAV GET %s failed with reason:%s", u, res.Status)
+	}
+	out, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("create %s: %v", localPath, err)
+	}
+	defer func() { _ = out.Close() }()
+	_, err = io.Copy(out, res.Body)
+	return err
+}
+
+// Save uploads a file or directory to a WebDAV server. Directories are uploaded as collections, and any
+// missing parent collections are created.
+func (d *ArtifactDriver) Save(ctx context.Context, localPath string, outputArtifact *wfv1.Artifact) error {
+	d.Log().Infof("WebDAV Save path: %s, url: %s", localPath, common.RedactURL(outputArtifact.WebDAV.URL))
+	u, err := url.Parse(outputArtifact.WebDAV.URL)
+	if err != nil {
+		return err
+	}
+	isDir, err := file.IsDirectory(localPath)
+	if err != nil {
+		return err
+	}
+	if !isDir {
+		return d.upload(ctx, u, localPath)
+	}
+	return filepath.Walk(localPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localPath, p)
+		if err != nil {
+			return err
+		}
+		dest := member(u, filepath.ToSlash(rel))
+		if info.IsDir() {
+			return d.mkcol(ctx, dest)
+		}
+		return d.upload(ctx, dest, p)
+	})
+}
+
+// mkcol creates the collection at the URL, and its missing parents. It is not an error if it exists.
+func (d *ArtifactDriver) mkcol(ctx context.Context, u *url.URL) error {
+	u = withTrailingSlash(u)
+	req, err := d.newRequest(ctx, methodMkcol, u, nil)
+	if err != nil {
+		return err
+	}
+	res, err := d.client().Do(req)
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return nil
+	// MKCOL is not allowed on URLs which are already mapped
+	case res.StatusCode == http.StatusMethodNotAllowed:
+		return nil
+	// the parent collection does not exist
+	case res.StatusCode == http.StatusConflict:
+		if p, ok := parent(u); ok {
+			if err := d.mkcol(ctx, p); err != nil {
+				return err
+			}
+			return d.mkcol(ctx, u)
+		}
+	}
+	return errors.InternalErrorf("WebDAV MKCOL %s failed with reason:%s", u, res.Status)
+}
+
+func (d *ArtifactDriver) upload(ctx context.Context, u *url.URL, localPath string) error {
+	status, err := d.put(ctx, u, localPath)
+	if err != nil {
+		return err
+	}
+	// the parent collection does not exist. RFC 4918 specifies 409 Conflict, but some servers respond 404 Not Found.
+	if p, ok := parent(u); ok && (status == http.StatusConflict || status == http.StatusNotFound) {
+		if err := d.mkcol(ctx, p); err != nil {
+			return err
+		}
+		status, err = d.put(ctx, u, localPath)
+		if err != nil {
+			return err
+		}
+	}
+	if status < 200 || status >= 300 {
+		return errors.InternalErrorf("WebDAV PUT %s failed with reason:%s", u, http.StatusText(status))
+	}
+	return nil
+}
+
+func (d *ArtifactDriver) put(ctx context.Context, u *url.URL, localPath string) (int, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = f.Close() }()
+	req, err := d.newRequest(ctx, http.MethodPut, u, f)
+	if err != nil {
+		return 0, err
+	}
+	res, err := d.client().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_ = res.Body.Close()
+	return res.StatusCode, nil
+}
+
+// Exists sends a PROPFIND request for the resource or collection
+func (d *ArtifactDriver) Exists(artifact *wfv1.Artifact) (bool, error) {
+	u, err := url.Parse(artifact.WebDAV.URL)
+	if err != nil {
+		return false, err
+	}
+	_, err = d.propfind(context.Background(), u, "0")
+	if err == nil {
+		return true, nil
+	}
+	if stderrors.Is(err, common.ErrArtifactNotFound) {
+		return false, nil
+	}
+	return false, err
+}
+
+// Delete removes a resource or collection from a WebDAV server. Collections are removed with their members.
+func (d *ArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	d.Log().Infof("WebDAV Delete url: %s", common.RedactURL(artifact.WebDAV.URL))
+	u, err := url.Parse(artifact.WebDAV.URL)
+	if err != nil {
+		return err
+	}
+	req, err := d.newRequest(context.Background(), http.MethodDelete, u, nil)
+	if err != nil {
+		return err
+	}
+	res, err := d.client().Do(req)
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.InternalErrorf("WebDAV DELETE %s failed with reason:%s", u, res.Status)
+	}
+	return nil
+}
+
+// Capabilities returns the optional operations on WebDAV artifacts the driver supports
+func (d *ArtifactDriver) Capabilities() common.DriverCapabilities {
+	return common.DriverCapabilities{
+		Delete: true,
+		Exists: true,
+	}
+}
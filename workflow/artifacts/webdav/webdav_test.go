@@ -0,0 +1,139 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	davserver "golang.org/x/net/webdav"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+)
+
+// newServer starts a WebDAV server which requires basic auth, and, like many servers, redirects
+// requests for collections without a trailing slash
+func newServer() *httptest.Server {
+	fs := davserver.NewMemFS()
+	handler := &davserver.Handler{FileSystem: fs, LockSystem: davserver.NewMemLS()}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "my-username" || password != "my-password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == methodPropfind && !strings.HasSuffix(r.URL.Path, "/") {
+			if info, err := fs.Stat(r.Context(), r.URL.Path); err == nil && info.IsDir() {
+				http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+				return
+			}
+		}
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+func artifact(u string) *wfv1.Artifact {
+	return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{WebDAV: &wfv1.WebDAVArtifact{URL: u}}}
+}
+
+func TestArtifactDriver(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+	tmp, err := ioutil.TempDir("", "webdav")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+	ctx := context.Background()
+	d := &ArtifactDriver{Username: "my-username", Password: "my-password"}
+
+	t.Run("File", func(t *testing.T) {
+		src := filepath.Join(tmp, "src-file")
+		assert.NoError(t, ioutil.WriteFile(src, []byte("my-data"), 0600))
+		// the parent collections do not exist yet
+		art := artifact(server.URL + "/my-parent/my-sub/my-file")
+		assert.NoError(t, d.Save(ctx, src, art))
+		dest := filepath.Join(tmp, "dest-file")
+		if assert.NoError(t, d.Load(ctx, art, dest)) {
+			data, err := ioutil.ReadFile(dest)
+			assert.NoError(t, err)
+			assert.Equal(t, "my-data", string(data))
+		}
+		ok, err := d.Exists(art)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+	t.Run("Directory", func(t *testing.T) {
+		src := filepath.Join(tmp, "src-dir")
+		assert.NoError(t, os.MkdirAll(filepath.Join(src, "sub", "empty"), 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "a"), []byte("a"), 0600))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "sub", "b"), []byte("b"), 0600))
+		art := artifact(server.URL + "/my-dir")
+		assert.NoError(t, d.Save(ctx, src, art))
+		// saving again finds the collections exist
+		assert.NoError(t, d.Save(ctx, src, art))
+		for _, u := range []string{server.URL + "/my-dir", server.URL + "/my-dir/"} {
+			dest, err := ioutil.TempDir(tmp, "dest-dir")
+			assert.NoError(t, err)
+			if assert.NoError(t, d.Load(ctx, artifact(u), dest)) {
+				data, err := ioutil.ReadFile(filepath.Join(dest, "a"))
+				assert.NoError(t, err)
+				assert.Equal(t, "a", string(data))
+				data, err = ioutil.ReadFile(filepath.Join(dest, "sub", "b"))
+				assert.NoError(t, err)
+				assert.Equal(t, "b", string(data))
+				assert.DirExists(t, filepath.Join(dest, "sub", "empty"))
+			}
+		}
+		assert.NoError(t, d.Delete(art))
+		ok, err := d.Exists(art)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		// deleting again is not an error
+		assert.NoError(t, d.Delete(art))
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		err := d.Load(ctx, artifact(server.URL+"/missing"), filepath.Join(tmp, "missing"))
+		assert.True(t, errors.Is(err, common.ErrArtifactNotFound))
+	})
+	t.Run("ProxyURL", func(t *testing.T) {
+		// the server handles the requests it is sent as a proxy for another host
+		proxyURL, err := url.Parse(server.URL)
+		assert.NoError(t, err)
+		d := &ArtifactDriver{Username: "my-username", Password: "my-password", ProxyURL: proxyURL}
+		dest := filepath.Join(tmp, "proxied")
+		if assert.NoError(t, d.Load(ctx, artifact("http://webdav.example.com/my-parent/my-sub/my-file"), dest)) {
+			data, err := ioutil.ReadFile(dest)
+			assert.NoError(t, err)
+			assert.Equal(t, "my-data", string(data))
+		}
+	})
+	t.Run("Unauthorized", func(t *testing.T) {
+		d := &ArtifactDriver{Username: "my-username", Password: "wrong"}
+		err := d.Load(ctx, artifact(server.URL+"/my-parent/my-sub/my-file"), filepath.Join(tmp, "unauthorized"))
+		assert.Error(t, err)
+		assert.False(t, errors.Is(err, common.ErrArtifactNotFound))
+	})
+}
+
+func TestURLs(t *testing.T) {
+	u, err := url.Parse("https://example.com/dav/my%20dir")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/dav/my%20dir/", withTrailingSlash(u).String())
+	assert.Equal(t, "https://example.com/dav/my%20dir/sub/my%20file", member(u, "sub/my file").String())
+	p, ok := parent(u)
+	if assert.True(t, ok) {
+		assert.Equal(t, "https://example.com/dav", p.String())
+	}
+	p, ok = parent(withTrailingSlash(p))
+	if assert.True(t, ok) {
+		assert.Equal(t, "https://example.com/", p.String())
+	}
+	_, ok = parent(p)
+	assert.False(t, ok)
+}
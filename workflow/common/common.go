@@ -117,6 +117,26 @@ const (
 	EnvVarKubeletInsecure = "ARGO_KUBELET_INSECURE"
 	// EnvVarArgoTrace is used enable tracing statements in Argo components
 	EnvVarArgoTrace = "ARGO_TRACE"
+	// EnvVarArtifactCacheDir is the directory the executor caches the input artifacts it loads in, e.g. a volume
+	// which the init containers of a pod share. Input artifacts are not cached if it is not set.
+	EnvVarArtifactCacheDir = "ARGO_ARTIFACT_CACHE_DIR"
+	// EnvVarArtifactCacheMaxSize is the max size of the artifact cache, e.g. "10Gi", which defaults to 1Gi
+	EnvVarArtifactCacheMaxSize = "ARGO_ARTIFACT_CACHE_MAX_SIZE"
+	// EnvVarArtifactBandwidthLimit is the bandwidth limit in bytes per second of the artifacts the executor loads and
+	// saves which do not set one, e.g. "10Mi". They are not limited if it is not set.
+	EnvVarArtifactBandwidthLimit = "ARGO_ARTIFACT_BANDWIDTH_LIMIT"
+	// EnvVarArtifactMaxSize is the max size of the artifacts the executor loads and saves which do not set one, e.g.
+	// "10Gi". Their sizes are not limited if it is not set.
+	EnvVarArtifactMaxSize = "ARGO_ARTIFACT_MAX_SIZE"
+	// EnvVarArtifactTempDir is the directory artifact drivers stage the intermediate files they buffer to disk in,
+	// e.g. a volume with more space than the default directory for temporary files
+	EnvVarArtifactTempDir = "ARGO_ARTIFACT_TEMP_DIR"
+	// EnvVarArtifactCircuitBreakerFailureRate is the fraction of the recent requests of artifact drivers to an
+	// endpoint which must fail for its circuit breaker to open, e.g. "0.5". Requests are not broken if it is not set.
+	EnvVarArtifactCircuitBreakerFailureRate = "ARGO_ARTIFACT_CIRCUIT_BREAKER_FAILURE_RATE"
+	// EnvVarArtifactCircuitBreakerCooldown is how long an open circuit breaker rejects requests, e.g. "1m", which
+	// defaults to 30s
+	EnvVarArtifactCircuitBreakerCooldown = "ARGO_ARTIFACT_CIRCUIT_BREAKER_COOLDOWN"
 
 	// ContainerRuntimeExecutorDocker to use docker as container runtime executor
 	ContainerRuntimeExecutorDocker = "docker"
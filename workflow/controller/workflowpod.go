@@ -1144,9 +1144,11 @@ func createArchiveLocationSecret(tmpl *wfv1.Template, volMap map[string]apiv1.Vo
 	if s3ArtRepo := tmpl.ArchiveLocation.S3; s3ArtRepo != nil {
 		createSecretVal(volMap, s3ArtRepo.AccessKeySecret, uniqueKeyMap)
 		createSecretVal(volMap, s3ArtRepo.SecretKeySecret, uniqueKeyMap)
+		createSecretVal(volMap, s3ArtRepo.CASecret, uniqueKeyMap)
 	} else if hdfsArtRepo := tmpl.ArchiveLocation.HDFS; hdfsArtRepo != nil {
 		createSecretVal(volMap, hdfsArtRepo.KrbKeytabSecret, uniqueKeyMap)
 		createSecretVal(volMap, hdfsArtRepo.KrbCCacheSecret, uniqueKeyMap)
+		createSecretVal(volMap, hdfsArtRepo.KrbConfigSecret, uniqueKeyMap)
 	} else if artRepo := tmpl.ArchiveLocation.Artifactory; artRepo != nil {
 		createSecretVal(volMap, artRepo.UsernameSecret, uniqueKeyMap)
 		createSecretVal(volMap, artRepo.PasswordSecret, uniqueKeyMap)
@@ -1157,8 +1159,10 @@ func createArchiveLocationSecret(tmpl *wfv1.Template, volMap map[string]apiv1.Vo
 	} else if ossRepo := tmpl.ArchiveLocation.OSS; ossRepo != nil {
 		createSecretVal(volMap, ossRepo.AccessKeySecret, uniqueKeyMap)
 		createSecretVal(volMap, ossRepo.SecretKeySecret, uniqueKeyMap)
+		createSecretVal(volMap, ossRepo.SecurityTokenSecret, uniqueKeyMap)
 	} else if gcsRepo := tmpl.ArchiveLocation.GCS; gcsRepo != nil {
 		createSecretVal(volMap, gcsRepo.ServiceAccountKeySecret, uniqueKeyMap)
+		createSecretVal(volMap, gcsRepo.EncryptionKeySecret, uniqueKeyMap)
 	}
 }
 
@@ -1166,6 +1170,12 @@ func createSecretVolume(volMap map[string]apiv1.Volume, art wfv1.Artifact, keyMa
 	if art.S3 != nil {
 		createSecretVal(volMap, art.S3.AccessKeySecret, keyMap)
 		createSecretVal(volMap, art.S3.SecretKeySecret, keyMap)
+		createSecretVal(volMap, art.S3.CASecret, keyMap)
+	} else if art.HTTP != nil {
+		createSecretVal(volMap, art.HTTP.CASecret, keyMap)
+		createSecretVal(volMap, art.HTTP.BearerTokenSecret, keyMap)
+		createSecretVal(volMap, art.HTTP.UsernameSecret, keyMap)
+		createSecretVal(volMap, art.HTTP.PasswordSecret, keyMap)
 	} else if art.Git != nil {
 		createSecretVal(volMap, art.Git.UsernameSecret, keyMap)
 		createSecretVal(volMap, art.Git.PasswordSecret, keyMap)
@@ -1176,11 +1186,56 @@ func createSecretVolume(volMap map[string]apiv1.Volume, art wfv1.Artifact, keyMa
 	} else if art.HDFS != nil {
 		createSecretVal(volMap, art.HDFS.KrbCCacheSecret, keyMap)
 		createSecretVal(volMap, art.HDFS.KrbKeytabSecret, keyMap)
+		createSecretVal(volMap, art.HDFS.KrbConfigSecret, keyMap)
 	} else if art.OSS != nil {
 		createSecretVal(volMap, art.OSS.AccessKeySecret, keyMap)
 		createSecretVal(volMap, art.OSS.SecretKeySecret, keyMap)
+		createSecretVal(volMap, art.OSS.SecurityTokenSecret, keyMap)
 	} else if art.GCS != nil {
 		createSecretVal(volMap, art.GCS.ServiceAccountKeySecret, keyMap)
+		createSecretVal(volMap, art.GCS.EncryptionKeySecret, keyMap)
+	} else if art.GDrive != nil {
+		createSecretVal(volMap, art.GDrive.ServiceAccountKeySecret, keyMap)
+	} else if art.SFTP != nil {
+		createSecretVal(volMap, art.SFTP.UsernameSecret, keyMap)
+		createSecretVal(volMap, art.SFTP.PasswordSecret, keyMap)
+		createSecretVal(volMap, art.SFTP.SSHPrivateKeySecret, keyMap)
+	} else if art.WebDAV != nil {
+		createSecretVal(volMap, art.WebDAV.UsernameSecret, keyMap)
+		createSecretVal(volMap, art.WebDAV.PasswordSecret, keyMap)
+	} else if art.FTP != nil {
+		createSecretVal(volMap, art.FTP.UsernameSecret, keyMap)
+		createSecretVal(volMap, art.FTP.PasswordSecret, keyMap)
+		createSecretVal(volMap, art.FTP.CASecret, keyMap)
+	} else if art.B2 != nil {
+		createSecretVal(volMap, art.B2.ApplicationKeyIDSecret, keyMap)
+		createSecretVal(volMap, art.B2.ApplicationKeySecret, keyMap)
+	} else if art.Swift != nil {
+		createSecretVal(volMap, art.Swift.UsernameSecret, keyMap)
+		createSecretVal(volMap, art.Swift.PasswordSecret, keyMap)
+		createSecretVal(volMap, art.Swift.ProjectSecret, keyMap)
+		createSecretVal(volMap, art.Swift.ApplicationCredentialIDSecret, keyMap)
+		createSecretVal(volMap, art.Swift.ApplicationCredentialSecretSecret, keyMap)
+	} else if art.Rclone != nil {
+		createSecretVal(volMap, art.Rclone.ConfigSecret, keyMap)
+	} else if art.Dropbox != nil {
+		createSecretVal(volMap, art.Dropbox.AppKeySecret, keyMap)
+		createSecretVal(volMap, art.Dropbox.AppSecretSecret, keyMap)
+		createSecretVal(volMap, art.Dropbox.RefreshTokenSecret, keyMap)
+	} else if art.IPFS != nil {
+		createSecretVal(volMap, art.IPFS.UsernameSecret, keyMap)
+		createSecretVal(volMap, art.IPFS.PasswordSecret, keyMap)
+		createSecretVal(volMap, art.IPFS.BearerTokenSecret, keyMap)
+	} else if art.ADLS != nil {
+		createSecretVal(volMap, art.ADLS.AccountKeySecret, keyMap)
+		createSecretVal(volMap, art.ADLS.SASTokenSecret, keyMap)
+	} else if art.HuggingFace != nil {
+		createSecretVal(volMap, art.HuggingFace.TokenSecret, keyMap)
+	}
+	if art.Mirrors != nil {
+		for _, location := range art.Mirrors.Locations {
+			createSecretVolume(volMap, wfv1.Artifact{ArtifactLocation: location}, keyMap)
+		}
 	}
 }
 
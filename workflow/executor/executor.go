@@ -34,6 +34,8 @@ import (
 	"github.com/argoproj/argo-workflows/v3/util/retry"
 	waitutil "github.com/argoproj/argo-workflows/v3/util/wait"
 	artifact "github.com/argoproj/argo-workflows/v3/workflow/artifacts"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/cache"
+	artifactscommon "github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
 	"github.com/argoproj/argo-workflows/v3/workflow/common"
 	os_specific "github.com/argoproj/argo-workflows/v3/workflow/executor/os-specific"
 )
@@ -66,6 +68,8 @@ type WorkflowExecutor struct {
 	PodAnnotationsPath string
 	ExecutionControl   *common.ExecutionControl
 	RuntimeExecutor    ContainerRuntimeExecutor
+	// ArtifactCache caches the input artifacts loaded, if it is set
+	ArtifactCache *cache.Cache
 
 	// memoized configmaps
 	memoizedConfigMaps map[string]string
@@ -177,7 +181,9 @@ func (we *WorkflowExecutor) LoadArtifacts(ctx context.Context) error {
 		// the file is a tarball or not. If it is, it is first extracted then renamed to
 		// the desired location. If not, it is simply renamed to the location.
 		tempArtPath := artPath + ".tmp"
-		err = artDriver.Load(driverArt, tempArtPath)
+		// Artifacts loaded before, e.g. by another init container, are copied from the cache, if there is one.
+		// An artifact with fallbacks is loaded from the first of its locations it can be loaded from.
+		source, err := artifact.LoadWithFallbacks(ctx, we.ArtifactCache, artDriver, we, driverArt, tempArtPath, logProgress("Downloaded", art.Name))
 		if err != nil {
 			if art.Optional && errors.IsCode(errors.CodeNotFound, err) {
 				log.Infof("Skipping optional input artifact that was not found: %s", art.Name)
@@ -185,6 +191,10 @@ func (we *WorkflowExecutor) LoadArtifacts(ctx context.Context) error {
 			}
 			return err
 		}
+		if art.Fallbacks != nil {
+			log.Infof("Loaded input artifact %s from its %s", art.Name, source.Location)
+		}
+		driverArt = source.Artifact
 
 		isTar := false
 		isZip := false
@@ -198,7 +208,7 @@ func (we *WorkflowExecutor) LoadArtifacts(ctx context.Context) error {
 		} else if art.GetArchive().Zip != nil {
 			// explicitly a zip
 			isZip = true
-		} else {
+		} else if art.ExtractPath == "" {
 			// auto-detect if tarball
 			// (don't try to autodetect zip files for backwards compatibility)
 			isTar, err = isTarball(tempArtPath)
@@ -207,7 +217,10 @@ func (we *WorkflowExecutor) LoadArtifacts(ctx context.Context) error {
 			}
 		}
 
-		if isTar {
+		if art.ExtractPath != "" {
+			err = extractArtifactPath(driverArt, tempArtPath, artPath)
+			_ = os.Remove(tempArtPath)
+		} else if isTar {
 			err = untar(tempArtPath, artPath)
 			_ = os.Remove(tempArtPath)
 		} else if isZip {
@@ -294,7 +307,8 @@ func (we *WorkflowExecutor) saveArtifact(ctx context.Context, containerName stri
 
 // fileBase is probably path.Base(filePath), but can be something else
 func (we *WorkflowExecutor) saveArtifactFromFile(ctx context.Context, art *wfv1.Artifact, fileName, localArtPath string) error {
-	if !art.HasKey() {
+	// an IPFS artifact has no key until it is saved, as its key is the CID it is saved as
+	if !art.HasKey() && art.IPFS == nil {
 		key, err := we.Template.ArchiveLocation.GetKey()
 		if err != nil {
 			return err
@@ -310,19 +324,53 @@ func (we *WorkflowExecutor) saveArtifactFromFile(ctx context.Context, art *wfv1.
 	if err != nil {
 		return err
 	}
+	if driverArt.Idempotency != nil && driverArt.Idempotency.Key == "" {
+		driverArt.Idempotency.Key = artifactscommon.DeriveIdempotencyKey(we.Namespace, we.nodeName(), art.Name)
+	}
 	artDriver, err := we.InitDriver(ctx, driverArt)
 	if err != nil {
 		return err
 	}
-	err = artDriver.Save(localArtPath, driverArt)
+	// the artifact is also saved to its mirror locations, if it has any, with drivers created like its own
+	err = artifact.SaveMirrored(ctx, artDriver, we, localArtPath, driverArt, logProgress("Uploaded", art.Name))
 	if err != nil {
 		return err
 	}
+	if art.IPFS != nil {
+		art.IPFS.CID = driverArt.IPFS.CID
+	}
+	if art.ExpandEnv {
+		// the output is recorded where it was saved, as the environment of whatever loads it is not that of the executor
+		art.ArtifactLocation = driverArt.ArtifactLocation
+		art.Mirrors = driverArt.Mirrors
+		art.ExpandEnv = false
+	}
 	we.maybeDeleteLocalArtPath(localArtPath)
 	log.Infof("Successfully saved file: %s", localArtPath)
 	return nil
 }
 
+// nodeName returns the name of the node of the pod, from its annotation, or otherwise the name of the pod
+func (we *WorkflowExecutor) nodeName() string {
+	nodeName, err := annotationField(we.PodAnnotationsPath, common.AnnotationKeyNodeName)
+	if err != nil || nodeName == "" {
+		log.Warnf("Failed to read the node name of the pod, using the pod name: %v", err)
+		return we.PodName
+	}
+	return nodeName
+}
+
+// logProgress returns a function which logs the progress of the transfer of an artifact
+func logProgress(verb, name string) artifactscommon.ProgressFunc {
+	return func(bytesTransferred, totalBytes int64) {
+		if totalBytes < 0 {
+			log.Infof("%s %d bytes of artifact %s", verb, bytesTransferred, name)
+		} else {
+			log.Infof("%s %d of %d bytes of artifact %s", verb, bytesTransferred, totalBytes, name)
+		}
+	}
+}
+
 func (we *WorkflowExecutor) maybeDeleteLocalArtPath(localArtPath string) {
 	if os.Getenv("REMOVE_LOCAL_ART_PATH") == "true" {
 		log.WithField("localArtPath", localArtPath).Info("deleting local artifact")
@@ -601,6 +649,11 @@ func (we *WorkflowExecutor) getPod(ctx context.Context) (*apiv1.Pod, error) {
 	return pod, nil
 }
 
+// Logger returns the logger of the artifact drivers of the executor, with the fields of its pod
+func (we *WorkflowExecutor) Logger() log.FieldLogger {
+	return log.WithFields(log.Fields{"namespace": we.Namespace, "podName": we.PodName})
+}
+
 // GetConfigMapKey retrieves a configmap value and memoizes the result
 func (we *WorkflowExecutor) GetConfigMapKey(ctx context.Context, name, key string) (string, error) {
 	namespace := we.Namespace
@@ -854,6 +907,22 @@ func unzip(zipPath string, destPath string) error {
 	return unpack(zipPath, destPath, decompressor)
 }
 
+// extractArtifactPath extracts the extract path of an input artifact from the archive it was loaded to. The format
+// of the archive is zip if its archive strategy is, and is otherwise detected by the extension of its key, or, if it
+// has none, its content.
+func extractArtifactPath(art *wfv1.Artifact, archivePath string, destPath string) error {
+	format := archive.FormatZip
+	if art.GetArchive().Zip == nil {
+		key, _ := art.GetKey()
+		var err error
+		format, err = archive.DetectFormat(archivePath, key)
+		if err != nil {
+			return err
+		}
+	}
+	return archive.ExtractPath(archivePath, format, art.ExtractPath, destPath)
+}
+
 // unpack unpacks a compressed file (tarball or zip file) to a temporary directory,
 // renaming it to the desired location
 // decompression is done using the decompressor closure, that should decompress a tarball or zip file
@@ -1107,11 +1176,26 @@ func LoadTemplate(path string) (*wfv1.Template, error) {
 // unmarshalAnnotationField unmarshals the value of an annotation key into the supplied interface
 // from the downward api annotation volume file
 func unmarshalAnnotationField(filePath string, key string, into interface{}) error {
+	fieldString, err := annotationField(filePath, key)
+	if err != nil {
+		return err
+	}
+	// The value of the annotation is itself JSON, e.g. `{"type":"container","inputs":{},"outputs":{}}`
+	err = json.Unmarshal([]byte(fieldString), into)
+	if err != nil {
+		log.Errorf("Error unmarshalling annotation into datastructure, %s, %v\n", fieldString, err)
+		return errors.InternalWrapError(err)
+	}
+	return nil
+}
+
+// annotationField returns the value of an annotation key from the downward api annotation volume file
+func annotationField(filePath string, key string) (string, error) {
 	// Read the annotation file
 	file, err := os.Open(filePath)
 	if err != nil {
 		log.Errorf("ERROR opening annotation file from %s", filePath)
-		return errors.InternalWrapError(err)
+		return "", errors.InternalWrapError(err)
 	}
 
 	defer func() {
@@ -1147,26 +1231,16 @@ func unmarshalAnnotationField(filePath string, key string, into interface{}) err
 			// Trim the prefix
 			content := strings.TrimPrefix(line, prefix)
 
-			// This part is a bit tricky in terms of unmarshalling
-			// The content in the file will be something like,
+			// The content in the file is quoted, e.g.
 			// `"{\"type\":\"container\",\"inputs\":{},\"outputs\":{}}"`
-			// which is required to unmarshal twice
-
-			// First unmarshal to a string without escaping characters
+			// so it is unmarshalled to a string without escaping characters
 			var fieldString string
 			err = json.Unmarshal([]byte(content), &fieldString)
 			if err != nil {
 				log.Errorf("Error unmarshalling annotation into string, %s, %v\n", content, err)
-				return errors.InternalWrapError(err)
-			}
-
-			// Second unmarshal to a template
-			err = json.Unmarshal([]byte(fieldString), into)
-			if err != nil {
-				log.Errorf("Error unmarshalling annotation into datastructure, %s, %v\n", fieldString, err)
-				return errors.InternalWrapError(err)
+				return "", errors.InternalWrapError(err)
 			}
-			return nil
+			return fieldString, nil
 		}
 
 		// The end of the annotation file
@@ -1176,9 +1250,9 @@ func unmarshalAnnotationField(filePath string, key string, into interface{}) err
 	}
 
 	if err != io.EOF {
-		return errors.InternalWrapError(err)
+		return "", errors.InternalWrapError(err)
 	}
 
 	// If we reach here, then the key does not exist in the file
-	return errors.Errorf(errors.CodeNotFound, "Key %s not found in annotation file: %s", key, filePath)
+	return "", errors.Errorf(errors.CodeNotFound, "Key %s not found in annotation file: %s", key, filePath)
 }
@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -334,3 +337,36 @@ func TestSaveArtifacts(t *testing.T) {
 	err = we.SaveArtifacts(ctx)
 	assert.Error(t, err)
 }
+
+func TestSaveArtifactFromFile_ExpandEnv(t *testing.T) {
+	var saved []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		saved = append(saved, r.URL.Path)
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "expand-env")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "my-file")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("my-data"), 0600))
+	assert.NoError(t, os.Setenv("ARTIFACT_SERVER", server.URL))
+	assert.NoError(t, os.Setenv("WORKSPACE", "workspaces/1"))
+	defer func() {
+		_ = os.Unsetenv("ARTIFACT_SERVER")
+		_ = os.Unsetenv("WORKSPACE")
+	}()
+	we := WorkflowExecutor{PodName: fakePodName, ClientSet: fake.NewSimpleClientset(), Namespace: fakeNamespace}
+	art := &wfv1.Artifact{
+		Name:             "my-art",
+		ExpandEnv:        true,
+		ArtifactLocation: wfv1.ArtifactLocation{HTTP: &wfv1.HTTPArtifact{URL: "${ARTIFACT_SERVER}/${WORKSPACE}/output", Upload: &wfv1.HTTPUpload{}}},
+	}
+	if assert.NoError(t, we.saveArtifactFromFile(context.Background(), art, "my-file", path)) {
+		assert.Equal(t, []string{"/workspaces/1/output"}, saved)
+		// the output is recorded with the location it was saved to, which is not expanded again
+		assert.Equal(t, server.URL+"/workspaces/1/output", art.HTTP.URL)
+		assert.False(t, art.ExpandEnv)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -22,7 +23,19 @@ import (
 	"github.com/argoproj/argo-workflows/v3/util"
 	"github.com/argoproj/argo-workflows/v3/util/intstr"
 	"github.com/argoproj/argo-workflows/v3/util/sorting"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/adls"
+	artifactscommon "github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/dropbox"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/encryption"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/filesystem"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/gcs"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/git"
 	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/hdfs"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/huggingface"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/oci"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/oss"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/s3"
+	"github.com/argoproj/argo-workflows/v3/workflow/artifacts/swift"
 	"github.com/argoproj/argo-workflows/v3/workflow/common"
 	"github.com/argoproj/argo-workflows/v3/workflow/metrics"
 	"github.com/argoproj/argo-workflows/v3/workflow/templateresolution"
@@ -489,6 +502,56 @@ func validateInputs(tmpl *wfv1.Template) (map[string]interface{}, error) {
 		if err != nil {
 			return nil, err
 		}
+		if art.IPFS != nil && art.IPFS.CID == "" {
+			return nil, errors.Errorf(errors.CodeBadRequest, "%s.ipfs.cid is required", errPrefix)
+		}
+		if art.DevNull != nil {
+			// nothing is saved to DevNull, so there is nothing to load
+			return nil, errors.Errorf(errors.CodeBadRequest, "%s.devNull is only valid for output artifacts", errPrefix)
+		}
+		if len(art.Metadata) > 0 {
+			// the metadata of an input artifact is that it was saved with, which is got from its storage
+			return nil, errors.Errorf(errors.CodeBadRequest, "%s.metadata is only valid for output artifacts", errPrefix)
+		}
+		if art.ExtractPath != "" && art.GetArchive().None != nil {
+			return nil, errors.Errorf(errors.CodeBadRequest, "%s.extractPath cannot be used with archive none", errPrefix)
+		}
+		if art.Checksum != nil {
+			err = artifactscommon.ValidateChecksum(fmt.Sprintf("%s.checksum", errPrefix), art.Checksum)
+			if err != nil {
+				return nil, err
+			}
+		}
+		err = artifactscommon.ValidateTimeout(errPrefix, &art)
+		if err != nil {
+			return nil, err
+		}
+		err = artifactscommon.ValidateBandwidthLimit(errPrefix, &art)
+		if err != nil {
+			return nil, err
+		}
+		err = artifactscommon.ValidateMaxSize(errPrefix, &art)
+		if err != nil {
+			return nil, err
+		}
+		err = artifactscommon.ValidateSelectLatest(errPrefix, &art)
+		if err != nil {
+			return nil, err
+		}
+		err = artifactscommon.ValidateExpandEnv(errPrefix, &art)
+		if err != nil {
+			return nil, err
+		}
+		err = encryption.ValidateEncryption(errPrefix, &art)
+		if err != nil {
+			return nil, err
+		}
+		if art.Fallbacks != nil {
+			err = validateFallbacks(fmt.Sprintf("%s.fallbacks", errPrefix), art.Fallbacks)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 	return scope, nil
 }
@@ -498,6 +561,146 @@ func validateArtifactLocation(errPrefix string, art wfv1.ArtifactLocation) error
 		if art.Git.Repo == "" {
 			return errors.Errorf(errors.CodeBadRequest, "%s.git.repo is required", errPrefix)
 		}
+		err := git.ValidateArtifact(fmt.Sprintf("%s.git", errPrefix), art.Git)
+		if err != nil {
+			return err
+		}
+	}
+	if art.SFTP != nil {
+		if art.SFTP.Host == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.sftp.host is required", errPrefix)
+		}
+		if art.SFTP.PasswordSecret == nil && art.SFTP.SSHPrivateKeySecret == nil {
+			return errors.Errorf(errors.CodeBadRequest, "either %s.sftp.passwordSecret or %s.sftp.sshPrivateKeySecret is required", errPrefix, errPrefix)
+		}
+	}
+	if art.GDrive != nil {
+		if art.GDrive.FileID == "" && art.GDrive.Path == "" {
+			return errors.Errorf(errors.CodeBadRequest, "either %s.gdrive.fileId or %s.gdrive.path is required", errPrefix, errPrefix)
+		}
+	}
+	if art.HTTP != nil {
+		if art.HTTP.BearerTokenSecret != nil && (art.HTTP.UsernameSecret != nil || art.HTTP.PasswordSecret != nil) {
+			return errors.Errorf(errors.CodeBadRequest, "%s.http.bearerTokenSecret cannot be used with basic auth", errPrefix)
+		}
+		if upload := art.HTTP.Upload; upload != nil {
+			if upload.Method != "" && upload.Method != http.MethodPut && upload.Method != http.MethodPost {
+				return errors.Errorf(errors.CodeBadRequest, "%s.http.upload.method must be PUT or POST", errPrefix)
+			}
+			if upload.Presigned && (art.HTTP.BearerTokenSecret != nil || art.HTTP.UsernameSecret != nil || art.HTTP.PasswordSecret != nil) {
+				return errors.Errorf(errors.CodeBadRequest, "%s.http.upload.presigned cannot be used with auth", errPrefix)
+			}
+		}
+		if redirects := art.HTTP.Redirects; redirects != nil && redirects.Limit != nil && *redirects.Limit < 0 {
+			return errors.Errorf(errors.CodeBadRequest, "%s.http.redirects.limit must not be negative", errPrefix)
+		}
+	}
+	if art.WebDAV != nil {
+		if art.WebDAV.URL == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.webdav.url is required", errPrefix)
+		}
+	}
+	if art.FTP != nil {
+		if art.FTP.URL == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.ftp.url is required", errPrefix)
+		}
+		if !strings.HasPrefix(art.FTP.URL, "ftp://") && !strings.HasPrefix(art.FTP.URL, "ftps://") {
+			return errors.Errorf(errors.CodeBadRequest, "%s.ftp.url must start with ftp:// or ftps://", errPrefix)
+		}
+	}
+	if art.B2 != nil {
+		if art.B2.Bucket == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.b2.bucket is required", errPrefix)
+		}
+		if art.B2.Key == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.b2.key is required", errPrefix)
+		}
+		if art.B2.ApplicationKeyIDSecret == nil || art.B2.ApplicationKeySecret == nil {
+			return errors.Errorf(errors.CodeBadRequest, "%s.b2.applicationKeyIDSecret and %s.b2.applicationKeySecret are required", errPrefix, errPrefix)
+		}
+	}
+	if art.Swift != nil {
+		if art.Swift.AuthURL == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.swift.authURL is required", errPrefix)
+		}
+		if art.Swift.Container == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.swift.container is required", errPrefix)
+		}
+		if art.Swift.Key == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.swift.key is required", errPrefix)
+		}
+		applicationCredential := art.Swift.ApplicationCredentialIDSecret != nil || art.Swift.ApplicationCredentialSecretSecret != nil
+		password := art.Swift.UsernameSecret != nil || art.Swift.PasswordSecret != nil || art.Swift.ProjectSecret != nil
+		switch {
+		case applicationCredential && password:
+			return errors.Errorf(errors.CodeBadRequest, "%s.swift cannot use both an application credential and a password", errPrefix)
+		case applicationCredential && (art.Swift.ApplicationCredentialIDSecret == nil || art.Swift.ApplicationCredentialSecretSecret == nil):
+			return errors.Errorf(errors.CodeBadRequest, "%s.swift.applicationCredentialIDSecret and %s.swift.applicationCredentialSecretSecret are required", errPrefix, errPrefix)
+		case !applicationCredential && (art.Swift.UsernameSecret == nil || art.Swift.PasswordSecret == nil || art.Swift.ProjectSecret == nil):
+			return errors.Errorf(errors.CodeBadRequest, "%s.swift.usernameSecret, %s.swift.passwordSecret and %s.swift.projectSecret, or an application credential, are required", errPrefix, errPrefix, errPrefix)
+		}
+		err := swift.ValidateArtifact(fmt.Sprintf("%s.swift", errPrefix), art.Swift)
+		if err != nil {
+			return err
+		}
+	}
+	if art.Rclone != nil {
+		if art.Rclone.Remote == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.rclone.remote is required", errPrefix)
+		}
+		if strings.Contains(art.Rclone.Remote, ":") {
+			return errors.Errorf(errors.CodeBadRequest, "%s.rclone.remote %q must be the name of a remote, without a colon", errPrefix, art.Rclone.Remote)
+		}
+		if art.Rclone.Path == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.rclone.path is required", errPrefix)
+		}
+		if (art.Rclone.ConfigSecret == nil) == (art.Rclone.ConfigConfigMap == nil) {
+			return errors.Errorf(errors.CodeBadRequest, "%s.rclone must have exactly one of configSecret or configConfigMap", errPrefix)
+		}
+	}
+	if art.Dropbox != nil {
+		if art.Dropbox.Path == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.dropbox.path is required", errPrefix)
+		}
+		if art.Dropbox.AppKeySecret == nil || art.Dropbox.RefreshTokenSecret == nil {
+			return errors.Errorf(errors.CodeBadRequest, "%s.dropbox.appKeySecret and %s.dropbox.refreshTokenSecret are required", errPrefix, errPrefix)
+		}
+		err := dropbox.ValidateArtifact(fmt.Sprintf("%s.dropbox", errPrefix), art.Dropbox)
+		if err != nil {
+			return err
+		}
+	}
+	if art.IPFS != nil {
+		if art.IPFS.URL == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.ipfs.url is required", errPrefix)
+		}
+		if art.IPFS.BearerTokenSecret != nil && (art.IPFS.UsernameSecret != nil || art.IPFS.PasswordSecret != nil) {
+			return errors.Errorf(errors.CodeBadRequest, "%s.ipfs.bearerTokenSecret cannot be used with basic auth", errPrefix)
+		}
+	}
+	if art.ADLS != nil {
+		err := adls.ValidateArtifact(fmt.Sprintf("%s.adls", errPrefix), art.ADLS)
+		if err != nil {
+			return err
+		}
+	}
+	if art.HuggingFace != nil {
+		err := huggingface.ValidateArtifact(fmt.Sprintf("%s.huggingface", errPrefix), art.HuggingFace)
+		if err != nil {
+			return err
+		}
+	}
+	if art.Filesystem != nil {
+		err := filesystem.ValidateArtifact(fmt.Sprintf("%s.filesystem", errPrefix), art.Filesystem)
+		if err != nil {
+			return err
+		}
+	}
+	if art.OCI != nil {
+		err := oci.ValidateArtifact(fmt.Sprintf("%s.oci", errPrefix), art.OCI)
+		if err != nil {
+			return err
+		}
 	}
 	if art.HDFS != nil {
 		err := hdfs.ValidateArtifact(fmt.Sprintf("%s.hdfs", errPrefix), art.HDFS)
@@ -505,10 +708,70 @@ func validateArtifactLocation(errPrefix string, art wfv1.ArtifactLocation) error
 			return err
 		}
 	}
+	if art.S3 != nil {
+		err := s3.ValidateArtifact(fmt.Sprintf("%s.s3", errPrefix), art.S3)
+		if err != nil {
+			return err
+		}
+	}
+	if art.GCS != nil {
+		err := gcs.ValidateArtifact(fmt.Sprintf("%s.gcs", errPrefix), art.GCS)
+		if err != nil {
+			return err
+		}
+	}
+	if art.OSS != nil {
+		err := oss.ValidateArtifact(fmt.Sprintf("%s.oss", errPrefix), art.OSS)
+		if err != nil {
+			return err
+		}
+	}
 	// TODO: validate other artifact locations
 	return nil
 }
 
+// validateMirrors validates the mirror locations of an output artifact, which must each have a key
+func validateMirrors(errPrefix string, mirrors *wfv1.ArtifactMirrors) error {
+	if len(mirrors.Locations) == 0 {
+		return errors.Errorf(errors.CodeBadRequest, "%s.locations must have at least one location", errPrefix)
+	}
+	for i, location := range mirrors.Locations {
+		locationPrefix := fmt.Sprintf("%s.locations[%d]", errPrefix, i)
+		if !location.HasLocation() {
+			return errors.Errorf(errors.CodeBadRequest, "%s must be a location with a key", locationPrefix)
+		}
+		err := validateArtifactLocation(locationPrefix, location)
+		if err != nil {
+			return err
+		}
+	}
+	if mirrors.Parallelism != nil && *mirrors.Parallelism < 1 {
+		return errors.Errorf(errors.CodeBadRequest, "%s.parallelism must be at least 1", errPrefix)
+	}
+	return nil
+}
+
+// validateFallbacks validates the fallback locations of an input artifact, which must each have a key
+func validateFallbacks(errPrefix string, fallbacks *wfv1.ArtifactFallbacks) error {
+	if len(fallbacks.Locations) == 0 {
+		return errors.Errorf(errors.CodeBadRequest, "%s.locations must have at least one location", errPrefix)
+	}
+	for i, location := range fallbacks.Locations {
+		locationPrefix := fmt.Sprintf("%s.locations[%d]", errPrefix, i)
+		if !location.HasLocation() {
+			return errors.Errorf(errors.CodeBadRequest, "%s must be a location with a key", locationPrefix)
+		}
+		if location.DevNull != nil {
+			return errors.Errorf(errors.CodeBadRequest, "%s.devNull is only valid for output artifacts", locationPrefix)
+		}
+		err := validateArtifactLocation(locationPrefix, location)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // resolveAllVariables is a helper to ensure all {{variables}} are resolveable from current scope
 func resolveAllVariables(scope map[string]interface{}, tmplStr string) error {
 	var unresolvedErr error
@@ -887,6 +1150,97 @@ func validateOutputs(scope map[string]interface{}, tmpl *wfv1.Template) error {
 				return errors.Errorf(errors.CodeBadRequest, "templates.%s.%s.globalName: %s", tmpl.Name, artRef, errs[0])
 			}
 		}
+		err = artifactscommon.ValidateFileFilter(fmt.Sprintf("templates.%s.%s", tmpl.Name, artRef), &art)
+		if err != nil {
+			return err
+		}
+		err = artifactscommon.ValidateSymlinks(fmt.Sprintf("templates.%s.%s", tmpl.Name, artRef), &art)
+		if err != nil {
+			return err
+		}
+		err = artifactscommon.ValidateExpandEnv(fmt.Sprintf("templates.%s.%s", tmpl.Name, artRef), &art)
+		if err != nil {
+			return err
+		}
+		if art.SelectLatest != "" {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.%s.selectLatest is only valid for input artifacts", tmpl.Name, artRef)
+		}
+		err = artifactscommon.ValidateCompression(fmt.Sprintf("templates.%s.%s", tmpl.Name, artRef), art.Compression)
+		if err != nil {
+			return err
+		}
+		err = artifactscommon.ValidateOverwrite(fmt.Sprintf("templates.%s.%s", tmpl.Name, artRef), art.Overwrite)
+		if err != nil {
+			return err
+		}
+		err = artifactscommon.ValidateContentType(fmt.Sprintf("templates.%s.%s", tmpl.Name, artRef), &art)
+		if err != nil {
+			return err
+		}
+		err = artifactscommon.ValidateManifest(fmt.Sprintf("templates.%s.%s", tmpl.Name, artRef), art.Manifest)
+		if err != nil {
+			return err
+		}
+		err = artifactscommon.ValidateTimeout(fmt.Sprintf("templates.%s.%s", tmpl.Name, artRef), &art)
+		if err != nil {
+			return err
+		}
+		err = artifactscommon.ValidateBandwidthLimit(fmt.Sprintf("templates.%s.%s", tmpl.Name, artRef), &art)
+		if err != nil {
+			return err
+		}
+		err = artifactscommon.ValidateMaxSize(fmt.Sprintf("templates.%s.%s", tmpl.Name, artRef), &art)
+		if err != nil {
+			return err
+		}
+		err = artifactscommon.ValidateMetadata(fmt.Sprintf("templates.%s.%s", tmpl.Name, artRef), &art)
+		if err != nil {
+			return err
+		}
+		err = encryption.ValidateEncryption(fmt.Sprintf("templates.%s.%s", tmpl.Name, artRef), &art)
+		if err != nil {
+			return err
+		}
+		err = s3.ValidateAtomicSave(fmt.Sprintf("templates.%s.%s", tmpl.Name, artRef), &art)
+		if err != nil {
+			return err
+		}
+		if art.S3 != nil && art.S3.VersionID != "" {
+			// saving an object creates its version, so the version of an output artifact cannot be chosen
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.%s.s3.versionID is only valid for input artifacts", tmpl.Name, artRef)
+		}
+		if art.ExtractPath != "" {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.%s.extractPath is only valid for input artifacts", tmpl.Name, artRef)
+		}
+		if art.S3 != nil && art.S3.Anonymous {
+			// objects can only be saved with credentials
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.%s.s3.anonymous is only valid for input artifacts", tmpl.Name, artRef)
+		}
+		if art.GCS != nil && art.GCS.Anonymous {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.%s.gcs.anonymous is only valid for input artifacts", tmpl.Name, artRef)
+		}
+		if art.IPFS != nil && art.IPFS.CID != "" {
+			// the CID of an output artifact is that of what is saved, which is only known once it has been
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.%s.ipfs.cid is only valid for input artifacts", tmpl.Name, artRef)
+		}
+		if art.OCI != nil && art.OCI.Digest != "" {
+			// the digest of an output artifact is that of the manifest which is pushed, which is only known once it has been
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.%s.oci.digest is only valid for input artifacts", tmpl.Name, artRef)
+		}
+		if art.HuggingFace != nil && art.HuggingFace.TokenSecret == nil {
+			// the Hub only accepts commits which are authorized with a token
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.%s.huggingface.tokenSecret is required for output artifacts", tmpl.Name, artRef)
+		}
+		if art.Fallbacks != nil {
+			// an output artifact is saved to every location, with mirrors, rather than to the first it can be
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.%s.fallbacks is only valid for input artifacts", tmpl.Name, artRef)
+		}
+		if art.Mirrors != nil {
+			err = validateMirrors(fmt.Sprintf("templates.%s.%s.mirrors", tmpl.Name, artRef), art.Mirrors)
+			if err != nil {
+				return err
+			}
+		}
 	}
 	for _, param := range tmpl.Outputs.Parameters {
 		paramRef := fmt.Sprintf("templates.%s.outputs.parameters.%s", tmpl.Name, param.Name)